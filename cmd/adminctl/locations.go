@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/tabular"
+)
+
+// importLocations loads a Kemendagri region code and coordinate file into the reference
+// tables as POST /api/admin/locations/import does, printing the diff
+func importLocations(cfg *config.Config, args []string) error {
+	fs := newFlagSet("import-locations", "[-dry-run] [-all] FILE")
+	dryRun := fs.Bool("dry-run", false, "only print the diff")
+	all := fs.Bool("all", false, "print the unchanged and skipped rows too")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	table, err := tabular.Read(path, data, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	imports := services.NewLocationImportService(repositories.NewLocationRepository(db), repositories.NewLocationImportRepository(db))
+	origin := models.LocationImport{Filename: filepath.Base(path), Source: models.LocationImportSourceAdminctl}
+	result, err := imports.Import(ctx, table, *dryRun, origin)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range result.Rows {
+		if !*all && (row.Action == models.RegionUnchanged || row.Action == models.RegionSkipped) {
+			continue
+		}
+		fmt.Printf("row %d\t%s\t%s %s\t%s\n", row.Row, row.Action, row.Kind, row.Code, row.Name)
+		for _, field := range sortedKeys(row.Changes) {
+			fmt.Printf("\t%s: %v -> %v\n", field, printable(row.Changes[field].Old), printable(row.Changes[field].New))
+		}
+		for _, field := range sortedKeys(row.Errors) {
+			fmt.Printf("\t%s: %s\n", field, row.Errors[field].Message)
+		}
+	}
+	fmt.Printf("%d rows: %d to create, %d to update, %d unchanged, %d skipped, %d invalid\n",
+		result.Total, result.Created, result.Updated, result.Unchanged, result.Skipped, result.Invalid)
+
+	switch {
+	case result.Invalid > 0:
+		return errors.New("nothing was imported, correct the invalid rows")
+	case result.Committed:
+		if err := audit(ctx, db, "CREATE", "location_imports", *result.ImportID, map[string]any{
+			"filename": origin.Filename, "created": result.Created, "updated": result.Updated}); err != nil {
+			return err
+		}
+		invalidate(cfg, events.Event{Entity: "locations", Action: events.ActionUpdated})
+		fmt.Printf("Applied as import %d; undo it with: adminctl rollback-locations %d\n", *result.ImportID, *result.ImportID)
+	case *dryRun:
+		fmt.Println("Dry run, nothing was written")
+	}
+	return nil
+}
+
+// rollbackLocations restores what the latest reference data import created or changed
+func rollbackLocations(cfg *config.Config, args []string) error {
+	fs := newFlagSet("rollback-locations", "IMPORT_ID")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	imports := services.NewLocationImportService(repositories.NewLocationRepository(db), repositories.NewLocationImportRepository(db))
+	imp, err := imports.Rollback(ctx, fs.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+	if err := audit(ctx, db, "UPDATE", "location_imports", imp.ID, map[string]any{"status": imp.Status}); err != nil {
+		return err
+	}
+	invalidate(cfg, events.Event{Entity: "locations", Action: events.ActionUpdated})
+	fmt.Printf("Rolled back import %d (%s): removed the %d regions it created, restored the %d it updated\n", imp.ID, imp.Filename, imp.Created, imp.Updated)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printable dereferences the pointers a change holds; nil is shown as -
+func printable(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *float64:
+		if v != nil {
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	case *int:
+		if v != nil {
+			return *v
+		}
+	case *string:
+		if v != nil {
+			return *v
+		}
+	default:
+		if v != nil {
+			return v
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,111 @@
+// Command adminctl performs the administrative operations that would otherwise take manual
+// SQL or Redis commands: creating the first administrator, resetting a password, rotating the
+// JWT signing key, running migrations, flushing caches and loading region reference data.
+//
+//	go run ./cmd/adminctl create-admin -username root -email root@example.com
+//	go run ./cmd/adminctl reset-password -username root
+//	go run ./cmd/adminctl rotate-jwt-secret
+//	go run ./cmd/adminctl migrate up
+//	go run ./cmd/adminctl flush-cache menus users
+//	go run ./cmd/adminctl import-locations -dry-run wilayah.csv
+//	go run ./cmd/adminctl rollback-locations 12
+//
+// It reads configs/config.yaml and the environment (and .env) like the server, but only
+// the sections a command uses need to be valid.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+)
+
+// command is one adminctl subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
+
+var commands = []command{
+	{"create-admin", "create a user holding the admin role, e.g. the first one", createAdmin},
+	{"reset-password", "set a new password for a user", resetPassword},
+	{"rotate-jwt-secret", "add a new JWT signing key to JWT_KEYS in .env, retiring the old one", rotateJWTSecret},
+	{"migrate", "apply, revert or list schema migrations, as cmd/migrate", runMigrate},
+	{"flush-cache", "delete cached entries from Redis, by namespace or all", flushCache},
+	{"import-locations", "load Kemendagri region codes and coordinates from CSV or XLSX, with a diff", importLocations},
+	{"rollback-locations", "undo the latest region import", rollbackLocations},
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, "Usage: adminctl <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprint(os.Stderr, "\nRun adminctl <command> -h for the flags of a command.\n")
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// A missing .env is normal when the environment is set otherwise
+	_ = config.LoadEnvFile(config.DefaultEnvFile)
+	cfg, err := config.Read(config.DefaultPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	name := flag.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(cfg, flag.Args()[1:]); err != nil {
+				log.Fatalf("%s: %v", name, err)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// newFlagSet returns the flags of a command, exiting on -h or a bad flag
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: adminctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// openDB connects to the database the configuration selects
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	if err := cfg.Database.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+	dialect, err := database.UseDialect(cfg.Database.Driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(dialect.DriverName(), cfg.Database.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	return db, nil
+}
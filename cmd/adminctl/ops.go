@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/migrate"
+	"adminbe/migrations"
+)
+
+// rotateJWTSecret puts a new random key in front of JWT_KEYS in the env file. The key that
+// signed until now is stamped with the time it stopped, so it keeps verifying its tokens
+// until they expire, and keys whose tokens have all expired are dropped.
+func rotateJWTSecret(cfg *config.Config, args []string) error {
+	fs := newFlagSet("rotate-jwt-secret", "[-env-file .env] [-print]")
+	envFile := fs.String("env-file", ".env", "file to write JWT_KEYS to")
+	printOnly := fs.Bool("print", false, "print the new JWT_KEYS instead of writing it")
+	fs.Parse(args)
+
+	keys, err := jwtkeys.ParseKeys(cfg.JWT.Keys)
+	if err != nil {
+		return fmt.Errorf("JWT_KEYS: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	key := jwtkeys.Key{ID: now.Format("20060102-150405"), Secret: []byte(base64.StdEncoding.EncodeToString(secret))}
+
+	entries := []string{jwtkeys.FormatKey(key)}
+	for i, old := range keys {
+		if i == 0 {
+			old.RetiredAt = now
+		}
+		if old.ID == key.ID || old.Expired(now, cfg.JWT.Expiration) {
+			continue
+		}
+		entries = append(entries, jwtkeys.FormatKey(old))
+	}
+	value := strings.Join(entries, ",")
+	if *printOnly {
+		fmt.Println(value)
+		return nil
+	}
+
+	if err := setEnvVar(*envFile, "JWT_KEYS", value); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote JWT_KEYS to %s; key %q signs from the next reload\n", *envFile, key.ID)
+	fmt.Println("Reload every instance (kill -HUP, or POST /api/admin/runtime/reload); signed in users stay signed in.")
+	if len(keys) == 0 && cfg.JWT.Secret != "" {
+		fmt.Printf("JWT_SECRET still verifies the tokens issued before; remove it after %s.\n",
+			now.Add(cfg.JWT.Expiration).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// setEnvVar sets name to value in the env file, replacing its line or adding one
+func setEnvVar(envFile, name, value string) error {
+	mode := os.FileMode(0o600)
+	var lines []string
+	data, err := os.ReadFile(envFile)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(envFile); err == nil {
+			mode = info.Mode().Perm()
+		}
+		lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	replaced := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimPrefix(strings.TrimSpace(line), "export "), name+"=") {
+			lines[i] = name + "=" + value
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, name+"="+value)
+	}
+
+	// Written beside the file and renamed over it, so a failure never leaves it half written
+	tmp := envFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, envFile); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// runMigrate runs the commands of cmd/migrate
+func runMigrate(cfg *config.Config, args []string) error {
+	fs := newFlagSet("migrate", "up | down [N] | status | force VERSION")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	fsys, err := migrations.ForDriver(database.Current.Name())
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(db, fsys)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch sub := fs.Arg(0); sub {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			if steps, err = strconv.Atoi(fs.Arg(1)); err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count %q", fs.Arg(1))
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migration(s)\n", count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Dirty {
+				state = "DIRTY"
+			} else if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-35s %s\n", s.Version, s.Name, state)
+		}
+
+	case "force":
+		if fs.NArg() < 2 {
+			return errors.New("force requires a version")
+		}
+		version, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", fs.Arg(1))
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("Schema forced to version %d\n", version)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", sub)
+	}
+	return nil
+}
+
+// flushCache deletes the given cache namespaces from Redis, or every cached entry with -all.
+// Instances with a local tier keep their copies until CACHE_LOCAL_TTL runs out.
+func flushCache(cfg *config.Config, args []string) error {
+	fs := newFlagSet("flush-cache", "-all | NAMESPACE...")
+	all := fs.Bool("all", false, "delete every cached entry, idempotency records included")
+	fs.Parse(args)
+	if *all == (fs.NArg() > 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if !cfg.Redis.Enabled {
+		return errors.New("REDIS_ENABLED is false: each instance caches in memory, restart them instead")
+	}
+	if err := cfg.Redis.Validate(); err != nil {
+		return err
+	}
+	client, err := database.NewRedisClient(cfg.Redis)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	store := cache.NewRedisCache(client)
+	patterns := []string{cache.CacheKeyPrefix + "*"}
+	if !*all {
+		patterns = patterns[:0]
+		for _, ns := range fs.Args() {
+			ns = strings.Trim(ns, ":*")
+			if ns == "" {
+				return errors.New("empty namespace")
+			}
+			patterns = append(patterns, cache.CacheKeyPrefix+ns+":*")
+		}
+	}
+	for _, pattern := range patterns {
+		if err := store.DeletePattern(pattern); err != nil {
+			return fmt.Errorf("flushing %s: %w", pattern, err)
+		}
+		fmt.Printf("Flushed %s\n", pattern)
+	}
+	return nil
+}
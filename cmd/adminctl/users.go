@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/validation"
+)
+
+// minPasswordLength matches the binding of CreateUserRequest
+const minPasswordLength = 6
+
+// createAdmin creates a user with the admin role, creating the role too on an empty database
+func createAdmin(cfg *config.Config, args []string) error {
+	fs := newFlagSet("create-admin", "-username NAME -email ADDRESS [-password-stdin]")
+	username := fs.String("username", "", "username (required)")
+	email := fs.String("email", "", "email address (required)")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin instead of generating one")
+	fs.Parse(args)
+
+	if !validation.IsUsername(*username) || len(*username) < 3 || len(*username) > 100 {
+		return errors.New("-username must be 3-100 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	if _, err := mail.ParseAddress(*email); err != nil {
+		return fmt.Errorf("-email is not a valid address: %w", err)
+	}
+	plain, generated, err := newPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := cfg.Password.Validate(); err != nil {
+		return err
+	}
+	hash, err := password.NewHasher(cfg.Password).Hash(context.Background(), plain)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	users := repositories.NewUserRepository(db)
+	roles := repositories.NewRoleRepository(db)
+	userRoles := repositories.NewUserRoleRepository(db)
+	var userID uint64
+	err = repositories.NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		role, err := roles.GetByName(ctx, middleware.RoleAdmin)
+		var roleID uint
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			now := time.Now()
+			description := "Administrator, passes every role check"
+			if roleID, err = roles.Create(ctx, models.Role{Name: middleware.RoleAdmin, Description: &description, CreatedAt: &now, UpdatedAt: &now}); err != nil {
+				return err
+			}
+			fmt.Printf("Created role %q\n", middleware.RoleAdmin)
+		case err != nil:
+			return err
+		default:
+			roleID = role.ID
+		}
+
+		if userID, err = users.Create(ctx, models.CreateUserRequest{Username: *username, Email: *email}, hash); err != nil {
+			return err
+		}
+		return userRoles.Create(ctx, models.UserRole{UserID: userID, RoleID: roleID})
+	})
+	if err != nil {
+		return err
+	}
+	if err := audit(ctx, db, "CREATE", "users", userID, map[string]any{"username": *username, "email": *email, "roles": []string{middleware.RoleAdmin}}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created user %q (id %d) with the %s role\n", *username, userID, middleware.RoleAdmin)
+	if generated {
+		fmt.Printf("Password: %s\nIt is shown once; change it after the first login.\n", plain)
+	}
+	return nil
+}
+
+// resetPassword replaces a user's password hash and drops the user's cached record
+func resetPassword(cfg *config.Config, args []string) error {
+	fs := newFlagSet("reset-password", "-username NAME [-password-stdin]")
+	username := fs.String("username", "", "username (required)")
+	fromStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin instead of generating one")
+	fs.Parse(args)
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+	plain, generated, err := newPassword(*fromStdin)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := cfg.Password.Validate(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	users := repositories.NewUserRepository(db)
+	user, err := users.GetByUsername(ctx, *username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user %q", *username)
+	}
+	if err != nil {
+		return err
+	}
+	hash, err := password.NewHasher(cfg.Password).Hash(ctx, plain)
+	if err != nil {
+		return err
+	}
+	if err := users.Update(ctx, user.ID, models.UpdateUserRequest{Password: plain}, hash); err != nil {
+		return err
+	}
+	if err := audit(ctx, db, "UPDATE", "users", user.ID, map[string]any{"password": "reset"}); err != nil {
+		return err
+	}
+	invalidate(cfg, events.Event{Entity: "users", Action: events.ActionUpdated, ID: strconv.FormatUint(user.ID, 10)})
+
+	fmt.Printf("Reset the password of %q (id %d)\n", *username, user.ID)
+	if generated {
+		fmt.Printf("Password: %s\nIt is shown once; change it after the next login.\n", plain)
+	}
+	return nil
+}
+
+// newPassword reads the password from stdin, or generates one when fromStdin is false
+func newPassword(fromStdin bool) (plain string, generated bool, err error) {
+	if !fromStdin {
+		b := make([]byte, 15)
+		if _, err := rand.Read(b); err != nil {
+			return "", false, err
+		}
+		return base64.RawURLEncoding.EncodeToString(b), true, nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false, fmt.Errorf("failed to read the password from stdin: %w", err)
+	}
+	plain = strings.TrimRight(line, "\r\n")
+	if len(plain) < minPasswordLength {
+		return "", false, fmt.Errorf("the password must be at least %d characters", minPasswordLength)
+	}
+	return plain, false, nil
+}
+
+// audit records a change made from the command line; it has no user, the new values name
+// adminctl as the source
+func audit(ctx context.Context, db *sql.DB, eventType, table string, recordID uint64, values map[string]any) error {
+	values["source"] = "adminctl"
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO audit_logs (event_type, table_name, record_id, new_values) VALUES (?, ?, ?, ?)",
+		eventType, table, recordID, data)
+	return err
+}
+
+// invalidate drops the cached entries of a change from Redis and tells the running
+// instances, which clear their local copies. Nothing is cached across restarts without Redis.
+func invalidate(cfg *config.Config, change events.Event) {
+	if !cfg.Redis.Enabled {
+		return
+	}
+	client, err := database.NewRedisClient(cfg.Redis)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cached %s not invalidated: %v\n", change.Entity, err)
+		return
+	}
+	defer client.Close()
+
+	bus := events.NewBus()
+	cache.RegisterInvalidation(bus, cache.NewRedisCache(client))
+	bus.AttachRedis(client, events.DefaultChannel)
+	defer bus.Close()
+	bus.Publish(change)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/migrate"
+	"adminbe/migrations"
+)
+
+const usage = `Usage: migrate <command> [args]
+
+Commands:
+  up              apply all pending migrations
+  down [N]        revert the last N applied migrations (default 1)
+  status          list migrations and whether they are applied
+  force VERSION   mark VERSION and earlier as applied and clean (after a manual fix)
+
+Connection settings are the database section of configs/config.yaml, overridden by
+DB_DRIVER (mysql or postgres), DB_HOST, DB_PORT, DB_USER, DB_PASSWORD and DB_NAME.
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.LoadEnvFile(config.DefaultEnvFile); err != nil {
+		log.Printf("No .env file found, using environment variables: %v", err)
+	}
+
+	// Only the database section matters here, so the rest of the configuration (a JWT
+	// secret, say) need not be set to run migrations
+	cfg, err := config.Read(config.DefaultPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+	dialect, err := database.UseDialect(cfg.Database.Driver)
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+
+	db, err := sql.Open(dialect.DriverName(), cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	fsys, err := migrations.ForDriver(dialect.Name())
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator, err := migrate.New(db, fsys)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("Invalid step count %q", flag.Arg(1))
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		fmt.Printf("Reverted %d migration(s)\n", count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Dirty {
+				state = "DIRTY"
+			} else if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-35s %s\n", s.Version, s.Name, state)
+		}
+
+	case "force":
+		if flag.NArg() < 2 {
+			log.Fatal("force requires a version")
+		}
+		version, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid version %q", flag.Arg(1))
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Fatalf("Force failed: %v", err)
+		}
+		fmt.Printf("Schema forced to version %d\n", version)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,81 @@
+// Command openapi prints the API specification, or checks it against the router.
+//
+// The router is built by handlers.SetupRoutes as the server builds it, over a database
+// handle that never connects, so no database is needed:
+//
+//	go run ./cmd/openapi > openapi.json   # write the document
+//	go run ./cmd/openapi -check           # fail when a route is undocumented or one is stale
+//
+// go test runs the same check as TestAPISpecCoversRoutes in the handlers package.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"adminbe/internal/app/handlers"
+	"adminbe/internal/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	check := flag.Bool("check", false, "compare the document with the registered routes instead of printing it")
+	flag.Parse()
+	log.SetFlags(0)
+
+	// Routes register their deprecations as SetupRoutes runs, so build the router first
+	r, err := router()
+	if err != nil {
+		log.Fatalf("Failed to build the router: %v", err)
+	}
+	spec := handlers.APISpec()
+	if !*check {
+		b, err := spec.JSON()
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return
+	}
+
+	undocumented, stale := spec.Missing(r.Routes())
+	for _, route := range undocumented {
+		fmt.Printf("undocumented: %s (add it to handlers.APISpec)\n", route)
+	}
+	for _, route := range stale {
+		fmt.Printf("stale: %s (no such route)\n", route)
+	}
+	if len(undocumented) > 0 || len(stale) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("All %d routes are documented\n", len(r.Routes()))
+}
+
+// router registers every route on a fresh engine. sql.Open only validates its arguments and
+// gorm is told not to ping, so nothing dials the database.
+func router() (*gin.Engine, error) {
+	sqlDB, err := sql.Open("mysql", "root@tcp(127.0.0.1:3306)/db_cms?parseTime=True")
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		return nil, err
+	}
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	r := gin.New()
+	// The routes do not depend on configuration, so the defaults do
+	cfg := config.Default()
+	handlers.SetupRoutes(r, db, handlers.NewServices(db, cfg), cfg)
+	return r, nil
+}
@@ -1,53 +1,309 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"adminbe/internal/app/grpcapi"
 	"adminbe/internal/app/handlers"
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/pkg/alerting"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/config"
 	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/errortracking"
+	"adminbe/internal/pkg/fieldcrypt"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/push"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/slo"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/storage"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
+// cleanupTimeout is how long flushing and closing may take after SHUTDOWN_TIMEOUT, which
+// bounds draining the listeners
+const cleanupTimeout = 10 * time.Second
+
 func main() {
-	err := godotenv.Load()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the server and serves until SIGINT or SIGTERM, then shuts down gracefully;
+// SIGHUP reloads the configuration.
+// Cleanup is deferred, so once both listeners are drained and the background jobs stopped it
+// happens in reverse order of startup: domain events, webhooks, audit logs, then the database and Redis connections.
+func run() error {
+	err := config.LoadEnvFile(config.DefaultEnvFile)
 	if err != nil {
 		log.Printf("No .env file found, using environment variables: %v", err)
 	}
 
+	// Every setting, from config.yaml and the environment, checked before anything starts
+	cfg, err := config.Load(config.DefaultPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Structured logs from here on; the standard log package is routed through the same logger
+	logging.Setup(os.Stderr, cfg.Logging)
+
+	// Panics and 5xx errors to a Sentry-compatible service, when ERROR_TRACKING_ENABLED=true
+	if err := errortracking.Init(cfg.ErrorTracking); err != nil {
+		log.Fatalf("Failed to initialize error tracking: %v", err)
+	}
+	defer errortracking.Flush(2 * time.Second)
+
+	// Operational alerts to Slack and Teams, from the SLO evaluator, the checks and failed jobs
+	alerting.Default.Configure(cfg.Alerting)
+
 	gin.SetMode(gin.ReleaseMode)
 
-	r := gin.Default()
-	r.Use(cors.Default())
+	// gin.New rather than gin.Default: access logging and panic recovery are structured
+	// middleware registered by SetupRoutes
+	r := gin.New()
+	// Client addresses (logs, per-address rate limits, the IP filter) from X-Forwarded-For
+	// only when the load balancer sent it. gin trusts every sender unless told otherwise, so
+	// without TRUSTED_PROXIES none is trusted and the address is the connection's.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(middleware.CORSMiddleware(cfg.CORS))
+
+	// In-process SLO burn-rate alerts, configured in the slo section of config.yaml
+	if cfg.SLO.Enabled {
+		evaluator := slo.NewEvaluator(cfg.SLO, alerting.Default)
+		r.Use(middleware.SLOMiddleware(evaluator))
+		evaluator.Start()
+		defer evaluator.Stop()
+	}
 
-	db := database.ConnectDB()
+	db := database.ConnectDB(cfg.Database, cfg.Redis)
 	defer func() {
-		sqlDB, _ := db.DB()
-		sqlDB.Close()
+		if err := database.Close(db); err != nil {
+			slog.Warn("Failed to close database connections", "error", err)
+		}
 	}()
 
+	if err := jwtkeys.Default.Configure(cfg.JWT.Secret, cfg.JWT.Keys, cfg.JWT.Expiration); err != nil {
+		return err
+	}
+	if err := fieldcrypt.Default.Configure(cfg.Encryption); err != nil {
+		return err
+	}
+	if err := ipfilter.Default.Configure(cfg.IPFilter); err != nil {
+		return err
+	}
+	secevents.Default.Configure(cfg.SecurityEvents)
+	if err := challenge.Default.Configure(cfg.Challenge); err != nil {
+		return err
+	}
+	if err := mail.Default.Configure(cfg.Mail); err != nil {
+		return err
+	}
+	chatbot.Default.Configure(cfg.Chatbot)
+	if err := push.Default.Configure(cfg.Push); err != nil {
+		return err
+	}
+	if err := storage.Default.Configure(cfg.Storage); err != nil {
+		return err
+	}
+	if err := geocode.Default.Configure(cfg.Geocoder); err != nil {
+		return err
+	}
+	if err := calendar.Default.Configure(cfg.Calendar); err != nil {
+		return err
+	}
+	if err := sms.Default.Configure(cfg.SMS); err != nil {
+		return err
+	}
+
 	// Initialize JasperServer client
-	err = handlers.InitJasperClient("configs/config.yaml")
+	handlers.InitJasperClient(cfg.Jasper)
+
+	// Schema, tables, Redis, JWT secret strength and JasperServer, checked before serving
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Printf("Failed to initialize JasperServer client: %v", err)
+		return err
+	}
+	if err := handlers.RunStartupChecks(sqlDB, cfg); err != nil {
+		if cfg.Startup.FailFast {
+			return fmt.Errorf("startup self-check failed (STARTUP_FAIL_FAST=false starts anyway): %w", err)
+		}
+		slog.Warn("Starting despite failed startup checks", "error", err)
 	}
 
 	// Start async audit logging system
 	handlers.StartAuditLogger()
 	defer handlers.StopAuditLogger()
 
-	handlers.SetupRoutes(r, db)
+	// Audit queue saturation and JasperServer outages, every ALERT_CHECK_INTERVAL
+	monitor := handlers.NewOpsMonitor(alerting.Default, cfg.Alerting)
+	monitor.Start()
+	defer monitor.Stop()
+
+	// One set of services behind both listeners
+	svc := handlers.NewServices(db, cfg)
+	defer svc.Webhooks.Close()
+	defer svc.Mail.Close()
+	defer svc.Push.Close()
+	defer svc.Events.Close()
+	handlers.SetupRoutes(r, db, svc, cfg)
+	// Recurring jobs; JOBS_ENABLED=false leaves them to be run by hand
+	if cfg.Jobs.Enabled {
+		svc.Jobs.Start()
+	}
+
+	// gRPC for internal consumers on its own port; GRPC_PORT=0 turns it off
+	var grpcServer *grpc.Server
+	grpcPort := cfg.Server.GRPCPort
+	if grpcPort != "0" {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcServer = grpcapi.NewServer(grpcapi.Services{
+			Users:         svc.Users,
+			Roles:         svc.Roles,
+			UserRoles:     svc.UserRoles,
+			Prayer:        svc.Prayer,
+			Tenants:       svc.Tenants,
+			LocationCodes: svc.LocationCodes,
+		})
+		slog.Info("gRPC server starting", "port", grpcPort)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal("gRPC server failed:", err)
+			}
+		}()
+	}
+
+	// HEAD requests are answered by the GET routes
+	plain, secure, err := newServers(cfg.Server, middleware.ServeHead(r))
+	if err != nil {
+		return err
+	}
+	servers := []*http.Server{plain}
+	if secure != nil {
+		servers = append(servers, secure)
+	}
+	// Event streams and WebSockets would otherwise keep the drain waiting until the timeout
+	for _, srv := range servers {
+		srv.RegisterOnShutdown(handlers.Drain)
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// SIGHUP reloads the env file and config.yaml, applying what can change while serving
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	defer signal.Stop(reloads)
+	go func() {
+		for range reloads {
+			if _, err := svc.Config.Reload("SIGHUP"); err != nil {
+				slog.Error("Configuration reload failed, keeping the current settings", "error", err)
+			}
+		}
+	}()
+
+	// Credentials from a secrets manager are fetched again, so rotating them needs no reload
+	if interval := cfg.Secrets.RefreshInterval; interval > 0 && cfg.UsesSecrets() {
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		defer stopWatch()
+		go svc.Config.WatchSecrets(watchCtx, interval)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	served := make(chan error, len(servers))
+	go func() {
+		slog.Info("Server starting", "port", cfg.Server.Port)
+		served <- plain.ListenAndServe()
+	}()
+	if secure != nil {
+		go func() {
+			slog.Info("HTTPS server starting", "port", cfg.Server.TLS.Port)
+			// The certificate comes from TLSConfig
+			served <- secure.ListenAndServeTLS("", "")
+		}()
 	}
 
-	log.Println("Server starting on port", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	select {
+	case err := <-served:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process at once
+	stopSignals()
+
+	timeout := cfg.Server.ShutdownTimeout
+	slog.Info("Shutting down, draining in-flight requests", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// The deferred cleanup may block too (flushing audit logs, webhooks or events to a slow
+	// database or broker), so it is bounded as well rather than left to hang the process
+	deadline := time.AfterFunc(timeout+cleanupTimeout, func() {
+		slog.Error("Shutdown timed out, exiting", "timeout", timeout+cleanupTimeout)
+		os.Exit(1)
+	})
+	defer deadline.Stop()
+
+	shutdownHTTP(shutdownCtx, servers)
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	// Running jobs are cancelled and get what is left of the timeout to return
+	svc.Jobs.Stop(shutdownCtx)
+	slog.Info("Servers stopped, closing connections")
+	return nil
+}
+
+// shutdownHTTP drains the servers together, cutting off the requests still running when
+// ctx ends
+func shutdownHTTP(ctx context.Context, servers []*http.Server) {
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				slog.Warn("Requests still running at the shutdown timeout were cut off", "addr", srv.Addr, "error", err)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// stopGRPC stops s gracefully, cutting off the calls still running when ctx ends
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
 	}
 }
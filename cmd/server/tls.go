@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"adminbe/internal/pkg/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newServers returns the HTTP server on PORT and, when TLS is configured, the HTTPS one on
+// TLS_PORT serving handler over HTTP/1.1 and HTTP/2. With TLS the plain server redirects to
+// HTTPS unless TLS_REDIRECT_HTTP=false, and answers ACME HTTP-01 challenges for autocert.
+func newServers(cfg config.Server, handler http.Handler) (plain, secure *http.Server, err error) {
+	plain = &http.Server{Addr: ":" + cfg.Port, Handler: handler}
+	if !cfg.TLS.Enabled() {
+		return plain, nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLS.CertFile != "" {
+		// Loaded once: a renewed certificate takes effect on restart
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLS.RedirectHTTP {
+		plain.Handler = redirectToHTTPS(cfg.TLS.Port)
+	}
+	if len(cfg.TLS.AutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+			Email:      cfg.TLS.AutocertEmail,
+		}
+		// Also enables the TLS-ALPN-01 challenge, and h2 and http/1.1 in NextProtos
+		tlsConfig = manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		plain.Handler = manager.HTTPHandler(plain.Handler)
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	secure = &http.Server{
+		Addr:      ":" + cfg.TLS.Port,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+		Protocols: protocols,
+	}
+	return plain, secure, nil
+}
+
+// redirectToHTTPS permanently redirects every request to the same URL on the HTTPS port;
+// 308 rather than 301 so that clients repeat POSTs as POSTs
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]") // IPv6 without a port
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,107 @@
+// Package graph serves the admin entities over GraphQL (schema.graphql). Resolvers call the
+// same services as the REST handlers; the assignment tables are loaded once per request and
+// shared by every field that needs them, so nested queries do not fan out into a query per row.
+package graph
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"strconv"
+
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/utils"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// Services are what the resolvers read from
+type Services struct {
+	Users            services.UserService
+	Roles            services.RoleService
+	Menus            services.MenuService
+	RoleInheritances services.RoleInheritanceService
+	UserRoles        services.UserRoleService
+	RoleMenus        services.RoleMenuService
+	UserMenus        services.UserMenuService
+	// DB serves the audit log, which has no service
+	DB *sql.DB
+}
+
+// Limits bound the work one query may ask for
+type Limits struct {
+	// MaxDepth is the deepest selection allowed, e.g. 3 for users { nodes { roles } }
+	MaxDepth int
+	// MaxLength is the longest query accepted, in bytes
+	MaxLength int
+}
+
+// NewSchema parses the schema and binds it to resolvers over svc
+func NewSchema(svc Services, limits Limits) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaSDL, &Resolver{svc: svc},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(limits.MaxDepth),
+		graphql.MaxQueryLength(limits.MaxLength))
+}
+
+// Error is a resolver error as clients see it: the message of a utils.AppError, and its code
+// under extensions.code
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Extensions implements graphql-go's extension hook
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
+
+// resolverError logs err and returns what the client may see of it; like utils.HandleError,
+// only AppError messages are exposed
+func resolverError(ctx context.Context, err error, operation string) error {
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			appErr = utils.NewTimeoutError(operation, err)
+		} else {
+			appErr = utils.NewInternalError(operation, err)
+		}
+	}
+	logger := logging.FromContext(ctx)
+	if appErr.Internal != nil {
+		logger.Error("GraphQL field failed", "operation", operation, "type", string(appErr.Type),
+			"error", appErr.Internal, "details", appErr.Details)
+	} else {
+		logger.Warn("GraphQL field rejected", "operation", operation, "type", string(appErr.Type),
+			"message", appErr.Message)
+	}
+	return &Error{Message: appErr.Message, Code: appErr.ErrorCode()}
+}
+
+// parseID reads a numeric entity ID
+func parseID(id graphql.ID) (uint64, error) {
+	n, err := strconv.ParseUint(string(id), 10, 64)
+	if err != nil || n == 0 {
+		return 0, utils.NewValidationError("Invalid ID")
+	}
+	return n, nil
+}
+
+func toID[T ~uint | ~uint64](n T) graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(n), 10))
+}
+
+// first reads a page size argument; like the REST lists, sizes outside 1 to 1000 get def
+func first(n int32, def int) int {
+	if n < 1 || n > 1000 {
+		return def
+	}
+	return int(n)
+}
@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/utils"
+)
+
+// loaderKey carries the request's loader
+type loaderKey struct{}
+
+// WithLoader returns ctx with a fresh loader; call it once per GraphQL request
+func WithLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderKey{}, &loader{users: map[uint64]*lazy[*models.User]{}})
+}
+
+// loader memoizes, for one request, the tables nested fields walk. The roles, menus and
+// assignment tables are small and each REST list already returns them whole, so they are
+// loaded once in full and indexed; users are loaded one at a time, at most once each.
+type loader struct {
+	roles        lazy[map[uint]models.Role]
+	menus        lazy[menuIndex]
+	userRoles    lazy[map[uint64][]uint]
+	roleMenus    lazy[map[uint][]uint]
+	userMenus    lazy[map[uint64][]uint]
+	inheritances lazy[map[uint][]uint]
+
+	mu    sync.Mutex
+	users map[uint64]*lazy[*models.User]
+}
+
+// menuIndex holds the menu items by ID and by parent
+type menuIndex struct {
+	byID     map[uint]models.Menu
+	children map[uint][]models.Menu
+}
+
+// lazy is a value loaded on first use; graphql-go resolves sibling fields concurrently
+type lazy[T any] struct {
+	once sync.Once
+	v    T
+	err  error
+}
+
+func (l *lazy[T]) get(load func() (T, error)) (T, error) {
+	l.once.Do(func() { l.v, l.err = load() })
+	return l.v, l.err
+}
+
+// loaderFrom returns the request's loader, or a throwaway one when the caller did not set
+// one up (every field then loads its own tables)
+func loaderFrom(ctx context.Context) *loader {
+	if l, ok := ctx.Value(loaderKey{}).(*loader); ok {
+		return l
+	}
+	return WithLoader(ctx).Value(loaderKey{}).(*loader)
+}
+
+func (r *Resolver) rolesByID(ctx context.Context) (map[uint]models.Role, error) {
+	return loaderFrom(ctx).roles.get(func() (map[uint]models.Role, error) {
+		roles, err := r.svc.Roles.ListRoles(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.Role, len(roles))
+		for _, role := range roles {
+			byID[role.ID] = role
+		}
+		return byID, nil
+	})
+}
+
+func (r *Resolver) menuIndex(ctx context.Context) (menuIndex, error) {
+	return loaderFrom(ctx).menus.get(func() (menuIndex, error) {
+		menus, err := r.svc.Menus.ListMenus(ctx, nil)
+		if err != nil {
+			return menuIndex{}, err
+		}
+		idx := menuIndex{byID: make(map[uint]models.Menu, len(menus)), children: map[uint][]models.Menu{}}
+		for _, m := range menus {
+			idx.byID[m.ID] = m
+			if m.ParentID != nil {
+				idx.children[*m.ParentID] = append(idx.children[*m.ParentID], m)
+			}
+		}
+		return idx, nil
+	})
+}
+
+func (r *Resolver) roleIDsByUser(ctx context.Context) (map[uint64][]uint, error) {
+	return loaderFrom(ctx).userRoles.get(func() (map[uint64][]uint, error) {
+		rows, err := r.svc.UserRoles.ListUserRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byUser := map[uint64][]uint{}
+		for _, ur := range rows {
+			byUser[ur.UserID] = append(byUser[ur.UserID], ur.RoleID)
+		}
+		return byUser, nil
+	})
+}
+
+func (r *Resolver) menuIDsByRole(ctx context.Context) (map[uint][]uint, error) {
+	return loaderFrom(ctx).roleMenus.get(func() (map[uint][]uint, error) {
+		rows, err := r.svc.RoleMenus.ListRoleMenus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byRole := map[uint][]uint{}
+		for _, rm := range rows {
+			byRole[rm.RoleID] = append(byRole[rm.RoleID], rm.MenuID)
+		}
+		return byRole, nil
+	})
+}
+
+func (r *Resolver) menuIDsByUser(ctx context.Context) (map[uint64][]uint, error) {
+	return loaderFrom(ctx).userMenus.get(func() (map[uint64][]uint, error) {
+		rows, err := r.svc.UserMenus.ListUserMenus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byUser := map[uint64][]uint{}
+		for _, um := range rows {
+			byUser[um.UserID] = append(byUser[um.UserID], um.MenuID)
+		}
+		return byUser, nil
+	})
+}
+
+func (r *Resolver) parentIDsByRole(ctx context.Context) (map[uint][]uint, error) {
+	return loaderFrom(ctx).inheritances.get(func() (map[uint][]uint, error) {
+		rows, err := r.svc.RoleInheritances.ListRoleInheritances(ctx)
+		if err != nil {
+			return nil, err
+		}
+		byRole := map[uint][]uint{}
+		for _, ri := range rows {
+			byRole[ri.RoleID] = append(byRole[ri.RoleID], ri.ParentRoleID)
+		}
+		return byRole, nil
+	})
+}
+
+// userByID returns a user, or nil when there is none (e.g. deleted since it was referenced)
+func (r *Resolver) userByID(ctx context.Context, id uint64) (*models.User, error) {
+	l := loaderFrom(ctx)
+	l.mu.Lock()
+	entry, ok := l.users[id]
+	if !ok {
+		entry = &lazy[*models.User]{}
+		l.users[id] = entry
+	}
+	l.mu.Unlock()
+	return entry.get(func() (*models.User, error) {
+		user, err := r.svc.Users.GetUser(ctx, string(toID(id)))
+		if utils.IsNotFound(err) {
+			return nil, nil
+		}
+		return user, err
+	})
+}
+
+// roles returns the roles with the given IDs, skipping deleted ones
+func (r *Resolver) roles(ctx context.Context, ids []uint) ([]*roleResolver, error) {
+	byID, err := r.rolesByID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*roleResolver, 0, len(ids))
+	for _, id := range ids {
+		if role, ok := byID[id]; ok {
+			out = append(out, &roleResolver{r, role})
+		}
+	}
+	return out, nil
+}
+
+// menus returns the menu items with the given IDs, skipping deleted ones
+func (r *Resolver) menus(ctx context.Context, ids []uint) ([]*menuResolver, error) {
+	idx, err := r.menuIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*menuResolver, 0, len(ids))
+	for _, id := range ids {
+		if m, ok := idx.byID[id]; ok {
+			out = append(out, &menuResolver{r, m})
+		}
+	}
+	return out, nil
+}
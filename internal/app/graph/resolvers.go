@@ -0,0 +1,534 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Resolver resolves the Query type
+type Resolver struct {
+	svc Services
+}
+
+// Users resolves Query.users with the keyset pagination of GET /api/users?cursor=
+func (r *Resolver) Users(ctx context.Context, args struct {
+	First int32
+	After *string
+}) (*userConnectionResolver, error) {
+	after := ""
+	if args.After != nil {
+		after = *args.After
+	}
+	page, err := r.svc.Users.ListUsersAfter(ctx, after, first(args.First, 50))
+	if err != nil {
+		return nil, resolverError(ctx, err, "list users")
+	}
+	users, _ := page["data"].([]models.User)
+	pagination, _ := page["pagination"].(map[string]interface{})
+	conn := &userConnectionResolver{nodes: []*userResolver{}}
+	for _, u := range users {
+		conn.nodes = append(conn.nodes, &userResolver{r, u})
+	}
+	conn.hasNext, _ = pagination["has_next"].(bool)
+	if next, _ := pagination["next_cursor"].(string); next != "" {
+		conn.nextCursor = &next
+	}
+	return conn, nil
+}
+
+// User resolves Query.user; null when there is no such user
+func (r *Resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, resolverError(ctx, err, "get user")
+	}
+	u, err := r.userByID(ctx, id)
+	if err != nil {
+		return nil, resolverError(ctx, err, "get user")
+	}
+	if u == nil {
+		return nil, nil
+	}
+	return &userResolver{r, *u}, nil
+}
+
+// Roles resolves Query.roles, newest first as GET /api/roles lists them
+func (r *Resolver) Roles(ctx context.Context) ([]*roleResolver, error) {
+	roles, err := r.svc.Roles.ListRoles(ctx, nil)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list roles")
+	}
+	out := make([]*roleResolver, len(roles))
+	for i, role := range roles {
+		out[i] = &roleResolver{r, role}
+	}
+	return out, nil
+}
+
+// Role resolves Query.role; null when there is no such role
+func (r *Resolver) Role(ctx context.Context, args struct{ ID graphql.ID }) (*roleResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, resolverError(ctx, err, "get role")
+	}
+	roles, err := r.roles(ctx, []uint{uint(id)})
+	if err != nil {
+		return nil, resolverError(ctx, err, "get role")
+	}
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	return roles[0], nil
+}
+
+// Menus resolves Query.menus
+func (r *Resolver) Menus(ctx context.Context) ([]*menuResolver, error) {
+	menus, err := r.svc.Menus.ListMenus(ctx, nil)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list menus")
+	}
+	out := make([]*menuResolver, len(menus))
+	for i, m := range menus {
+		out[i] = &menuResolver{r, m}
+	}
+	return out, nil
+}
+
+// Menu resolves Query.menu; null when there is no such menu item
+func (r *Resolver) Menu(ctx context.Context, args struct{ ID graphql.ID }) (*menuResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, resolverError(ctx, err, "get menu")
+	}
+	menus, err := r.menus(ctx, []uint{uint(id)})
+	if err != nil {
+		return nil, resolverError(ctx, err, "get menu")
+	}
+	if len(menus) == 0 {
+		return nil, nil
+	}
+	return menus[0], nil
+}
+
+// RoleInheritances resolves Query.roleInheritances
+func (r *Resolver) RoleInheritances(ctx context.Context) ([]*roleInheritanceResolver, error) {
+	rows, err := r.svc.RoleInheritances.ListRoleInheritances(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role inheritances")
+	}
+	out := make([]*roleInheritanceResolver, len(rows))
+	for i, ri := range rows {
+		out[i] = &roleInheritanceResolver{r, ri}
+	}
+	return out, nil
+}
+
+// UserRoles resolves Query.userRoles
+func (r *Resolver) UserRoles(ctx context.Context) ([]*userRoleResolver, error) {
+	rows, err := r.svc.UserRoles.ListUserRoles(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user roles")
+	}
+	out := make([]*userRoleResolver, len(rows))
+	for i, ur := range rows {
+		out[i] = &userRoleResolver{r, ur}
+	}
+	return out, nil
+}
+
+// RoleMenus resolves Query.roleMenus
+func (r *Resolver) RoleMenus(ctx context.Context) ([]*roleMenuResolver, error) {
+	rows, err := r.svc.RoleMenus.ListRoleMenus(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role menus")
+	}
+	out := make([]*roleMenuResolver, len(rows))
+	for i, rm := range rows {
+		out[i] = &roleMenuResolver{r, rm}
+	}
+	return out, nil
+}
+
+// UserMenus resolves Query.userMenus
+func (r *Resolver) UserMenus(ctx context.Context) ([]*userMenuResolver, error) {
+	rows, err := r.svc.UserMenus.ListUserMenus(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user menus")
+	}
+	out := make([]*userMenuResolver, len(rows))
+	for i, um := range rows {
+		out[i] = &userMenuResolver{r, um}
+	}
+	return out, nil
+}
+
+// AuditLogs resolves Query.auditLogs
+func (r *Resolver) AuditLogs(ctx context.Context, args struct {
+	First     int32
+	UserID    *graphql.ID
+	TableName *string
+}) ([]*auditLogResolver, error) {
+	var userID uint64
+	if args.UserID != nil {
+		id, err := parseID(*args.UserID)
+		if err != nil {
+			return nil, resolverError(ctx, err, "list audit logs")
+		}
+		userID = id
+	}
+	table := ""
+	if args.TableName != nil {
+		table = *args.TableName
+	}
+	logs, err := r.auditLogs(ctx, userID, table, first(args.First, 50))
+	if err != nil {
+		return nil, resolverError(ctx, err, "list audit logs")
+	}
+	return logs, nil
+}
+
+// auditLogs reads the newest audit log entries from the replica, as GET /api/audit_logs
+// does; userID 0 and table "" match every entry. The audit log is the whole instance's, so
+// only the default tenant reads it.
+func (r *Resolver) auditLogs(ctx context.Context, userID uint64, table string, limit int) ([]*auditLogResolver, error) {
+	if !tenant.IsDefault(ctx) {
+		return nil, utils.NewForbiddenError("Audit logs are only available to the default tenant")
+	}
+	ctx = database.WithReplica(ctx)
+	var where []string
+	var args []interface{}
+	if userID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, userID)
+	}
+	if table != "" {
+		where = append(where, "table_name = ?")
+		args = append(args, table)
+	}
+	query := "SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := database.Reader(ctx, r.svc.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	defer rows.Close()
+
+	out := []*auditLogResolver{}
+	for rows.Next() {
+		a := &auditLogResolver{r: r}
+		if err := rows.Scan(&a.AuditLog.ID, &a.AuditLog.UserID, &a.AuditLog.EventType, &a.AuditLog.TableName, &a.AuditLog.RecordID, &a.oldValues, &a.newValues, &a.AuditLog.IPAddress, &a.AuditLog.UserAgent, &a.AuditLog.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+type userConnectionResolver struct {
+	nodes      []*userResolver
+	nextCursor *string
+	hasNext    bool
+}
+
+func (c *userConnectionResolver) Nodes() []*userResolver { return c.nodes }
+func (c *userConnectionResolver) NextCursor() *string    { return c.nextCursor }
+func (c *userConnectionResolver) HasNext() bool          { return c.hasNext }
+
+type userResolver struct {
+	r *Resolver
+	u models.User
+}
+
+func (u *userResolver) ID() graphql.ID           { return toID(u.u.ID) }
+func (u *userResolver) Username() string         { return u.u.Username }
+func (u *userResolver) Email() string            { return u.u.Email }
+func (u *userResolver) Status() int32            { return int32(u.u.Status) }
+func (u *userResolver) CreatedAt() *graphql.Time { return gqlTime(u.u.CreatedAt) }
+func (u *userResolver) UpdatedAt() *graphql.Time { return gqlTime(u.u.UpdatedAt) }
+
+func (u *userResolver) Roles(ctx context.Context) ([]*roleResolver, error) {
+	byUser, err := u.r.roleIDsByUser(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user roles")
+	}
+	roles, err := u.r.roles(ctx, byUser[u.u.ID])
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user roles")
+	}
+	return roles, nil
+}
+
+func (u *userResolver) Menus(ctx context.Context) ([]*menuResolver, error) {
+	byUser, err := u.r.menuIDsByUser(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user menus")
+	}
+	menus, err := u.r.menus(ctx, byUser[u.u.ID])
+	if err != nil {
+		return nil, resolverError(ctx, err, "list user menus")
+	}
+	return menus, nil
+}
+
+func (u *userResolver) AuditLogs(ctx context.Context, args struct{ First int32 }) ([]*auditLogResolver, error) {
+	logs, err := u.r.auditLogs(ctx, u.u.ID, "", first(args.First, 20))
+	if err != nil {
+		return nil, resolverError(ctx, err, "list audit logs")
+	}
+	return logs, nil
+}
+
+type roleResolver struct {
+	r    *Resolver
+	role models.Role
+}
+
+func (r *roleResolver) ID() graphql.ID           { return toID(r.role.ID) }
+func (r *roleResolver) Name() string             { return r.role.Name }
+func (r *roleResolver) Description() *string     { return r.role.Description }
+func (r *roleResolver) CreatedAt() *graphql.Time { return gqlTime(r.role.CreatedAt) }
+func (r *roleResolver) UpdatedAt() *graphql.Time { return gqlTime(r.role.UpdatedAt) }
+
+func (r *roleResolver) Menus(ctx context.Context) ([]*menuResolver, error) {
+	byRole, err := r.r.menuIDsByRole(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role menus")
+	}
+	menus, err := r.r.menus(ctx, byRole[r.role.ID])
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role menus")
+	}
+	return menus, nil
+}
+
+func (r *roleResolver) Parents(ctx context.Context) ([]*roleResolver, error) {
+	byRole, err := r.r.parentIDsByRole(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role inheritances")
+	}
+	roles, err := r.r.roles(ctx, byRole[r.role.ID])
+	if err != nil {
+		return nil, resolverError(ctx, err, "list role inheritances")
+	}
+	return roles, nil
+}
+
+type menuResolver struct {
+	r *Resolver
+	m models.Menu
+}
+
+func (m *menuResolver) ID() graphql.ID           { return toID(m.m.ID) }
+func (m *menuResolver) Label() string            { return m.m.Label }
+func (m *menuResolver) URL() *string             { return m.m.Url }
+func (m *menuResolver) Icon() *string            { return m.m.Icon }
+func (m *menuResolver) SortOrder() int32         { return int32(m.m.SortOrder) }
+func (m *menuResolver) CreatedAt() *graphql.Time { return gqlTime(m.m.CreatedAt) }
+func (m *menuResolver) UpdatedAt() *graphql.Time { return gqlTime(m.m.UpdatedAt) }
+
+func (m *menuResolver) Parent(ctx context.Context) (*menuResolver, error) {
+	if m.m.ParentID == nil {
+		return nil, nil
+	}
+	menus, err := m.r.menus(ctx, []uint{*m.m.ParentID})
+	if err != nil {
+		return nil, resolverError(ctx, err, "get menu")
+	}
+	if len(menus) == 0 {
+		return nil, nil
+	}
+	return menus[0], nil
+}
+
+func (m *menuResolver) Children(ctx context.Context) ([]*menuResolver, error) {
+	idx, err := m.r.menuIndex(ctx)
+	if err != nil {
+		return nil, resolverError(ctx, err, "list menus")
+	}
+	children := idx.children[m.m.ID]
+	out := make([]*menuResolver, len(children))
+	for i, child := range children {
+		out[i] = &menuResolver{m.r, child}
+	}
+	return out, nil
+}
+
+type roleInheritanceResolver struct {
+	r  *Resolver
+	ri models.RoleInheritance
+}
+
+func (ri *roleInheritanceResolver) ID() graphql.ID           { return toID(ri.ri.ID) }
+func (ri *roleInheritanceResolver) CreatedAt() *graphql.Time { return gqlTime(ri.ri.CreatedAt) }
+
+func (ri *roleInheritanceResolver) Role(ctx context.Context) (*roleResolver, error) {
+	return ri.r.roleRef(ctx, ri.ri.RoleID)
+}
+
+func (ri *roleInheritanceResolver) ParentRole(ctx context.Context) (*roleResolver, error) {
+	return ri.r.roleRef(ctx, ri.ri.ParentRoleID)
+}
+
+type userRoleResolver struct {
+	r  *Resolver
+	ur models.UserRole
+}
+
+func (ur *userRoleResolver) User(ctx context.Context) (*userResolver, error) {
+	return ur.r.userRef(ctx, ur.ur.UserID)
+}
+
+func (ur *userRoleResolver) Role(ctx context.Context) (*roleResolver, error) {
+	return ur.r.roleRef(ctx, ur.ur.RoleID)
+}
+
+type roleMenuResolver struct {
+	r  *Resolver
+	rm models.RoleMenu
+}
+
+func (rm *roleMenuResolver) Role(ctx context.Context) (*roleResolver, error) {
+	return rm.r.roleRef(ctx, rm.rm.RoleID)
+}
+
+func (rm *roleMenuResolver) Menu(ctx context.Context) (*menuResolver, error) {
+	return rm.r.menuRef(ctx, rm.rm.MenuID)
+}
+
+type userMenuResolver struct {
+	r  *Resolver
+	um models.UserMenu
+}
+
+func (um *userMenuResolver) User(ctx context.Context) (*userResolver, error) {
+	return um.r.userRef(ctx, um.um.UserID)
+}
+
+func (um *userMenuResolver) Menu(ctx context.Context) (*menuResolver, error) {
+	return um.r.menuRef(ctx, um.um.MenuID)
+}
+
+type auditLogResolver struct {
+	r *Resolver
+	models.AuditLog
+	oldValues, newValues []byte
+}
+
+func (a *auditLogResolver) ID() graphql.ID           { return toID(a.AuditLog.ID) }
+func (a *auditLogResolver) EventType() string        { return a.AuditLog.EventType }
+func (a *auditLogResolver) TableName() string        { return a.AuditLog.TableName }
+func (a *auditLogResolver) RecordID() graphql.ID     { return toID(a.AuditLog.RecordID) }
+func (a *auditLogResolver) OldValues() *JSON         { return newJSON(a.oldValues) }
+func (a *auditLogResolver) NewValues() *JSON         { return newJSON(a.newValues) }
+func (a *auditLogResolver) UserAgent() *string       { return a.AuditLog.UserAgent }
+func (a *auditLogResolver) CreatedAt() *graphql.Time { return gqlTime(a.AuditLog.CreatedAt) }
+
+// IPAddress formats the INET6_ATON bytes the audit log stores
+func (a *auditLogResolver) IPAddress() *string {
+	if len(a.AuditLog.IPAddress) != net.IPv4len && len(a.AuditLog.IPAddress) != net.IPv6len {
+		return nil
+	}
+	ip := net.IP(a.AuditLog.IPAddress).String()
+	return &ip
+}
+
+func (a *auditLogResolver) User(ctx context.Context) (*userResolver, error) {
+	return a.r.userRef(ctx, a.AuditLog.UserID)
+}
+
+// roleRef resolves a role referenced by ID, null when it has been deleted
+func (r *Resolver) roleRef(ctx context.Context, id uint) (*roleResolver, error) {
+	roles, err := r.roles(ctx, []uint{id})
+	if err != nil {
+		return nil, resolverError(ctx, err, "get role")
+	}
+	if len(roles) == 0 {
+		return nil, nil
+	}
+	return roles[0], nil
+}
+
+// menuRef resolves a menu item referenced by ID, null when it has been deleted
+func (r *Resolver) menuRef(ctx context.Context, id uint) (*menuResolver, error) {
+	menus, err := r.menus(ctx, []uint{id})
+	if err != nil {
+		return nil, resolverError(ctx, err, "get menu")
+	}
+	if len(menus) == 0 {
+		return nil, nil
+	}
+	return menus[0], nil
+}
+
+// userRef resolves a user referenced by ID, null when it has been deleted
+func (r *Resolver) userRef(ctx context.Context, id uint64) (*userResolver, error) {
+	u, err := r.userByID(ctx, id)
+	if err != nil {
+		return nil, resolverError(ctx, err, "get user")
+	}
+	if u == nil {
+		return nil, nil
+	}
+	return &userResolver{r, *u}, nil
+}
+
+func gqlTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+// JSON is the JSON scalar: a stored JSON document passed through as is
+type JSON struct {
+	raw json.RawMessage
+}
+
+// newJSON wraps a stored document, nil for SQL NULL; text that is not valid JSON is
+// returned as a string
+func newJSON(b []byte) *JSON {
+	if b == nil {
+		return nil
+	}
+	if !json.Valid(b) {
+		quoted, _ := json.Marshal(string(b))
+		return &JSON{raw: quoted}
+	}
+	return &JSON{raw: json.RawMessage(b)}
+}
+
+// ImplementsGraphQLType binds JSON to the schema's JSON scalar
+func (JSON) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+// UnmarshalGraphQL accepts any input value
+func (j *JSON) UnmarshalGraphQL(input any) error {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return utils.NewValidationError("Invalid JSON value")
+	}
+	j.raw = raw
+	return nil
+}
+
+// MarshalJSON writes the document
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j.raw == nil {
+		return []byte("null"), nil
+	}
+	return j.raw, nil
+}
@@ -0,0 +1,136 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/utils"
+	"adminbe/pkg/adminpb"
+)
+
+// maxScheduleDays caps GetSchedule at a year, the longest range /api/v2/prayer/schedule serves
+const maxScheduleDays = 366
+
+type prayerServer struct {
+	adminpb.UnimplementedPrayerServiceServer
+	svc Services
+}
+
+// The location lists share their cache entries with /api/v2/prayer
+
+func (s *prayerServer) ListProvinces(ctx context.Context, _ *adminpb.ListProvincesRequest) (*adminpb.ListLocationsResponse, error) {
+	provinces, _, err := cache.GetOrLoad(ctx, database.Cache, cache.CacheKeyProvinceLocations, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]services.Location, error) {
+		return s.svc.Prayer.ListProvinces(ctx)
+	})
+	if err != nil {
+		return nil, statusError(ctx, err, "retrieve provinces")
+	}
+	return &adminpb.ListLocationsResponse{Locations: s.locations(locationcode.Province, provinces)}, nil
+}
+
+func (s *prayerServer) ListCities(ctx context.Context, req *adminpb.ListCitiesRequest) (*adminpb.ListLocationsResponse, error) {
+	provinceID, err := s.svc.LocationCodes.Decode(locationcode.Province, req.GetProvince())
+	if err != nil {
+		return nil, statusError(ctx, utils.NewValidationError("Invalid province code"), "retrieve cities")
+	}
+	cacheKey := fmt.Sprintf(cache.CacheKeyCityLocations, provinceID)
+	cities, _, err := cache.GetOrLoad(ctx, database.Cache, cacheKey, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]services.Location, error) {
+		return s.svc.Prayer.ListCities(ctx, services.LocationHash(provinceID))
+	})
+	if err != nil {
+		return nil, statusError(ctx, err, "retrieve cities")
+	}
+	return &adminpb.ListLocationsResponse{Locations: s.locations(locationcode.City, cities)}, nil
+}
+
+func (s *prayerServer) GetSchedule(ctx context.Context, req *adminpb.GetScheduleRequest) (*adminpb.Schedule, error) {
+	start, err := time.Parse("2006-01-02", req.GetStartDate())
+	if err != nil {
+		return nil, statusError(ctx, utils.NewValidationError("start_date must be in YYYY-MM-DD form"), "get prayer schedule")
+	}
+	days := int(req.GetDays())
+	if days == 0 {
+		days = 1
+	}
+	if days < 0 || days > maxScheduleDays {
+		return nil, statusError(ctx, utils.NewValidationError(fmt.Sprintf("days must be between 1 and %d", maxScheduleDays)), "get prayer schedule")
+	}
+	provinceHash, cityHash, err := s.locationHashes(req.GetProvince(), req.GetCity())
+	if err != nil {
+		return nil, statusError(ctx, err, "get prayer schedule")
+	}
+
+	schedule, err := s.svc.Prayer.GetSchedule(ctx, provinceHash, cityHash, start, days)
+	if err != nil {
+		return nil, statusError(ctx, err, "get prayer schedule")
+	}
+	return scheduleMessage(req.GetProvince(), req.GetCity(), schedule), nil
+}
+
+func (s *prayerServer) GetFastingSchedule(ctx context.Context, req *adminpb.GetFastingScheduleRequest) (*adminpb.Schedule, error) {
+	if req.GetYear() < 1 || req.GetYear() > 9999 {
+		return nil, statusError(ctx, utils.NewValidationError("year must be between 1 and 9999"), "get imsakiyah schedule")
+	}
+	provinceHash, cityHash, err := s.locationHashes(req.GetProvince(), req.GetCity())
+	if err != nil {
+		return nil, statusError(ctx, err, "get imsakiyah schedule")
+	}
+
+	schedule, err := s.svc.Prayer.GetFastingSchedule(ctx, int(req.GetYear()), provinceHash, cityHash)
+	if err != nil {
+		return nil, statusError(ctx, err, "get imsakiyah schedule")
+	}
+	return scheduleMessage(req.GetProvince(), req.GetCity(), schedule), nil
+}
+
+// locationHashes decodes location codes into the MD5 codes the prayer service looks
+// locations up by, as the /api/v2 handlers do
+func (s *prayerServer) locationHashes(province, city string) (string, string, error) {
+	provinceID, err := s.svc.LocationCodes.Decode(locationcode.Province, province)
+	if err != nil {
+		return "", "", utils.NewValidationError("Invalid province code")
+	}
+	cityID, err := s.svc.LocationCodes.Decode(locationcode.City, city)
+	if err != nil {
+		return "", "", utils.NewValidationError("Invalid city code")
+	}
+	return services.LocationHash(provinceID), services.LocationHash(cityID), nil
+}
+
+// locations gives locations their opaque codes
+func (s *prayerServer) locations(kind locationcode.Kind, locations []services.Location) []*adminpb.Location {
+	out := make([]*adminpb.Location, len(locations))
+	for i, l := range locations {
+		out[i] = &adminpb.Location{Code: s.svc.LocationCodes.Encode(kind, l.ID), Name: l.Name}
+	}
+	return out
+}
+
+// scheduleMessage pairs a schedule with the codes it was requested by
+func scheduleMessage(province, city string, schedule *services.Schedule) *adminpb.Schedule {
+	msg := &adminpb.Schedule{
+		Province:  &adminpb.Location{Code: province, Name: schedule.Province},
+		City:      &adminpb.Location{Code: city, Name: schedule.City},
+		HijriYear: schedule.Hijriah,
+		Days:      make([]*adminpb.PrayerDay, len(schedule.Days)),
+	}
+	for i, d := range schedule.Days {
+		msg.Days[i] = &adminpb.PrayerDay{
+			Date:    d.Date,
+			Imsak:   d.Imsak,
+			Subuh:   d.Subuh,
+			Terbit:  d.Terbit,
+			Dhuha:   d.Dhuha,
+			Dzuhur:  d.Dzuhur,
+			Ashar:   d.Ashar,
+			Maghrib: d.Maghrib,
+			Isya:    d.Isya,
+		}
+	}
+	return msg
+}
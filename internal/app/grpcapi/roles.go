@@ -0,0 +1,51 @@
+package grpcapi
+
+import (
+	"context"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/utils"
+	"adminbe/pkg/adminpb"
+)
+
+type roleServer struct {
+	adminpb.UnimplementedRoleServiceServer
+	svc Services
+}
+
+func (s *roleServer) GetRole(ctx context.Context, req *adminpb.GetRoleRequest) (*adminpb.Role, error) {
+	if req.GetId() == 0 {
+		return nil, statusError(ctx, utils.NewValidationError("Invalid ID"), "get role")
+	}
+	role, err := s.svc.Roles.GetRole(ctx, strconv.FormatUint(req.GetId(), 10))
+	if err != nil {
+		return nil, statusError(ctx, err, "get role")
+	}
+	return roleMessage(role), nil
+}
+
+func (s *roleServer) ListRoles(ctx context.Context, _ *adminpb.ListRolesRequest) (*adminpb.ListRolesResponse, error) {
+	roles, err := s.svc.Roles.ListRoles(ctx, nil)
+	if err != nil {
+		return nil, statusError(ctx, err, "list roles")
+	}
+	resp := &adminpb.ListRolesResponse{Roles: make([]*adminpb.Role, len(roles))}
+	for i := range roles {
+		resp.Roles[i] = roleMessage(&roles[i])
+	}
+	return resp, nil
+}
+
+func roleMessage(r *models.Role) *adminpb.Role {
+	msg := &adminpb.Role{
+		Id:         uint64(r.ID),
+		Name:       r.Name,
+		CreateTime: timestamp(r.CreatedAt),
+		UpdateTime: timestamp(r.UpdatedAt),
+	}
+	if r.Description != nil {
+		msg.Description = *r.Description
+	}
+	return msg
+}
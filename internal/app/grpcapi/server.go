@@ -0,0 +1,194 @@
+// Package grpcapi serves the user, role and prayer services over gRPC for internal consumers
+// (pkg/adminpb). It calls the same services as the HTTP handlers, authenticates with the same
+// access tokens, and maps utils.AppError types to gRPC status codes.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/tracing"
+	"adminbe/internal/pkg/utils"
+	"adminbe/pkg/adminpb"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Services are what the gRPC methods call; the server shares them with the HTTP routes
+type Services struct {
+	Users         services.UserService
+	Roles         services.RoleService
+	UserRoles     services.UserRoleService
+	Prayer        services.PrayerService
+	Tenants       services.TenantService
+	LocationCodes *locationcode.Codec
+}
+
+var (
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC request latency by method.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"method"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(grpcRequests, grpcDuration)
+}
+
+// NewServer returns a gRPC server with the UserService, RoleService and PrayerService
+// registered, plus server reflection so tools like grpcurl can list them. Every method
+// needs an access token in the authorization metadata, as "Bearer <token>".
+func NewServer(svc Services) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(observe, recoverPanic, authenticate(svc.Tenants)))
+	adminpb.RegisterUserServiceServer(s, &userServer{svc: svc})
+	adminpb.RegisterRoleServiceServer(s, &roleServer{svc: svc})
+	adminpb.RegisterPrayerServiceServer(s, &prayerServer{svc: svc})
+	reflection.Register(s)
+	return s
+}
+
+// observe gives each call a request ID, request logger and language, as TracingMiddleware
+// and LanguageMiddleware do for HTTP, and records metrics and an access log line once it has
+// been answered
+func observe(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	requestID, acceptLanguage := "", ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(strings.ToLower(tracing.HeaderRequestID)); len(ids) > 0 {
+			requestID = ids[0]
+		}
+		acceptLanguage = strings.Join(md.Get("accept-language"), ",")
+	}
+	ctx = i18n.WithLanguage(ctx, i18n.Negotiate("", acceptLanguage))
+	trace := tracing.NewTrace(requestID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(tracing.HeaderRequestID), trace.ID))
+	logger := slog.Default().With(logging.KeyRequestID, trace.ID)
+	ctx = logging.WithLogger(tracing.WithTrace(ctx, trace), logger)
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	grpcRequests.WithLabelValues(info.FullMethod, code.String()).Inc()
+	grpcDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	logger.Info("gRPC request", "method", info.FullMethod, "code", code.String(),
+		"duration_ms", float64(time.Since(start).Microseconds())/1000)
+	return resp, err
+}
+
+// recoverPanic answers a panicking call with Internal, as CustomRecoveryMiddleware does
+func recoverPanic(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logging.FromContext(ctx).Error("Panic recovered", "method", info.FullMethod,
+				"panic", recovered, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, i18n.T(ctx, "Internal server error"))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authenticate checks the access token, like AuthMiddleware, and serves the call for the
+// tenant that issued it, with the tenant's settings. Reflection is a streaming service, so
+// it is left open.
+func authenticate(tenants services.TenantService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var tokenString string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				tokenString = strings.TrimPrefix(values[0], "Bearer ")
+			}
+		}
+		if tokenString == "" {
+			return nil, status.Error(codes.Unauthenticated, i18n.T(ctx, "Authorization header required"))
+		}
+		claims, err := middleware.ParseToken(tokenString)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if claims.TenantID != tenant.DefaultID && !tenant.Enabled() {
+			return nil, status.Error(codes.Unauthenticated, middleware.ErrInvalidToken.Error())
+		}
+		logger := logging.FromContext(ctx).With("user_id", claims.UserID)
+		ctx = tenant.WithID(ctx, claims.TenantID)
+		if claims.TenantID != tenant.DefaultID {
+			logger = logger.With("tenant_id", claims.TenantID)
+			settings, err := tenants.Settings(ctx, claims.TenantID)
+			if err != nil {
+				return nil, statusError(logging.WithLogger(ctx, logger), err, "load tenant settings")
+			}
+			ctx = tenant.WithSettings(ctx, settings)
+		}
+		return handler(logging.WithLogger(ctx, logger), req)
+	}
+}
+
+// statusError logs err and returns the status the client may see of it; like
+// utils.HandleError, only AppError messages are exposed
+func statusError(ctx context.Context, err error, operation string) error {
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			appErr = utils.NewTimeoutError(operation, err)
+		} else if errors.Is(err, context.Canceled) {
+			return status.Error(codes.Canceled, "Request canceled")
+		} else {
+			appErr = utils.NewInternalError(operation, err)
+		}
+	}
+	logger := logging.FromContext(ctx)
+	if appErr.Internal != nil {
+		logger.Error("gRPC request failed", "operation", operation, "type", string(appErr.Type),
+			"error", appErr.Internal, "details", appErr.Details)
+	} else {
+		logger.Warn("gRPC request rejected", "operation", operation, "type", string(appErr.Type),
+			"message", appErr.Message)
+	}
+	return status.Error(statusCode(appErr.Type), i18n.T(ctx, appErr.Message))
+}
+
+// statusCode is the gRPC counterpart of an AppError type's HTTP status
+func statusCode(t utils.ErrorType) codes.Code {
+	switch t {
+	case utils.ErrorTypeValidation:
+		return codes.InvalidArgument
+	case utils.ErrorTypeNotFound:
+		return codes.NotFound
+	case utils.ErrorTypeForbidden:
+		return codes.PermissionDenied
+	case utils.ErrorTypeConflict:
+		return codes.AlreadyExists
+	case utils.ErrorTypeTransient, utils.ErrorTypeExternal:
+		return codes.Unavailable
+	case utils.ErrorTypeTimeout:
+		return codes.DeadlineExceeded
+	case utils.ErrorTypeOverloaded, utils.ErrorTypeRateLimited:
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
@@ -0,0 +1,100 @@
+package grpcapi
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/utils"
+	"adminbe/pkg/adminpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultPageSize is the ListUsers page size when the request sets none, as for /api/users
+const defaultPageSize = 50
+
+type userServer struct {
+	adminpb.UnimplementedUserServiceServer
+	svc Services
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *adminpb.GetUserRequest) (*adminpb.User, error) {
+	if req.GetId() == 0 {
+		return nil, statusError(ctx, utils.NewValidationError("Invalid ID"), "get user")
+	}
+	user, err := s.svc.Users.GetUser(ctx, strconv.FormatUint(req.GetId(), 10))
+	if err != nil {
+		return nil, statusError(ctx, err, "get user")
+	}
+	return userMessage(user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *adminpb.ListUsersRequest) (*adminpb.ListUsersResponse, error) {
+	size := int(req.GetPageSize())
+	if size < 1 || size > 1000 {
+		size = defaultPageSize
+	}
+	page, err := s.svc.Users.ListUsersAfter(ctx, req.GetPageToken(), size)
+	if err != nil {
+		return nil, statusError(ctx, err, "list users")
+	}
+	users, _ := page["data"].([]models.User)
+	pagination, _ := page["pagination"].(map[string]interface{})
+	resp := &adminpb.ListUsersResponse{Users: make([]*adminpb.User, len(users))}
+	for i := range users {
+		resp.Users[i] = userMessage(&users[i])
+	}
+	resp.NextPageToken, _ = pagination["next_cursor"].(string)
+	return resp, nil
+}
+
+func (s *userServer) ListUserRoles(ctx context.Context, req *adminpb.ListUserRolesRequest) (*adminpb.ListUserRolesResponse, error) {
+	if req.GetUserId() == 0 {
+		return nil, statusError(ctx, utils.NewValidationError("Invalid user ID"), "list user roles")
+	}
+	assignments, err := s.svc.UserRoles.ListUserRoles(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err, "list user roles")
+	}
+	assigned := map[uint]bool{}
+	for _, ur := range assignments {
+		if ur.UserID == req.GetUserId() {
+			assigned[ur.RoleID] = true
+		}
+	}
+	resp := &adminpb.ListUserRolesResponse{Roles: []*adminpb.Role{}}
+	if len(assigned) == 0 {
+		return resp, nil
+	}
+	roles, err := s.svc.Roles.ListRoles(ctx, nil)
+	if err != nil {
+		return nil, statusError(ctx, err, "list user roles")
+	}
+	for i := range roles {
+		if assigned[roles[i].ID] {
+			resp.Roles = append(resp.Roles, roleMessage(&roles[i]))
+		}
+	}
+	return resp, nil
+}
+
+func userMessage(u *models.User) *adminpb.User {
+	return &adminpb.User{
+		Id:         u.ID,
+		Username:   u.Username,
+		Email:      u.Email,
+		Status:     uint32(u.Status),
+		CreateTime: timestamp(u.CreatedAt),
+		UpdateTime: timestamp(u.UpdatedAt),
+	}
+}
+
+// timestamp converts a nullable column; NULL stays unset
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
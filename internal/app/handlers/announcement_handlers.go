@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listAnnouncementsHandler GET /api/announcements
+// Newest first. Takes ?status=scheduled|active|expired, ?before_id= to page back and ?limit=
+// (default 100, at most 1000).
+func listAnnouncementsHandler(announcements services.AnnouncementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.AnnouncementFilter{
+			Status: c.Query("status"),
+			Limit:  parseIntMinMax(c.Query("limit"), 100, 1, 1000),
+		}
+		if v := c.Query("before_id"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			filter.BeforeID = n
+		}
+
+		list, err := announcements.ListAnnouncements(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list announcements") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getAnnouncementHandler GET /api/announcements/:id
+func getAnnouncementHandler(announcements services.AnnouncementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, err := announcements.GetAnnouncement(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get announcement") {
+			return
+		}
+		response.OK(c, a)
+	}
+}
+
+// createAnnouncementHandler POST /api/announcements
+func createAnnouncementHandler(announcements services.AnnouncementService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.AnnouncementRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		a, err := announcements.CreateAnnouncement(c.Request.Context(), req, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "create announcement") {
+			return
+		}
+
+		logAuditEntry(c, "CREATE", "announcements", a.ID, nil, a, db)
+
+		response.Write(c, http.StatusCreated, response.Body{Data: a, Message: "Announcement created"})
+	}
+}
+
+// updateAnnouncementHandler PUT /api/announcements/:id
+// Replaces the announcement: members left out take their defaults.
+func updateAnnouncementHandler(announcements services.AnnouncementService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.AnnouncementRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		old, a, err := announcements.UpdateAnnouncement(c.Request.Context(), c.Param("id"), req, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "update announcement") {
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "announcements", a.ID, old, a, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: a, Message: "Announcement updated"})
+	}
+}
+
+// deleteAnnouncementHandler DELETE /api/announcements/:id
+func deleteAnnouncementHandler(announcements services.AnnouncementService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, err := announcements.DeleteAnnouncement(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "delete announcement") {
+			return
+		}
+
+		logAuditEntry(c, "DELETE", "announcements", a.ID, a, nil, db)
+
+		response.Write(c, http.StatusOK, response.Body{Message: "Announcement deleted"})
+	}
+}
+
+// myAnnouncementsHandler GET /api/me/announcements
+// The banners to show the signed-in user now: those for everyone and those targeting one of
+// their roles, most severe first, then newest
+func myAnnouncementsHandler(announcements services.AnnouncementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := announcements.ActiveForRoles(c.Request.Context(), middleware.GetRolesFromContext(c))
+		if utils.HandleError(c, err, "list my announcements") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
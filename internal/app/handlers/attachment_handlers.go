@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is what the body limit of the upload routes allows on top of
+// STORAGE_MAX_UPLOAD_BYTES for the multipart boundaries, headers and other parts
+const multipartOverhead = 64 << 10
+
+// isAdmin reports whether the caller holds the administrator role of the default tenant.
+// Files and export jobs are not kept per tenant, so other tenants' administrators only reach
+// their own.
+func isAdmin(c *gin.Context) bool {
+	if !tenant.IsDefault(c.Request.Context()) {
+		return false
+	}
+	for _, role := range middleware.GetRolesFromContext(c) {
+		if role == middleware.RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// formFile reads the "file" part of a multipart upload, answering 400 without one. The
+// caller closes the returned file.
+func formFile(c *gin.Context) (services.FileUpload, io.Closer, bool) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "A multipart/form-data body with a file part is required")
+		return services.FileUpload{}, nil, false
+	}
+	content, err := header.Open()
+	if err != nil {
+		utils.HandleError(c, err, "open upload")
+		return services.FileUpload{}, nil, false
+	}
+	return services.FileUpload{Filename: header.Filename, Size: header.Size, Content: content}, content, true
+}
+
+// setAvatarHandler PUT /api/users/:id/avatar
+// Replaces the avatar with the "file" part of a multipart body: PNG, JPEG, GIF or WebP of at
+// most STORAGE_MAX_AVATAR_BYTES. Users set their own; administrators anyone's.
+func setAvatarHandler(attachments services.AttachmentService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		file, closer, ok := formFile(c)
+		if !ok {
+			return
+		}
+		defer closer.Close()
+
+		a, err := attachments.SetAvatar(c.Request.Context(), c.Param("id"), file, userID, isAdmin(c))
+		if utils.HandleError(c, err, "set avatar") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "attachments", a.ID, nil, a, db)
+		response.OK(c, a)
+	}
+}
+
+// getAvatarHandler GET /api/users/:id/avatar
+// Redirects to a signed download URL of the avatar, for use as an image source
+func getAvatarHandler(attachments services.AttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, err := attachments.GetAvatar(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get avatar") {
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+		c.Redirect(http.StatusFound, a.URL)
+	}
+}
+
+// deleteAvatarHandler DELETE /api/users/:id/avatar
+func deleteAvatarHandler(attachments services.AttachmentService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		if utils.HandleError(c, attachments.DeleteAvatar(c.Request.Context(), c.Param("id"), userID, isAdmin(c)), "delete avatar") {
+			return
+		}
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		logAuditEntry(c, "DELETE", "attachments", id, gin.H{"avatar_of": id}, nil, db)
+		response.Write(c, http.StatusOK, response.Body{Message: "Avatar removed"})
+	}
+}
+
+// uploadFileHandler POST /api/files
+// Stores the "file" part of a multipart body for the caller. The "purpose" part is
+// report_parameter, the default: a file a report takes as "attachment:<id>".
+func uploadFileHandler(attachments services.AttachmentService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		file, closer, ok := formFile(c)
+		if !ok {
+			return
+		}
+		defer closer.Close()
+
+		purpose := c.DefaultPostForm("purpose", models.AttachmentReportParameter)
+		a, err := attachments.Upload(c.Request.Context(), purpose, file, userID)
+		if utils.HandleError(c, err, "upload file") {
+			return
+		}
+		logAuditEntry(c, "CREATE", "attachments", a.ID, nil, a, db)
+		response.Write(c, http.StatusCreated, response.Body{Data: a, Message: "File uploaded"})
+	}
+}
+
+// listFilesHandler GET /api/files
+// The caller's files, newest first, optionally of one ?purpose=; administrators see
+// everyone's, or one user's with ?owner_id=. ?before_id= pages back.
+func listFilesHandler(attachments services.AttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		filter := models.AttachmentFilter{
+			Purpose: c.Query("purpose"),
+			Limit:   parseIntMinMax(c.Query("limit"), 100, 1, 1000),
+		}
+		for name, dest := range map[string]*uint64{"owner_id": &filter.OwnerID, "before_id": &filter.BeforeID} {
+			if v := c.Query(name); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					utils.RespondError(c, http.StatusBadRequest, "Invalid "+name)
+					return
+				}
+				*dest = n
+			}
+		}
+
+		list, err := attachments.List(c.Request.Context(), filter, userID, isAdmin(c))
+		if utils.HandleError(c, err, "list files") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getFileHandler GET /api/files/:id
+// The file's metadata with a fresh signed download URL
+func getFileHandler(attachments services.AttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		a, err := attachments.Get(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "get file") {
+			return
+		}
+		response.OK(c, a)
+	}
+}
+
+// deleteFileHandler DELETE /api/files/:id
+func deleteFileHandler(attachments services.AttachmentService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		a, err := attachments.Delete(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "delete file") {
+			return
+		}
+		logAuditEntry(c, "DELETE", "attachments", a.ID, a, nil, db)
+		response.Write(c, http.StatusOK, response.Body{Message: "File deleted"})
+	}
+}
+
+// downloadFileHandler GET /api/files/:id/content?expires=&signature=
+// Public: the signature is the authorization, so JasperServer and <img> tags can fetch
+// files. Served as a download, except images, and never sniffed into something a browser
+// would run.
+func downloadFileHandler(attachments services.AttachmentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, body, err := attachments.Open(c.Request.Context(), c.Param("id"), c.Query("expires"), c.Query("signature"))
+		if utils.HandleError(c, err, "download file") {
+			return
+		}
+		defer body.Close()
+
+		disposition := "attachment"
+		if strings.HasPrefix(a.ContentType, "image/") {
+			disposition = "inline"
+		}
+		c.DataFromReader(http.StatusOK, a.Size, a.ContentType, body, map[string]string{
+			"Content-Disposition":     mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}),
+			"Content-Security-Policy": "sandbox",
+			"X-Content-Type-Options":  "nosniff",
+			"Cache-Control":           "private, max-age=300",
+		})
+	}
+}
@@ -1,20 +1,58 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"log"
+	"net"
 	"net/http"
 	"strconv"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// auditLogListSpec whitelists the sort and fields parameters of the audit log list. The
+// change payloads can be selected but not sorted by.
+var auditLogListSpec = utils.ListSpec{
+	Sort: map[string]string{
+		"id":         "id",
+		"user_id":    "user_id",
+		"event_type": "event_type",
+		"table_name": "table_name",
+		"record_id":  "record_id",
+		"created_at": "created_at",
+	},
+	Fields:   []string{"id", "user_id", "event_type", "table_name", "record_id", "old_values", "new_values", "ip_address", "user_agent", "created_at"},
+	Tiebreak: "id DESC",
+}
+
 // listAuditLogsHandler GET /api/audit_logs
+// Both pagination modes take ?fields=id,event_type; offset mode also takes ?sort=table_name:asc.
+// Accept: text/csv streams the whole trail in that order instead, the fields being the columns.
 func listAuditLogsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		q, ok := parseListQuery(c, auditLogListSpec, "list audit logs")
+		if !ok {
+			return
+		}
+
+		if wantsCSV(c) {
+			orderBy, err := auditLogListSpec.OrderBy(q.Sort, "created_at DESC, id DESC")
+			if utils.HandleError(c, err, "list audit logs") {
+				return
+			}
+			raiseAuditExport(c, "csv")
+			ctx := c.Request.Context()
+			stream := newCSVStream(c, "audit_logs", csvColumns(auditLogListSpec, q.Fields))
+			stream.Close("list audit logs", streamAuditLogs(ctx, database.Reader(database.WithReplica(ctx), db), orderBy, stream.Write, ""))
+			return
+		}
+
 		// Parse pagination parameters
 		pageStr := c.DefaultQuery("page", "1")
 		limitStr := c.DefaultQuery("limit", "50")
@@ -31,21 +69,43 @@ func listAuditLogsHandler(db *sql.DB) gin.HandlerFunc {
 
 		offset := (page - 1) * limit
 
-		// Get total count for pagination info
-		var totalCount int
-		err = db.QueryRow("SELECT COUNT(*) FROM audit_logs").Scan(&totalCount)
+		// The audit trail is read-heavy and tolerates lag, so serve it from the replica when available
+		reader := database.Reader(database.WithReplica(c.Request.Context()), db)
+
+		if cursor, ok := c.GetQuery("cursor"); ok {
+			if rejectSortWithCursor(c, q, "list audit logs") {
+				return
+			}
+			listAuditLogsAfter(c, reader, cursor, limit, q.Fields)
+			return
+		}
+
+		// Get total count for pagination info; LIST_COUNT_MODE may make it an estimate
+		totalCount, estimated, err := database.Total(c.Request.Context(),
+			func(ctx context.Context) (int64, error) {
+				return database.EstimateRows(ctx, reader, "audit_logs")
+			},
+			func(ctx context.Context) (int, error) {
+				var count int
+				err := reader.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs").Scan(&count)
+				return count, err
+			})
 		if err != nil {
-			log.Printf("Error counting audit logs: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count audit logs"})
+			logger(c).Error("Error counting audit logs", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to count audit logs")
 			return
 		}
 
-		// Query with pagination
-		rows, err := db.Query("SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		// Query with pagination; orderBy only holds expressions from auditLogListSpec
+		orderBy, err := auditLogListSpec.OrderBy(q.Sort, "created_at DESC")
+		if utils.HandleError(c, err, "list audit logs") {
+			return
+		}
+		rows, err := reader.QueryContext(c.Request.Context(), "SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs ORDER BY "+orderBy+" LIMIT ? OFFSET ?",
 			limit, offset)
 		if err != nil {
-			log.Printf("Error querying audit logs: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit logs"})
+			logger(c).Error("Error querying audit logs", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve audit logs")
 			return
 		}
 		defer rows.Close()
@@ -54,8 +114,8 @@ func listAuditLogsHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var a models.AuditLog
 			if err := rows.Scan(&a.ID, &a.UserID, &a.EventType, &a.TableName, &a.RecordID, &a.OldValues, &a.NewValues, &a.IPAddress, &a.UserAgent, &a.CreatedAt); err != nil {
-				log.Printf("Error scanning audit log row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit logs"})
+				logger(c).Error("Error scanning audit log row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve audit logs")
 				return
 			}
 			logs = append(logs, a)
@@ -66,19 +126,118 @@ func listAuditLogsHandler(db *sql.DB) gin.HandlerFunc {
 		hasNext := page < totalPages
 		hasPrev := page > 1
 
-		response := gin.H{
-			"data": logs,
+		data, err := selectFields(logs, q.Fields)
+		if utils.HandleError(c, err, "list audit logs") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: data, Meta: response.Meta{
 			"pagination": gin.H{
-				"page":        page,
-				"limit":       limit,
-				"total":       totalCount,
-				"total_pages": totalPages,
-				"has_next":    hasNext,
-				"has_prev":    hasPrev,
+				"page":            page,
+				"limit":           limit,
+				"total":           totalCount,
+				"total_estimated": estimated,
+				"total_pages":     totalPages,
+				"has_next":        hasNext,
+				"has_prev":        hasPrev,
 			},
+		}})
+	}
+}
+
+// listAuditLogsAfter serves GET /api/audit_logs?cursor=... with keyset pagination,
+// which stays fast at any depth unlike OFFSET
+func listAuditLogsAfter(c *gin.Context, reader *sql.DB, cursor string, limit int, fields []string) {
+	after, err := utils.DecodeCursor(cursor)
+	if utils.HandleError(c, err, "list audit logs") {
+		return
+	}
+
+	query := "SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs"
+	var args []interface{}
+	if after != nil {
+		query += " WHERE created_at < ? OR (created_at = ? AND id < ?)"
+		args = append(args, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit+1) // one extra row tells whether another page exists
+
+	rows, err := reader.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		logger(c).Error("Error querying audit logs", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve audit logs")
+		return
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		var a models.AuditLog
+		if err := rows.Scan(&a.ID, &a.UserID, &a.EventType, &a.TableName, &a.RecordID, &a.OldValues, &a.NewValues, &a.IPAddress, &a.UserAgent, &a.CreatedAt); err != nil {
+			logger(c).Error("Error scanning audit log row", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve audit logs")
+			return
+		}
+		logs = append(logs, a)
+	}
+
+	hasNext := len(logs) > limit
+	nextCursor := ""
+	if hasNext {
+		logs = logs[:limit]
+		last := logs[len(logs)-1]
+		nextCursor = utils.NextCursor(last.CreatedAt, last.ID)
+	}
+
+	data, err := selectFields(logs, fields)
+	if utils.HandleError(c, err, "list audit logs") {
+		return
+	}
+	response.Write(c, http.StatusOK, response.Body{Data: data, Meta: response.Meta{
+		"pagination": gin.H{
+			"limit":       limit,
+			"next_cursor": nextCursor,
+			"has_next":    hasNext,
+		},
+	}})
+}
+
+// exportAuditLogsHandler GET /api/audit_logs/export
+// Streams the whole audit trail, newest first, as NDJSON (default) or a JSON array (?format=json).
+// Rows are encoded straight from rows.Next(), so memory use does not grow with the table.
+func exportAuditLogsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		format := streamFormatFor(c)
+		raiseAuditExport(c, format)
+		stream := newJSONStream(c, format)
+		stream.Close("export audit logs", streamAuditLogs(ctx, database.Reader(database.WithReplica(ctx), db), "created_at DESC, id DESC", stream.Write, ""))
+	}
+}
+
+// streamAuditLogs passes every audit log row matching where, if set, to write in orderBy,
+// which must come from auditLogListSpec
+func streamAuditLogs(ctx context.Context, reader *sql.DB, orderBy string, write func(v interface{}) error, where string, args ...interface{}) error {
+	query := "SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	rows, err := reader.QueryContext(ctx, query+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return database.TranslateError(err)
+	}
+	defer rows.Close()
+
+	var a models.AuditLog
+	for rows.Next() {
+		a = models.AuditLog{}
+		if err := rows.Scan(&a.ID, &a.UserID, &a.EventType, &a.TableName, &a.RecordID, &a.OldValues, &a.NewValues, &a.IPAddress, &a.UserAgent, &a.CreatedAt); err != nil {
+			return err
+		}
+		if err := write(&a); err != nil {
+			return err
 		}
-		c.JSON(http.StatusOK, response)
 	}
+	return rows.Err()
 }
 
 // getAuditLogHandler GET /api/audit_logs/:id
@@ -87,22 +246,22 @@ func getAuditLogHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		aID, err := strconv.ParseUint(id, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		var a models.AuditLog
-		row := db.QueryRow("SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs WHERE id = ?", aID)
+		row := db.QueryRowContext(c.Request.Context(), "SELECT id, user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at FROM audit_logs WHERE id = ?", aID)
 		err = row.Scan(&a.ID, &a.UserID, &a.EventType, &a.TableName, &a.RecordID, &a.OldValues, &a.NewValues, &a.IPAddress, &a.UserAgent, &a.CreatedAt)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Audit log not found"})
+			utils.RespondError(c, http.StatusNotFound, "Audit log not found")
 			return
 		} else if err != nil {
-			log.Printf("Error querying audit log: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error querying audit log", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": a})
+		response.OK(c, a)
 	}
 }
 
@@ -120,7 +279,7 @@ func createAuditLogHandler(db *sql.DB) gin.HandlerFunc {
 			UserAgent *string     `json:"user_agent"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
@@ -132,29 +291,41 @@ func createAuditLogHandler(db *sql.DB) gin.HandlerFunc {
 			newJSON, _ = json.Marshal(req.NewValues)
 		}
 
-		result, err := db.Exec("INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent) VALUES (?, ?, ?, ?, ?, ?, INET6_ATON(?), ?)",
-			req.UserID, req.EventType, req.TableName, req.RecordID, oldJSON, newJSON, req.IPAddress, req.UserAgent)
+		logID, err := database.InsertID(c.Request.Context(), db, "INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			req.UserID, req.EventType, req.TableName, req.RecordID, oldJSON, newJSON, inet6Aton(req.IPAddress), req.UserAgent)
 		if err != nil {
-			log.Printf("Error inserting audit log: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create audit log"})
+			logger(c).Error("Error inserting audit log", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to create audit log")
 			return
 		}
 
-		logID, _ := result.LastInsertId()
-		c.JSON(http.StatusCreated, gin.H{"message": "Audit log created", "id": logID})
+		respondCreatedID(c, "Audit log created", logID)
 	}
 }
 
 // updateAuditLogHandler PUT /api/audit_logs/:id (not recommended, but for CRUD)
 func updateAuditLogHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Update not allowed for audit logs"})
+		utils.RespondError(c, http.StatusForbidden, "Update not allowed for audit logs")
 	}
 }
 
 // deleteAuditLogHandler DELETE /api/audit_logs/:id (not recommended, but for CRUD)
 func deleteAuditLogHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Delete not allowed for audit logs"})
+		utils.RespondError(c, http.StatusForbidden, "Delete not allowed for audit logs")
+	}
+}
+
+// inet6Aton converts an IP address to the 4- or 16-byte form stored in audit_logs.ip_address,
+// matching MySQL's INET6_ATON so both engines store the same bytes. Invalid input yields NULL.
+func inet6Aton(ip string) []byte {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4
 	}
+	return parsed.To16()
 }
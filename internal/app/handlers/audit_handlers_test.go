@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/database/dbtest"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCreateAuditLogIntegration posts audit logs on the engine of DB_DRIVER: the id comes
+// back from database.InsertID, and the address is stored in the binary form of INET6_ATON,
+// which MySQL itself must agree with
+func TestCreateAuditLogIntegration(t *testing.T) {
+	db := dbtest.Open(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/api/audit_logs", createAuditLogHandler(db))
+
+	var lastID uint64
+	for _, ip := range []string{"192.0.2.1", "2001:db8::1"} {
+		t.Run(ip, func(t *testing.T) {
+			body := fmt.Sprintf(`{"user_id":1,"event_type":"CREATE","table_name":"integration","record_id":1,"ip_address":%q}`, ip)
+			req := httptest.NewRequest(http.MethodPost, "/api/audit_logs", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(response.HeaderAcceptVersion, "1")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+			}
+			var created struct {
+				ID uint64 `json:"id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			t.Cleanup(func() { dbtest.Exec(t, db, "DELETE FROM audit_logs WHERE id = ?", created.ID) })
+			if created.ID == 0 || created.ID <= lastID {
+				t.Fatalf("id = %d after %d, want a new generated id", created.ID, lastID)
+			}
+			lastID = created.ID
+
+			var stored []byte
+			if err := db.QueryRow("SELECT ip_address FROM audit_logs WHERE id = ?", created.ID).Scan(&stored); err != nil {
+				t.Fatalf("read the audit log: %v", err)
+			}
+			if want := inet6Aton(ip); !bytes.Equal(stored, want) {
+				t.Errorf("ip_address = %x, want %x", stored, want)
+			}
+			if database.Current.Name() != database.DriverMySQL {
+				return
+			}
+			var same bool
+			if err := db.QueryRow("SELECT ip_address = INET6_ATON(?) FROM audit_logs WHERE id = ?", ip, created.ID).Scan(&same); err != nil {
+				t.Fatalf("INET6_ATON: %v", err)
+			}
+			if !same {
+				t.Errorf("ip_address = %x, which MySQL's INET6_ATON(%q) does not match", stored, ip)
+			}
+		})
+	}
+}
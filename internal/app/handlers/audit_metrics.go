@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results recorded on auditWritten
+const (
+	auditResultOK    = "ok"
+	auditResultError = "error"
+)
+
+var (
+	auditDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "audit",
+		Name:      "dropped_total",
+		Help:      "Audit entries dropped because the queue was full.",
+	})
+
+	auditWritten = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "audit",
+		Name:      "written_total",
+		Help:      "Audit entries written by the audit workers, by result (ok, error).",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		auditDropped,
+		auditWritten,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "audit",
+			Name:      "queue_depth",
+			Help:      "Audit entries waiting for a worker.",
+		}, func() float64 { return float64(len(auditLogChan)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "audit",
+			Name:      "queue_capacity",
+			Help:      "Audit entries the queue holds before new ones are dropped.",
+		}, func() float64 { return float64(cap(auditLogChan)) }),
+	)
+}
+
+// recordAuditWrite counts one audit insert attempt
+func recordAuditWrite(err error) {
+	if err != nil {
+		recordAuditWrites(0, 1, err)
+		return
+	}
+	recordAuditWrites(1, 0, nil)
+}
+
+// recordAuditWrites counts audit entries written and failed, on /metrics and the audit
+// pipeline dashboard; err is the latest failure
+func recordAuditWrites(ok, failed int, err error) {
+	if ok > 0 {
+		auditWritten.WithLabelValues(auditResultOK).Add(float64(ok))
+	}
+	if failed > 0 {
+		auditWritten.WithLabelValues(auditResultError).Add(float64(failed))
+	}
+	auditPipeline.recordWrites(ok, failed, err)
+}
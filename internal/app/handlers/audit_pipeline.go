@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Thresholds behind the status reported by /api/admin/audit-pipeline
+const (
+	auditStallAfter     = 10 * time.Second // queued entries but nothing written for this long
+	auditDropWindow     = time.Minute      // an entry was dropped this recently
+	auditLagUtilization = 0.8              // queue at least this full
+	auditRateWindow     = 60               // seconds of write throughput kept
+)
+
+// auditPipeline holds the in-process state shown on the audit pipeline dashboard. The
+// Prometheus counters in audit_metrics.go cover the same events for alerting; this adds
+// what a counter cannot answer, such as when the workers last flushed.
+var auditPipeline = &auditPipelineStats{startedAt: time.Now()}
+
+// auditPipelineStats is updated by EnqueueAuditLog on the request path, so enqueue and drop
+// counts are atomics; everything recorded by the workers is guarded by mu
+type auditPipelineStats struct {
+	enqueued   atomic.Uint64
+	dropped    atomic.Uint64
+	lastDropAt atomic.Int64 // unix nanoseconds, 0 before the first drop
+
+	mu            sync.Mutex
+	startedAt     time.Time
+	written       uint64
+	failed        uint64
+	batches       uint64
+	batchEntries  uint64
+	lastBatchSize int
+	maxBatchSize  int
+	lastFlushAt   time.Time
+	lastErrorAt   time.Time
+	lastError     string
+	rate          [auditRateWindow]auditRateBucket
+}
+
+// auditRateBucket counts entries written during one second
+type auditRateBucket struct {
+	second  int64
+	written uint64
+}
+
+func (s *auditPipelineStats) recordEnqueued(n int) {
+	s.enqueued.Add(uint64(n))
+}
+
+func (s *auditPipelineStats) recordDropped() {
+	s.dropped.Add(1)
+	s.lastDropAt.Store(time.Now().UnixNano())
+}
+
+// recordBatch counts a batch handed to processAuditBatch
+func (s *auditPipelineStats) recordBatch(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches++
+	s.batchEntries += uint64(size)
+	s.lastBatchSize = size
+	s.maxBatchSize = max(s.maxBatchSize, size)
+}
+
+// recordWrites counts entries written and failed; err is the latest failure, if any
+func (s *auditPipelineStats) recordWrites(ok, failed int, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written += uint64(ok)
+	s.failed += uint64(failed)
+	if ok > 0 {
+		s.lastFlushAt = now
+		bucket := &s.rate[now.Unix()%auditRateWindow]
+		if bucket.second != now.Unix() {
+			*bucket = auditRateBucket{second: now.Unix()}
+		}
+		bucket.written += uint64(ok)
+	}
+	if err != nil {
+		s.lastErrorAt = now
+		s.lastError = err.Error()
+	}
+}
+
+// start resets the clock used for the since-start throughput, when the workers start
+func (s *auditPipelineStats) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startedAt = time.Now()
+}
+
+// AuditPipelineStatus is the /api/admin/audit-pipeline body
+type AuditPipelineStatus struct {
+	Status          string     `json:"status"` // ok, lagging, dropping or stalled
+	Workers         int        `json:"workers"`
+	QueueDepth      int        `json:"queue_depth"`
+	QueueCapacity   int        `json:"queue_capacity"`
+	BatchQueueDepth int        `json:"batch_queue_depth"`
+	Enqueued        uint64     `json:"enqueued_total"`
+	Dropped         uint64     `json:"dropped_total"`
+	Written         uint64     `json:"written_total"`
+	Failed          uint64     `json:"failed_total"`
+	Batches         uint64     `json:"batches_total"`
+	LastBatchSize   int        `json:"last_batch_size"`
+	MaxBatchSize    int        `json:"max_batch_size"`
+	AvgBatchSize    float64    `json:"avg_batch_size"`
+	WrittenPerSec   float64    `json:"written_per_sec"`     // over the last minute
+	AvgPerSec       float64    `json:"avg_written_per_sec"` // since the workers started
+	StartedAt       time.Time  `json:"started_at"`
+	LastFlushAt     *time.Time `json:"last_flush_at"`
+	LastDropAt      *time.Time `json:"last_drop_at"`
+	LastErrorAt     *time.Time `json:"last_error_at"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// snapshot reports the pipeline as of now
+func (s *auditPipelineStats) snapshot(now time.Time) AuditPipelineStatus {
+	st := AuditPipelineStatus{
+		Workers:         numAuditWorkers,
+		QueueDepth:      len(auditLogChan),
+		QueueCapacity:   cap(auditLogChan),
+		BatchQueueDepth: len(auditBatchChan),
+		Enqueued:        s.enqueued.Load(),
+		Dropped:         s.dropped.Load(),
+	}
+	if drop := s.lastDropAt.Load(); drop != 0 {
+		t := time.Unix(0, drop)
+		st.LastDropAt = &t
+	}
+
+	s.mu.Lock()
+	st.Written = s.written
+	st.Failed = s.failed
+	st.Batches = s.batches
+	st.LastBatchSize = s.lastBatchSize
+	st.MaxBatchSize = s.maxBatchSize
+	if s.batches > 0 {
+		st.AvgBatchSize = float64(s.batchEntries) / float64(s.batches)
+	}
+	st.StartedAt = s.startedAt
+	if !s.lastFlushAt.IsZero() {
+		t := s.lastFlushAt
+		st.LastFlushAt = &t
+	}
+	if !s.lastErrorAt.IsZero() {
+		t := s.lastErrorAt
+		st.LastErrorAt = &t
+		st.LastError = s.lastError
+	}
+	var recent uint64
+	for _, b := range s.rate {
+		if now.Unix()-b.second < auditRateWindow {
+			recent += b.written
+		}
+	}
+	s.mu.Unlock()
+
+	uptime := now.Sub(st.StartedAt).Seconds()
+	if uptime > 0 {
+		st.WrittenPerSec = float64(recent) / min(uptime, auditRateWindow)
+		st.AvgPerSec = float64(st.Written) / uptime
+	}
+	st.Status = auditPipelineHealth(st, now)
+	return st
+}
+
+// auditPipelineHealth names the worst condition the pipeline is in
+func auditPipelineHealth(st AuditPipelineStatus, now time.Time) string {
+	lastFlush := st.StartedAt
+	if st.LastFlushAt != nil {
+		lastFlush = *st.LastFlushAt
+	}
+	switch {
+	case st.QueueDepth > 0 && now.Sub(lastFlush) > auditStallAfter:
+		return "stalled"
+	case st.LastDropAt != nil && now.Sub(*st.LastDropAt) < auditDropWindow:
+		return "dropping"
+	case st.QueueCapacity > 0 && float64(st.QueueDepth) >= auditLagUtilization*float64(st.QueueCapacity):
+		return "lagging"
+	}
+	return "ok"
+}
+
+// auditPipelineHandler GET /api/admin/audit-pipeline
+// Shows whether the async audit workers keep up: queue depth, batch sizes, throughput,
+// drops and the last successful flush
+func auditPipelineHandler(c *gin.Context) {
+	response.OK(c, auditPipeline.snapshot(time.Now()))
+}
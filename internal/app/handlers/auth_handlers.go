@@ -1,17 +1,22 @@
 package handlers
 
 import (
+	"adminbe/internal/app/middleware"
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
 	"adminbe/internal/pkg/utils"
 	"context"
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -19,63 +24,248 @@ import (
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Cookie asks for a cookie session (COOKIE_AUTH_ENABLED): the token is set as an HttpOnly
+	// cookie instead of returned, with a CSRF token for unsafe requests
+	Cookie bool `json:"cookie"`
+	// NewPassword replaces the password of a user an administrator made change it (see
+	// POST /api/users/force-password-reset); it is required for them and ignored otherwise
+	NewPassword string `json:"new_password,omitempty" binding:"omitempty,min=6"`
 }
 
-// loginHandler POST /api/auth/login
-func loginHandler(db *gorm.DB) gin.HandlerFunc {
+// loginHandler POST /api/auth/login; tokens expire after expiration. Users with two-factor
+// sign-in on are texted a code instead, redeemed at /api/auth/2fa.
+func loginHandler(db *gorm.DB, hasher *password.Hasher, users services.UserService, otp services.OTPService, expiration time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
+			return
+		}
+		if req.Cookie && !middleware.CookieAuthEnabled() {
+			utils.RespondError(c, http.StatusBadRequest, "Cookie sessions are disabled; use the returned token")
 			return
 		}
 
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
 
+		// Emails are unique per tenant: the one the request is for
 		var user models.User
-		result := db.WithContext(ctx).Where("email = ? AND deleted_at IS NULL", req.Email).First(&user)
+		result := db.WithContext(ctx).Where("email = ? AND tenant_id = ? AND deleted_at IS NULL", req.Email, tenant.ID(ctx)).First(&user)
 		if result.Error != nil {
 			if result.Error == gorm.ErrRecordNotFound {
-				log.Printf("Login failed: user not found for email %s", req.Email)
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				logger(c).Info("Login failed: user not found", "email", req.Email)
+				utils.RespondError(c, http.StatusUnauthorized, "Invalid credentials")
 				return
 			}
-			log.Printf("Error querying user for login: %v", result.Error)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			logger(c).Error("Error querying user for login", "error", result.Error)
+			utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
 			return
 		}
 
 		// Check password
-		err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+		err := hasher.Verify(ctx, user.PasswordHash, req.Password)
+		if errors.Is(err, password.ErrMismatch) {
+			logger(c).Info("Login failed: incorrect password", "email", req.Email)
+			utils.RespondError(c, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
 		if err != nil {
-			log.Printf("Login failed: incorrect password for email %s", req.Email)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			logger(c).Error("Error verifying password for login", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
 			return
 		}
 
+		// Upgrade hashes made with an old algorithm or cost while the plain password is at hand
+		if hasher.NeedsRehash(user.PasswordHash) {
+			if rehashed, err := hasher.Hash(ctx, req.Password); err == nil {
+				err = db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Update("password_hash", rehashed).Error
+				if err != nil {
+					logger(c).Warn("Failed to upgrade password hash", "user_id", user.ID, "error", err)
+				}
+			}
+		}
+
 		// Check status
 		if user.Status != 1 {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account disabled"})
+			utils.RespondError(c, http.StatusUnauthorized, "Account disabled")
+			return
+		}
+
+		// Two-factor sign-in: a code goes to the user's phone and /api/auth/2fa exchanges it
+		// for the token. The SMS timeout applies, not the budget of the lookups above.
+		challenge, err := otp.StartLogin(c.Request.Context(), user.ID)
+		if utils.HandleError(c, err, "start two-factor sign-in") {
+			return
+		}
+		if challenge != nil {
+			// An expired password is replaced on /api/auth/2fa, once the code is in
+			pending := gin.H{"two_factor_required": true, "challenge": challenge.Challenge, "phone": challenge.Phone,
+				"expires_at": challenge.ExpiresAt, "password_change_required": user.PasswordResetRequired}
+			response.Write(c, http.StatusOK, response.Body{Data: pending, Message: "A sign-in code was sent to your phone", Legacy: pending})
+			return
+		}
+
+		if !changeRequiredPassword(ctx, c, db, hasher, users, &user, req.NewPassword) {
+			return
+		}
+		startSession(ctx, c, db, &user, req.Cookie, expiration)
+	}
+}
+
+// twoFactorLoginHandler POST /api/auth/2fa
+// Completes a sign-in /api/auth/login answered with two_factor_required: the challenge and
+// the code texted to the user get the token, as the login would have
+func twoFactorLoginHandler(db *gorm.DB, hasher *password.Hasher, users services.UserService, otp services.OTPService, expiration time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TwoFactorLoginRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		if req.Cookie && !middleware.CookieAuthEnabled() {
+			utils.RespondError(c, http.StatusBadRequest, "Cookie sessions are disabled; use the returned token")
+			return
+		}
+
+		userID, err := otp.CompleteLogin(c.Request.Context(), req.Challenge, req.Code)
+		if utils.HandleError(c, err, "complete two-factor sign-in") {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		// The account may have been disabled or deleted since the code was sent; the challenge
+		// only signs in to the tenant the login was for
+		var user models.User
+		result := db.WithContext(ctx).Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", userID, tenant.ID(ctx)).First(&user)
+		if result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				utils.RespondError(c, http.StatusUnauthorized, "Invalid credentials")
+				return
+			}
+			logger(c).Error("Error querying user for two-factor sign-in", "error", result.Error)
+			utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		if user.Status != 1 {
+			utils.RespondError(c, http.StatusUnauthorized, "Account disabled")
 			return
 		}
 
-		// Generate JWT
-		jwtSecret := utils.GetJWTSecret()
+		if !changeRequiredPassword(ctx, c, db, hasher, users, &user, req.NewPassword) {
+			return
+		}
+		startSession(ctx, c, db, &user, req.Cookie, expiration)
+	}
+}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"user_id":  strconv.FormatUint(user.ID, 10),
-			"username": user.Username,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24 hours
+// changeRequiredPassword sets newPassword for a user an administrator made change their
+// password (POST /api/users/force-password-reset), before they are signed in. Without one it
+// answers 403 PASSWORD_CHANGE_REQUIRED, and the client signs in again with new_password. It
+// reports whether the sign-in can go on.
+func changeRequiredPassword(ctx context.Context, c *gin.Context, db *gorm.DB, hasher *password.Hasher, users services.UserService, user *models.User, newPassword string) bool {
+	if !user.PasswordResetRequired {
+		return true
+	}
+	if newPassword == "" {
+		response.WriteError(c, http.StatusForbidden, response.Failure{
+			Code:    response.CodePasswordChange,
+			Message: "Your password has expired; sign in again with a new_password",
 		})
+		return false
+	}
+	// The current password again would not be a change
+	err := hasher.Verify(ctx, user.PasswordHash, newPassword)
+	if err == nil {
+		utils.HandleError(c, utils.NewValidationError("The new password must differ from the current one"), "change expired password")
+		return false
+	}
+	if !errors.Is(err, password.ErrMismatch) {
+		logger(c).Error("Error verifying new password", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if _, err := users.UpdateUser(ctx, strconv.FormatUint(user.ID, 10), models.UpdateUserRequest{Password: newPassword}); utils.HandleError(c, err, "change expired password") {
+		return false
+	}
+	// No one is signed in yet, so the entry is the user's own
+	sqlDB, _ := db.DB()
+	if !EnqueueAuditLog(sqlDB, user.ID, "UPDATE", "users", user.ID, gin.H{"password_reset_required": true}, gin.H{"password_reset_required": false}) {
+		logger(c).Warn("Audit log queue full, dropping entry", "event", "UPDATE", "table", "users", "record_id", user.ID)
+	}
+	return true
+}
+
+// startSession answers a signed-in user with a token for them, or sets it as a cookie
+// session when cookie is set
+func startSession(ctx context.Context, c *gin.Context, db *gorm.DB, user *models.User, cookie bool, expiration time.Duration) {
+	// Load role names for the token so middleware can scope by role without a DB hit
+	var roles []string
+	err := db.WithContext(ctx).Raw(`
+		SELECT r.name FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id AND r.deleted_at IS NULL
+		WHERE ur.user_id = ? AND ur.deleted_at IS NULL
+		ORDER BY r.name`, user.ID).Scan(&roles).Error
+	if err != nil {
+		logger(c).Error("Error loading roles for login", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	// Generate JWT; the token is only good for the tenant it was issued by
+	tokenString, err := jwtkeys.Default.Sign(jwt.MapClaims{
+		"user_id":   strconv.FormatUint(user.ID, 10),
+		"username":  user.Username,
+		"roles":     roles,
+		"tenant_id": tenant.ID(ctx),
+		"exp":       time.Now().Add(expiration).Unix(),
+	})
+	if err != nil {
+		logger(c).Error("Error generating JWT", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Token generation failed")
+		return
+	}
 
-		tokenString, err := token.SignedString([]byte(jwtSecret))
+	// For POST /api/users/force-password-reset; updated_at is left as it is
+	err = db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("last_login_at", time.Now()).Error
+	if err != nil {
+		logger(c).Warn("Failed to record sign-in time", "user_id", user.ID, "error", err)
+	}
+
+	session := gin.H{"token": tokenString, "user": gin.H{"id": user.ID, "username": user.Username, "email": user.Email}}
+	if cookie {
+		// The token stays out of the body, where scripts could read it
+		csrfToken, err := middleware.StartCookieSession(c, tokenString, expiration)
 		if err != nil {
-			log.Printf("Error generating JWT: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+			logger(c).Error("Error generating CSRF token", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Token generation failed")
 			return
 		}
+		delete(session, "token")
+		session["csrf_token"] = csrfToken
+	}
+	response.Write(c, http.StatusOK, response.Body{Data: session, Legacy: session})
+}
+
+// csrfTokenHandler GET /api/auth/csrf
+// A new CSRF token for the session, also set as the CSRF cookie, e.g. after a 403 CSRF_INVALID
+func csrfTokenHandler(c *gin.Context) {
+	token, err := middleware.IssueCSRFToken(c)
+	if err != nil {
+		logger(c).Error("Error generating CSRF token", "error", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Token generation failed")
+		return
+	}
+	response.OK(c, gin.H{"csrf_token": token})
+}
 
-		c.JSON(http.StatusOK, gin.H{"token": tokenString, "user": gin.H{"id": user.ID, "username": user.Username, "email": user.Email}})
+// logoutHandler POST /api/auth/logout
+// Clears the session cookies. The token itself stays valid until it expires, so bearer
+// clients simply discard theirs.
+func logoutHandler(c *gin.Context) {
+	if middleware.CookieSession(c) {
+		middleware.EndCookieSession(c)
 	}
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tracing"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchRequest is the body of POST /api/batch
+type BatchRequest struct {
+	Requests []BatchItem `json:"requests" binding:"required,min=1,dive"`
+}
+
+// BatchItem is one sub-request of a batch
+type BatchItem struct {
+	Method string          `json:"method" binding:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path   string          `json:"path" binding:"required"` // with its query, e.g. "/api/users?limit=5"
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the response to one sub-request
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// transactionalRoutes are the writes whose repositories join a transaction carried by the
+// request context. A batch runs in one transaction only when all its writes are among them;
+// the other routes write through the connection pool and could not be rolled back.
+var transactionalRoutes = []string{
+	"POST /api/users", "PUT /api/users/:id", "PATCH /api/users/:id", "DELETE /api/users/:id",
+	"POST /api/roles", "PATCH /api/roles/:id",
+	"POST /api/menu", "PUT /api/menu/:id", "PATCH /api/menu/:id", "DELETE /api/menu/:id",
+	"POST /api/users/bulk-delete", "POST /api/users/bulk-restore",
+	"POST /api/roles/bulk-delete", "POST /api/roles/bulk-restore",
+	"POST /api/menu/bulk-delete", "POST /api/menu/bulk-restore",
+}
+
+// batchExcluded are path prefixes a batch may not call: itself, and routes answering with
+// files or streams
+var batchExcluded = []string{"/api/batch", "/api/reports", "/api/users/export", "/api/audit_logs/export"}
+
+// errBatchFailed rolls back an atomic batch after one of its requests failed
+var errBatchFailed = errors.New("batch request failed")
+
+// batchAuditKey holds the audit entries of an atomic batch until it commits
+type batchAuditKey struct{}
+
+// deferBatchAudit keeps entry until the batch that c runs in commits, reporting false
+// outside an atomic batch
+func deferBatchAudit(c *gin.Context, entry auditLogEntry) bool {
+	pending, ok := c.Request.Context().Value(batchAuditKey{}).(*[]auditLogEntry)
+	if !ok {
+		return false
+	}
+	*pending = append(*pending, entry)
+	return true
+}
+
+// afterCommit runs fn once the writes c made are committed: straight away, or when the
+// atomic batch c runs in commits (and never if it rolls back)
+func afterCommit(c *gin.Context, fn func()) {
+	repositories.AfterCommit(c.Request.Context(), fn)
+}
+
+// batchHandler POST /api/batch
+// Runs up to maxItems sub-requests in order through engine, as if the client had sent them
+// with its own credentials, and answers with each one's status and body. When every write in
+// the batch joins a transaction (see transactionalRoutes) the batch is atomic: the first
+// failure (4xx or 5xx) rolls back the writes before it, and the requests after it are not
+// run. Otherwise each request stands on its own and all of them run.
+func batchHandler(engine *gin.Engine, txManager repositories.TxManager, maxItems int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req BatchRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		if len(req.Requests) > maxItems {
+			utils.HandleError(c, utils.NewValidationError(fmt.Sprintf("A batch holds at most %d requests", maxItems)), "run batch")
+			return
+		}
+		for i, item := range req.Requests {
+			if err := checkBatchPath(item.Path); err != nil {
+				utils.HandleError(c, utils.NewValidationError(fmt.Sprintf("requests[%d]: %v", i, err)), "run batch")
+				return
+			}
+		}
+
+		atomic := batchIsAtomic(req.Requests)
+		results := make([]BatchResult, len(req.Requests))
+		var audits []auditLogEntry
+
+		ctx := middleware.WithinBatch(c.Request.Context())
+		run := func(ctx context.Context) error {
+			for i, item := range req.Requests {
+				results[i] = runBatchItem(ctx, engine, c.Request, item)
+				if atomic && results[i].Status >= http.StatusBadRequest {
+					for j := i + 1; j < len(results); j++ {
+						results[j] = batchNotRun(c)
+					}
+					return errBatchFailed
+				}
+			}
+			return nil
+		}
+
+		var err error
+		if atomic {
+			err = txManager.WithinTx(context.WithValue(ctx, batchAuditKey{}, &audits), run)
+		} else {
+			err = run(ctx)
+		}
+		if err != nil && !errors.Is(err, errBatchFailed) {
+			// The transaction could not begin or commit, so none of the writes happened
+			utils.HandleError(c, err, "run batch")
+			return
+		}
+
+		committed := err == nil
+		for _, a := range audits {
+			if committed {
+				if !EnqueueAuditLog(a.DB, a.UserID, a.Event, a.Table, a.RecordID, a.OldValues, a.NewValues) {
+					logger(c).Warn("Audit log queue full, dropping entry", "event", a.Event, "table", a.Table, "record_id", a.RecordID)
+				}
+			} else {
+				// Reads later in the batch may have cached rolled-back state; drop it again
+				events.EntityChanged(a.Table, events.ActionUpdated, strconv.FormatUint(a.RecordID, 10))
+			}
+		}
+
+		meta := response.Meta{"atomic": atomic}
+		if atomic {
+			meta["committed"] = committed
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: results, Meta: meta})
+	}
+}
+
+// checkBatchPath accepts a clean /api path outside batchExcluded
+func checkBatchPath(p string) error {
+	u, err := url.ParseRequestURI(p)
+	if err != nil || u.Host != "" || !strings.HasPrefix(u.Path, "/api/") || path.Clean(u.Path) != u.Path {
+		return fmt.Errorf("path must be an /api route, e.g. /api/users/1")
+	}
+	for _, prefix := range batchExcluded {
+		if u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/") {
+			return fmt.Errorf("%s cannot be called in a batch", prefix)
+		}
+	}
+	return nil
+}
+
+// batchIsAtomic reports whether every write among items joins a transaction
+func batchIsAtomic(items []BatchItem) bool {
+	for _, item := range items {
+		if item.Method == http.MethodGet {
+			continue
+		}
+		p, _, _ := strings.Cut(item.Path, "?")
+		transactional := false
+		for _, route := range transactionalRoutes {
+			method, template, _ := strings.Cut(route, " ")
+			if method == item.Method && routeMatches(template, p) {
+				transactional = true
+				break
+			}
+		}
+		if !transactional {
+			return false
+		}
+	}
+	return true
+}
+
+// routeMatches reports whether p matches a gin route template with :params
+func routeMatches(template, p string) bool {
+	want, got := strings.Split(template, "/"), strings.Split(p, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if strings.HasPrefix(want[i], ":") {
+			if got[i] == "" {
+				return false
+			}
+		} else if want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runBatchItem serves item through engine with the batch request's credentials and ctx
+func runBatchItem(ctx context.Context, engine *gin.Engine, outer *http.Request, item BatchItem) BatchResult {
+	sub, err := http.NewRequestWithContext(ctx, item.Method, item.Path, bytes.NewReader(item.Body))
+	if err != nil {
+		return BatchResult{Status: http.StatusBadRequest}
+	}
+	// A cookie session's sub-requests carry the session and CSRF token the batch was checked
+	// with, and with the proxy headers and RemoteAddr the client address resolves as the batch's
+	// did, for the IP filter, per-address rate limits, logs and audit entries
+	for _, name := range []string{"Authorization", "Cookie", middleware.HeaderCSRFToken, response.HeaderAcceptVersion, tracing.HeaderRequestID,
+		"X-Forwarded-For", "X-Real-IP"} {
+		if v := outer.Header.Get(name); v != "" {
+			sub.Header.Set(name, v)
+		}
+	}
+	if len(item.Body) > 0 {
+		if item.Method == http.MethodPatch {
+			sub.Header.Set("Content-Type", MIMEMergePatch)
+		} else {
+			sub.Header.Set("Content-Type", "application/json")
+		}
+	}
+	sub.RemoteAddr = outer.RemoteAddr
+
+	w := &batchResponseWriter{header: http.Header{}, status: http.StatusOK}
+	engine.ServeHTTP(w, sub)
+
+	result := BatchResult{Status: w.status}
+	if b := w.body.Bytes(); len(b) > 0 {
+		if json.Valid(b) {
+			result.Body = b
+		} else {
+			result.Body, _ = json.Marshal(string(b))
+		}
+	}
+	return result
+}
+
+// batchNotRun is the result of a request skipped after an earlier one failed
+func batchNotRun(c *gin.Context) BatchResult {
+	// Sub-requests never get problem details (their Accept is not the batch's), so neither
+	// does a skipped one
+	body, _ := json.Marshal(response.ErrorFor(response.Negotiate(c), tracing.RequestID(c.Request.Context()), response.Failure{
+		Code:    response.CodeFailedDependency,
+		Message: "Not run: an earlier request in the batch failed",
+	}))
+	return BatchResult{Status: http.StatusFailedDependency, Body: body}
+}
+
+// batchResponseWriter collects a sub-request's response
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestBatchSubRequestsKeepTheClientAddress checks a sub-request resolves the client address
+// as the batch did: from X-Forwarded-For behind a trusted proxy, and from the connection
+// when the sender is not trusted
+func TestBatchSubRequestsKeepTheClientAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	if err := engine.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	// A POST outside transactionalRoutes keeps the batch non-atomic, so it needs no TxManager
+	engine.POST("/api/client-ip", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ip": c.ClientIP()})
+	})
+	engine.POST("/api/batch", batchHandler(engine, nil, 10))
+
+	for _, tc := range []struct {
+		name, remoteAddr, want string
+	}{
+		{"trusted proxy", "10.1.2.3:5000", "198.51.100.7"},
+		{"untrusted sender", "203.0.113.9:5000", "203.0.113.9"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"requests":[{"method":"POST","path":"/api/client-ip"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewBufferString(body))
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			req.Header.Set("X-Real-IP", "198.51.100.7")
+			req.Header.Set(response.HeaderAcceptVersion, "2")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			var batch struct {
+				Data []BatchResult `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil || len(batch.Data) != 1 {
+				t.Fatalf("batch response %d %s: %v", w.Code, w.Body, err)
+			}
+			var sub struct {
+				IP string `json:"ip"`
+			}
+			if err := json.Unmarshal(batch.Data[0].Body, &sub); err != nil {
+				t.Fatalf("sub-response %s: %v", batch.Data[0].Body, err)
+			}
+			if sub.IP != tc.want {
+				t.Errorf("sub-request client IP = %q, want %q", sub.IP, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brandingHandler GET /api/branding
+// Public, so the sign-in page can show it: the BRANDING_* strings, with those the request's
+// tenant overrides in their place.
+func brandingHandler(branding models.Branding) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.OK(c, branding.With(tenant.SettingsFrom(c.Request.Context()).Branding))
+	}
+}
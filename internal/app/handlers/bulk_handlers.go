@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bulkDeleteHandler POST /api/users/bulk-delete, /api/roles/bulk-delete, /api/menu/bulk-delete
+// Soft deletes the rows of table whose IDs the body lists, at most maxIDs, in one
+// transaction. IDs that do not exist or are already deleted are skipped and reported as
+// such; a database error rolls back the whole request. Each row deleted gets a DELETE
+// audit entry once the transaction commits.
+func bulkDeleteHandler(table, noun string, del func(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error), maxIDs int, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ids, ok := bindBulkIDs(c, maxIDs)
+		if !ok {
+			return
+		}
+		by := getUserIDFromContext(c)
+		result, err := del(c.Request.Context(), ids, by)
+		if utils.HandleError(c, err, "bulk delete "+table) {
+			return
+		}
+		for _, o := range result.Outcomes {
+			if o.Before != nil {
+				logAuditEntry(c, "DELETE", table, o.ID, o.Before, models.SoftDeleteState{DeletedAt: &result.At, DeletedBy: by}, db)
+			}
+		}
+		writeBulkResult(c, result, noun+" deleted")
+	}
+}
+
+// bulkRestoreHandler POST /api/users/bulk-restore, /api/roles/bulk-restore, /api/menu/bulk-restore
+// Brings back the soft-deleted rows of table whose IDs the body lists, as bulkDeleteHandler
+// deletes them, with a RESTORE audit entry for each
+func bulkRestoreHandler(table, noun string, restore func(ctx context.Context, ids []uint64) (*models.BulkResult, error), maxIDs int, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ids, ok := bindBulkIDs(c, maxIDs)
+		if !ok {
+			return
+		}
+		result, err := restore(c.Request.Context(), ids)
+		if utils.HandleError(c, err, "bulk restore "+table) {
+			return
+		}
+		for _, o := range result.Outcomes {
+			if o.Before != nil {
+				logAuditEntry(c, "RESTORE", table, o.ID, o.Before, models.SoftDeleteState{}, db)
+			}
+		}
+		writeBulkResult(c, result, noun+" restored")
+	}
+}
+
+// bindBulkIDs reads the IDs of a bulk request, answering 400 for more than maxIDs
+func bindBulkIDs(c *gin.Context, maxIDs int) ([]uint64, bool) {
+	var req models.BulkIDsRequest
+	if !bindJSONRequest(c, &req) {
+		return nil, false
+	}
+	if len(req.IDs) > maxIDs {
+		utils.HandleError(c, utils.NewValidationError(fmt.Sprintf("A bulk request lists at most %d IDs", maxIDs)), "bind bulk request")
+		return nil, false
+	}
+	return req.IDs, true
+}
+
+// writeBulkResult answers 200 with each ID's outcome, whether or not any row changed
+func writeBulkResult(c *gin.Context, result *models.BulkResult, done string) {
+	response.Write(c, http.StatusOK, response.Body{
+		Data:    result.Outcomes,
+		Message: fmt.Sprintf("%d of %d %s", result.Changed, len(result.Outcomes), done),
+		Meta:    response.Meta{"changed": result.Changed, "skipped": result.Skipped},
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"adminbe/internal/pkg/utils"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheKeyInfo describes a cache key and its remaining TTL
+type cacheKeyInfo struct {
+	Key        string  `json:"key"`
+	TTLSeconds float64 `json:"ttl_seconds"` // -1 = no expiry, -2 = missing
+}
+
+// WarmCacheRequest selects which known keys to warm (all when empty)
+type WarmCacheRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// ensureCachePrefix keeps admin operations inside the application namespace.
+// Fully qualified keys are kept as-is so keys left by older key versions can still be inspected.
+func ensureCachePrefix(pattern string) string {
+	if !strings.HasPrefix(pattern, cache.CacheKeyRoot) {
+		return cache.CacheKeyPrefix + pattern
+	}
+	return pattern
+}
+
+// listCacheKeysHandler GET /api/admin/cache/keys?pattern=menus:*&limit=100
+func listCacheKeysHandler(store cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pattern := ensureCachePrefix(c.DefaultQuery("pattern", "*"))
+		limit := parseIntMinMax(c.DefaultQuery("limit", "100"), 100, 1, 1000)
+
+		keys, err := store.Keys(pattern, limit)
+		if err != nil {
+			logger(c).Error("Error listing cache keys", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to list cache keys")
+			return
+		}
+
+		infos := make([]cacheKeyInfo, 0, len(keys))
+		for _, key := range keys {
+			ttl, err := store.GetTTL(key)
+			if err != nil {
+				logger(c).Error("Error reading TTL for cache key", "key", key, "error", err)
+				continue
+			}
+			infos = append(infos, cacheKeyInfo{Key: key, TTLSeconds: ttlSeconds(ttl)})
+		}
+
+		response.Write(c, http.StatusOK, response.Body{Data: infos, Meta: response.Meta{"pattern": pattern, "count": len(infos)}})
+	}
+}
+
+// getCacheKeyHandler GET /api/admin/cache/key?key=cms:v1:menus:list
+func getCacheKeyHandler(store cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Query("key")
+		if key == "" {
+			utils.RespondError(c, http.StatusBadRequest, "key parameter is required")
+			return
+		}
+		key = ensureCachePrefix(key)
+
+		var value json.RawMessage
+		err := store.Get(key, &value)
+		if cache.IsCacheMiss(err) {
+			utils.RespondError(c, http.StatusNotFound, "Cache key not found")
+			return
+		}
+		if err != nil {
+			logger(c).Error("Error reading cache key", "key", key, "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to read cache key")
+			return
+		}
+
+		ttl, err := store.GetTTL(key)
+		if err != nil {
+			logger(c).Error("Error reading TTL for cache key", "key", key, "error", err)
+		}
+
+		response.OK(c, gin.H{
+			"key":         key,
+			"ttl_seconds": ttlSeconds(ttl),
+			"size_bytes":  len(value),
+			"value":       value,
+		})
+	}
+}
+
+// flushCacheNamespaceHandler DELETE /api/admin/cache/namespaces/:namespace
+func flushCacheNamespaceHandler(store cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := strings.Trim(c.Param("namespace"), ":*")
+		if namespace == "" {
+			utils.RespondError(c, http.StatusBadRequest, "Namespace is required")
+			return
+		}
+
+		pattern := cache.CacheKeyPrefix + namespace + ":*"
+		if err := store.DeletePattern(pattern); err != nil {
+			logger(c).Error("Error flushing cache namespace", "namespace", namespace, "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to flush cache namespace")
+			return
+		}
+
+		logger(c).Info("Cache namespace flushed", "namespace", namespace, "user_id", getUserIDFromContext(c))
+		response.Write(c, http.StatusOK, response.Body{Message: "Cache namespace flushed", Meta: response.Meta{"pattern": pattern}})
+	}
+}
+
+// listCacheWarmersHandler GET /api/admin/cache/warm
+func listCacheWarmersHandler(c *gin.Context) {
+	response.OK(c, cache.WarmableKeys())
+}
+
+// warmCacheHandler POST /api/admin/cache/warm
+func warmCacheHandler(store cache.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req WarmCacheRequest
+		if c.Request.ContentLength > 0 && !bindJSONRequest(c, &req) {
+			return
+		}
+
+		results := cache.Warm(store, req.Keys...)
+
+		status := http.StatusOK
+		data := make(map[string]string, len(results))
+		for key, err := range results {
+			if err != nil {
+				logger(c).Error("Error warming cache key", "key", key, "error", err)
+				data[key] = "failed"
+				status = http.StatusMultiStatus
+				continue
+			}
+			data[key] = "warmed"
+		}
+
+		response.Write(c, status, response.Body{Data: data})
+	}
+}
+
+// ttlSeconds converts a Redis TTL into seconds, preserving the -1/-2 sentinels
+func ttlSeconds(ttl time.Duration) float64 {
+	if ttl < 0 {
+		return float64(ttl)
+	}
+	return ttl.Seconds()
+}
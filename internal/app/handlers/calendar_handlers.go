@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calendarSyncJob is the scheduler job that publishes the next runs of report schedules
+const calendarSyncJob = "calendar_sync"
+
+// calendarStatusHandler GET /api/admin/calendar
+// The provider report schedules are published to, whether it can write and the last sync
+func calendarStatusHandler(calendars services.CalendarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := calendars.Status(c.Request.Context())
+		if utils.HandleError(c, err, "get calendar status") {
+			return
+		}
+		response.OK(c, status)
+	}
+}
+
+// authorizeCalendarHandler GET /api/admin/calendar/authorize
+// The Google consent page to connect the account schedules are published with. Google
+// sends the administrator back to GOOGLE_OAUTH_REDIRECT_URL, which must route to
+// /api/calendar/oauth/callback.
+func authorizeCalendarHandler(calendars services.CalendarService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		authorization, err := calendars.AuthorizeURL(c.Request.Context(), userID)
+		if utils.HandleError(c, err, "authorize calendar") {
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		response.OK(c, authorization)
+	}
+}
+
+// calendarCallbackHandler GET /api/calendar/oauth/callback
+// Where Google sends the administrator after the consent page. It carries no token: the
+// single-use state handed out by /api/admin/calendar/authorize stands for the administrator.
+func calendarCallbackHandler(calendars services.CalendarService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reason := c.Query("error"); reason != "" {
+			utils.RespondError(c, http.StatusBadRequest, "Google Calendar was not connected: "+reason)
+			return
+		}
+		status, err := calendars.Authorize(c.Request.Context(), c.Query("state"), c.Query("code"))
+		if utils.HandleError(c, err, "connect calendar") {
+			return
+		}
+		if status.ConnectedBy != nil && !EnqueueAuditLog(db, *status.ConnectedBy, "CREATE", "calendar_credentials", 0, nil, status) {
+			logger(c).Warn("Audit log queue full, dropping entry", "event", "CREATE", "table", "calendar_credentials")
+		}
+		syncCalendar(c, jobs)
+		c.Header("Cache-Control", "no-store")
+		response.Write(c, http.StatusOK, response.Body{Data: status, Message: "Google Calendar connected"})
+	}
+}
+
+// disconnectCalendarHandler DELETE /api/admin/calendar/credentials
+// Forgets the connected Google account; events already published stay in its calendar
+func disconnectCalendarHandler(calendars services.CalendarService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before, err := calendars.Status(c.Request.Context())
+		if utils.HandleError(c, err, "disconnect calendar") {
+			return
+		}
+		if err := calendars.Disconnect(c.Request.Context()); utils.HandleError(c, err, "disconnect calendar") {
+			return
+		}
+		logAuditEntry(c, "DELETE", "calendar_credentials", 0, before, nil, db)
+		response.Write(c, http.StatusOK, response.Body{Message: "Calendar disconnected"})
+	}
+}
+
+// syncCalendarHandler POST /api/admin/calendar/sync
+// Starts the calendar_sync job; GET /api/admin/jobs shows how it went
+func syncCalendarHandler(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch err := jobs.RunNow(calendarSyncJob); {
+		case errors.Is(err, scheduler.ErrJobRunning):
+			utils.RespondError(c, http.StatusConflict, "The calendar is already syncing")
+			return
+		case err != nil:
+			utils.HandleError(c, err, "sync calendar")
+			return
+		}
+		response.Write(c, http.StatusAccepted, response.Body{Message: "Calendar sync started"})
+	}
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/pkg/alerting"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/fieldcrypt"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/payloadlog"
+	"adminbe/internal/pkg/push"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/storage"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigChange is a setting that differed on a configuration reload
+type ConfigChange struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	// Applied is false for settings read only at startup; they take effect on the next restart
+	Applied bool `json:"applied"`
+}
+
+// ConfigReloader re-reads the env file and config.yaml on SIGHUP or POST
+// /api/admin/runtime/reload and applies the tunables that can change while serving: the log
+// level, the JWT secret, the database credentials, the JasperServer endpoint and account, the
+// concurrency and rate limits, the field encryption keys and the cache TTL overrides. Every
+// other change is logged as waiting for a restart. Each instance reloads its own files.
+// Settings from a secrets manager are also fetched again every SECRETS_REFRESH_INTERVAL.
+type ConfigReloader struct {
+	mu      sync.Mutex
+	envFile string
+	path    string
+	// running is the configuration in effect: the startup one with the applied reloads on top
+	running config.Config
+	// loaded is the configuration last read, whose secrets manager references are refreshed
+	loaded *config.Config
+
+	// The limiters and rate limit policies SetupRoutes creates
+	global, reports, exports, ws, eventStream *middleware.ConcurrencyLimiter
+	loginRate, prayerRate, apiRate            *middleware.RateLimitPolicy
+	loginChallenge, prayerChallenge           *middleware.RateLimitPolicy
+}
+
+// NewConfigReloader creates a reloader over the configuration cfg was loaded from
+func NewConfigReloader(cfg *config.Config, envFile, path string) *ConfigReloader {
+	return &ConfigReloader{envFile: envFile, path: path, running: *cfg, loaded: cfg}
+}
+
+// Reload reads and validates the configuration, applies what it can and logs every setting
+// that changed; source names the trigger in the logs. An invalid configuration changes nothing.
+func (r *ConfigReloader) Reload(source string) ([]ConfigChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := config.LoadEnvFile(r.envFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	next, err := config.Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+	r.loaded = next
+
+	changes := r.update(source, next)
+	for _, change := range redact(reloadCacheTTLs()) {
+		slog.Info("Configuration changed", "source", source, "setting", change.Setting, "old", change.Old, "new", change.New)
+		changes = append(changes, change)
+	}
+	slog.Info("Configuration reloaded", "source", source, "changes", len(changes))
+	return changes, nil
+}
+
+// RefreshSecrets fetches the settings that come from a secrets manager again and applies
+// those that changed, as Reload would, without re-reading the files
+func (r *ConfigReloader) RefreshSecrets(ctx context.Context) ([]ConfigChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.loaded.RefreshSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.loaded = next
+	return r.update("secrets", next), nil
+}
+
+// WatchSecrets calls RefreshSecrets every interval until ctx ends
+func (r *ConfigReloader) WatchSecrets(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RefreshSecrets(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Secrets refresh failed, keeping the current values", "error", err)
+			}
+		}
+	}
+}
+
+// update applies what it can of next, then logs and returns every setting that differed
+func (r *ConfigReloader) update(source string, next *config.Config) []ConfigChange {
+	diff := config.Diff(&r.running, next)
+	r.apply(next)
+	pending := make(map[string]bool)
+	for _, change := range config.Diff(&r.running, next) {
+		pending[change.Setting] = true
+	}
+
+	changes := make([]ConfigChange, 0, len(diff))
+	for _, change := range diff {
+		changes = append(changes, ConfigChange{Setting: change.Setting, Old: change.Old, New: change.New, Applied: !pending[change.Setting]})
+	}
+
+	for _, change := range redact(changes) {
+		if change.Applied {
+			slog.Info("Configuration changed", "source", source, "setting", change.Setting, "old", change.Old, "new", change.New)
+		} else {
+			slog.Warn("Configuration changed, restart to apply", "source", source, "setting", change.Setting, "old", change.Old, "new", change.New)
+		}
+	}
+	return changes
+}
+
+// redact hides the values of credentials, in place
+func redact(changes []ConfigChange) []ConfigChange {
+	for i, change := range changes {
+		if payloadlog.Sensitive(change.Setting) || strings.Contains(strings.ToLower(change.Setting), "dsn") || change.Setting == "JWT_KEYS" || change.Setting == "AUDIT_INGEST_KEYS" {
+			changes[i].Old, changes[i].New = payloadlog.Redacted, payloadlog.Redacted
+		}
+	}
+	return changes
+}
+
+// apply puts the reloadable settings of next into effect and into r.running
+func (r *ConfigReloader) apply(next *config.Config) {
+	if next.Logging.Level != r.running.Logging.Level {
+		logging.SetLevel(next.Logging.Level)
+		r.running.Logging.Level = next.Logging.Level
+	}
+
+	// A new JWT_SECRET signs out the users it signed in, as on a restart; a key added in front
+	// of JWT_KEYS signs new tokens while the others still verify theirs
+	if j := next.JWT; j.Secret != r.running.JWT.Secret || !slices.Equal(j.Keys, r.running.JWT.Keys) {
+		if err := jwtkeys.Default.Configure(j.Secret, j.Keys, r.running.JWT.Expiration); err != nil {
+			slog.Error("JWT keys not reloaded", "error", err)
+		} else {
+			r.running.JWT.Secret, r.running.JWT.Keys = j.Secret, j.Keys
+		}
+	}
+
+	// New connections log in with the new credentials; open ones keep their session
+	if db := next.Database; db.User != r.running.Database.User || db.Password != r.running.Database.Password ||
+		db.Replica.DSN != r.running.Database.Replica.DSN || db.Replica.User != r.running.Database.Replica.User ||
+		db.Replica.Password != r.running.Database.Replica.Password {
+		database.SetCredentials(db)
+		r.running.Database.User, r.running.Database.Password = db.User, db.Password
+		r.running.Database.Replica.DSN, r.running.Database.Replica.User, r.running.Database.Replica.Password =
+			db.Replica.DSN, db.Replica.User, db.Replica.Password
+	}
+
+	if next.Jasper != r.running.Jasper {
+		if jasperClient != nil {
+			jasperClient.SetConfig(next.Jasper)
+		}
+		r.running.Jasper = next.Jasper
+	}
+
+	limits := next.Limits
+	if limits != r.running.Limits || next.WebSocket.MaxClients != r.running.WebSocket.MaxClients ||
+		next.EventStream.MaxClients != r.running.EventStream.MaxClients {
+		// The queue sizes of the group limiters are fixed, as in SetupRoutes
+		for _, l := range []struct {
+			limiter         *middleware.ConcurrencyLimiter
+			limit, maxQueue int
+		}{
+			{r.global, limits.MaxConcurrentRequests, limits.MaxQueuedRequests},
+			{r.reports, limits.ReportMaxConcurrent, 16},
+			{r.exports, limits.ExportMaxConcurrent, 0},
+			{r.ws, next.WebSocket.MaxClients, 0},
+			{r.eventStream, next.EventStream.MaxClients, 0},
+		} {
+			if l.limiter != nil {
+				l.limiter.SetLimits(l.limit, l.maxQueue, limits.QueueTimeout)
+			}
+		}
+		r.running.Limits = limits
+		r.running.WebSocket.MaxClients = next.WebSocket.MaxClients
+		r.running.EventStream.MaxClients = next.EventStream.MaxClients
+	}
+
+	// The keys file is re-read every time, as a secret manager may have rotated its contents
+	if enc := next.Encryption; enc.KeysFile != "" || enc.KeysFile != r.running.Encryption.KeysFile ||
+		!slices.Equal(enc.Keys, r.running.Encryption.Keys) {
+		if err := fieldcrypt.Default.Configure(enc); err != nil {
+			slog.Error("Field encryption keys not reloaded", "error", err)
+		} else {
+			r.running.Encryption = enc
+		}
+	}
+
+	// Lists an administrator set stay in effect until reset
+	if ip := next.IPFilter; !slices.Equal(ip.Allow, r.running.IPFilter.Allow) || !slices.Equal(ip.Deny, r.running.IPFilter.Deny) {
+		if err := ipfilter.Default.Configure(ip); err != nil {
+			slog.Error("IP filter lists not reloaded", "error", err)
+		} else {
+			r.running.IPFilter = ip
+		}
+	}
+
+	if se := next.SecurityEvents; se.AuthFailureThreshold != r.running.SecurityEvents.AuthFailureThreshold ||
+		se.AuthFailureWindow != r.running.SecurityEvents.AuthFailureWindow ||
+		!slices.Equal(se.PrivilegedRoles, r.running.SecurityEvents.PrivilegedRoles) {
+		secevents.Default.Configure(se)
+		r.running.SecurityEvents = se
+	}
+
+	if next.RateLimit != r.running.RateLimit {
+		for _, p := range []struct {
+			policy *middleware.RateLimitPolicy
+			rate   ratelimit.Rate
+		}{
+			{r.loginRate, next.RateLimit.Login},
+			{r.prayerRate, next.RateLimit.Prayer},
+			{r.apiRate, next.RateLimit.API},
+		} {
+			if p.policy != nil {
+				p.policy.SetRate(p.rate)
+			}
+		}
+		r.running.RateLimit = next.RateLimit
+	}
+
+	if next.Challenge != r.running.Challenge {
+		if err := challenge.Default.Configure(next.Challenge); err != nil {
+			slog.Error("Challenge settings not reloaded", "error", err)
+		} else {
+			for _, p := range []struct {
+				policy *middleware.RateLimitPolicy
+				rate   ratelimit.Rate
+			}{
+				{r.loginChallenge, next.Challenge.LoginAfter},
+				{r.prayerChallenge, next.Challenge.PrayerAfter},
+			} {
+				if p.policy != nil {
+					p.policy.SetRate(p.rate)
+				}
+			}
+			r.running.Challenge = next.Challenge
+		}
+	}
+
+	// The workers and the retry policy are fixed at startup; the driver, its credentials and
+	// the sender change, and the templates directory is re-read every time so edited
+	// templates apply
+	m := next.Mail
+	m.Workers, m.MaxAttempts, m.RetryBackoff = r.running.Mail.Workers, r.running.Mail.MaxAttempts, r.running.Mail.RetryBackoff
+	if m != r.running.Mail || m.TemplatesDir != "" {
+		if err := mail.Default.Configure(m); err != nil {
+			slog.Error("Mail settings not reloaded", "error", err)
+		} else {
+			r.running.Mail = m
+		}
+	}
+
+	if next.Chatbot != r.running.Chatbot {
+		chatbot.Default.Configure(next.Chatbot)
+		r.running.Chatbot = next.Chatbot
+	}
+
+	if next.Alerting != r.running.Alerting {
+		alerting.Default.Configure(next.Alerting)
+		r.running.Alerting = next.Alerting
+	}
+
+	// A credentials file is re-read every time so a rotated key applies
+	if next.Push != r.running.Push || next.Push.CredentialsFile != "" {
+		if err := push.Default.Configure(next.Push); err != nil {
+			slog.Error("Push settings not reloaded", "error", err)
+		} else {
+			r.running.Push = next.Push
+		}
+	}
+
+	// The body limit of the upload routes is set when they are, so the upload size limit
+	// waits for a restart
+	nextStorage := next.Storage
+	nextStorage.MaxUploadBytes = r.running.Storage.MaxUploadBytes
+	if nextStorage != r.running.Storage {
+		if err := storage.Default.Configure(nextStorage); err != nil {
+			slog.Error("Storage settings not reloaded", "error", err)
+		} else {
+			r.running.Storage = nextStorage
+		}
+	}
+
+	if next.Geocoder != r.running.Geocoder {
+		if err := geocode.Default.Configure(next.Geocoder); err != nil {
+			slog.Error("Geocoder settings not reloaded", "error", err)
+		} else {
+			r.running.Geocoder = next.Geocoder
+		}
+	}
+
+	if next.Calendar != r.running.Calendar {
+		if err := calendar.Default.Configure(next.Calendar); err != nil {
+			slog.Error("Calendar settings not reloaded", "error", err)
+		} else {
+			r.running.Calendar = next.Calendar
+		}
+	}
+
+	if next.SMS != r.running.SMS {
+		if err := sms.Default.Configure(next.SMS); err != nil {
+			slog.Error("SMS settings not reloaded", "error", err)
+		} else {
+			r.running.SMS = next.SMS
+		}
+	}
+}
+
+// reloadCacheTTLs re-reads the CACHE_TTL_* overrides, which live outside the config struct
+func reloadCacheTTLs() []ConfigChange {
+	before := cache.TTLOverrides()
+	cache.LoadTTLConfig()
+	after := cache.TTLOverrides()
+
+	var changes []ConfigChange
+	for name, d := range after {
+		if old, ok := before[name]; !ok || old != d {
+			change := ConfigChange{Setting: name, New: d.String(), Applied: true}
+			if ok {
+				change.Old = old.String()
+			}
+			changes = append(changes, change)
+		}
+	}
+	for name, d := range before {
+		if _, ok := after[name]; !ok {
+			changes = append(changes, ConfigChange{Setting: name, Old: d.String(), Applied: true})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Setting < changes[j].Setting })
+	return changes
+}
+
+// reloadConfigHandler POST /api/admin/runtime/reload
+// Like SIGHUP, but audited and answering with what changed; per instance, as the files are.
+func reloadConfigHandler(reloader *ConfigReloader, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changes, err := reloader.Reload("api")
+		if err != nil {
+			logger(c).Error("Configuration reload failed", "error", err)
+			utils.RespondError(c, http.StatusUnprocessableEntity, "Configuration not reloaded: "+err.Error())
+			return
+		}
+
+		if len(changes) > 0 {
+			before, after := make(map[string]string, len(changes)), make(map[string]string, len(changes))
+			for _, change := range changes {
+				before[change.Setting], after[change.Setting] = change.Old, change.New
+			}
+			logAuditEntry(c, "UPDATE", "configuration", 0, before, after, db)
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: changes, Message: "Configuration reloaded"})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tabular"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentTypeCSV is offered by list endpoints to clients that accept it
+const contentTypeCSV = "text/csv"
+
+// headerStreamError is the trailer naming the failure that cut a CSV download short
+const headerStreamError = "X-Stream-Error"
+
+// wantsCSV reports whether the client of a list endpoint asked for CSV. Either way the
+// response varies with Accept.
+func wantsCSV(c *gin.Context) bool {
+	c.Writer.Header().Add("Vary", "Accept")
+	return response.Accepts(c.GetHeader("Accept"), contentTypeCSV)
+}
+
+// onCSV runs h, typically a limiter, only for requests that ask for CSV
+func onCSV(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if response.Accepts(c.GetHeader("Accept"), contentTypeCSV) {
+			h(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// rowWriter is a streaming response that rows are written to as they are read
+type rowWriter interface {
+	Write(v interface{}) error
+	Close(operation string, err error)
+}
+
+// csvStream writes rows as CSV records. A row is encoded as it is in the JSON list and
+// each column takes the member of that name (see tabular.Record), so columns match
+// ?fields= exactly.
+type csvStream struct {
+	c       *gin.Context
+	w       *csv.Writer
+	columns []string
+	name    string
+	status  int
+	count   int
+	started bool
+}
+
+// newCSVStream prepares a stream of columns, downloaded as name.csv; like a jsonStream it
+// sends the headers with the first row
+func newCSVStream(c *gin.Context, name string, columns []string) *csvStream {
+	return &csvStream{c: c, w: csv.NewWriter(c.Writer), columns: columns, name: name, status: http.StatusOK}
+}
+
+// begin writes the response headers and the header record
+func (s *csvStream) begin() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	s.c.Header("Content-Type", contentTypeCSV+"; charset=utf-8")
+	s.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, s.name))
+	s.c.Header("Cache-Control", "no-store")
+	s.c.Header("X-Content-Type-Options", "nosniff")
+	s.c.Status(s.status)
+	s.w.Write(s.columns)
+}
+
+// Write encodes one row
+func (s *csvStream) Write(v interface{}) error {
+	record, err := tabular.Record(s.columns, v)
+	if err != nil {
+		return err
+	}
+
+	s.begin()
+	if err := s.w.Write(record); err != nil {
+		return err
+	}
+	s.count++
+	if s.count%streamFlushEvery == 0 {
+		s.w.Flush()
+		s.c.Writer.Flush()
+		return s.w.Error()
+	}
+	return nil
+}
+
+// Close finishes the stream. Before the first row an error is reported as usual. After it
+// the status line has been sent, so the rows written so far are kept and the failure is
+// sent in the X-Stream-Error trailer, which a truncated download carries and a complete
+// one does not.
+func (s *csvStream) Close(operation string, err error) {
+	if err != nil && !s.started {
+		utils.HandleError(s.c, err, operation)
+		return
+	}
+	s.begin()
+	s.w.Flush()
+	if err != nil {
+		logger(s.c).Error("Stream interrupted", "operation", operation, "rows", s.count, "error", err)
+		s.c.Writer.Header().Set(http.TrailerPrefix+headerStreamError, operation+" interrupted")
+	}
+	s.c.Writer.Flush()
+}
+
+// csvColumns returns the columns of a CSV list: fields when given, else all of spec's
+func csvColumns(spec utils.ListSpec, fields []string) []string {
+	if len(fields) > 0 {
+		return fields
+	}
+	return spec.Fields
+}
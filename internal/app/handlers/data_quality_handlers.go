@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dataQualityReportHandler GET /api/admin/data-quality
+// Every check with the severity, count and time of its last run, and the findings newest
+// first; ?check= and ?severity= narrow the findings, ?before_id= pages back. The data_quality
+// job refreshes them; POST /api/admin/jobs/data_quality/run does so now.
+func dataQualityReportHandler(dataQuality services.DataQualityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var beforeID uint64
+		if v := c.Query("before_id"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			beforeID = n
+		}
+		report, err := dataQuality.Report(c.Request.Context(), c.Query("check"), c.Query("severity"),
+			beforeID, parseIntMinMax(c.Query("limit"), 100, 1, 1000))
+		if utils.HandleError(c, err, "get data quality report") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: report, Meta: response.Meta{"count": len(report.Findings)}})
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dbBackupJob is the scheduler job that makes the queued backups
+const dbBackupJob = "db_backup"
+
+// createBackupHandler POST /api/admin/backups
+// Queues a backup and starts the db_backup job to make it. Answers 202 with the backup, the
+// one already queued if there is one; 409 while one is running. GET /api/admin/backups/:id
+// follows it.
+func createBackupHandler(backups services.DBBackupService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		backup, err := backups.Create(c.Request.Context(), userID)
+		if utils.HandleError(c, err, "create backup") {
+			return
+		}
+		logAuditEntry(c, "CREATE", "db_backups", backup.ID, nil, backup, db)
+
+		// A run in progress takes the backup once it is done with the others
+		if err := jobs.RunNow(dbBackupJob); err != nil && !errors.Is(err, scheduler.ErrJobRunning) {
+			logger(c).Warn("Failed to start the backup job", "error", err)
+		}
+		response.Write(c, http.StatusAccepted, response.Body{Data: backup, Message: "Backup queued"})
+	}
+}
+
+// listBackupsHandler GET /api/admin/backups
+// The backups, newest first; ?before_id= pages back
+func listBackupsHandler(backups services.DBBackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var beforeID uint64
+		if v := c.Query("before_id"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			beforeID = n
+		}
+		list, err := backups.List(c.Request.Context(), beforeID, parseIntMinMax(c.Query("limit"), 50, 1, 500))
+		if utils.HandleError(c, err, "list backups") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getBackupHandler GET /api/admin/backups/:id
+// A backup's status, and how many tables and rows it dumped so far
+func getBackupHandler(backups services.DBBackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		backup, err := backups.Get(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get backup") {
+			return
+		}
+		response.OK(c, backup)
+	}
+}
+
+// downloadBackupHandler GET /api/admin/backups/:id/download
+// Streams the gzipped SQL of a succeeded backup; 409 until it succeeded, once it expired, or
+// when its file is gone from storage. Every download is audited and raises a security event.
+func downloadBackupHandler(backups services.DBBackupService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		backup, file, err := backups.Open(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "download backup") {
+			return
+		}
+		defer file.Close()
+		logAuditEntry(c, "API_ACCESS", "db_backups", backup.ID, nil, gin.H{"download": backup.Filename}, db)
+		raiseBackupDownload(c, backup)
+
+		c.DataFromReader(http.StatusOK, *backup.Size, "application/gzip", file, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": *backup.Filename}),
+			"Cache-Control":       "private, no-store",
+			"X-Checksum-SHA256":   *backup.Checksum,
+		})
+	}
+}
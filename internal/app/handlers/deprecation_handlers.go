@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"adminbe/internal/pkg/deprecation"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listDeprecationsHandler GET /api/admin/deprecations
+// Lists the deprecated routes with their sunset, successor and the callers still using them
+// since this process started, to tell when a route can be removed
+func listDeprecationsHandler(c *gin.Context) {
+	routes := deprecation.List()
+	response.Write(c, http.StatusOK, response.Body{Data: routes, Meta: response.Meta{"count": len(routes)}})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/broadcast"
+	"adminbe/internal/pkg/payloadlog"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventStreamRetry is how long EventSource clients wait before reconnecting, in milliseconds
+const eventStreamRetry = 3000
+
+// streamAuditEntry is an audit entry as the live stream carries it: the audit log's fields,
+// with the values of password, token and similar fields redacted
+type streamAuditEntry struct {
+	UserID    uint64          `json:"user_id"`
+	EventType string          `json:"event_type"`
+	TableName string          `json:"table_name"`
+	RecordID  uint64          `json:"record_id"`
+	OldValues json.RawMessage `json:"old_values"`
+	NewValues json.RawMessage `json:"new_values"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// broadcastAuditEntry pushes an audit entry to the live stream of every instance
+func broadcastAuditEntry(userID uint64, eventType, tableName string, recordID uint64, oldValues, newValues interface{}) {
+	broadcast.Default.Publish(broadcast.TypeAudit, streamAuditEntry{
+		UserID:    userID,
+		EventType: eventType,
+		TableName: tableName,
+		RecordID:  recordID,
+		OldValues: redactedJSON(oldValues),
+		NewValues: redactedJSON(newValues),
+		CreatedAt: time.Now(),
+	})
+}
+
+// redactedJSON encodes v with sensitive fields redacted; nil, and values that cannot be
+// encoded, become null
+func redactedJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	redacted, err := payloadlog.RedactJSON(data)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// parseStreamTypes reads ?types=audit,invalidate,security; all of them when empty
+func parseStreamTypes(s string) (map[string]bool, error) {
+	if s == "" {
+		return map[string]bool{broadcast.TypeAudit: true, broadcast.TypeInvalidate: true, broadcast.TypeSecurity: true}, nil
+	}
+	types := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t != broadcast.TypeAudit && t != broadcast.TypeInvalidate && t != broadcast.TypeSecurity {
+			return nil, utils.NewValidationError(fmt.Sprintf("Unknown event type %q; use %s, %s or %s",
+				t, broadcast.TypeAudit, broadcast.TypeInvalidate, broadcast.TypeSecurity))
+		}
+		types[t] = true
+	}
+	return types, nil
+}
+
+// eventStreamHandler GET /api/events/stream
+// Server-Sent Events: audit entries, entity invalidations and security events from every
+// instance as they happen, optionally only some types (?types=audit). A comment line is sent
+// every heartbeat to keep proxies from closing an idle stream, and the stream is ended after
+// maxDuration so clients reconnect with a current token (0 turns either off). A client too
+// slow to keep up is disconnected.
+func eventStreamHandler(hub *broadcast.Hub, buffer int, heartbeat, maxDuration time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		types, err := parseStreamTypes(c.Query("types"))
+		if utils.HandleError(c, err, "stream events") {
+			return
+		}
+
+		sub := hub.Subscribe(buffer)
+		defer sub.Close()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-store")
+		// Stop nginx from buffering the stream
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		fmt.Fprintf(c.Writer, "retry: %d\n\n", eventStreamRetry)
+		c.Writer.Flush()
+
+		// A nil channel never fires, which is how a 0 duration turns its case off
+		var heartbeats, deadline <-chan time.Time
+		if heartbeat > 0 {
+			ticker := time.NewTicker(heartbeat)
+			defer ticker.Stop()
+			heartbeats = ticker.C
+		}
+		if maxDuration > 0 {
+			timer := time.NewTimer(maxDuration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		ctx := c.Request.Context()
+		for {
+			var err error
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
+				return
+			case <-draining:
+				return
+			case <-heartbeats:
+				_, err = c.Writer.WriteString(": keep-alive\n\n")
+			case msg, ok := <-sub.C:
+				if !ok {
+					if sub.Lagging() {
+						logger(c).Warn("Event stream client fell behind, disconnecting", "buffer", buffer)
+					}
+					return
+				}
+				if !types[msg.Type] {
+					continue
+				}
+				_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", msg.Type, msg.Data)
+			}
+			if err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
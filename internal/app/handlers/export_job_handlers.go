@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportJobsJob is the scheduler job that runs the queued exports
+const exportJobsJob = "export_jobs"
+
+// auditLogExporter exports the audit trail, newest first, as GET /api/audit_logs/export
+// streams it. Params: from and to, dates or RFC 3339 times bounding created_at (to is
+// exclusive), and table, one table_name.
+func auditLogExporter(db *sql.DB) services.Exporter {
+	return services.Exporter{
+		Kind:        "audit_logs",
+		Description: "The audit trail, newest first, optionally from and to a time (from, to) and of one table (table)",
+		Columns:     auditLogListSpec.Fields,
+		Check: func(params map[string]string) error {
+			_, _, err := auditExportFilter(params)
+			return err
+		},
+		Export: func(ctx context.Context, params map[string]string, w services.ExportWriter) error {
+			where, args, err := auditExportFilter(params)
+			if err != nil {
+				return err
+			}
+			reader := database.Reader(database.WithReplica(ctx), db)
+			query := "SELECT COUNT(*) FROM audit_logs"
+			if where != "" {
+				query += " WHERE " + where
+			}
+			var total int64
+			if err := reader.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+				return database.TranslateError(err)
+			}
+			w.SetTotal(total)
+			return streamAuditLogs(ctx, reader, "created_at DESC, id DESC", w.Write, where, args...)
+		},
+	}
+}
+
+// auditExportFilter turns the params of an audit_logs export into a WHERE clause
+func auditExportFilter(params map[string]string) (string, []interface{}, error) {
+	where := ""
+	var args []interface{}
+	add := func(clause string, arg interface{}) {
+		if where != "" {
+			where += " AND "
+		}
+		where += clause
+		args = append(args, arg)
+	}
+	for _, bound := range []struct{ param, clause string }{{"from", "created_at >= ?"}, {"to", "created_at < ?"}} {
+		v, ok := params[bound.param]
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return "", nil, utils.NewValidationError("params." + bound.param + " must be a date (YYYY-MM-DD) or an RFC 3339 time")
+			}
+		}
+		add(bound.clause, t)
+	}
+	if table, ok := params["table"]; ok {
+		add("table_name = ?", table)
+	}
+	return where, args, nil
+}
+
+// createExportJobHandler POST /api/jobs
+// Queues an export of kind, in format csv (the default) or ndjson, and starts the
+// export_jobs job to run it. Answers 202 with the job; GET /api/jobs/:id follows it.
+func createExportJobHandler(exports services.ExportJobService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.CreateExportJobRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		// The audit trail is the whole instance's
+		if req.Kind == "audit_logs" && !tenant.IsDefault(c.Request.Context()) {
+			utils.HandleError(c, utils.NewForbiddenError("Audit logs are only exported from the default tenant"), "create export job")
+			return
+		}
+
+		job, err := exports.Create(c.Request.Context(), req, userID)
+		if utils.HandleError(c, err, "create export job") {
+			return
+		}
+		if job.Kind == "audit_logs" {
+			raiseAuditExport(c, job.Format)
+		}
+		logAuditEntry(c, "CREATE", "export_jobs", job.ID, nil, job, db)
+
+		// A run in progress claims the job once it is done with the others
+		if err := jobs.RunNow(exportJobsJob); err != nil && !errors.Is(err, scheduler.ErrJobRunning) {
+			logger(c).Warn("Failed to start the export jobs", "error", err)
+		}
+		response.Write(c, http.StatusAccepted, response.Body{Data: job, Message: "Export queued"})
+	}
+}
+
+// listExportJobsHandler GET /api/jobs
+// The caller's export jobs, newest first; administrators see everyone's, or one user's
+// with ?created_by=. ?before_id= pages back.
+func listExportJobsHandler(exports services.ExportJobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		filter := models.ExportJobFilter{Limit: parseIntMinMax(c.Query("limit"), 100, 1, 1000)}
+		for name, dest := range map[string]*uint64{"created_by": &filter.CreatedBy, "before_id": &filter.BeforeID} {
+			if v := c.Query(name); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					utils.RespondError(c, http.StatusBadRequest, "Invalid "+name)
+					return
+				}
+				*dest = n
+			}
+		}
+
+		list, err := exports.List(c.Request.Context(), filter, userID, isAdmin(c))
+		if utils.HandleError(c, err, "list export jobs") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getExportJobHandler GET /api/jobs/:id
+// The job's status and progress; once it succeeded, file carries a signed download URL
+func getExportJobHandler(exports services.ExportJobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		job, err := exports.Get(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "get export job") {
+			return
+		}
+		response.OK(c, job)
+	}
+}
+
+// downloadExportJobHandler GET /api/jobs/:id/download
+// Redirects to a signed download URL of the finished export; 409 until it succeeded, or
+// once its file expired or was deleted
+func downloadExportJobHandler(exports services.ExportJobService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		job, err := exports.Get(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "download export") {
+			return
+		}
+		if job.File == nil {
+			message := "The export is " + job.Status
+			if job.Status == models.ExportJobSucceeded {
+				message = "The export's file was deleted"
+			}
+			utils.RespondError(c, http.StatusConflict, message)
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+		c.Redirect(http.StatusFound, job.File.URL)
+	}
+}
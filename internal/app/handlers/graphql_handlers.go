@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"adminbe/internal/app/graph"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// GraphQLRequest is a GraphQL-over-HTTP request
+type GraphQLRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// graphqlHandler GET|POST /graphql
+// Runs a query against the graph schema. POST takes a JSON GraphQLRequest; GET takes query,
+// operationName and variables (JSON) as query parameters. Executed queries answer 200 with
+// the GraphQL response, errors included; malformed requests get the usual 400.
+func graphqlHandler(schema *graphql.Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req GraphQLRequest
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					utils.RespondError(c, http.StatusBadRequest, "variables must be a JSON object")
+					return
+				}
+			}
+			if req.Query == "" {
+				utils.RespondError(c, http.StatusBadRequest, "query is required")
+				return
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+
+		res := schema.Exec(graph.WithLoader(c.Request.Context()), req.Query, req.OperationName, req.Variables)
+		c.JSON(http.StatusOK, res)
+	}
+}
@@ -1,14 +1,40 @@
 package handlers
 
 import (
+	"adminbe/internal/app/graph"
 	"adminbe/internal/app/middleware"
-	"adminbe/internal/app/repositories"
-	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/alerting"
+	"adminbe/internal/pkg/broadcast"
+	"adminbe/internal/pkg/buildinfo"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/config"
 	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/deprecation"
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/maintenance"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/payloadlog"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/tenant"
 	"adminbe/internal/pkg/utils"
+	"adminbe/internal/pkg/validation"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -22,12 +48,66 @@ func handleServiceError(c *gin.Context, err error, operation string) bool {
 // bindJSONRequest binds JSON request and handles validation errors
 func bindJSONRequest(c *gin.Context, req interface{}) bool {
 	if err := c.ShouldBindJSON(req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return false
 	}
 	return true
 }
 
+// respondBindError answers a request that failed to bind: 400 VALIDATION_ERROR with a code
+// and message per rejected field in details.fields, or 400 BAD_REQUEST when the body could
+// not be read at all
+func respondBindError(c *gin.Context, err error) {
+	fields, ok := validation.Translate(err)
+	if !ok {
+		var syntaxErr *json.SyntaxError
+		switch {
+		case errors.Is(err, io.EOF):
+			utils.RespondError(c, http.StatusBadRequest, "Request body is required")
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			utils.RespondError(c, http.StatusBadRequest, "Request body is not valid JSON")
+		default:
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+	response.WriteError(c, http.StatusBadRequest, response.Failure{
+		Code:    response.CodeValidation,
+		Message: "Request validation failed",
+		Details: response.Meta{"fields": validation.Localize(c.Request.Context(), fields)},
+	})
+}
+
+// MIMEMergePatch is the media type of a JSON Merge Patch (RFC 7386) body
+const MIMEMergePatch = "application/merge-patch+json"
+
+// bindMergePatch decodes a JSON Merge Patch body into req, answering 415 for other media
+// types (application/json is accepted too) and 400 for anything but a JSON object of req's
+// members
+func bindMergePatch(c *gin.Context, req interface{}) bool {
+	if ct := c.ContentType(); ct != MIMEMergePatch && ct != "application/json" {
+		utils.RespondError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+MIMEMergePatch)
+		return false
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid merge patch: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// respondCreatedID answers 201 for a created record identified only by id. Version 1
+// clients got the id next to the message rather than under data.
+func respondCreatedID(c *gin.Context, message string, id any) {
+	response.Write(c, http.StatusCreated, response.Body{
+		Data:    gin.H{"id": id},
+		Message: message,
+		Legacy:  gin.H{"message": message, "id": id},
+	})
+}
+
 // getUserIDFromContext extracts user ID from Gin context
 func getUserIDFromContext(c *gin.Context) *uint64 {
 	userIDVal, exists := c.Get("user_id")
@@ -44,19 +124,37 @@ func getUserIDFromContext(c *gin.Context) *uint64 {
 
 // logAuditEntry creates an audit log entry (helper for consistency)
 func logAuditEntry(c *gin.Context, eventType, tableName string, recordID uint64, oldValues, newValues interface{}, db *sql.DB) {
-	if auditLogChan == nil {
+	userIDPtr := getUserIDFromContext(c)
+	if userIDPtr == nil {
+		logger(c).Warn("Cannot create audit log without user ID", "event", eventType, "table", tableName, "record_id", recordID)
 		return
 	}
 
-	userIDPtr := getUserIDFromContext(c)
-	if userIDPtr == nil {
-		log.Printf("Warning: cannot create audit log without user ID for %s %s %d", eventType, tableName, recordID)
+	if deferBatchAudit(c, auditLogEntry{
+		UserID:    *userIDPtr,
+		Event:     eventType,
+		Table:     tableName,
+		RecordID:  recordID,
+		OldValues: oldValues,
+		NewValues: newValues,
+		DB:        db,
+	}) {
 		return
 	}
+	if !EnqueueAuditLog(db, *userIDPtr, eventType, tableName, recordID, oldValues, newValues) {
+		logger(c).Warn("Audit log queue full, dropping entry", "event", eventType, "table", tableName, "record_id", recordID)
+	}
+}
+
+// EnqueueAuditLog hands an audit entry to the async audit workers started by StartAuditLogger,
+// for callers outside a request. It never blocks and reports false when the queue is full.
+func EnqueueAuditLog(db *sql.DB, userID uint64, eventType, tableName string, recordID uint64, oldValues, newValues interface{}) bool {
+	publishWebhookEvent(eventType, tableName, recordID)
+	broadcastAuditEntry(userID, eventType, tableName, recordID, oldValues, newValues)
 
 	select {
 	case auditLogChan <- auditLogEntry{
-		UserID:    *userIDPtr,
+		UserID:    userID,
 		Event:     eventType,
 		Table:     tableName,
 		RecordID:  recordID,
@@ -64,76 +162,412 @@ func logAuditEntry(c *gin.Context, eventType, tableName string, recordID uint64,
 		NewValues: newValues,
 		DB:        db,
 	}:
+		auditPipeline.recordEnqueued(1)
+		return true
 	default:
-		log.Printf("Warning: audit log queue full, dropping %s audit for %s %d", eventType, tableName, recordID)
+		auditPipeline.recordDropped()
+		auditDropped.Inc()
+		return false
 	}
 }
 
+// logger returns the request's logger, which tags every line with the request ID
+func logger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
 // isNotFoundError checks if error is a public not found error
 func isNotFoundError(err error) bool {
 	return utils.IsNotFound(err)
 }
 
-func SetupRoutes(r *gin.Engine, db *gorm.DB) {
-	sqlDB, _ := db.DB()
-
-	// Dependency injection setup
-	userRepo := repositories.NewUserRepository(sqlDB)
-	userService := services.NewUserService(userRepo)
-
-	menuRepo := repositories.NewMenuRepository(sqlDB)
-	menuService := services.NewMenuService(menuRepo)
+// Response cache TTLs for read-heavy routes
+const (
+	menuResponseExpiration   = 5 * time.Minute
+	prayerResponseExpiration = time.Hour
+)
 
-	roleRepo := repositories.NewRoleRepository(sqlDB)
-	roleService := services.NewRoleService(roleRepo)
+// SetupRoutes registers every route on r, serving them with svc (see NewServices) as cfg
+// configures
+func SetupRoutes(r *gin.Engine, db *gorm.DB, svc *Services, cfg *config.Config) {
+	sqlDB, _ := db.DB()
 
-	roleInheritanceRepo := repositories.NewRoleInheritanceRepository(sqlDB)
-	services.NewRoleInheritanceService(roleInheritanceRepo)
+	// Custom binding tags (username, phone_id) and json field names in validation errors
+	if err := validation.Register(); err != nil {
+		log.Fatalf("Failed to set up request validation: %v", err)
+	}
 
-	roleMenuRepo := repositories.NewRoleMenuRepository(sqlDB)
-	services.NewRoleMenuService(roleMenuRepo)
+	// Services shared with the gRPC server
+	txManager := svc.Tx
+	hasher := svc.Hasher
+	userService := svc.Users
+	menuService := svc.Menus
+	roleService := svc.Roles
+	roleInheritanceService := svc.RoleInheritances
+	roleMenuService := svc.RoleMenus
+	userMenuService := svc.UserMenus
+	userRoleService := svc.UserRoles
+	prayerService := svc.Prayer
+	reportService := svc.Reports
+	scimService := svc.SCIM
+	locationCodes := svc.LocationCodes
+	webhookService := svc.Webhooks
+	webhooks = svc.Webhooks
+	mailer = svc.Mail
+	securityEventService := svc.SecurityEvents
+	// Raised security events are stored, streamed and sent to webhooks
+	secevents.Default.SetHandler(recordSecurityEvent(securityEventService))
+
+	// JSON encoder for successful shalat responses, checked against encoding/json at startup
+	shalatJSON := loadShalatEncoder(cfg.API.ShalatJSONEncoder)
+
+	// Response format for requests without Accept-Version: 2 is the data/meta/error envelope,
+	// 1 the shapes from before it
+	if err := response.SetDefaultVersion(cfg.API.ResponseVersion); err != nil {
+		log.Fatalf("Invalid API_RESPONSE_VERSION: %v", err)
+	}
+	// Language of the messages of requests asking for none that is supported
+	if err := i18n.SetDefault(cfg.API.DefaultLanguage); err != nil {
+		log.Fatalf("Invalid DEFAULT_LANGUAGE: %v", err)
+	}
+	// Errors for clients accepting application/problem+json are typed PROBLEM_TYPE_BASE plus
+	// their code, or about:blank without it
+	response.SetProblemTypeBase(cfg.API.ProblemTypeBase)
 
-	userMenuRepo := repositories.NewUserMenuRepository(sqlDB)
-	services.NewUserMenuService(userMenuRepo)
+	// Known cache keys that operators can warm via /api/admin/cache/warm
+	cache.RegisterWarmer(cache.CacheKeyMenuList, cache.TTL("menu", cache.TTLList), func() (interface{}, error) {
+		return menuService.ListMenus(context.Background(), nil)
+	})
+	cache.RegisterWarmer(cache.CacheKeyMenuNavigation, cache.TTL("menu", cache.TTLNavigation), func() (interface{}, error) {
+		return queryMenuNavigation(context.Background(), sqlDB)
+	})
+	cache.RegisterWarmer(cache.CacheKeyProvinces, cache.TTL("prayer", cache.TTLReference), func() (interface{}, error) {
+		return prayerService.GetAllProvinces(context.Background())
+	})
+	cache.RegisterWarmer(cache.CacheKeyProvinceLocations, cache.TTL("prayer", cache.TTLReference), func() (interface{}, error) {
+		return prayerService.ListProvinces(context.Background())
+	})
 
-	userRoleRepo := repositories.NewUserRoleRepository(sqlDB)
-	services.NewUserRoleService(userRoleRepo)
+	// Recurring jobs, listed and run by hand under /api/admin/jobs
+	registerJobs(svc.Jobs, sqlDB, cfg.Jobs, svc.PrayerSubscriptions, svc.Push, svc.OTP, svc.Exports, svc.Backups, svc.DataQuality, svc.ReportSchedules, svc.Calendar)
+	svc.Jobs.OnFailure(alertJobFailure(alerting.Default))
+
+	// The apiv1 prayer routes are deprecated in favour of /api/v2/prayer; they keep working
+	// and announce it with Deprecation, Sunset (once API_V1_SUNSET is set) and Link headers
+	for path, successor := range map[string]string{
+		"/api/apiv1/getShalat":       "/api/v2/prayer/schedule",
+		"/api/apiv1/getApiProv":      "/api/v2/prayer/provinces",
+		"/api/apiv1/getApiKabko":     "/api/v2/prayer/cities",
+		"/api/apiv1/getApiSholatbln": "/api/v2/prayer/schedule",
+		"/api/apiv1/getApiSholatthn": "/api/v2/prayer/schedule",
+		"/api/apiv1/getApiimsakiyah": "/api/v2/prayer/imsakiyah",
+	} {
+		deprecation.Register(deprecation.Route{
+			Method:    http.MethodPost,
+			Path:      path,
+			Since:     cfg.API.V1DeprecatedAt,
+			Sunset:    cfg.API.V1Sunset,
+			Successor: successor,
+		})
+	}
 
-	prayerRepo := repositories.NewPrayerRepository(sqlDB)
-	prayerService := services.NewPrayerService(prayerRepo)
+	// OPTIONS, and methods a path does not have, are answered with the methods the router
+	// has for it in Allow; HEAD is served by wrapping the engine with middleware.ServeHead
+	r.HandleMethodNotAllowed = true
+	r.NoMethod(middleware.MethodNotAllowed())
 
 	// Global middleware
-	r.Use(middleware.CustomRecoveryMiddleware())
+	// RED metrics first, so every status a client receives is counted
+	r.Use(middleware.MetricsMiddleware())
+	// Request ID and request logger next, so recovered panics are logged and answered with it
+	r.Use(middleware.TracingMiddleware(cfg.API.QueryCountWarn))
 	r.Use(middleware.RequestLoggerMiddleware(sqlDB))
-	r.Use(middleware.SecurityHeadersMiddleware())
+	// Messages in the language the client asks for, Indonesian or English
+	r.Use(middleware.LanguageMiddleware())
+	// Deprecation headers and usage counts for the routes in the deprecation registry
+	r.Use(middleware.DeprecationMiddleware())
+	// Redacted request/response capture for incident debugging, off until the payload_log
+	// feature is switched on (PAYLOAD_LOG_ENABLED, or at runtime via /api/admin/runtime)
+	features.Register(features.PayloadLog, "Record redacted request and response bodies for /api/admin/payloads",
+		cfg.PayloadLog.Enabled)
+	payloadlog.Resize(cfg.PayloadLog.Size)
+	r.Use(middleware.PayloadLogMiddleware(cfg.PayloadLog.MaxBytes, cfg.PayloadLog.Routes, "/api/admin/payloads"))
+	r.Use(middleware.CustomRecoveryMiddleware())
+	r.Use(middleware.SecurityHeadersMiddleware(cfg.SecurityHeaders))
+	// Maintenance mode (/api/admin/maintenance): 503 for everyone but the allowed users, except
+	// on probes, metrics, profiling, sign-in (so allowed users can get a token), SMS delivery
+	// reports and the switch
+	r.Use(middleware.MaintenanceMiddleware(maintenance.Default, "/ping", "/health", "/metrics", "/status",
+		"/debug/pprof", "/api/auth/login", "/api/auth/2fa", "/api/sms/status", "/api/admin/maintenance"))
+	// Load shedding: at most MAX_CONCURRENT_REQUESTS run at once, a bounded queue waits for
+	// LIMIT_QUEUE_TIMEOUT and the rest get 429. Probes and metrics are never shed.
+	queueTimeout := cfg.Limits.QueueTimeout
+	globalLimiter := middleware.NewConcurrencyLimiter("global",
+		cfg.Limits.MaxConcurrentRequests, cfg.Limits.MaxQueuedRequests, queueTimeout)
+	// The event stream and /ws hold their connections open, so they have limits of their own instead
+	r.Use(globalLimiter.Middleware("/ping", "/health", "/health/live", "/health/ready", "/metrics", "/api/events/stream", "/ws"))
+
+	// Response-time budgets: tight for CRUD, longer for Jasper reports and streaming exports
+	exportTimeout := cfg.Timeouts.Export
+	r.Use(middleware.TimeoutMiddleware(middleware.TimeoutBudgets{
+		Default: cfg.Timeouts.Request,
+		Routes: map[string]time.Duration{
+			"/api/reports":                cfg.Timeouts.Report,
+			"/api/users/export":           exportTimeout,
+			"/api/users/import":           exportTimeout,
+			"/api/admin/locations/import": exportTimeout,
+			"/api/admin/rbac/import":      exportTimeout,
+			"/api/audit_logs/export":      exportTimeout,
+			"/api/batch":                  cfg.Timeouts.Batch,
+			// Backup downloads stream a file as large as the database
+			"/api/admin/backups/:id/download": exportTimeout,
+			// CPU profiles and execution traces run for ?seconds= (30 by default)
+			"/debug/pprof": cfg.Timeouts.Pprof,
+			// Streams end on their own after EVENT_STREAM_MAX_DURATION, WebSockets when the token expires
+			"/api/events/stream": 0,
+			"/ws":                0,
+		},
+		// CSV lists stream the whole table, like the exports
+		Media: map[string]time.Duration{"text/csv": exportTimeout},
+	}))
+
+	// Request bodies: 413 past MAX_BODY_BYTES (BATCH_MAX_BODY_BYTES for batches) and 415 for
+	// media types the route cannot parse. An empty media list accepts any.
+	r.Use(middleware.BodyLimitMiddleware(middleware.BodyLimits{
+		MaxBytes: cfg.API.MaxBodyBytes,
+		Routes: map[string]int64{
+			"/api/batch":            cfg.API.BatchMaxBodyBytes,
+			"/api/audit_logs/batch": cfg.API.BatchMaxBodyBytes,
+			// Uploads, with room for the multipart framing around the file
+			"/api/files":                  cfg.Storage.MaxUploadBytes + multipartOverhead,
+			"/api/users/:id/avatar":       cfg.Storage.MaxUploadBytes + multipartOverhead,
+			"/api/users/import":           cfg.API.ImportMaxBytes + multipartOverhead,
+			"/api/admin/locations/import": cfg.API.ImportMaxBytes + multipartOverhead,
+			// RBAC bundles, JSON but as large as an imported file
+			"/api/admin/rbac/import": cfg.API.ImportMaxBytes,
+		},
+		Media: []string{"application/json", MIMEMergePatch},
+		RouteMedia: map[string][]string{
+			"/scim/v2": {"application/scim+json", "application/json"},
+			// The legacy shalat routes take form posts as well as JSON
+			"/api/apiv1":                  {"application/json", "application/x-www-form-urlencoded", "multipart/form-data"},
+			"/api/files":                  {"multipart/form-data"},
+			"/api/users/:id/avatar":       {"multipart/form-data"},
+			"/api/users/import":           {"multipart/form-data"},
+			"/api/admin/locations/import": {"multipart/form-data"},
+			"/debug/pprof":                nil,
+		},
+	}))
+
+	// Bursts of 401s from one address raise a security event (SECURITY_AUTH_FAILURE_*)
+	r.Use(middleware.SecurityMonitorMiddleware(secevents.Default))
+
+	// The tenant a request is for, named by TENANT_HEADER or the subdomain of
+	// TENANT_BASE_DOMAIN; everything is the default tenant's unless TENANCY_ENABLED
+	tenant.SetEnabled(cfg.Tenancy.Enabled)
+	r.Use(middleware.TenantMiddleware(cfg.Tenancy, svc.Tenants.Resolve, svc.Tenants.Settings))
+
+	// Tighter limits for groups whose work is expensive per request
+	reportLimiter := middleware.NewConcurrencyLimiter("reports", cfg.Limits.ReportMaxConcurrent, 16, queueTimeout)
+	exportLimiter := middleware.NewConcurrencyLimiter("exports", cfg.Limits.ExportMaxConcurrent, 0, queueTimeout)
+	svc.Config.global, svc.Config.reports, svc.Config.exports = globalLimiter, reportLimiter, exportLimiter
 
 	r.GET("/ping", pingHandler)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 	r.GET("/health", func(c *gin.Context) { healthHandler(c, db) })
+	// Kubernetes probes: liveness checks nothing external, readiness pings every dependency.
+	// READINESS_REQUIRED lists the dependencies that must be up for the pod to take traffic.
+	required := cfg.Health.ReadinessRequired
+	readiness := newReadinessChecker(sqlDB, required, cfg.Health.CheckTimeout, cfg.Health.CheckInterval)
+	r.GET("/health/live", liveHandler)
+	r.GET("/health/ready", readyHandler(readiness))
+	// Public status page summary, with its own checker so frequent polling reuses one report
+	// for STATUS_CHECK_INTERVAL. DEPLOYED_AT (RFC 3339) is set by the deploy pipeline.
+	statusChecker := newReadinessChecker(sqlDB, required, cfg.Health.CheckTimeout, cfg.Health.StatusCheckInterval)
+	deployedAt := cfg.Server.DeployedAt
+	if deployedAt.IsZero() {
+		deployedAt = buildinfo.StartedAt()
+	}
+	r.GET("/status", statusHandler(statusChecker, deployedAt))
+	// How the admin panel presents the application, for the tenant the request names
+	r.GET("/api/branding", brandingHandler(cfg.Branding))
+	// API specification (see APISpec) and Swagger UI browsing it
+	r.GET("/openapi.json", openAPIHandler)
+	r.GET("/docs", swaggerUIHandler(cfg.Server.SwaggerUIAssets))
+	// Live notifications for the signed-in user (role granted, report finished, account locked)
+	wsLimiter := middleware.NewConcurrencyLimiter("ws", cfg.WebSocket.MaxClients, 0, queueTimeout)
+	svc.Config.ws = wsLimiter
+	r.GET("/ws", wsLimiter.Middleware(), wsHandler(notify.Default, wsConfig{
+		Buffer:       cfg.WebSocket.Buffer,
+		PingInterval: cfg.WebSocket.PingInterval,
+		AuthTimeout:  cfg.WebSocket.AuthTimeout,
+	}))
+
+	// Profiling for operators. PPROF_ENABLED=false starts with the pprof feature off; it can
+	// be switched on at runtime through /api/admin/runtime.
+	features.Register(features.Pprof, "Serve runtime profiles on /debug/pprof (ops role)",
+		cfg.Server.PprofEnabled)
+	pprofGroup := r.Group("/debug/pprof")
+	pprofGroup.Use(middleware.AuthMiddleware(), middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleOps))
+	{
+		pprofGroup.GET("/*name", pprofHandler)
+		pprofGroup.POST("/*name", pprofHandler)
+	}
+
+	// SCIM 2.0 provisioning for identity providers, authenticated with the shared SCIM_TOKEN
+	// rather than user tokens; without one SCIM is off
+	scimToken := cfg.API.SCIMToken
+	if scimToken == "" {
+		slog.Info("SCIM_TOKEN is not set, SCIM provisioning is disabled")
+	}
+	scimGroup := r.Group("/scim/v2")
+	scimGroup.Use(middleware.RequireDefaultTenant(), scimAuth(scimToken))
+	{
+		scimGroup.GET("/ServiceProviderConfig", scimServiceProviderConfigHandler)
+		scimGroup.GET("/Users", scimListUsersHandler(scimService))
+		scimGroup.GET("/Users/:id", scimGetUserHandler(scimService))
+		scimGroup.POST("/Users", scimCreateUserHandler(scimService, sqlDB))
+		scimGroup.PUT("/Users/:id", scimReplaceUserHandler(scimService, sqlDB))
+		scimGroup.PATCH("/Users/:id", scimPatchUserHandler(scimService, sqlDB))
+		scimGroup.DELETE("/Users/:id", scimDeleteUserHandler(scimService, sqlDB))
+		scimGroup.GET("/Groups", scimListGroupsHandler(scimService))
+		scimGroup.GET("/Groups/:id", scimGetGroupHandler(scimService))
+		scimGroup.POST("/Groups", scimCreateGroupHandler(scimService, sqlDB))
+		scimGroup.PUT("/Groups/:id", scimReplaceGroupHandler(scimService, sqlDB))
+		scimGroup.PATCH("/Groups/:id", scimPatchGroupHandler(scimService, sqlDB))
+		scimGroup.DELETE("/Groups/:id", scimDeleteGroupHandler(scimService, sqlDB))
+	}
 
-	// Auth routes (public)
+	// Per-client budgets (RATE_LIMIT_*), in buckets every instance shares through Redis: sign-in
+	// per address, the shalat lookups and the rest of the API per user
+	loginRate := middleware.NewRateLimitPolicy("login", cfg.RateLimit.Login, middleware.ByClientIP)
+	prayerRate := middleware.NewRateLimitPolicy("prayer", cfg.RateLimit.Prayer, middleware.ByUser)
+	apiRate := middleware.NewRateLimitPolicy("api", cfg.RateLimit.API, middleware.ByUser)
+	svc.Config.loginRate, svc.Config.prayerRate, svc.Config.apiRate = loginRate, prayerRate, apiRate
+	// Lower thresholds (CHALLENGE_*_AFTER) past which a client must answer a proof-of-work or
+	// reCAPTCHA challenge, before it reaches the limits above
+	loginChallenge := middleware.NewRateLimitPolicy("login_challenge", cfg.Challenge.LoginAfter, middleware.ByClientIP)
+	prayerChallenge := middleware.NewRateLimitPolicy("prayer_challenge", cfg.Challenge.PrayerAfter, middleware.ByUser)
+	svc.Config.loginChallenge, svc.Config.prayerChallenge = loginChallenge, prayerChallenge
+
+	// Auth routes (public). Browsers may keep the token in an HttpOnly cookie instead
+	// (COOKIE_AUTH_ENABLED); AuthMiddleware then wants the CSRF token on unsafe requests.
+	middleware.SetCookieAuth(cfg.CookieAuth)
+	// IP allow and deny lists (IP_ALLOWLIST, IP_DENYLIST, or /api/admin/ip_filter) guard the
+	// administration API; the public shalat lookups stay open to every address
+	ipFilter := middleware.IPFilterMiddleware(ipfilter.Default, "/api/apiv1", "/api/v2/prayer")
 	authGroup := r.Group("/api/auth")
+	authGroup.Use(ipFilter)
+	{
+		authGroup.POST("/login", loginRate.Middleware(ratelimit.Default), loginChallenge.ChallengeMiddleware(ratelimit.Default, challenge.Default),
+			loginHandler(db, hasher, userService, svc.OTP, cfg.JWT.Expiration))
+		// The second step of two-factor sign-in, and password resets by a texted code; the
+		// codes are also limited per phone number (SMS_RATE_PER_NUMBER)
+		authGroup.POST("/2fa", loginRate.Middleware(ratelimit.Default), twoFactorLoginHandler(db, hasher, userService, svc.OTP, cfg.JWT.Expiration))
+		authGroup.POST("/password/forgot", loginRate.Middleware(ratelimit.Default), loginChallenge.ChallengeMiddleware(ratelimit.Default, challenge.Default),
+			forgotPasswordHandler(svc.OTP))
+		authGroup.POST("/password/reset", loginRate.Middleware(ratelimit.Default), resetPasswordHandler(svc.OTP, sqlDB))
+		authGroup.GET("/csrf", middleware.AuthMiddleware(), csrfTokenHandler)
+		authGroup.POST("/logout", middleware.AuthMiddleware(), logoutHandler)
+	}
+
+	// Idempotency-Key support for POSTs a client may retry after a timeout; responses are
+	// replayed for IDEMPOTENCY_TTL
+	idempotencyTTL := cfg.API.IdempotencyTTL
+
+	// GraphQL over the same services, for nested reads in one request (see graph.NewSchema)
+	graphSchema, err := graph.NewSchema(graph.Services{
+		Users:            userService,
+		Roles:            roleService,
+		Menus:            menuService,
+		RoleInheritances: roleInheritanceService,
+		UserRoles:        userRoleService,
+		RoleMenus:        roleMenuService,
+		UserMenus:        userMenuService,
+		DB:               sqlDB,
+	}, graph.Limits{
+		MaxDepth:  cfg.GraphQL.MaxDepth,
+		MaxLength: cfg.GraphQL.MaxQueryBytes,
+	})
+	if err != nil {
+		log.Fatalf("Invalid GraphQL schema: %v", err)
+	}
+	graphqlGroup := r.Group("/graphql")
+	graphqlGroup.Use(ipFilter, middleware.AuthMiddleware(), apiRate.Middleware(ratelimit.Default))
 	{
-		authGroup.POST("/login", loginHandler(db))
+		graphqlGroup.GET("", graphqlHandler(graphSchema))
+		graphqlGroup.POST("", graphqlHandler(graphSchema))
 	}
 
+	// Downloads of uploaded files, outside the authenticated API: the signed URL is the
+	// authorization, so JasperServer and image tags can fetch them
+	r.GET("/api/files/:id/content", downloadFileHandler(svc.Attachments))
+	// Google's redirect after an administrator connects the calendar report schedules are
+	// published to; the single-use state from /api/admin/calendar/authorize is the authorization
+	r.GET("/api/calendar/oauth/callback", calendarCallbackHandler(svc.Calendar, svc.Jobs, sqlDB))
+	// SMS delivery reports, authenticated by the provider's signature or SMS_CALLBACK_SECRET
+	r.POST("/api/sms/status/:driver", smsStatusHandler(sms.Default, svc.OTP, sqlDB))
+	// Audit entries from sibling services, authenticated by their AUDIT_INGEST_KEYS key rather
+	// than a user token
+	ingestServices, _ := cfg.API.AuditIngestServices()
+	r.POST("/api/audit_logs/batch", auditIngestAuth(ingestServices), ingestAuditLogsHandler(cfg.API.AuditIngestMaxEntries, sqlDB))
+
 	// Protected API routes
 	apiGroup := r.Group("/api")
-	apiGroup.Use(middleware.AuthMiddleware())
+	apiGroup.Use(ipFilter, middleware.AuthMiddleware(), apiRate.Middleware(ratelimit.Default, "/api/apiv1", "/api/v2/prayer"))
 	{
 		// User CRUD
 		userGroup := apiGroup.Group("/users")
 		{
-			userGroup.GET("", listUsersHandler(userService))
+			userGroup.GET("", onCSV(exportLimiter.Middleware()), listUsersHandler(userService))
+			userGroup.GET("/export", exportLimiter.Middleware(), exportUsersHandler(userService))
+			// Bulk creation from a spreadsheet, validated row by row and written all or nothing
+			userGroup.POST("/import", middleware.RequireRoles(middleware.RoleAdmin), exportLimiter.Middleware(),
+				importUsersHandler(svc.UserImport, sqlDB, cfg.API.ImportMaxBytes, cfg.API.ImportMaxRows))
 			userGroup.GET("/:id", getUserHandler(userService))
-			userGroup.POST("", createUserHandler(userService, sqlDB))
+			userGroup.POST("", middleware.IdempotencyMiddleware(database.Cache, "users", idempotencyTTL), createUserHandler(userService, sqlDB))
 			userGroup.PUT("/:id", updateUserHandler(userService, sqlDB))
+			userGroup.PATCH("/:id", patchUserHandler(userService, sqlDB))
 			userGroup.DELETE("/:id", deleteUserHandler(userService, sqlDB))
+			// Soft delete and restore many at once, in one transaction
+			userGroup.POST("/bulk-delete", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkDeleteHandler("users", "users", userService.DeleteUsers, cfg.API.BulkMaxIDs, sqlDB))
+			userGroup.POST("/bulk-restore", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkRestoreHandler("users", "users", userService.RestoreUsers, cfg.API.BulkMaxIDs, sqlDB))
+			// Expire the passwords of users by role or inactivity, e.g. after a breach
+			userGroup.POST("/force-password-reset", middleware.RequireRoles(middleware.RoleAdmin), forcePasswordResetHandler(userService, sqlDB))
+			// Avatars: anyone signed in may see them, users change their own
+			userGroup.PUT("/:id/avatar", setAvatarHandler(svc.Attachments, sqlDB))
+			userGroup.GET("/:id/avatar", getAvatarHandler(svc.Attachments))
+			userGroup.DELETE("/:id/avatar", deleteAvatarHandler(svc.Attachments, sqlDB))
+			// For users who lost the phone their sign-in codes go to
+			userGroup.DELETE("/:id/2fa", middleware.RequireRoles(middleware.RoleAdmin), resetTwoFactorHandler(svc.OTP, sqlDB))
+		}
+
+		// Uploaded files, such as the files reports take as "attachment:<id>" parameters
+		filesGroup := apiGroup.Group("/files")
+		{
+			filesGroup.POST("", uploadFileHandler(svc.Attachments, sqlDB))
+			filesGroup.GET("", listFilesHandler(svc.Attachments))
+			filesGroup.GET("/:id", getFileHandler(svc.Attachments))
+			filesGroup.DELETE("/:id", deleteFileHandler(svc.Attachments, sqlDB))
+		}
+
+		// Exports run in the background: queued here, followed by ID, downloaded once done
+		exportGroup := apiGroup.Group("/jobs")
+		{
+			exportGroup.POST("", createExportJobHandler(svc.Exports, svc.Jobs, sqlDB))
+			exportGroup.GET("", listExportJobsHandler(svc.Exports))
+			exportGroup.GET("/:id", getExportJobHandler(svc.Exports))
+			exportGroup.GET("/:id/download", downloadExportJobHandler(svc.Exports))
 		}
 
 		// Audit Logs CRUD
 		auditGroup := apiGroup.Group("/audit_logs")
+		auditGroup.Use(middleware.RequireDefaultTenant())
 		{
-			auditGroup.GET("", listAuditLogsHandler(sqlDB))
+			auditGroup.GET("", onCSV(exportLimiter.Middleware()), listAuditLogsHandler(sqlDB))
+			auditGroup.GET("/export", exportLimiter.Middleware(), exportAuditLogsHandler(sqlDB))
 			auditGroup.GET("/:id", getAuditLogHandler(sqlDB))
 			auditGroup.POST("", createAuditLogHandler(sqlDB))
 			auditGroup.PUT("/:id", updateAuditLogHandler(sqlDB))
@@ -142,12 +576,19 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 
 		// Menu CRUD
 		menuGroup := apiGroup.Group("/menu")
+		menuResponseCache := middleware.ResponseCacheMiddleware(database.Cache, "menu", menuResponseExpiration)
 		{
-			menuGroup.GET("", listMenuHandler(menuService))
-			menuGroup.GET("/:id", getMenuHandler(menuService))
+			menuGroup.GET("", menuResponseCache, listMenuHandler(menuService))
+			menuGroup.GET("/:id", menuResponseCache, getMenuHandler(menuService))
 			menuGroup.POST("", createMenuHandler(menuService, sqlDB))
 			menuGroup.PUT("/:id", updateMenuHandler(menuService, sqlDB))
+			menuGroup.PATCH("/:id", patchMenuHandler(menuService, sqlDB))
 			menuGroup.DELETE("/:id", deleteMenuHandler(menuService, sqlDB))
+			menuGroup.POST("/bulk-delete", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkDeleteHandler("menu", "menu items", menuService.DeleteMenus, cfg.API.BulkMaxIDs, sqlDB))
+			menuGroup.POST("/bulk-restore", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkRestoreHandler("menu", "menu items", menuService.RestoreMenus, cfg.API.BulkMaxIDs, sqlDB))
+			menuGroup.PUT("/bulk", middleware.RequireRoles(middleware.RoleAdmin), applyMenuTreeHandler(menuService, sqlDB))
 		}
 
 		// Roles CRUD
@@ -157,7 +598,12 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 			rolesGroup.GET("/:id", getRoleHandler(roleService))
 			rolesGroup.POST("", createRoleHandler(roleService, sqlDB))
 			rolesGroup.PUT("/:id", updateRoleHandler(sqlDB))
+			rolesGroup.PATCH("/:id", patchRoleHandler(roleService, sqlDB))
 			rolesGroup.DELETE("/:id", deleteRoleHandler(sqlDB))
+			rolesGroup.POST("/bulk-delete", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkDeleteHandler("roles", "roles", roleService.DeleteRoles, cfg.API.BulkMaxIDs, sqlDB))
+			rolesGroup.POST("/bulk-restore", middleware.RequireRoles(middleware.RoleAdmin),
+				bulkRestoreHandler("roles", "roles", roleService.RestoreRoles, cfg.API.BulkMaxIDs, sqlDB))
 		}
 
 		// Role Inheritances CRUD
@@ -189,7 +635,7 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		// Menu Navigation (view for menu tree)
 		menuNavigationGroup := apiGroup.Group("/menu_navigation")
 		{
-			menuNavigationGroup.GET("", listMenuNavigationHandler(sqlDB))
+			menuNavigationGroup.GET("", middleware.ResponseCacheMiddleware(database.Cache, "menu", menuResponseExpiration), listMenuNavigationHandler(sqlDB))
 		}
 
 		// User Menu CRUD
@@ -207,27 +653,211 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			userRolesGroup.GET("", listUserRolesHandler(sqlDB))
 			userRolesGroup.GET("/:userId/:roleId", getUserRoleHandler(sqlDB))
-			userRolesGroup.POST("", createUserRoleHandler(sqlDB))
+			userRolesGroup.POST("", createUserRoleHandler(userRoleService, sqlDB))
 			userRolesGroup.PUT("/:userId/:roleId", updateUserRoleHandler(sqlDB))
 			userRolesGroup.DELETE("/:userId/:roleId", deleteUserRoleHandler(sqlDB))
 		}
 
 		// Reports group
 		reportsGroup := apiGroup.Group("/reports")
+		reportsGroup.Use(reportLimiter.Middleware())
 		{
-			reportsGroup.POST("/run", runReportHandler)
+			reportsGroup.POST("/run", middleware.IdempotencyMiddleware(database.Cache, "reports", idempotencyTTL), runReportHandler(reportService, sqlDB))
 			reportsGroup.GET("/server-info", getServerInfoHandler)
 			reportsGroup.GET("/health", jasperHealthHandler)
 		}
 
+		// Reports rendered on a schedule, for their owner. Outside reportsGroup: its limiter
+		// bounds the renders, and these calls render nothing.
+		reportSchedulesGroup := apiGroup.Group("/reports/schedules")
+		{
+			reportSchedulesGroup.POST("", createReportScheduleHandler(svc.ReportSchedules, svc.Jobs, sqlDB))
+			reportSchedulesGroup.GET("", listReportSchedulesHandler(svc.ReportSchedules))
+			reportSchedulesGroup.GET("/:id", getReportScheduleHandler(svc.ReportSchedules))
+			reportSchedulesGroup.PUT("/:id", updateReportScheduleHandler(svc.ReportSchedules, svc.Jobs, sqlDB))
+			reportSchedulesGroup.DELETE("/:id", deleteReportScheduleHandler(svc.ReportSchedules, svc.Jobs, sqlDB))
+		}
+
+		// Several sub-requests in one call, atomically when all their writes can share a
+		// transaction; BATCH_MAX_REQUESTS bounds the batch
+		apiGroup.POST("/batch", batchHandler(r, txManager, cfg.API.BatchMaxRequests))
+
+		// Outbound webhooks: signed notifications of user, role and menu changes
+		webhookGroup := apiGroup.Group("/webhooks")
+		webhookGroup.Use(middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleAdmin))
+		{
+			webhookGroup.GET("", listWebhooksHandler(webhookService))
+			webhookGroup.POST("", createWebhookHandler(webhookService, sqlDB))
+			webhookGroup.GET("/:id", getWebhookHandler(webhookService))
+			webhookGroup.PUT("/:id", updateWebhookHandler(webhookService, sqlDB))
+			webhookGroup.DELETE("/:id", deleteWebhookHandler(webhookService, sqlDB))
+			webhookGroup.GET("/:id/deliveries", listWebhookDeliveriesHandler(webhookService))
+		}
+
+		// Banners operators broadcast to admin panel users, read under /api/me/announcements
+		announcementGroup := apiGroup.Group("/announcements")
+		announcementGroup.Use(middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleAdmin))
+		{
+			announcementGroup.GET("", listAnnouncementsHandler(svc.Announcements))
+			announcementGroup.POST("", createAnnouncementHandler(svc.Announcements, sqlDB))
+			announcementGroup.GET("/:id", getAnnouncementHandler(svc.Announcements))
+			announcementGroup.PUT("/:id", updateAnnouncementHandler(svc.Announcements, sqlDB))
+			announcementGroup.DELETE("/:id", deleteAnnouncementHandler(svc.Announcements, sqlDB))
+		}
+
+		// The signed-in user's notification center, which /ws pushes live, their banners, and
+		// the phone number their one-time codes go to
+		meGroup := apiGroup.Group("/me")
+		{
+			meGroup.GET("/notifications", listNotificationsHandler(svc.Notifications))
+			meGroup.POST("/notifications/read", markNotificationsReadHandler(svc.Notifications))
+			meGroup.POST("/notifications/:id/read", markNotificationReadHandler(svc.Notifications))
+			meGroup.GET("/announcements", myAnnouncementsHandler(svc.Announcements))
+			meGroup.GET("/phone", getMyPhoneHandler(svc.OTP))
+			meGroup.PUT("/phone", setMyPhoneHandler(svc.OTP, sqlDB))
+			meGroup.POST("/phone/verify", verifyMyPhoneHandler(svc.OTP, sqlDB))
+			meGroup.PUT("/2fa", setMyTwoFactorHandler(svc.OTP, sqlDB))
+		}
+
+		// Live feed of audit entries and entity invalidations, across instances, for admin UIs
+		eventStreamLimiter := middleware.NewConcurrencyLimiter("event_stream", cfg.EventStream.MaxClients, 0, queueTimeout)
+		svc.Config.eventStream = eventStreamLimiter
+		apiGroup.GET("/events/stream", middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleAdmin), eventStreamLimiter.Middleware(),
+			eventStreamHandler(broadcast.Default, cfg.EventStream.Buffer, cfg.EventStream.Heartbeat, cfg.EventStream.MaxDuration))
+
+		// Admin operations; they are the whole instance's, so only the default tenant's
+		// administrators run them
+		adminGroup := apiGroup.Group("/admin")
+		adminGroup.Use(middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleAdmin))
+		{
+			cacheGroup := adminGroup.Group("/cache")
+			cacheGroup.GET("/keys", listCacheKeysHandler(database.Cache))
+			cacheGroup.GET("/key", getCacheKeyHandler(database.Cache))
+			cacheGroup.DELETE("/namespaces/:namespace", flushCacheNamespaceHandler(database.Cache))
+			cacheGroup.GET("/warm", listCacheWarmersHandler)
+			cacheGroup.POST("/warm", warmCacheHandler(database.Cache))
+
+			adminGroup.GET("/traces", listTracesHandler)
+			adminGroup.GET("/audit-pipeline", auditPipelineHandler)
+			adminGroup.GET("/deprecations", listDeprecationsHandler)
+			adminGroup.GET("/payloads", listPayloadsHandler)
+			adminGroup.DELETE("/payloads", clearPayloadsHandler)
+			adminGroup.GET("/maintenance", getMaintenanceHandler(maintenance.Default))
+			adminGroup.PUT("/maintenance", updateMaintenanceHandler(maintenance.Default, sqlDB))
+			adminGroup.GET("/ip_filter", getIPFilterHandler(ipfilter.Default))
+			adminGroup.PUT("/ip_filter", updateIPFilterHandler(ipfilter.Default, sqlDB))
+			adminGroup.DELETE("/ip_filter", resetIPFilterHandler(ipfilter.Default, sqlDB))
+			adminGroup.GET("/security_events", listSecurityEventsHandler(securityEventService))
+			adminGroup.GET("/security_events/:id", getSecurityEventHandler(securityEventService))
+			adminGroup.POST("/security_events/:id/acknowledge", acknowledgeSecurityEventHandler(securityEventService, sqlDB))
+			adminGroup.GET("/mail/deliveries", listMailDeliveriesHandler(svc.Mail))
+			adminGroup.GET("/sms/messages", listSMSMessagesHandler(svc.OTP))
+			// Chats sent prayer times by the Telegram and WhatsApp bots
+			adminGroup.GET("/prayer_subscriptions", listPrayerSubscriptionsHandler(svc.PrayerSubscriptions))
+			adminGroup.POST("/prayer_subscriptions", createPrayerSubscriptionHandler(svc.PrayerSubscriptions, sqlDB))
+			adminGroup.GET("/prayer_subscriptions/:id", getPrayerSubscriptionHandler(svc.PrayerSubscriptions))
+			adminGroup.PUT("/prayer_subscriptions/:id", updatePrayerSubscriptionHandler(svc.PrayerSubscriptions, sqlDB))
+			adminGroup.DELETE("/prayer_subscriptions/:id", deletePrayerSubscriptionHandler(svc.PrayerSubscriptions, sqlDB))
+			adminGroup.POST("/prayer_subscriptions/:id/test", testPrayerSubscriptionHandler(svc.PrayerSubscriptions))
+			// Mobile devices sent prayer reminders and announcements over FCM
+			adminGroup.GET("/push/devices", listPushDevicesHandler(svc.Push, locationCodes))
+			adminGroup.POST("/push/announcements", sendPushAnnouncementHandler(svc.Push, sqlDB))
+			// Cities prayer times are computed for, with coordinates from the geocoder
+			adminGroup.GET("/locations/geocode", geocodeHandler(svc.Locations))
+			adminGroup.POST("/locations/cities", createCityLocationHandler(svc.Locations, sqlDB))
+			adminGroup.GET("/locations/cities/:id", getCityLocationHandler(svc.Locations))
+			adminGroup.PUT("/locations/cities/:id", updateCityLocationHandler(svc.Locations, sqlDB))
+			// Kemendagri region codes and coordinates in bulk, previewed, journaled and undoable
+			adminGroup.POST("/locations/import", exportLimiter.Middleware(),
+				importLocationsHandler(svc.LocationImports, sqlDB, cfg.API.ImportMaxBytes, cfg.API.ImportMaxRows))
+			adminGroup.GET("/locations/imports", listLocationImportsHandler(svc.LocationImports))
+			adminGroup.GET("/locations/imports/:id", getLocationImportHandler(svc.LocationImports))
+			adminGroup.POST("/locations/imports/:id/rollback", rollbackLocationImportHandler(svc.LocationImports, sqlDB))
+			// Findings of the data_quality job
+			adminGroup.GET("/data-quality", dataQualityReportHandler(svc.DataQuality))
+			// The calendar the next runs of report schedules are published to
+			adminGroup.GET("/calendar", calendarStatusHandler(svc.Calendar))
+			adminGroup.GET("/calendar/authorize", authorizeCalendarHandler(svc.Calendar))
+			adminGroup.DELETE("/calendar/credentials", disconnectCalendarHandler(svc.Calendar, sqlDB))
+			adminGroup.POST("/calendar/sync", syncCalendarHandler(svc.Jobs))
+			adminGroup.GET("/jobs", listJobsHandler(svc.Jobs))
+			adminGroup.GET("/jobs/:name/runs", listJobRunsHandler(svc.Jobs))
+			adminGroup.POST("/jobs/:name/run", runJobHandler(svc.Jobs, sqlDB))
+			// The organizations this deployment serves (TENANCY_ENABLED)
+			adminGroup.GET("/tenants", listTenantsHandler(svc.Tenants))
+			adminGroup.POST("/tenants", createTenantHandler(svc.Tenants, sqlDB))
+			adminGroup.GET("/tenants/:id", getTenantHandler(svc.Tenants))
+			adminGroup.PUT("/tenants/:id", updateTenantHandler(svc.Tenants, sqlDB))
+			adminGroup.GET("/tenants/:id/settings", getTenantSettingsHandler(svc.Tenants))
+			adminGroup.PUT("/tenants/:id/settings", updateTenantSettingsHandler(svc.Tenants, sqlDB))
+		}
+
+		// Roles and menus as a bundle, moved between environments. They are the tenant's own,
+		// so this is registered outside adminGroup for every tenant's administrators.
+		rbacGroup := apiGroup.Group("/admin/rbac")
+		rbacGroup.Use(middleware.RequireRoles(middleware.RoleAdmin))
+		{
+			rbacGroup.GET("/export", exportRBACHandler(svc.RBAC))
+			rbacGroup.POST("/import", importRBACHandler(svc.RBAC, sqlDB))
+		}
+
+		// Runtime settings: log level, caching and debug features, changed without a restart,
+		// and reloading the configuration files as SIGHUP does.
+		// Registered outside adminGroup so the dedicated role is enough.
+		runtimeGroup := apiGroup.Group("/admin/runtime")
+		runtimeGroup.Use(middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleRuntimeAdmin))
+		{
+			runtimeGroup.GET("", getRuntimeSettingsHandler)
+			runtimeGroup.PATCH("", updateRuntimeSettingsHandler(sqlDB))
+			runtimeGroup.POST("/reload", reloadConfigHandler(svc.Config, sqlDB))
+		}
+
+		// Database backups: anyone holding one can read every table, password hashes
+		// included, so they take their own role. Registered outside adminGroup for the same
+		// reason as the runtime settings.
+		backupGroup := apiGroup.Group("/admin/backups")
+		backupGroup.Use(middleware.RequireDefaultTenant(), middleware.RequireRoles(middleware.RoleBackupAdmin))
+		{
+			backupGroup.POST("", createBackupHandler(svc.Backups, svc.Jobs, sqlDB))
+			backupGroup.GET("", listBackupsHandler(svc.Backups))
+			backupGroup.GET("/:id", getBackupHandler(svc.Backups))
+			backupGroup.GET("/:id/download", downloadBackupHandler(svc.Backups, sqlDB))
+		}
+
 		// Prayer schedule (Shalat) API - typically public but keeping under auth for consistency
+		// The shalat POSTs are pure lookups over reference data, so full responses are cached
 		apiv1Group := apiGroup.Group("/apiv1")
+		apiv1Group.Use(prayerRate.Middleware(ratelimit.Default), prayerChallenge.ChallengeMiddleware(ratelimit.Default, challenge.Default),
+			middleware.ResponseCacheMiddleware(database.Cache, "prayer", prayerResponseExpiration))
+		{
+			apiv1Group.POST("/getShalat", getShalatHandler(prayerService, shalatJSON))
+			apiv1Group.POST("/getApiProv", getApiProvHandler(prayerService, shalatJSON))
+			apiv1Group.POST("/getApiKabko", getApiKabkoHandler(prayerService, shalatJSON))
+			apiv1Group.POST("/getApiSholatbln", getApiSholatblnHandler(prayerService, shalatJSON))
+			apiv1Group.POST("/getApiSholatthn", getApiSholatthnHandler(prayerService, shalatJSON))
+			apiv1Group.POST("/getApiimsakiyah", getApiimsakiyahHandler(prayerService, shalatJSON))
+		}
+
+		// API version 2: breaking changes ship here while the routes above stay as they are.
+		// Routes without a breaking change keep their only path under /api. Responses are
+		// always the envelope, whatever Accept-Version says.
+		v2Group := apiGroup.Group("/v2")
+		v2Group.Use(middleware.APIVersionMiddleware(response.Version2))
 		{
-			apiv1Group.POST("/getShalat", getShalatHandler(prayerService))
-			apiv1Group.POST("/getApiProv", getApiProvHandler(prayerService))
-			apiv1Group.POST("/getApiKabko", getApiKabkoHandler(prayerService))
-			apiv1Group.POST("/getApiSholatbln", getApiSholatblnHandler(prayerService))
-			apiv1Group.POST("/getApiimsakiyah", getApiimsakiyahHandler(prayerService))
+			// Normalized prayer schedules: one shape for every range, opaque location codes
+			// instead of MD5s of sequential IDs, and errors as error statuses
+			prayerGroup := v2Group.Group("/prayer")
+			prayerGroup.Use(prayerRate.Middleware(ratelimit.Default), middleware.ResponseCacheMiddleware(database.Cache, "prayer", prayerResponseExpiration))
+			prayerGroup.GET("/provinces", listPrayerProvincesHandler(prayerService, locationCodes, shalatJSON))
+			prayerGroup.GET("/cities", listPrayerCitiesHandler(prayerService, locationCodes, shalatJSON))
+			prayerGroup.GET("/schedule", getPrayerScheduleHandler(prayerService, locationCodes, shalatJSON))
+			prayerGroup.GET("/imsakiyah", getFastingScheduleHandler(prayerService, locationCodes, shalatJSON))
+
+			// Device registration for push reminders writes, so it stays out of the response cache
+			devicesGroup := v2Group.Group("/prayer/devices")
+			devicesGroup.Use(prayerRate.Middleware(ratelimit.Default))
+			devicesGroup.POST("", registerPushDeviceHandler(svc.Push))
+			devicesGroup.POST("/unregister", unregisterPushDeviceHandler(svc.Push))
 		}
 
 	}
@@ -245,7 +875,7 @@ func healthHandler(c *gin.Context, db *gorm.DB) {
 
 	sqlDB, _ := db.DB()
 	if err := sqlDB.Ping(); err != nil {
-		log.Printf("Database health check failed: %v", err)
+		logger(c).Error("Database health check failed", "error", err)
 		dbHealthy = false
 	}
 
@@ -261,7 +891,14 @@ func healthHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "ok", "message": "Service is healthy", "redis": redisHealthy})
+	stats := sqlDB.Stats()
+	c.JSON(200, gin.H{"status": "ok", "message": "Service is healthy", "redis": redisHealthy, "db_pool": gin.H{
+		"open":          stats.OpenConnections,
+		"in_use":        stats.InUse,
+		"idle":          stats.Idle,
+		"wait_count":    stats.WaitCount,
+		"wait_duration": stats.WaitDuration.String(),
+	}})
 }
 
 // createAuditLog creates an audit log entry (deprecated - use logAuditEntry with Gin context instead)
@@ -283,6 +920,6 @@ func createAuditLog(db *sql.DB, userIDPtr *uint64, eventType string, tableName s
 	_, err := db.Exec("INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values) VALUES (?, ?, ?, ?, ?, ?)",
 		userID, eventType, tableName, recordID, oldJSON, newJSON)
 	if err != nil {
-		log.Printf("Error creating audit log: %v", err)
+		slog.Error("Error creating audit log", "error", err)
 	}
 }
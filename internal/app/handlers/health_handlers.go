@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dependency states reported by /health/ready
+const (
+	dependencyUp      = "up"
+	dependencyDown    = "down"
+	dependencySkipped = "skipped" // not configured, e.g. Redis disabled or no replica
+)
+
+// dependencyCheck probes one dependency. check returns errDependencyNotConfigured when the
+// dependency is not in use, which reports it as skipped rather than down.
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var errDependencyNotConfigured = errors.New("not configured")
+
+// dependencyStatus is the outcome of one check
+type dependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// readinessReport is the /health/ready body
+type readinessReport struct {
+	Status       string             `json:"status"`
+	CheckedAt    time.Time          `json:"checked_at"`
+	Dependencies []dependencyStatus `json:"dependencies"`
+}
+
+// readinessChecker runs every dependency check concurrently, each bounded by timeout. A
+// report is reused for minInterval so frequent or hostile probes cannot turn into a flood
+// of pings against the database, Redis and JasperServer.
+type readinessChecker struct {
+	checks      []dependencyCheck
+	required    map[string]bool
+	timeout     time.Duration
+	minInterval time.Duration
+
+	mu   sync.Mutex
+	last *readinessReport
+}
+
+// newReadinessChecker builds the checker for the primary database, the read replica,
+// Redis and JasperServer. required lists the dependencies whose failure makes the service
+// unready; the others are reported but do not fail the probe.
+func newReadinessChecker(sqlDB *sql.DB, required []string, timeout, minInterval time.Duration) *readinessChecker {
+	r := &readinessChecker{
+		checks: []dependencyCheck{
+			{name: "database", check: sqlDB.PingContext},
+			{name: "database_replica", check: func(ctx context.Context) error {
+				if database.ReplicaDB == nil {
+					return errDependencyNotConfigured
+				}
+				return database.ReplicaDB.PingContext(ctx)
+			}},
+			{name: "redis", check: func(ctx context.Context) error {
+				if database.RedisClient == nil {
+					return errDependencyNotConfigured
+				}
+				return database.RedisClient.Ping(ctx).Err()
+			}},
+			{name: "jasperserver", check: func(ctx context.Context) error {
+				if jasperClient == nil {
+					return errDependencyNotConfigured
+				}
+				_, err := jasperClient.GetServerInfo(ctx)
+				return err
+			}},
+		},
+		required:    make(map[string]bool),
+		timeout:     timeout,
+		minInterval: minInterval,
+	}
+	for _, name := range required {
+		if name = strings.TrimSpace(name); name != "" {
+			r.required[name] = true
+		}
+	}
+	return r
+}
+
+// report returns a fresh report, or the previous one when it is younger than minInterval
+func (r *readinessChecker) report(ctx context.Context) *readinessReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last != nil && time.Since(r.last.CheckedAt) < r.minInterval {
+		return r.last
+	}
+
+	// The report is shared with later callers, so it must not fail because this caller left
+	ctx = context.WithoutCancel(ctx)
+	report := &readinessReport{Status: "ok", CheckedAt: time.Now(), Dependencies: make([]dependencyStatus, len(r.checks))}
+	var wg sync.WaitGroup
+	for i, dep := range r.checks {
+		wg.Add(1)
+		go func(i int, dep dependencyCheck) {
+			defer wg.Done()
+			report.Dependencies[i] = r.run(ctx, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	for _, dep := range report.Dependencies {
+		if dep.Required && dep.Status != dependencyUp {
+			report.Status = "unavailable"
+		}
+	}
+	r.last = report
+	return report
+}
+
+// run probes one dependency. Errors are logged in full but reported only as timeout or
+// unreachable, since the probe endpoints are public.
+func (r *readinessChecker) run(ctx context.Context, dep dependencyCheck) dependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dep.check(ctx)
+	status := dependencyStatus{
+		Name:      dep.name,
+		Status:    dependencyUp,
+		Required:  r.required[dep.name],
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	switch {
+	case errors.Is(err, errDependencyNotConfigured):
+		status.Status = dependencySkipped
+		status.LatencyMs = 0
+	case err != nil:
+		status.Status = dependencyDown
+		status.Error = "unreachable"
+		if ctx.Err() == context.DeadlineExceeded {
+			status.Error = "timeout"
+		}
+		logging.FromContext(ctx).Warn("Readiness check failed", "dependency", dep.name, "error", err)
+	}
+	return status
+}
+
+// liveHandler handles GET /health/live: the process is up and serving HTTP. It checks no
+// dependencies, so an outage elsewhere never gets the pod restarted.
+func liveHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyHandler handles GET /health/ready: 200 when every required dependency answered
+// within the timeout, 503 otherwise, with per-dependency status and latency
+func readyHandler(checker *readinessChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.report(c.Request.Context())
+		code := http.StatusOK
+		if report.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tabular"
+	"adminbe/internal/pkg/utils"
+	"adminbe/internal/pkg/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The columns of the per-row reports of the imports as CSV
+var (
+	userImportColumns     = []string{"row", "username", "email", "roles", "result", "user_id", "errors"}
+	locationImportColumns = []string{"row", "code", "kind", "name", "action", "id", "changes", "errors"}
+)
+
+// readImportFile reads the "file" part of a multipart upload as a table of at most maxRows
+// rows, answering 413 for a file over maxBytes and 400 for one that cannot be read
+func readImportFile(c *gin.Context, maxBytes int64, maxRows int) (*tabular.Table, string, bool) {
+	file, closer, ok := formFile(c)
+	if !ok {
+		return nil, "", false
+	}
+	defer closer.Close()
+
+	if file.Size > maxBytes {
+		utils.RespondError(c, http.StatusRequestEntityTooLarge, "The file is larger than "+strconv.FormatInt(maxBytes, 10)+" bytes")
+		return nil, "", false
+	}
+	data, err := io.ReadAll(io.LimitReader(file.Content, maxBytes+1))
+	if err != nil {
+		utils.HandleError(c, err, "read upload")
+		return nil, "", false
+	}
+	if int64(len(data)) > maxBytes {
+		utils.RespondError(c, http.StatusRequestEntityTooLarge, "The file is larger than "+strconv.FormatInt(maxBytes, 10)+" bytes")
+		return nil, "", false
+	}
+
+	table, err := tabular.Read(file.Filename, data, maxRows)
+	if err != nil {
+		var tooMany *tabular.TooManyRowsError
+		switch {
+		case errors.Is(err, tabular.ErrUnsupported):
+			utils.RespondError(c, http.StatusUnsupportedMediaType, err.Error())
+		case errors.As(err, &tooMany):
+			utils.RespondError(c, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+		}
+		return nil, "", false
+	}
+	return table, file.Filename, true
+}
+
+// dryRunQuery reads ?dry_run=, answering 400 for a value other than a boolean
+func dryRunQuery(c *gin.Context) (bool, bool) {
+	return boolQuery(c, "dry_run")
+}
+
+// boolQuery reads a boolean query parameter, false when absent, answering 400 for a value
+// other than a boolean
+func boolQuery(c *gin.Context, name string) (bool, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, name+" must be true or false")
+		return false, false
+	}
+	return b, true
+}
+
+// importUsersHandler POST /api/users/import
+// Creates users from the "file" part of a multipart body, CSV or XLSX with a header row of
+// username, email and optionally password, status and roles. Every row is validated first;
+// with ?dry_run=true, or when any row is invalid (422), nothing is written. Otherwise all
+// rows are created in one transaction (201). The per-row report is JSON, or CSV with
+// Accept: text/csv.
+func importUsersHandler(imports services.UserImportService, db *sql.DB, maxBytes int64, maxRows int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun, ok := dryRunQuery(c)
+		if !ok {
+			return
+		}
+		table, filename, ok := readImportFile(c, maxBytes, maxRows)
+		if !ok {
+			return
+		}
+
+		result, err := imports.Import(c.Request.Context(), table, dryRun)
+		if utils.HandleError(c, err, "import users") {
+			return
+		}
+
+		status, message := http.StatusOK, "Every row is valid; nothing was written"
+		switch {
+		case result.Invalid > 0:
+			status, message = http.StatusUnprocessableEntity, strconv.Itoa(result.Invalid)+" of "+strconv.Itoa(result.Total)+" rows are invalid; nothing was imported"
+		case result.Committed:
+			status, message = http.StatusCreated, strconv.Itoa(result.Created)+" users imported"
+			ids := make([]uint64, 0, len(result.Rows))
+			for _, row := range result.Rows {
+				if row.UserID != nil {
+					ids = append(ids, *row.UserID)
+				}
+			}
+			// One entry for the whole file rather than one per user
+			logAuditEntry(c, "CREATE", "users", 0, nil, gin.H{"import": filename, "format": table.Format, "created": result.Created, "user_ids": ids}, db)
+		}
+
+		if wantsCSV(c) {
+			records := make([]gin.H, len(result.Rows))
+			for i, row := range result.Rows {
+				records[i] = gin.H{
+					"row":      row.Row,
+					"username": row.Username,
+					"email":    row.Email,
+					"roles":    strings.Join(row.Roles, ";"),
+					"result":   row.Result,
+					"user_id":  row.UserID,
+					"errors":   importErrors(row.Errors),
+				}
+			}
+			writeImportReport(c, "user-import", status, userImportColumns, records)
+			return
+		}
+		response.Write(c, status, response.Body{
+			Data:    result,
+			Message: message,
+			Meta:    response.Meta{"total": result.Total, "valid": result.Valid, "invalid": result.Invalid, "created": result.Created},
+		})
+	}
+}
+
+// writeImportReport sends the rows of an import report as CSV, downloaded as name.csv
+func writeImportReport(c *gin.Context, name string, status int, columns []string, records []gin.H) {
+	stream := newCSVStream(c, name, columns)
+	stream.status = status
+	var err error
+	for _, record := range records {
+		if err = stream.Write(record); err != nil {
+			break
+		}
+	}
+	stream.Close("import report", err)
+}
+
+// importErrors renders the errors of a row in one cell, "column: message" separated by
+// semicolons and ordered by column
+func importErrors(errs map[string]validation.FieldError) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + ": " + errs[field].Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// importChanges renders the changes of a row in one cell, "column: old -> new" separated by
+// semicolons and ordered by column
+func importChanges(changes map[string]models.ValueChange) string {
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + ": " + importValue(changes[field].Old) + " -> " + importValue(changes[field].New)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// importValue renders a value of a change, dereferencing pointers
+func importValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "(none)"
+	case *float64:
+		if v == nil {
+			return "(none)"
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	case *int:
+		if v == nil {
+			return "(none)"
+		}
+		return strconv.Itoa(*v)
+	case *string:
+		if v == nil {
+			return "(none)"
+		}
+		return *v
+	}
+	return fmt.Sprint(v)
+}
+
+// importLocationsHandler POST /api/admin/locations/import
+// Loads Kemendagri region codes and coordinates from the "file" part of a multipart body,
+// CSV or XLSX with a code column and optionally name, latitude, longitude, elevation and
+// time_zone. The answer is the diff against app_province and app_city: applied (201, with
+// the import_id to roll back) unless ?dry_run=true (200) or a row is invalid (422). CSV
+// with Accept: text/csv.
+func importLocationsHandler(imports services.LocationImportService, db *sql.DB, maxBytes int64, maxRows int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun, ok := dryRunQuery(c)
+		if !ok {
+			return
+		}
+		table, filename, ok := readImportFile(c, maxBytes, maxRows)
+		if !ok {
+			return
+		}
+
+		origin := models.LocationImport{Filename: filename, Source: models.LocationImportSourceAPI, CreatedBy: getUserIDFromContext(c)}
+		result, err := imports.Import(c.Request.Context(), table, dryRun, origin)
+		if utils.HandleError(c, err, "import locations") {
+			return
+		}
+
+		status, message := http.StatusOK, "Nothing to change"
+		switch {
+		case result.Invalid > 0:
+			status, message = http.StatusUnprocessableEntity, strconv.Itoa(result.Invalid)+" of "+strconv.Itoa(result.Total)+" rows are invalid; nothing was imported"
+		case result.Committed:
+			status, message = http.StatusCreated, fmt.Sprintf("Import %d: %d regions created, %d updated", *result.ImportID, result.Created, result.Updated)
+			logAuditEntry(c, "CREATE", "location_imports", *result.ImportID, nil,
+				gin.H{"filename": filename, "format": table.Format, "created": result.Created, "updated": result.Updated}, db)
+		case dryRun:
+			message = fmt.Sprintf("Dry run: %d regions to create, %d to update", result.Created, result.Updated)
+		}
+
+		if wantsCSV(c) {
+			records := make([]gin.H, len(result.Rows))
+			for i, row := range result.Rows {
+				records[i] = gin.H{
+					"row":     row.Row,
+					"code":    row.Code,
+					"kind":    row.Kind,
+					"name":    row.Name,
+					"action":  row.Action,
+					"id":      row.ID,
+					"changes": importChanges(row.Changes),
+					"errors":  importErrors(row.Errors),
+				}
+			}
+			writeImportReport(c, "location-import", status, locationImportColumns, records)
+			return
+		}
+		response.Write(c, status, response.Body{
+			Data:    result,
+			Message: message,
+			Meta: response.Meta{"total": result.Total, "created": result.Created, "updated": result.Updated,
+				"unchanged": result.Unchanged, "skipped": result.Skipped, "invalid": result.Invalid},
+		})
+	}
+}
+
+// listLocationImportsHandler GET /api/admin/locations/imports
+// The reference data imports, newest first; ?before_id= pages back
+func listLocationImportsHandler(imports services.LocationImportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var beforeID uint64
+		if v := c.Query("before_id"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			beforeID = n
+		}
+		list, err := imports.ListImports(c.Request.Context(), beforeID, parseIntMinMax(c.Query("limit"), 50, 1, 500))
+		if utils.HandleError(c, err, "list location imports") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getLocationImportHandler GET /api/admin/locations/imports/:id
+// An import with the regions it created or changed, before and after
+func getLocationImportHandler(imports services.LocationImportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imp, changes, err := imports.GetImport(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get location import") {
+			return
+		}
+		response.OK(c, models.LocationImportDetail{Import: imp, Changes: changes})
+	}
+}
+
+// rollbackLocationImportHandler POST /api/admin/locations/imports/:id/rollback
+// Restores what the import created or changed; only the latest applied import can be
+// rolled back (409 otherwise)
+func rollbackLocationImportHandler(imports services.LocationImportService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imp, err := imports.Rollback(c.Request.Context(), c.Param("id"), getUserIDFromContext(c))
+		if utils.HandleError(c, err, "roll back location import") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "location_imports", imp.ID, gin.H{"status": models.LocationImportApplied}, imp, db)
+		response.Write(c, http.StatusOK, response.Body{Data: imp, Message: fmt.Sprintf("Import %d rolled back", imp.ID)})
+	}
+}
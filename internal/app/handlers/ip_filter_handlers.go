@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateIPFilterRequest replaces the configured IP lists. Entries are CIDRs or single
+// addresses; an empty allow list lets in every address not denied.
+type UpdateIPFilterRequest struct {
+	Allow []string `json:"allow" binding:"max=1000"`
+	Deny  []string `json:"deny" binding:"max=1000"`
+}
+
+// IPFilterStatus is the rules in effect and how they treat the caller
+type IPFilterStatus struct {
+	ipfilter.Rules
+	ClientIP string `json:"client_ip"`
+}
+
+// getIPFilterHandler GET /api/admin/ip_filter
+func getIPFilterHandler(f *ipfilter.Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.OK(c, IPFilterStatus{Rules: f.Current(), ClientIP: c.ClientIP()})
+	}
+}
+
+// updateIPFilterHandler PUT /api/admin/ip_filter
+// Rules refusing the caller's own address are rejected, so administrators cannot lock
+// themselves out. The change is audited and reaches every instance through Redis.
+func updateIPFilterHandler(f *ipfilter.Filter, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateIPFilterRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		rules := ipfilter.Rules{Allow: req.Allow, Deny: req.Deny, UpdatedAt: time.Now().UTC()}
+		if rules.Allow == nil {
+			rules.Allow = []string{}
+		}
+		if rules.Deny == nil {
+			rules.Deny = []string{}
+		}
+		if err := rules.Validate(); err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		clientIP := c.ClientIP()
+		if err := rules.Check(clientIP); err != nil {
+			utils.RespondError(c, http.StatusConflict, "These rules would refuse your own address "+clientIP+": "+err.Error())
+			return
+		}
+		if userID := getUserIDFromContext(c); userID != nil {
+			rules.UpdatedBy = *userID
+		}
+
+		before := f.Current()
+		if err := f.Set(c.Request.Context(), rules); err != nil {
+			logger(c).Error("Error storing IP filter rules", "error", err)
+			utils.RespondError(c, http.StatusServiceUnavailable, "Failed to store IP filter rules")
+			return
+		}
+		rules = f.Current()
+
+		logAuditEntry(c, "UPDATE", "ip_filter", 0, before, rules, db)
+		logger(c).Warn("IP filter rules replaced", "user_id", rules.UpdatedBy, "allow", rules.Allow, "deny", rules.Deny)
+		response.Write(c, http.StatusOK, response.Body{Data: IPFilterStatus{Rules: rules, ClientIP: clientIP}, Message: "IP filter rules updated"})
+	}
+}
+
+// resetIPFilterHandler DELETE /api/admin/ip_filter
+// Goes back to the configured lists (IP_ALLOWLIST and IP_DENYLIST), on every instance.
+func resetIPFilterHandler(f *ipfilter.Filter, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before := f.Current()
+		rules, err := f.Reset(c.Request.Context())
+		if err != nil {
+			logger(c).Error("Error resetting IP filter rules", "error", err)
+			utils.RespondError(c, http.StatusServiceUnavailable, "Failed to reset IP filter rules")
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "ip_filter", 0, before, rules, db)
+		logger(c).Warn("IP filter rules reset to the configured lists", "allow", rules.Allow, "deny", rules.Deny)
+		response.Write(c, http.StatusOK, response.Body{Data: IPFilterStatus{Rules: rules, ClientIP: c.ClientIP()},
+			Message: "IP filter rules reset to the configuration"})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/fieldcrypt"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditRetentionBatch is how many audit log rows one DELETE removes, keeping locks short
+const auditRetentionBatch = 1000
+
+// reencryptBatch is how many rows the re-encryption job reads at a time
+const reencryptBatch = 500
+
+// registerJobs adds the recurring jobs to jobs; main starts them once the routes are set up
+func registerJobs(jobs *scheduler.Scheduler, sqlDB *sql.DB, cfg config.Jobs, prayerSubscriptions services.PrayerSubscriptionService, pushes services.PushService, otp services.OTPService, exports services.ExportJobService, backups services.DBBackupService, dataQuality services.DataQualityService, reportSchedules services.ReportScheduleService, calendars services.CalendarService) {
+	for _, job := range []scheduler.Job{
+		{
+			Name:        "audit_retention",
+			Description: fmt.Sprintf("Delete audit log entries older than %s", cfg.AuditRetention.MaxAge),
+			Schedule:    cfg.AuditRetention.Schedule,
+			Enabled:     cfg.AuditRetention.Enabled,
+			Timeout:     time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				deleted, err := purgeAuditLogs(ctx, sqlDB, time.Now().Add(-cfg.AuditRetention.MaxAge))
+				return fmt.Sprintf("deleted %d entries", deleted), err
+			},
+		},
+		{
+			Name:        "cache_warm",
+			Description: "Reload the warmable cache keys, as POST /api/admin/cache/warm",
+			Schedule:    cfg.CacheWarm.Schedule,
+			Enabled:     cfg.CacheWarm.Enabled,
+			Timeout:     5 * time.Minute,
+			Run: func(context.Context) (string, error) {
+				return warmAll(database.Cache)
+			},
+		},
+		{
+			Name:        "field_reencrypt",
+			Description: "Rewrite encrypted columns not yet sealed with the primary key",
+			Schedule:    cfg.FieldReencrypt.Schedule,
+			Enabled:     cfg.FieldReencrypt.Enabled,
+			Timeout:     time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				return reencryptFields(ctx, sqlDB)
+			},
+		},
+		{
+			Name:        "prayer_daily",
+			Description: "Send today's prayer times to the daily chat subscriptions",
+			Schedule:    cfg.PrayerDaily.Schedule,
+			Enabled:     cfg.PrayerDaily.Enabled,
+			Timeout:     time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				return prayerSubscriptions.SendDue(ctx, models.SubscriptionDaily, time.Now())
+			},
+		},
+		{
+			Name:        "prayer_imsakiyah",
+			Description: "Send the imsak reminder to the imsakiyah chat subscriptions during the fasting period",
+			Schedule:    cfg.PrayerImsakiyah.Schedule,
+			Enabled:     cfg.PrayerImsakiyah.Enabled,
+			Timeout:     time.Hour,
+			Run: func(ctx context.Context) (string, error) {
+				return prayerSubscriptions.SendDue(ctx, models.SubscriptionImsakiyah, time.Now())
+			},
+		},
+		{
+			Name:        "push_reminders",
+			Description: "Send prayer reminders to the registered mobile devices of the cities where a prayer is due",
+			Schedule:    cfg.PushReminders.Schedule,
+			Enabled:     cfg.PushReminders.Enabled,
+			Timeout:     30 * time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				return pushes.SendReminders(ctx, time.Now())
+			},
+		},
+		{
+			Name:        "otp_cleanup",
+			Description: "Delete the one-time codes that expired more than a day ago",
+			Schedule:    cfg.OTPCleanup.Schedule,
+			Enabled:     cfg.OTPCleanup.Enabled,
+			Timeout:     10 * time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				return otp.DeleteExpiredCodes(ctx, time.Now())
+			},
+		},
+		{
+			Name:        exportJobsJob,
+			Description: "Run the queued export jobs and remove the files of those past their retention",
+			Schedule:    cfg.ExportJobs.Schedule,
+			Enabled:     cfg.ExportJobs.Enabled,
+			// Each export has its own EXPORT_JOB_TIMEOUT, and a run takes as many as are queued
+			Timeout: 0,
+			Run:     exports.RunQueued,
+		},
+		{
+			Name:        dbBackupJob,
+			Description: "Back the database up to storage and remove the backups past their retention",
+			Schedule:    cfg.DBBackup.Schedule,
+			Enabled:     cfg.DBBackup.Enabled,
+			// Each backup has its own DB_BACKUP_TIMEOUT
+			Timeout: 0,
+			Run:     backups.Run,
+		},
+		{
+			Name:        "data_quality",
+			Description: "Look for inconsistent reference data, listed under GET /api/admin/data-quality",
+			Schedule:    cfg.DataQuality.Schedule,
+			Enabled:     cfg.DataQuality.Enabled,
+			Timeout:     30 * time.Minute,
+			Run:         dataQuality.Run,
+		},
+		{
+			Name:        reportSchedulesJob,
+			Description: "Run the report schedules that are due and keep each file with its owner's attachments",
+			Schedule:    cfg.ReportSchedules.Schedule,
+			Enabled:     cfg.ReportSchedules.Enabled,
+			// Each report has its own REPORT_SCHEDULE_TIMEOUT, and a run takes as many as are due
+			Timeout: 0,
+			Run:     reportSchedules.RunDue,
+		},
+		{
+			Name:        calendarSyncJob,
+			Description: "Publish the next runs of report schedules to the calendar and remove those no longer planned",
+			Schedule:    cfg.CalendarSync.Schedule,
+			Enabled:     cfg.CalendarSync.Enabled,
+			Timeout:     30 * time.Minute,
+			Run:         calendars.Sync,
+		},
+	} {
+		if err := jobs.Register(job); err != nil {
+			log.Fatalf("Failed to register job: %v", err)
+		}
+	}
+}
+
+// reencryptFields rewrites every encrypted column with the primary key, in batches
+func reencryptFields(ctx context.Context, sqlDB *sql.DB) (string, error) {
+	var summary []string
+	for _, field := range repositories.EncryptedFields {
+		res, err := fieldcrypt.Reencrypt(ctx, sqlDB, field, reencryptBatch)
+		summary = append(summary, fmt.Sprintf("%s: rewrote %d of %d", field.Context(), res.Rewritten, res.Scanned))
+		if err != nil {
+			return strings.Join(summary, ", "), err
+		}
+	}
+	return strings.Join(summary, ", "), nil
+}
+
+// purgeAuditLogs deletes the audit log entries created before cutoff, in batches
+func purgeAuditLogs(ctx context.Context, sqlDB *sql.DB, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		// The derived table lets MySQL delete from the table it selects from
+		result, err := sqlDB.ExecContext(ctx, "DELETE FROM audit_logs WHERE id IN (SELECT id FROM (SELECT id FROM audit_logs WHERE created_at < ? ORDER BY id LIMIT ?) AS expired)",
+			cutoff, auditRetentionBatch)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < auditRetentionBatch {
+			return total, nil
+		}
+	}
+}
+
+// warmAll warms every registered cache key, failing with the keys that did not load
+func warmAll(store cache.Cache) (string, error) {
+	results := cache.Warm(store)
+	var failed []string
+	for key, err := range results {
+		if err != nil {
+			failed = append(failed, key+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return "", errors.New(strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("warmed %d keys", len(results)), nil
+}
+
+// listJobsHandler GET /api/admin/jobs
+// meta.distributed is false when Redis is not attached, and each instance runs every job.
+func listJobsHandler(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := jobs.Jobs()
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list), "distributed": jobs.Distributed()}})
+	}
+}
+
+// listJobRunsHandler GET /api/admin/jobs/:name/runs
+// Runs are kept in memory, newest first, JOB_HISTORY_SIZE per job and per instance.
+func listJobRunsHandler(jobs *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := jobs.Job(c.Param("name"))
+		if err != nil {
+			utils.RespondError(c, http.StatusNotFound, "Job not found")
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: job.History, Meta: response.Meta{"job": job.Name, "count": len(job.History)}})
+	}
+}
+
+// runJobHandler POST /api/admin/jobs/:name/run
+// The run starts in the background, even for a job disabled on schedule, and shows up in
+// the job's history when it finishes.
+func runJobHandler(jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		switch err := jobs.RunNow(name); {
+		case errors.Is(err, scheduler.ErrUnknownJob):
+			utils.RespondError(c, http.StatusNotFound, "Job not found")
+			return
+		case errors.Is(err, scheduler.ErrJobRunning):
+			utils.RespondError(c, http.StatusConflict, "Job is already running")
+			return
+		}
+
+		logAuditEntry(c, "API_ACCESS", "jobs", 0, nil, gin.H{"job": name}, db)
+		logger(c).Info("Job started by hand", "job", name)
+		response.Write(c, http.StatusAccepted, response.Body{Data: gin.H{"job": name}, Message: "Job started"})
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListQuery reads ?sort= and ?fields= and checks them against spec, answering 400 and
+// returning false when they are invalid
+func parseListQuery(c *gin.Context, spec utils.ListSpec, op string) (utils.ListQuery, bool) {
+	q, err := utils.ParseListQuery(c.Query("sort"), c.Query("fields"))
+	if err == nil {
+		err = spec.Validate(q)
+	}
+	if utils.HandleError(c, err, op) {
+		return q, false
+	}
+	return q, true
+}
+
+// rejectSortWithCursor answers 400 when a cursor-paginated request also asks for a sort,
+// since cursors only follow the default newest-first order
+func rejectSortWithCursor(c *gin.Context, q utils.ListQuery, op string) bool {
+	if len(q.Sort) == 0 {
+		return false
+	}
+	utils.HandleError(c, utils.NewValidationError("sort cannot be combined with cursor pagination"), op)
+	return true
+}
+
+// selectFields reduces each element of the list data to fields, keeping their JSON
+// encoding; data is returned as is when fields is empty. The result is a copy, so cached
+// lists are never modified.
+func selectFields(data interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	if items == nil {
+		return data, nil // keep a null list null
+	}
+
+	selected := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		selected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := item[f]; ok {
+				selected[i][f] = v
+			}
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geocodeHandler GET /api/admin/locations/geocode?q=
+// The coordinates and elevation the geocoder finds for a place name, to check before adding
+// a city
+func geocodeHandler(locations services.LocationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		place, err := locations.Geocode(c.Request.Context(), c.Query("q"))
+		if utils.HandleError(c, err, "geocode") {
+			return
+		}
+		response.OK(c, place)
+	}
+}
+
+// getCityLocationHandler GET /api/admin/locations/cities/:id
+func getCityLocationHandler(locations services.LocationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		city, err := locations.GetCity(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get city") {
+			return
+		}
+		response.OK(c, city)
+	}
+}
+
+// createCityLocationHandler POST /api/admin/locations/cities
+// The coordinates and elevation left out are geocoded from the city and province names;
+// data.geocoded lists which.
+func createCityLocationHandler(locations services.LocationService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CityLocationRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		city, err := locations.CreateCity(c.Request.Context(), req)
+		if utils.HandleError(c, err, "create city") {
+			return
+		}
+
+		logAuditEntry(c, "CREATE", "app_city", uint64(city.ID), nil, city, db)
+
+		response.Write(c, http.StatusCreated, response.Body{Data: city, Message: "City created"})
+	}
+}
+
+// updateCityLocationHandler PUT /api/admin/locations/cities/:id
+// Replaces the city, geocoding what is left out as on create
+func updateCityLocationHandler(locations services.LocationService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CityLocationRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		old, city, err := locations.UpdateCity(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "update city") {
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "app_city", uint64(city.ID), old, city, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: city, Message: "City updated"})
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mailer sends the emails handlers queue; set by SetupRoutes
+var mailer services.MailService
+
+// sendMail queues req once the request's writes are committed
+func sendMail(c *gin.Context, req services.MailRequest) {
+	if mailer == nil || req.To == "" {
+		return
+	}
+	req.Data = tenantMailData(c.Request.Context(), req.Data)
+	afterCommit(c, func() { mailer.Send(req) })
+}
+
+// tenantMailData returns data with the app_name the request's tenant brands itself with,
+// unless data sets one or the tenant keeps the global name
+func tenantMailData(ctx context.Context, data map[string]any) map[string]any {
+	name := tenant.SettingsFrom(ctx).Branding[models.BrandingAppName]
+	if name == "" {
+		return data
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	if _, ok := data["app_name"]; !ok {
+		data["app_name"] = name
+	}
+	return data
+}
+
+// mailUser queues template for userID once the request's writes are committed, looking up
+// their address and username then; data gains username
+func mailUser(c *gin.Context, db *sql.DB, userID uint64, template string, data map[string]any, attachments ...mail.Attachment) {
+	if mailer == nil {
+		return
+	}
+	ctx := context.WithoutCancel(c.Request.Context())
+	afterCommit(c, func() {
+		var username, email string
+		err := db.QueryRowContext(ctx, "SELECT username, email FROM users WHERE id = ? AND deleted_at IS NULL", userID).
+			Scan(&username, &email)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				slog.Error("Error looking up mail recipient", "user_id", userID, "template", template, "error", err)
+			}
+			return
+		}
+		data = tenantMailData(ctx, data)
+		if data == nil {
+			data = map[string]any{}
+		}
+		data["username"] = username
+		mailer.Send(services.MailRequest{Template: template, To: email, UserID: &userID, Data: data, Attachments: attachments})
+	})
+}
+
+// mailRoleChange tells userID that roleID was granted to them or taken away
+func mailRoleChange(c *gin.Context, db *sql.DB, userID uint64, roleID uint, granted bool) {
+	if mailer == nil {
+		return
+	}
+	var role string
+	err := db.QueryRowContext(c.Request.Context(), "SELECT name FROM roles WHERE id = ?", roleID).Scan(&role)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger(c).Error("Error looking up changed role", "role_id", roleID, "error", err)
+		}
+		return
+	}
+	mailUser(c, db, userID, mail.TemplateRoleChanged, map[string]any{"role": role, "role_id": roleID, "granted": granted})
+}
+
+// mailPasswordReset tells a user an administrator set a new password for them
+func mailPasswordReset(c *gin.Context, user *models.User, req models.UpdateUserRequest) {
+	if req.Password == "" {
+		return
+	}
+	sendMail(c, services.MailRequest{
+		Template: mail.TemplatePasswordReset,
+		To:       user.Email,
+		UserID:   &user.ID,
+		Data:     map[string]any{"username": user.Username},
+	})
+}
+
+// mailPasswordExpired tells a user an administrator made them change their password at
+// their next sign-in
+func mailPasswordExpired(c *gin.Context, user *models.User) {
+	sendMail(c, services.MailRequest{
+		Template: mail.TemplatePasswordExpired,
+		To:       user.Email,
+		UserID:   &user.ID,
+		Data:     map[string]any{"username": user.Username},
+	})
+}
+
+// listMailDeliveriesHandler GET /api/admin/mail/deliveries
+// Newest first, optionally only one ?status=, ?template= or ?recipient=; ?before_id= pages
+// back.
+func listMailDeliveriesHandler(mailService services.MailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.MailDeliveryFilter{
+			Status:    c.Query("status"),
+			Template:  c.Query("template"),
+			Recipient: c.Query("recipient"),
+			Limit:     parseIntMinMax(c.Query("limit"), 50, 1, 1000),
+		}
+		if v := c.Query("before_id"); v != "" {
+			beforeID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			filter.BeforeID = beforeID
+		}
+
+		list, err := mailService.ListDeliveries(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list mail deliveries") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"adminbe/internal/pkg/maintenance"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateMaintenanceRequest replaces the maintenance state
+type UpdateMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Message string `json:"message" binding:"max=500"`
+	// RetryAfterSeconds is the Retry-After of rejected requests; 0 uses 300
+	RetryAfterSeconds int `json:"retry_after_seconds" binding:"min=0,max=86400"`
+	// AllowedUsers may keep using the API; the caller is always added
+	AllowedUsers []uint64 `json:"allowed_users" binding:"max=100"`
+}
+
+// getMaintenanceHandler GET /api/admin/maintenance
+func getMaintenanceHandler(sw *maintenance.Switch) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.OK(c, sw.Current())
+	}
+}
+
+// updateMaintenanceHandler PUT /api/admin/maintenance
+// Turning maintenance on keeps the caller allowed, so they cannot lock themselves out.
+// The change is audited and reaches every instance through Redis.
+func updateMaintenanceHandler(sw *maintenance.Switch, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateMaintenanceRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		state := maintenance.State{
+			Enabled:           *req.Enabled,
+			Message:           req.Message,
+			RetryAfterSeconds: req.RetryAfterSeconds,
+			AllowedUsers:      req.AllowedUsers,
+			UpdatedAt:         time.Now().UTC(),
+		}
+		if userID := getUserIDFromContext(c); userID != nil {
+			state.UpdatedBy = *userID
+			if !state.Allows(*userID) {
+				state.AllowedUsers = append(state.AllowedUsers, *userID)
+			}
+		}
+
+		before := sw.Current()
+		if err := sw.Set(c.Request.Context(), state); err != nil {
+			logger(c).Error("Error storing maintenance state", "error", err)
+			utils.RespondError(c, http.StatusServiceUnavailable, "Failed to store maintenance state")
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "maintenance", 0, before, state, db)
+		logger(c).Warn("Maintenance mode changed", "user_id", state.UpdatedBy, "enabled", state.Enabled,
+			"allowed_users", state.AllowedUsers)
+
+		message := "Maintenance mode off"
+		if state.Enabled {
+			message = "Maintenance mode on"
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: state, Message: message})
+	}
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+	"context"
 	"database/sql"
-	"log"
 	"net/http"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
 	"adminbe/internal/app/services"
 	"adminbe/internal/pkg/cache"
 	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/response"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,50 +20,55 @@ import (
 // isNotFoundError function is defined in user_handlers.go
 
 // listMenuHandler GET /api/menu
+// Takes ?sort=label:asc and ?fields=id,label,url.
 func listMenuHandler(menuService services.MenuService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Try to get from Redis cache first
-		var menus []models.Menu
-		err := database.Cache.Get(cache.CacheKeyMenuList, &menus)
-		if err == nil {
-			// Cache hit
-			c.JSON(http.StatusOK, gin.H{"data": menus, "cached": true})
+		q, ok := parseListQuery(c, repositories.MenuListSpec, "list menus")
+		if !ok {
 			return
 		}
 
-		// Cache miss - fetch from database
-		menus, err = menuService.ListMenus()
+		var menus []models.Menu
+		var cached bool
+		var err error
+		if len(q.Sort) == 0 {
+			// Read through the cache; concurrent misses share a single DB load
+			menus, cached, err = cache.GetOrLoad(c.Request.Context(), database.Cache, tenant.CacheKey(c.Request.Context(), cache.CacheKeyMenuList), cache.TTLFor(c.Request.Context(), "menu", cache.TTLList), func(ctx context.Context) ([]models.Menu, error) {
+				return menuService.ListMenus(ctx, nil)
+			})
+		} else {
+			menus, err = menuService.ListMenus(c.Request.Context(), q.Sort)
+		}
 		if err != nil {
-			log.Printf("Error listing menus: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menu"})
+			logger(c).Error("Error listing menus", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve menu")
 			return
 		}
 
-		// Cache the result
-		cacheErr := database.Cache.Set(cache.CacheKeyMenuList, menus, cache.DefaultListExpiration)
-		if cacheErr != nil {
-			log.Printf("Warning: Failed to cache menus: %v", cacheErr)
+		data, err := selectFields(menus, q.Fields)
+		if utils.HandleError(c, err, "list menus") {
+			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{"data": menus, "cached": false})
+		response.Write(c, http.StatusOK, response.Body{Data: data, Meta: response.Meta{"cached": cached}})
 	}
 }
 
 // getMenuHandler GET /api/menu/:id
+// Answers 304 when If-None-Match holds the current ETag.
 func getMenuHandler(menuService services.MenuService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		menu, err := menuService.GetMenu(id)
+		menu, err := menuService.GetMenu(c.Request.Context(), id)
 		if err != nil && isNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			utils.RespondError(c, http.StatusNotFound, "Menu not found")
 			return
 		}
 		if err != nil {
-			log.Printf("Error getting menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menu"})
+			logger(c).Error("Error getting menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve menu")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": menu})
+		response.OKConditional(c, menu)
 	}
 }
 
@@ -77,7 +86,7 @@ func createMenuHandler(menuService services.MenuService, db *sql.DB) gin.Handler
 	return func(c *gin.Context) {
 		var req CreateMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
@@ -94,26 +103,17 @@ func createMenuHandler(menuService services.MenuService, db *sql.DB) gin.Handler
 			}(),
 		}
 
-		createdMenu, err := menuService.CreateMenu(menu)
+		createdMenu, err := menuService.CreateMenu(c.Request.Context(), menu)
 		if err != nil {
-			log.Printf("Error creating menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create menu"})
+			logger(c).Error("Error creating menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to create menu")
 			return
 		}
 
-		// Invalidate menu cache
-		invalidateErr := database.Cache.Delete(cache.CacheKeyMenuList)
-		if invalidateErr != nil {
-			log.Printf("Warning: Failed to invalidate menu cache: %v", invalidateErr)
-		}
-
-		// Also invalidate menu navigation cache
-		database.Cache.Delete(cache.CacheKeyMenuNavigation)
-
 		// Audit logging
 		logAuditEntry(c, "CREATE", "menu", uint64(createdMenu.ID), nil, req, db)
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Menu created", "data": createdMenu})
+		response.Write(c, http.StatusCreated, response.Body{Data: createdMenu, Message: "Menu created"})
 	}
 }
 
@@ -133,7 +133,7 @@ func updateMenuHandler(menuService services.MenuService, db *sql.DB) gin.Handler
 
 		var req UpdateMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
@@ -154,30 +154,48 @@ func updateMenuHandler(menuService services.MenuService, db *sql.DB) gin.Handler
 			updateData["sort_order"] = *req.SortOrder
 		}
 
-		updatedMenu, err := menuService.UpdateMenu(id, updateData)
+		updatedMenu, err := menuService.UpdateMenu(c.Request.Context(), id, updateData)
 		if err != nil && isNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			utils.RespondError(c, http.StatusNotFound, "Menu not found")
 			return
 		}
 		if err != nil {
-			log.Printf("Error updating menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update menu"})
+			logger(c).Error("Error updating menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to update menu")
 			return
 		}
 
-		// Invalidate menu cache
-		invalidateErr := database.Cache.Delete(cache.CacheKeyMenuList)
-		if invalidateErr != nil {
-			log.Printf("Warning: Failed to invalidate menu cache: %v", invalidateErr)
+		// Audit logging
+		logAuditEntry(c, "UPDATE", "menu", uint64(updatedMenu.ID), nil, req, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: updatedMenu, Message: "Menu updated"})
+	}
+}
+
+// patchMenuHandler PATCH /api/menu/:id
+// Takes a JSON Merge Patch: only the members present change, and null clears url, icon or
+// parent_id.
+func patchMenuHandler(menuService services.MenuService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch models.PatchMenuRequest
+		if !bindMergePatch(c, &patch) {
+			return
+		}
+		changes, err := patch.Changes()
+		if err != nil {
+			utils.HandleError(c, utils.NewValidationError(err.Error()), "patch menu")
+			return
 		}
 
-		// Also invalidate menu navigation cache
-		database.Cache.Delete(cache.CacheKeyMenuNavigation)
+		menu, err := menuService.UpdateMenu(c.Request.Context(), c.Param("id"), changes)
+		if utils.HandleError(c, err, "patch menu") {
+			return
+		}
 
 		// Audit logging
-		logAuditEntry(c, "UPDATE", "menu", uint64(updatedMenu.ID), nil, req, db)
+		logAuditEntry(c, "UPDATE", "menu", uint64(menu.ID), nil, changes, db)
 
-		c.JSON(http.StatusOK, gin.H{"message": "Menu updated", "data": updatedMenu})
+		response.Write(c, http.StatusOK, response.Body{Data: menu, Message: "Menu updated"})
 	}
 }
 
@@ -187,40 +205,70 @@ func deleteMenuHandler(menuService services.MenuService, db *sql.DB) gin.Handler
 		id := c.Param("id")
 
 		// Get the menu for audit logging
-		menu, err := menuService.GetMenu(id)
+		menu, err := menuService.GetMenu(c.Request.Context(), id)
 		if err != nil && isNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			utils.RespondError(c, http.StatusNotFound, "Menu not found")
 			return
 		}
 		if err != nil {
-			log.Printf("Error getting menu for deletion: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete menu"})
+			logger(c).Error("Error getting menu for deletion", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to delete menu")
 			return
 		}
 
 		// Audit logging for DELETE event
 		logAuditEntry(c, "DELETE", "menu", uint64(menu.ID), menu, nil, db)
 
-		err = menuService.DeleteMenu(id)
+		err = menuService.DeleteMenu(c.Request.Context(), id)
 		if err != nil && isNotFoundError(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			utils.RespondError(c, http.StatusNotFound, "Menu not found")
 			return
 		}
 		if err != nil {
-			log.Printf("Error deleting menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete menu"})
+			logger(c).Error("Error deleting menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to delete menu")
 			return
 		}
 
-		// Invalidate menu cache
-		invalidateErr := database.Cache.Delete(cache.CacheKeyMenuList)
-		if invalidateErr != nil {
-			log.Printf("Warning: Failed to invalidate menu cache: %v", invalidateErr)
+		response.Write(c, http.StatusOK, response.Body{Message: "Menu deleted"})
+	}
+}
+
+// applyMenuTreeHandler PUT /api/menu/bulk
+// Takes the whole menu from a menu builder and makes the database match it in one
+// transaction: nodes without an ID are created, the others moved and reordered, and live
+// items the tree leaves out deleted. Answers with the tree as persisted; every item created,
+// changed or deleted gets its own audit entry.
+func applyMenuTreeHandler(menuService services.MenuService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.MenuTreeRequest
+		if !bindJSONRequest(c, &req) {
+			return
 		}
 
-		// Also invalidate menu navigation cache
-		database.Cache.Delete(cache.CacheKeyMenuNavigation)
+		result, err := menuService.ApplyTree(c.Request.Context(), req.Items, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "apply menu tree") {
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Menu deleted"})
+		counts := map[string]int{"created": 0, "updated": 0, "deleted": 0}
+		for _, change := range result.Changes {
+			switch {
+			case change.Before == nil:
+				counts["created"]++
+				logAuditEntry(c, "CREATE", "menu", uint64(change.ID), nil, change.After, db)
+			case change.After == nil:
+				counts["deleted"]++
+				logAuditEntry(c, "DELETE", "menu", uint64(change.ID), change.Before, nil, db)
+			default:
+				counts["updated"]++
+				logAuditEntry(c, "UPDATE", "menu", uint64(change.ID), change.Before, change.After, db)
+			}
+		}
+		response.Write(c, http.StatusOK, response.Body{
+			Data:    result.Tree,
+			Message: "Menu applied",
+			Meta:    response.Meta{"created": counts["created"], "updated": counts["updated"], "deleted": counts["deleted"]},
+		})
 	}
 }
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+	"context"
 	"database/sql"
-	"log"
 	"net/http"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/pkg/cache"
 	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/response"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,41 +18,36 @@ import (
 // listMenuNavigationHandler GET /api/menu_navigation
 func listMenuNavigationHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Try to get from Redis cache first
-		var navigations []models.MenuNavigation
-		err := database.Cache.Get(cache.CacheKeyMenuNavigation, &navigations)
-		if err == nil {
-			// Cache hit
-			c.JSON(http.StatusOK, gin.H{"data": navigations, "cached": true})
-			return
-		}
-
-		// Cache miss - query from DB
-		rows, err := db.Query("SELECT id, label, url, icon, children FROM menu_navigation")
+		// Read through the cache; concurrent misses share a single DB load
+		navigations, cached, err := cache.GetOrLoad(c.Request.Context(), database.Cache, tenant.CacheKey(c.Request.Context(), cache.CacheKeyMenuNavigation), cache.TTLFor(c.Request.Context(), "menu", cache.TTLNavigation), func(ctx context.Context) ([]models.MenuNavigation, error) {
+			return queryMenuNavigation(ctx, db)
+		})
 		if err != nil {
-			log.Printf("Error querying menu_navigation: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menu navigation"})
+			logger(c).Error("Error querying menu_navigation", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve menu navigation")
 			return
 		}
-		defer rows.Close()
-
-		navigations = []models.MenuNavigation{}
-		for rows.Next() {
-			var mn models.MenuNavigation
-			if err := rows.Scan(&mn.ID, &mn.Label, &mn.URL, &mn.Icon, &mn.Children); err != nil {
-				log.Printf("Error scanning menu_navigation row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menu navigation"})
-				return
-			}
-			navigations = append(navigations, mn)
-		}
 
-		// Cache the result
-		cacheErr := database.Cache.Set(cache.CacheKeyMenuNavigation, navigations, cache.DefaultNavigationExpiration)
-		if cacheErr != nil {
-			log.Printf("Warning: Failed to cache menu navigation: %v", cacheErr)
-		}
+		response.Write(c, http.StatusOK, response.Body{Data: navigations, Meta: response.Meta{"cached": cached}})
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{"data": navigations, "cached": false})
+// queryMenuNavigation loads the tenant's menu tree from the menu_navigation view
+func queryMenuNavigation(ctx context.Context, db *sql.DB) ([]models.MenuNavigation, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, label, url, icon, children FROM menu_navigation WHERE tenant_id = ?", tenant.ID(ctx))
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+
+	navigations := []models.MenuNavigation{}
+	for rows.Next() {
+		var mn models.MenuNavigation
+		if err := rows.Scan(&mn.ID, &mn.Label, &mn.URL, &mn.Icon, &mn.Children); err != nil {
+			return nil, err
+		}
+		navigations = append(navigations, mn)
+	}
+
+	return navigations, rows.Err()
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID returns the signed-in user's ID, answering 401 when the token carries none
+func currentUserID(c *gin.Context) (uint64, bool) {
+	userID := getUserIDFromContext(c)
+	if userID == nil {
+		utils.RespondError(c, http.StatusUnauthorized, "Not signed in as a user")
+		return 0, false
+	}
+	return *userID, true
+}
+
+// listNotificationsHandler GET /api/me/notifications
+// The caller's notifications, newest first, ?unread=true for the unread ones only; ?before_id=
+// pages back. meta.unread is the badge count.
+func listNotificationsHandler(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		filter := models.NotificationFilter{Limit: parseIntMinMax(c.Query("limit"), 20, 1, 100)}
+		if v := c.Query("unread"); v != "" {
+			unread, err := strconv.ParseBool(v)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "unread must be true or false")
+				return
+			}
+			filter.Unread = unread
+		}
+		if v := c.Query("before_id"); v != "" {
+			beforeID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			filter.BeforeID = beforeID
+		}
+
+		list, unread, err := notifications.ListNotifications(c.Request.Context(), userID, filter)
+		if utils.HandleError(c, err, "list notifications") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list), "unread": unread}})
+	}
+}
+
+// markNotificationReadHandler POST /api/me/notifications/:id/read
+func markNotificationReadHandler(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		n, err := notifications.MarkRead(c.Request.Context(), userID, c.Param("id"))
+		if utils.HandleError(c, err, "mark notification read") {
+			return
+		}
+		response.OK(c, n)
+	}
+}
+
+// markNotificationsReadHandler POST /api/me/notifications/read
+// Marks the notifications in ids read, or every one with "all": true.
+func markNotificationsReadHandler(notifications services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.MarkNotificationsReadRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		marked, err := notifications.MarkManyRead(c.Request.Context(), userID, req)
+		if utils.HandleError(c, err, "mark notifications read") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: gin.H{"marked": marked}, Message: "Notifications marked read"})
+	}
+}
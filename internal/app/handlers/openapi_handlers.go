@@ -0,0 +1,1136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/buildinfo"
+	"adminbe/internal/pkg/deprecation"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/maintenance"
+	"adminbe/internal/pkg/openapi"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/tracing"
+	"adminbe/internal/pkg/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// specBuilder declares operations on an OpenAPI document
+type specBuilder struct {
+	*openapi.Document
+}
+
+// public marks an operation that needs no token
+var public = []map[string][]string{{}}
+
+// add declares method on route. Every operation can answer with the error envelope; routes
+// behind the token also declare 401.
+func (s specBuilder) add(method, route, tag, summary string, op openapi.Operation) {
+	op.Summary = summary
+	op.Tags = []string{tag}
+	if op.Responses == nil {
+		op.Responses = map[string]openapi.Response{}
+	}
+	if op.Security == nil {
+		s.errors(op.Responses, http.StatusUnauthorized)
+	}
+	if _, ok := op.Responses["default"]; !ok {
+		op.Responses["default"] = openapi.Response{Description: "Error", Content: s.errorContent()}
+	}
+	if _, ok := deprecation.Lookup(method, route); ok {
+		op.Deprecated = true
+	}
+	s.Add(method, route, op)
+}
+
+// ok returns the responses of an operation answering status with data v in the envelope,
+// and the error envelope for errs
+func (s specBuilder) ok(status int, v any, errs ...int) map[string]openapi.Response {
+	responses := map[string]openapi.Response{
+		strconv.Itoa(status): {Description: http.StatusText(status), Content: s.jsonContent(s.Envelope(v))},
+	}
+	s.errors(responses, errs...)
+	return responses
+}
+
+// withCSV adds the CSV a list answers with for Accept: text/csv to its 200 response
+func (s specBuilder) withCSV(responses map[string]openapi.Response) map[string]openapi.Response {
+	ok := responses["200"]
+	ok.Content[contentTypeCSV] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Description: "Every matching row; ?fields= selects the columns"}}
+	responses["200"] = ok
+	return responses
+}
+
+// importReport returns the responses of an import: its report v as JSON or, for
+// Accept: text/csv, as CSV, for a dry run (200), a commit (201) and invalid rows (422)
+func (s specBuilder) importReport(v any, errs ...int) map[string]openapi.Response {
+	responses := map[string]openapi.Response{}
+	for status, description := range map[int]string{
+		http.StatusOK:                  "Every row is valid, but nothing was written: a dry run, or nothing to change",
+		http.StatusCreated:             "The file was imported",
+		http.StatusUnprocessableEntity: "Some rows are invalid; nothing was written",
+	} {
+		content := s.jsonContent(s.Envelope(v))
+		content[contentTypeCSV] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Description: "The report, a row per row of the file"}}
+		responses[strconv.Itoa(status)] = openapi.Response{Description: description, Content: content}
+	}
+	s.errors(responses, errs...)
+	return responses
+}
+
+// raw returns the responses of an operation answering 200 with a body outside the envelope
+func (s specBuilder) raw(contentType string, schema *openapi.Schema, errs ...int) map[string]openapi.Response {
+	responses := map[string]openapi.Response{
+		"200": {Description: "OK", Content: map[string]openapi.MediaType{contentType: {Schema: schema}}},
+	}
+	s.errors(responses, errs...)
+	return responses
+}
+
+func (s specBuilder) errors(responses map[string]openapi.Response, errs ...int) {
+	for _, status := range errs {
+		responses[strconv.Itoa(status)] = openapi.Response{
+			Description: http.StatusText(status),
+			Content:     s.errorContent(),
+		}
+	}
+}
+
+// scim returns the responses of a SCIM operation answering status with v (nil for no body),
+// and SCIM errors for errs and by default
+func (s specBuilder) scim(status int, v any, errs ...int) map[string]openapi.Response {
+	scimError := map[string]openapi.MediaType{scimContentType: {Schema: s.Schema(models.SCIMError{})}}
+	responses := map[string]openapi.Response{
+		strconv.Itoa(status): {Description: http.StatusText(status)},
+		"401":                {Description: http.StatusText(http.StatusUnauthorized), Content: scimError},
+		"default":            {Description: "Error", Content: scimError},
+	}
+	if v != nil {
+		responses[strconv.Itoa(status)] = openapi.Response{
+			Description: http.StatusText(status),
+			Content:     map[string]openapi.MediaType{scimContentType: {Schema: s.Schema(v)}},
+		}
+	}
+	for _, e := range errs {
+		responses[strconv.Itoa(e)] = openapi.Response{Description: http.StatusText(e), Content: scimError}
+	}
+	return responses
+}
+
+// scimBody returns a required SCIM request body of v's type
+func (s specBuilder) scimBody(v any) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{scimContentType: {Schema: s.Schema(v)}}}
+}
+
+// body returns a required JSON request body of v's type
+func (s specBuilder) body(v any) *openapi.RequestBody {
+	return &openapi.RequestBody{Required: true, Content: s.jsonContent(s.Schema(v))}
+}
+
+// mergePatch returns a required JSON Merge Patch body of v's type
+func (s specBuilder) mergePatch(v any) *openapi.RequestBody {
+	schema := s.Schema(v)
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		MIMEMergePatch:     {Schema: schema},
+		"application/json": {Schema: schema},
+	}}
+}
+
+// form returns a required form-encoded body with the given string fields
+func form(fields ...string) *openapi.RequestBody {
+	schema := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	for _, f := range fields {
+		schema.Properties[f] = &openapi.Schema{Type: "string"}
+	}
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		"application/x-www-form-urlencoded": {Schema: schema},
+	}}
+}
+
+// upload returns a required multipart body with a binary "file" part and the given string
+// parts
+func upload(fields ...string) *openapi.RequestBody {
+	schema := &openapi.Schema{Type: "object", Required: []string{"file"}, Properties: map[string]*openapi.Schema{
+		"file": {Type: "string", Format: "binary"},
+	}}
+	for _, f := range fields {
+		schema.Properties[f] = &openapi.Schema{Type: "string"}
+	}
+	return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		"multipart/form-data": {Schema: schema},
+	}}
+}
+
+func (s specBuilder) jsonContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+// errorContent is the content of an error response: the error envelope, or problem details
+// for clients accepting application/problem+json
+func (s specBuilder) errorContent() map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{
+		"application/json":          {Schema: &openapi.Schema{Ref: "#/components/schemas/Error"}},
+		response.ContentTypeProblem: {Schema: &openapi.Schema{Ref: "#/components/schemas/Problem"}},
+	}
+}
+
+// query returns an optional query parameter
+func query(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+// header returns an optional request header
+func header(name, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "header", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+var (
+	pageParams = []openapi.Parameter{
+		query("page", "integer", "Page number, from 1"),
+		query("limit", "integer", "Items per page"),
+		query("cursor", "string", "Keyset pagination: next_cursor of the previous page; excludes page and sort"),
+	}
+	listParams = []openapi.Parameter{
+		query("sort", "string", "Comma-separated fields, - for descending, e.g. -created_at,username"),
+		query("fields", "string", "Comma-separated fields to return"),
+	}
+	conditionalParams = []openapi.Parameter{
+		header("If-None-Match", "ETag of a previous response; 304 while unchanged"),
+	}
+	idempotencyParams = []openapi.Parameter{
+		header("Idempotency-Key", "Replays the first response of a retried request (up to 255 characters)"),
+	}
+	challengeParams = []openapi.Parameter{
+		header("X-Challenge-Response", "Answer to the challenge of a 403 CHALLENGE_REQUIRED: \"<token>:<counter>\" for pow, the reCAPTCHA token otherwise"),
+	}
+)
+
+func params(groups ...[]openapi.Parameter) []openapi.Parameter {
+	var out []openapi.Parameter
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// specErrorSchema is the version 2 error body
+var specErrorSchema = &openapi.Schema{
+	Type:     "object",
+	Required: []string{"error", "meta"},
+	Properties: map[string]*openapi.Schema{
+		"error": {
+			Type:     "object",
+			Required: []string{"code", "message"},
+			Properties: map[string]*openapi.Schema{
+				"code":    {Type: "string", Description: "Machine-readable, e.g. NOT_FOUND or VALIDATION_ERROR"},
+				"message": {Type: "string"},
+				"details": {Type: "object", Description: "e.g. fields, the rejected fields of a VALIDATION_ERROR"},
+			},
+		},
+		"meta": {Type: "object", Properties: map[string]*openapi.Schema{"request_id": {Type: "string"}}},
+	},
+}
+
+// specProblemSchema is the RFC 7807 error body
+var specProblemSchema = &openapi.Schema{
+	Type:     "object",
+	Required: []string{"type", "title", "status", "code"},
+	Properties: map[string]*openapi.Schema{
+		"type":     {Type: "string", Description: "about:blank, or PROBLEM_TYPE_BASE followed by the code, e.g. not-found"},
+		"title":    {Type: "string", Description: "The status text, e.g. Not Found"},
+		"status":   {Type: "integer"},
+		"detail":   {Type: "string"},
+		"instance": {Type: "string", Description: "Path of the request that failed"},
+		"code":     {Type: "string", Description: "Machine-readable, e.g. NOT_FOUND or VALIDATION_ERROR"},
+		"trace_id": {Type: "string", Description: "The request ID, as in X-Request-ID"},
+	},
+}
+
+var (
+	apiSpecOnce sync.Once
+	apiSpec     *openapi.Document
+)
+
+// APISpec returns the OpenAPI document of every route SetupRoutes registers. Add an
+// operation here with each new route; `go run ./cmd/openapi -check` fails CI otherwise.
+func APISpec() *openapi.Document {
+	apiSpecOnce.Do(func() { apiSpec = buildAPISpec() })
+	return apiSpec
+}
+
+func buildAPISpec() *openapi.Document {
+	s := specBuilder{openapi.New(openapi.Info{
+		Title:   "adminbe",
+		Version: buildinfo.Version,
+		Description: "Admin backend: users, roles, menus and their permissions, audit logs, " +
+			"JasperServer reports and prayer schedules. Bodies use the version 2 envelope; send " +
+			"Accept-Version: 1 for the earlier per-endpoint shapes, or Accept: application/problem+json " +
+			"for RFC 7807 errors. Messages are in English, or Indonesian with ?lang=id or " +
+			"Accept-Language: id.",
+	})}
+	s.Pattern(validation.TagUsername, validation.UsernamePattern)
+	s.Pattern(validation.TagPhoneID, validation.PhoneIDPattern)
+	s.Components.SecuritySchemes["bearerAuth"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	// Browsers signed in with "cookie": true (COOKIE_AUTH_ENABLED) send the session cookie instead
+	s.Components.SecuritySchemes["cookieAuth"] = openapi.SecurityScheme{Type: "apiKey", In: "cookie", Name: "adminbe_session",
+		Description: "COOKIE_AUTH_NAME; POST, PUT, PATCH and DELETE also need the CSRF cookie's value in X-CSRF-Token"}
+	s.Security = []map[string][]string{{"bearerAuth": {}}, {"cookieAuth": {}}}
+	// SCIM clients send the SCIM_TOKEN shared with the identity provider
+	s.Components.SecuritySchemes["scimToken"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer"}
+	// Sibling services send their key of AUDIT_INGEST_KEYS
+	s.Components.SecuritySchemes["auditIngestKey"] = openapi.SecurityScheme{Type: "apiKey", In: "header", Name: auditIngestKeyHeader}
+	s.Components.Schemas["Error"] = specErrorSchema
+	s.Components.Schemas["Problem"] = specProblemSchema
+
+	const (
+		get, post, put, patch, del = http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete
+		bad, notFound, conflict    = http.StatusBadRequest, http.StatusNotFound, http.StatusConflict
+		forbidden, unsupported     = http.StatusForbidden, http.StatusUnsupportedMediaType
+	)
+
+	// Service
+	s.add(get, "/ping", "Service", "Liveness check answering pong", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}),
+	})
+	s.add(get, "/health", "Service", "Database, Redis and connection pool health", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}),
+	})
+	s.add(get, "/health/live", "Service", "Kubernetes liveness probe", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}),
+	})
+	s.add(get, "/health/ready", "Service", "Kubernetes readiness probe: every required dependency is up", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/status", "Service", "Public status page summary", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}),
+	})
+	s.add(get, "/api/branding", "Service", "Application name, logo, colour and support address, as the request's tenant brands them", openapi.Operation{
+		Security: public, Responses: s.ok(http.StatusOK, models.Branding{}),
+	})
+	s.add(get, "/metrics", "Service", "Prometheus metrics", openapi.Operation{
+		Security: public, Responses: s.raw("text/plain", &openapi.Schema{Type: "string"}),
+	})
+	s.add(get, "/openapi.json", "Service", "This document", openapi.Operation{
+		Security: public, Responses: s.raw("application/json", &openapi.Schema{Type: "object"}),
+	})
+	s.add(get, "/docs", "Service", "Swagger UI for this document", openapi.Operation{
+		Security: public, Responses: s.raw("text/html", &openapi.Schema{Type: "string"}),
+	})
+	s.add(get, "/ws", "Service", "WebSocket of the signed-in user's notifications", openapi.Operation{
+		Description: "Each text message is a notification: {id, type, message, data, time}, with type " +
+			"role_granted, report_finished or user_locked; the first two are also kept under /api/me/notifications, with the same id. Without an Authorization header, send " +
+			`{"type": "auth", "token": "<jwt>"} first. Closed with code 4401 when the token is invalid or expires.`,
+		Security: []map[string][]string{{}, {"bearerAuth": {}}},
+		Responses: map[string]openapi.Response{
+			"101": {Description: http.StatusText(http.StatusSwitchingProtocols)},
+			"401": {Description: http.StatusText(http.StatusUnauthorized), Content: s.errorContent()},
+		},
+	})
+	s.add(get, "/debug/pprof/*name", "Service", "Runtime profiles (ops role, pprof feature)", openapi.Operation{
+		Responses: s.raw("application/octet-stream", &openapi.Schema{Type: "string", Format: "binary"}, forbidden, notFound),
+	})
+	s.add(post, "/debug/pprof/*name", "Service", "Symbol lookup for runtime profiles (ops role, pprof feature)", openapi.Operation{
+		Responses: s.raw("text/plain", &openapi.Schema{Type: "string"}, forbidden, notFound),
+	})
+
+	// SCIM 2.0 provisioning (RFC 7644)
+	scimAuth := []map[string][]string{{"scimToken": {}}}
+	scimPage := []openapi.Parameter{
+		query("startIndex", "integer", "1-based index of the first result"),
+		query("count", "integer", "Results per page, at most 200"),
+	}
+	s.add(get, "/scim/v2/ServiceProviderConfig", "SCIM", "Features of this SCIM service provider", openapi.Operation{
+		Security: scimAuth, Responses: s.scim(http.StatusOK, map[string]any{}),
+	})
+	s.add(get, "/scim/v2/Users", "SCIM", "List users", openapi.Operation{
+		Security:   scimAuth,
+		Parameters: params(scimPage, []openapi.Parameter{query("filter", "string", `userName eq "<name>"`)}),
+		Responses:  s.scim(http.StatusOK, models.SCIMListResponse{}, bad),
+	})
+	s.add(get, "/scim/v2/Users/:id", "SCIM", "Get a user", openapi.Operation{
+		Security: scimAuth, Responses: s.scim(http.StatusOK, models.SCIMUser{}, notFound),
+	})
+	s.add(post, "/scim/v2/Users", "SCIM", "Provision a user", openapi.Operation{
+		Description: "userName must be a valid username. Without a password the user gets a random one.",
+		Security:    scimAuth, RequestBody: s.scimBody(models.SCIMUser{}),
+		Responses: s.scim(http.StatusCreated, models.SCIMUser{}, bad, conflict),
+	})
+	s.add(put, "/scim/v2/Users/:id", "SCIM", "Replace a user", openapi.Operation{
+		Security: scimAuth, RequestBody: s.scimBody(models.SCIMUser{}),
+		Responses: s.scim(http.StatusOK, models.SCIMUser{}, bad, notFound, conflict),
+	})
+	s.add(patch, "/scim/v2/Users/:id", "SCIM", "Change a user's userName, emails, active or password", openapi.Operation{
+		Security: scimAuth, RequestBody: s.scimBody(models.SCIMPatchRequest{}),
+		Responses: s.scim(http.StatusOK, models.SCIMUser{}, bad, notFound, conflict),
+	})
+	s.add(del, "/scim/v2/Users/:id", "SCIM", "Deprovision a user", openapi.Operation{
+		Security: scimAuth, Responses: s.scim(http.StatusNoContent, nil, notFound),
+	})
+	s.add(get, "/scim/v2/Groups", "SCIM", "List groups (roles)", openapi.Operation{
+		Security: scimAuth,
+		Parameters: params(scimPage, []openapi.Parameter{
+			query("filter", "string", `displayName eq "<name>"`),
+			query("excludedAttributes", "string", "members leaves the members out"),
+		}),
+		Responses: s.scim(http.StatusOK, models.SCIMListResponse{}, bad),
+	})
+	s.add(get, "/scim/v2/Groups/:id", "SCIM", "Get a group with its members", openapi.Operation{
+		Security: scimAuth, Responses: s.scim(http.StatusOK, models.SCIMGroup{}, notFound),
+	})
+	s.add(post, "/scim/v2/Groups", "SCIM", "Create a role with its members", openapi.Operation{
+		Security: scimAuth, RequestBody: s.scimBody(models.SCIMGroup{}),
+		Responses: s.scim(http.StatusCreated, models.SCIMGroup{}, bad, conflict),
+	})
+	s.add(put, "/scim/v2/Groups/:id", "SCIM", "Replace a role's name and members", openapi.Operation{
+		Security: scimAuth, RequestBody: s.scimBody(models.SCIMGroup{}),
+		Responses: s.scim(http.StatusOK, models.SCIMGroup{}, bad, notFound, conflict),
+	})
+	s.add(patch, "/scim/v2/Groups/:id", "SCIM", "Rename a role or add and remove members", openapi.Operation{
+		Security: scimAuth, RequestBody: s.scimBody(models.SCIMPatchRequest{}),
+		Responses: s.scim(http.StatusOK, models.SCIMGroup{}, bad, notFound, conflict),
+	})
+	s.add(del, "/scim/v2/Groups/:id", "SCIM", "Delete a role", openapi.Operation{
+		Security: scimAuth, Responses: s.scim(http.StatusNoContent, nil, notFound),
+	})
+
+	// Auth
+	s.add(post, "/api/auth/login", "Auth", "Exchange email and password for a JWT; with two-factor sign-in on, a challenge for /api/auth/2fa (two_factor_required) instead", openapi.Operation{
+		Security: public, Parameters: challengeParams, RequestBody: s.body(LoginRequest{}),
+		Responses: s.ok(http.StatusOK, map[string]any{}, bad, forbidden, http.StatusTooManyRequests),
+	})
+	s.add(post, "/api/auth/2fa", "Auth", "Complete a sign-in with the challenge from /api/auth/login and the code texted", openapi.Operation{
+		Security: public, RequestBody: s.body(models.TwoFactorLoginRequest{}),
+		Responses: s.ok(http.StatusOK, map[string]any{}, bad, http.StatusUnauthorized, forbidden, http.StatusTooManyRequests),
+	})
+	s.add(post, "/api/auth/password/forgot", "Auth", "Text a reset code to the verified number of an account; the answer is the same whether or not there is one", openapi.Operation{
+		Security: public, Parameters: challengeParams, RequestBody: s.body(models.ForgotPasswordRequest{}),
+		Responses: s.ok(http.StatusOK, models.OTPChallenge{}, bad, http.StatusTooManyRequests),
+	})
+	s.add(post, "/api/auth/password/reset", "Auth", "Set a new password with the challenge from /api/auth/password/forgot and the code texted", openapi.Operation{
+		Security: public, RequestBody: s.body(models.ResetPasswordRequest{}),
+		Responses: s.ok(http.StatusOK, nil, bad, http.StatusTooManyRequests),
+	})
+	s.add(get, "/api/auth/csrf", "Auth", "A new CSRF token for the cookie session, also set as the CSRF cookie", openapi.Operation{
+		Responses: s.ok(http.StatusOK, map[string]any{}),
+	})
+	s.add(post, "/api/auth/logout", "Auth", "End the cookie session; bearer tokens stay valid until they expire", openapi.Operation{
+		Responses: map[string]openapi.Response{"204": {Description: "Signed out"}},
+	})
+
+	// Users
+	s.add(get, "/api/users", "Users", "List users", openapi.Operation{
+		Parameters: params(pageParams, listParams), Responses: s.withCSV(s.ok(http.StatusOK, []models.User{}, bad)),
+	})
+	s.add(get, "/api/users/export", "Users", "Stream every active user as NDJSON or a JSON array", openapi.Operation{
+		Parameters: []openapi.Parameter{query("format", "string", "ndjson (default) or json")},
+		Responses: map[string]openapi.Response{"200": {Description: "OK", Content: map[string]openapi.MediaType{
+			"application/x-ndjson": {Schema: s.Schema(models.User{})},
+			"application/json":     {Schema: s.Schema([]models.User{})},
+		}}},
+	})
+	s.add(post, "/api/users/import", "Users", "Create users from a CSV or XLSX file, all or nothing, with a per-row report", openapi.Operation{
+		Parameters:  []openapi.Parameter{query("dry_run", "boolean", "Only validate the file")},
+		RequestBody: upload(),
+		Responses:   s.importReport(models.UserImportResult{}, bad, forbidden, conflict, http.StatusRequestEntityTooLarge, unsupported, http.StatusTooManyRequests),
+	})
+	s.add(get, "/api/users/:id", "Users", "Get a user", openapi.Operation{
+		Parameters: conditionalParams, Responses: s.ok(http.StatusOK, models.User{}, notFound),
+	})
+	s.add(post, "/api/users", "Users", "Create a user, optionally with roles", openapi.Operation{
+		Parameters: idempotencyParams, RequestBody: s.body(models.CreateUserRequest{}),
+		Responses: s.ok(http.StatusCreated, models.User{}, bad, conflict, http.StatusUnprocessableEntity),
+	})
+	s.add(put, "/api/users/:id", "Users", "Update a user", openapi.Operation{
+		RequestBody: s.body(models.UpdateUserRequest{}), Responses: s.ok(http.StatusOK, models.User{}, bad, notFound, conflict),
+	})
+	s.add(patch, "/api/users/:id", "Users", "Change only the given fields of a user", openapi.Operation{
+		RequestBody: s.mergePatch(models.PatchUserRequest{}), Responses: s.ok(http.StatusOK, models.User{}, bad, notFound, conflict, unsupported),
+	})
+	s.add(del, "/api/users/:id", "Users", "Delete a user", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+	s.add(post, "/api/users/force-password-reset", "Users", "Make the users with a role, or not signed in since a date, change their password at their next sign-in", openapi.Operation{
+		RequestBody: s.body(models.ForcePasswordResetRequest{}), Responses: s.ok(http.StatusOK, []models.User{}, bad, forbidden),
+	})
+	s.add(post, "/api/users/bulk-delete", "Users", "Delete many users in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(post, "/api/users/bulk-restore", "Users", "Restore many deleted users in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(put, "/api/users/:id/avatar", "Users", "Replace a user's avatar (PNG, JPEG, GIF or WebP); users change their own", openapi.Operation{
+		RequestBody: upload(), Responses: s.ok(http.StatusOK, models.Attachment{}, bad, forbidden, http.StatusRequestEntityTooLarge, unsupported, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/api/users/:id/avatar", "Users", "Redirect to a signed download URL of a user's avatar", openapi.Operation{
+		Responses: func() map[string]openapi.Response {
+			responses := map[string]openapi.Response{"302": {Description: "Found; Location is the signed URL"}}
+			s.errors(responses, bad, notFound)
+			return responses
+		}(),
+	})
+	s.add(del, "/api/users/:id/avatar", "Users", "Remove a user's avatar", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, bad, forbidden, notFound),
+	})
+
+	s.add(del, "/api/users/:id/2fa", "Users", "Turn a user's two-factor sign-in off, e.g. when they lost their phone", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.UserPhone{}, bad, forbidden, notFound),
+	})
+
+	// Files
+	s.add(post, "/api/files", "Files", "Upload a file, e.g. for a report to take as \"attachment:<id>\"", openapi.Operation{
+		RequestBody: upload("purpose"),
+		Responses:   s.ok(http.StatusCreated, models.Attachment{}, bad, http.StatusRequestEntityTooLarge, unsupported, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/api/files", "Files", "The caller's files, newest first; administrators see everyone's", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("purpose", "string", "avatar or report_parameter"), query("owner_id", "integer", "Administrators: one user's files"),
+			query("before_id", "integer", "Only files older than this one"), query("limit", "integer", "At most this many (1-1000, default 100)"),
+		},
+		Responses: s.ok(http.StatusOK, []models.Attachment{}, bad),
+	})
+	s.add(get, "/api/files/:id", "Files", "A file's metadata with a fresh signed download URL", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.Attachment{}, bad, notFound),
+	})
+	s.add(del, "/api/files/:id", "Files", "Delete a file", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(get, "/api/files/:id/content", "Files", "Download a file through a signed URL; needs no token", openapi.Operation{
+		Security: public,
+		Parameters: []openapi.Parameter{
+			query("expires", "integer", "Unix time the URL expires at"), query("signature", "string", "Signature of the URL"),
+		},
+		Responses: s.raw("application/octet-stream", &openapi.Schema{Type: "string", Format: "binary"}, notFound),
+	})
+	s.add(get, "/api/calendar/oauth/callback", "Admin", "Google's redirect after the consent page of /api/admin/calendar/authorize; needs no token", openapi.Operation{
+		Security: public,
+		Parameters: []openapi.Parameter{
+			query("state", "string", "The single-use state of the authorization"), query("code", "string", "Google's authorization code"),
+			query("error", "string", "Why Google granted no code"),
+		},
+		Responses: s.ok(http.StatusOK, models.CalendarStatus{}, bad, http.StatusBadGateway),
+	})
+
+	// Export jobs
+	s.add(post, "/api/jobs", "Export jobs", "Queue an export (audit_logs or prayer_schedules) to run in the background", openapi.Operation{
+		RequestBody: s.body(models.CreateExportJobRequest{}), Responses: s.ok(http.StatusAccepted, models.ExportJob{}, bad),
+	})
+	s.add(get, "/api/jobs", "Export jobs", "The caller's export jobs, newest first; administrators see everyone's", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("created_by", "integer", "Administrators: one user's jobs"),
+			query("before_id", "integer", "Only jobs older than this one"), query("limit", "integer", "At most this many (1-1000, default 100)"),
+		},
+		Responses: s.ok(http.StatusOK, []models.ExportJob{}, bad),
+	})
+	s.add(get, "/api/jobs/:id", "Export jobs", "An export job's status and progress, with its file once it succeeded", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.ExportJob{}, bad, notFound),
+	})
+	s.add(get, "/api/jobs/:id/download", "Export jobs", "Redirect to a signed download URL of a finished export", openapi.Operation{
+		Responses: func() map[string]openapi.Response {
+			responses := map[string]openapi.Response{"302": {Description: "Found; Location is the signed URL"}}
+			s.errors(responses, bad, notFound, conflict)
+			return responses
+		}(),
+	})
+
+	// Roles
+	s.add(get, "/api/roles", "Roles", "List roles", openapi.Operation{
+		Parameters: listParams, Responses: s.withCSV(s.ok(http.StatusOK, []models.Role{}, bad)),
+	})
+	s.add(get, "/api/roles/:id", "Roles", "Get a role", openapi.Operation{
+		Parameters: conditionalParams, Responses: s.ok(http.StatusOK, models.Role{}, notFound),
+	})
+	s.add(post, "/api/roles", "Roles", "Create a role", openapi.Operation{
+		RequestBody: s.body(models.CreateRoleRequest{}), Responses: s.ok(http.StatusCreated, models.Role{}, bad, conflict),
+	})
+	s.add(put, "/api/roles/:id", "Roles", "Update a role", openapi.Operation{
+		RequestBody: s.body(models.UpdateRoleRequest{}), Responses: s.ok(http.StatusOK, nil, bad, notFound, conflict),
+	})
+	s.add(patch, "/api/roles/:id", "Roles", "Change only the given fields of a role", openapi.Operation{
+		RequestBody: s.mergePatch(models.PatchRoleRequest{}), Responses: s.ok(http.StatusOK, models.Role{}, bad, notFound, conflict, unsupported),
+	})
+	s.add(del, "/api/roles/:id", "Roles", "Delete a role", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+	s.add(post, "/api/roles/bulk-delete", "Roles", "Delete many roles in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(post, "/api/roles/bulk-restore", "Roles", "Restore many deleted roles in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(get, "/api/v_roles", "Roles", "Flattened role hierarchy", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.VRole{}),
+	})
+
+	// Role inheritances
+	s.add(get, "/api/role_inheritances", "Role Inheritances", "List role inheritances", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.RoleInheritance{}),
+	})
+	s.add(get, "/api/role_inheritances/:id", "Role Inheritances", "Get a role inheritance", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.RoleInheritance{}, notFound),
+	})
+	s.add(post, "/api/role_inheritances", "Role Inheritances", "Make a role inherit another", openapi.Operation{
+		RequestBody: s.body(models.CreateRoleInheritanceRequest{}), Responses: s.ok(http.StatusCreated, models.RoleInheritance{}, bad, conflict),
+	})
+	s.add(put, "/api/role_inheritances/:id", "Role Inheritances", "Update a role inheritance", openapi.Operation{
+		RequestBody: s.body(models.UpdateRoleInheritanceRequest{}), Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(del, "/api/role_inheritances/:id", "Role Inheritances", "Delete a role inheritance", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+
+	// Menu
+	s.add(get, "/api/menu", "Menu", "List menu items", openapi.Operation{
+		Parameters: listParams, Responses: s.ok(http.StatusOK, []models.Menu{}, bad),
+	})
+	s.add(get, "/api/menu/:id", "Menu", "Get a menu item", openapi.Operation{
+		Parameters: conditionalParams, Responses: s.ok(http.StatusOK, models.Menu{}, notFound),
+	})
+	s.add(post, "/api/menu", "Menu", "Create a menu item", openapi.Operation{
+		RequestBody: s.body(CreateMenuRequest{}), Responses: s.ok(http.StatusCreated, models.Menu{}, bad),
+	})
+	s.add(put, "/api/menu/:id", "Menu", "Update a menu item", openapi.Operation{
+		RequestBody: s.body(UpdateMenuRequest{}), Responses: s.ok(http.StatusOK, models.Menu{}, bad, notFound),
+	})
+	s.add(patch, "/api/menu/:id", "Menu", "Change only the given fields of a menu item", openapi.Operation{
+		RequestBody: s.mergePatch(models.PatchMenuRequest{}), Responses: s.ok(http.StatusOK, models.Menu{}, bad, notFound, unsupported),
+	})
+	s.add(del, "/api/menu/:id", "Menu", "Delete a menu item", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+	s.add(post, "/api/menu/bulk-delete", "Menu", "Delete many menu items in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(post, "/api/menu/bulk-restore", "Menu", "Restore many deleted menu items in one transaction, with the outcome of each ID", openapi.Operation{
+		RequestBody: s.body(models.BulkIDsRequest{}), Responses: s.ok(http.StatusOK, []models.BulkOutcome{}, bad, forbidden),
+	})
+	s.add(put, "/api/menu/bulk", "Menu", "Make the menu match a whole tree in one transaction: create, move, reorder and delete items", openapi.Operation{
+		RequestBody: s.body(models.MenuTreeRequest{}), Responses: s.ok(http.StatusOK, []models.MenuNode{}, bad, forbidden),
+	})
+	s.add(get, "/api/menu_navigation", "Menu", "Menu hierarchy tree", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.MenuNavigation{}),
+	})
+
+	// Permissions
+	s.add(get, "/api/role_menu", "Role-Menu", "List role-menu assignments", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.RoleMenu{}),
+	})
+	s.add(get, "/api/role_menu/:roleId/:menuId", "Role-Menu", "Get a role-menu assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.RoleMenu{}, notFound),
+	})
+	s.add(post, "/api/role_menu", "Role-Menu", "Give a role a menu item", openapi.Operation{
+		RequestBody: s.body(models.CreateRoleMenuRequest{}), Responses: s.ok(http.StatusCreated, nil, bad, conflict),
+	})
+	s.add(put, "/api/role_menu/:roleId/:menuId", "Role-Menu", "Update a role-menu assignment", openapi.Operation{
+		RequestBody: s.body(models.UpdateRoleMenuRequest{}), Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(del, "/api/role_menu/:roleId/:menuId", "Role-Menu", "Delete a role-menu assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+	s.add(get, "/api/user_roles", "User-Roles", "List user-role assignments", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.UserRole{}),
+	})
+	s.add(get, "/api/user_roles/:userId/:roleId", "User-Roles", "Get a user-role assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.UserRole{}, notFound),
+	})
+	s.add(post, "/api/user_roles", "User-Roles", "Give a user a role", openapi.Operation{
+		RequestBody: s.body(models.CreateUserRoleRequest{}), Responses: s.ok(http.StatusCreated, nil, bad, conflict),
+	})
+	s.add(put, "/api/user_roles/:userId/:roleId", "User-Roles", "Update a user-role assignment", openapi.Operation{
+		RequestBody: s.body(models.UpdateUserRoleRequest{}), Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(del, "/api/user_roles/:userId/:roleId", "User-Roles", "Delete a user-role assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+	s.add(get, "/api/user_menu", "User-Menu", "List user-menu assignments", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.UserMenu{}),
+	})
+	s.add(get, "/api/user_menu/:userId/:menuId", "User-Menu", "Get a user-menu assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.UserMenu{}, notFound),
+	})
+	s.add(post, "/api/user_menu", "User-Menu", "Give a user a menu item", openapi.Operation{
+		RequestBody: s.body(models.CreateUserMenuRequest{}), Responses: s.ok(http.StatusCreated, nil, bad, conflict),
+	})
+	s.add(put, "/api/user_menu/:userId/:menuId", "User-Menu", "Update a user-menu assignment", openapi.Operation{
+		RequestBody: s.body(models.UpdateUserMenuRequest{}), Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(del, "/api/user_menu/:userId/:menuId", "User-Menu", "Delete a user-menu assignment", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+
+	// Audit logs
+	s.add(get, "/api/audit_logs", "Audit Logs", "List audit log entries", openapi.Operation{
+		Parameters: params(pageParams, listParams), Responses: s.withCSV(s.ok(http.StatusOK, []models.AuditLog{}, bad)),
+	})
+	s.add(get, "/api/audit_logs/export", "Audit Logs", "Stream the whole audit trail as NDJSON or a JSON array", openapi.Operation{
+		Parameters: []openapi.Parameter{query("format", "string", "ndjson (default) or json")},
+		Responses: map[string]openapi.Response{"200": {Description: "OK", Content: map[string]openapi.MediaType{
+			"application/x-ndjson": {Schema: s.Schema(models.AuditLog{})},
+			"application/json":     {Schema: s.Schema([]models.AuditLog{})},
+		}}},
+	})
+	s.add(get, "/api/audit_logs/:id", "Audit Logs", "Get an audit log entry", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.AuditLog{}, notFound),
+	})
+	s.add(post, "/api/audit_logs", "Audit Logs", "Write an audit log entry", openapi.Operation{
+		RequestBody: &openapi.RequestBody{Required: true, Content: s.jsonContent(&openapi.Schema{Type: "object"})},
+		Responses:   s.ok(http.StatusCreated, nil, bad),
+	})
+	s.add(post, "/api/audit_logs/batch", "Audit Logs", "Queue audit entries from a sibling service, authenticated by its AUDIT_INGEST_KEYS key", openapi.Operation{
+		Security:    []map[string][]string{{"auditIngestKey": {}}},
+		RequestBody: s.body(models.AuditIngestRequest{}), Responses: s.ok(http.StatusAccepted, nil, bad, http.StatusServiceUnavailable),
+	})
+	s.add(put, "/api/audit_logs/:id", "Audit Logs", "Update an audit log entry", openapi.Operation{
+		RequestBody: &openapi.RequestBody{Required: true, Content: s.jsonContent(&openapi.Schema{Type: "object"})},
+		Responses:   s.ok(http.StatusOK, nil, bad, notFound),
+	})
+	s.add(del, "/api/audit_logs/:id", "Audit Logs", "Delete an audit log entry", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, notFound),
+	})
+
+	// Reports
+	s.add(post, "/api/reports/run", "Reports", "Run a JasperServer report", openapi.Operation{
+		Parameters: idempotencyParams, RequestBody: s.body(models.JasperReportRequest{}),
+		Responses: map[string]openapi.Response{"200": {Description: "The report, or its execution for interactive runs", Content: map[string]openapi.MediaType{
+			"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			"application/json":         {Schema: s.Envelope(models.JasperReportResponse{})},
+		}}},
+	})
+	s.add(get, "/api/reports/server-info", "Reports", "JasperServer version and edition", openapi.Operation{
+		Responses: s.ok(http.StatusOK, map[string]any{}, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/api/reports/health", "Reports", "Whether JasperServer answers", openapi.Operation{
+		Responses: s.ok(http.StatusOK, map[string]any{}, http.StatusServiceUnavailable),
+	})
+	s.add(post, "/api/reports/schedules", "Reports", "Schedule a report; each run keeps the file with the caller's attachments", openapi.Operation{
+		RequestBody: s.body(models.ReportScheduleRequest{}), Responses: s.ok(http.StatusCreated, models.ReportSchedule{}, bad),
+	})
+	s.add(get, "/api/reports/schedules", "Reports", "The caller's report schedules, newest first; administrators see everyone's", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("owner_id", "integer", "Administrators: one user's schedules"),
+			query("before_id", "integer", "Only schedules older than this one"), query("limit", "integer", "At most this many (1-1000, default 100)"),
+		},
+		Responses: s.ok(http.StatusOK, []models.ReportSchedule{}, bad),
+	})
+	s.add(get, "/api/reports/schedules/:id", "Reports", "A report schedule with its next run and the outcome of its last", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.ReportSchedule{}, bad, notFound),
+	})
+	s.add(put, "/api/reports/schedules/:id", "Reports", "Replace a report schedule's definition", openapi.Operation{
+		RequestBody: s.body(models.ReportScheduleRequest{}), Responses: s.ok(http.StatusOK, models.ReportSchedule{}, bad, notFound),
+	})
+	s.add(del, "/api/reports/schedules/:id", "Reports", "Delete a report schedule; the files of its runs are kept", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, bad, notFound),
+	})
+
+	// Batch
+	s.add(post, "/api/batch", "Batch", "Run several requests in order, in one transaction where possible", openapi.Operation{
+		RequestBody: s.body(BatchRequest{}), Responses: s.ok(http.StatusOK, []BatchResult{}, bad),
+	})
+
+	// GraphQL
+	graphqlResponse := s.raw("application/json", &openapi.Schema{
+		Type:        "object",
+		Description: "GraphQL response: data, and errors with extensions.code",
+		Properties: map[string]*openapi.Schema{
+			"data":   {Type: "object"},
+			"errors": {Type: "array", Items: &openapi.Schema{Type: "object"}},
+		},
+	}, bad)
+	s.add(get, "/graphql", "GraphQL", "Run a GraphQL query given in the query string", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			query("operationName", "string", ""),
+			query("variables", "string", "JSON object"),
+		},
+		Responses: graphqlResponse,
+	})
+	s.add(post, "/graphql", "GraphQL", "Run a GraphQL query; the schema is served by introspection", openapi.Operation{
+		RequestBody: s.body(GraphQLRequest{}), Responses: graphqlResponse,
+	})
+
+	// Prayer schedules
+	s.add(get, "/api/v2/prayer/provinces", "Prayer", "Provinces", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.PrayerLocation{}),
+	})
+	s.add(get, "/api/v2/prayer/cities", "Prayer", "Cities and regencies of a province", openapi.Operation{
+		Parameters: []openapi.Parameter{{Name: "province", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Responses:  s.ok(http.StatusOK, []models.PrayerLocation{}, bad),
+	})
+	s.add(get, "/api/v2/prayer/schedule", "Prayer", "Schedule for a day (date), a month (year and month) or a year", openapi.Operation{
+		Parameters: s.Query(PrayerScheduleQuery{}), Responses: s.ok(http.StatusOK, models.PrayerScheduleResponse{}, bad),
+	})
+	s.add(get, "/api/v2/prayer/imsakiyah", "Prayer", "Schedule of a year's fasting period", openapi.Operation{
+		Parameters: s.Query(FastingScheduleQuery{}), Responses: s.ok(http.StatusOK, models.PrayerScheduleResponse{}, bad),
+	})
+	s.add(post, "/api/v2/prayer/devices", "Prayer", "Register a mobile app's FCM token for a city's prayer reminders and announcements", openapi.Operation{
+		RequestBody: s.body(models.RegisterPushDeviceRequest{}), Responses: s.ok(http.StatusOK, models.PushDevice{}, bad),
+	})
+	s.add(post, "/api/v2/prayer/devices/unregister", "Prayer", "Stop sending to an FCM token", openapi.Operation{
+		RequestBody: s.body(models.UnregisterPushDeviceRequest{}), Responses: s.ok(http.StatusOK, nil, bad),
+	})
+	s.add(post, "/api/apiv1/getShalat", "Prayer v1", "Schedule for one day", openapi.Operation{
+		Parameters: challengeParams, RequestBody: s.body(models.ShalatRequest{}), Responses: s.raw("application/json", s.Schema(models.ShalatResponse{}), bad, forbidden),
+	})
+	s.add(post, "/api/apiv1/getApiProv", "Prayer v1", "Provinces", openapi.Operation{
+		Parameters: challengeParams, Responses: s.raw("application/json", &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "object"}}, forbidden),
+	})
+	s.add(post, "/api/apiv1/getApiKabko", "Prayer v1", "Cities and regencies of a province", openapi.Operation{
+		Parameters: challengeParams, RequestBody: form("x"), Responses: s.raw("application/json", &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "object"}}, bad, forbidden),
+	})
+	s.add(post, "/api/apiv1/getApiSholatbln", "Prayer v1", "Monthly schedule", openapi.Operation{
+		Parameters: challengeParams, RequestBody: form("thn", "bln", "prov", "kabko"), Responses: s.raw("application/json", s.Schema(models.MonthlyShalatResponse{}), bad, forbidden),
+	})
+	s.add(post, "/api/apiv1/getApiSholatthn", "Prayer v1", "Yearly schedule, one entry per day", openapi.Operation{
+		Parameters: challengeParams, RequestBody: form("thn", "prov", "kabko"), Responses: s.raw("application/json", s.Schema(models.MonthlyShalatResponse{}), bad, forbidden),
+	})
+	s.add(post, "/api/apiv1/getApiimsakiyah", "Prayer v1", "Fasting period schedule", openapi.Operation{
+		Parameters: challengeParams, RequestBody: form("thn", "prov", "kabko"), Responses: s.raw("application/json", s.Schema(models.ImsakiyahResponse{}), bad, forbidden),
+	})
+
+	// Webhooks
+	s.add(get, "/api/webhooks", "Webhooks", "List webhooks", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.Webhook{}, forbidden),
+	})
+	s.add(get, "/api/webhooks/:id", "Webhooks", "Get a webhook", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.Webhook{}, forbidden, notFound),
+	})
+	s.add(post, "/api/webhooks", "Webhooks", "Subscribe a URL to entity events; the secret is only shown here", openapi.Operation{
+		RequestBody: s.body(models.CreateWebhookRequest{}), Responses: s.ok(http.StatusCreated, models.CreatedWebhook{}, bad, forbidden),
+	})
+	s.add(put, "/api/webhooks/:id", "Webhooks", "Update a webhook", openapi.Operation{
+		RequestBody: s.body(models.UpdateWebhookRequest{}), Responses: s.ok(http.StatusOK, models.Webhook{}, bad, forbidden, notFound),
+	})
+	s.add(del, "/api/webhooks/:id", "Webhooks", "Delete a webhook", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, forbidden, notFound),
+	})
+	s.add(get, "/api/webhooks/:id/deliveries", "Webhooks", "Latest deliveries to a webhook, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{query("status", "string", "pending, succeeded or failed"), query("limit", "integer", "")},
+		Responses:  s.ok(http.StatusOK, []models.WebhookDelivery{}, bad, forbidden, notFound),
+	})
+
+	// Announcements
+	s.add(get, "/api/announcements", "Announcements", "List announcements, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("status", "string", "scheduled, active or expired"), query("before_id", "integer", "Page back from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.Announcement{}, bad, forbidden),
+	})
+	s.add(get, "/api/announcements/:id", "Announcements", "Get an announcement", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.Announcement{}, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/announcements", "Announcements", "Broadcast a banner to admin panel users, all or those with one of the target roles", openapi.Operation{
+		RequestBody: s.body(models.AnnouncementRequest{}), Responses: s.ok(http.StatusCreated, models.Announcement{}, bad, forbidden),
+	})
+	s.add(put, "/api/announcements/:id", "Announcements", "Replace an announcement", openapi.Operation{
+		RequestBody: s.body(models.AnnouncementRequest{}), Responses: s.ok(http.StatusOK, models.Announcement{}, bad, forbidden, notFound),
+	})
+	s.add(del, "/api/announcements/:id", "Announcements", "Delete an announcement", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, bad, forbidden, notFound),
+	})
+	s.add(get, "/api/me/announcements", "Announcements", "The banners to show the caller now, most severe first, then newest", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.Announcement{}),
+	})
+
+	// Phone and two-factor sign-in
+	s.add(get, "/api/me/phone", "Phone", "The caller's phone number and whether it is verified and used at sign-in", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.UserPhone{}, notFound),
+	})
+	s.add(put, "/api/me/phone", "Phone", "Replace the caller's number, turning two-factor sign-in off, and text it a verification code", openapi.Operation{
+		RequestBody: s.body(models.PhoneRequest{}),
+		Responses:   s.ok(http.StatusOK, models.OTPChallenge{}, bad, conflict, http.StatusTooManyRequests, http.StatusServiceUnavailable),
+	})
+	s.add(post, "/api/me/phone/verify", "Phone", "Verify the caller's number with the challenge from PUT /api/me/phone and the code texted", openapi.Operation{
+		RequestBody: s.body(models.PhoneVerifyRequest{}), Responses: s.ok(http.StatusOK, models.UserPhone{}, bad),
+	})
+	s.add(put, "/api/me/2fa", "Phone", "Turn SMS codes at sign-in on or off; on needs a verified number", openapi.Operation{
+		RequestBody: s.body(models.TwoFactorRequest{}), Responses: s.ok(http.StatusOK, models.UserPhone{}, bad, notFound),
+	})
+	s.add(post, "/api/sms/status/:driver", "Phone", "Delivery reports from the SMS provider: Twilio's form posts, signed, or the gateway's JSON with the callback secret", openapi.Operation{
+		Security: public, RequestBody: form("MessageSid", "MessageStatus", "ErrorCode"),
+		Responses: func() map[string]openapi.Response {
+			responses := map[string]openapi.Response{"204": {Description: "Recorded"}}
+			s.errors(responses, bad, http.StatusUnauthorized)
+			return responses
+		}(),
+	})
+
+	// Notification center
+	s.add(get, "/api/me/notifications", "Notifications", "The caller's notifications, newest first; meta.unread counts the unread ones", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("unread", "boolean", "true for unread notifications only"), query("before_id", "integer", "Page back from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.Notification{}, bad),
+	})
+	s.add(post, "/api/me/notifications/read", "Notifications", "Mark the listed notifications, or all of them, read", openapi.Operation{
+		RequestBody: s.body(models.MarkNotificationsReadRequest{}),
+		Responses:   s.ok(http.StatusOK, map[string]any{}, bad),
+	})
+	s.add(post, "/api/me/notifications/:id/read", "Notifications", "Mark a notification read", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.Notification{}, bad, notFound),
+	})
+
+	// Live events
+	s.add(get, "/api/events/stream", "Events", "Server-Sent Events stream of audit entries (event: audit), entity invalidations (event: invalidate) and security events (event: security)", openapi.Operation{
+		Parameters: []openapi.Parameter{query("types", "string", "audit, invalidate, security, comma-separated; all by default")},
+		Responses:  s.raw("text/event-stream", &openapi.Schema{Type: "string"}, bad, forbidden, http.StatusTooManyRequests),
+	})
+
+	// Administration
+	s.add(get, "/api/admin/cache/keys", "Admin", "List cache keys with their TTLs", openapi.Operation{
+		Parameters: []openapi.Parameter{query("pattern", "string", "e.g. menus:*"), query("limit", "integer", "")},
+		Responses:  s.ok(http.StatusOK, []cacheKeyInfo{}, forbidden),
+	})
+	s.add(get, "/api/admin/cache/key", "Admin", "Inspect a cache key", openapi.Operation{
+		Parameters: []openapi.Parameter{{Name: "key", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Responses:  s.ok(http.StatusOK, map[string]any{}, forbidden, notFound),
+	})
+	s.add(del, "/api/admin/cache/namespaces/:namespace", "Admin", "Flush a cache namespace", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, forbidden),
+	})
+	s.add(get, "/api/admin/cache/warm", "Admin", "List the cache keys that can be warmed", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []string{}, forbidden),
+	})
+	s.add(post, "/api/admin/cache/warm", "Admin", "Warm all known cache keys, or the given ones", openapi.Operation{
+		RequestBody: s.body(WarmCacheRequest{}), Responses: s.ok(http.StatusOK, map[string]any{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/traces", "Admin", "Recent requests with slow or too many queries", openapi.Operation{
+		Parameters: []openapi.Parameter{query("spans", "boolean", "false for summaries only")},
+		Responses:  s.ok(http.StatusOK, []tracing.Trace{}, forbidden),
+	})
+	s.add(get, "/api/admin/audit-pipeline", "Admin", "State of the async audit workers", openapi.Operation{
+		Responses: s.ok(http.StatusOK, AuditPipelineStatus{}, forbidden),
+	})
+	s.add(get, "/api/admin/deprecations", "Admin", "Deprecated routes and who still calls them", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []deprecation.Usage{}, forbidden),
+	})
+	s.add(get, "/api/admin/payloads", "Admin", "Captured redacted requests, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("route", "string", "Route template, e.g. /api/users/:id"), query("status", "integer", ""),
+			query("request_id", "string", ""), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []map[string]any{}, forbidden),
+	})
+	s.add(del, "/api/admin/payloads", "Admin", "Drop every captured payload", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, forbidden),
+	})
+	s.add(get, "/api/admin/maintenance", "Admin", "Maintenance mode and the users it lets through", openapi.Operation{
+		Responses: s.ok(http.StatusOK, maintenance.State{}, forbidden),
+	})
+	s.add(put, "/api/admin/maintenance", "Admin", "Turn maintenance mode on or off for every instance", openapi.Operation{
+		RequestBody: s.body(UpdateMaintenanceRequest{}),
+		Responses:   s.ok(http.StatusOK, maintenance.State{}, bad, forbidden, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/api/admin/ip_filter", "Admin", "IP allow and deny lists in effect, and the caller's address", openapi.Operation{
+		Responses: s.ok(http.StatusOK, IPFilterStatus{}, forbidden),
+	})
+	s.add(put, "/api/admin/ip_filter", "Admin", "Replace the IP allow and deny lists on every instance", openapi.Operation{
+		RequestBody: s.body(UpdateIPFilterRequest{}),
+		Responses:   s.ok(http.StatusOK, IPFilterStatus{}, bad, forbidden, conflict, http.StatusServiceUnavailable),
+	})
+	s.add(del, "/api/admin/ip_filter", "Admin", "Go back to the configured IP lists", openapi.Operation{
+		Responses: s.ok(http.StatusOK, IPFilterStatus{}, forbidden, http.StatusServiceUnavailable),
+	})
+	s.add(get, "/api/admin/security_events", "Admin", "Security events raised by the detectors, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("type", "string", strings.Join(services.SecurityEventTypes, ", ")), query("severity", "string", strings.Join(secevents.Severities, ", ")),
+			query("acknowledged", "boolean", "false for open events only"), query("before_id", "integer", "Page back from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.SecurityEvent{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/security_events/:id", "Admin", "Get a security event", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.SecurityEvent{}, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/security_events/:id/acknowledge", "Admin", "Acknowledge a security event, with an optional note", openapi.Operation{
+		RequestBody: s.body(models.AcknowledgeSecurityEventRequest{}),
+		Responses:   s.ok(http.StatusOK, models.SecurityEvent{}, bad, forbidden, notFound, conflict),
+	})
+	s.add(get, "/api/admin/mail/deliveries", "Admin", "Emails sent, with the outcome of their latest attempt, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("status", "string", "pending, succeeded or failed"), query("template", "string", "welcome, password_reset, password_expired, report_ready or role_changed"),
+			query("recipient", "string", "Email address"), query("before_id", "integer", "Page back from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.MailDelivery{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/prayer_subscriptions", "Admin", "Chats subscribed to prayer times, oldest first; meta.channels lists the configured channels", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("channel", "string", "telegram or whatsapp"), query("chat_id", "string", ""), query("kind", "string", "daily or imsakiyah"),
+			query("active", "boolean", ""), query("after_id", "integer", "Page forward from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.PrayerSubscription{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/prayer_subscriptions/:id", "Admin", "Get a prayer subscription", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.PrayerSubscription{}, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/prayer_subscriptions", "Admin", "Subscribe a Telegram or WhatsApp chat to a city's daily prayer times or imsak reminders", openapi.Operation{
+		RequestBody: s.body(models.CreatePrayerSubscriptionRequest{}), Responses: s.ok(http.StatusCreated, models.PrayerSubscription{}, bad, forbidden, conflict),
+	})
+	s.add(put, "/api/admin/prayer_subscriptions/:id", "Admin", "Update a prayer subscription", openapi.Operation{
+		RequestBody: s.body(models.UpdatePrayerSubscriptionRequest{}), Responses: s.ok(http.StatusOK, models.PrayerSubscription{}, bad, forbidden, notFound, conflict),
+	})
+	s.add(del, "/api/admin/prayer_subscriptions/:id", "Admin", "Unsubscribe a chat", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/prayer_subscriptions/:id/test", "Admin", "Send a subscription today's message now", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.PrayerSubscription{}, bad, forbidden, notFound),
+	})
+	s.add(get, "/api/admin/push/devices", "Admin", "Mobile devices registered for push notifications, oldest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("platform", "string", "android, ios or web"), query("city", "string", "Location code"),
+			query("after_id", "integer", "Page forward from this ID"), query("limit", "integer", ""),
+		},
+		Responses: s.ok(http.StatusOK, []models.PushDevice{}, bad, forbidden),
+	})
+	s.add(post, "/api/admin/push/announcements", "Admin", "Send an announcement to the devices of some cities, or all, in the background", openapi.Operation{
+		RequestBody: s.body(models.PushAnnouncementRequest{}), Responses: s.ok(http.StatusAccepted, nil, bad, forbidden),
+	})
+	s.add(get, "/api/admin/sms/messages", "Admin", "Texts sent, newest first, with their delivery status", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("status", "string", "queued, sent, delivered or failed"), query("purpose", "string", "verify_phone, login or password_reset"),
+			query("phone", "string", "E.164 number"), query("user_id", "integer", ""),
+			query("before_id", "integer", "Page back from this ID"), query("limit", "integer", "At most this many (1-1000, default 50)"),
+		},
+		Responses: s.ok(http.StatusOK, []models.SMSMessage{}, bad, forbidden),
+	})
+	geocoderErrs := []int{bad, forbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	s.add(get, "/api/admin/locations/geocode", "Admin", "Coordinates and elevation of a place, from the configured geocoder", openapi.Operation{
+		Parameters: []openapi.Parameter{{Name: "q", In: "query", Required: true, Description: "Place name, e.g. \"Kota Bogor, Jawa Barat\"", Schema: &openapi.Schema{Type: "string"}}},
+		Responses:  s.ok(http.StatusOK, geocode.Place{}, append(geocoderErrs, notFound)...),
+	})
+	s.add(get, "/api/admin/locations/cities/:id", "Admin", "A city with its coordinates and time zone", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.CityLocation{}, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/locations/cities", "Admin", "Add a city; coordinates and elevation left out are geocoded", openapi.Operation{
+		RequestBody: s.body(models.CityLocationRequest{}), Responses: s.ok(http.StatusCreated, models.CityLocation{}, append(geocoderErrs, notFound)...),
+	})
+	s.add(put, "/api/admin/locations/cities/:id", "Admin", "Replace a city; coordinates and elevation left out are geocoded", openapi.Operation{
+		RequestBody: s.body(models.CityLocationRequest{}), Responses: s.ok(http.StatusOK, models.CityLocation{}, append(geocoderErrs, notFound)...),
+	})
+	s.add(post, "/api/admin/locations/import", "Admin", "Load Kemendagri region codes and coordinates from a CSV or XLSX file, with a diff", openapi.Operation{
+		Parameters:  []openapi.Parameter{query("dry_run", "boolean", "Only show the diff")},
+		RequestBody: upload(),
+		Responses:   s.importReport(models.LocationImportResult{}, bad, forbidden, http.StatusRequestEntityTooLarge, unsupported, http.StatusTooManyRequests),
+	})
+	s.add(get, "/api/admin/locations/imports", "Admin", "Reference data imports, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{query("before_id", "integer", "Only imports older than this one"), query("limit", "integer", "At most this many (1-500, default 50)")},
+		Responses:  s.ok(http.StatusOK, []models.LocationImport{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/locations/imports/:id", "Admin", "A reference data import with the regions it created or changed", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.LocationImportDetail{}, bad, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/locations/imports/:id/rollback", "Admin", "Restore what the latest reference data import created or changed", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.LocationImport{}, bad, forbidden, notFound, conflict),
+	})
+	s.add(get, "/api/admin/rbac/export", "Admin", "The roles, inheritances, menu and role-menu links as a bundle to import elsewhere", openapi.Operation{
+		Responses: s.raw("application/json", s.Schema(models.RBACBundle{}), forbidden, conflict),
+	})
+	s.add(post, "/api/admin/rbac/import", "Admin", "Apply an RBAC bundle, matching roles by name and menu items by path, with a diff", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("dry_run", "boolean", "Only show the diff"),
+			query("prune", "boolean", "Also remove the inheritances and menu links of the bundle's roles it leaves out"),
+		},
+		RequestBody: s.body(models.RBACBundle{}),
+		Responses: func() map[string]openapi.Response {
+			responses := s.ok(http.StatusCreated, models.RBACImportResult{}, bad, forbidden, http.StatusRequestEntityTooLarge, unsupported)
+			responses["200"] = openapi.Response{Description: "Nothing was written: a dry run, or nothing to change", Content: s.jsonContent(s.Envelope(models.RBACImportResult{}))}
+			return responses
+		}(),
+	})
+	s.add(get, "/api/admin/data-quality", "Admin", "Data quality checks with their last run, and the records they flagged, newest first", openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("check", "string", strings.Join([]string{models.CheckCitiesWithoutCoordinates, models.CheckUsersWithoutRoles, models.CheckMenusWithDanglingParent, models.CheckOrphanRoleMenus}, ", ")),
+			query("severity", "string", strings.Join([]string{models.SeverityCritical, models.SeverityWarning, models.SeverityInfo}, ", ")),
+			query("before_id", "integer", "Page back from this ID"), query("limit", "integer", "At most this many findings (1-1000, default 100)"),
+		},
+		Responses: s.ok(http.StatusOK, models.DataQualityReport{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/calendar", "Admin", "The calendar report schedules are published to, whether it can write, and the last sync", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.CalendarStatus{}, forbidden),
+	})
+	s.add(get, "/api/admin/calendar/authorize", "Admin", "The Google consent page connecting the account report schedules are published with", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.CalendarAuthorization{}, forbidden, conflict),
+	})
+	s.add(del, "/api/admin/calendar/credentials", "Admin", "Forget the connected Google account", openapi.Operation{
+		Responses: s.ok(http.StatusOK, nil, forbidden),
+	})
+	s.add(post, "/api/admin/calendar/sync", "Admin", "Start publishing the next runs of report schedules to the calendar", openapi.Operation{
+		Responses: s.ok(http.StatusAccepted, nil, forbidden, conflict),
+	})
+	s.add(get, "/api/admin/jobs", "Admin", "Background jobs with their schedule, next run and last run", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []scheduler.JobStatus{}, forbidden),
+	})
+	s.add(get, "/api/admin/jobs/:name/runs", "Admin", "Latest runs of a background job on this instance, newest first", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []scheduler.RunRecord{}, forbidden, notFound),
+	})
+	s.add(post, "/api/admin/jobs/:name/run", "Admin", "Start a background job now, even if disabled on schedule", openapi.Operation{
+		Responses: s.ok(http.StatusAccepted, nil, forbidden, notFound, conflict),
+	})
+	s.add(get, "/api/admin/tenants", "Admin", "The tenants this deployment serves, the default one first", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []models.Tenant{}, forbidden),
+	})
+	s.add(post, "/api/admin/tenants", "Admin", "Create a tenant with an admin role and its first administrator", openapi.Operation{
+		RequestBody: s.body(models.CreateTenantRequest{}), Responses: s.ok(http.StatusCreated, models.CreatedTenant{}, bad, forbidden, conflict),
+	})
+	s.add(get, "/api/admin/tenants/:id", "Admin", "Get a tenant", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.Tenant{}, bad, forbidden, notFound),
+	})
+	s.add(put, "/api/admin/tenants/:id", "Admin", "Rename a tenant or, with status 0, disable it", openapi.Operation{
+		RequestBody: s.body(models.UpdateTenantRequest{}), Responses: s.ok(http.StatusOK, models.Tenant{}, bad, forbidden, notFound),
+	})
+	s.add(get, "/api/admin/tenants/:id/settings", "Admin", "What a tenant overrides of the global configuration", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.TenantSettings{}, bad, forbidden, notFound),
+	})
+	s.add(put, "/api/admin/tenants/:id/settings", "Admin", "Replace a tenant's overrides of the global configuration", openapi.Operation{
+		Description: "prayer_method is one of kemenag, mwl, isna, egypt and umm_al_qura; branding keys are app_name, logo_url, " +
+			`primary_color and support_email; cache_ttls map a class ("list") or entity and class ("users:list") to a duration. ` +
+			"Settings left out, or empty, keep the global value. The default tenant has none.",
+		RequestBody: s.body(models.TenantSettings{}), Responses: s.ok(http.StatusOK, models.TenantSettings{}, bad, forbidden, notFound),
+	})
+	s.add(get, "/api/admin/runtime", "Admin", "Current runtime settings (runtime_admin role)", openapi.Operation{
+		Responses: s.ok(http.StatusOK, RuntimeSettings{}, forbidden),
+	})
+	s.add(patch, "/api/admin/runtime", "Admin", "Change runtime settings without a restart (runtime_admin role)", openapi.Operation{
+		RequestBody: s.body(UpdateRuntimeSettingsRequest{}), Responses: s.ok(http.StatusOK, RuntimeSettings{}, bad, forbidden),
+	})
+	s.add(post, "/api/admin/runtime/reload", "Admin", "Reload the env file and config.yaml on this instance, applying the tunables (runtime_admin role)", openapi.Operation{
+		Responses: s.ok(http.StatusOK, []ConfigChange{}, forbidden, http.StatusUnprocessableEntity),
+	})
+	s.add(post, "/api/admin/backups", "Admin", "Queue a database backup and start the db_backup job (backup_admin role)", openapi.Operation{
+		Responses: s.ok(http.StatusAccepted, models.DBBackup{}, forbidden, conflict),
+	})
+	s.add(get, "/api/admin/backups", "Admin", "Database backups, newest first (backup_admin role)", openapi.Operation{
+		Parameters: []openapi.Parameter{query("before_id", "integer", "Only backups older than this one"), query("limit", "integer", "At most this many (1-500, default 50)")},
+		Responses:  s.ok(http.StatusOK, []models.DBBackup{}, bad, forbidden),
+	})
+	s.add(get, "/api/admin/backups/:id", "Admin", "A database backup's status and progress (backup_admin role)", openapi.Operation{
+		Responses: s.ok(http.StatusOK, models.DBBackup{}, bad, forbidden, notFound),
+	})
+	s.add(get, "/api/admin/backups/:id/download", "Admin", "Download a database backup as gzipped SQL (backup_admin role)", openapi.Operation{
+		Responses: s.raw("application/gzip", &openapi.Schema{Type: "string", Format: "binary"}, bad, forbidden, notFound, conflict),
+	})
+
+	return s.Document
+}
+
+// openAPIHandler GET /openapi.json
+func openAPIHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, APISpec())
+}
+
+// swaggerUIHandler GET /docs
+// Swagger UI for /openapi.json; its scripts and styles load from assets (SWAGGER_UI_ASSETS)
+func swaggerUIHandler(assets string) gin.HandlerFunc {
+	page := openapi.SwaggerUI("/openapi.json", assets)
+	// The API's own policy allows nothing to load, which would leave the page blank
+	policy := openapi.SwaggerUIPolicy(page, assets)
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", policy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"adminbe/internal/pkg/alerting"
+	"adminbe/internal/pkg/scheduler"
+)
+
+// jasperDownAfter is how many checks in a row must fail for JasperServer to count as down,
+// so one slow answer does not page anyone
+const jasperDownAfter = 2
+
+// jasperCheckTimeout bounds one JasperServer check
+const jasperCheckTimeout = 10 * time.Second
+
+// OpsMonitor checks the audit pipeline and JasperServer on an interval and reports changes
+// through an alerter. Both are per process, so each replica alerts on its own.
+type OpsMonitor struct {
+	alerts   *alerting.Alerter
+	interval time.Duration
+	jasper   bool
+
+	// jasperFailures counts failed checks in a row; only the monitor loop touches it
+	jasperFailures int
+	stop           chan struct{}
+	done           chan struct{}
+}
+
+// NewOpsMonitor builds the monitor for cfg; a zero CheckInterval makes Start do nothing
+func NewOpsMonitor(alerts *alerting.Alerter, cfg alerting.Config) *OpsMonitor {
+	return &OpsMonitor{
+		alerts:   alerts,
+		interval: cfg.CheckInterval,
+		jasper:   cfg.CheckJasper,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start checks every interval until Stop
+func (m *OpsMonitor) Start() {
+	if m.interval <= 0 {
+		close(m.done)
+		return
+	}
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the checks and waits for the one running
+func (m *OpsMonitor) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// check runs every check once
+func (m *OpsMonitor) check() {
+	ctx := context.Background()
+	m.checkAudit(ctx, auditPipeline.snapshot(time.Now()))
+	if m.jasper && jasperClient != nil {
+		m.checkJasper(ctx)
+	}
+}
+
+// checkAudit alerts while the audit queue is saturated: critical when entries are dropped
+// or the workers stopped writing, a warning when it is nearly full
+func (m *OpsMonitor) checkAudit(ctx context.Context, st AuditPipelineStatus) {
+	alert := alerting.Alert{
+		Source: "audit",
+		Key:    "pipeline",
+		Fields: map[string]string{
+			"queue":   fmt.Sprintf("%d of %d", st.QueueDepth, st.QueueCapacity),
+			"dropped": strconv.FormatUint(st.Dropped, 10),
+			"failed":  strconv.FormatUint(st.Failed, 10),
+		},
+	}
+	switch st.Status {
+	case "stalled":
+		alert.Severity, alert.Title = alerting.SeverityCritical, "Audit queue stalled"
+		alert.Text = "Audit entries are queued but none were written for " + auditStallAfter.String() + "."
+	case "dropping":
+		alert.Severity, alert.Title = alerting.SeverityCritical, "Audit queue dropping entries"
+		alert.Text = "The audit queue is full and new entries are being dropped."
+	case "lagging":
+		alert.Severity, alert.Title = alerting.SeverityWarning, "Audit queue nearly full"
+		alert.Text = fmt.Sprintf("The audit queue is over %.0f%% full; the workers are not keeping up.", auditLagUtilization*100)
+	default:
+		alert.Title, alert.Text = "Audit queue back to normal", "Audit entries are written as they come in."
+		m.alerts.Resolve(ctx, alert)
+		return
+	}
+	if st.LastError != "" {
+		alert.Fields["last_error"] = st.LastError
+	}
+	m.alerts.Fire(ctx, alert)
+}
+
+// checkJasper alerts once JasperServer failed jasperDownAfter checks in a row
+func (m *OpsMonitor) checkJasper(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, jasperCheckTimeout)
+	defer cancel()
+	_, err := jasperClient.GetServerInfo(ctx)
+
+	alert := alerting.Alert{Source: "jasper", Key: "server"}
+	if err == nil {
+		m.jasperFailures = 0
+		alert.Title, alert.Text = "JasperServer reachable again", "Reports can be run again."
+		m.alerts.Resolve(ctx, alert)
+		return
+	}
+	slog.Warn("JasperServer check failed", "error", err)
+	if m.jasperFailures++; m.jasperFailures < jasperDownAfter {
+		return
+	}
+	// Fire sends once until resolved, so the error is that of the check that crossed the line
+	alert.Severity, alert.Title = alerting.SeverityCritical, "JasperServer unreachable"
+	alert.Text = "Reports cannot be run: JasperServer failed " + strconv.Itoa(jasperDownAfter) + " checks in a row."
+	alert.Fields = map[string]string{"error": clip(err.Error(), 300)}
+	m.alerts.Fire(ctx, alert)
+}
+
+// alertJobFailure reports a failed background job run; repeated failures of one job are
+// throttled by ALERT_THROTTLE
+func alertJobFailure(alerts *alerting.Alerter) func(scheduler.Job, scheduler.RunRecord) {
+	return func(job scheduler.Job, record scheduler.RunRecord) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		alerts.Event(ctx, alerting.Alert{
+			Source:   "jobs",
+			Key:      job.Name,
+			Severity: alerting.SeverityWarning,
+			Title:    "Job " + job.Name + " failed",
+			Text:     job.Description,
+			Fields:   map[string]string{"trigger": record.Trigger, "error": clip(record.Error, 500)},
+			Time:     record.StartedAt,
+		})
+	}
+}
+
+// clip shortens s to at most n characters for an alert
+func clip(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
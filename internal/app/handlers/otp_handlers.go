@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forgotPasswordHandler POST /api/auth/password/forgot
+// Texts a reset code to the verified number of the account with the email or number given.
+// The answer is the same whether or not there is one, so it cannot be used to find accounts.
+func forgotPasswordHandler(otp services.OTPService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ForgotPasswordRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		challenge, err := otp.StartPasswordReset(c.Request.Context(), req.Email, req.Phone)
+		if utils.HandleError(c, err, "start password reset") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{
+			Data:    challenge,
+			Message: "If the account has a verified phone number, a code was sent to it",
+		})
+	}
+}
+
+// resetPasswordHandler POST /api/auth/password/reset
+// Sets a new password with the challenge from /api/auth/password/forgot and the code texted
+func resetPasswordHandler(otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ResetPasswordRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		userID, err := otp.ResetPassword(c.Request.Context(), req.Challenge, req.Code, req.Password)
+		if utils.HandleError(c, err, "reset password") {
+			return
+		}
+		// No one is signed in, so the entry is the user's own
+		if !EnqueueAuditLog(db, userID, "UPDATE", "users", userID, nil, gin.H{"password_reset": "sms"}) {
+			logger(c).Warn("Audit log queue full, dropping entry", "event", "UPDATE", "table", "users", "record_id", userID)
+		}
+		response.Write(c, http.StatusOK, response.Body{Message: "Password changed"})
+	}
+}
+
+// getMyPhoneHandler GET /api/me/phone
+func getMyPhoneHandler(otp services.OTPService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		p, err := otp.GetPhone(c.Request.Context(), userID)
+		if utils.HandleError(c, err, "get phone") {
+			return
+		}
+		response.OK(c, p)
+	}
+}
+
+// setMyPhoneHandler PUT /api/me/phone
+// Replaces the caller's number, turning two-factor sign-in off, and texts it a code that
+// POST /api/me/phone/verify takes with the challenge answered
+func setMyPhoneHandler(otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.PhoneRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		challenge, err := otp.SetPhone(c.Request.Context(), userID, req.Phone, req.Password)
+		if utils.HandleError(c, err, "set phone") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "user_phones", userID, nil, gin.H{"phone": challenge.Phone, "verified": false}, db)
+		response.Write(c, http.StatusOK, response.Body{Data: challenge, Message: "A verification code was sent to your phone"})
+	}
+}
+
+// verifyMyPhoneHandler POST /api/me/phone/verify
+func verifyMyPhoneHandler(otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.PhoneVerifyRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		p, err := otp.VerifyPhone(c.Request.Context(), userID, req.Challenge, req.Code)
+		if utils.HandleError(c, err, "verify phone") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "user_phones", userID, nil, p, db)
+		response.Write(c, http.StatusOK, response.Body{Data: p, Message: "Phone number verified"})
+	}
+}
+
+// setMyTwoFactorHandler PUT /api/me/2fa
+// Turns SMS codes at sign-in on or off; on needs a verified number
+func setMyTwoFactorHandler(otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.TwoFactorRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		p, err := otp.SetTwoFactor(c.Request.Context(), userID, *req.Enabled, req.Password)
+		if utils.HandleError(c, err, "set two-factor sign-in") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "user_phones", userID, nil, gin.H{"two_factor": p.TwoFactor}, db)
+		response.OK(c, p)
+	}
+}
+
+// resetTwoFactorHandler DELETE /api/users/:id/2fa
+// Turns a user's two-factor sign-in off, e.g. when they lost their phone; administrators only
+func resetTwoFactorHandler(otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := otp.ResetTwoFactor(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "reset two-factor sign-in") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "user_phones", p.UserID, nil, gin.H{"two_factor": false}, db)
+		response.Write(c, http.StatusOK, response.Body{Data: p, Message: "Two-factor sign-in turned off"})
+	}
+}
+
+// listSMSMessagesHandler GET /api/admin/sms/messages
+// The texts sent, newest first, with their delivery status; ?status=, ?purpose=, ?phone= and
+// ?user_id= narrow them and ?before_id= pages back
+func listSMSMessagesHandler(otp services.OTPService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.SMSMessageFilter{
+			Status:  c.Query("status"),
+			Purpose: c.Query("purpose"),
+			Phone:   c.Query("phone"),
+			Limit:   parseIntMinMax(c.Query("limit"), 50, 1, 1000),
+		}
+		for name, dest := range map[string]*uint64{"user_id": &filter.UserID, "before_id": &filter.BeforeID} {
+			if v := c.Query(name); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					utils.RespondError(c, http.StatusBadRequest, "Invalid "+name)
+					return
+				}
+				*dest = n
+			}
+		}
+
+		list, err := otp.ListMessages(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list SMS messages") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// smsStatusHandler POST /api/sms/status/:driver
+// Delivery reports from the SMS provider, outside the authenticated API: Twilio's are signed
+// with the auth token, the gateway's carry SMS_CALLBACK_SECRET. Each change is audited
+// against the user the text was sent to.
+func smsStatusHandler(client *sms.Client, otp services.OTPService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		driver := c.Param("driver")
+		update, err := client.ParseCallback(driver, c.Request)
+		if errors.Is(err, sms.ErrUnauthorized) {
+			logger(c).Warn("SMS delivery report refused", "driver", driver, "client_ip", c.ClientIP())
+			utils.RespondError(c, http.StatusUnauthorized, "Invalid signature")
+			return
+		}
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		old, updated, err := otp.RecordStatus(c.Request.Context(), driver, *update)
+		if isNotFoundError(err) {
+			// Acknowledged all the same, so the provider does not retry it
+			logger(c).Info("SMS delivery report for an unknown message", "driver", driver, "message_id", update.MessageID)
+			c.Status(http.StatusNoContent)
+			return
+		}
+		if utils.HandleError(c, err, "record SMS status") {
+			return
+		}
+		if updated != old {
+			var userID uint64
+			if updated.UserID != nil {
+				userID = *updated.UserID
+			}
+			if !EnqueueAuditLog(db, userID, "UPDATE", "sms_messages", updated.ID, old, updated) {
+				logger(c).Warn("Audit log queue full, dropping entry", "event", "UPDATE", "table", "sms_messages", "record_id", updated.ID)
+			}
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/payloadlog"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listPayloadsHandler GET /api/admin/payloads?route=/api/users/:id&status=500&limit=50
+// Lists captured request and response payloads, newest first. Filters: route pattern,
+// exact status, or request_id.
+func listPayloadsHandler(c *gin.Context) {
+	route := c.Query("route")
+	status := parseIntMinMax(c.Query("status"), 0, 0, 999)
+	requestID := c.Query("request_id")
+	limit := parseIntMinMax(c.Query("limit"), 50, 1, 1000)
+
+	entries := make([]payloadlog.Entry, 0, limit)
+	for _, e := range payloadlog.Recent() {
+		if len(entries) == limit {
+			break
+		}
+		if (route != "" && e.Route != route) || (status != 0 && e.Status != status) ||
+			(requestID != "" && !strings.EqualFold(e.RequestID, requestID)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	response.Write(c, http.StatusOK, response.Body{Data: entries, Meta: response.Meta{
+		"count":   len(entries),
+		"enabled": features.Enabled(features.PayloadLog),
+	}})
+}
+
+// clearPayloadsHandler DELETE /api/admin/payloads
+// Drops every captured payload, e.g. once an incident is closed
+func clearPayloadsHandler(c *gin.Context) {
+	payloadlog.Clear()
+	response.Write(c, http.StatusOK, response.Body{Message: "Payload log cleared"})
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pprofHandler serves GET/POST /debug/pprof/*name from net/http/pprof.
+// The index and named profiles (heap, goroutine, allocs, block, mutex, threadcreate) are
+// served by pprof.Index, which expects the /debug/pprof/ prefix this route keeps.
+// The routes answer 404 while the pprof feature is off.
+func pprofHandler(c *gin.Context) {
+	if !features.Enabled(features.Pprof) {
+		utils.RespondError(c, http.StatusNotFound, "Profiling is disabled")
+		return
+	}
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
@@ -3,62 +3,129 @@ package handlers
 import (
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/jsonenc"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+	"context"
 	"crypto/md5"
 	"fmt"
 	"log"
+	"log/slog"
 
 	"github.com/gin-gonic/gin"
 )
 
+// loadShalatEncoder returns the named JSON encoder once it has produced the same bytes as
+// encoding/json for every golden shalat response; otherwise it logs why and uses encoding/json
+func loadShalatEncoder(name string) jsonenc.Encoder {
+	enc, err := jsonenc.New(name)
+	if err != nil {
+		log.Fatalf("Invalid SHALAT_JSON_ENCODER: %v", err)
+	}
+	if err := jsonenc.Verify(enc, shalatGolden()...); err != nil {
+		slog.Warn("Shalat JSON encoder is not byte-compatible, falling back", "encoder", enc.Name(), "fallback", jsonenc.NameStd, "error", err)
+		return jsonenc.Std
+	}
+	return enc
+}
+
+// shalatGolden returns one value of every shalat response type, plus the envelope they are
+// written in, filled with the awkward cases for a JSON encoder: HTML characters, quotes,
+// non-ASCII and invalid UTF-8 in names, U+2028, a nil embedded schedule, nil and empty
+// slices, and omitempty fields
+func shalatGolden() []any {
+	day := models.MonthlyScheduleItem{Date: "2024-02-29", Imsak: "04:28", Subuh: "04:38", Terbit: "05:54",
+		Dhuha: "06:20", Dzuhur: "12:09", Ashar: "15:21", Maghrib: "18:17", Isya: "19:29"}
+	fast := models.ImsakiyahScheduleItem(day)
+	return []any{
+		&models.ShalatResponse{
+			PrayerSchedule: &models.PrayerSchedule{Tanggal: "Kamis, 29 Februari 2024", Imsak: day.Imsak, Subuh: day.Subuh,
+				Terbit: day.Terbit, Dhuha: day.Dhuha, Dzuhur: day.Dzuhur, Ashar: day.Ashar, Maghrib: day.Maghrib, Isya: day.Isya},
+			Prov: "DKI JAKARTA", Kota: "KOTA JAKARTA PUSAT", Time: "2024-02-29", Msg: "Success",
+		},
+		&models.ShalatResponse{Prov: "NUSA <TENGGARA> & \"BARAT\"", Kota: "KAB. BIMA\u2028\xff", Msg: "Location not found"},
+		&models.MonthlyShalatResponse{Status: 1, Message: "Success", Prov: "Aceh – Banda", Kabko: "Kab. Pidie",
+			Data: []models.MonthlyScheduleItem{day, day}},
+		&models.MonthlyShalatResponse{Message: "Invalid month"},
+		&models.ImsakiyahResponse{Status: 1, Message: "Success", Prov: "JAWA BARAT", Kabko: "KOTA BANDUNG",
+			Lintang: "-6.9175", Bujur: "107.6191", Hijriah: "1445 H", Tahun: "2024", Data: []models.ImsakiyahScheduleItem{fast}},
+		&models.ImsakiyahResponse{Data: []models.ImsakiyahScheduleItem{}},
+		[]*services.ProvinceAPIResponse{{ProvKode: "c51ce410c124a10e0db5e4b97fc2af39", ProvNama: "DKI JAKARTA"}},
+		[]*services.CityAPIResponse{{KabkoKode: "58a2fc6ed39fd083f55d4182bf88826d", KabkoNama: "KOTA JAKARTA PUSAT"}, nil},
+		response.Envelope{Data: &models.ShalatResponse{Msg: "Success"}, Meta: response.Meta{}},
+		&models.PrayerScheduleResponse{Province: models.PrayerLocation{Code: "x-9_Qm", Name: "DI <YOGYAKARTA>"},
+			City: models.PrayerLocation{Name: "KOTA\u2028\xff"}, HijriYear: "1445 H", Method: models.PrayerMethodKemenag,
+			Labels: map[string]string{"subuh": "Subuh", "isya": "Isya\u00a0<>"}, Days: []models.PrayerDay{models.PrayerDay(day)}},
+		&models.PrayerScheduleResponse{},
+		[]models.PrayerLocation{{Code: "x-9_Qm", Name: "JAWA & BALI"}},
+	}
+}
+
+// renderJSON writes v as the data of a JSON response using enc, with the same headers as
+// c.JSON. Version 1 clients get v itself, as the apiv1 endpoints always returned.
+func renderJSON(c *gin.Context, enc jsonenc.Encoder, code int, v any) {
+	body, err := enc.Marshal(response.Render(c, response.Body{Data: v, Legacy: v}))
+	if err != nil {
+		logger(c).Error("Error encoding response", "error", err)
+		utils.RespondError(c, 500, "Failed to encode response")
+		return
+	}
+	c.Data(code, "application/json; charset=utf-8", body)
+}
+
 // getShalatHandler handles POST /api/apiv1/getShalat - Prayer schedule API
-func getShalatHandler(prayerService services.PrayerService) gin.HandlerFunc {
+func getShalatHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse and validate request
 		var req models.ShalatRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": "Invalid request format: " + err.Error()})
+			utils.RespondError(c, 400, "Invalid request format: "+err.Error())
 			return
 		}
 
 		// Input validation
 		if req.Prov == "" {
-			c.JSON(400, gin.H{"error": "Province (prov) parameter is required"})
+			utils.RespondError(c, 400, "Province (prov) parameter is required")
 			return
 		}
 		if req.Tgl == "" {
-			c.JSON(400, gin.H{"error": "Date (tgl) parameter is required"})
+			utils.RespondError(c, 400, "Date (tgl) parameter is required")
 			return
 		}
 
 		// Get prayer schedule from service
-		response, err := prayerService.GetPrayerSchedule(c.Request.Context(), req.Prov, req.Kabko, req.Tgl)
+		schedule, err := prayerService.GetPrayerSchedule(c.Request.Context(), req.Prov, req.Kabko, req.Tgl)
 		if err != nil {
-			log.Printf("Error getting prayer schedule: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to calculate prayer times"})
+			logger(c).Error("Error getting prayer schedule", "error", err)
+			utils.RespondError(c, 500, "Failed to calculate prayer times")
 			return
 		}
 
-		c.JSON(200, response)
+		renderJSON(c, enc, 200, schedule)
 	}
 }
 
 // getApiProvHandler handles POST /api/apiv1/getApiProv - Get all provinces API
-func getApiProvHandler(prayerService services.PrayerService) gin.HandlerFunc {
+func getApiProvHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get all provinces from service
-		response, err := prayerService.GetAllProvinces(c.Request.Context())
+		// Province list is reference data and rarely changes - read through the cache
+		provinces, _, err := cache.GetOrLoad(c.Request.Context(), database.Cache, cache.CacheKeyProvinces, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]*services.ProvinceAPIResponse, error) {
+			return prayerService.GetAllProvinces(ctx)
+		})
 		if err != nil {
-			log.Printf("Error getting provinces: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to retrieve provinces"})
+			logger(c).Error("Error getting provinces", "error", err)
+			utils.RespondError(c, 500, "Failed to retrieve provinces")
 			return
 		}
 
-		c.JSON(200, response)
+		renderJSON(c, enc, 200, provinces)
 	}
 }
 
 // getApiKabkoHandler handles POST /api/apiv1/getApiKabko - Get cities/regencies by province API
-func getApiKabkoHandler(prayerService services.PrayerService) gin.HandlerFunc {
+func getApiKabkoHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse request parameters
 		provinceHash := c.PostForm("x")
@@ -67,20 +134,23 @@ func getApiKabkoHandler(prayerService services.PrayerService) gin.HandlerFunc {
 			provinceHash = fmt.Sprintf("%x", md5.Sum([]byte("13")))
 		}
 
-		// Get cities from service
-		response, err := prayerService.GetCitiesByProvince(c.Request.Context(), provinceHash)
+		// Read through the cache; concurrent misses share a single DB load
+		cacheKey := fmt.Sprintf(cache.CacheKeyCities, provinceHash)
+		cities, _, err := cache.GetOrLoad(c.Request.Context(), database.Cache, cacheKey, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]*services.CityAPIResponse, error) {
+			return prayerService.GetCitiesByProvince(ctx, provinceHash)
+		})
 		if err != nil {
-			log.Printf("Error getting cities: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to retrieve cities"})
+			logger(c).Error("Error getting cities", "error", err)
+			utils.RespondError(c, 500, "Failed to retrieve cities")
 			return
 		}
 
-		c.JSON(200, response)
+		renderJSON(c, enc, 200, cities)
 	}
 }
 
 // getApiSholatblnHandler handles POST /api/apiv1/getApiSholatbln - Get monthly prayer schedule API
-func getApiSholatblnHandler(prayerService services.PrayerService) gin.HandlerFunc {
+func getApiSholatblnHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse request parameters (matching PHP POST format)
 		year := c.PostForm("thn")
@@ -89,7 +159,7 @@ func getApiSholatblnHandler(prayerService services.PrayerService) gin.HandlerFun
 		cityHash := c.PostForm("kabko")
 
 		// Get monthly prayer schedule from service
-		response, err := prayerService.GetMonthlyPrayerSchedule(
+		schedule, err := prayerService.GetMonthlyPrayerSchedule(
 			c.Request.Context(),
 			year,
 			month,
@@ -97,17 +167,42 @@ func getApiSholatblnHandler(prayerService services.PrayerService) gin.HandlerFun
 			cityHash,
 		)
 		if err != nil {
-			log.Printf("Error getting monthly prayer schedule: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to retrieve monthly prayer schedule"})
+			logger(c).Error("Error getting monthly prayer schedule", "error", err)
+			utils.RespondError(c, 500, "Failed to retrieve monthly prayer schedule")
+			return
+		}
+
+		renderJSON(c, enc, 200, schedule)
+	}
+}
+
+// getApiSholatthnHandler handles POST /api/apiv1/getApiSholatthn - Get yearly prayer schedule API
+func getApiSholatthnHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Same parameters as the monthly schedule, without bln
+		year := c.PostForm("thn")
+		provinceHash := c.PostForm("prov")
+		cityHash := c.PostForm("kabko")
+
+		// Get yearly prayer schedule from service
+		schedule, err := prayerService.GetYearlyPrayerSchedule(
+			c.Request.Context(),
+			year,
+			provinceHash,
+			cityHash,
+		)
+		if err != nil {
+			logger(c).Error("Error getting yearly prayer schedule", "error", err)
+			utils.RespondError(c, 500, "Failed to retrieve yearly prayer schedule")
 			return
 		}
 
-		c.JSON(200, response)
+		renderJSON(c, enc, 200, schedule)
 	}
 }
 
 // getApiimsakiyahHandler handles POST /api/apiv1/getApiimsakiyah - Get fasting/imsakiyah prayer schedule API
-func getApiimsakiyahHandler(prayerService services.PrayerService) gin.HandlerFunc {
+func getApiimsakiyahHandler(prayerService services.PrayerService, enc jsonenc.Encoder) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse request parameters (matching PHP POST format)
 		year := c.PostForm("thn")
@@ -115,18 +210,18 @@ func getApiimsakiyahHandler(prayerService services.PrayerService) gin.HandlerFun
 		cityHash := c.PostForm("kabko")
 
 		// Get imsakiyah/fasting prayer schedule from service
-		response, err := prayerService.GetImsakiyahSchedule(
+		schedule, err := prayerService.GetImsakiyahSchedule(
 			c.Request.Context(),
 			year,
 			provinceHash,
 			cityHash,
 		)
 		if err != nil {
-			log.Printf("Error getting imsakiyah schedule: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to retrieve imsakiyah schedule"})
+			logger(c).Error("Error getting imsakiyah schedule", "error", err)
+			utils.RespondError(c, 500, "Failed to retrieve imsakiyah schedule")
 			return
 		}
 
-		c.JSON(200, response)
+		renderJSON(c, enc, 200, schedule)
 	}
 }
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"adminbe/internal/pkg/jsonenc"
+)
+
+// TestShalatEncodersMatchEncodingJSON marshals every golden shalat response with each encoder
+// SHALAT_JSON_ENCODER can select and compares the bytes with encoding/json, so a library
+// upgrade that changes the output fails here instead of falling back in production
+func TestShalatEncodersMatchEncodingJSON(t *testing.T) {
+	for _, name := range []string{jsonenc.NameJsoniter, jsonenc.NameSonic} {
+		t.Run(name, func(t *testing.T) {
+			enc, err := jsonenc.New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range shalatGolden() {
+				t.Run(fmt.Sprintf("%d_%T", i, v), func(t *testing.T) {
+					want, err := json.Marshal(v)
+					if err != nil {
+						t.Fatalf("encoding/json: %v", err)
+					}
+					got, err := enc.Marshal(v)
+					if err != nil {
+						t.Fatalf("%s: %v", name, err)
+					}
+					if string(got) != string(want) {
+						t.Errorf("%s output differs from encoding/json\n got: %s\nwant: %s", name, got, want)
+					}
+				})
+			}
+		})
+	}
+}
+
+// TestLoadShalatEncoderKeepsTheConfiguredEncoder checks the startup verification passes, so
+// the encoder configured is the one that serves the shalat endpoints
+func TestLoadShalatEncoderKeepsTheConfiguredEncoder(t *testing.T) {
+	for _, name := range []string{jsonenc.NameStd, jsonenc.NameJsoniter, jsonenc.NameSonic} {
+		if got := loadShalatEncoder(name).Name(); got != name {
+			t.Errorf("loadShalatEncoder(%q) fell back to %s", name, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listPrayerSubscriptionsHandler GET /api/admin/prayer_subscriptions
+// Oldest first, optionally only one ?channel=, ?chat_id=, ?kind= or ?active=; ?after_id=
+// pages forward. meta.channels lists the configured channels.
+func listPrayerSubscriptionsHandler(subscriptions services.PrayerSubscriptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.PrayerSubscriptionFilter{
+			Channel: c.Query("channel"),
+			ChatID:  c.Query("chat_id"),
+			Kind:    c.Query("kind"),
+			Limit:   parseIntMinMax(c.Query("limit"), 100, 1, 1000),
+		}
+		if v := c.Query("active"); v != "" {
+			active, err := strconv.ParseBool(v)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "active must be true or false")
+				return
+			}
+			filter.Active = &active
+		}
+		if v := c.Query("after_id"); v != "" {
+			afterID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid after_id")
+				return
+			}
+			filter.AfterID = afterID
+		}
+
+		list, err := subscriptions.ListSubscriptions(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list prayer subscriptions") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{
+			"count":    len(list),
+			"channels": chatbot.Default.EnabledChannels(),
+		}})
+	}
+}
+
+// getPrayerSubscriptionHandler GET /api/admin/prayer_subscriptions/:id
+func getPrayerSubscriptionHandler(subscriptions services.PrayerSubscriptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := subscriptions.GetSubscription(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get prayer subscription") {
+			return
+		}
+		response.OK(c, sub)
+	}
+}
+
+// createPrayerSubscriptionHandler POST /api/admin/prayer_subscriptions
+// The channel must be configured, and the chat must have started a conversation with the bot
+// for messages to arrive.
+func createPrayerSubscriptionHandler(subscriptions services.PrayerSubscriptionService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreatePrayerSubscriptionRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		sub, err := subscriptions.CreateSubscription(c.Request.Context(), req, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "create prayer subscription") {
+			return
+		}
+
+		logAuditEntry(c, "CREATE", "prayer_subscriptions", sub.ID, nil, sub, db)
+
+		response.Write(c, http.StatusCreated, response.Body{Data: sub, Message: "Prayer subscription created"})
+	}
+}
+
+// updatePrayerSubscriptionHandler PUT /api/admin/prayer_subscriptions/:id
+// Members left out keep their values; setting active to true again resumes a chat the job
+// deactivated.
+func updatePrayerSubscriptionHandler(subscriptions services.PrayerSubscriptionService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdatePrayerSubscriptionRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		sub, err := subscriptions.UpdateSubscription(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "update prayer subscription") {
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "prayer_subscriptions", sub.ID, nil, sub, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: sub, Message: "Prayer subscription updated"})
+	}
+}
+
+// deletePrayerSubscriptionHandler DELETE /api/admin/prayer_subscriptions/:id
+func deletePrayerSubscriptionHandler(subscriptions services.PrayerSubscriptionService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := subscriptions.DeleteSubscription(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "delete prayer subscription") {
+			return
+		}
+
+		logAuditEntry(c, "DELETE", "prayer_subscriptions", sub.ID, sub, nil, db)
+
+		response.Write(c, http.StatusOK, response.Body{Message: "Prayer subscription deleted"})
+	}
+}
+
+// testPrayerSubscriptionHandler POST /api/admin/prayer_subscriptions/:id/test
+// Sends today's message right away; a failed delivery answers 400 with the channel's reason.
+func testPrayerSubscriptionHandler(subscriptions services.PrayerSubscriptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := subscriptions.SendTest(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "test prayer subscription") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: sub, Message: "Message sent"})
+	}
+}
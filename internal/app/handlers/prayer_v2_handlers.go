@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/jsonenc"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrayerScheduleQuery selects a /api/v2 prayer schedule: one day (date), a month (year and
+// month) or a whole year (year alone)
+type PrayerScheduleQuery struct {
+	Province string `form:"province" binding:"required"`
+	City     string `form:"city" binding:"required"`
+	Date     string `form:"date"`
+	Year     int    `form:"year" binding:"omitempty,min=1,max=9999"`
+	Month    int    `form:"month" binding:"omitempty,min=1,max=12"`
+}
+
+// FastingScheduleQuery selects the /api/v2 schedule of a year's fasting period
+type FastingScheduleQuery struct {
+	Province string `form:"province" binding:"required"`
+	City     string `form:"city" binding:"required"`
+	Year     int    `form:"year" binding:"required,min=1,max=9999"`
+}
+
+// scheduleRange returns the first day and number of days q asks for
+func (q *PrayerScheduleQuery) scheduleRange() (time.Time, int, error) {
+	switch {
+	case q.Date != "" && q.Year == 0 && q.Month == 0:
+		day, err := time.Parse("2006-01-02", q.Date)
+		if err != nil {
+			return time.Time{}, 0, utils.NewValidationError("date must be in YYYY-MM-DD form")
+		}
+		return day, 1, nil
+	case q.Date == "" && q.Year != 0 && q.Month != 0:
+		first := time.Date(q.Year, time.Month(q.Month), 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(0, 1, -1).Day(), nil
+	case q.Date == "" && q.Year != 0:
+		first := time.Date(q.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return first, first.AddDate(1, 0, -1).YearDay(), nil
+	}
+	return time.Time{}, 0, utils.NewValidationError("Either date, or year with an optional month, is required")
+}
+
+// locationHashes decodes the province and city codes of a /api/v2 request into the MD5
+// codes the prayer service looks locations up by, which keeps both API versions on the
+// same lookups
+func locationHashes(codes *locationcode.Codec, province, city string) (string, string, error) {
+	provinceID, err := codes.Decode(locationcode.Province, province)
+	if err != nil {
+		return "", "", utils.NewValidationError("Invalid province code")
+	}
+	cityID, err := codes.Decode(locationcode.City, city)
+	if err != nil {
+		return "", "", utils.NewValidationError("Invalid city code")
+	}
+	return services.LocationHash(provinceID), services.LocationHash(cityID), nil
+}
+
+// prayerLocations gives locations their opaque codes
+func prayerLocations(codes *locationcode.Codec, kind locationcode.Kind, locations []services.Location) []models.PrayerLocation {
+	out := make([]models.PrayerLocation, len(locations))
+	for i, l := range locations {
+		out[i] = models.PrayerLocation{Code: codes.Encode(kind, l.ID), Name: l.Name}
+	}
+	return out
+}
+
+// prayerScheduleResponse pairs a schedule with the codes it was requested by, labelling its
+// times in the request's language
+func prayerScheduleResponse(ctx context.Context, province, city string, schedule *services.Schedule) *models.PrayerScheduleResponse {
+	labels := make(map[string]string, len(models.PrayerTimeNames))
+	for key, name := range models.PrayerTimeNames {
+		labels[key] = i18n.T(ctx, name)
+	}
+	return &models.PrayerScheduleResponse{
+		Province:  models.PrayerLocation{Code: province, Name: schedule.Province},
+		City:      models.PrayerLocation{Code: city, Name: schedule.City},
+		HijriYear: schedule.Hijriah,
+		Method:    schedule.Method,
+		Labels:    labels,
+		Days:      schedule.Days,
+	}
+}
+
+// listPrayerProvincesHandler GET /api/v2/prayer/provinces
+func listPrayerProvincesHandler(prayerService services.PrayerService, codes *locationcode.Codec, enc jsonenc.Encoder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provinces, _, err := cache.GetOrLoad(c.Request.Context(), database.Cache, cache.CacheKeyProvinceLocations, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]services.Location, error) {
+			return prayerService.ListProvinces(ctx)
+		})
+		if utils.HandleError(c, err, "retrieve provinces") {
+			return
+		}
+
+		renderJSON(c, enc, 200, prayerLocations(codes, locationcode.Province, provinces))
+	}
+}
+
+// listPrayerCitiesHandler GET /api/v2/prayer/cities?province=<code>
+func listPrayerCitiesHandler(prayerService services.PrayerService, codes *locationcode.Codec, enc jsonenc.Encoder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provinceID, err := codes.Decode(locationcode.Province, c.Query("province"))
+		if err != nil {
+			utils.HandleError(c, utils.NewValidationError("Invalid province code"), "retrieve cities")
+			return
+		}
+
+		cacheKey := fmt.Sprintf(cache.CacheKeyCityLocations, provinceID)
+		cities, _, err := cache.GetOrLoad(c.Request.Context(), database.Cache, cacheKey, cache.TTL("prayer", cache.TTLReference), func(ctx context.Context) ([]services.Location, error) {
+			return prayerService.ListCities(ctx, services.LocationHash(provinceID))
+		})
+		if utils.HandleError(c, err, "retrieve cities") {
+			return
+		}
+
+		renderJSON(c, enc, 200, prayerLocations(codes, locationcode.City, cities))
+	}
+}
+
+// getPrayerScheduleHandler GET /api/v2/prayer/schedule?province=&city=&date=2024-02-29
+// Also ?year=2024&month=2 for a month and ?year=2024 for a year; every range has the same shape.
+func getPrayerScheduleHandler(prayerService services.PrayerService, codes *locationcode.Codec, enc jsonenc.Encoder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q PrayerScheduleQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		start, days, err := q.scheduleRange()
+		if utils.HandleError(c, err, "get prayer schedule") {
+			return
+		}
+		provinceHash, cityHash, err := locationHashes(codes, q.Province, q.City)
+		if utils.HandleError(c, err, "get prayer schedule") {
+			return
+		}
+
+		schedule, err := prayerService.GetSchedule(c.Request.Context(), provinceHash, cityHash, start, days)
+		if utils.HandleError(c, err, "get prayer schedule") {
+			return
+		}
+
+		renderJSON(c, enc, 200, prayerScheduleResponse(c.Request.Context(), q.Province, q.City, schedule))
+	}
+}
+
+// getFastingScheduleHandler GET /api/v2/prayer/imsakiyah?province=&city=&year=2024
+func getFastingScheduleHandler(prayerService services.PrayerService, codes *locationcode.Codec, enc jsonenc.Encoder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q FastingScheduleQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		provinceHash, cityHash, err := locationHashes(codes, q.Province, q.City)
+		if utils.HandleError(c, err, "get imsakiyah schedule") {
+			return
+		}
+
+		schedule, err := prayerService.GetFastingSchedule(c.Request.Context(), q.Year, provinceHash, cityHash)
+		if utils.HandleError(c, err, "get imsakiyah schedule") {
+			return
+		}
+
+		renderJSON(c, enc, 200, prayerScheduleResponse(c.Request.Context(), q.Province, q.City, schedule))
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerPushDeviceHandler POST /api/v2/prayer/devices
+// Registers the app installation with the token, or moves it to another city. Reminders and
+// announcements are on unless turned off.
+func registerPushDeviceHandler(pushes services.PushService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RegisterPushDeviceRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		device, err := pushes.RegisterDevice(c.Request.Context(), req, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "register push device") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: device, Message: "Device registered"})
+	}
+}
+
+// unregisterPushDeviceHandler POST /api/v2/prayer/devices/unregister
+// The token travels in the body rather than the path, where it would be logged. Unknown
+// tokens succeed too.
+func unregisterPushDeviceHandler(pushes services.PushService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UnregisterPushDeviceRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		if utils.HandleError(c, pushes.UnregisterDevice(c.Request.Context(), req.Token), "unregister push device") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Message: "Device unregistered"})
+	}
+}
+
+// listPushDevicesHandler GET /api/admin/push/devices
+// Oldest first, optionally only one ?platform= or ?city= (location code); ?after_id= pages
+// forward.
+func listPushDevicesHandler(pushes services.PushService, codes *locationcode.Codec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.PushDeviceFilter{
+			Platform: c.Query("platform"),
+			Limit:    parseIntMinMax(c.Query("limit"), 100, 1, 1000),
+		}
+		if v := c.Query("city"); v != "" {
+			cityID, err := codes.Decode(locationcode.City, v)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid city code")
+				return
+			}
+			filter.CityIDs = []int{cityID}
+		}
+		if v := c.Query("after_id"); v != "" {
+			afterID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid after_id")
+				return
+			}
+			filter.AfterID = afterID
+		}
+
+		list, err := pushes.ListDevices(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list push devices") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// sendPushAnnouncementHandler POST /api/admin/push/announcements
+// Answers 202 once the request is checked; the devices with announcements on are sent to in
+// the background and the outcome is logged.
+func sendPushAnnouncementHandler(pushes services.PushService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PushAnnouncementRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		if utils.HandleError(c, pushes.Announce(c.Request.Context(), req), "send push announcement") {
+			return
+		}
+
+		logAuditEntry(c, "API_ACCESS", "push_devices", 0, nil, req, db)
+		response.Write(c, http.StatusAccepted, response.Body{Message: "Announcement is being sent"})
+	}
+}
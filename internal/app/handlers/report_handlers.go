@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path"
+
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
 	"adminbe/pkg/jasper"
-	"log"
-	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,105 +20,105 @@ import (
 var jasperClient *jasper.Client
 
 // InitJasperClient initializes the JasperServer client
-func InitJasperClient(configPath string) error {
-	config := &models.JasperServerConfig{
-		BaseURL:      getEnvOrDefault("JASPER_BASE_URL", "http://localhost:8080/jasperserver"),
-		Username:     getEnvOrDefault("JASPER_USERNAME", "jasperadmin"),
-		Password:     getEnvOrDefault("JASPER_PASSWORD", "password"),
-		Organization: getEnvOrDefault("JASPER_ORGANIZATION", ""),
-	}
-
-	jasperClient = jasper.NewClient(config)
-	log.Printf("JasperServer client initialized with base URL: %s", config.BaseURL)
-	return nil
-}
-
-// getEnvOrDefault gets environment variable or returns default value
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+func InitJasperClient(config models.JasperServerConfig) {
+	jasperClient = jasper.NewClient(&config)
+	slog.Info("JasperServer client initialized", "base_url", config.BaseURL)
 }
 
 // runReportHandler handles report execution requests
-func runReportHandler(c *gin.Context) {
-	var req models.JasperReportRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request format"})
-		return
-	}
-
-	// Execute report
-	response, reportData, err := jasperClient.RunReport(&req)
-	if err != nil {
-		log.Printf("Error running JasperServer report: %v", err)
-		c.JSON(500, gin.H{"error": "Failed to run report"})
-		return
-	}
-
-	// For binary content, return the file directly
-	if req.OutputFormat == "pdf" || req.OutputFormat == "excel" || req.OutputFormat == "pptx" ||
-		req.OutputFormat == "rtf" || req.OutputFormat == "docx" || req.OutputFormat == "xlsx" ||
-		req.OutputFormat == "xls" || req.OutputFormat == "png" {
+func runReportHandler(reportService services.ReportService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.JasperReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
 
-		contentType := "application/octet-stream"
-		filename := "report." + req.OutputFormat
+		// Execute report
+		userID := getUserIDFromContext(c)
+		result, reportData, err := reportService.RunReport(c.Request.Context(), &req, userID)
+		// Rejected file parameters say why; JasperServer failures stay a generic 500
+		var appErr *utils.AppError
+		if errors.As(err, &appErr) {
+			utils.HandleError(c, err, "run report")
+			return
+		}
+		if err != nil {
+			logger(c).Error("Error running JasperServer report", "error", err)
+			utils.RespondError(c, 500, "Failed to run report")
+			return
+		}
+		if req.Email && userID != nil {
+			mailReport(c, db, *userID, &req, reportData)
+		}
 
-		switch req.OutputFormat {
-		case "pdf":
-			contentType = "application/pdf"
-		case "excel", "xlsx", "xls":
-			contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
-		case "pptx":
-			contentType = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
-		case "docx":
-			contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
-		case "rtf":
-			contentType = "application/rtf"
-		case "png":
-			contentType = "image/png"
+		// For binary content, return the file directly
+		if contentType, ok := services.ReportContentType(req.OutputFormat); ok {
+			c.Header("Content-Disposition", "attachment; filename=report."+req.OutputFormat)
+			c.Header("Content-Type", contentType)
+			c.Data(200, contentType, reportData)
+			return
 		}
 
-		c.Header("Content-Disposition", "attachment; filename="+filename)
-		c.Header("Content-Type", contentType)
-		c.Data(200, contentType, reportData)
-		return
+		// For HTML/JSON content, return JSON response
+		response.OK(c, result)
 	}
+}
 
-	// For HTML/JSON content, return JSON response
-	c.JSON(200, response)
+// mailReport emails the report the user ran to them, attached unless it is larger than
+// MAIL_MAX_ATTACHMENT_BYTES
+func mailReport(c *gin.Context, db *sql.DB, userID uint64, req *models.JasperReportRequest, data []byte) {
+	var attachments []mail.Attachment
+	if limit := mail.Default.Config().MaxAttachmentBytes; len(data) > 0 && int64(len(data)) <= limit {
+		contentType, ok := services.ReportContentType(req.OutputFormat)
+		if !ok {
+			contentType = "text/html; charset=utf-8"
+		}
+		attachments = append(attachments, mail.Attachment{
+			Filename:    path.Base(req.ReportPath) + "." + req.OutputFormat,
+			ContentType: contentType,
+			Data:        data,
+		})
+	}
+	mailUser(c, db, userID, mail.TemplateReportReady, map[string]any{
+		"report_name":   path.Base(req.ReportPath),
+		"report_path":   req.ReportPath,
+		"output_format": req.OutputFormat,
+		"attached":      len(attachments) > 0,
+	}, attachments...)
 }
 
 // getServerInfoHandler retrieves JasperServer server information
 func getServerInfoHandler(c *gin.Context) {
-	info, err := jasperClient.GetServerInfo()
+	info, err := jasperClient.GetServerInfo(c.Request.Context())
 	if err != nil {
-		log.Printf("Error getting JasperServer info: %v", err)
-		c.JSON(500, gin.H{"error": "Failed to get server info"})
+		logger(c).Error("Error getting JasperServer info", "error", err)
+		utils.RespondError(c, 500, "Failed to get server info")
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"server_info": info,
-		"status":      "success",
+	response.Write(c, 200, response.Body{
+		Data:   info,
+		Legacy: gin.H{"server_info": info, "status": "success"},
 	})
 }
 
 // health check for JasperServer
 func jasperHealthHandler(c *gin.Context) {
-	_, err := jasperClient.GetServerInfo()
+	_, err := jasperClient.GetServerInfo(c.Request.Context())
 	if err != nil {
-		log.Printf("JasperServer health check failed: %v", err)
-		c.JSON(500, gin.H{
-			"status":  "error",
-			"message": "JasperServer connection failed",
+		logger(c).Error("JasperServer health check failed", "error", err)
+		response.WriteError(c, 500, response.Failure{
+			Code:    response.CodeExternal,
+			Message: "JasperServer connection failed",
+			Legacy:  gin.H{"status": "error", "message": "JasperServer connection failed"},
 		})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"status":  "ok",
-		"message": "JasperServer is healthy",
+	response.Write(c, 200, response.Body{
+		Data:    gin.H{"status": "ok"},
+		Message: "JasperServer is healthy",
+		Legacy:  gin.H{"status": "ok", "message": "JasperServer is healthy"},
 	})
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportSchedulesJob is the scheduler job that runs the due report schedules
+const reportSchedulesJob = "report_schedules"
+
+// syncCalendar starts the calendar_sync job so a schedule's change shows in the calendar
+// without waiting for the next run; a run in progress picks it up on the one after
+func syncCalendar(c *gin.Context, jobs *scheduler.Scheduler) {
+	if !calendar.Default.Enabled() {
+		return
+	}
+	if err := jobs.RunNow(calendarSyncJob); err != nil && !errors.Is(err, scheduler.ErrJobRunning) {
+		logger(c).Warn("Failed to start the calendar sync", "error", err)
+	}
+}
+
+// createReportScheduleHandler POST /api/reports/schedules
+// Schedules a report for the caller. Each run renders it as they would with POST
+// /api/reports/run and keeps the file as one of their attachments.
+func createReportScheduleHandler(schedules services.ReportScheduleService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.ReportScheduleRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		schedule, err := schedules.Create(c.Request.Context(), req, userID)
+		if utils.HandleError(c, err, "create report schedule") {
+			return
+		}
+		logAuditEntry(c, "CREATE", "report_schedules", schedule.ID, nil, schedule, db)
+		syncCalendar(c, jobs)
+		response.Write(c, http.StatusCreated, response.Body{Data: schedule, Message: "Report scheduled"})
+	}
+}
+
+// listReportSchedulesHandler GET /api/reports/schedules
+// The caller's schedules, newest first; administrators see everyone's, or one user's with
+// ?owner_id=. ?before_id= pages back.
+func listReportSchedulesHandler(schedules services.ReportScheduleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		filter := models.ReportScheduleFilter{Limit: parseIntMinMax(c.Query("limit"), 100, 1, 1000)}
+		for name, dest := range map[string]*uint64{"owner_id": &filter.OwnerID, "before_id": &filter.BeforeID} {
+			if v := c.Query(name); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					utils.RespondError(c, http.StatusBadRequest, "Invalid "+name)
+					return
+				}
+				*dest = n
+			}
+		}
+
+		list, err := schedules.List(c.Request.Context(), filter, userID, isAdmin(c))
+		if utils.HandleError(c, err, "list report schedules") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getReportScheduleHandler GET /api/reports/schedules/:id
+// The schedule, its next run and the outcome of its last
+func getReportScheduleHandler(schedules services.ReportScheduleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		schedule, err := schedules.Get(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "get report schedule") {
+			return
+		}
+		response.OK(c, schedule)
+	}
+}
+
+// updateReportScheduleHandler PUT /api/reports/schedules/:id
+// Replaces the schedule's definition; its next run is worked out again from now
+func updateReportScheduleHandler(schedules services.ReportScheduleService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		var req models.ReportScheduleRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		old, schedule, err := schedules.Update(c.Request.Context(), c.Param("id"), req, userID, isAdmin(c))
+		if utils.HandleError(c, err, "update report schedule") {
+			return
+		}
+		logAuditEntry(c, "UPDATE", "report_schedules", schedule.ID, old, schedule, db)
+		syncCalendar(c, jobs)
+		response.Write(c, http.StatusOK, response.Body{Data: schedule, Message: "Report schedule updated"})
+	}
+}
+
+// deleteReportScheduleHandler DELETE /api/reports/schedules/:id
+// Stops the schedule; the files of its past runs stay with the owner's attachments
+func deleteReportScheduleHandler(schedules services.ReportScheduleService, jobs *scheduler.Scheduler, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return
+		}
+		schedule, err := schedules.Delete(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+		if utils.HandleError(c, err, "delete report schedule") {
+			return
+		}
+		logAuditEntry(c, "DELETE", "report_schedules", schedule.ID, schedule, nil, db)
+		syncCalendar(c, jobs)
+		response.Write(c, http.StatusOK, response.Body{Message: "Report schedule deleted"})
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"adminbe/internal/app/models"
+
+	"github.com/gin-gonic/gin/render"
+)
+
+// These benchmarks compare building and rendering a users list page with fresh
+// allocations against a single sync.Pool with safe copy semantics. The pooled slice
+// cannot be handed to the response or the cache directly (both keep a reference after
+// the handler returns), so it has to be copied out, which costs the same allocation
+// pooling was meant to save. See the results before reintroducing pooling.
+
+// poolPageRows is the simulated list page size
+const poolPageRows = 50
+
+// discardWriter is an http.ResponseWriter that drops the body
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func newDiscardWriter() *discardWriter { return &discardWriter{header: make(http.Header)} }
+
+// fillUsers appends n sample users to dst
+func fillUsers(dst []models.User, n int) []models.User {
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		dst = append(dst, models.User{
+			ID:        uint64(i + 1),
+			Username:  "user",
+			Email:     "user@example.com",
+			Status:    1,
+			CreatedAt: &now,
+			UpdatedAt: &now,
+		})
+	}
+	return dst
+}
+
+// pageResponse builds the same shape userService.listUsers returns
+func pageResponse(users []models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"data": users,
+		"pagination": map[string]interface{}{
+			"page":        1,
+			"limit":       len(users),
+			"total":       len(users),
+			"total_pages": 1,
+			"has_next":    false,
+			"has_prev":    false,
+		},
+	}
+}
+
+var userSlicePool = sync.Pool{
+	New: func() interface{} { return new([]models.User) },
+}
+
+// BenchmarkUsersPage renders a users list page built in a fresh slice, and in a pooled one
+// copied out before it escapes
+func BenchmarkUsersPage(b *testing.B) {
+	b.Run("alloc", func(b *testing.B) {
+		w := newDiscardWriter()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			users := fillUsers(make([]models.User, 0, poolPageRows), poolPageRows)
+			if err := (render.JSON{Data: pageResponse(users)}).Render(w); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled_copy", func(b *testing.B) {
+		w := newDiscardWriter()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := userSlicePool.Get().(*[]models.User)
+			*buf = fillUsers((*buf)[:0], poolPageRows)
+
+			// Copy out before the slice escapes to the response or the cache
+			users := make([]models.User, len(*buf))
+			copy(users, *buf)
+			userSlicePool.Put(buf)
+
+			if err := (render.JSON{Data: pageResponse(users)}).Render(w); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+var responseMapPool = sync.Pool{
+	New: func() interface{} { return make(map[string]interface{}, 2) },
+}
+
+// BenchmarkResponseMap renders a small response map allocated per request, and one taken
+// from a pool
+func BenchmarkResponseMap(b *testing.B) {
+	users := fillUsers(nil, poolPageRows)
+	b.Run("alloc", func(b *testing.B) {
+		w := newDiscardWriter()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := (render.JSON{Data: map[string]interface{}{"data": users, "count": len(users)}}).Render(w); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		w := newDiscardWriter()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resp := responseMapPool.Get().(map[string]interface{})
+			resp["data"] = users
+			resp["count"] = len(users)
+			// Safe only because Render has finished writing before the map is reused
+			err := (render.JSON{Data: resp}).Render(w)
+			clear(resp)
+			responseMapPool.Put(resp)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
 	utils "adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,10 +20,10 @@ import (
 // listRoleInheritancesHandler GET /api/role_inheritances
 func listRoleInheritancesHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query("SELECT id, role_id, parent_role_id, created_at FROM role_inheritances")
+		rows, err := db.QueryContext(c.Request.Context(), "SELECT id, role_id, parent_role_id, created_at FROM role_inheritances WHERE "+repositories.RoleInTenant, tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error querying role inheritances: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role inheritances"})
+			logger(c).Error("Error querying role inheritances", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role inheritances")
 			return
 		}
 		defer rows.Close()
@@ -28,13 +32,13 @@ func listRoleInheritancesHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var ri models.RoleInheritance
 			if err := rows.Scan(&ri.ID, &ri.RoleID, &ri.ParentRoleID, &ri.CreatedAt); err != nil {
-				log.Printf("Error scanning role inheritance row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role inheritances"})
+				logger(c).Error("Error scanning role inheritance row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role inheritances")
 				return
 			}
 			inheritances = append(inheritances, ri)
 		}
-		c.JSON(http.StatusOK, gin.H{"data": inheritances})
+		response.OK(c, inheritances)
 	}
 }
 
@@ -44,22 +48,22 @@ func getRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		inheritanceID, err := strconv.ParseUint(id, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		var ri models.RoleInheritance
-		row := db.QueryRow("SELECT id, role_id, parent_role_id, created_at FROM role_inheritances WHERE id = ?", inheritanceID)
+		row := db.QueryRowContext(c.Request.Context(), "SELECT id, role_id, parent_role_id, created_at FROM role_inheritances WHERE id = ? AND "+repositories.RoleInTenant, inheritanceID, tenant.ID(c.Request.Context()))
 		err = row.Scan(&ri.ID, &ri.RoleID, &ri.ParentRoleID, &ri.CreatedAt)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role inheritance not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role inheritance not found")
 			return
 		} else if err != nil {
-			log.Printf("Error querying role inheritance: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error querying role inheritance", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": ri})
+		response.OK(c, ri)
 	}
 }
 
@@ -68,22 +72,25 @@ func createRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req models.CreateRoleInheritanceRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
+			return
+		}
+
+		if !requireInTenant(c, db, "roles", uint64(req.RoleID), "Role") ||
+			!requireInTenant(c, db, "roles", uint64(req.ParentRoleID), "Parent role") {
 			return
 		}
 
 		now := time.Now()
-		result, err := db.Exec("INSERT INTO role_inheritances (role_id, parent_role_id, created_at) VALUES (?, ?, ?)",
+		inheritanceID, err := database.InsertID(c.Request.Context(), db, "INSERT INTO role_inheritances (role_id, parent_role_id, created_at) VALUES (?, ?, ?)",
 			req.RoleID, req.ParentRoleID, now)
-		if err != nil {
-			log.Printf("Error inserting role inheritance: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role inheritance"})
+		if utils.HandleError(c, err, "create role inheritance") {
 			return
 		}
 
-		inheritanceID, _ := result.LastInsertId()
-		c.JSON(http.StatusCreated, gin.H{"message": "Role inheritance created", "id": inheritanceID})
+		respondCreatedID(c, "Role inheritance created", inheritanceID)
 		createAuditLog(db, nil, "CREATE", "role_inheritances", uint64(inheritanceID), nil, req)
+		events.EntityChanged("role_inheritances", events.ActionCreated, strconv.FormatUint(uint64(inheritanceID), 10))
 	}
 }
 
@@ -93,25 +100,32 @@ func updateRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		inheritanceID, err := strconv.ParseUint(id, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		var req models.UpdateRoleInheritanceRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
 		// Check if exists
 		var exists bool
-		err = db.QueryRow("SELECT 1 FROM role_inheritances WHERE id = ?", inheritanceID).Scan(&exists)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM role_inheritances WHERE id = ? AND "+repositories.RoleInTenant, inheritanceID, tenant.ID(c.Request.Context())).Scan(&exists)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role inheritance not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role inheritance not found")
 			return
 		} else if err != nil {
-			log.Printf("Error checking role inheritance existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking role inheritance existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
+			return
+		}
+
+		if req.RoleID != nil && !requireInTenant(c, db, "roles", uint64(*req.RoleID), "Role") {
+			return
+		}
+		if req.ParentRoleID != nil && !requireInTenant(c, db, "roles", uint64(*req.ParentRoleID), "Parent role") {
 			return
 		}
 
@@ -120,10 +134,10 @@ func updateRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 			RoleID       uint `json:"role_id"`
 			ParentRoleID uint `json:"parent_role_id"`
 		}
-		err = db.QueryRow("SELECT role_id, parent_role_id FROM role_inheritances WHERE id = ?", inheritanceID).Scan(&oldInheritance.RoleID, &oldInheritance.ParentRoleID)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT role_id, parent_role_id FROM role_inheritances WHERE id = ? AND "+repositories.RoleInTenant, inheritanceID, tenant.ID(c.Request.Context())).Scan(&oldInheritance.RoleID, &oldInheritance.ParentRoleID)
 		if err != nil {
-			log.Printf("Error getting old role inheritance values: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error getting old role inheritance values", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 
@@ -141,22 +155,21 @@ func updateRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if len(setParts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			utils.RespondError(c, http.StatusBadRequest, "No fields to update")
 			return
 		}
 
-		query := "UPDATE role_inheritances SET " + utils.JoinStrings(setParts, ", ") + " WHERE id = ?"
-		args = append(args, inheritanceID)
+		query := "UPDATE role_inheritances SET " + utils.JoinStrings(setParts, ", ") + " WHERE id = ? AND " + repositories.RoleInTenant
+		args = append(args, inheritanceID, tenant.ID(c.Request.Context()))
 
-		_, err = db.Exec(query, args...)
-		if err != nil {
-			log.Printf("Error updating role inheritance: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		_, err = db.ExecContext(c.Request.Context(), query, args...)
+		if utils.HandleError(c, database.TranslateError(err), "update role inheritance") {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role inheritance updated"})
+		response.Write(c, http.StatusOK, response.Body{Message: "Role inheritance updated"})
 		createAuditLog(db, nil, "UPDATE", "role_inheritances", inheritanceID, oldInheritance, req)
+		events.EntityChanged("role_inheritances", events.ActionUpdated, strconv.FormatUint(uint64(inheritanceID), 10))
 	}
 }
 
@@ -166,7 +179,7 @@ func deleteRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		inheritanceID, err := strconv.ParseUint(id, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -175,24 +188,25 @@ func deleteRoleInheritanceHandler(db *sql.DB) gin.HandlerFunc {
 			RoleID       uint `json:"role_id"`
 			ParentRoleID uint `json:"parent_role_id"`
 		}
-		err = db.QueryRow("SELECT role_id, parent_role_id FROM role_inheritances WHERE id = ?", inheritanceID).Scan(&oldInheritance.RoleID, &oldInheritance.ParentRoleID)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT role_id, parent_role_id FROM role_inheritances WHERE id = ? AND "+repositories.RoleInTenant, inheritanceID, tenant.ID(c.Request.Context())).Scan(&oldInheritance.RoleID, &oldInheritance.ParentRoleID)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role inheritance not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role inheritance not found")
 			return
 		} else if err != nil {
-			log.Printf("Error getting old role inheritance values: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error getting old role inheritance values", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 
-		_, err = db.Exec("DELETE FROM role_inheritances WHERE id = ?", inheritanceID)
+		_, err = db.ExecContext(c.Request.Context(), "DELETE FROM role_inheritances WHERE id = ? AND "+repositories.RoleInTenant, inheritanceID, tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error deleting role inheritance: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Delete failed"})
+			logger(c).Error("Error deleting role inheritance", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Delete failed")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role inheritance deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "Role inheritance deleted"})
 		createAuditLog(db, nil, "DELETE", "role_inheritances", inheritanceID, oldInheritance, nil)
+		events.EntityChanged("role_inheritances", events.ActionDeleted, strconv.FormatUint(uint64(inheritanceID), 10))
 	}
 }
@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,10 +22,10 @@ import (
 // listRoleMenusHandler GET /api/role_menu
 func listRoleMenusHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query("SELECT role_id, menu_id, deleted_at, deleted_by FROM role_menu WHERE deleted_at IS NULL")
+		rows, err := db.QueryContext(c.Request.Context(), "SELECT role_id, menu_id, deleted_at, deleted_by FROM role_menu WHERE deleted_at IS NULL AND "+repositories.RoleInTenant, tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error querying role_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role-menu assignments"})
+			logger(c).Error("Error querying role_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role-menu assignments")
 			return
 		}
 		defer rows.Close()
@@ -28,13 +34,13 @@ func listRoleMenusHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var rm models.RoleMenu
 			if err := rows.Scan(&rm.RoleID, &rm.MenuID, &rm.DeletedAt, &rm.DeletedBy); err != nil {
-				log.Printf("Error scanning role_menu row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role-menu assignments"})
+				logger(c).Error("Error scanning role_menu row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role-menu assignments")
 				return
 			}
 			roleMenus = append(roleMenus, rm)
 		}
-		c.JSON(http.StatusOK, gin.H{"data": roleMenus})
+		response.OK(c, roleMenus)
 	}
 }
 
@@ -45,27 +51,27 @@ func getRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
 		var rm models.RoleMenu
-		row := db.QueryRow("SELECT role_id, menu_id, deleted_at, deleted_by FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL", uint(roleID), uint(menuID))
+		row := db.QueryRowContext(c.Request.Context(), "SELECT role_id, menu_id, deleted_at, deleted_by FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.RoleInTenant, uint(roleID), uint(menuID), tenant.ID(c.Request.Context()))
 		err = row.Scan(&rm.RoleID, &rm.MenuID, &rm.DeletedAt, &rm.DeletedBy)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role-menu assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role-menu assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error querying role_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error querying role_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": rm})
+		response.OK(c, rm)
 	}
 }
 
@@ -74,33 +80,36 @@ func createRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req models.CreateRoleMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
+			return
+		}
+
+		if !requireInTenant(c, db, "roles", uint64(req.RoleID), "Role") || !requireInTenant(c, db, "menu", uint64(req.MenuID), "Menu") {
 			return
 		}
 
 		// Check if already exists active
 		var exists bool
-		err := db.QueryRow("SELECT 1 FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL", req.RoleID, req.MenuID).Scan(&exists)
+		err := db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.RoleInTenant, req.RoleID, req.MenuID, tenant.ID(c.Request.Context())).Scan(&exists)
 		if err != nil && err != sql.ErrNoRows {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 		if exists {
-			c.JSON(http.StatusConflict, gin.H{"error": "Role-menu assignment already exists"})
+			utils.RespondError(c, http.StatusConflict, "Role-menu assignment already exists")
 			return
 		}
 
-		_, err = db.Exec("INSERT INTO role_menu (role_id, menu_id, deleted_at, deleted_by) VALUES (?, ?, ?, ?)",
+		_, err = db.ExecContext(c.Request.Context(), "INSERT INTO role_menu (role_id, menu_id, deleted_at, deleted_by) VALUES (?, ?, ?, ?)",
 			req.RoleID, req.MenuID, nil, nil)
-		if err != nil {
-			log.Printf("Error inserting role_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role-menu assignment"})
+		if utils.HandleError(c, database.TranslateError(err), "create role-menu assignment") {
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Role-menu assignment created"})
+		response.Write(c, http.StatusCreated, response.Body{Message: "Role-menu assignment created"})
 		createAuditLog(db, nil, "CREATE", "role_menu", uint64(req.RoleID), nil, req)
+		events.EntityChanged("role_menu", events.ActionCreated, fmt.Sprintf("%d:%d", req.RoleID, req.MenuID))
 	}
 }
 
@@ -111,30 +120,37 @@ func updateRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
 		var req models.UpdateRoleMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
 		// Check if exists
 		var exists bool
-		err = db.QueryRow("SELECT 1 FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL", uint(roleID), uint(menuID)).Scan(&exists)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM role_menu WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.RoleInTenant, uint(roleID), uint(menuID), tenant.ID(c.Request.Context())).Scan(&exists)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role-menu assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role-menu assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
+			return
+		}
+
+		if req.RoleID != nil && !requireInTenant(c, db, "roles", uint64(*req.RoleID), "Role") {
+			return
+		}
+		if req.MenuID != nil && !requireInTenant(c, db, "menu", uint64(*req.MenuID), "Menu") {
 			return
 		}
 
@@ -160,22 +176,21 @@ func updateRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if len(setParts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			utils.RespondError(c, http.StatusBadRequest, "No fields to update")
 			return
 		}
 
-		query := "UPDATE role_menu SET " + strings.Join(setParts, ", ") + " WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL"
-		args = append(args, uint(roleID), uint(menuID))
+		query := "UPDATE role_menu SET " + strings.Join(setParts, ", ") + " WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND " + repositories.RoleInTenant
+		args = append(args, uint(roleID), uint(menuID), tenant.ID(c.Request.Context()))
 
-		_, err = db.Exec(query, args...)
-		if err != nil {
-			log.Printf("Error updating role_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		_, err = db.ExecContext(c.Request.Context(), query, args...)
+		if utils.HandleError(c, database.TranslateError(err), "update role-menu assignment") {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role-menu assignment updated"})
+		response.Write(c, http.StatusOK, response.Body{Message: "Role-menu assignment updated"})
 		createAuditLog(db, nil, "UPDATE", "role_menu", uint64(roleID), oldRoleMenu, req)
+		events.EntityChanged("role_menu", events.ActionUpdated, fmt.Sprintf("%d:%d", roleID, menuID))
 	}
 }
 
@@ -186,12 +201,12 @@ func deleteRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
@@ -203,14 +218,15 @@ func deleteRoleMenuHandler(db *sql.DB) gin.HandlerFunc {
 		oldRoleMenu.RoleID = uint(roleID)
 		oldRoleMenu.MenuID = uint(menuID)
 
-		_, err = db.Exec("UPDATE role_menu SET deleted_at = ? WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL", time.Now(), uint(roleID), uint(menuID))
+		_, err = db.ExecContext(c.Request.Context(), "UPDATE role_menu SET deleted_at = ? WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.RoleInTenant, time.Now(), uint(roleID), uint(menuID), tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error soft deleting role_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Soft delete failed"})
+			logger(c).Error("Error soft deleting role_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Soft delete failed")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role-menu assignment deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "Role-menu assignment deleted"})
 		createAuditLog(db, nil, "DELETE", "role_menu", uint64(roleID), oldRoleMenu, nil)
+		events.EntityChanged("role_menu", events.ActionDeleted, fmt.Sprintf("%d:%d", roleID, menuID))
 	}
 }
@@ -2,41 +2,67 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
 	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
 	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // listRolesHandler GET /api/roles
+// Takes ?sort=name:asc and ?fields=id,name; Accept: text/csv gets the list as CSV.
 func listRolesHandler(roleService services.RoleService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roles, err := roleService.ListRoles()
+		q, ok := parseListQuery(c, repositories.RoleListSpec, "list roles")
+		if !ok {
+			return
+		}
+
+		roles, err := roleService.ListRoles(c.Request.Context(), q.Sort)
 		if err != nil {
-			log.Printf("Error listing roles: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roles"})
+			logger(c).Error("Error listing roles", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve roles")
+			return
+		}
+
+		if wantsCSV(c) {
+			stream := newCSVStream(c, "roles", csvColumns(repositories.RoleListSpec, q.Fields))
+			for i := range roles {
+				if err = stream.Write(&roles[i]); err != nil {
+					break
+				}
+			}
+			stream.Close("list roles", err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": roles})
+
+		data, err := selectFields(roles, q.Fields)
+		if utils.HandleError(c, err, "list roles") {
+			return
+		}
+		response.OK(c, data)
 	}
 }
 
 // getRoleHandler GET /api/roles/:id
+// Answers 304 when If-None-Match holds the current ETag.
 func getRoleHandler(roleService services.RoleService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		role, err := roleService.GetRole(id)
+		role, err := roleService.GetRole(c.Request.Context(), id)
 		if handleServiceError(c, err, "role") {
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": role})
+		response.OKConditional(c, role)
 	}
 }
 
@@ -48,7 +74,7 @@ func createRoleHandler(roleService services.RoleService, db *sql.DB) gin.Handler
 			return
 		}
 
-		role, err := roleService.CreateRole(req)
+		role, err := roleService.CreateRole(c.Request.Context(), req)
 		if err != nil {
 			handleServiceError(c, err, "create role")
 			return
@@ -57,7 +83,7 @@ func createRoleHandler(roleService services.RoleService, db *sql.DB) gin.Handler
 		// Audit logging
 		logAuditEntry(c, "CREATE", "roles", uint64(role.ID), nil, req, db)
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Role created", "data": role})
+		response.Write(c, http.StatusCreated, response.Body{Data: role, Message: "Role created"})
 	}
 }
 
@@ -67,25 +93,25 @@ func updateRoleHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		roleID, err := strconv.ParseUint(id, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
 		var req models.UpdateRoleRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
 		// Check if exists
 		var exists bool
-		err = db.QueryRow("SELECT 1 FROM roles WHERE id = ? AND deleted_at IS NULL", uint(roleID)).Scan(&exists)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM roles WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL", uint(roleID), tenant.ID(c.Request.Context())).Scan(&exists)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role not found")
 			return
 		} else if err != nil {
-			log.Printf("Error checking role existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking role existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 
@@ -94,10 +120,10 @@ func updateRoleHandler(db *sql.DB) gin.HandlerFunc {
 			Name        string  `json:"name"`
 			Description *string `json:"description"`
 		}
-		err = db.QueryRow("SELECT name, description FROM roles WHERE id = ?", uint(roleID)).Scan(&oldRole.Name, &oldRole.Description)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT name, description FROM roles WHERE id = ? AND tenant_id = ?", uint(roleID), tenant.ID(c.Request.Context())).Scan(&oldRole.Name, &oldRole.Description)
 		if err != nil {
-			log.Printf("Error getting old role values: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error getting old role values", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 
@@ -115,31 +141,59 @@ func updateRoleHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if len(setParts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			utils.RespondError(c, http.StatusBadRequest, "No fields to update")
 			return
 		}
 
 		setParts = append(setParts, "updated_at = ?")
 		args = append(args, time.Now())
 
-		query := "UPDATE roles SET " + utils.JoinStrings(setParts, ", ") + " WHERE id = ? AND deleted_at IS NULL"
-		args = append(args, uint(roleID))
+		query := "UPDATE roles SET " + utils.JoinStrings(setParts, ", ") + " WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL"
+		args = append(args, uint(roleID), tenant.ID(c.Request.Context()))
 
-		_, err = db.Exec(query, args...)
+		_, err = db.ExecContext(c.Request.Context(), query, args...)
 		if err != nil {
-			log.Printf("Error updating role: %v", err)
-			if strings.Contains(err.Error(), "1062") {
-				c.JSON(http.StatusConflict, gin.H{"error": "Role name already exists"})
+			logger(c).Error("Error updating role", "error", err)
+			if database.IsDuplicateKey(err) {
+				utils.RespondError(c, http.StatusConflict, "Role name already exists")
 			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+				utils.RespondError(c, http.StatusInternalServerError, "Update failed")
 			}
 			return
 		}
 
 		// Audit logging
 		logAuditEntry(c, "UPDATE", "roles", uint64(roleID), oldRole, req, db)
+		events.EntityChanged("roles", events.ActionUpdated, id)
+
+		response.Write(c, http.StatusOK, response.Body{Message: "Role updated"})
+	}
+}
+
+// patchRoleHandler PATCH /api/roles/:id
+// Takes a JSON Merge Patch: only the members present change, and "description": null
+// clears the description.
+func patchRoleHandler(roleService services.RoleService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch models.PatchRoleRequest
+		if !bindMergePatch(c, &patch) {
+			return
+		}
+		changes, err := patch.Changes()
+		if err != nil {
+			utils.HandleError(c, utils.NewValidationError(err.Error()), "patch role")
+			return
+		}
+
+		role, err := roleService.PatchRole(c.Request.Context(), c.Param("id"), changes)
+		if handleServiceError(c, err, "patch role") {
+			return
+		}
+
+		// Audit logging
+		logAuditEntry(c, "UPDATE", "roles", uint64(role.ID), nil, changes, db)
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+		response.Write(c, http.StatusOK, response.Body{Data: role, Message: "Role updated"})
 	}
 }
 
@@ -149,7 +203,7 @@ func deleteRoleHandler(db *sql.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		roleID, err := strconv.ParseUint(id, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid ID")
 			return
 		}
 
@@ -158,28 +212,29 @@ func deleteRoleHandler(db *sql.DB) gin.HandlerFunc {
 			Name        string  `json:"name"`
 			Description *string `json:"description"`
 		}
-		err = db.QueryRow("SELECT name, description FROM roles WHERE id = ?", uint(roleID)).Scan(&oldRole.Name, &oldRole.Description)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT name, description FROM roles WHERE id = ? AND tenant_id = ?", uint(roleID), tenant.ID(c.Request.Context())).Scan(&oldRole.Name, &oldRole.Description)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			utils.RespondError(c, http.StatusNotFound, "Role not found")
 			return
 		} else if err != nil {
-			log.Printf("Error getting old role values: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error getting old role values", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 
 		// Perform soft delete
-		_, err = db.Exec("UPDATE roles SET deleted_at = ?, updated_at = ?, deleted_by = ? WHERE id = ? AND deleted_at IS NULL",
-			time.Now(), time.Now(), getUserIDFromContext(c), uint(roleID))
+		_, err = db.ExecContext(c.Request.Context(), "UPDATE roles SET deleted_at = ?, updated_at = ?, deleted_by = ? WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL",
+			time.Now(), time.Now(), getUserIDFromContext(c), uint(roleID), tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error soft deleting role: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Soft delete failed"})
+			logger(c).Error("Error soft deleting role", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Soft delete failed")
 			return
 		}
 
 		// Audit logging
 		logAuditEntry(c, "DELETE", "roles", uint64(roleID), oldRole, nil, db)
+		events.EntityChanged("roles", events.ActionDeleted, id)
 
-		c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "Role deleted"})
 	}
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RuntimeSettings is the GET /api/admin/runtime body, and the audited old/new values
+type RuntimeSettings struct {
+	LogLevel     string             `json:"log_level"`
+	CacheEnabled bool               `json:"cache_enabled"`
+	Features     []features.Feature `json:"features"`
+}
+
+// UpdateRuntimeSettingsRequest changes only the fields that are present
+type UpdateRuntimeSettingsRequest struct {
+	LogLevel     *string         `json:"log_level"`
+	CacheEnabled *bool           `json:"cache_enabled"`
+	Features     map[string]bool `json:"features"`
+}
+
+// currentRuntimeSettings snapshots the settings of this process
+func currentRuntimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		LogLevel:     logging.Level().String(),
+		CacheEnabled: cache.Enabled(),
+		Features:     features.List(),
+	}
+}
+
+// getRuntimeSettingsHandler GET /api/admin/runtime
+func getRuntimeSettingsHandler(c *gin.Context) {
+	response.OK(c, currentRuntimeSettings())
+}
+
+// updateRuntimeSettingsHandler PATCH /api/admin/runtime
+// The whole request is validated before anything changes, and every change is audited.
+// Settings are per process: behind a load balancer, apply the change to each instance.
+func updateRuntimeSettingsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpdateRuntimeSettingsRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		var level slog.Level
+		if req.LogLevel != nil {
+			if err := level.UnmarshalText([]byte(*req.LogLevel)); err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "log_level must be debug, info, warn or error")
+				return
+			}
+		}
+		for name := range req.Features {
+			if !features.Known(name) {
+				utils.RespondError(c, http.StatusBadRequest, "Unknown feature: "+name)
+				return
+			}
+		}
+
+		before := currentRuntimeSettings()
+		if req.LogLevel != nil {
+			logging.SetLevel(level)
+		}
+		if req.CacheEnabled != nil {
+			cache.SetEnabled(*req.CacheEnabled)
+		}
+		for name, enabled := range req.Features {
+			features.Set(name, enabled)
+		}
+		after := currentRuntimeSettings()
+
+		logAuditEntry(c, "UPDATE", "runtime_settings", 0, before, after, db)
+		logger(c).Warn("Runtime settings changed", "user_id", getUserIDFromContext(c),
+			"log_level", after.LogLevel, "cache_enabled", after.CacheEnabled)
+
+		response.Write(c, http.StatusOK, response.Body{Data: after, Message: "Runtime settings updated"})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scimContentType is the media type of SCIM requests and responses
+const scimContentType = "application/scim+json"
+
+// Page sizes of the SCIM lists: the default, and the largest a client may ask for
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// scimFilterPattern matches the one filter form supported: <attribute> eq "<value>"
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([A-Za-z.]+)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// scimAuth admits requests bearing token, the shared secret configured in the identity
+// provider. Without a token SCIM is turned off and every request is refused.
+func scimAuth(token string) gin.HandlerFunc {
+	want := sha256.Sum256([]byte(token))
+	return func(c *gin.Context) {
+		if token == "" {
+			writeSCIMError(c, http.StatusUnauthorized, "", "SCIM provisioning is not enabled")
+			c.Abort()
+			return
+		}
+		got := sha256.Sum256([]byte(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")))
+		if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			writeSCIMError(c, http.StatusUnauthorized, "", "Invalid SCIM token")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// writeSCIM answers with a SCIM resource
+func writeSCIM(c *gin.Context, status int, v any) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, v)
+}
+
+// writeSCIMError answers with a SCIM error
+func writeSCIMError(c *gin.Context, status int, scimType, detail string) {
+	writeSCIM(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// handleSCIMError answers err as a SCIM error, like utils.HandleError does for the API;
+// it reports whether there was an error
+func handleSCIMError(c *gin.Context, err error, operation string) bool {
+	if err == nil {
+		return false
+	}
+	var appErr *utils.AppError
+	if !errors.As(err, &appErr) {
+		logger(c).Error("SCIM request failed", "operation", operation, "error", err)
+		writeSCIMError(c, http.StatusInternalServerError, "", "Internal server error")
+		return true
+	}
+
+	scimType := ""
+	switch appErr.Type {
+	case utils.ErrorTypeConflict:
+		scimType = "uniqueness"
+	case utils.ErrorTypeValidation:
+		scimType = "invalidValue"
+	}
+	if appErr.Internal != nil {
+		logger(c).Error("SCIM request failed", "operation", operation, "error", appErr.Internal)
+	} else {
+		logger(c).Warn("SCIM request rejected", "operation", operation, "message", appErr.Message)
+	}
+	writeSCIMError(c, appErr.Code, scimType, appErr.Message)
+	return true
+}
+
+// bindSCIM decodes a SCIM request body into v, answering 400 when it cannot
+func bindSCIM(c *gin.Context, v any) bool {
+	if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", "Request body is not valid JSON")
+		return false
+	}
+	return true
+}
+
+// scimPage reads ?startIndex (1-based) and ?count
+func scimPage(c *gin.Context) (startIndex, count int) {
+	startIndex = parseIntMinMax(c.Query("startIndex"), 1, 1, 1<<30)
+	count = parseIntMinMax(c.Query("count"), scimDefaultCount, 0, scimMaxCount)
+	return startIndex, count
+}
+
+// scimFilterValue reads ?filter=<attribute> eq "<value>", which may only name attribute.
+// An empty filter gives "".
+func scimFilterValue(c *gin.Context, attribute string) (string, bool) {
+	filter := c.Query("filter")
+	if filter == "" {
+		return "", true
+	}
+	m := scimFilterPattern.FindStringSubmatch(filter)
+	var value string
+	if m == nil || !strings.EqualFold(m[1], attribute) || json.Unmarshal([]byte(m[2]), &value) != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidFilter",
+			fmt.Sprintf(`Only filter=%s eq "<value>" is supported`, attribute))
+		return "", false
+	}
+	return value, true
+}
+
+// scimLocation is the URL of a SCIM resource, as seen by the client
+func scimLocation(c *gin.Context, resourceType, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/scim/v2/%s/%s", scheme, c.Request.Host, resourceType, id)
+}
+
+// writeSCIMUser answers with user, setting its location
+func writeSCIMUser(c *gin.Context, status int, user *models.SCIMUser) {
+	user.Meta.Location = scimLocation(c, "Users", user.ID)
+	if status == http.StatusCreated {
+		c.Header("Location", user.Meta.Location)
+	}
+	writeSCIM(c, status, user)
+}
+
+// writeSCIMGroup answers with group, setting its location
+func writeSCIMGroup(c *gin.Context, status int, group *models.SCIMGroup) {
+	group.Meta.Location = scimLocation(c, "Groups", group.ID)
+	if status == http.StatusCreated {
+		c.Header("Location", group.Meta.Location)
+	}
+	writeSCIM(c, status, group)
+}
+
+// scimAuditID is the record ID of a SCIM resource in the audit log
+func scimAuditID(id string) uint64 {
+	n, _ := strconv.ParseUint(id, 10, 64)
+	return n
+}
+
+// scimServiceProviderConfigHandler GET /scim/v2/ServiceProviderConfig
+func scimServiceProviderConfigHandler(c *gin.Context) {
+	supported := func(b bool) gin.H { return gin.H{"supported": b} }
+	writeSCIM(c, http.StatusOK, gin.H{
+		"schemas":        []string{models.SCIMSchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "The SCIM_TOKEN of this server, as Authorization: Bearer <token>",
+		}},
+		"meta": gin.H{"resourceType": "ServiceProviderConfig"},
+	})
+}
+
+// scimListUsersHandler GET /scim/v2/Users
+// Pages with ?startIndex and ?count; ?filter=userName eq "<name>" finds one user.
+func scimListUsersHandler(scimService services.SCIMService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userName, ok := scimFilterValue(c, "userName")
+		if !ok {
+			return
+		}
+		startIndex, count := scimPage(c)
+		list, err := scimService.ListUsers(c.Request.Context(), userName, startIndex, count)
+		if handleSCIMError(c, err, "list SCIM users") {
+			return
+		}
+		for _, r := range list.Resources {
+			user := r.(models.SCIMUser)
+			user.Meta.Location = scimLocation(c, "Users", user.ID)
+		}
+		writeSCIM(c, http.StatusOK, list)
+	}
+}
+
+// scimGetUserHandler GET /scim/v2/Users/:id
+func scimGetUserHandler(scimService services.SCIMService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := scimService.GetUser(c.Request.Context(), c.Param("id"))
+		if handleSCIMError(c, err, "get SCIM user") {
+			return
+		}
+		writeSCIMUser(c, http.StatusOK, user)
+	}
+}
+
+// scimCreateUserHandler POST /scim/v2/Users
+func scimCreateUserHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in models.SCIMUser
+		if !bindSCIM(c, &in) {
+			return
+		}
+		user, err := scimService.CreateUser(c.Request.Context(), in)
+		if handleSCIMError(c, err, "create SCIM user") {
+			return
+		}
+		createAuditLog(db, nil, "CREATE", "users", scimAuditID(user.ID), nil, user)
+		writeSCIMUser(c, http.StatusCreated, user)
+	}
+}
+
+// scimReplaceUserHandler PUT /scim/v2/Users/:id
+func scimReplaceUserHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in models.SCIMUser
+		if !bindSCIM(c, &in) {
+			return
+		}
+		user, err := scimService.ReplaceUser(c.Request.Context(), c.Param("id"), in)
+		if handleSCIMError(c, err, "replace SCIM user") {
+			return
+		}
+		createAuditLog(db, nil, "UPDATE", "users", scimAuditID(user.ID), nil, user)
+		writeSCIMUser(c, http.StatusOK, user)
+	}
+}
+
+// scimPatchUserHandler PATCH /scim/v2/Users/:id
+// Identity providers deprovision with {"op": "replace", "path": "active", "value": false}.
+func scimPatchUserHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SCIMPatchRequest
+		if !bindSCIM(c, &req) {
+			return
+		}
+		user, err := scimService.PatchUser(c.Request.Context(), c.Param("id"), req.Operations)
+		if handleSCIMError(c, err, "patch SCIM user") {
+			return
+		}
+		createAuditLog(db, nil, "UPDATE", "users", scimAuditID(user.ID), nil, user)
+		writeSCIMUser(c, http.StatusOK, user)
+	}
+}
+
+// scimDeleteUserHandler DELETE /scim/v2/Users/:id
+func scimDeleteUserHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		user, err := scimService.GetUser(ctx, c.Param("id"))
+		if handleSCIMError(c, err, "delete SCIM user") {
+			return
+		}
+		if handleSCIMError(c, scimService.DeleteUser(ctx, user.ID), "delete SCIM user") {
+			return
+		}
+		createAuditLog(db, nil, "DELETE", "users", scimAuditID(user.ID), user, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// scimListGroupsHandler GET /scim/v2/Groups
+// Pages with ?startIndex and ?count; ?filter=displayName eq "<name>" finds one group, and
+// ?excludedAttributes=members leaves the members out.
+func scimListGroupsHandler(scimService services.SCIMService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		displayName, ok := scimFilterValue(c, "displayName")
+		if !ok {
+			return
+		}
+		startIndex, count := scimPage(c)
+		withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+		list, err := scimService.ListGroups(c.Request.Context(), displayName, startIndex, count, withMembers)
+		if handleSCIMError(c, err, "list SCIM groups") {
+			return
+		}
+		for _, r := range list.Resources {
+			group := r.(models.SCIMGroup)
+			group.Meta.Location = scimLocation(c, "Groups", group.ID)
+		}
+		writeSCIM(c, http.StatusOK, list)
+	}
+}
+
+// scimGetGroupHandler GET /scim/v2/Groups/:id
+func scimGetGroupHandler(scimService services.SCIMService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := scimService.GetGroup(c.Request.Context(), c.Param("id"))
+		if handleSCIMError(c, err, "get SCIM group") {
+			return
+		}
+		writeSCIMGroup(c, http.StatusOK, group)
+	}
+}
+
+// scimCreateGroupHandler POST /scim/v2/Groups
+func scimCreateGroupHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in models.SCIMGroup
+		if !bindSCIM(c, &in) {
+			return
+		}
+		group, err := scimService.CreateGroup(c.Request.Context(), in)
+		if handleSCIMError(c, err, "create SCIM group") {
+			return
+		}
+		createAuditLog(db, nil, "CREATE", "roles", scimAuditID(group.ID), nil, group)
+		checkSCIMGroupGrants(c, nil, group)
+		writeSCIMGroup(c, http.StatusCreated, group)
+	}
+}
+
+// scimReplaceGroupHandler PUT /scim/v2/Groups/:id
+func scimReplaceGroupHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in models.SCIMGroup
+		if !bindSCIM(c, &in) {
+			return
+		}
+		before, err := scimService.GetGroup(c.Request.Context(), c.Param("id"))
+		if handleSCIMError(c, err, "replace SCIM group") {
+			return
+		}
+		group, err := scimService.ReplaceGroup(c.Request.Context(), c.Param("id"), in)
+		if handleSCIMError(c, err, "replace SCIM group") {
+			return
+		}
+		createAuditLog(db, nil, "UPDATE", "roles", scimAuditID(group.ID), before, group)
+		checkSCIMGroupGrants(c, before.Members, group)
+		writeSCIMGroup(c, http.StatusOK, group)
+	}
+}
+
+// scimPatchGroupHandler PATCH /scim/v2/Groups/:id
+func scimPatchGroupHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SCIMPatchRequest
+		if !bindSCIM(c, &req) {
+			return
+		}
+		before, err := scimService.GetGroup(c.Request.Context(), c.Param("id"))
+		if handleSCIMError(c, err, "patch SCIM group") {
+			return
+		}
+		group, err := scimService.PatchGroup(c.Request.Context(), c.Param("id"), req.Operations)
+		if handleSCIMError(c, err, "patch SCIM group") {
+			return
+		}
+		createAuditLog(db, nil, "UPDATE", "roles", scimAuditID(group.ID), before, group)
+		checkSCIMGroupGrants(c, before.Members, group)
+		writeSCIMGroup(c, http.StatusOK, group)
+	}
+}
+
+// scimDeleteGroupHandler DELETE /scim/v2/Groups/:id
+func scimDeleteGroupHandler(scimService services.SCIMService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		group, err := scimService.GetGroup(ctx, c.Param("id"))
+		if handleSCIMError(c, err, "delete SCIM group") {
+			return
+		}
+		if handleSCIMError(c, scimService.DeleteGroup(ctx, group.ID), "delete SCIM group") {
+			return
+		}
+		createAuditLog(db, nil, "DELETE", "roles", scimAuditID(group.ID), group, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/broadcast"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordSecurityEvent is the security event handler SetupRoutes installs: events are stored,
+// pushed to the live stream of every instance and sent to the webhooks subscribed to
+// security_event.created
+func recordSecurityEvent(securityEvents services.SecurityEventService) secevents.Handler {
+	return func(ctx context.Context, e secevents.Event) {
+		event, err := securityEvents.Record(ctx, e)
+		if err != nil {
+			slog.Error("Failed to record security event", "type", e.Type, "error", err)
+			return
+		}
+		broadcast.Default.Publish(broadcast.TypeSecurity, event)
+		if webhooks != nil {
+			webhooks.Publish("security_event.created", strconv.FormatUint(event.ID, 10))
+		}
+	}
+}
+
+// raiseSecurityEvent raises e for the request once its writes are committed, filling in the
+// client address and the signed-in user
+func raiseSecurityEvent(c *gin.Context, e secevents.Event) {
+	e.IP = c.ClientIP()
+	if e.UserID == nil {
+		e.UserID = getUserIDFromContext(c)
+	}
+	ctx := c.Request.Context()
+	afterCommit(c, func() { secevents.Default.Raise(ctx, e) })
+}
+
+// checkPrivilegeGrant raises TypePrivilegeEscalation when roleID, just given to userID, is a
+// privileged role; critical when the caller granted it to themselves
+func checkPrivilegeGrant(c *gin.Context, db *sql.DB, userID uint64, roleID uint) {
+	var name string
+	err := db.QueryRowContext(c.Request.Context(), "SELECT name FROM roles WHERE id = ?", roleID).Scan(&name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger(c).Error("Error looking up granted role", "role_id", roleID, "error", err)
+		}
+		return
+	}
+	raisePrivilegeGrant(c, userID, roleID, name)
+}
+
+// checkSCIMGroupGrants runs the privilege grant check for the members group has and before
+// did not, as SCIM grants roles by changing group membership
+func checkSCIMGroupGrants(c *gin.Context, before []models.SCIMMember, group *models.SCIMGroup) {
+	if !secevents.Default.Privileged(group.DisplayName) {
+		return
+	}
+	had := make(map[string]bool, len(before))
+	for _, m := range before {
+		had[m.Value] = true
+	}
+	roleID := uint(scimAuditID(group.ID))
+	for _, m := range group.Members {
+		if userID, err := strconv.ParseUint(m.Value, 10, 64); err == nil && !had[m.Value] {
+			raisePrivilegeGrant(c, userID, roleID, group.DisplayName)
+		}
+	}
+}
+
+// raisePrivilegeGrant raises TypePrivilegeEscalation when role is a privileged role
+func raisePrivilegeGrant(c *gin.Context, userID uint64, roleID uint, role string) {
+	if !secevents.Default.Privileged(role) {
+		return
+	}
+
+	severity := secevents.SeverityHigh
+	actor := getUserIDFromContext(c)
+	if actor != nil && *actor == userID {
+		severity = secevents.SeverityCritical
+	}
+	raiseSecurityEvent(c, secevents.Event{
+		Type:     secevents.TypePrivilegeEscalation,
+		Severity: severity,
+		Message:  fmt.Sprintf("User %d was granted the privileged role %s", userID, role),
+		Details:  map[string]any{"user_id": userID, "role_id": roleID, "role": role, "route": c.FullPath()},
+	})
+}
+
+// raiseAuditExport raises TypeAuditExport for a bulk read of the audit trail
+func raiseAuditExport(c *gin.Context, format string) {
+	raiseSecurityEvent(c, secevents.Event{
+		Type:     secevents.TypeAuditExport,
+		Severity: secevents.SeverityMedium,
+		Message:  "The audit log was exported as " + format,
+		Details:  map[string]any{"format": format, "query": c.Request.URL.RawQuery},
+	})
+}
+
+// raiseBackupDownload raises TypeBackupDownload for a download of a database backup, which
+// holds every user's password hash
+func raiseBackupDownload(c *gin.Context, backup *models.DBBackup) {
+	raiseSecurityEvent(c, secevents.Event{
+		Type:     secevents.TypeBackupDownload,
+		Severity: secevents.SeverityMedium,
+		Message:  fmt.Sprintf("Database backup %d was downloaded", backup.ID),
+		Details:  map[string]any{"backup_id": backup.ID, "filename": backup.Filename},
+	})
+}
+
+// listSecurityEventsHandler GET /api/admin/security_events
+// Newest first, optionally only one ?type= or ?severity=, open (?acknowledged=false) or
+// acknowledged ones; ?before_id= pages back.
+func listSecurityEventsHandler(securityEvents services.SecurityEventService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.SecurityEventFilter{
+			Type:     c.Query("type"),
+			Severity: c.Query("severity"),
+			Limit:    parseIntMinMax(c.Query("limit"), 50, 1, 1000),
+		}
+		if v := c.Query("acknowledged"); v != "" {
+			acknowledged, err := strconv.ParseBool(v)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "acknowledged must be true or false")
+				return
+			}
+			filter.Acknowledged = &acknowledged
+		}
+		if v := c.Query("before_id"); v != "" {
+			beforeID, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				utils.RespondError(c, http.StatusBadRequest, "Invalid before_id")
+				return
+			}
+			filter.BeforeID = beforeID
+		}
+
+		list, err := securityEvents.ListEvents(c.Request.Context(), filter)
+		if utils.HandleError(c, err, "list security events") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getSecurityEventHandler GET /api/admin/security_events/:id
+func getSecurityEventHandler(securityEvents services.SecurityEventService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		event, err := securityEvents.GetEvent(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get security event") {
+			return
+		}
+		response.OK(c, event)
+	}
+}
+
+// acknowledgeSecurityEventHandler POST /api/admin/security_events/:id/acknowledge
+func acknowledgeSecurityEventHandler(securityEvents services.SecurityEventService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.AcknowledgeSecurityEventRequest
+		if c.Request.ContentLength != 0 && !bindJSONRequest(c, &req) {
+			return
+		}
+		var by uint64
+		if userID := getUserIDFromContext(c); userID != nil {
+			by = *userID
+		}
+
+		event, err := securityEvents.Acknowledge(c.Request.Context(), c.Param("id"), by, req.Note)
+		if utils.HandleError(c, err, "acknowledge security event") {
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "security_events", event.ID, nil, event, db)
+		response.Write(c, http.StatusOK, response.Body{Data: event, Message: "Security event acknowledged"})
+	}
+}
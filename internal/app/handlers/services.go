@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"log"
+	"log/slog"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/lock"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/push"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+// Services are the application services. They are built once, by NewServices, and shared by
+// the HTTP routes and the gRPC server, so both see the same caches and worker pools.
+type Services struct {
+	Tx               repositories.TxManager
+	Hasher           *password.Hasher
+	Users            services.UserService
+	Roles            services.RoleService
+	Menus            services.MenuService
+	RoleInheritances services.RoleInheritanceService
+	RoleMenus        services.RoleMenuService
+	UserMenus        services.UserMenuService
+	UserRoles        services.UserRoleService
+	Prayer           services.PrayerService
+	Reports          services.ReportService
+	SCIM             services.SCIMService
+	Webhooks         services.WebhookService
+	SecurityEvents   services.SecurityEventService
+	Notifications    services.NotificationService
+	// PrayerSubscriptions sends prayer times to chats through chatbot.Default
+	PrayerSubscriptions services.PrayerSubscriptionService
+	// Push sends prayer reminders and announcements to the mobile apps through push.Default;
+	// Close it on shutdown
+	Push services.PushService
+	// Attachments keeps uploaded avatars and report files in storage.Default
+	Attachments services.AttachmentService
+	// Announcements are the banners shown in the admin panel
+	Announcements services.AnnouncementService
+	// Locations adds and corrects cities, with coordinates from geocode.Default
+	Locations services.LocationService
+	// OTP texts one-time codes through sms.Default, for two-factor sign-in and password resets
+	OTP services.OTPService
+	// ReportSchedules runs reports on a schedule for their owners, from the report_schedules job
+	ReportSchedules services.ReportScheduleService
+	// Calendar publishes the runs of the report schedules to calendar.Default, from the
+	// calendar_sync job
+	Calendar services.CalendarService
+	// UserImport creates users in bulk from uploaded CSV and XLSX files
+	UserImport services.UserImportService
+	// LocationImports loads the Kemendagri region codes and coordinates, and rolls loads back
+	LocationImports services.LocationImportService
+	// Exports runs long exports in the background, from the export_jobs job
+	Exports services.ExportJobService
+	// RBAC exports the roles and menus as a bundle and imports one from another environment
+	RBAC services.RBACService
+	// Backups dumps the database to storage, from the db_backup job
+	Backups services.DBBackupService
+	// DataQuality looks for inconsistent reference data, from the data_quality job
+	DataQuality services.DataQualityService
+	// Mail sends templated emails through mail.Default; Close it on shutdown
+	Mail services.MailService
+	// Events carries domain events to the configured broker; Close it on shutdown to
+	// flush what is queued
+	Events domainevents.EventPublisher
+	// LocationCodes are the opaque location codes of /api/v2/prayer and the gRPC PrayerService
+	LocationCodes *locationcode.Codec
+	// Jobs runs the recurring background jobs SetupRoutes registers, each run on one instance
+	// only; main starts and stops it
+	Jobs *scheduler.Scheduler
+	// Tenants are the organizations this deployment serves; their slugs are resolved for
+	// every request naming one
+	Tenants services.TenantService
+	// Config reloads the configuration files on SIGHUP or from the admin API and applies
+	// the tunables; SetupRoutes hands it the limiters
+	Config *ConfigReloader
+}
+
+// NewServices builds the services over db as cfg configures them
+func NewServices(db *gorm.DB, cfg *config.Config) *Services {
+	sqlDB, _ := db.DB()
+
+	txManager := repositories.NewTxManager(sqlDB)
+	userRoleRepo := repositories.NewUserRoleRepository(sqlDB)
+
+	userRepo := repositories.NewUserRepository(sqlDB)
+	// Password hashing: algorithm, cost and the optional worker pool
+	hasher := password.NewHasher(cfg.Password)
+
+	// Domain events for consumers outside this codebase: EVENT_BROKER picks none, nats or kafka
+	publisher, err := domainevents.New(cfg.Events)
+	if err != nil {
+		log.Fatalf("Failed to connect to the %s event broker: %v", cfg.Events.Broker, err)
+	}
+
+	// Opaque location codes for /api/v2. The secret must stay the same across deploys and
+	// replicas, or codes clients have stored stop resolving.
+	locationSecret := cfg.API.LocationCodeSecret
+	if locationSecret == "" {
+		slog.Warn("LOCATION_CODE_SECRET is not set, /api/v2 location codes use the default secret")
+		locationSecret = "default_location_secret_change_in_prod"
+	}
+
+	prayer := services.NewPrayerService(repositories.NewPrayerRepository(sqlDB), cfg.API.PrayerWorkers, cfg.API.PrayerMethod)
+	locationCodes := locationcode.New(locationSecret)
+
+	roleRepo := repositories.NewRoleRepository(sqlDB)
+	users := services.NewUserService(userRepo, userRoleRepo, txManager, database.Cache, hasher, publisher)
+	roles := services.NewRoleService(roleRepo, txManager)
+	// The notification center, fed by the services and read under /api/me/notifications
+	notifications := services.NewNotificationService(repositories.NewNotificationRepository(sqlDB), notify.Default)
+	userRoles := services.NewUserRoleService(userRoleRepo, publisher, notifications)
+	// Download URLs of uploaded files are signed with STORAGE_URL_SECRET; it must be the same
+	// on every replica, and changing it breaks the URLs already handed out
+	attachments := services.NewAttachmentService(repositories.NewAttachmentRepository(sqlDB), storage.Default)
+	locationRepo := repositories.NewLocationRepository(sqlDB)
+	// One-time codes by SMS, at most SMS_RATE_PER_NUMBER to a number across instances
+	otp := services.NewOTPService(repositories.NewOTPRepository(sqlDB), repositories.NewSMSMessageRepository(sqlDB),
+		userRepo, users, hasher, sms.Default, ratelimit.Default)
+
+	reports := services.NewReportService(jasperClient, publisher, notifications, attachments)
+	// A new tenant's first user is given a role named as the admin role
+	tenants := services.NewTenantService(repositories.NewTenantRepository(sqlDB), roleRepo, users, txManager,
+		database.Cache, middleware.RoleAdmin)
+	// Google Calendar is written with the tokens of the account an administrator connected
+	reportScheduleRepo := repositories.NewReportScheduleRepository(sqlDB)
+	calendarService := services.NewCalendarService(repositories.NewCalendarRepository(sqlDB), reportScheduleRepo, txManager, database.Cache, calendar.Default)
+	calendar.Default.SetTokenSource(calendarService)
+
+	return &Services{
+		Tx:               txManager,
+		Hasher:           hasher,
+		Users:            users,
+		Roles:            roles,
+		Menus:            services.NewMenuService(repositories.NewMenuRepository(sqlDB), txManager),
+		RoleInheritances: services.NewRoleInheritanceService(repositories.NewRoleInheritanceRepository(sqlDB)),
+		RoleMenus:        services.NewRoleMenuService(repositories.NewRoleMenuRepository(sqlDB)),
+		UserMenus:        services.NewUserMenuService(repositories.NewUserMenuRepository(sqlDB)),
+		UserRoles:        userRoles,
+		// Goroutines per multi-day schedule computation; 0 uses GOMAXPROCS, 1 is sequential
+		Prayer:        prayer,
+		LocationCodes: locationCodes,
+		// Prayer times for subscribed chats, sent by the prayer_daily and prayer_imsakiyah jobs
+		PrayerSubscriptions: services.NewPrayerSubscriptionService(repositories.NewPrayerSubscriptionRepository(sqlDB), prayer, locationCodes, chatbot.Default),
+		// Reminders are sent by the push_reminders job, announcements in the background
+		Push: services.NewPushService(repositories.NewPushDeviceRepository(sqlDB), prayer, locationCodes, push.Default),
+		// Built over the client InitJasperClient made, so that must run first
+		Reports:         reports,
+		Attachments:     attachments,
+		Announcements:   services.NewAnnouncementService(repositories.NewAnnouncementRepository(sqlDB), roleRepo),
+		Locations:       services.NewLocationService(locationRepo, txManager, database.Cache, geocode.Default),
+		LocationImports: services.NewLocationImportService(locationRepo, repositories.NewLocationImportRepository(sqlDB)),
+		OTP:             otp,
+		UserImport:      services.NewUserImportService(userRepo, roleRepo, userRoleRepo, txManager, hasher, publisher),
+		Notifications:   notifications,
+		// Finished exports are kept as attachments of their creators for EXPORT_JOB_RETENTION
+		Exports: services.NewExportJobService(repositories.NewExportJobRepository(sqlDB), attachments, notifications,
+			services.ExportJobConfig{Timeout: cfg.Jobs.ExportJobs.Timeout, Retention: cfg.Jobs.ExportJobs.Retention},
+			auditLogExporter(sqlDB), services.PrayerScheduleExporter(prayer)),
+		// Backups are kept in storage.Default for DB_BACKUP_RETENTION
+		Backups: services.NewDBBackupService(repositories.NewDBBackupRepository(sqlDB), sqlDB, storage.Default,
+			services.DBBackupConfig{Timeout: cfg.Jobs.DBBackup.Timeout, Retention: cfg.Jobs.DBBackup.Retention}),
+		DataQuality:    services.NewDataQualityService(repositories.NewDataQualityRepository(sqlDB), txManager),
+		RBAC:           services.NewRBACService(repositories.NewRBACRepository(sqlDB), txManager),
+		SecurityEvents: services.NewSecurityEventService(repositories.NewSecurityEventRepository(sqlDB)),
+		SCIM:           services.NewSCIMService(users, roles, userRoles, userRepo, roleRepo, userRoleRepo, txManager),
+		Events:         publisher,
+		Jobs:           scheduler.New(cfg.Jobs.HistorySize, lock.Default),
+		Config:         NewConfigReloader(cfg, config.DefaultEnvFile, config.DefaultPath),
+		// Outbound webhooks: concurrent deliveries, per-attempt timeout, attempts per
+		// delivery, and the wait before the first retry (doubled after each failure)
+		Webhooks: services.NewWebhookService(repositories.NewWebhookRepository(sqlDB), services.WebhookConfig{
+			Workers:      cfg.Webhooks.Workers,
+			Timeout:      cfg.Webhooks.Timeout,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			RetryBackoff: cfg.Webhooks.RetryBackoff,
+		}),
+		// Email through the driver main configured mail.Default with; the same retry policy
+		// as webhooks, with its own settings
+		Mail: services.NewMailService(mail.Default, repositories.NewMailDeliveryRepository(sqlDB), services.MailConfig{
+			Workers:      cfg.Mail.Workers,
+			MaxAttempts:  cfg.Mail.MaxAttempts,
+			RetryBackoff: cfg.Mail.RetryBackoff,
+		}),
+		Tenants: tenants,
+		// Scheduled reports are kept as attachments of their owners, like exports
+		ReportSchedules: services.NewReportScheduleService(reportScheduleRepo, reports, attachments, notifications, tenants, cfg.Jobs.ReportSchedules.Timeout),
+		Calendar:        calendarService,
+	}
+}
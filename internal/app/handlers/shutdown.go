@@ -0,0 +1,17 @@
+package handlers
+
+import "sync"
+
+var (
+	// draining is closed once the server begins shutting down
+	draining  = make(chan struct{})
+	drainOnce sync.Once
+)
+
+// Drain ends the long-lived connections, /api/events/stream and /ws, so they do not hold up
+// the shutdown of the HTTP server while it waits for requests to finish. Their clients
+// reconnect, to another instance behind a load balancer. Register it with
+// http.Server.RegisterOnShutdown; it is safe to call more than once.
+func Drain() {
+	drainOnce.Do(func() { close(draining) })
+}
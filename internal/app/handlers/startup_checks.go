@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/jwtkeys"
+)
+
+// Outcomes of a startup check
+const (
+	startupOK   = "ok"
+	startupWarn = "warn" // reported, never stops the start
+	startupFail = "fail" // stops the start under STARTUP_FAIL_FAST
+)
+
+// minJWTSecretLength is the shortest JWT_SECRET accepted, in bytes; cmd/secret makes 44
+const minJWTSecretLength = 32
+
+// RunStartupChecks verifies what the service needs before it takes traffic: the database
+// schema version and the tables it queries, Redis when it is enabled, the JWT secret's
+// strength and JasperServer (which never fails the start). Redis fails hard only when
+// READINESS_REQUIRED lists it, as the cache works without it. Each check is logged as one
+// structured line with its status, detail and latency, then a summary; the error lists the
+// hard failures, for main to abort on.
+func RunStartupChecks(sqlDB *sql.DB, cfg *config.Config) error {
+	timeout := cfg.Startup.CheckTimeout
+	redisRequired := slices.Contains(cfg.Health.ReadinessRequired, "redis")
+	checks := []struct {
+		name string
+		run  func(ctx context.Context) (status, detail string)
+	}{
+		{"database_schema", func(ctx context.Context) (string, string) {
+			return checkSchemaVersion(ctx, sqlDB, cfg.Database.MigrationCheck)
+		}},
+		{"database_tables", func(ctx context.Context) (string, string) {
+			missing, err := database.MissingRelations(ctx, sqlDB)
+			switch {
+			case err != nil:
+				return startupFail, err.Error()
+			case len(missing) > 0:
+				return startupFail, "missing " + strings.Join(missing, ", ")
+			}
+			return startupOK, fmt.Sprintf("%d tables and views", len(database.RequiredRelations))
+		}},
+		{"redis", func(ctx context.Context) (string, string) {
+			return checkRedis(ctx, cfg.Redis.Enabled, redisRequired)
+		}},
+		{"jwt_secret", func(context.Context) (string, string) {
+			return checkJWTKeys(cfg.JWT)
+		}},
+		{"jasperserver", func(ctx context.Context) (string, string) {
+			if jasperClient == nil {
+				return startupWarn, "client not initialized"
+			}
+			if _, err := jasperClient.GetServerInfo(ctx); err != nil {
+				return startupWarn, "unreachable, reports will fail: " + err.Error()
+			}
+			return startupOK, ""
+		}},
+	}
+
+	var failed []string
+	warnings := 0
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		status, detail := check.run(ctx)
+		if status != startupOK && ctx.Err() == context.DeadlineExceeded {
+			detail = "timeout after " + timeout.String()
+		}
+		cancel()
+
+		level := slog.LevelInfo
+		switch status {
+		case startupWarn:
+			level = slog.LevelWarn
+			warnings++
+		case startupFail:
+			level = slog.LevelError
+			failed = append(failed, check.name+": "+detail)
+		}
+		slog.Log(context.Background(), level, "Startup check", "check", check.name, "status", status,
+			"detail", detail, "latency_ms", float64(time.Since(start).Microseconds())/1000)
+	}
+
+	status := startupOK
+	if len(failed) > 0 {
+		status = startupFail
+	}
+	slog.Info("Startup self-check finished", "status", status, "failed", len(failed), "warnings", warnings)
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// checkSchemaVersion reports the applied schema version. Pending migrations only warn when
+// DB_MIGRATION_CHECK=false, as the operator chose to run ahead of the schema.
+func checkSchemaVersion(ctx context.Context, sqlDB *sql.DB, strict bool) (string, string) {
+	version, pending, err := database.SchemaVersion(ctx, sqlDB)
+	if err != nil {
+		return startupFail, err.Error()
+	}
+	detail := fmt.Sprintf("version %d", version)
+	if len(pending) == 0 {
+		return startupOK, detail
+	}
+	detail += ", pending " + strings.Join(pending, ", ")
+	if !strict {
+		return startupWarn, detail
+	}
+	return startupFail, detail
+}
+
+// checkRedis pings Redis when it is enabled; without it the in-memory cache is used
+func checkRedis(ctx context.Context, enabled, required bool) (string, string) {
+	if !enabled {
+		return startupOK, "disabled, using the in-memory cache"
+	}
+	down := startupWarn
+	if required {
+		down = startupFail
+	}
+	if database.RedisClient == nil {
+		return down, "not connected, using the in-memory cache"
+	}
+	if err := database.RedisClient.Ping(ctx).Err(); err != nil {
+		return down, "unreachable, using the in-memory cache: " + err.Error()
+	}
+	return startupOK, ""
+}
+
+// checkJWTKeys runs checkJWTSecret on JWT_SECRET, when set, and on every JWT_KEYS key; the
+// configuration already checked the keys parse
+func checkJWTKeys(cfg config.JWT) (string, string) {
+	keys, _ := jwtkeys.ParseKeys(cfg.Keys)
+	if len(keys) == 0 {
+		return checkJWTSecret(cfg.Secret)
+	}
+	if cfg.Secret != "" {
+		if status, detail := checkJWTSecret(cfg.Secret); status != startupOK {
+			return status, "JWT_SECRET: " + detail
+		}
+	}
+	for _, key := range keys {
+		if status, detail := checkJWTSecret(string(key.Secret)); status != startupOK {
+			return status, fmt.Sprintf("key %q: %s", key.ID, detail)
+		}
+	}
+	return startupOK, fmt.Sprintf("%d keys, %q signs", len(keys), keys[0].ID)
+}
+
+// checkJWTSecret fails secrets short or repetitive enough to guess; the configuration
+// already refuses an empty one and the example values
+func checkJWTSecret(secret string) (string, string) {
+	if len(secret) < minJWTSecretLength {
+		return startupFail, fmt.Sprintf("%d bytes, want at least %d (generate one with go run ./cmd/secret)", len(secret), minJWTSecretLength)
+	}
+	distinct := make(map[rune]bool)
+	for _, r := range secret {
+		distinct[r] = true
+	}
+	if len(distinct) < 8 {
+		return startupFail, "too few distinct characters (generate one with go run ./cmd/secret)"
+	}
+	return startupOK, fmt.Sprintf("%d bytes", len(secret))
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"adminbe/internal/pkg/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Component and overall states on the public status page
+const (
+	statusOperational = "operational"
+	statusDegraded    = "degraded"
+	statusOutage      = "outage"
+	statusMajorOutage = "major_outage"
+)
+
+// statusComponents maps dependencies to the names shown publicly. The read replica is folded
+// into database: when it is down reads fall back to the primary, so the service is degraded.
+var statusComponents = []struct {
+	name         string
+	dependencies []string
+}{
+	{name: "database", dependencies: []string{"database", "database_replica"}},
+	{name: "cache", dependencies: []string{"redis"}},
+	{name: "reports", dependencies: []string{"jasperserver"}},
+}
+
+// statusComponent is one row of the status page
+type statusComponent struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// statusPage is the /status body. It carries no latencies, hosts or error text, since it
+// is public; /health/ready has the detail.
+type statusPage struct {
+	Status        string            `json:"status"`
+	Version       string            `json:"version"`
+	Commit        string            `json:"commit,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+	DeployedAt    time.Time         `json:"deployed_at"`
+	Components    []statusComponent `json:"components"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// statusHandler handles GET /status for the frontend status page. Dependency states come
+// from checker, whose report is reused for its interval, so polling clients cannot flood
+// the dependencies; the response may be cached for as long. deployedAt is when this
+// release was rolled out.
+func statusHandler(checker *readinessChecker, deployedAt time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.report(c.Request.Context())
+		states := make(map[string]dependencyStatus, len(report.Dependencies))
+		for _, dep := range report.Dependencies {
+			states[dep.Name] = dep
+		}
+
+		page := statusPage{
+			Status:        statusOperational,
+			Version:       buildinfo.Version,
+			Commit:        buildinfo.Commit,
+			StartedAt:     buildinfo.StartedAt(),
+			UptimeSeconds: int64(buildinfo.Uptime().Seconds()),
+			DeployedAt:    deployedAt,
+			Components:    []statusComponent{{Name: "api", Status: statusOperational}},
+			UpdatedAt:     report.CheckedAt,
+		}
+		for _, component := range statusComponents {
+			status, configured := componentStatus(states, component.dependencies)
+			if !configured {
+				continue
+			}
+			page.Components = append(page.Components, statusComponent{Name: component.name, Status: status})
+			if status != statusOperational {
+				page.Status = statusDegraded
+			}
+		}
+		// A required dependency down means the API cannot serve requests
+		if report.Status != "ok" {
+			page.Status = statusMajorOutage
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(checker.minInterval.Seconds())))
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// componentStatus combines the states of a component's dependencies: the first is the
+// component itself, the others only degrade it. configured is false when none is in use.
+func componentStatus(states map[string]dependencyStatus, dependencies []string) (status string, configured bool) {
+	status = statusOperational
+	for i, name := range dependencies {
+		dep, ok := states[name]
+		if !ok || dep.Status == dependencySkipped {
+			continue
+		}
+		configured = true
+		if dep.Status == dependencyUp {
+			continue
+		}
+		if i == 0 {
+			return statusOutage, true
+		}
+		status = statusDegraded
+	}
+	return status, configured
+}
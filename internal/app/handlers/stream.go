@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tracing"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Streaming formats for export endpoints
+const (
+	streamFormatNDJSON = "ndjson" // one JSON document per line (application/x-ndjson)
+	streamFormatJSON   = "json"   // a single JSON array written element by element
+)
+
+// streamFlushEvery is how many rows are buffered before flushing to the client
+const streamFlushEvery = 100
+
+// jsonStream writes rows to the response as they are read instead of collecting them
+// into a slice first, so memory stays flat however many rows an export returns
+type jsonStream struct {
+	c       *gin.Context
+	enc     *json.Encoder
+	format  string
+	count   int
+	started bool
+}
+
+// streamFormatFor picks the format from ?format=, then the Accept header; NDJSON by default
+func streamFormatFor(c *gin.Context) string {
+	switch c.Query("format") {
+	case streamFormatJSON:
+		return streamFormatJSON
+	case streamFormatNDJSON:
+		return streamFormatNDJSON
+	}
+	accept := c.GetHeader("Accept")
+	if strings.Contains(accept, "application/json") && !strings.Contains(accept, "ndjson") {
+		return streamFormatJSON
+	}
+	return streamFormatNDJSON
+}
+
+// newJSONStream prepares a stream; the headers are sent with the first row, so an error
+// raised before any row (a failed query, say) still gets a normal error response
+func newJSONStream(c *gin.Context, format string) *jsonStream {
+	return &jsonStream{c: c, enc: json.NewEncoder(c.Writer), format: format}
+}
+
+// begin writes the response headers and, for the array format, the opening bracket
+func (s *jsonStream) begin() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	contentType := "application/x-ndjson"
+	if s.format == streamFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	s.c.Header("Content-Type", contentType)
+	s.c.Header("Cache-Control", "no-store")
+	s.c.Header("X-Content-Type-Options", "nosniff")
+	s.c.Status(http.StatusOK)
+	if s.format == streamFormatJSON {
+		s.c.Writer.WriteString("[")
+	}
+}
+
+// Write encodes one row
+func (s *jsonStream) Write(v interface{}) error {
+	s.begin()
+	if s.format == streamFormatJSON && s.count > 0 {
+		if _, err := s.c.Writer.WriteString(","); err != nil {
+			return err
+		}
+	}
+	// Encoder.Encode appends a newline, which is the NDJSON separator and harmless inside an array
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.count++
+	if s.count%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+// Close finishes the stream. Before the first row an error is reported as usual. After it the
+// status line has been sent, so a failure is reported in-band: NDJSON gets a final
+// {"error": ...} line and the JSON array is left unterminated, so clients cannot mistake a
+// truncated export for a complete one.
+func (s *jsonStream) Close(operation string, err error) {
+	if err != nil && !s.started {
+		utils.HandleError(s.c, err, operation)
+		return
+	}
+	s.begin()
+	if err != nil {
+		logger(s.c).Error("Stream interrupted", "operation", operation, "rows", s.count, "error", err)
+		if s.format == streamFormatNDJSON {
+			// A line of the stream, so never problem details
+			s.enc.Encode(response.ErrorFor(response.Negotiate(s.c), tracing.RequestID(s.c.Request.Context()), response.Failure{
+				Code:    response.CodeInternal,
+				Message: operation + " interrupted",
+				Details: response.Meta{"rows": s.count},
+			}))
+		}
+		s.c.Writer.Flush()
+		return
+	}
+	if s.format == streamFormatJSON {
+		s.c.Writer.WriteString("]")
+	}
+	s.c.Writer.Flush()
+}
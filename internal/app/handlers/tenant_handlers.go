@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listTenantsHandler GET /api/admin/tenants
+func listTenantsHandler(tenants services.TenantService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := tenants.ListTenants(c.Request.Context())
+		if utils.HandleError(c, err, "list tenants") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: list, Meta: response.Meta{"count": len(list)}})
+	}
+}
+
+// getTenantHandler GET /api/admin/tenants/:id
+func getTenantHandler(tenants services.TenantService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, err := tenants.GetTenant(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get tenant") {
+			return
+		}
+		response.OK(c, t)
+	}
+}
+
+// createTenantHandler POST /api/admin/tenants
+// Creates the tenant with an admin role and its first administrator, who signs in with the
+// tenant named in the X-Tenant header (TENANT_HEADER).
+func createTenantHandler(tenants services.TenantService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateTenantRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		t, err := tenants.CreateTenant(c.Request.Context(), req)
+		if utils.HandleError(c, err, "create tenant") {
+			return
+		}
+
+		logAuditEntry(c, "CREATE", "tenants", uint64(t.ID), nil, t, db)
+
+		response.Write(c, http.StatusCreated, response.Body{Data: t, Message: "Tenant created"})
+	}
+}
+
+// updateTenantHandler PUT /api/admin/tenants/:id
+// Renames the tenant or, with status 0, disables it: requests naming it are answered 404.
+func updateTenantHandler(tenants services.TenantService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateTenantRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		old, t, err := tenants.UpdateTenant(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "update tenant") {
+			return
+		}
+
+		logAuditEntry(c, "UPDATE", "tenants", uint64(t.ID), old, t, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: t, Message: "Tenant updated"})
+	}
+}
+
+// getTenantSettingsHandler GET /api/admin/tenants/:id/settings
+func getTenantSettingsHandler(tenants services.TenantService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings, err := tenants.GetSettings(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get tenant settings") {
+			return
+		}
+		response.OK(c, settings)
+	}
+}
+
+// updateTenantSettingsHandler PUT /api/admin/tenants/:id/settings
+// Replaces what the tenant overrides of the global configuration; what the body leaves out
+// goes back to the global value.
+func updateTenantSettingsHandler(tenants services.TenantService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TenantSettings
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		old, settings, err := tenants.UpdateSettings(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "update tenant settings") {
+			return
+		}
+
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+		logAuditEntry(c, "UPDATE", "tenant_settings", id, old, settings, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: settings, Message: "Tenant settings updated"})
+	}
+}
+
+// requireInTenant answers 404 naming resource, and returns false, unless the row id of table
+// belongs to the request's tenant
+func requireInTenant(c *gin.Context, db *sql.DB, table string, id uint64, resource string) bool {
+	ok, err := repositories.InTenant(c.Request.Context(), db, table, id)
+	if utils.HandleError(c, err, "look up "+table) {
+		return false
+	}
+	if !ok {
+		utils.HandleError(c, utils.NewNotFoundError(resource), "look up "+table)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listTracesHandler GET /api/admin/traces?spans=false
+// Lists recent requests that ran slow queries or too many queries, newest first
+func listTracesHandler(c *gin.Context) {
+	traces := tracing.Recent()
+	if c.Query("spans") == "false" {
+		for i := range traces {
+			traces[i].Spans = nil
+		}
+	}
+	response.Write(c, http.StatusOK, response.Body{Data: traces, Meta: response.Meta{"count": len(traces)}})
+}
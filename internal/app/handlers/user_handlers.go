@@ -3,29 +3,24 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
 	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/response"
 	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Per-goroutine pools to eliminate concurrency bottlenecks
 var (
-	responseMapPoolMu sync.RWMutex
-	responseMapPools  = make(map[string]*sync.Pool) // Per-goroutine pools indexed by goroutine ID
-
-	paginationMapPoolMu sync.RWMutex
-	paginationMapPools  = make(map[string]*sync.Pool)
-
-	userSlicePoolMu sync.RWMutex
-	userSlicePools  = make(map[string]*sync.Pool)
-
 	// 🔧 OPTIMIZED: Worker pool for audit logging (3 workers)
 	numAuditWorkers = 3
 	auditLogChan    = make(chan auditLogEntry, 2000)  // Increased buffer
@@ -54,11 +49,36 @@ type auditLogEntry struct {
 	NewValues interface{}   `json:"new_values,omitempty"`
 	DB        *sql.DB       `json:"-"` // DB connection (not serialized)
 	Priority  AuditPriority `json:"priority"`
-	Timestamp time.Time     `json:"-"`
+	// Timestamp is when the change happened, zero for now; IPAddress (as inet6Aton stores
+	// it) and UserAgent are the client's, when known
+	Timestamp time.Time `json:"-"`
+	IPAddress []byte    `json:"-"`
+	UserAgent *string   `json:"-"`
+}
+
+// auditInsert writes one audit entry; created_at falls back to the database's clock
+const auditInsert = "INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values, ip_address, user_agent, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))"
+
+// auditArgs are the auditInsert arguments of entry
+func auditArgs(entry auditLogEntry) []interface{} {
+	var oldJSON, newJSON []byte
+	if entry.OldValues != nil {
+		oldJSON, _ = json.Marshal(entry.OldValues)
+	}
+	if entry.NewValues != nil {
+		newJSON, _ = json.Marshal(entry.NewValues)
+	}
+	var at interface{}
+	if !entry.Timestamp.IsZero() {
+		at = entry.Timestamp
+	}
+	return []interface{}{entry.UserID, entry.Event, entry.Table, entry.RecordID, oldJSON, newJSON, entry.IPAddress, entry.UserAgent, at}
 }
 
 // StartAuditLogger starts the optimized worker pool for audit logging
 func StartAuditLogger() {
+	auditPipeline.start()
+
 	// ✅ RECOMMENDATION 1: Worker Pool Pattern
 	for i := 0; i < numAuditWorkers; i++ {
 		auditWorkerWG.Add(1)
@@ -127,24 +147,25 @@ func auditBatchWorker() {
 		case batch := <-auditBatchChan:
 			processAuditBatch(batch)
 		case <-auditStopCh:
-			return
+			// Batches were accepted with a 202 (see ingestAuditLogsHandler), so the queued
+			// ones are written before stopping
+			for {
+				select {
+				case batch := <-auditBatchChan:
+					processAuditBatch(batch)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
 // processAuditLog processes an audit log entry synchronously but in background
 func processAuditLog(entry auditLogEntry) {
-	var oldJSON, newJSON []byte
-	if entry.OldValues != nil {
-		oldJSON, _ = json.Marshal(entry.OldValues)
-	}
-	if entry.NewValues != nil {
-		newJSON, _ = json.Marshal(entry.NewValues)
-	}
-
 	// Execute synchronously but outside of request handler
-	entry.DB.Exec("INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values) VALUES (?, ?, ?, ?, ?, ?)",
-		entry.UserID, entry.Event, entry.Table, entry.RecordID, oldJSON, newJSON)
+	_, err := entry.DB.Exec(auditInsert, auditArgs(entry)...)
+	recordAuditWrite(err)
 }
 
 // processAuditBatch processes multiple audit log entries in optimized batches
@@ -152,6 +173,7 @@ func processAuditBatch(entries []auditLogEntry) {
 	if len(entries) == 0 {
 		return
 	}
+	auditPipeline.recordBatch(len(entries))
 
 	// Get one DB connection for the batch (assuming first entry's DB)
 	db := entries[0].DB
@@ -159,7 +181,7 @@ func processAuditBatch(entries []auditLogEntry) {
 	// ✅ RECOMMENDATION 4: Use transaction for batch inserts
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Failed to start audit batch transaction: %v", err)
+		slog.Error("Failed to start audit batch transaction", "error", err)
 		// Fall back to individual processing
 		for _, entry := range entries {
 			processAuditLog(entry)
@@ -169,9 +191,9 @@ func processAuditBatch(entries []auditLogEntry) {
 	defer tx.Rollback() // Will be ignored if committed
 
 	// Prepare statement once for the batch
-	stmt, err := tx.Prepare("INSERT INTO audit_logs (user_id, event_type, table_name, record_id, old_values, new_values) VALUES (?, ?, ?, ?, ?, ?)")
+	stmt, err := tx.Prepare(auditInsert)
 	if err != nil {
-		log.Printf("Failed to prepare audit batch statement: %v", err)
+		slog.Error("Failed to prepare audit batch statement", "error", err)
 		// Fall back to individual processing
 		for _, entry := range entries {
 			processAuditLog(entry)
@@ -181,27 +203,26 @@ func processAuditBatch(entries []auditLogEntry) {
 	defer stmt.Close()
 
 	// Execute batch inserts
+	inserted := 0
 	for _, entry := range entries {
-		var oldJSON, newJSON []byte
-		if entry.OldValues != nil {
-			oldJSON, _ = json.Marshal(entry.OldValues)
-		}
-		if entry.NewValues != nil {
-			newJSON, _ = json.Marshal(entry.NewValues)
-		}
-
-		_, err = stmt.Exec(entry.UserID, entry.Event, entry.Table, entry.RecordID, oldJSON, newJSON)
+		_, err = stmt.Exec(auditArgs(entry)...)
 		if err != nil {
-			log.Printf("Failed to execute batch audit insert: %v", err)
+			slog.Error("Failed to execute batch audit insert", "error", err)
+			recordAuditWrites(0, 1, err)
 			// Continue with other entries - don't fail the whole batch
+			continue
 		}
+		inserted++
 	}
 
-	// Commit the transaction
+	// Commit the transaction; the inserted entries are only written once it succeeds
 	if err := tx.Commit(); err != nil {
-		log.Printf("Failed to commit audit batch transaction: %v", err)
+		slog.Error("Failed to commit audit batch transaction", "error", err)
+		recordAuditWrites(0, inserted, err)
 		// Transaction will rollback automatically due to defer
+		return
 	}
+	recordAuditWrites(inserted, 0, nil)
 }
 
 // parseIntMinMax parses a string to int with min/max bounds
@@ -220,6 +241,9 @@ func parseIntMinMax(s string, defaultVal, min, max int) int {
 }
 
 // listUsersHandler GET /api/users
+// Offset mode: ?page=2&limit=50. Cursor mode: ?cursor=&limit=50, then ?cursor=<next_cursor>.
+// Both take ?fields=id,username; offset mode also takes ?sort=username:asc,created_at:desc.
+// Accept: text/csv streams every user in that order instead, the fields being the columns.
 func listUsersHandler(userService services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pageStr := c.DefaultQuery("page", "1")
@@ -228,24 +252,83 @@ func listUsersHandler(userService services.UserService) gin.HandlerFunc {
 		page := parseIntMinMax(pageStr, 1, 1, 10000)
 		limit := parseIntMinMax(limitStr, 50, 1, 1000)
 
-		result, err := userService.ListUsers(page, limit)
+		q, ok := parseListQuery(c, repositories.UserListSpec, "list users")
+		if !ok {
+			return
+		}
+
+		if wantsCSV(c) {
+			stream := newCSVStream(c, "users", csvColumns(repositories.UserListSpec, q.Fields))
+			stream.Close("list users", userService.ExportUsers(c.Request.Context(), q.Sort, func(u *models.User) error {
+				return stream.Write(u)
+			}))
+			return
+		}
+
+		if cursor, ok := c.GetQuery("cursor"); ok {
+			if rejectSortWithCursor(c, q, "list users") {
+				return
+			}
+			result, err := userService.ListUsersAfter(c.Request.Context(), cursor, limit)
+			if utils.HandleError(c, err, "list users") {
+				return
+			}
+			writeUserList(c, result, q.Fields)
+			return
+		}
+
+		result, err := userService.ListUsers(c.Request.Context(), page, limit, q.Sort)
 		if utils.HandleError(c, err, "list users") {
 			return
 		}
 
-		c.JSON(200, result)
+		writeUserList(c, result, q.Fields)
+	}
+}
+
+// writeUserList writes a page from UserService.ListUsers or ListUsersAfter, whose data and
+// pagination are also the version 1 body, keeping only fields of each user when given
+func writeUserList(c *gin.Context, result map[string]interface{}, fields []string) {
+	data, err := selectFields(result["data"], fields)
+	if utils.HandleError(c, err, "list users") {
+		return
+	}
+
+	legacy := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		legacy[k] = v
+	}
+	legacy["data"] = data
+
+	response.Write(c, http.StatusOK, response.Body{
+		Data:   data,
+		Meta:   response.Meta{"pagination": result["pagination"]},
+		Legacy: legacy,
+	})
+}
+
+// exportUsersHandler GET /api/users/export
+// Streams every active user as NDJSON (default) or a JSON array (?format=json), row by row
+func exportUsersHandler(userService services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stream := newJSONStream(c, streamFormatFor(c))
+		err := userService.ExportUsers(c.Request.Context(), nil, func(u *models.User) error {
+			return stream.Write(u)
+		})
+		stream.Close("export users", err)
 	}
 }
 
 // getUserHandler GET /api/users/:id
+// Answers 304 when If-None-Match holds the current ETag.
 func getUserHandler(userService services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		user, err := userService.GetUser(id)
+		user, err := userService.GetUser(c.Request.Context(), id)
 		if utils.HandleError(c, err, "get user") {
 			return
 		}
-		c.JSON(200, gin.H{"data": user})
+		response.OKConditional(c, user)
 	}
 }
 
@@ -254,21 +337,27 @@ func createUserHandler(userService services.UserService, db *sql.DB) gin.Handler
 	return func(c *gin.Context) {
 		var req models.CreateUserRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
-		user, err := userService.CreateUser(req)
+		user, err := userService.CreateUser(c.Request.Context(), req)
 		if err != nil {
-			log.Printf("Error creating user: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to create user"})
+			logger(c).Error("Error creating user", "error", err)
+			utils.RespondError(c, 500, "Failed to create user")
 			return
 		}
 
 		// Audit logging
 		logAuditEntry(c, "CREATE", "users", user.ID, nil, req, db)
-
-		c.JSON(201, gin.H{"message": "User created", "data": user})
+		sendMail(c, services.MailRequest{
+			Template: mail.TemplateWelcome,
+			To:       user.Email,
+			UserID:   &user.ID,
+			Data:     map[string]any{"username": user.Username},
+		})
+
+		response.Write(c, http.StatusCreated, response.Body{Data: user, Message: "User created"})
 	}
 }
 
@@ -279,44 +368,107 @@ func updateUserHandler(userService services.UserService, db *sql.DB) gin.Handler
 
 		var req models.UpdateUserRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(400, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
-		user, err := userService.UpdateUser(id, req)
+		user, err := userService.UpdateUser(c.Request.Context(), id, req)
 		if err != nil {
 			if isNotFoundError(err) {
-				c.JSON(404, gin.H{"error": "User not found"})
+				utils.RespondError(c, 404, "User not found")
 				return
 			}
-			log.Printf("Error updating user: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to update user"})
+			logger(c).Error("Error updating user", "error", err)
+			utils.RespondError(c, 500, "Failed to update user")
 			return
 		}
 
 		// Audit logging
 		logAuditEntry(c, "UPDATE", "users", user.ID, nil, req, db)
+		notifyUserLocked(c, user, req)
+		mailPasswordReset(c, user, req)
 
-		c.JSON(200, gin.H{"message": "User updated", "data": user})
+		response.Write(c, http.StatusOK, response.Body{Data: user, Message: "User updated"})
 
 		// Audit logging would go here, but we need DB
 	}
 }
 
+// patchUserHandler PATCH /api/users/:id
+// Takes a JSON Merge Patch: only the members present change.
+func patchUserHandler(userService services.UserService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch models.PatchUserRequest
+		if !bindMergePatch(c, &patch) {
+			return
+		}
+		req, err := patch.UpdateRequest()
+		if err != nil {
+			utils.HandleError(c, utils.NewValidationError(err.Error()), "patch user")
+			return
+		}
+
+		user, err := userService.UpdateUser(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "patch user") {
+			return
+		}
+
+		// Audit logging; the audit trail must not hold the new password
+		audited := req
+		audited.Password = ""
+		logAuditEntry(c, "UPDATE", "users", user.ID, nil, audited, db)
+		notifyUserLocked(c, user, req)
+		mailPasswordReset(c, user, req)
+
+		response.Write(c, http.StatusOK, response.Body{Data: user, Message: "User updated"})
+	}
+}
+
+// forcePasswordResetHandler POST /api/users/force-password-reset
+// Makes the active users with role_id, or not signed in since last_login_before, or all of
+// them, set a new password at their next sign-in. Each user flagged gets an UPDATE audit
+// entry and, unless notify is false, an email; users already flagged are left out.
+func forcePasswordResetHandler(userService services.UserService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ForcePasswordResetRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+		flagged, err := userService.ForcePasswordReset(c.Request.Context(), req)
+		if utils.HandleError(c, err, "force password reset") {
+			return
+		}
+		notify := req.Notify == nil || *req.Notify
+		for i := range flagged {
+			user := &flagged[i]
+			logAuditEntry(c, "UPDATE", "users", user.ID, gin.H{"password_reset_required": false}, gin.H{"password_reset_required": true}, db)
+			if notify {
+				mailPasswordExpired(c, user)
+			}
+		}
+		logger(c).Info("Passwords expired", "users", len(flagged))
+		response.Write(c, http.StatusOK, response.Body{
+			Data:    flagged,
+			Message: fmt.Sprintf("%d users must change their password at their next sign-in", len(flagged)),
+			Meta:    response.Meta{"flagged": len(flagged)},
+		})
+	}
+}
+
 // deleteUserHandler DELETE /api/users/:id
 func deleteUserHandler(userService services.UserService, db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 
 		// Get the user before deletion for audit logging
-		user, err := userService.GetUser(id)
+		user, err := userService.GetUser(c.Request.Context(), id)
 		if err != nil {
 			if isNotFoundError(err) {
-				c.JSON(404, gin.H{"error": "User not found"})
+				utils.RespondError(c, 404, "User not found")
 				return
 			}
-			log.Printf("Error getting user for deletion: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to delete user"})
+			logger(c).Error("Error getting user for deletion", "error", err)
+			utils.RespondError(c, 500, "Failed to delete user")
 			return
 		}
 
@@ -324,17 +476,17 @@ func deleteUserHandler(userService services.UserService, db *sql.DB) gin.Handler
 		logAuditEntry(c, "DELETE", "users", user.ID, user, nil, db)
 
 		// Proceed with deletion
-		err = userService.DeleteUser(id)
+		err = userService.DeleteUser(c.Request.Context(), id)
 		if err != nil {
 			if isNotFoundError(err) {
-				c.JSON(404, gin.H{"error": "User not found"})
+				utils.RespondError(c, 404, "User not found")
 				return
 			}
-			log.Printf("Error deleting user: %v", err)
-			c.JSON(500, gin.H{"error": "Failed to delete user"})
+			logger(c).Error("Error deleting user", "error", err)
+			utils.RespondError(c, 500, "Failed to delete user")
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "User deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "User deleted"})
 	}
 }
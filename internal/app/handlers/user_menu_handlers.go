@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,10 +22,10 @@ import (
 // listUserMenusHandler GET /api/user_menu
 func listUserMenusHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query("SELECT user_id, menu_id, deleted_at, deleted_by FROM user_menu WHERE deleted_at IS NULL")
+		rows, err := db.QueryContext(c.Request.Context(), "SELECT user_id, menu_id, deleted_at, deleted_by FROM user_menu WHERE deleted_at IS NULL AND "+repositories.UserInTenant, tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error querying user_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user-menu assignments"})
+			logger(c).Error("Error querying user_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve user-menu assignments")
 			return
 		}
 		defer rows.Close()
@@ -28,13 +34,13 @@ func listUserMenusHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var um models.UserMenu
 			if err := rows.Scan(&um.UserID, &um.MenuID, &um.DeletedAt, &um.DeletedBy); err != nil {
-				log.Printf("Error scanning user_menu row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user-menu assignments"})
+				logger(c).Error("Error scanning user_menu row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve user-menu assignments")
 				return
 			}
 			userMenus = append(userMenus, um)
 		}
-		c.JSON(http.StatusOK, gin.H{"data": userMenus})
+		response.OK(c, userMenus)
 	}
 }
 
@@ -45,27 +51,27 @@ func getUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
 		var um models.UserMenu
-		row := db.QueryRow("SELECT user_id, menu_id, deleted_at, deleted_by FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL", userID, uint(menuID))
+		row := db.QueryRowContext(c.Request.Context(), "SELECT user_id, menu_id, deleted_at, deleted_by FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, userID, uint(menuID), tenant.ID(c.Request.Context()))
 		err = row.Scan(&um.UserID, &um.MenuID, &um.DeletedAt, &um.DeletedBy)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User-menu assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "User-menu assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error querying user_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error querying user_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": um})
+		response.OK(c, um)
 	}
 }
 
@@ -74,33 +80,36 @@ func createUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req models.CreateUserMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
+			return
+		}
+
+		if !requireInTenant(c, db, "users", req.UserID, "User") || !requireInTenant(c, db, "menu", uint64(req.MenuID), "Menu") {
 			return
 		}
 
 		// Check if already exists active
 		var exists bool
-		err := db.QueryRow("SELECT 1 FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL", req.UserID, req.MenuID).Scan(&exists)
+		err := db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, req.UserID, req.MenuID, tenant.ID(c.Request.Context())).Scan(&exists)
 		if err != nil && err != sql.ErrNoRows {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
 		if exists {
-			c.JSON(http.StatusConflict, gin.H{"error": "User-menu assignment already exists"})
+			utils.RespondError(c, http.StatusConflict, "User-menu assignment already exists")
 			return
 		}
 
-		_, err = db.Exec("INSERT INTO user_menu (user_id, menu_id, deleted_at, deleted_by) VALUES (?, ?, ?, ?)",
+		_, err = db.ExecContext(c.Request.Context(), "INSERT INTO user_menu (user_id, menu_id, deleted_at, deleted_by) VALUES (?, ?, ?, ?)",
 			req.UserID, req.MenuID, nil, nil)
-		if err != nil {
-			log.Printf("Error inserting user_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user-menu assignment"})
+		if utils.HandleError(c, database.TranslateError(err), "create user-menu assignment") {
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "User-menu assignment created"})
+		response.Write(c, http.StatusCreated, response.Body{Message: "User-menu assignment created"})
 		createAuditLog(db, nil, "CREATE", "user_menu", uint64(req.UserID), nil, req)
+		events.EntityChanged("user_menu", events.ActionCreated, fmt.Sprintf("%d:%d", req.UserID, req.MenuID))
 	}
 }
 
@@ -111,30 +120,37 @@ func updateUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
 		var req models.UpdateUserMenuRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
 		// Check if exists
 		var exists bool
-		err = db.QueryRow("SELECT 1 FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL", userID, uint(menuID)).Scan(&exists)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM user_menu WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, userID, uint(menuID), tenant.ID(c.Request.Context())).Scan(&exists)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User-menu assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "User-menu assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
+			return
+		}
+
+		if req.UserID != nil && !requireInTenant(c, db, "users", *req.UserID, "User") {
+			return
+		}
+		if req.MenuID != nil && !requireInTenant(c, db, "menu", uint64(*req.MenuID), "Menu") {
 			return
 		}
 
@@ -160,22 +176,21 @@ func updateUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if len(setParts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			utils.RespondError(c, http.StatusBadRequest, "No fields to update")
 			return
 		}
 
-		query := "UPDATE user_menu SET " + strings.Join(setParts, ", ") + " WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL"
-		args = append(args, userID, uint(menuID))
+		query := "UPDATE user_menu SET " + strings.Join(setParts, ", ") + " WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND " + repositories.UserInTenant
+		args = append(args, userID, uint(menuID), tenant.ID(c.Request.Context()))
 
-		_, err = db.Exec(query, args...)
-		if err != nil {
-			log.Printf("Error updating user_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		_, err = db.ExecContext(c.Request.Context(), query, args...)
+		if utils.HandleError(c, database.TranslateError(err), "update user-menu assignment") {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User-menu assignment updated"})
+		response.Write(c, http.StatusOK, response.Body{Message: "User-menu assignment updated"})
 		createAuditLog(db, nil, "UPDATE", "user_menu", userID, oldUserMenu, req)
+		events.EntityChanged("user_menu", events.ActionUpdated, fmt.Sprintf("%d:%d", userID, menuID))
 	}
 }
 
@@ -186,12 +201,12 @@ func deleteUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 		menuIDStr := c.Param("menuId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		menuID, err := strconv.ParseUint(menuIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid menu ID")
 			return
 		}
 
@@ -203,14 +218,15 @@ func deleteUserMenuHandler(db *sql.DB) gin.HandlerFunc {
 		oldUserMenu.UserID = userID
 		oldUserMenu.MenuID = uint(menuID)
 
-		_, err = db.Exec("UPDATE user_menu SET deleted_at = ? WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL", time.Now(), userID, uint(menuID))
+		_, err = db.ExecContext(c.Request.Context(), "UPDATE user_menu SET deleted_at = ? WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, time.Now(), userID, uint(menuID), tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error soft deleting user_menu: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Soft delete failed"})
+			logger(c).Error("Error soft deleting user_menu", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Soft delete failed")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User-menu assignment deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "User-menu assignment deleted"})
 		createAuditLog(db, nil, "DELETE", "user_menu", userID, oldUserMenu, nil)
+		events.EntityChanged("user_menu", events.ActionDeleted, fmt.Sprintf("%d:%d", userID, menuID))
 	}
 }
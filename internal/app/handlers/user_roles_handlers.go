@@ -2,13 +2,20 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,10 +23,10 @@ import (
 // listUserRolesHandler GET /api/user_roles
 func listUserRolesHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query("SELECT user_id, role_id, deleted_at, deleted_by FROM user_roles WHERE deleted_at IS NULL")
+		rows, err := db.QueryContext(c.Request.Context(), "SELECT user_id, role_id, deleted_at, deleted_by FROM user_roles WHERE deleted_at IS NULL AND "+repositories.UserInTenant, tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error querying user_roles: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user-role assignments"})
+			logger(c).Error("Error querying user_roles", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve user-role assignments")
 			return
 		}
 		defer rows.Close()
@@ -28,13 +35,13 @@ func listUserRolesHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var ur models.UserRole
 			if err := rows.Scan(&ur.UserID, &ur.RoleID, &ur.DeletedAt, &ur.DeletedBy); err != nil {
-				log.Printf("Error scanning user_role row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user-role assignments"})
+				logger(c).Error("Error scanning user_role row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve user-role assignments")
 				return
 			}
 			userRoles = append(userRoles, ur)
 		}
-		c.JSON(http.StatusOK, gin.H{"data": userRoles})
+		response.OK(c, userRoles)
 	}
 }
 
@@ -45,62 +52,49 @@ func getUserRoleHandler(db *sql.DB) gin.HandlerFunc {
 		roleIDStr := c.Param("roleId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 
 		var ur models.UserRole
-		row := db.QueryRow("SELECT user_id, role_id, deleted_at, deleted_by FROM user_roles WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL", userID, uint(roleID))
+		row := db.QueryRowContext(c.Request.Context(), "SELECT user_id, role_id, deleted_at, deleted_by FROM user_roles WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, userID, uint(roleID), tenant.ID(c.Request.Context()))
 		err = row.Scan(&ur.UserID, &ur.RoleID, &ur.DeletedAt, &ur.DeletedBy)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User-role assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "User-role assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error querying user_role: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error querying user_role", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"data": ur})
+		response.OK(c, ur)
 	}
 }
 
 // createUserRoleHandler POST /api/user_roles
-func createUserRoleHandler(db *sql.DB) gin.HandlerFunc {
+func createUserRoleHandler(userRoleService services.UserRoleService, db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req models.CreateUserRoleRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
-		// Check if already exists active
-		var exists bool
-		err := db.QueryRow("SELECT 1 FROM user_roles WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL", req.UserID, req.RoleID).Scan(&exists)
-		if err != nil && err != sql.ErrNoRows {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
-			return
-		}
-		if exists {
-			c.JSON(http.StatusConflict, gin.H{"error": "User-role assignment already exists"})
-			return
-		}
-
-		_, err = db.Exec("INSERT INTO user_roles (user_id, role_id, deleted_at, deleted_by) VALUES (?, ?, ?, ?)",
-			req.UserID, req.RoleID, nil, nil)
-		if err != nil {
-			log.Printf("Error inserting user_role: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user-role assignment"})
+		_, err := userRoleService.CreateUserRole(c.Request.Context(), req)
+		if utils.HandleError(c, err, "create user-role assignment") {
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "User-role assignment created"})
+		response.Write(c, http.StatusCreated, response.Body{Message: "User-role assignment created"})
 		createAuditLog(db, nil, "CREATE", "user_roles", uint64(req.UserID), nil, req)
+		checkPrivilegeGrant(c, db, req.UserID, req.RoleID)
+		events.EntityChanged("user_roles", events.ActionCreated, fmt.Sprintf("%d:%d", req.UserID, req.RoleID))
+		mailRoleChange(c, db, req.UserID, req.RoleID, true)
 	}
 }
 
@@ -111,30 +105,37 @@ func updateUserRoleHandler(db *sql.DB) gin.HandlerFunc {
 		roleIDStr := c.Param("roleId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 
 		var req models.UpdateUserRoleRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respondBindError(c, err)
 			return
 		}
 
 		// Check if exists
 		var exists bool
-		err = db.QueryRow("SELECT 1 FROM user_roles WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL", userID, uint(roleID)).Scan(&exists)
+		err = db.QueryRowContext(c.Request.Context(), "SELECT 1 FROM user_roles WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, userID, uint(roleID), tenant.ID(c.Request.Context())).Scan(&exists)
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User-role assignment not found"})
+			utils.RespondError(c, http.StatusNotFound, "User-role assignment not found")
 			return
 		} else if err != nil {
-			log.Printf("Error checking existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database query failed"})
+			logger(c).Error("Error checking existence", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Database query failed")
+			return
+		}
+
+		if req.UserID != nil && !requireInTenant(c, db, "users", *req.UserID, "User") {
+			return
+		}
+		if req.RoleID != nil && !requireInTenant(c, db, "roles", uint64(*req.RoleID), "Role") {
 			return
 		}
 
@@ -160,22 +161,32 @@ func updateUserRoleHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if len(setParts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+			utils.RespondError(c, http.StatusBadRequest, "No fields to update")
 			return
 		}
 
-		query := "UPDATE user_roles SET " + strings.Join(setParts, ", ") + " WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL"
-		args = append(args, userID, uint(roleID))
+		query := "UPDATE user_roles SET " + strings.Join(setParts, ", ") + " WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND " + repositories.UserInTenant
+		args = append(args, userID, uint(roleID), tenant.ID(c.Request.Context()))
 
-		_, err = db.Exec(query, args...)
-		if err != nil {
-			log.Printf("Error updating user_role: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		_, err = db.ExecContext(c.Request.Context(), query, args...)
+		if utils.HandleError(c, database.TranslateError(err), "update user-role assignment") {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User-role assignment updated"})
+		response.Write(c, http.StatusOK, response.Body{Message: "User-role assignment updated"})
 		createAuditLog(db, nil, "UPDATE", "user_roles", userID, oldUserRole, req)
+		if req.UserID != nil || req.RoleID != nil {
+			grantedUser, grantedRole := userID, uint(roleID)
+			if req.UserID != nil {
+				grantedUser = *req.UserID
+			}
+			if req.RoleID != nil {
+				grantedRole = *req.RoleID
+			}
+			checkPrivilegeGrant(c, db, grantedUser, grantedRole)
+			mailRoleChange(c, db, grantedUser, grantedRole, true)
+		}
+		events.EntityChanged("user_roles", events.ActionUpdated, fmt.Sprintf("%d:%d", userID, roleID))
 	}
 }
 
@@ -186,12 +197,12 @@ func deleteUserRoleHandler(db *sql.DB) gin.HandlerFunc {
 		roleIDStr := c.Param("roleId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
 		roleID, err := strconv.ParseUint(roleIDStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+			utils.RespondError(c, http.StatusBadRequest, "Invalid role ID")
 			return
 		}
 
@@ -203,14 +214,16 @@ func deleteUserRoleHandler(db *sql.DB) gin.HandlerFunc {
 		oldUserRole.UserID = userID
 		oldUserRole.RoleID = uint(roleID)
 
-		_, err = db.Exec("UPDATE user_roles SET deleted_at = ? WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL", time.Now(), userID, uint(roleID))
+		_, err = db.ExecContext(c.Request.Context(), "UPDATE user_roles SET deleted_at = ? WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND "+repositories.UserInTenant, time.Now(), userID, uint(roleID), tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error soft deleting user_role: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Soft delete failed"})
+			logger(c).Error("Error soft deleting user_role", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Soft delete failed")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "User-role assignment deleted"})
+		response.Write(c, http.StatusOK, response.Body{Message: "User-role assignment deleted"})
 		createAuditLog(db, nil, "DELETE", "user_roles", userID, oldUserRole, nil)
+		mailRoleChange(c, db, userID, uint(roleID), false)
+		events.EntityChanged("user_roles", events.ActionDeleted, fmt.Sprintf("%d:%d", userID, roleID))
 	}
 }
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"adminbe/internal/pkg/utils"
 	"database/sql"
-	"log"
 	"net/http"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,10 +15,10 @@ import (
 // listVRolesHandler GET /api/v_roles
 func listVRolesHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query("SELECT role_id, role_name, child_id, child_name, level FROM v_roles")
+		rows, err := db.QueryContext(c.Request.Context(), "SELECT role_id, role_name, child_id, child_name, level FROM v_roles WHERE tenant_id = ?", tenant.ID(c.Request.Context()))
 		if err != nil {
-			log.Printf("Error querying v_roles: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role hierarchies"})
+			logger(c).Error("Error querying v_roles", "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role hierarchies")
 			return
 		}
 		defer rows.Close()
@@ -25,12 +27,12 @@ func listVRolesHandler(db *sql.DB) gin.HandlerFunc {
 		for rows.Next() {
 			var vr models.VRole
 			if err := rows.Scan(&vr.RoleID, &vr.RoleName, &vr.ChildID, &vr.ChildName, &vr.Level); err != nil {
-				log.Printf("Error scanning v_role row: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role hierarchies"})
+				logger(c).Error("Error scanning v_role row", "error", err)
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to retrieve role hierarchies")
 				return
 			}
 			vRoles = append(vRoles, vr)
 		}
-		c.JSON(http.StatusOK, gin.H{"data": vRoles})
+		response.OK(c, vRoles)
 	}
 }
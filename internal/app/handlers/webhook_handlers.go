@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/services"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhooks receives the entity events recorded by EnqueueAuditLog; set by SetupRoutes
+var webhooks services.WebhookService
+
+// webhookEntities maps the audited tables whose changes webhooks are sent for to their
+// event entity names
+var webhookEntities = map[string]string{"users": "user", "roles": "role", "menu": "menu", "security_events": "security_event"}
+
+// webhookActions maps audit event types to event actions
+var webhookActions = map[string]string{"CREATE": "created", "UPDATE": "updated", "DELETE": "deleted"}
+
+// publishWebhookEvent hands a committed change to the webhook dispatcher. It is called with
+// every audit entry, which is only logged once the change is committed (for an atomic batch,
+// once the whole batch is).
+func publishWebhookEvent(eventType, tableName string, recordID uint64) {
+	entity, ok := webhookEntities[tableName]
+	action, known := webhookActions[eventType]
+	if webhooks == nil || !ok || !known {
+		return
+	}
+	webhooks.Publish(entity+"."+action, strconv.FormatUint(recordID, 10))
+}
+
+// listWebhooksHandler GET /api/webhooks
+func listWebhooksHandler(webhookService services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := webhookService.ListWebhooks(c.Request.Context())
+		if utils.HandleError(c, err, "list webhooks") {
+			return
+		}
+		response.OK(c, list)
+	}
+}
+
+// getWebhookHandler GET /api/webhooks/:id
+func getWebhookHandler(webhookService services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhook, err := webhookService.GetWebhook(c.Request.Context(), c.Param("id"))
+		if utils.HandleError(c, err, "get webhook") {
+			return
+		}
+		response.OK(c, webhook)
+	}
+}
+
+// createWebhookHandler POST /api/webhooks
+// The response carries the signing secret; it is not shown again.
+func createWebhookHandler(webhookService services.WebhookService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateWebhookRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		created, err := webhookService.CreateWebhook(c.Request.Context(), req, getUserIDFromContext(c))
+		if utils.HandleError(c, err, "create webhook") {
+			return
+		}
+
+		// Audit logging, without the secret
+		logAuditEntry(c, "CREATE", "webhooks", created.ID, nil, created.Webhook, db)
+
+		response.Write(c, http.StatusCreated, response.Body{Data: created, Message: "Webhook created"})
+	}
+}
+
+// updateWebhookHandler PUT /api/webhooks/:id
+// Members left out keep their values; a new secret is used from the next attempt on.
+func updateWebhookHandler(webhookService services.WebhookService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateWebhookRequest
+		if !bindJSONRequest(c, &req) {
+			return
+		}
+
+		webhook, err := webhookService.UpdateWebhook(c.Request.Context(), c.Param("id"), req)
+		if utils.HandleError(c, err, "update webhook") {
+			return
+		}
+
+		// Audit logging, without the secret
+		logAuditEntry(c, "UPDATE", "webhooks", webhook.ID, nil, webhook, db)
+
+		response.Write(c, http.StatusOK, response.Body{Data: webhook, Message: "Webhook updated"})
+	}
+}
+
+// deleteWebhookHandler DELETE /api/webhooks/:id
+// Deliveries still waiting for a retry are marked failed; the delivery log is kept.
+func deleteWebhookHandler(webhookService services.WebhookService, db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		webhook, err := webhookService.GetWebhook(c.Request.Context(), id)
+		if utils.HandleError(c, err, "delete webhook") {
+			return
+		}
+
+		if err := webhookService.DeleteWebhook(c.Request.Context(), id, getUserIDFromContext(c)); utils.HandleError(c, err, "delete webhook") {
+			return
+		}
+
+		// Audit logging for DELETE event
+		logAuditEntry(c, "DELETE", "webhooks", webhook.ID, webhook, nil, db)
+
+		response.Write(c, http.StatusOK, response.Body{Message: "Webhook deleted"})
+	}
+}
+
+// listWebhookDeliveriesHandler GET /api/webhooks/:id/deliveries
+// The latest deliveries, newest first. Takes ?status=pending|succeeded|failed and ?limit=
+// (default 50, at most 1000).
+func listWebhookDeliveriesHandler(webhookService services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := parseIntMinMax(c.Query("limit"), 50, 1, 1000)
+		deliveries, err := webhookService.ListDeliveries(c.Request.Context(), c.Param("id"), c.Query("status"), limit)
+		if utils.HandleError(c, err, "list webhook deliveries") {
+			return
+		}
+		response.Write(c, http.StatusOK, response.Body{Data: deliveries, Meta: response.Meta{"count": len(deliveries)}})
+	}
+}
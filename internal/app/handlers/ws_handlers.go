@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// wsCloseUnauthorized closes a /ws connection over its token. It is in the range RFC 6455
+// leaves to applications, and mirrors HTTP 401.
+const wsCloseUnauthorized = 4401
+
+// errWSAuthRequired closes a connection whose first message was not an auth message
+var errWSAuthRequired = errors.New("Authentication required")
+
+// wsWriteTimeout bounds a single write to a client
+const wsWriteTimeout = 10 * time.Second
+
+// wsUpgrader accepts every origin, whatever the CORS policy. Clients authenticate with a
+// token rather than cookies, so another site cannot open a connection in a user's name.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// wsAuthMessage is the first message of a client that could not send an Authorization
+// header (browsers cannot, on a WebSocket)
+type wsAuthMessage struct {
+	Type  string `json:"type"` // "auth"
+	Token string `json:"token"`
+}
+
+// wsConfig tunes the /ws endpoint
+type wsConfig struct {
+	Buffer       int           // notifications a connection may fall behind by
+	PingInterval time.Duration // how often clients are pinged; two missed pongs close it
+	AuthTimeout  time.Duration // how long a client has to send its auth message
+}
+
+// wsHandler GET /ws
+// Upgrades to a WebSocket that pushes the caller's notifications (notify.Notification, one
+// JSON text message each). The token comes from the Authorization header or, failing that,
+// from a first message {"type": "auth", "token": "..."}. The connection is closed with
+// code 4401 when the token is invalid and when it expires.
+func wsHandler(hub *notify.Hub, config wsConfig) gin.HandlerFunc {
+	if config.PingInterval <= 0 {
+		config.PingInterval = 30 * time.Second
+	}
+	if config.AuthTimeout <= 0 {
+		config.AuthTimeout = 10 * time.Second
+	}
+
+	return func(c *gin.Context) {
+		var claims *middleware.TokenClaims
+		if header := c.GetHeader("Authorization"); header != "" {
+			var err error
+			claims, err = middleware.ParseToken(strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				utils.RespondError(c, http.StatusUnauthorized, err.Error())
+				return
+			}
+		}
+
+		ws, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// The upgrader has answered the client already
+			logger(c).Info("WebSocket upgrade failed", "error", err)
+			return
+		}
+		defer ws.Close()
+		ws.SetReadLimit(4096)
+
+		if claims == nil {
+			if claims, err = wsAuthenticate(ws, config.AuthTimeout); err != nil {
+				wsClose(ws, wsCloseUnauthorized, err.Error())
+				return
+			}
+		}
+		if claims.UserID == 0 {
+			wsClose(ws, wsCloseUnauthorized, middleware.ErrInvalidTokenClaims.Error())
+			return
+		}
+
+		conn := hub.Register(claims.UserID, config.Buffer)
+		defer conn.Close()
+		logger(c).Info("WebSocket connected", "user_id", claims.UserID)
+
+		// The read loop answers pings and notices the client leaving; clients send nothing else
+		gone := make(chan struct{})
+		ws.SetReadDeadline(time.Now().Add(2 * config.PingInterval))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(2 * config.PingInterval))
+		})
+		go func() {
+			defer close(gone)
+			for {
+				if _, _, err := ws.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(config.PingInterval)
+		defer ping.Stop()
+		var expired <-chan time.Time
+		if !claims.ExpiresAt.IsZero() {
+			timer := time.NewTimer(time.Until(claims.ExpiresAt))
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		for {
+			select {
+			case <-gone:
+				return
+			case <-expired:
+				wsClose(ws, wsCloseUnauthorized, "Token expired")
+				return
+			case <-draining:
+				wsClose(ws, websocket.CloseGoingAway, "Server shutting down")
+				return
+			case <-ping.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+					return
+				}
+			case n, ok := <-conn.C:
+				if !ok {
+					if conn.Lagging() {
+						logger(c).Warn("WebSocket client fell behind, disconnecting", "user_id", claims.UserID)
+						wsClose(ws, websocket.CloseTryAgainLater, "Too far behind")
+					}
+					return
+				}
+				ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := ws.WriteJSON(n); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// wsAuthenticate reads the client's auth message
+func wsAuthenticate(ws *websocket.Conn, timeout time.Duration) (*middleware.TokenClaims, error) {
+	ws.SetReadDeadline(time.Now().Add(timeout))
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return nil, errWSAuthRequired
+	}
+	var msg wsAuthMessage
+	if json.Unmarshal(data, &msg) != nil || msg.Type != "auth" {
+		return nil, errWSAuthRequired
+	}
+	return middleware.ParseToken(strings.TrimPrefix(msg.Token, "Bearer "))
+}
+
+// wsClose sends a close frame; the caller closes the connection
+func wsClose(ws *websocket.Conn, code int, reason string) {
+	ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+}
+
+// notifyUser sends n to userID's /ws connections once the writes c made are committed
+func notifyUser(c *gin.Context, userID uint64, n notify.Notification) {
+	afterCommit(c, func() { notify.Send(userID, n) })
+}
+
+// notifyUserLocked tells a user whose account an update left disabled
+func notifyUserLocked(c *gin.Context, user *models.User, req models.UpdateUserRequest) {
+	if req.Status == nil || user.Status == 1 {
+		return
+	}
+	notifyUser(c, user.ID, notify.Notification{
+		Type:    notify.TypeUserLocked,
+		Message: "Your account has been disabled",
+		Data:    map[string]any{"status": user.Status},
+	})
+}
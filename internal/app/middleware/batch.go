@@ -0,0 +1,19 @@
+package middleware
+
+import "context"
+
+// batchKey marks the context of a sub-request run by POST /api/batch
+type batchKey struct{}
+
+// WithinBatch marks ctx as belonging to a sub-request of a batch
+func WithinBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, true)
+}
+
+// InBatch reports whether ctx belongs to a sub-request of a batch. Such requests skip the
+// global concurrency limit, since the batch already holds a slot, and the response cache,
+// since they may read the batch's uncommitted writes.
+func InBatch(ctx context.Context) bool {
+	v, _ := ctx.Value(batchKey{}).(bool)
+	return v
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errBodyTooLarge is what handlers reading past the limit get from the body
+var errBodyTooLarge = errors.New("request body too large")
+
+var bodyRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "http",
+	Name:      "request_body_rejections_total",
+	Help:      "Request bodies refused, by reason (too_large, unsupported_media_type).",
+}, []string{"reason"})
+
+func init() {
+	metrics.Registry.MustRegister(bodyRejections)
+}
+
+// BodyLimits caps the size of request bodies and the media types they may have, by route
+type BodyLimits struct {
+	MaxBytes int64            // applies to routes without an override; 0 leaves bodies unlimited
+	Routes   map[string]int64 // overrides keyed by route prefix, e.g. "/api/batch"
+	// Media lists the media types bodies may have, "type/*" matching a whole type; empty
+	// accepts any. RouteMedia overrides it by route prefix.
+	Media      []string
+	RouteMedia map[string][]string
+}
+
+// For returns the limit and media types of a route pattern (gin's FullPath); the longest
+// matching prefix of each wins
+func (l BodyLimits) For(path string) (int64, []string) {
+	maxBytes, media := l.MaxBytes, l.Media
+	if v, ok := longestPrefix(l.Routes, path); ok {
+		maxBytes = v
+	}
+	if v, ok := longestPrefix(l.RouteMedia, path); ok {
+		media = v
+	}
+	return maxBytes, media
+}
+
+// longestPrefix returns the value of the longest key of m that is path or a whole-segment
+// prefix of it
+func longestPrefix[T any](m map[string]T, path string) (T, bool) {
+	var value T
+	matched := -1
+	for prefix, v := range m {
+		if len(prefix) > matched && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			value, matched = v, len(prefix)
+		}
+	}
+	return value, matched >= 0
+}
+
+// BodyLimitMiddleware refuses request bodies of a media type the route does not take with
+// 415, and bodies over its limit with 413: at once when Content-Length gives them away, or
+// once the handler reads past the limit, replacing whatever it then answers. Bodies are
+// never read ahead, so nothing bigger than the limit is buffered.
+func BodyLimitMiddleware(limits BodyLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+		maxBytes, media := limits.For(c.FullPath())
+
+		if len(media) > 0 && !acceptedMedia(c.GetHeader("Content-Type"), media) {
+			bodyRejections.WithLabelValues("unsupported_media_type").Inc()
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, response.RenderError(c, http.StatusUnsupportedMediaType, response.Failure{
+				Code:    response.CodeUnsupportedMedia,
+				Message: "Content-Type must be one of " + strings.Join(media, ", "),
+				Details: response.Meta{"accepted": media},
+			}))
+			return
+		}
+		if maxBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			bodyRejections.WithLabelValues("too_large").Inc()
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, response.RenderError(c, http.StatusRequestEntityTooLarge, bodyTooLarge(maxBytes)))
+			return
+		}
+
+		body := &limitedBody{ReadCloser: c.Request.Body, remaining: maxBytes}
+		c.Request.Body = body
+		c.Writer = &bodyLimitWriter{ResponseWriter: c.Writer, body: body, maxBytes: maxBytes,
+			version: response.Negotiate(c), problem: response.WantsProblem(c), instance: c.Request.URL.Path,
+			requestID: tracing.RequestID(c.Request.Context()), language: i18n.Language(c.Request.Context())}
+		c.Next()
+	}
+}
+
+// acceptedMedia reports whether the Content-Type header names one of media
+func acceptedMedia(contentType string, media []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, m := range media {
+		if m == mediaType || (strings.HasSuffix(m, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(m, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func bodyTooLarge(maxBytes int64) response.Failure {
+	return response.Failure{
+		Code:    response.CodePayloadTooLarge,
+		Message: "Request body exceeds " + strconv.FormatInt(maxBytes, 10) + " bytes",
+		Details: response.Meta{"max_bytes": maxBytes},
+	}
+}
+
+// limitedBody fails reads past the limit, remembering that it did
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	// One byte more than remains tells a body ending at the limit from a longer one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		n = int(b.remaining)
+		err = errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// bodyLimitWriter replaces the response with a 413 when the handler read past the limit
+type bodyLimitWriter struct {
+	gin.ResponseWriter
+	body      *limitedBody
+	maxBytes  int64
+	version   string // response format the client negotiated
+	problem   bool   // the client accepts problem details
+	instance  string // request path, the instance of a problem
+	requestID string
+	language  string // language of the request's messages
+	rejected  bool
+}
+
+// tooLarge reports whether the response must be dropped, writing the 413 the first time
+func (w *bodyLimitWriter) tooLarge() bool {
+	if w.rejected {
+		return true
+	}
+	if !w.body.exceeded || w.ResponseWriter.Written() {
+		return false
+	}
+	w.rejected = true
+	bodyRejections.WithLabelValues("too_large").Inc()
+
+	h := w.ResponseWriter.Header()
+	h.Del("Content-Disposition")
+	h.Del("Content-Length")
+	h.Add("Vary", response.HeaderAcceptVersion)
+	h.Add("Vary", "Accept")
+	failure := bodyTooLarge(w.maxBytes)
+	failure.Message = i18n.Translate(w.language, failure.Message)
+	var body []byte
+	if w.problem {
+		h.Set("Content-Type", response.ContentTypeProblem)
+		body, _ = json.Marshal(response.ProblemFor(http.StatusRequestEntityTooLarge, w.instance, w.requestID, failure))
+	} else {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		body, _ = json.Marshal(response.ErrorFor(w.version, w.requestID, failure))
+	}
+	w.ResponseWriter.WriteHeader(http.StatusRequestEntityTooLarge)
+	w.ResponseWriter.Write(body)
+	return true
+}
+
+func (w *bodyLimitWriter) Write(b []byte) (int, error) {
+	if w.tooLarge() {
+		return 0, errBodyTooLarge
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLimitWriter) WriteString(s string) (int, error) {
+	if w.tooLarge() {
+		return 0, errBodyTooLarge
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyLimitWriter) WriteHeaderNow() {
+	if !w.tooLarge() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bodyLimitWriter) Flush() {
+	if !w.tooLarge() {
+		w.ResponseWriter.Flush()
+	}
+}
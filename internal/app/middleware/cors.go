@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig is the cors section of the configuration: the policy of every route, and
+// Groups overriding it for route prefixes, e.g. the public prayer API open to any site
+// while the admin API only answers the admin front-end
+type CORSConfig struct {
+	// AllowOrigins lists exact origins ("https://admin.example.com"), origins with one
+	// wildcard ("https://*.example.com") or "*" for any
+	AllowOrigins  []string `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS" default:"*"`
+	AllowMethods  []string `yaml:"allow_methods" env:"CORS_ALLOW_METHODS" default:"GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS"`
+	AllowHeaders  []string `yaml:"allow_headers" env:"CORS_ALLOW_HEADERS" default:"Origin,Content-Type,Content-Length,Accept,Accept-Version,Authorization,If-None-Match,Idempotency-Key,X-Request-ID,X-CSRF-Token,X-Challenge-Response"`
+	ExposeHeaders []string `yaml:"expose_headers" env:"CORS_EXPOSE_HEADERS" default:"X-Request-ID,ETag,Location,Retry-After,Deprecation,Sunset,Link,Idempotent-Replayed,X-Cache,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset"`
+	// AllowCredentials lets browsers send cookies; it needs explicit origins
+	AllowCredentials bool `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	// MaxAge is how long browsers may cache a preflight answer
+	MaxAge time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" default:"12h"`
+	Groups []CORSGroup   `yaml:"groups"`
+}
+
+// CORSGroup overrides the policy for the paths under Prefix; the longest matching prefix
+// wins, and settings left out are those of the section
+type CORSGroup struct {
+	Prefix           string        `yaml:"prefix"`
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials *bool         `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// Validate checks every policy up front, so a mistake fails the start instead of the
+// first request
+func (c *CORSConfig) Validate() error {
+	var errs []error
+	if err := validateCORS(c.policy()); err != nil {
+		errs = append(errs, fmt.Errorf("cors: %w", err))
+	}
+	seen := make(map[string]bool, len(c.Groups))
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		g.Prefix = strings.TrimSuffix(g.Prefix, "/")
+		if !strings.HasPrefix(g.Prefix, "/") {
+			errs = append(errs, fmt.Errorf("cors group %d: prefix %q must start with /", i, g.Prefix))
+			continue
+		}
+		if seen[g.Prefix] {
+			errs = append(errs, fmt.Errorf("cors group %s: duplicate prefix", g.Prefix))
+			continue
+		}
+		seen[g.Prefix] = true
+		if err := validateCORS(c.groupPolicy(*g)); err != nil {
+			errs = append(errs, fmt.Errorf("cors group %s: %w", g.Prefix, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCORS catches what cors.New would panic on, and credentials with any origin,
+// which browsers refuse
+func validateCORS(p cors.Config) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	for _, origin := range p.AllowOrigins {
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("origin %q has more than one *", origin)
+		}
+	}
+	if p.AllowCredentials && slices.Contains(p.AllowOrigins, "*") {
+		return errors.New("allow_credentials needs explicit origins, not *")
+	}
+	return nil
+}
+
+// policy is the section's own policy
+func (c CORSConfig) policy() cors.Config {
+	return cors.Config{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+		AllowWildcard:    true,
+	}
+}
+
+// groupPolicy is the section's policy with g's overrides
+func (c CORSConfig) groupPolicy(g CORSGroup) cors.Config {
+	p := c.policy()
+	if len(g.AllowOrigins) > 0 {
+		p.AllowOrigins = g.AllowOrigins
+	}
+	if len(g.AllowMethods) > 0 {
+		p.AllowMethods = g.AllowMethods
+	}
+	if len(g.AllowHeaders) > 0 {
+		p.AllowHeaders = g.AllowHeaders
+	}
+	if len(g.ExposeHeaders) > 0 {
+		p.ExposeHeaders = g.ExposeHeaders
+	}
+	if g.AllowCredentials != nil {
+		p.AllowCredentials = *g.AllowCredentials
+	}
+	if g.MaxAge > 0 {
+		p.MaxAge = g.MaxAge
+	}
+	return p
+}
+
+// CORSMiddleware applies the policy of the request path: answering preflights, adding the
+// Access-Control headers, and refusing disallowed origins with 403. It matches the raw
+// path rather than the route, since preflights run before routing and match none. The
+// config must have been validated.
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	base := cors.New(cfg.policy())
+	groups := make(map[string]gin.HandlerFunc, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groups[g.Prefix] = cors.New(cfg.groupPolicy(g))
+	}
+
+	return func(c *gin.Context) {
+		handler, matched := base, -1
+		path := c.Request.URL.Path
+		for prefix, h := range groups {
+			if len(prefix) > matched && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+				handler, matched = h, len(prefix)
+			}
+		}
+		handler(c)
+	}
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderCSRFToken carries the CSRF token on unsafe requests authenticated by the session cookie
+const HeaderCSRFToken = "X-CSRF-Token"
+
+// ctxCookieSession marks a request AuthMiddleware authenticated by the session cookie
+const ctxCookieSession = "cookie_session"
+
+// CookieAuthConfig is the cookie_auth section of the configuration. Browsers may sign in with
+// an HttpOnly session cookie instead of keeping the token where scripts can read it; requests
+// authenticated that way must then echo the CSRF cookie in X-CSRF-Token on every unsafe
+// method (double submit). Bearer-token clients are never asked for it.
+type CookieAuthConfig struct {
+	Enabled bool `yaml:"enabled" env:"COOKIE_AUTH_ENABLED" default:"false"`
+	// Name is the session cookie, CSRFName the CSRF cookie scripts read
+	Name     string `yaml:"name" env:"COOKIE_AUTH_NAME" default:"adminbe_session"`
+	CSRFName string `yaml:"csrf_name" env:"CSRF_COOKIE_NAME" default:"adminbe_csrf"`
+	// Domain shares the cookies with subdomains; empty keeps them to this host
+	Domain string `yaml:"domain" env:"COOKIE_DOMAIN"`
+	// Secure sends the cookies over HTTPS only; turn it off for plain-HTTP development
+	Secure bool `yaml:"secure" env:"COOKIE_SECURE" default:"true"`
+	// SameSite is lax, strict or none; none needs Secure and suits a front-end on another site
+	SameSite string `yaml:"same_site" env:"COOKIE_SAME_SITE" default:"lax"`
+}
+
+// Validate checks the cookie names and SameSite mode
+func (c *CookieAuthConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.Name == "" || c.CSRFName == "" || c.Name == c.CSRFName {
+		errs = append(errs, errors.New("COOKIE_AUTH_NAME and CSRF_COOKIE_NAME must be set and differ"))
+	}
+	switch strings.ToLower(c.SameSite) {
+	case "lax", "strict":
+	case "none":
+		if !c.Secure {
+			errs = append(errs, errors.New("COOKIE_SAME_SITE=none needs COOKIE_SECURE=true"))
+		}
+	default:
+		errs = append(errs, errors.New("COOKIE_SAME_SITE must be lax, strict or none"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *CookieAuthConfig) sameSite() http.SameSite {
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+var cookieAuth atomic.Pointer[CookieAuthConfig]
+
+func init() {
+	cookieAuth.Store(&CookieAuthConfig{})
+}
+
+// SetCookieAuth configures cookie sessions; SetupRoutes calls it with the validated section
+func SetCookieAuth(cfg CookieAuthConfig) {
+	cookieAuth.Store(&cfg)
+}
+
+// CookieAuthEnabled reports whether clients may sign in with a session cookie
+func CookieAuthEnabled() bool {
+	return cookieAuth.Load().Enabled
+}
+
+// sessionToken returns the token in the session cookie, if cookie sessions are on
+func sessionToken(c *gin.Context) string {
+	cfg := cookieAuth.Load()
+	if !cfg.Enabled {
+		return ""
+	}
+	token, _ := c.Cookie(cfg.Name)
+	return token
+}
+
+// StartCookieSession sets the session cookie to token, valid for ttl like the token, with a
+// new CSRF cookie bound to it; the CSRF token is returned for the response body
+func StartCookieSession(c *gin.Context, token string, ttl time.Duration) (string, error) {
+	cfg := cookieAuth.Load()
+	csrfToken, err := newCSRFToken(token)
+	if err != nil {
+		return "", err
+	}
+	setCookie(c, cfg, cfg.Name, token, int(ttl.Seconds()), true)
+	setCookie(c, cfg, cfg.CSRFName, csrfToken, int(ttl.Seconds()), false)
+	return csrfToken, nil
+}
+
+// EndCookieSession clears the session and CSRF cookies
+func EndCookieSession(c *gin.Context) {
+	cfg := cookieAuth.Load()
+	setCookie(c, cfg, cfg.Name, "", -1, true)
+	setCookie(c, cfg, cfg.CSRFName, "", -1, false)
+}
+
+// IssueCSRFToken sets a fresh CSRF cookie for the request's session and returns the token.
+// Bearer-token requests get one too, unbound, though they never need it.
+func IssueCSRFToken(c *gin.Context) (string, error) {
+	cfg := cookieAuth.Load()
+	token, err := newCSRFToken(sessionToken(c))
+	if err != nil {
+		return "", err
+	}
+	if cfg.Enabled {
+		// As long as the session it is bound to can last
+		setCookie(c, cfg, cfg.CSRFName, token, 0, false)
+	}
+	return token, nil
+}
+
+// CookieSession reports whether AuthMiddleware authenticated the request by the session cookie
+func CookieSession(c *gin.Context) bool {
+	return c.GetBool(ctxCookieSession)
+}
+
+// setCookie writes a cookie for the whole API; maxAge < 0 deletes it, 0 lasts the browser session.
+// The CSRF cookie is readable by scripts, which must copy it into X-CSRF-Token.
+func setCookie(c *gin.Context, cfg *CookieAuthConfig, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.sameSite(),
+	})
+}
+
+// A CSRF token is a nonce with its MAC over the session it belongs to, so a token planted
+// by a sibling subdomain, or left from an earlier session, is refused
+func newCSRFToken(session string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + csrfMAC(encoded, session), nil
+}
+
+func csrfMAC(nonce, session string) string {
+	// Keyed like the session, so rotating the signing key leaves open sessions working
+	mac := hmac.New(sha256.New, jwtkeys.Default.SecretFor(session))
+	mac.Write([]byte("csrf|" + nonce + "|" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRF checks the double submit: the header matches the cookie, and was issued for session
+func validCSRF(c *gin.Context, session string) bool {
+	header := c.GetHeader(HeaderCSRFToken)
+	cookie, _ := c.Cookie(cookieAuth.Load().CSRFName)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 {
+		return false
+	}
+	nonce, mac, ok := strings.Cut(header, ".")
+	return ok && hmac.Equal([]byte(mac), []byte(csrfMAC(nonce, session)))
+}
+
+// safeMethod reports whether a method only reads, so needs no CSRF token
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// abortCSRF answers 403 with its own code, so front-ends know to fetch a new token and retry
+func abortCSRF(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, response.RenderError(c, http.StatusForbidden, response.Failure{
+		Code:    response.CodeCSRFInvalid,
+		Message: "Missing or invalid CSRF token; send the " + cookieAuth.Load().CSRFName + " cookie value in " + HeaderCSRFToken,
+	}))
+}
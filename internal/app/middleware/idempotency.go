@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Idempotency headers
+const (
+	HeaderIdempotencyKey    = "Idempotency-Key"
+	HeaderIdempotentReplay  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+const (
+	// idempotencyLockTTL bounds how long a key stays claimed by a request that never
+	// finishes (e.g. the process died); it must outlast the longest route budget
+	idempotencyLockTTL = 5 * time.Minute
+	// maxIdempotentBodySize bounds the responses stored for replay. Larger ones (big report
+	// exports) are sent as they are written and release their key instead.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// replayedHeaders are the response headers stored with a body; the rest (request ID, CORS,
+// Vary) belong to the request that is being answered
+var replayedHeaders = []string{"Content-Type", "Content-Disposition", "Location"}
+
+var idempotencyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "idempotency",
+	Name:      "requests_total",
+	Help:      "Requests carrying an Idempotency-Key, by scope and outcome (stored, replayed, in_progress, mismatch, released, unavailable).",
+}, []string{"scope", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(idempotencyRequests)
+}
+
+// idempotentResponse is what a key holds: only the request fingerprint while the first
+// request runs, then its complete response
+type idempotentResponse struct {
+	Fingerprint string            `json:"fingerprint"`
+	Done        bool              `json:"done"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// idempotencyWriter buffers the response for storage, switching to writing it straight
+// through once it outgrows maxIdempotentBodySize
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (w *idempotencyWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *idempotencyWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	if !w.passthrough && w.body.Len()+len(data) > maxIdempotentBodySize {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			return 0, err
+		}
+		w.body.Reset()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *idempotencyWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *idempotencyWriter) Status() int {
+	return w.status
+}
+
+func (w *idempotencyWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *idempotencyWriter) Written() bool {
+	return w.passthrough || w.body.Len() > 0
+}
+
+// IdempotencyMiddleware makes retries of a mutating route safe. The first request with an
+// Idempotency-Key header runs and its response is stored for ttl; a retry with the same key
+// gets that response again (marked Idempotent-Replayed) instead of running the handler, so a
+// client that timed out and resubmits does not create a second user. Keys are per caller
+// and scope. While the first request is still running a retry gets 409, and reusing a key
+// for a different request body gets 422. Responses of 5xx are not stored, so the retry runs
+// again. Requests without the header are not affected.
+//
+// Register it after AuthMiddleware, on the routes it protects.
+func IdempotencyMiddleware(store cache.Cache, scope string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idemKey := c.GetHeader(HeaderIdempotencyKey)
+		if idemKey == "" || !cache.Enabled() {
+			c.Next()
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLength {
+			rejectIdempotent(c, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters", HeaderIdempotencyKey, maxIdempotencyKeyLength))
+			return
+		}
+
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			rejectIdempotent(c, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+
+		log := logging.FromContext(c.Request.Context())
+		key := idempotencyCacheKey(c, scope, idemKey)
+		claimed, err := store.SetNX(key, idempotentResponse{Fingerprint: fingerprint}, idempotencyLockTTL)
+		if err != nil {
+			// Without the store the request cannot be deduplicated; serve it rather than fail
+			log.Warn("Idempotency store unavailable", "scope", scope, "error", err)
+			idempotencyRequests.WithLabelValues(scope, "unavailable").Inc()
+			c.Next()
+			return
+		}
+		if !claimed {
+			replayIdempotent(c, store, scope, key, fingerprint)
+			return
+		}
+
+		original := c.Writer
+		w := &idempotencyWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		if w.passthrough || w.status >= http.StatusInternalServerError {
+			// Not replayable: free the key so a retry runs the request again
+			if err := store.Delete(key); err != nil {
+				log.Warn("Failed to release idempotency key", "scope", scope, "error", err)
+			}
+			idempotencyRequests.WithLabelValues(scope, "released").Inc()
+		} else {
+			stored := idempotentResponse{
+				Fingerprint: fingerprint,
+				Done:        true,
+				Status:      w.status,
+				Header:      map[string]string{},
+				Body:        w.body.Bytes(),
+			}
+			for _, name := range replayedHeaders {
+				if v := w.Header().Get(name); v != "" {
+					stored.Header[name] = v
+				}
+			}
+			if err := store.Set(key, stored, ttl); err != nil {
+				log.Warn("Failed to store idempotent response", "scope", scope, "error", err)
+			}
+			idempotencyRequests.WithLabelValues(scope, "stored").Inc()
+		}
+
+		if !w.passthrough {
+			original.WriteHeader(w.status)
+			original.Write(w.body.Bytes())
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key is already claimed
+func replayIdempotent(c *gin.Context, store cache.Cache, scope, key, fingerprint string) {
+	var prior idempotentResponse
+	err := store.Get(key, &prior)
+	if err == nil && prior.Fingerprint != fingerprint {
+		idempotencyRequests.WithLabelValues(scope, "mismatch").Inc()
+		rejectIdempotent(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if err != nil || !prior.Done {
+		// Still running, or released by a failure a moment ago: either way, retry later
+		idempotencyRequests.WithLabelValues(scope, "in_progress").Inc()
+		c.Header("Retry-After", "1")
+		rejectIdempotent(c, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+		return
+	}
+
+	idempotencyRequests.WithLabelValues(scope, "replayed").Inc()
+	for name, v := range prior.Header {
+		c.Header(name, v)
+	}
+	c.Header(HeaderIdempotentReplay, "true")
+	c.Data(prior.Status, prior.Header["Content-Type"], prior.Body)
+	c.Abort()
+}
+
+// rejectIdempotent aborts with an error about the Idempotency-Key
+func rejectIdempotent(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, response.RenderError(c, status, response.Failure{
+		Code:    response.CodeForStatus(status),
+		Message: message,
+	}))
+}
+
+// requestFingerprint hashes what makes a request the same request: its method, route and
+// body. The body is restored for the handler.
+func requestFingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method)
+	io.WriteString(h, "|"+c.Request.URL.Path)
+	io.WriteString(h, "|"+c.Request.URL.RawQuery)
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write([]byte("|"))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idempotencyCacheKey scopes a client's key to the caller, so callers cannot collide with
+// or read each other's responses
+func idempotencyCacheKey(c *gin.Context, scope, idemKey string) string {
+	caller := "anonymous"
+	if id, ok := c.Get("user_id"); ok {
+		caller = fmt.Sprint(id)
+	}
+	sum := sha256.Sum256([]byte(caller + "|" + idemKey))
+	return fmt.Sprintf(cache.CacheKeyIdempotency, scope, hex.EncodeToString(sum[:]))
+}
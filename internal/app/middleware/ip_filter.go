@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ipFilterRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "ip_filter",
+	Name:      "rejections_total",
+	Help:      "Requests refused by the IP filter, by list (deny, allow).",
+}, []string{"list"})
+
+func init() {
+	metrics.Registry.MustRegister(ipFilterRejections)
+}
+
+// IPFilterMiddleware answers 403 to clients whose address f refuses, except on the paths
+// under the exempt prefixes. The address is gin's ClientIP, so TRUSTED_PROXIES must list
+// the proxies in front, and only them: a trusted sender's X-Forwarded-For names the address
+// checked. Register it before AuthMiddleware, so refused clients get no further.
+func IPFilterMiddleware(f *ipfilter.Filter, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if underPrefix(c.Request.URL.Path, exempt) {
+			c.Next()
+			return
+		}
+		err := f.Check(c.ClientIP())
+		if err == nil {
+			c.Next()
+			return
+		}
+
+		list := "allow"
+		if errors.Is(err, ipfilter.ErrDenied) {
+			list = "deny"
+		}
+		ipFilterRejections.WithLabelValues(list).Inc()
+		c.AbortWithStatusJSON(http.StatusForbidden, response.RenderError(c, http.StatusForbidden, response.Failure{
+			Code:    response.CodeForbidden,
+			Message: "Access from this address is not allowed",
+		}))
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"adminbe/internal/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LanguageMiddleware localizes each request's messages to the language it asks for with the
+// lang query parameter or Accept-Language (see i18n.Negotiate), and names it in
+// Content-Language. Register it globally, before anything that may answer with an error.
+func LanguageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.Query(i18n.QueryParam), c.GetHeader("Accept-Language"))
+		h := c.Writer.Header()
+		h.Set("Content-Language", lang)
+		h.Add("Vary", "Accept-Language")
+		c.Request = c.Request.WithContext(i18n.WithLanguage(c.Request.Context(), lang))
+		c.Next()
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons a request is shed, recorded on limiterRejected
+const (
+	shedQueueFull    = "queue_full"
+	shedQueueTimeout = "queue_timeout"
+	shedCanceled     = "canceled"
+)
+
+var (
+	limiterInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "limiter",
+		Name:      "in_flight",
+		Help:      "Requests currently holding a slot, by limiter.",
+	}, []string{"limiter"})
+
+	limiterQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "limiter",
+		Name:      "queued",
+		Help:      "Requests waiting for a slot, by limiter.",
+	}, []string{"limiter"})
+
+	limiterRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "limiter",
+		Name:      "rejected_total",
+		Help:      "Requests shed with 429, by limiter and reason (queue_full, queue_timeout, canceled).",
+	}, []string{"limiter", "reason"})
+
+	limiterWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "limiter",
+		Name:      "queue_wait_seconds",
+		Help:      "Time admitted requests spent waiting for a slot, by limiter.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"limiter"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(limiterInFlight, limiterQueued, limiterRejected, limiterWait)
+}
+
+// ConcurrencyLimiter admits a bounded number of requests at once. Requests arriving when
+// every slot is taken wait in a bounded queue for up to the queue timeout; anything beyond
+// that is shed with 429, so overload surfaces as fast rejections instead of every request
+// queueing on database connections or the Jasper proxy until it times out.
+type ConcurrencyLimiter struct {
+	name    string
+	limits  atomic.Pointer[limiterLimits]
+	waiting atomic.Int64
+}
+
+// limiterLimits are replaced as a whole by SetLimits. Requests give their slot back to the
+// slots they took it from, so resizing never blocks a release.
+type limiterLimits struct {
+	slots        chan struct{} // nil when the limiter is off
+	maxQueue     int64
+	queueTimeout time.Duration
+}
+
+// NewConcurrencyLimiter creates a limiter named for metrics. limit <= 0 disables it;
+// maxQueue is how many requests may wait at once and queueTimeout how long each may wait.
+func NewConcurrencyLimiter(name string, limit, maxQueue int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{name: name}
+	l.SetLimits(limit, maxQueue, queueTimeout)
+	return l
+}
+
+// SetLimits changes the limits for the requests arriving from now on, e.g. on a configuration
+// reload. Requests already admitted keep their slots, so while they finish up to the old and
+// the new limit together may run.
+func (l *ConcurrencyLimiter) SetLimits(limit, maxQueue int, queueTimeout time.Duration) {
+	next := &limiterLimits{maxQueue: int64(maxQueue), queueTimeout: queueTimeout}
+	if limit > 0 {
+		next.slots = make(chan struct{}, limit)
+		if cur := l.limits.Load(); cur != nil && cur.slots != nil && cap(cur.slots) == limit {
+			next.slots = cur.slots
+		}
+	}
+	l.limits.Store(next)
+}
+
+// acquire takes a slot of lim, waiting in the queue if needed; it returns the shed reason on failure
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, lim *limiterLimits) (string, bool) {
+	select {
+	case lim.slots <- struct{}{}:
+		return "", true
+	default:
+	}
+
+	if l.waiting.Add(1) > lim.maxQueue {
+		l.waiting.Add(-1)
+		return shedQueueFull, false
+	}
+	limiterQueued.WithLabelValues(l.name).Inc()
+	defer func() {
+		l.waiting.Add(-1)
+		limiterQueued.WithLabelValues(l.name).Dec()
+	}()
+
+	start := time.Now()
+	timer := time.NewTimer(lim.queueTimeout)
+	defer timer.Stop()
+	select {
+	case lim.slots <- struct{}{}:
+		limiterWait.WithLabelValues(l.name).Observe(time.Since(start).Seconds())
+		return "", true
+	case <-timer.C:
+		return shedQueueTimeout, false
+	case <-ctx.Done():
+		return shedCanceled, false
+	}
+}
+
+// Middleware applies the limiter. Route patterns in exempt (e.g. "/health") are never
+// limited, so probes and metrics keep answering under overload; neither are the
+// sub-requests of a batch, which already holds a slot.
+func (l *ConcurrencyLimiter) Middleware(exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		lim := l.limits.Load()
+		if lim.slots == nil || skip[c.FullPath()] || InBatch(c.Request.Context()) {
+			c.Next()
+			return
+		}
+
+		reason, ok := l.acquire(c.Request.Context(), lim)
+		if !ok {
+			limiterRejected.WithLabelValues(l.name, reason).Inc()
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response.RenderError(c, http.StatusTooManyRequests, response.Failure{
+				Code:    response.CodeOverloaded,
+				Message: "Server is busy, please retry shortly",
+				Legacy:  gin.H{"type": string(utils.ErrorTypeOverloaded)},
+			}))
+			return
+		}
+		limiterInFlight.WithLabelValues(l.name).Inc()
+		defer func() {
+			limiterInFlight.WithLabelValues(l.name).Dec()
+			<-lim.slots
+		}()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/pkg/maintenance"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMiddleware answers 503 with Retry-After while sw is in maintenance mode, except
+// on the paths under the exempt prefixes (probes, metrics, the switch itself) and for the
+// users the state allows, recognised by their access token. Register it globally, before
+// the limiter, so rejected requests do not take up slots.
+func MaintenanceMiddleware(sw *maintenance.Switch, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := sw.Current()
+		if !state.Enabled || underPrefix(c.Request.URL.Path, exempt) || state.Allows(tokenUserID(c)) {
+			c.Next()
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = "Service is under maintenance, please retry later"
+		}
+		retryAfter := state.RetryAfter()
+		c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, response.RenderError(c, http.StatusServiceUnavailable, response.Failure{
+			Code:    response.CodeMaintenance,
+			Message: message,
+			Details: response.Meta{"retry_after_seconds": int(retryAfter.Seconds())},
+		}))
+	}
+}
+
+// underPrefix reports whether path is one of prefixes or below one of them
+func underPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenUserID is the user of the request's access token, or 0 without a valid one. It is
+// for middleware that runs before AuthMiddleware, which still checks the token afterwards.
+func tokenUserID(c *gin.Context) uint64 {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		return 0
+	}
+	claims, err := ParseToken(token)
+	if err != nil {
+		return 0
+	}
+	return claims.UserID
+}
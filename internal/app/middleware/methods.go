@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// headKey marks a HEAD request routed as a GET
+type headKey struct{}
+
+// ServeHead answers HEAD requests with the GET route for the path, which gin does not do by
+// itself. The request is routed, logged and measured as a GET; net/http drops the body
+// because the client asked for HEAD, so the headers (ETag, Content-Type, Content-Length of
+// small responses) are exactly those of the GET. Wrap the engine with it when serving.
+func ServeHead(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodHead {
+			req = req.WithContext(context.WithValue(req.Context(), headKey{}, true))
+			req.Method = http.MethodGet
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// MethodNotAllowed is the engine's NoMethod handler; set engine.HandleMethodNotAllowed so
+// gin calls it, with the methods its router has for the path in the Allow header. OPTIONS
+// gets 204 with that list, which gateways and clients use to discover what a resource
+// supports (CORS preflights are answered before, by the CORS middleware). Any other method
+// the path does not have gets 405 METHOD_NOT_ALLOWED.
+func MethodNotAllowed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allow := allowedMethods(c.Writer.Header().Get("Allow"))
+		c.Header("Allow", strings.Join(allow, ", "))
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		method := c.Request.Method
+		if head, _ := c.Request.Context().Value(headKey{}).(bool); head {
+			method = http.MethodHead
+		}
+		response.WriteError(c, http.StatusMethodNotAllowed, response.Failure{
+			Code:    response.CodeMethodNotAllowed,
+			Message: method + " is not supported here",
+			Details: response.Meta{"allow": allow},
+		})
+		c.Abort()
+	}
+}
+
+// allowedMethods completes gin's Allow list with the methods every route answers: HEAD
+// wherever there is a GET (see ServeHead), and OPTIONS
+func allowedMethods(routed string) []string {
+	var allow []string
+	for _, m := range strings.Split(routed, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			allow = append(allow, m)
+		}
+	}
+	if slices.Contains(allow, http.MethodGet) && !slices.Contains(allow, http.MethodHead) {
+		allow = append(allow, http.MethodHead)
+	}
+	if !slices.Contains(allow, http.MethodOptions) {
+		allow = append(allow, http.MethodOptions)
+	}
+	slices.Sort(allow)
+	return allow
+}
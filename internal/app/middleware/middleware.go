@@ -1,40 +1,56 @@
 package middleware
 
 import (
+	"adminbe/internal/pkg/errortracking"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
 	"adminbe/internal/pkg/utils"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// RequestLoggerMiddleware logs incoming requests to console
+// RequestLoggerMiddleware writes one structured access log line per request, tagged with
+// the request ID. Register it after TracingMiddleware so the request logger is in place.
 // Removed per-request audit logging to prevent memory allocation from JSON marshaling
 // Audit logs should be created selectively in handlers for important actions only
 func RequestLoggerMiddleware(db *sql.DB) gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		logStr := fmt.Sprintf("%s - [%s] %s %s %s %d %s %s\n",
-			param.ClientIP,
-			param.TimeStamp.Format("2006/01/02 15:04:05"),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency.String(),
-			param.Request.UserAgent(),
-		)
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
 
-		// Log to console only - removed audit logging for every request
-		log.Print(logStr)
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "Request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", milliseconds(time.Since(start))),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
 
-		return logStr
-	})
+// milliseconds converts d for log attributes, which read better than JSON's nanoseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
 }
 
 // logRequestToAudit creates an audit log entry for the request
@@ -66,37 +82,91 @@ func logRequestToAudit(db *sql.DB, param gin.LogFormatterParams) {
 		userID, eventType, "api_requests", 0, requestJSON)
 }
 
-// CustomRecoveryMiddleware provides panic recovery with logging
+// CustomRecoveryMiddleware provides panic recovery with logging and error tracking
 func CustomRecoveryMiddleware() gin.HandlerFunc {
 	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered interface{}) {
-		if err, ok := recovered.(string); ok {
-			log.Printf("Panic recovered: %s", err)
-		} else {
-			log.Printf("Panic recovered: %v", recovered)
-		}
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "Internal server error occurred",
-		})
+		logging.FromContext(c.Request.Context()).Error("Panic recovered", "panic", fmt.Sprint(recovered),
+			"method", c.Request.Method, "path", c.Request.URL.Path)
+		errortracking.CapturePanic(c, recovered)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, response.RenderError(c, http.StatusInternalServerError, response.Failure{
+			Code:    response.CodeInternal,
+			Message: "Internal server error occurred",
+		}))
 	})
 }
 
-// SecurityHeadersMiddleware adds security headers to responses
-func SecurityHeadersMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("X-Content-Type-Options", "nosniff")
-		c.Header("X-Frame-Options", "DENY")
-		c.Header("X-XSS-Protection", "1; mode=block")
-		c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-		c.Next()
+// TokenClaims are what the API reads from an access token
+type TokenClaims struct {
+	// UserID is 0 when the token carries none
+	UserID uint64
+	// Roles is nil when the token carries none
+	Roles []string
+	// ExpiresAt is zero when the token does not expire
+	ExpiresAt time.Time
+	// TenantID is the tenant the token was issued by; tokens from before tenancy carry none
+	// and are the default tenant's
+	TenantID uint
+}
+
+// Token errors; their messages are what clients are told
+var (
+	ErrInvalidToken       = errors.New("Invalid token")
+	ErrInvalidTokenClaims = errors.New("Invalid token claims")
+)
+
+// ParseToken verifies an access token (without the "Bearer " prefix) and reads its claims.
+// AuthMiddleware, the gRPC server and the /ws endpoint all authenticate with it.
+func ParseToken(tokenString string) (*TokenClaims, error) {
+	token, err := jwt.Parse(tokenString, jwtkeys.Default.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidTokenClaims
 	}
+	out := TokenClaims{TenantID: tenant.DefaultID}
+	if userIDStr, ok := claims["user_id"].(string); ok {
+		userID, err := strconv.ParseUint(userIDStr, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidTokenClaims
+		}
+		out.UserID = userID
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		out.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	if tenantID, ok := claims["tenant_id"].(float64); ok {
+		if tenantID < 1 {
+			return nil, ErrInvalidTokenClaims
+		}
+		out.TenantID = uint(tenantID)
+	}
+	if rawRoles, ok := claims["roles"].([]interface{}); ok {
+		out.Roles = make([]string, 0, len(rawRoles))
+		for _, r := range rawRoles {
+			if name, ok := r.(string); ok {
+				out.Roles = append(out.Roles, name)
+			}
+		}
+	}
+	return &out, nil
 }
 
-// AuthMiddleware checks JWT token and sets user ID in context
+// AuthMiddleware checks JWT token and sets user ID in context. Without an Authorization
+// header the session cookie is tried when cookie sessions are on; unsafe requests
+// authenticated by it must carry the CSRF token as well. A token is only good for the
+// tenant TenantMiddleware resolved.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
+		fromCookie := false
+		if tokenString == "" {
+			tokenString, fromCookie = sessionToken(c), true
+		}
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			utils.RespondError(c, http.StatusUnauthorized, "Authorization header required")
 			c.Abort()
 			return
 		}
@@ -106,37 +176,75 @@ func AuthMiddleware() gin.HandlerFunc {
 			tokenString = tokenString[7:]
 		}
 
-		jwtSecret := utils.GetJWTSecret()
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		claims, err := ParseToken(tokenString)
+		if err != nil {
+			utils.RespondError(c, http.StatusUnauthorized, err.Error())
 			c.Abort()
 			return
 		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			if userIDStr, ok := claims["user_id"].(string); ok {
-				userID, err := strconv.ParseUint(userIDStr, 10, 64)
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-					c.Abort()
-					return
-				}
-				c.Set("user_id", userID)
-			}
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		if claims.TenantID != tenant.ID(c.Request.Context()) {
+			utils.RespondError(c, http.StatusUnauthorized, ErrInvalidToken.Error())
 			c.Abort()
 			return
 		}
+		if fromCookie {
+			if !safeMethod(c.Request.Method) && !validCSRF(c, tokenString) {
+				abortCSRF(c)
+				return
+			}
+			c.Set(ctxCookieSession, true)
+		}
+		if claims.UserID != 0 {
+			c.Set("user_id", claims.UserID)
+		}
+		if claims.Roles != nil {
+			c.Set("roles", claims.Roles)
+		}
 
 		c.Next()
 	}
 }
+
+// GetRolesFromContext returns the role names set by AuthMiddleware
+func GetRolesFromContext(c *gin.Context) []string {
+	if roles, ok := c.Get("roles"); ok {
+		if names, ok := roles.([]string); ok {
+			return names
+		}
+	}
+	return nil
+}
+
+// RoleAdmin is the built-in administrator role that passes every role check
+const RoleAdmin = "admin"
+
+// RoleOps may reach operational endpoints such as /debug/pprof without full admin rights
+const RoleOps = "ops"
+
+// RoleRuntimeAdmin may view and change runtime settings on /api/admin/runtime
+const RoleRuntimeAdmin = "runtime_admin"
+
+// RoleBackupAdmin may make and download database backups on /api/admin/backups
+const RoleBackupAdmin = "backup_admin"
+
+// RequireRoles allows the request only when the caller holds one of roles.
+// Must run after AuthMiddleware. Administrators are always allowed.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles)+1)
+	allowed[RoleAdmin] = true
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return func(c *gin.Context) {
+		for _, role := range GetRolesFromContext(c) {
+			if allowed[role] {
+				c.Next()
+				return
+			}
+		}
+
+		utils.RespondError(c, http.StatusForbidden, "Insufficient permissions")
+		c.Abort()
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/payloadlog"
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// teeWriter passes the response through and keeps its first limit bytes; over keeps
+// counting so the entry can say the body was too large
+type teeWriter struct {
+	gin.ResponseWriter
+	limit int
+	body  bytes.Buffer
+	over  bool
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *teeWriter) capture(data []byte) {
+	if w.over {
+		return
+	}
+	if w.body.Len()+len(data) > w.limit {
+		w.over = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+// PayloadLogMiddleware records redacted request and response payloads of paths under one of
+// prefixes in the payloadlog buffer, viewable on GET /api/admin/payloads. Route patterns in
+// exempt are never recorded. It does nothing unless the payload_log feature is on, so
+// operators enable it during an incident through /api/admin/runtime. Bodies over maxBytes
+// are noted by size but not kept. Register it after TracingMiddleware for the request ID,
+// and before CustomRecoveryMiddleware so panics are recorded with their 500.
+func PayloadLogMiddleware(maxBytes int, prefixes []string, exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if !features.Enabled(features.PayloadLog) || skip[c.FullPath()] || !hasPrefix(c.Request.URL.Path, prefixes) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		var reqData []byte
+		reqSize := 0
+		if c.Request.Body != nil {
+			// Read one byte past the limit to tell a full body from a cut one, then hand the
+			// handler the bytes read followed by the rest
+			head, _ := io.ReadAll(io.LimitReader(c.Request.Body, int64(maxBytes)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+			reqSize = len(head)
+			if len(head) <= maxBytes {
+				reqData = head
+			}
+		}
+		if c.Request.ContentLength > int64(reqSize) {
+			reqSize = int(c.Request.ContentLength)
+		}
+
+		writer := &teeWriter{ResponseWriter: c.Writer, limit: maxBytes}
+		c.Writer = writer
+		c.Next()
+		// Put the original writer back for outer middleware
+		c.Writer = writer.ResponseWriter
+
+		var respData []byte
+		if !writer.over {
+			respData = writer.body.Bytes()
+		}
+		entry := payloadlog.Entry{
+			RequestID: tracing.RequestID(c.Request.Context()),
+			Time:      start,
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Query:     payloadlog.RedactQuery(c.Request.URL.Query()),
+			Status:    writer.Status(),
+			Duration:  time.Since(start),
+			Request:   payloadlog.NewBody(c.ContentType(), reqSize, reqData),
+			Response:  payloadlog.NewBody(writer.Header().Get("Content-Type"), max(writer.Size(), 0), respData),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(uint64); ok {
+				entry.UserID = &id
+			}
+		}
+		payloadlog.Record(entry)
+	}
+}
+
+// hasPrefix reports whether path is one of prefixes or below one
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Rate limit headers, sent with every limited response so clients can pace themselves
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset" // seconds until the budget is full again
+)
+
+var rateLimitRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "ratelimit",
+	Name:      "requests_total",
+	Help:      "Requests checked against a rate limit, by policy and outcome (allowed, limited, local_fallback).",
+}, []string{"policy", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRequests)
+}
+
+// RateLimitKey names the bucket of a request within a policy
+type RateLimitKey func(c *gin.Context) string
+
+// ByClientIP gives every client address its own bucket, for routes used before signing in
+func ByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser gives every signed-in user their own bucket, whatever address they call from;
+// unauthenticated requests fall back to their address
+func ByUser(c *gin.Context) string {
+	if id, ok := c.Get("user_id"); ok {
+		return fmt.Sprint("user:", id)
+	}
+	return ByClientIP(c)
+}
+
+// RateLimitPolicy is a named budget; its rate can be changed while serving
+type RateLimitPolicy struct {
+	name string
+	key  RateLimitKey
+	rate atomic.Pointer[ratelimit.Rate]
+}
+
+// NewRateLimitPolicy creates a policy named for metrics and bucket keys
+func NewRateLimitPolicy(name string, rate ratelimit.Rate, key RateLimitKey) *RateLimitPolicy {
+	p := &RateLimitPolicy{name: name, key: key}
+	p.SetRate(rate)
+	return p
+}
+
+// SetRate changes the budget, e.g. on a configuration reload. Buckets already in use keep
+// their tokens and refill at the new rate.
+func (p *RateLimitPolicy) SetRate(rate ratelimit.Rate) {
+	p.rate.Store(&rate)
+}
+
+// Middleware takes a token per request from the caller's bucket in limiter, answering 429
+// with Retry-After once it is empty. Routes under the exempt prefixes are not counted (they
+// have a policy of their own), nor are the sub-requests of a batch, which was counted once.
+func (p *RateLimitPolicy) Middleware(limiter *ratelimit.Limiter, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate := *p.rate.Load()
+		if !rate.Enabled() || InBatch(c.Request.Context()) || exemptRoute(c.FullPath(), exempt) {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), p.name+":"+p.key(c), rate)
+		if err != nil {
+			rateLimitRequests.WithLabelValues(p.name, "local_fallback").Inc()
+			logging.FromContext(c.Request.Context()).Warn("Rate limit store unavailable, using the local bucket",
+				"policy", p.name, "error", err)
+		}
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+		c.Header(HeaderRateLimitReset, ceilSeconds(res.Reset))
+		if !res.Allowed {
+			rateLimitRequests.WithLabelValues(p.name, "limited").Inc()
+			c.Header("Retry-After", ceilSeconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, response.RenderError(c, http.StatusTooManyRequests, response.Failure{
+				Code:    response.CodeRateLimited,
+				Message: "Too many requests, please retry later",
+				Legacy:  gin.H{"type": string(utils.ErrorTypeRateLimited)},
+			}))
+			return
+		}
+		rateLimitRequests.WithLabelValues(p.name, "allowed").Inc()
+		c.Next()
+	}
+}
+
+// HeaderChallengeResponse carries a client's answer to the challenge it was given
+const HeaderChallengeResponse = "X-Challenge-Response"
+
+var challengeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "challenge",
+	Name:      "requests_total",
+	Help:      "Requests past a challenge threshold, by policy and outcome (issued, solved, rejected, unverified).",
+}, []string{"policy", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(challengeRequests)
+}
+
+// ChallengeMiddleware uses the policy as an abuse threshold rather than a limit: once the
+// caller's bucket is empty its requests must carry an answer to a challenge from ch in
+// X-Challenge-Response, and are otherwise refused with 403 and a new challenge. Answers that
+// cannot be checked, say because reCAPTCHA is unreachable, are let through, as the rate
+// limit still stands behind this.
+func (p *RateLimitPolicy) ChallengeMiddleware(limiter *ratelimit.Limiter, ch *challenge.Challenger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate := *p.rate.Load()
+		if !rate.Enabled() || !ch.Enabled() || InBatch(c.Request.Context()) {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		if res, _ := limiter.Allow(ctx, p.name+":"+p.key(c), rate); res.Allowed {
+			c.Next()
+			return
+		}
+
+		if answer := c.GetHeader(HeaderChallengeResponse); answer != "" {
+			err := ch.Verify(ctx, answer, c.ClientIP())
+			if err == nil {
+				challengeRequests.WithLabelValues(p.name, "solved").Inc()
+				c.Next()
+				return
+			}
+			if !errors.Is(err, challenge.ErrRejected) {
+				challengeRequests.WithLabelValues(p.name, "unverified").Inc()
+				logging.FromContext(ctx).Warn("Challenge answer could not be checked, letting it through", "policy", p.name, "error", err)
+				c.Next()
+				return
+			}
+			challengeRequests.WithLabelValues(p.name, "rejected").Inc()
+		}
+
+		issued, err := ch.Issue()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to issue a challenge", "policy", p.name, "error", err)
+			c.Next()
+			return
+		}
+		challengeRequests.WithLabelValues(p.name, "issued").Inc()
+		c.AbortWithStatusJSON(http.StatusForbidden, response.RenderError(c, http.StatusForbidden, response.Failure{
+			Code:    response.CodeChallenge,
+			Message: "Too many requests from this client; answer the challenge in the " + HeaderChallengeResponse + " header and retry",
+			Details: response.Meta{"challenge": issued},
+		}))
+	}
+}
+
+func exemptRoute(route string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ceilSeconds formats d in whole seconds, rounded up so a client waiting that long is not
+// refused again
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"adminbe/internal/pkg/logging"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCachedBodySize bounds request bodies hashed into cache keys
+const maxCachedBodySize = 64 << 10
+
+// cachedResponse is a complete HTTP response stored in the cache
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag"`
+	Body        []byte `json:"body"`
+}
+
+// bufferedWriter holds the response in memory so headers (ETag) can be set after the handler runs
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// ResponseCacheMiddleware caches successful responses of read-only routes for ttl.
+// Responses are keyed by method, path, query, request body, the caller's roles and the
+// negotiated response version,
+// stored under namespace so entity-changed events can drop them (see cache invalidation rules).
+// Every response carries an ETag, the handler's own when it set one, else a hash of the body,
+// and conditional requests are answered with 304.
+// POST is cached too, so only attach this to routes whose POSTs are pure lookups.
+func ResponseCacheMiddleware(store cache.Cache, namespace string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead && method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		if InBatch(c.Request.Context()) {
+			c.Next()
+			return
+		}
+
+		key, ok := responseCacheKey(c, namespace)
+		if !ok || !cache.Enabled() {
+			c.Next()
+			return
+		}
+
+		var cached cachedResponse
+		if err := store.Get(key, &cached); err == nil {
+			c.Header("X-Cache", "HIT")
+			c.Header("Vary", response.HeaderAcceptVersion)
+			writeCachedResponse(c, &cached)
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		fresh := cachedResponse{
+			Status:      buffered.status,
+			ContentType: buffered.Header().Get("Content-Type"),
+			ETag:        buffered.Header().Get("ETag"),
+			Body:        buffered.body.Bytes(),
+		}
+		if fresh.ETag == "" {
+			fresh.ETag = response.ETag(fresh.Body)
+		}
+
+		if fresh.Status == http.StatusOK {
+			if err := store.Set(key, fresh, ttl); err != nil {
+				logging.FromContext(c.Request.Context()).Warn("Failed to cache response", "path", c.Request.URL.Path, "error", err)
+			}
+		}
+
+		c.Header("X-Cache", "MISS")
+		writeCachedResponse(c, &fresh)
+	}
+}
+
+// responseCacheKey builds the cache key for the current request
+func responseCacheKey(c *gin.Context, namespace string) (string, bool) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method)
+	io.WriteString(h, "|"+c.Request.URL.Path)
+	io.WriteString(h, "|"+c.Request.URL.RawQuery)
+	io.WriteString(h, "|"+c.ContentType())
+
+	if c.Request.Body != nil && c.Request.Method == http.MethodPost {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCachedBodySize+1))
+		if err != nil || len(body) > maxCachedBodySize {
+			// Oversized or unreadable bodies are not cached; restore what we read
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+			return "", false
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write([]byte("|"))
+		h.Write(body)
+	}
+
+	io.WriteString(h, "|"+roleScope(c))
+	io.WriteString(h, "|tenant="+strconv.FormatUint(uint64(tenant.ID(c.Request.Context())), 10))
+	io.WriteString(h, "|"+response.Negotiate(c))
+	io.WriteString(h, "|lang="+i18n.Language(c.Request.Context()))
+
+	return fmt.Sprintf(cache.CacheKeyHTTPResponse, namespace, hex.EncodeToString(h.Sum(nil))), true
+}
+
+// roleScope returns a stable representation of the caller's roles
+func roleScope(c *gin.Context) string {
+	roles := GetRolesFromContext(c)
+	if len(roles) == 0 {
+		return "public"
+	}
+	sorted := append([]string(nil), roles...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// writeCachedResponse writes r to the client, answering 304 when the ETag matches
+func writeCachedResponse(c *gin.Context, r *cachedResponse) {
+	if r.ETag != "" && r.Status == http.StatusOK {
+		c.Header("ETag", r.ETag)
+		if response.ETagMatches(c.GetHeader("If-None-Match"), r.ETag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(r.Status, r.ContentType, r.Body)
+	c.Abort()
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestResponseCacheETag checks a cached route keeps the ETag its handler set, on the miss
+// and on later hits, and falls back to a hash of the body when the handler set none
+func TestResponseCacheETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(ResponseCacheMiddleware(cache.NewMemoryCache(cache.DefaultMemorySize), "test", time.Minute))
+	engine.GET("/versioned", func(c *gin.Context) {
+		c.Header("ETag", `"v7"`)
+		c.String(http.StatusOK, "versioned")
+	})
+	engine.GET("/plain", func(c *gin.Context) {
+		c.String(http.StatusOK, "plain")
+	})
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tc := range []struct {
+		path, etag string
+	}{
+		{"/versioned", `"v7"`},
+		{"/plain", response.ETag([]byte("plain"))},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			for _, want := range []string{"MISS", "HIT"} {
+				w := get(tc.path, "")
+				if got := w.Header().Get("X-Cache"); got != want {
+					t.Fatalf("X-Cache = %q, want %q", got, want)
+				}
+				if got := w.Header().Get("ETag"); got != tc.etag {
+					t.Errorf("%s: ETag = %s, want %s", want, got, tc.etag)
+				}
+			}
+			if w := get(tc.path, tc.etag); w.Code != http.StatusNotModified {
+				t.Errorf("If-None-Match %s: status = %d, want 304", tc.etag, w.Code)
+			}
+		})
+	}
+}
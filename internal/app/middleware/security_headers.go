@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersConfig is the security_headers section of the configuration: the headers
+// every response carries, and Groups overriding them for route prefixes, e.g. letting the
+// admin front-end frame the report viewer. An empty value leaves its header out.
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy suits JSON: nothing may load and nothing may frame the response
+	ContentSecurityPolicy string `yaml:"content_security_policy" env:"CONTENT_SECURITY_POLICY" default:"default-src 'none'; frame-ancestors 'none'"`
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only, to try one out
+	CSPReportOnly bool `yaml:"csp_report_only" env:"CSP_REPORT_ONLY" default:"false"`
+	// FrameOptions is DENY or SAMEORIGIN, for browsers predating frame-ancestors
+	FrameOptions              string `yaml:"frame_options" env:"X_FRAME_OPTIONS" default:"DENY"`
+	ReferrerPolicy            string `yaml:"referrer_policy" env:"REFERRER_POLICY" default:"no-referrer"`
+	PermissionsPolicy         string `yaml:"permissions_policy" env:"PERMISSIONS_POLICY" default:"camera=(), microphone=(), geolocation=(), payment=(), usb=()"`
+	CrossOriginOpenerPolicy   string `yaml:"cross_origin_opener_policy" env:"CROSS_ORIGIN_OPENER_POLICY" default:"same-origin"`
+	CrossOriginEmbedderPolicy string `yaml:"cross_origin_embedder_policy" env:"CROSS_ORIGIN_EMBEDDER_POLICY"`
+	// StrictTransportSecurity is only honoured over HTTPS
+	StrictTransportSecurity string                 `yaml:"strict_transport_security" env:"STRICT_TRANSPORT_SECURITY" default:"max-age=31536000; includeSubDomains"`
+	Groups                  []SecurityHeadersGroup `yaml:"groups"`
+}
+
+// SecurityHeadersGroup overrides headers for the paths under Prefix; the longest matching
+// prefix wins, settings left out are those of the section and "" removes a header
+type SecurityHeadersGroup struct {
+	Prefix                    string  `yaml:"prefix"`
+	ContentSecurityPolicy     *string `yaml:"content_security_policy"`
+	CSPReportOnly             *bool   `yaml:"csp_report_only"`
+	FrameOptions              *string `yaml:"frame_options"`
+	ReferrerPolicy            *string `yaml:"referrer_policy"`
+	PermissionsPolicy         *string `yaml:"permissions_policy"`
+	CrossOriginOpenerPolicy   *string `yaml:"cross_origin_opener_policy"`
+	CrossOriginEmbedderPolicy *string `yaml:"cross_origin_embedder_policy"`
+	StrictTransportSecurity   *string `yaml:"strict_transport_security"`
+}
+
+// Allowed values of the enumerated headers; "" leaves the header out
+var (
+	frameOptionsValues   = []string{"", "DENY", "SAMEORIGIN"}
+	openerPolicyValues   = []string{"", "same-origin", "same-origin-allow-popups", "noopener-allow-popups", "unsafe-none"}
+	embedderPolicyValues = []string{"", "require-corp", "credentialless", "unsafe-none"}
+	referrerPolicyValues = []string{"", "no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+		"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url"}
+)
+
+// Validate checks every header set up front, so a typo fails the start instead of being
+// ignored by browsers
+func (c *SecurityHeadersConfig) Validate() error {
+	var errs []error
+	c.FrameOptions = strings.ToUpper(c.FrameOptions)
+	if err := validateSecurityHeaders(c.headers()); err != nil {
+		errs = append(errs, fmt.Errorf("security_headers: %w", err))
+	}
+	seen := make(map[string]bool, len(c.Groups))
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		g.Prefix = strings.TrimSuffix(g.Prefix, "/")
+		if !strings.HasPrefix(g.Prefix, "/") {
+			errs = append(errs, fmt.Errorf("security_headers group %d: prefix %q must start with /", i, g.Prefix))
+			continue
+		}
+		if seen[g.Prefix] {
+			errs = append(errs, fmt.Errorf("security_headers group %s: duplicate prefix", g.Prefix))
+			continue
+		}
+		seen[g.Prefix] = true
+		if g.FrameOptions != nil {
+			upper := strings.ToUpper(*g.FrameOptions)
+			g.FrameOptions = &upper
+		}
+		if err := validateSecurityHeaders(c.groupHeaders(*g)); err != nil {
+			errs = append(errs, fmt.Errorf("security_headers group %s: %w", g.Prefix, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateSecurityHeaders(h SecurityHeadersConfig) error {
+	var errs []error
+	for _, e := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"frame_options", h.FrameOptions, frameOptionsValues},
+		{"cross_origin_opener_policy", h.CrossOriginOpenerPolicy, openerPolicyValues},
+		{"cross_origin_embedder_policy", h.CrossOriginEmbedderPolicy, embedderPolicyValues},
+	} {
+		if !slices.Contains(e.allowed, e.value) {
+			errs = append(errs, fmt.Errorf("%s %q is not one of %s", e.name, e.value, strings.Join(e.allowed[1:], ", ")))
+		}
+	}
+	// A fallback list is allowed, e.g. "no-referrer, strict-origin-when-cross-origin"
+	for _, policy := range strings.Split(h.ReferrerPolicy, ",") {
+		if policy = strings.TrimSpace(policy); !slices.Contains(referrerPolicyValues, policy) {
+			errs = append(errs, fmt.Errorf("unknown referrer_policy %q", policy))
+		}
+	}
+	for _, value := range []string{h.ContentSecurityPolicy, h.PermissionsPolicy, h.StrictTransportSecurity} {
+		if strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, errors.New("header values must be on one line"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// headers is the section's own header set
+func (c SecurityHeadersConfig) headers() SecurityHeadersConfig {
+	c.Groups = nil
+	return c
+}
+
+// groupHeaders is the section's header set with g's overrides
+func (c SecurityHeadersConfig) groupHeaders(g SecurityHeadersGroup) SecurityHeadersConfig {
+	h := c.headers()
+	for _, o := range []struct {
+		dst *string
+		src *string
+	}{
+		{&h.ContentSecurityPolicy, g.ContentSecurityPolicy},
+		{&h.FrameOptions, g.FrameOptions},
+		{&h.ReferrerPolicy, g.ReferrerPolicy},
+		{&h.PermissionsPolicy, g.PermissionsPolicy},
+		{&h.CrossOriginOpenerPolicy, g.CrossOriginOpenerPolicy},
+		{&h.CrossOriginEmbedderPolicy, g.CrossOriginEmbedderPolicy},
+		{&h.StrictTransportSecurity, g.StrictTransportSecurity},
+	} {
+		if o.src != nil {
+			*o.dst = *o.src
+		}
+	}
+	if g.CSPReportOnly != nil {
+		h.CSPReportOnly = *g.CSPReportOnly
+	}
+	return h
+}
+
+// header is one response header to set
+type header struct{ name, value string }
+
+// list renders the set as the headers to send, always with nosniff and the legacy XSS filter
+func (c SecurityHeadersConfig) list() []header {
+	list := []header{
+		{"X-Content-Type-Options", "nosniff"},
+		{"X-XSS-Protection", "1; mode=block"},
+	}
+	csp := "Content-Security-Policy"
+	if c.CSPReportOnly {
+		csp += "-Report-Only"
+	}
+	for _, h := range []header{
+		{csp, c.ContentSecurityPolicy},
+		{"X-Frame-Options", c.FrameOptions},
+		{"Referrer-Policy", c.ReferrerPolicy},
+		{"Permissions-Policy", c.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", c.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Embedder-Policy", c.CrossOriginEmbedderPolicy},
+		{"Strict-Transport-Security", c.StrictTransportSecurity},
+	} {
+		if h.value != "" {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+// SecurityHeadersMiddleware adds the security headers of the request path to responses.
+// Handlers serving pages, such as /docs, may replace the policy with their own. The config
+// must have been validated.
+func SecurityHeadersMiddleware(cfg SecurityHeadersConfig) gin.HandlerFunc {
+	base := cfg.headers().list()
+	groups := make(map[string][]header, len(cfg.Groups))
+	for _, g := range cfg.Groups {
+		groups[g.Prefix] = cfg.groupHeaders(g).list()
+	}
+
+	return func(c *gin.Context) {
+		headers, matched := base, -1
+		path := c.Request.URL.Path
+		for prefix, h := range groups {
+			if len(prefix) > matched && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+				headers, matched = h, len(prefix)
+			}
+		}
+		for _, h := range headers {
+			c.Header(h.name, h.value)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"adminbe/internal/pkg/secevents"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityMonitorMiddleware reports every 401 answered to m, which raises a security event
+// when one address collects too many. Register it globally so failed sign-ins, bad tokens
+// and bad SCIM credentials all count.
+func SecurityMonitorMiddleware(m *secevents.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			m.AuthFailure(c.Request.Context(), c.ClientIP())
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"time"
+
+	"adminbe/internal/pkg/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLOMiddleware counts every request that matched a route against the SLO objectives.
+// Register it outermost, so load shedding and timeouts count as the client saw them.
+func SLOMiddleware(evaluator *slo.Evaluator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if route := c.FullPath(); route != "" {
+			evaluator.Record(route, c.Writer.Status(), time.Since(start))
+		}
+	}
+}
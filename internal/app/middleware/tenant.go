@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenancyConfig controls multi-tenancy: which tenant a request is for, named by a header or
+// by the subdomain it was sent to. Without it every request is the default tenant's.
+type TenancyConfig struct {
+	Enabled bool `yaml:"enabled" env:"TENANCY_ENABLED" default:"false"`
+	// Header carries the tenant's slug
+	Header string `yaml:"header" env:"TENANT_HEADER" default:"X-Tenant"`
+	// BaseDomain, when set, makes <slug>.<base domain> name a tenant too; the header wins
+	BaseDomain string `yaml:"base_domain" env:"TENANT_BASE_DOMAIN"`
+}
+
+// Validate checks that tenants can be named
+func (c *TenancyConfig) Validate() error {
+	if c.Enabled && c.Header == "" {
+		return errors.New("TENANT_HEADER must be set when TENANCY_ENABLED is")
+	}
+	return nil
+}
+
+// TenantLookup resolves a tenant slug to its ID, reporting whether an active tenant has it
+type TenantLookup func(ctx context.Context, slug string) (uint, bool, error)
+
+// TenantSettingsLoader returns what a tenant overrides of the global configuration
+type TenantSettingsLoader func(ctx context.Context, id uint) (*tenant.Settings, error)
+
+// TenantMiddleware serves each request for the tenant it names (see TenancyConfig), 404 for
+// unknown or disabled ones, with the tenant's settings in its context. Requests naming none,
+// or the default tenant, are left as they are, so batch sub-requests keep the tenant of
+// their batch. Register it globally, before AuthMiddleware, which only accepts tokens the
+// tenant issued.
+func TenantMiddleware(cfg TenancyConfig, lookup TenantLookup, settings TenantSettingsLoader) gin.HandlerFunc {
+	baseDomain := strings.ToLower(strings.TrimPrefix(cfg.BaseDomain, "."))
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		slug := strings.ToLower(strings.TrimSpace(c.GetHeader(cfg.Header)))
+		if slug == "" && baseDomain != "" {
+			slug = subdomain(c.Request.Host, baseDomain)
+		}
+		if slug == "" || slug == tenant.DefaultSlug {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		id, ok, err := lookup(ctx, slug)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to resolve tenant", "tenant", slug, "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
+			c.Abort()
+			return
+		}
+		if !ok {
+			utils.RespondError(c, http.StatusNotFound, "Unknown tenant")
+			c.Abort()
+			return
+		}
+		overrides, err := settings(ctx, id)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to load tenant settings", "tenant", slug, "error", err)
+			utils.RespondError(c, http.StatusInternalServerError, "Internal server error")
+			c.Abort()
+			return
+		}
+		ctx = tenant.WithSettings(tenant.WithID(ctx, id), overrides)
+		ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("tenant_id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// subdomain returns the label host has below baseDomain, empty when it is not directly below
+func subdomain(host, baseDomain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+baseDomain)
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// RequireDefaultTenant answers 403 to requests for other tenants than the default one. It
+// guards what is the whole instance's rather than a tenant's: operations, audit logs,
+// webhooks, reference data and the tenants themselves.
+func RequireDefaultTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tenant.IsDefault(c.Request.Context()) {
+			utils.RespondError(c, http.StatusForbidden, "Only available to the default tenant")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
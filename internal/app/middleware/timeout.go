@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/response"
+	"adminbe/internal/pkg/tracing"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutBudgets assigns a response-time budget to every route
+type TimeoutBudgets struct {
+	Default time.Duration            // applies to routes without an override; 0 disables
+	Routes  map[string]time.Duration // overrides keyed by route prefix, e.g. "/api/reports"
+	// Media overrides both for requests whose Accept lists the media type, e.g. "text/csv"
+	// for lists downloaded whole
+	Media map[string]time.Duration
+}
+
+// For returns the budget of a route pattern (gin's FullPath). The longest matching prefix
+// wins, and a prefix only matches whole path segments.
+func (b TimeoutBudgets) For(path string) time.Duration {
+	budget, matched := b.Default, -1
+	for prefix, d := range b.Routes {
+		if len(prefix) > matched && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			budget, matched = d, len(prefix)
+		}
+	}
+	return budget
+}
+
+// TimeoutMiddleware cancels the request context once the route's budget is spent, so
+// queries and outbound calls made with it are abandoned. A handler that has not started
+// its response by then gets 504 with a structured error instead of whatever it was about
+// to write (usually a 500 caused by the cancellation). Responses already streaming when
+// the budget runs out are left to fail in-band.
+//
+// This must be registered before handlers run, as a global middleware, because a context
+// deadline can only be shortened further down the chain, never extended.
+func TimeoutMiddleware(budgets TimeoutBudgets) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget := budgets.For(c.FullPath())
+		for mediaType, d := range budgets.Media {
+			if response.Accepts(c.GetHeader("Accept"), mediaType) {
+				budget = d
+			}
+		}
+		if budget <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		w := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx, budget: budget, version: response.Negotiate(c),
+			problem: response.WantsProblem(c), instance: c.Request.URL.Path}
+		c.Writer = w
+		c.Next()
+
+		// The handler gave up without writing anything
+		if !w.Written() {
+			w.expired()
+		}
+	}
+}
+
+// timeoutWriter replaces the response with a 504 when the handler starts writing after
+// the deadline has passed
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	budget   time.Duration
+	version  string // response format the client negotiated
+	problem  bool   // the client accepts problem details
+	instance string // request path, the instance of a problem
+	timedOut bool
+}
+
+// expired reports whether the response must be dropped, writing the 504 the first time
+func (w *timeoutWriter) expired() bool {
+	if w.timedOut {
+		return true
+	}
+	if w.ResponseWriter.Written() || w.ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	w.timedOut = true
+
+	// Drop anything the handler prepared for its own response, e.g. a download's filename
+	h := w.ResponseWriter.Header()
+	h.Del("Content-Disposition")
+	h.Del("Content-Length")
+	h.Add("Vary", response.HeaderAcceptVersion)
+	h.Add("Vary", "Accept")
+	failure := response.Failure{
+		Code:    response.CodeTimeout,
+		Message: i18n.T(w.ctx, "Request exceeded its time budget"),
+		Details: response.Meta{"budget": w.budget.String()},
+		Legacy:  gin.H{"type": string(utils.ErrorTypeTimeout)},
+	}
+	var body []byte
+	if w.problem {
+		h.Set("Content-Type", response.ContentTypeProblem)
+		body, _ = json.Marshal(response.ProblemFor(http.StatusGatewayTimeout, w.instance, tracing.RequestID(w.ctx), failure))
+	} else {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		body, _ = json.Marshal(response.ErrorFor(w.version, tracing.RequestID(w.ctx), failure))
+	}
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	return true
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Flush() {
+	if !w.expired() {
+		w.ResponseWriter.Flush()
+	}
+}
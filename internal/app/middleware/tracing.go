@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"adminbe/internal/pkg/features"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TracingMiddleware starts a trace per request, keyed by the incoming X-Request-ID or a
+// generated one, and echoes the ID back. The request context carries the trace and a logger
+// tagged with the request ID (see logging.FromContext). Database calls made with the request
+// context add spans to the trace. Requests that ran a slow query or more than queryWarn
+// queries (a likely N+1) are logged and kept for GET /api/admin/traces; queryWarn <= 0
+// disables the count check. With the trace_all feature on, every trace is kept.
+func TracingMiddleware(queryWarn int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trace := tracing.NewTrace(c.GetHeader(tracing.HeaderRequestID))
+		c.Header(tracing.HeaderRequestID, trace.ID)
+		ctx := tracing.WithTrace(c.Request.Context(), trace)
+		logger := slog.Default().With(logging.KeyRequestID, trace.ID)
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, logger))
+
+		c.Next()
+
+		name := c.FullPath()
+		if name == "" {
+			name = c.Request.URL.Path
+		}
+		trace.Finish(c.Request.Method+" "+name, c.Writer.Status())
+
+		summary := trace.Summary()
+		tooMany := queryWarn > 0 && summary.SpanCount > queryWarn
+		if summary.SlowSpans == 0 && !tooMany {
+			if features.Enabled(features.TraceAll) {
+				tracing.Record(trace)
+			}
+			return
+		}
+
+		logger.Warn("Flagged request trace", "route", summary.Name, "status", summary.Status,
+			"duration_ms", milliseconds(summary.Duration), "queries", summary.SpanCount,
+			"slow_queries", summary.SlowSpans, "db_time_ms", milliseconds(summary.SpanTime))
+		tracing.Record(trace)
+	}
+}
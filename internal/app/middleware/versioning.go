@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"adminbe/internal/pkg/deprecation"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var deprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "http",
+	Name:      "deprecated_requests_total",
+	Help:      "Requests to deprecated routes by route template, to tell when a version can be retired.",
+}, []string{"route"})
+
+func init() {
+	metrics.Registry.MustRegister(deprecatedRequests)
+}
+
+// APIVersionMiddleware pins the response format of a versioned route group (e.g. /api/v2
+// always answers in the envelope), so Accept-Version only selects formats on the
+// unversioned routes
+func APIVersionMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.Pin(c, version)
+		c.Next()
+	}
+}
+
+// DeprecationMiddleware adds the Deprecation (RFC 9745), Sunset (RFC 8594, once a date is
+// set) and successor-version Link headers to every response of the routes registered with
+// deprecation.Register, and counts their requests by route and caller, so clients are told
+// to migrate while the routes keep working unchanged. Register it globally.
+func DeprecationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		d, ok := deprecation.Lookup(c.Request.Method, route)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		c.Header("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+		if !d.Sunset.IsZero() {
+			c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.Successor != "" {
+			c.Writer.Header().Add("Link", "<"+d.Successor+`>; rel="successor-version"`)
+		}
+		deprecatedRequests.WithLabelValues(route).Inc()
+
+		c.Next()
+
+		// After the handlers, so the caller has been authenticated
+		caller := "anonymous"
+		if id, ok := c.Get("user_id"); ok {
+			caller = fmt.Sprint(id)
+		}
+		deprecation.Record(c.Request.Method, route, caller, time.Now())
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Announcement severities, in the order banners are shown
+const (
+	AnnouncementCritical = "critical"
+	AnnouncementWarning  = "warning"
+	AnnouncementInfo     = "info"
+)
+
+// Announcement statuses, by the validity window and the current time
+const (
+	AnnouncementScheduled = "scheduled"
+	AnnouncementActive    = "active"
+	AnnouncementExpired   = "expired"
+)
+
+// Announcement represents the announcements table: a banner for the admin panel, shown from
+// StartsAt until EndsAt (open-ended when nil) to the users holding one of TargetRoles, or to
+// everyone when there are none
+type Announcement struct {
+	ID          uint64     `json:"id" db:"id"`
+	Message     string     `json:"message" db:"message"`
+	Severity    string     `json:"severity" db:"severity"`
+	StartsAt    time.Time  `json:"starts_at" db:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at" db:"ends_at"`
+	TargetRoles []string   `json:"target_roles" db:"target_roles"`
+	Status      string     `json:"status" db:"-"`
+	CreatedBy   *uint64    `json:"created_by" db:"created_by"`
+	UpdatedBy   *uint64    `json:"updated_by" db:"updated_by"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AnnouncementFilter narrows an announcement listing
+type AnnouncementFilter struct {
+	// Status selects the scheduled, active or expired announcements at Now, none for all
+	Status string
+	Now    time.Time
+	// BeforeID pages backwards: only announcements older than this one
+	BeforeID uint64
+	Limit    int
+}
+
+// AnnouncementRequest for creating an announcement, or replacing one. StartsAt defaults to
+// now; TargetRoles are role names, none for every user.
+type AnnouncementRequest struct {
+	Message     string     `json:"message" binding:"required,max=1000"`
+	Severity    string     `json:"severity" binding:"required,oneof=info warning critical"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	TargetRoles []string   `json:"target_roles,omitempty" binding:"omitempty,max=50,dive,required,max=100"`
+}
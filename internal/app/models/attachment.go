@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Attachment purposes
+const (
+	AttachmentAvatar          = "avatar"
+	AttachmentReportParameter = "report_parameter"
+	// AttachmentExport is a file the server generated: a finished export job, or the run of a
+	// report schedule
+	AttachmentExport = "export"
+)
+
+// Attachment represents the attachments table: the metadata of an uploaded file, whose bytes
+// are in storage under StorageKey. URL is a signed download link, valid until URLExpiresAt.
+type Attachment struct {
+	ID           uint64     `json:"id" db:"id"`
+	Purpose      string     `json:"purpose" db:"purpose"`
+	OwnerID      uint64     `json:"owner_id" db:"owner_id"`
+	Filename     string     `json:"filename" db:"filename"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	Size         int64      `json:"size" db:"size"`
+	SHA256       string     `json:"sha256" db:"sha256"`
+	StorageKey   string     `json:"-" db:"storage_key"`
+	ScanStatus   string     `json:"scan_status" db:"scan_status"`
+	CreatedBy    *uint64    `json:"created_by" db:"created_by"`
+	CreatedAt    *time.Time `json:"created_at" db:"created_at"`
+	URL          string     `json:"url,omitempty" db:"-"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty" db:"-"`
+}
+
+// AttachmentFilter narrows an attachment listing
+type AttachmentFilter struct {
+	// OwnerID selects the files of one user, 0 those of everyone
+	OwnerID uint64
+	Purpose string
+	// BeforeID pages backwards: only files older than this one
+	BeforeID uint64
+	Limit    int
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -17,3 +18,23 @@ type AuditLog struct {
 	UserAgent *string     `json:"user_agent" db:"user_agent"`
 	CreatedAt *time.Time  `json:"created_at" db:"created_at"`
 }
+
+// AuditIngestEntry is an audit entry a sibling service sends to POST /api/audit_logs/batch
+type AuditIngestEntry struct {
+	// UserID is the user who made the change, 0 for the service itself
+	UserID    uint64          `json:"user_id"`
+	EventType string          `json:"event_type" binding:"required,oneof=CREATE UPDATE DELETE RESTORE LOGIN LOGOUT API_ACCESS API_ERROR"`
+	TableName string          `json:"table_name" binding:"required,max=64"`
+	RecordID  uint64          `json:"record_id"`
+	OldValues json.RawMessage `json:"old_values"`
+	NewValues json.RawMessage `json:"new_values"`
+	IPAddress string          `json:"ip_address" binding:"omitempty,ip"`
+	UserAgent *string         `json:"user_agent" binding:"omitempty,max=255"`
+	// OccurredAt is when the change happened, the time it is received when absent
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// AuditIngestRequest is the body of POST /api/audit_logs/batch
+type AuditIngestRequest struct {
+	Entries []AuditIngestEntry `json:"entries" binding:"required,min=1,dive"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// Branding is how the admin panel presents the application, read before sign-in from GET
+// /api/branding; tenants override it field by field (see TenantSettings)
+type Branding struct {
+	AppName      string `yaml:"app_name" json:"app_name" env:"BRANDING_APP_NAME" default:"AdminBE"`
+	LogoURL      string `yaml:"logo_url" json:"logo_url" env:"BRANDING_LOGO_URL"`
+	PrimaryColor string `yaml:"primary_color" json:"primary_color" env:"BRANDING_PRIMARY_COLOR"`
+	SupportEmail string `yaml:"support_email" json:"support_email" env:"BRANDING_SUPPORT_EMAIL"`
+}
+
+// Branding strings tenants may override, by their json names
+const (
+	BrandingAppName      = "app_name"
+	BrandingLogoURL      = "logo_url"
+	BrandingPrimaryColor = "primary_color"
+	BrandingSupportEmail = "support_email"
+)
+
+// BrandingFields are the branding strings tenants may override
+var BrandingFields = []string{BrandingAppName, BrandingLogoURL, BrandingPrimaryColor, BrandingSupportEmail}
+
+// With returns b with the non-empty overrides, by json name, in place of its own strings
+func (b Branding) With(overrides map[string]string) Branding {
+	for field, dst := range map[string]*string{
+		BrandingAppName:      &b.AppName,
+		BrandingLogoURL:      &b.LogoURL,
+		BrandingPrimaryColor: &b.PrimaryColor,
+		BrandingSupportEmail: &b.SupportEmail,
+	} {
+		if v := overrides[field]; v != "" {
+			*dst = v
+		}
+	}
+	return b
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Outcomes of an ID in a bulk soft delete or restore
+const (
+	BulkDeleted  = "deleted"
+	BulkRestored = "restored"
+	BulkNotFound = "not_found"
+	// BulkAlreadyDeleted is an ID a bulk delete skipped, BulkNotDeleted one a bulk restore
+	// skipped because it was never deleted
+	BulkAlreadyDeleted = "already_deleted"
+	BulkNotDeleted     = "not_deleted"
+)
+
+// BulkIDsRequest is the body of the bulk-delete and bulk-restore routes
+type BulkIDsRequest struct {
+	IDs []uint64 `json:"ids" binding:"required,min=1,dive,min=1"`
+}
+
+// SoftDeleteState is when, and by whom, a row was soft deleted; DeletedAt is nil for a live row
+type SoftDeleteState struct {
+	DeletedAt *time.Time `json:"deleted_at"`
+	DeletedBy *uint64    `json:"deleted_by"`
+}
+
+// BulkOutcome is what a bulk delete or restore did with one ID. Before is the row's state
+// beforehand, which the audit log records.
+type BulkOutcome struct {
+	ID     uint64           `json:"id"`
+	Result string           `json:"result"`
+	Before *SoftDeleteState `json:"-"`
+}
+
+// BulkResult is the outcome of a bulk delete or restore, ID by ID in the order they were
+// given (duplicates dropped). Changed counts the rows deleted or restored; the others were
+// left as they were.
+type BulkResult struct {
+	Changed  int           `json:"changed"`
+	Skipped  int           `json:"skipped"`
+	At       time.Time     `json:"at"`
+	Outcomes []BulkOutcome `json:"outcomes"`
+}
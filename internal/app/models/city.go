@@ -7,3 +7,32 @@ type City struct {
 	Province  int     `json:"province" db:"city_province"`
 	CityIDNew int     `json:"city_id_new" db:"city_id_new"`
 }
+
+// CityLocation is a city with the coordinates and time zone its prayer times are computed
+// for, from data_lintang_kota_cms_new; nil while they are unknown
+type CityLocation struct {
+	ID         int      `json:"id"`
+	ProvinceID int      `json:"province_id"`
+	Name       string   `json:"name"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	// Elevation is in meters above sea level
+	Elevation *int    `json:"elevation"`
+	TimeZone  *string `json:"time_zone"`
+	// Geocoded lists the members the geocoder filled in
+	Geocoded []string `json:"geocoded,omitempty"`
+}
+
+// CityLocationRequest for adding a city, or replacing one. Latitude and longitude come
+// together; what is left out is geocoded from the city and province names, unless Geocode
+// is false.
+type CityLocationRequest struct {
+	ProvinceID int      `json:"province_id" binding:"required,min=1"`
+	Name       string   `json:"name" binding:"required,max=40"`
+	Latitude   *float64 `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude  *float64 `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	Elevation  *int     `json:"elevation,omitempty" binding:"omitempty,min=-500,max=9000"`
+	// TimeZone is hours east of UTC: 7 for WIB, 8 for WITA, 9 for WIT
+	TimeZone string `json:"time_zone" binding:"required,max=3"`
+	Geocode  *bool  `json:"geocode,omitempty"`
+}
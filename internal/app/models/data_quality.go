@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Severities of data quality checks, most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Data quality checks
+const (
+	CheckOrphanRoleMenus          = "orphan_role_menu"
+	CheckUsersWithoutRoles        = "users_without_roles"
+	CheckCitiesWithoutCoordinates = "cities_without_coordinates"
+	CheckMenusWithDanglingParent  = "menus_with_dangling_parent"
+)
+
+// DataQualityCheck represents the data_quality_checks table: a check and its last run
+type DataQualityCheck struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"-"`
+	Severity    string `json:"severity" db:"severity"`
+	// FindingCount is how many records the last run flagged; Truncated is set when it
+	// flagged more than a run keeps
+	FindingCount int        `json:"finding_count" db:"finding_count"`
+	Truncated    bool       `json:"truncated" db:"truncated"`
+	CheckedAt    *time.Time `json:"checked_at" db:"checked_at"`
+}
+
+// DataQualityFinding represents the data_quality_findings table: a record a check flagged,
+// since FirstSeenAt and still at LastSeenAt
+type DataQualityFinding struct {
+	ID          uint64     `json:"id" db:"id"`
+	Check       string     `json:"check" db:"check_name"`
+	Severity    string     `json:"severity" db:"severity"`
+	RecordKey   string     `json:"record_key" db:"record_key"`
+	Detail      string     `json:"detail" db:"detail"`
+	FirstSeenAt *time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt  *time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// DataQualityReport is the body of GET /api/admin/data-quality: every check with its last
+// run, and the findings asked for
+type DataQualityReport struct {
+	Checks   []DataQualityCheck   `json:"checks"`
+	Findings []DataQualityFinding `json:"findings"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Statuses of a database backup
+const (
+	BackupQueued    = "queued"
+	BackupRunning   = "running"
+	BackupSucceeded = "succeeded"
+	BackupFailed    = "failed"
+	// BackupExpired is a succeeded backup whose file was removed after DB_BACKUP_RETENTION
+	BackupExpired = "expired"
+)
+
+// DBBackup represents the db_backups table: a logical dump of the database made by the
+// db_backup job, by hand (CreatedBy) or on schedule. Tables and Rows count what was dumped
+// so far; Checksum is the SHA-256 of the file, SchemaVersion the migration it was taken at.
+type DBBackup struct {
+	ID            uint64     `json:"id" db:"id"`
+	Status        string     `json:"status" db:"status"`
+	Filename      *string    `json:"filename" db:"-"`
+	StorageKey    *string    `json:"-" db:"storage_key"`
+	Size          *int64     `json:"size" db:"size"`
+	Checksum      *string    `json:"checksum" db:"checksum"`
+	Tables        int        `json:"tables" db:"table_count"`
+	Rows          int64      `json:"rows" db:"row_count"`
+	SchemaVersion *int64     `json:"schema_version" db:"schema_version"`
+	Error         *string    `json:"error" db:"error"`
+	CreatedBy     *uint64    `json:"created_by" db:"created_by"`
+	CreatedAt     *time.Time `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Statuses of an export job
+const (
+	ExportJobQueued    = "queued"
+	ExportJobRunning   = "running"
+	ExportJobSucceeded = "succeeded"
+	ExportJobFailed    = "failed"
+	// ExportJobExpired is a succeeded job whose file was removed after EXPORT_JOB_RETENTION
+	ExportJobExpired = "expired"
+)
+
+// CreateExportJobRequest is the body of POST /api/jobs. Params are those of the kind, e.g.
+// {"year": "2026"} for prayer_schedules.
+type CreateExportJobRequest struct {
+	Kind   string            `json:"kind" binding:"required,max=50"`
+	Format string            `json:"format" binding:"omitempty,oneof=csv ndjson"`
+	Params map[string]string `json:"params"`
+}
+
+// ExportJob represents the export_jobs table: an export run in the background for
+// CreatedBy. Processed counts the rows written so far, out of Total once the exporter knows
+// it; File is the finished export, with a signed download URL.
+type ExportJob struct {
+	ID           uint64            `json:"id" db:"id"`
+	Kind         string            `json:"kind" db:"kind"`
+	Format       string            `json:"format" db:"format"`
+	Params       map[string]string `json:"params" db:"params"`
+	Status       string            `json:"status" db:"status"`
+	Processed    int64             `json:"processed" db:"processed"`
+	Total        *int64            `json:"total" db:"total"`
+	Percent      *float64          `json:"percent,omitempty" db:"-"`
+	AttachmentID *uint64           `json:"attachment_id" db:"attachment_id"`
+	Error        *string           `json:"error" db:"error"`
+	CreatedBy    uint64            `json:"created_by" db:"created_by"`
+	CreatedAt    *time.Time        `json:"created_at" db:"created_at"`
+	StartedAt    *time.Time        `json:"started_at" db:"started_at"`
+	HeartbeatAt  *time.Time        `json:"-" db:"heartbeat_at"`
+	FinishedAt   *time.Time        `json:"finished_at" db:"finished_at"`
+	File         *Attachment       `json:"file,omitempty" db:"-"`
+}
+
+// ExportJobFilter narrows an export job listing
+type ExportJobFilter struct {
+	// CreatedBy selects the jobs of one user, 0 those of everyone
+	CreatedBy uint64
+	// BeforeID pages backwards: only jobs older than this one
+	BeforeID uint64
+	Limit    int
+}
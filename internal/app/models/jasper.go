@@ -2,10 +2,10 @@ package models
 
 // JasperServerConfig holds JasperServer configuration
 type JasperServerConfig struct {
-	BaseURL      string `yaml:"base_url" json:"base_url"`
-	Username     string `yaml:"username" json:"username"`
-	Password     string `yaml:"password" json:"password"`
-	Organization string `yaml:"organization" json:"organization"`
+	BaseURL      string `yaml:"base_url" json:"base_url" env:"JASPER_BASE_URL" default:"http://localhost:8080/jasperserver"`
+	Username     string `yaml:"username" json:"username" env:"JASPER_USERNAME" default:"jasperadmin"`
+	Password     string `yaml:"password" json:"password" env:"JASPER_PASSWORD" default:"password"`
+	Organization string `yaml:"organization" json:"organization" env:"JASPER_ORGANIZATION"`
 }
 
 // JasperReportRequest represents a request to run a report
@@ -16,6 +16,8 @@ type JasperReportRequest struct {
 	Interactive  bool                   `json:"interactive,omitempty"`
 	Page         uint                   `json:"page,omitempty"`
 	Pages        string                 `json:"pages,omitempty"`
+	// Email also sends the report to the user who ran it, when mail is configured
+	Email bool `json:"email,omitempty"`
 }
 
 // JasperReportResponse represents the response from running a report
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"adminbe/internal/pkg/validation"
+)
+
+// Kinds of region in a reference data import, by the length of their Kemendagri code: two
+// digits for a province, four for a regency or city
+const (
+	RegionProvince = "province"
+	RegionCity     = "city"
+)
+
+// What a reference data import does with a row
+const (
+	RegionCreate    = "create"
+	RegionUpdate    = "update"
+	RegionUnchanged = "unchanged"
+	// RegionSkipped is a row of a district or village, which have no table
+	RegionSkipped = "skipped"
+	RegionInvalid = "invalid"
+)
+
+// Statuses of a reference data import
+const (
+	LocationImportApplying   = "applying"
+	LocationImportApplied    = "applied"
+	LocationImportFailed     = "failed"
+	LocationImportRolledBack = "rolled_back"
+)
+
+// Sources of a reference data import
+const (
+	LocationImportSourceAPI      = "api"
+	LocationImportSourceAdminctl = "adminctl"
+)
+
+// Region is a province (app_province) or a city (app_city) as a reference data import
+// compares and journals it. Code is its Kemendagri code, province_id_new or city_id_new;
+// a city's coordinates are its data_lintang_kota_cms_new row, when HasCoordinates.
+type Region struct {
+	ID             int      `json:"id"`
+	Code           int      `json:"code"`
+	Name           string   `json:"name"`
+	ProvinceID     int      `json:"province_id,omitempty"`
+	HasCoordinates bool     `json:"has_coordinates,omitempty"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Elevation      *int     `json:"elevation,omitempty"`
+	TimeZone       *string  `json:"time_zone,omitempty"`
+}
+
+// ValueChange is a value before and after an import
+type ValueChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// LocationImportRow is the outcome of a row of a reference data import: what it creates or
+// changes, or why it is invalid. Row is its row number in the file, the header being row 1.
+type LocationImportRow struct {
+	Row     int                              `json:"row"`
+	Code    string                           `json:"code"`
+	Kind    string                           `json:"kind,omitempty"`
+	Name    string                           `json:"name"`
+	Action  string                           `json:"action"`
+	ID      *int                             `json:"id,omitempty"`
+	Changes map[string]ValueChange           `json:"changes,omitempty"`
+	Errors  map[string]validation.FieldError `json:"errors,omitempty"`
+}
+
+// LocationImportResult is the diff of a reference data import, applied unless it is a dry
+// run or a row is invalid; ImportID then names the import to roll back
+type LocationImportResult struct {
+	ImportID  *uint64             `json:"import_id,omitempty"`
+	DryRun    bool                `json:"dry_run"`
+	Committed bool                `json:"committed"`
+	Total     int                 `json:"total"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Skipped   int                 `json:"skipped"`
+	Invalid   int                 `json:"invalid"`
+	Rows      []LocationImportRow `json:"rows"`
+}
+
+// LocationImport represents the location_imports table: a reference data import applied,
+// from a file uploaded by CreatedBy or loaded with adminctl (Source)
+type LocationImport struct {
+	ID           uint64     `json:"id" db:"id"`
+	Filename     string     `json:"filename" db:"filename"`
+	Source       string     `json:"source" db:"source"`
+	Status       string     `json:"status" db:"status"`
+	Created      int        `json:"created" db:"created"`
+	Updated      int        `json:"updated" db:"updated"`
+	CreatedBy    *uint64    `json:"created_by" db:"created_by"`
+	RolledBackBy *uint64    `json:"rolled_back_by" db:"rolled_back_by"`
+	RolledBackAt *time.Time `json:"rolled_back_at" db:"rolled_back_at"`
+	CreatedAt    *time.Time `json:"created_at" db:"created_at"`
+}
+
+// LocationImportDetail is an import with the changes it made
+type LocationImportDetail struct {
+	Import  *LocationImport        `json:"import"`
+	Changes []LocationImportChange `json:"changes"`
+}
+
+// LocationImportChange represents the location_import_changes table: a region an import
+// created (Old nil) or changed
+type LocationImportChange struct {
+	ID       uint64  `json:"id" db:"id"`
+	ImportID uint64  `json:"import_id" db:"import_id"`
+	Kind     string  `json:"kind" db:"kind"`
+	RecordID int     `json:"record_id" db:"record_id"`
+	Action   string  `json:"action" db:"action"`
+	Old      *Region `json:"old" db:"old_values"`
+	New      *Region `json:"new" db:"new_values"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// MailDelivery represents the mail_deliveries table: one email to one recipient, with the
+// outcome of its latest attempt. Statuses are those of webhook deliveries.
+type MailDelivery struct {
+	ID            uint64     `json:"id" db:"id"`
+	Template      string     `json:"template" db:"template"`
+	Recipient     string     `json:"recipient" db:"recipient"`
+	Subject       string     `json:"subject" db:"subject"`
+	UserID        *uint64    `json:"user_id" db:"user_id"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error" db:"last_error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MailDeliveryFilter narrows a mail delivery listing; zero fields match everything
+type MailDeliveryFilter struct {
+	Status    string
+	Template  string
+	Recipient string
+	// BeforeID pages backwards: only deliveries older than this one
+	BeforeID uint64
+	Limit    int
+}
@@ -26,3 +26,35 @@ type MenuNavigation struct {
 	Icon     string `json:"icon" db:"icon"`
 	Children string `json:"children" db:"children"`
 }
+
+// MenuNode is a menu item of the whole tree PUT /api/menu/bulk takes and returns. A node
+// without an ID is a new item; its children are listed in order, and its place among its
+// siblings becomes its sort order.
+type MenuNode struct {
+	ID        *uint      `json:"id"`
+	Label     string     `json:"label" binding:"required,min=1,max=100"`
+	Url       *string    `json:"url" binding:"omitempty,max=255"`
+	Icon      *string    `json:"icon" binding:"omitempty,max=100"`
+	SortOrder uint16     `json:"sort_order"`
+	Children  []MenuNode `json:"children" binding:"omitempty,dive"`
+}
+
+// MenuTreeRequest is the body of PUT /api/menu/bulk: the top-level items of the menu. Live
+// items the tree leaves out are deleted.
+type MenuTreeRequest struct {
+	Items []MenuNode `json:"items" binding:"required,dive"`
+}
+
+// MenuTreeChange is a menu item applying a tree created, changed or deleted, as it was
+// before (nil for a created one) and after (nil for a deleted one)
+type MenuTreeChange struct {
+	ID     uint
+	Before *Menu
+	After  *Menu
+}
+
+// MenuTreeResult is the tree as persisted and what applying it changed
+type MenuTreeResult struct {
+	Tree    []MenuNode
+	Changes []MenuTreeChange
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification represents the notifications table: something a user was told, unread until
+// they mark it read
+type Notification struct {
+	ID        uint64          `json:"id" db:"id"`
+	UserID    uint64          `json:"user_id" db:"user_id"`
+	Type      string          `json:"type" db:"type"`
+	Message   string          `json:"message" db:"message"`
+	Data      json.RawMessage `json:"data" db:"data"`
+	CreatedAt *time.Time      `json:"created_at" db:"created_at"`
+	ReadAt    *time.Time      `json:"read_at" db:"read_at"`
+}
+
+// NotificationFilter narrows a user's notification listing
+type NotificationFilter struct {
+	// Unread lists only the notifications not yet read
+	Unread bool
+	// BeforeID pages backwards: only notifications older than this one
+	BeforeID uint64
+	Limit    int
+}
+
+// MarkNotificationsReadRequest marks several notifications read: those listed in IDs, or
+// every one with All
+type MarkNotificationsReadRequest struct {
+	IDs []uint64 `json:"ids" binding:"max=1000"`
+	All bool     `json:"all"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// What one-time codes, and the texts carrying them, are for
+const (
+	OTPVerifyPhone   = "verify_phone"
+	OTPLogin         = "login"
+	OTPPasswordReset = "password_reset"
+)
+
+// UserPhone represents the user_phones table: the number a user receives codes on, in E.164.
+// Codes are only sent for sign-in and password resets once it is verified.
+type UserPhone struct {
+	ID         uint64     `json:"-" db:"id"`
+	UserID     uint64     `json:"user_id" db:"user_id"`
+	Phone      string     `json:"phone" db:"phone"`
+	VerifiedAt *time.Time `json:"verified_at" db:"verified_at"`
+	TwoFactor  bool       `json:"two_factor" db:"two_factor"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OTPCode represents the otp_codes table: a code sent to Phone, stored as a hash, which the
+// caller redeems together with the challenge it was handed
+type OTPCode struct {
+	ID            uint64     `db:"id"`
+	UserID        uint64     `db:"user_id"`
+	Purpose       string     `db:"purpose"`
+	Phone         string     `db:"phone"`
+	CodeHash      string     `db:"code_hash"`
+	ChallengeHash string     `db:"challenge_hash"`
+	Attempts      int        `db:"attempts"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	ConsumedAt    *time.Time `db:"consumed_at"`
+	CreatedAt     *time.Time `db:"created_at"`
+}
+
+// SMSMessage represents the sms_messages table: a text sent, with the delivery status last
+// reported by the provider. Statuses are those of package sms.
+type SMSMessage struct {
+	ID                uint64     `json:"id" db:"id"`
+	Provider          string     `json:"provider" db:"provider"`
+	ProviderMessageID *string    `json:"provider_message_id" db:"provider_message_id"`
+	Phone             string     `json:"phone" db:"phone"`
+	Purpose           string     `json:"purpose" db:"purpose"`
+	UserID            *uint64    `json:"user_id" db:"user_id"`
+	Status            string     `json:"status" db:"status"`
+	Error             *string    `json:"error" db:"error"`
+	CreatedAt         *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SMSMessageFilter narrows an SMS message listing; zero fields match everything
+type SMSMessageFilter struct {
+	Status  string
+	Purpose string
+	Phone   string
+	UserID  uint64
+	// BeforeID pages backwards: only messages older than this one
+	BeforeID uint64
+	Limit    int
+}
+
+// OTPChallenge is the answer to a request that sent a code: the challenge to redeem it with,
+// where it went, masked, and when it expires
+type OTPChallenge struct {
+	Challenge string    `json:"challenge"`
+	Phone     string    `json:"phone,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PhoneRequest for setting the caller's phone number, which sends it a verification code.
+// The current password is asked for, as the number can then reset it.
+type PhoneRequest struct {
+	Phone    string `json:"phone" binding:"required,max=30"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PhoneVerifyRequest for proving the caller received the code sent to their number, with the
+// challenge PUT /api/me/phone answered
+type PhoneVerifyRequest struct {
+	Challenge string `json:"challenge" binding:"required,max=100"`
+	Code      string `json:"code" binding:"required,max=10"`
+}
+
+// TwoFactorRequest for turning SMS codes at sign-in on or off
+type TwoFactorRequest struct {
+	Enabled  *bool  `json:"enabled" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TwoFactorLoginRequest for completing a sign-in with the code sent by /api/auth/login
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required,max=100"`
+	Code      string `json:"code" binding:"required,max=10"`
+	// Cookie asks for a cookie session, as on /api/auth/login
+	Cookie bool `json:"cookie"`
+	// NewPassword replaces an expired password, as on /api/auth/login
+	NewPassword string `json:"new_password,omitempty" binding:"omitempty,min=6"`
+}
+
+// ForgotPasswordRequest for a password reset code, sent to the verified number of the
+// account with that email or number
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required_without=Phone,omitempty,email"`
+	Phone string `json:"phone" binding:"required_without=Email,omitempty,max=30"`
+}
+
+// ResetPasswordRequest for setting a new password with a code from /api/auth/password/forgot
+type ResetPasswordRequest struct {
+	Challenge string `json:"challenge" binding:"required,max=100"`
+	Code      string `json:"code" binding:"required,max=10"`
+	Password  string `json:"password" binding:"required,min=6"`
+}
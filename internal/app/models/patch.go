@@ -0,0 +1,176 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"unicode/utf8"
+
+	"adminbe/internal/pkg/validation"
+)
+
+// Patch is a member of a JSON Merge Patch (RFC 7386) body. Set reports whether the member
+// was present and Null whether it was null, which is how a patch clears a nullable column;
+// the *T fields of the PUT requests cannot tell null from absent.
+type Patch[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON records the member as present; encoding/json only calls it for members
+// that are in the body
+func (p *Patch[T]) UnmarshalJSON(data []byte) error {
+	p.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		p.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &p.Value)
+}
+
+// nullable returns the column value for a nullable field: nil for null
+func (p Patch[T]) nullable() interface{} {
+	if p.Null {
+		return nil
+	}
+	return p.Value
+}
+
+// PatchMenuRequest for PATCH /api/menu/:id; null clears url, icon or parent_id
+type PatchMenuRequest struct {
+	Label     Patch[string] `json:"label"`
+	Url       Patch[string] `json:"url"`
+	Icon      Patch[string] `json:"icon"`
+	ParentID  Patch[uint]   `json:"parent_id"`
+	SortOrder Patch[uint16] `json:"sort_order"`
+}
+
+// Changes validates the patch and returns the columns it sets, nil meaning NULL
+func (r PatchMenuRequest) Changes() (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+	if r.Label.Set {
+		if err := checkLength("label", r.Label, 1, 100); err != nil {
+			return nil, err
+		}
+		changes["label"] = r.Label.Value
+	}
+	if r.Url.Set {
+		changes["url"] = r.Url.nullable()
+	}
+	if r.Icon.Set {
+		changes["icon"] = r.Icon.nullable()
+	}
+	if r.ParentID.Set {
+		changes["parent_id"] = r.ParentID.nullable()
+	}
+	if r.SortOrder.Set {
+		if r.SortOrder.Null {
+			return nil, fmt.Errorf("sort_order cannot be null")
+		}
+		changes["sort_order"] = r.SortOrder.Value
+	}
+	return changes, requireChanges(len(changes))
+}
+
+// PatchRoleRequest for PATCH /api/roles/:id; null clears description
+type PatchRoleRequest struct {
+	Name        Patch[string] `json:"name"`
+	Description Patch[string] `json:"description"`
+}
+
+// Changes validates the patch and returns the columns it sets, nil meaning NULL
+func (r PatchRoleRequest) Changes() (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+	if r.Name.Set {
+		if err := checkLength("name", r.Name, 1, 100); err != nil {
+			return nil, err
+		}
+		changes["name"] = r.Name.Value
+	}
+	if r.Description.Set {
+		changes["description"] = r.Description.nullable()
+	}
+	return changes, requireChanges(len(changes))
+}
+
+// PatchUserRequest for PATCH /api/users/:id. Users have no nullable fields, so null is
+// rejected.
+type PatchUserRequest struct {
+	Username Patch[string] `json:"username"`
+	Email    Patch[string] `json:"email"`
+	Password Patch[string] `json:"password"`
+	Status   Patch[uint8]  `json:"status"`
+}
+
+// UpdateRequest validates the patch and returns it as an update of only its members
+func (r PatchUserRequest) UpdateRequest() (UpdateUserRequest, error) {
+	var req UpdateUserRequest
+	n := 0
+	if r.Username.Set {
+		if err := checkLength("username", r.Username, 3, 100); err != nil {
+			return req, err
+		}
+		if !validation.IsUsername(r.Username.Value) {
+			return req, fmt.Errorf("username may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit")
+		}
+		req.Username = r.Username.Value
+		n++
+	}
+	if r.Email.Set {
+		if r.Email.Null {
+			return req, fmt.Errorf("email cannot be null")
+		}
+		if addr, err := mail.ParseAddress(r.Email.Value); err != nil || addr.Address != r.Email.Value {
+			return req, fmt.Errorf("email must be a valid email address")
+		}
+		req.Email = r.Email.Value
+		n++
+	}
+	if r.Password.Set {
+		if err := checkLength("password", r.Password, 6, 0); err != nil {
+			return req, err
+		}
+		req.Password = r.Password.Value
+		n++
+	}
+	if r.Status.Set {
+		if r.Status.Null {
+			return req, fmt.Errorf("status cannot be null")
+		}
+		status := r.Status.Value
+		req.Status = &status
+		n++
+	}
+	return req, requireChanges(n)
+}
+
+// checkLength rejects null and strings outside min..max characters (max 0: unbounded)
+func checkLength(name string, p Patch[string], min, max int) error {
+	if p.Null {
+		return fmt.Errorf("%s cannot be null", name)
+	}
+	n := utf8.RuneCountInString(p.Value)
+	if n < min || (max > 0 && n > max) {
+		if max > 0 {
+			return fmt.Errorf("%s must be %d to %d characters", name, min, max)
+		}
+		return fmt.Errorf("%s must be at least %d characters", name, min)
+	}
+	return nil
+}
+
+// requireChanges rejects an empty patch
+func requireChanges(n int) error {
+	if n == 0 {
+		return fmt.Errorf("No fields to update")
+	}
+	return nil
+}
+
+// SchemaType is the JSON type of the member, for the API specification
+func (Patch[T]) SchemaType() any {
+	var v T
+	return v
+}
@@ -0,0 +1,60 @@
+package models
+
+// Prayer time calculation methods, chosen by PRAYER_METHOD and by tenant settings: the
+// Kemenag RI criteria, Muslim World League, ISNA, the Egyptian General Authority of Survey
+// and Umm al-Qura
+const (
+	PrayerMethodKemenag   = "kemenag"
+	PrayerMethodMWL       = "mwl"
+	PrayerMethodISNA      = "isna"
+	PrayerMethodEgypt     = "egypt"
+	PrayerMethodUmmAlQura = "umm_al_qura"
+)
+
+// PrayerMethods are the calculation methods a schedule can be computed with
+var PrayerMethods = []string{PrayerMethodKemenag, PrayerMethodMWL, PrayerMethodISNA, PrayerMethodEgypt, PrayerMethodUmmAlQura}
+
+// PrayerLocation is a province or city/regency in /api/v2, identified by an opaque code
+type PrayerLocation struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// PrayerDay holds the prayer times of one date (YYYY-MM-DD)
+type PrayerDay struct {
+	Date    string `json:"date"`
+	Imsak   string `json:"imsak"`
+	Subuh   string `json:"subuh"`
+	Terbit  string `json:"terbit"`
+	Dhuha   string `json:"dhuha"`
+	Dzuhur  string `json:"dzuhur"`
+	Ashar   string `json:"ashar"`
+	Maghrib string `json:"maghrib"`
+	Isya    string `json:"isya"`
+}
+
+// PrayerTimeNames name the times of a PrayerDay, by their json names, in English; /api/v2
+// answers them in the request's language as labels
+var PrayerTimeNames = map[string]string{
+	"imsak":   "Imsak",
+	"subuh":   "Fajr",
+	"terbit":  "Sunrise",
+	"dhuha":   "Duha",
+	"dzuhur":  "Dhuhr",
+	"ashar":   "Asr",
+	"maghrib": "Maghrib",
+	"isya":    "Isha",
+}
+
+// PrayerScheduleResponse is every /api/v2 prayer schedule, whether for one day, a month, a
+// year or the fasting period. HijriYear is set for the fasting period only; Method is the
+// calculation method the times were computed with, and Labels name the times of each day
+// in the request's language.
+type PrayerScheduleResponse struct {
+	Province  PrayerLocation    `json:"province"`
+	City      PrayerLocation    `json:"city"`
+	HijriYear string            `json:"hijri_year,omitempty"`
+	Method    string            `json:"method"`
+	Labels    map[string]string `json:"labels"`
+	Days      []PrayerDay       `json:"days"`
+}
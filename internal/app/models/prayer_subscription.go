@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Prayer subscription kinds
+const (
+	// SubscriptionDaily is sent the day's prayer times every morning
+	SubscriptionDaily = "daily"
+	// SubscriptionImsakiyah is sent the imsak reminder on every day of the fasting period
+	SubscriptionImsakiyah = "imsakiyah"
+)
+
+// PrayerSubscription represents the prayer_subscriptions table: a chat on a messaging
+// channel that is sent the prayer times of one city. Province and City are the /api/v2
+// location codes of the stored IDs.
+type PrayerSubscription struct {
+	ID         uint64     `json:"id" db:"id"`
+	Channel    string     `json:"channel" db:"channel"`
+	ChatID     string     `json:"chat_id" db:"chat_id"`
+	Kind       string     `json:"kind" db:"kind"`
+	ProvinceID int        `json:"-" db:"province_id"`
+	CityID     int        `json:"-" db:"city_id"`
+	Province   string     `json:"province" db:"-"`
+	City       string     `json:"city" db:"-"`
+	Active     bool       `json:"active" db:"active"`
+	LastSentAt *time.Time `json:"last_sent_at" db:"last_sent_at"`
+	LastError  *string    `json:"last_error" db:"last_error"`
+	CreatedBy  *uint64    `json:"created_by" db:"created_by"`
+	CreatedAt  *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PrayerSubscriptionFilter narrows a subscription listing; zero fields match everything
+type PrayerSubscriptionFilter struct {
+	Channel string
+	ChatID  string
+	Kind    string
+	Active  *bool
+	// AfterID pages forwards: only subscriptions newer than this one
+	AfterID uint64
+	Limit   int
+}
+
+// CreatePrayerSubscriptionRequest for subscribing a chat. Province and city are /api/v2
+// location codes; a Telegram chat ID is the numeric ID or @channelname, a WhatsApp one the
+// phone number in international form.
+type CreatePrayerSubscriptionRequest struct {
+	Channel  string `json:"channel" binding:"required,oneof=telegram whatsapp"`
+	ChatID   string `json:"chat_id" binding:"required,max=64"`
+	Kind     string `json:"kind" binding:"required,oneof=daily imsakiyah"`
+	Province string `json:"province" binding:"required"`
+	City     string `json:"city" binding:"required"`
+	Active   *bool  `json:"active,omitempty"`
+}
+
+// UpdatePrayerSubscriptionRequest for updating a subscription; the location changes with
+// province and city given together
+type UpdatePrayerSubscriptionRequest struct {
+	Kind     *string `json:"kind,omitempty" binding:"omitempty,oneof=daily imsakiyah"`
+	Province *string `json:"province,omitempty"`
+	City     *string `json:"city,omitempty"`
+	Active   *bool   `json:"active,omitempty"`
+}
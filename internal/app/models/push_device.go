@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// PushDevice represents the push_devices table: a mobile app installation that is sent the
+// prayer reminders and announcements of one city. Province and City are the /api/v2 location
+// codes of the stored IDs; the token is only shown shortened.
+type PushDevice struct {
+	ID             uint64     `json:"id" db:"id"`
+	Token          string     `json:"-" db:"token"`
+	TokenHint      string     `json:"token" db:"-"`
+	Platform       string     `json:"platform" db:"platform"`
+	ProvinceID     int        `json:"-" db:"province_id"`
+	CityID         int        `json:"-" db:"city_id"`
+	Province       string     `json:"province" db:"-"`
+	City           string     `json:"city" db:"-"`
+	Reminders      bool       `json:"reminders" db:"reminders"`
+	Announcements  bool       `json:"announcements" db:"announcements"`
+	UserID         *uint64    `json:"user_id" db:"user_id"`
+	LastReminderAt *time.Time `json:"last_reminder_at" db:"last_reminder_at"`
+	CreatedAt      *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PushDeviceFilter narrows a device listing; zero fields match everything
+type PushDeviceFilter struct {
+	Platform string
+	// CityIDs are the cities of the devices, none for every city
+	CityIDs []int
+	// Reminders and Announcements select the devices with that kind of message on
+	Reminders     bool
+	Announcements bool
+	// RemindedBefore selects the devices not yet reminded of a prayer at or after it
+	RemindedBefore *time.Time
+	// AfterID pages forwards: only devices newer than this one
+	AfterID uint64
+	Limit   int
+}
+
+// RegisterPushDeviceRequest for registering an app installation, or changing the city or
+// messages of one already registered. Province and city are /api/v2 location codes.
+type RegisterPushDeviceRequest struct {
+	Token         string `json:"token" binding:"required,max=512"`
+	Platform      string `json:"platform" binding:"required,oneof=android ios web"`
+	Province      string `json:"province" binding:"required"`
+	City          string `json:"city" binding:"required"`
+	Reminders     *bool  `json:"reminders,omitempty"`
+	Announcements *bool  `json:"announcements,omitempty"`
+}
+
+// UnregisterPushDeviceRequest for removing an app installation, e.g. on sign-out
+type UnregisterPushDeviceRequest struct {
+	Token string `json:"token" binding:"required,max=512"`
+}
+
+// PushAnnouncementRequest for an announcement to the devices of the listed cities, /api/v2
+// location codes, or of every city when there are none
+type PushAnnouncementRequest struct {
+	Title  string            `json:"title" binding:"required,max=100"`
+	Body   string            `json:"body" binding:"required,max=1000"`
+	Cities []string          `json:"cities,omitempty" binding:"omitempty,max=1000"`
+	Data   map[string]string `json:"data,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// RBACBundleVersion is the version of the RBAC bundle format this build writes and reads
+const RBACBundleVersion = 1
+
+// What an RBAC import does with an item of the bundle, or with a link prune removes
+const (
+	RBACCreate    = "create"
+	RBACUpdate    = "update"
+	RBACRestore   = "restore"
+	RBACUnchanged = "unchanged"
+	RBACRemove    = "remove"
+)
+
+// RBACBundle is the RBAC configuration of an environment: its roles, their inheritances,
+// the menu and which roles see which menu items. Everything is named rather than numbered,
+// roles by name and menu items by the labels from the top of the menu down to them, so a
+// bundle applies to a database whose IDs differ.
+type RBACBundle struct {
+	Version      int               `json:"version" binding:"required"`
+	ExportedAt   *time.Time        `json:"exported_at,omitempty"`
+	Roles        []RBACRole        `json:"roles" binding:"dive"`
+	Inheritances []RBACInheritance `json:"inheritances" binding:"dive"`
+	Menus        []RBACMenu        `json:"menus" binding:"dive"`
+	RoleMenus    []RBACRoleMenu    `json:"role_menus" binding:"dive"`
+}
+
+// RBACRole is a role of a bundle
+type RBACRole struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+}
+
+// RBACInheritance is a role of a bundle inheriting the menus of Parent
+type RBACInheritance struct {
+	Role   string `json:"role" binding:"required,max=100"`
+	Parent string `json:"parent" binding:"required,max=100"`
+}
+
+// RBACMenu is a menu item of a bundle. Path is the labels of its parents, top first, then
+// its own; a bundle lists parents before their children.
+type RBACMenu struct {
+	Path      []string `json:"path" binding:"required,min=1,dive,required,max=100"`
+	URL       *string  `json:"url" binding:"omitempty,max=255"`
+	Icon      *string  `json:"icon" binding:"omitempty,max=100"`
+	SortOrder uint16   `json:"sort_order"`
+}
+
+// RBACRoleMenu is a role of a bundle seeing the menu item at Menu, a path of labels
+type RBACRoleMenu struct {
+	Role string   `json:"role" binding:"required,max=100"`
+	Menu []string `json:"menu" binding:"required,min=1,dive,required,max=100"`
+}
+
+// RBACChange is what an import does with an item of a bundle: Key is a role's name, an
+// inheritance as "role < parent", a menu item's path joined by " > ", or a role-menu link as
+// "role: path". Changes lists the values an update changes.
+type RBACChange struct {
+	Entity  string                 `json:"entity"`
+	Key     string                 `json:"key"`
+	Action  string                 `json:"action"`
+	ID      *uint64                `json:"id,omitempty"`
+	Changes map[string]ValueChange `json:"changes,omitempty"`
+}
+
+// RBACImportResult is the diff of an RBAC import, applied in one transaction unless it is a
+// dry run. Unchanged items are counted but not listed.
+type RBACImportResult struct {
+	DryRun    bool         `json:"dry_run"`
+	Prune     bool         `json:"prune"`
+	Committed bool         `json:"committed"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Restored  int          `json:"restored"`
+	Removed   int          `json:"removed"`
+	Unchanged int          `json:"unchanged"`
+	Changes   []RBACChange `json:"changes"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Outcomes of a report schedule's last run
+const (
+	ReportRunSucceeded = "succeeded"
+	ReportRunFailed    = "failed"
+)
+
+// ReportScheduleRequest is the body of POST /api/reports/schedules and of PUT on one.
+// Schedule takes the syntax of the background jobs: five cron fields in server local time,
+// a descriptor such as @daily, or "@every 6h".
+type ReportScheduleRequest struct {
+	Name         string                 `json:"name" binding:"required,max=100"`
+	ReportPath   string                 `json:"report_path" binding:"required,max=255"`
+	OutputFormat string                 `json:"output_format" binding:"required,oneof=pdf html excel pptx rtf docx xlsx xls png"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	Schedule     string                 `json:"schedule" binding:"required,max=100"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// ReportSchedule represents the report_schedules table: a report the report_schedules job
+// runs for OwnerID on Schedule, within the owner's tenant, keeping each rendered file as
+// one of their attachments. NextRunAt is nil while the schedule is disabled.
+type ReportSchedule struct {
+	ID               uint64                 `json:"id" db:"id"`
+	TenantID         uint                   `json:"-" db:"tenant_id"`
+	OwnerID          uint64                 `json:"owner_id" db:"owner_id"`
+	Name             string                 `json:"name" db:"name"`
+	ReportPath       string                 `json:"report_path" db:"report_path"`
+	OutputFormat     string                 `json:"output_format" db:"output_format"`
+	Parameters       map[string]interface{} `json:"parameters" db:"parameters"`
+	Schedule         string                 `json:"schedule" db:"schedule"`
+	Enabled          bool                   `json:"enabled" db:"enabled"`
+	NextRunAt        *time.Time             `json:"next_run_at" db:"next_run_at"`
+	LastRunAt        *time.Time             `json:"last_run_at" db:"last_run_at"`
+	LastStatus       *string                `json:"last_status" db:"last_status"`
+	LastError        *string                `json:"last_error" db:"last_error"`
+	LastAttachmentID *uint64                `json:"last_attachment_id" db:"last_attachment_id"`
+	CreatedAt        *time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt        *time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+// ReportScheduleFilter narrows a report schedule listing
+type ReportScheduleFilter struct {
+	// OwnerID selects the schedules of one user, 0 those of everyone in the tenant
+	OwnerID uint64
+	// BeforeID pages backwards: only schedules older than this one
+	BeforeID uint64
+	Limit    int
+}
+
+// ReportScheduleEvent represents the report_schedule_events table: the calendar event the
+// calendar_sync job published for one run of a schedule. Digest is a hash of what was
+// published, so an unchanged event is not written again.
+type ReportScheduleEvent struct {
+	ScheduleID uint64     `db:"schedule_id"`
+	RunAt      time.Time  `db:"run_at"`
+	Provider   string     `db:"provider"`
+	EventID    string     `db:"event_id"`
+	Digest     string     `db:"digest"`
+	SyncedAt   *time.Time `db:"synced_at"`
+}
+
+// CalendarCredential represents the calendar_credentials table: the OAuth tokens of the
+// Google account report schedules are published with, decrypted
+type CalendarCredential struct {
+	ID           uint       `db:"id"`
+	Provider     string     `db:"provider"`
+	AccessToken  string     `db:"access_token"`
+	RefreshToken string     `db:"refresh_token"`
+	ExpiresAt    *time.Time `db:"expires_at"`
+	Scope        string     `db:"scope"`
+	ConnectedBy  *uint64    `db:"connected_by"`
+	CreatedAt    *time.Time `db:"created_at"`
+	UpdatedAt    *time.Time `db:"updated_at"`
+}
+
+// CalendarStatus is the answer of GET /api/admin/calendar: the provider publishing report
+// schedules, whether it can write (for Google, whether an account is connected), and the
+// last sync
+type CalendarStatus struct {
+	Provider    string     `json:"provider"`
+	Connected   bool       `json:"connected"`
+	ConnectedBy *uint64    `json:"connected_by,omitempty"`
+	ConnectedAt *time.Time `json:"connected_at,omitempty"`
+	Scope       string     `json:"scope,omitempty"`
+	CalendarID  string     `json:"calendar_id,omitempty"`
+	// Events counts the runs currently published
+	Events int64 `json:"events"`
+	// LastSyncedAt is when an event was last written
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+}
+
+// CalendarAuthorization is the answer of GET /api/admin/calendar/authorize: the Google
+// consent page to send the administrator to, good until ExpiresAt
+type CalendarAuthorization struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SecurityEvent represents the security_events table: suspicious activity raised by the
+// detectors, open until an administrator acknowledges it
+type SecurityEvent struct {
+	ID                  uint64          `json:"id" db:"id"`
+	Type                string          `json:"type" db:"type"`
+	Severity            string          `json:"severity" db:"severity"`
+	Message             string          `json:"message" db:"message"`
+	IPAddress           *string         `json:"ip_address" db:"ip_address"`
+	UserID              *uint64         `json:"user_id" db:"user_id"`
+	Details             json.RawMessage `json:"details" db:"details"`
+	CreatedAt           *time.Time      `json:"created_at" db:"created_at"`
+	AcknowledgedAt      *time.Time      `json:"acknowledged_at" db:"acknowledged_at"`
+	AcknowledgedBy      *uint64         `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgementNote *string         `json:"acknowledgement_note" db:"acknowledgement_note"`
+}
+
+// SecurityEventFilter narrows a security event listing; zero fields match everything
+type SecurityEventFilter struct {
+	Type     string
+	Severity string
+	// Acknowledged lists only acknowledged events when true, only open ones when false
+	Acknowledged *bool
+	// BeforeID pages backwards: only events older than this one
+	BeforeID uint64
+	Limit    int
+}
+
+// AcknowledgeSecurityEventRequest closes a security event
+type AcknowledgeSecurityEventRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Tenant statuses; requests naming a disabled tenant are answered 404
+const (
+	TenantDisabled uint8 = 0
+	TenantActive   uint8 = 1
+)
+
+// Tenant represents the tenants table: an organization served by this deployment, named in
+// requests by its slug. Its users, roles and menu are its own.
+type Tenant struct {
+	ID        uint       `json:"id" db:"id"`
+	Slug      string     `json:"slug" db:"slug"`
+	Name      string     `json:"name" db:"name"`
+	Status    uint8      `json:"status" db:"status"`
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateTenantRequest for creating a tenant together with its first administrator, who is
+// given the tenant's admin role
+type CreateTenantRequest struct {
+	Slug          string `json:"slug" binding:"required,min=2,max=63"`
+	Name          string `json:"name" binding:"required,max=100"`
+	AdminUsername string `json:"admin_username" binding:"required,min=3,max=100,username"`
+	AdminEmail    string `json:"admin_email" binding:"required,email"`
+	AdminPassword string `json:"admin_password" binding:"required,min=6"`
+}
+
+// UpdateTenantRequest for renaming a tenant or disabling it; the slug cannot change
+type UpdateTenantRequest struct {
+	Name   *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Status *uint8  `json:"status,omitempty" binding:"omitempty,oneof=0 1"`
+}
+
+// CreatedTenant is a new tenant with its administrator
+type CreatedTenant struct {
+	Tenant
+	AdminUserID uint64 `json:"admin_user_id"`
+	AdminRoleID uint   `json:"admin_role_id"`
+}
+
+// TenantSettings are what a tenant overrides of the global configuration; a setting left
+// out keeps the global value. PUT replaces them all.
+type TenantSettings struct {
+	// PrayerMethod replaces PRAYER_METHOD
+	PrayerMethod string `json:"prayer_method,omitempty"`
+	// JasperOrganization replaces JASPER_ORGANIZATION for the tenant's reports
+	JasperOrganization string `json:"jasper_organization,omitempty"`
+	// Branding replaces BRANDING_* strings by their json names (see BrandingFields)
+	Branding map[string]string `json:"branding,omitempty"`
+	// CacheTTLs are durations ("10m") replacing CACHE_TTL_* for the tenant's cached data,
+	// by class ("list") or entity and class ("users:list")
+	CacheTTLs map[string]string `json:"cache_ttls,omitempty"`
+}
@@ -15,20 +15,38 @@ type User struct {
 	UpdatedAt    *time.Time `json:"updated_at" db:"updated_at"`
 	DeletedAt    *time.Time `json:"deleted_at" db:"deleted_at"`
 	DeletedBy    *uint64    `json:"deleted_by" db:"deleted_by"`
+	// PasswordResetRequired and LastLoginAt are read at sign-in only, and left out of the
+	// user's JSON
+	PasswordResetRequired bool       `json:"-" db:"password_reset_required"`
+	LastLoginAt           *time.Time `json:"-" db:"last_login_at"`
 }
 
 // CreateUserRequest for creating a new user
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=100"`
+	Username string `json:"username" binding:"required,min=3,max=100,username"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	Status   *uint8 `json:"status,omitempty"`
+	RoleIDs  []uint `json:"role_ids,omitempty"` // assigned in the same transaction as the user
 }
 
 // UpdateUserRequest for updating an existing user
 type UpdateUserRequest struct {
-	Username string `json:"username,omitempty" binding:"min=3,max=100"`
+	Username string `json:"username,omitempty" binding:"min=3,max=100,username"`
 	Email    string `json:"email,omitempty" binding:"email"`
 	Password string `json:"password,omitempty" binding:"min=6"`
 	Status   *uint8 `json:"status,omitempty"`
 }
+
+// ForcePasswordResetRequest selects the active users POST /api/users/force-password-reset
+// makes change their password at their next sign-in. The filters add up; All must be set to
+// select everyone.
+type ForcePasswordResetRequest struct {
+	// RoleID selects the holders of the role
+	RoleID *uint `json:"role_id,omitempty" binding:"omitempty,min=1"`
+	// LastLoginBefore, a date, selects those who have not signed in since, or ever
+	LastLoginBefore string `json:"last_login_before,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	All             bool   `json:"all,omitempty"`
+	// Notify emails each user selected (default true)
+	Notify *bool `json:"notify,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "adminbe/internal/pkg/validation"
+
+// Results of a row of an import
+const (
+	ImportRowValid   = "valid"
+	ImportRowInvalid = "invalid"
+	ImportRowCreated = "created"
+)
+
+// UserImportRecord is a row of a user import as read from the file. Password may be left
+// empty, giving the user a random one nobody knows until it is reset.
+type UserImportRecord struct {
+	Username string   `json:"username" binding:"required,min=3,max=100,username"`
+	Email    string   `json:"email" binding:"required,email,max=191"`
+	Password string   `json:"password" binding:"omitempty,min=6"`
+	Status   *uint8   `json:"status" binding:"omitempty,oneof=0 1"`
+	Roles    []string `json:"roles" binding:"dive,max=100"`
+}
+
+// UserImportRow is the outcome of a row of a user import. Row is its row number in the file,
+// the header being row 1; Errors are keyed by column.
+type UserImportRow struct {
+	Row      int                              `json:"row"`
+	Username string                           `json:"username"`
+	Email    string                           `json:"email"`
+	Roles    []string                         `json:"roles"`
+	Result   string                           `json:"result"`
+	UserID   *uint64                          `json:"user_id,omitempty"`
+	Errors   map[string]validation.FieldError `json:"errors,omitempty"`
+}
+
+// UserImportResult is the report of a user import: every row with its outcome. Nothing is
+// written unless every row is valid and it is not a dry run.
+type UserImportResult struct {
+	DryRun    bool            `json:"dry_run"`
+	Committed bool            `json:"committed"`
+	Total     int             `json:"total"`
+	Valid     int             `json:"valid"`
+	Invalid   int             `json:"invalid"`
+	Created   int             `json:"created"`
+	Rows      []UserImportRow `json:"rows"`
+}
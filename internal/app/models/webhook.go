@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook represents the webhooks table: an endpoint that is sent the entity events it
+// subscribes to
+type Webhook struct {
+	ID          uint64     `json:"id" db:"id"`
+	URL         string     `json:"url" db:"url"`
+	Secret      string     `json:"-" db:"secret"`
+	Events      []string   `json:"events" db:"events"`
+	Active      bool       `json:"active" db:"active"`
+	Description *string    `json:"description" db:"description"`
+	CreatedBy   *uint64    `json:"created_by" db:"created_by"`
+	CreatedAt   *time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at" db:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at" db:"deleted_at"`
+	DeletedBy   *uint64    `json:"deleted_by" db:"deleted_by"`
+}
+
+// CreatedWebhook is a new webhook with its signing secret, which is only shown once
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// CreateWebhookRequest for creating a webhook. Events are names such as "user.created",
+// "role.*" or "*"; a secret is generated when none is given.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`
+	Secret      string   `json:"secret,omitempty" binding:"omitempty,min=16,max=255"`
+	Events      []string `json:"events" binding:"required,min=1,dive,required"`
+	Active      *bool    `json:"active,omitempty"`
+	Description *string  `json:"description,omitempty" binding:"omitempty,max=255"`
+}
+
+// UpdateWebhookRequest for updating an existing webhook
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" binding:"omitempty,url,max=2048"`
+	Secret      *string  `json:"secret,omitempty" binding:"omitempty,min=16,max=255"`
+	Events      []string `json:"events,omitempty" binding:"omitempty,min=1,dive,required"`
+	Active      *bool    `json:"active,omitempty"`
+	Description *string  `json:"description,omitempty" binding:"omitempty,max=255"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery represents the webhook_deliveries table: one event sent to one webhook,
+// with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             uint64          `json:"id" db:"id"`
+	WebhookID      uint64          `json:"webhook_id" db:"webhook_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	Event          string          `json:"event" db:"event"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt      *time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      *time.Time      `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// AnnouncementRepository interface defines data access methods for admin panel announcements
+type AnnouncementRepository interface {
+	Create(ctx context.Context, a models.Announcement) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.Announcement, error)
+	List(ctx context.Context, filter models.AnnouncementFilter) ([]models.Announcement, error)
+	// Update replaces the message, severity, window and target roles of an announcement
+	Update(ctx context.Context, a models.Announcement) error
+	Delete(ctx context.Context, id uint64) error
+}
+
+// announcementRepository implements AnnouncementRepository
+type announcementRepository struct {
+	db *sql.DB
+}
+
+// NewAnnouncementRepository creates a new announcement repository
+func NewAnnouncementRepository(db *sql.DB) AnnouncementRepository {
+	return &announcementRepository{db: db}
+}
+
+const announcementColumns = "id, message, severity, starts_at, ends_at, target_roles, created_by, updated_by, created_at, updated_at"
+
+// scanAnnouncement reads a row of announcementColumns; target roles are stored
+// comma-separated
+func scanAnnouncement(scan func(dest ...interface{}) error) (*models.Announcement, error) {
+	var a models.Announcement
+	var roles string
+	if err := scan(&a.ID, &a.Message, &a.Severity, &a.StartsAt, &a.EndsAt, &roles,
+		&a.CreatedBy, &a.UpdatedBy, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	a.TargetRoles = []string{}
+	if roles != "" {
+		a.TargetRoles = strings.Split(roles, ",")
+	}
+	return &a, nil
+}
+
+// Create inserts a new announcement
+func (r *announcementRepository) Create(ctx context.Context, a models.Announcement) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO announcements (message, severity, starts_at, ends_at, target_roles, created_by, updated_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Message, a.Severity, a.StartsAt, a.EndsAt, strings.Join(a.TargetRoles, ","), a.CreatedBy, a.UpdatedBy, a.CreatedAt, a.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert announcement: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// GetByID retrieves an announcement by ID
+func (r *announcementRepository) GetByID(ctx context.Context, id uint64) (*models.Announcement, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+announcementColumns+`
+		FROM announcements
+		WHERE id = ?`,
+		id)
+
+	a, err := scanAnnouncement(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan announcement: %w", err)
+	}
+
+	return a, nil
+}
+
+// List retrieves the announcements matching filter, newest first
+func (r *announcementRepository) List(ctx context.Context, filter models.AnnouncementFilter) ([]models.Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `
+		FROM announcements
+		WHERE 1 = 1`
+	var args []interface{}
+	switch filter.Status {
+	case models.AnnouncementScheduled:
+		query += " AND starts_at > ?"
+		args = append(args, filter.Now)
+	case models.AnnouncementActive:
+		query += " AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)"
+		args = append(args, filter.Now, filter.Now)
+	case models.AnnouncementExpired:
+		query += " AND ends_at <= ?"
+		args = append(args, filter.Now)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query announcements: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Announcement{}
+	for rows.Next() {
+		a, err := scanAnnouncement(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		list = append(list, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating announcements: %w", err)
+	}
+
+	return list, nil
+}
+
+// Update modifies an existing announcement
+func (r *announcementRepository) Update(ctx context.Context, a models.Announcement) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE announcements
+		SET message = ?, severity = ?, starts_at = ?, ends_at = ?, target_roles = ?, updated_by = ?, updated_at = ?
+		WHERE id = ?`,
+		a.Message, a.Severity, a.StartsAt, a.EndsAt, strings.Join(a.TargetRoles, ","), a.UpdatedBy, a.UpdatedAt, a.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update announcement: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an announcement
+func (r *announcementRepository) Delete(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM announcements WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	return nil
+}
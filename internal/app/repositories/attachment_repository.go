@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// AttachmentRepository interface defines data access methods for uploaded file metadata
+type AttachmentRepository interface {
+	Create(ctx context.Context, a models.Attachment) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.Attachment, error)
+	// GetAvatar retrieves the newest avatar of userID
+	GetAvatar(ctx context.Context, userID uint64) (*models.Attachment, error)
+	List(ctx context.Context, filter models.AttachmentFilter) ([]models.Attachment, error)
+	Delete(ctx context.Context, id uint64) error
+}
+
+// attachmentRepository implements AttachmentRepository
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new attachment repository
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+const attachmentColumns = "id, purpose, owner_id, filename, content_type, size, sha256, storage_key, scan_status, created_by, created_at"
+
+func scanAttachment(scan func(dest ...interface{}) error) (*models.Attachment, error) {
+	var a models.Attachment
+	if err := scan(&a.ID, &a.Purpose, &a.OwnerID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256,
+		&a.StorageKey, &a.ScanStatus, &a.CreatedBy, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create records the metadata of a stored file
+func (r *attachmentRepository) Create(ctx context.Context, a models.Attachment) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO attachments (purpose, owner_id, filename, content_type, size, sha256, storage_key, scan_status, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Purpose, a.OwnerID, a.Filename, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.ScanStatus, a.CreatedBy, a.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert attachment: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// GetByID retrieves an attachment by ID
+func (r *attachmentRepository) GetByID(ctx context.Context, id uint64) (*models.Attachment, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE id = ?`,
+		id)
+
+	a, err := scanAttachment(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan attachment: %w", err)
+	}
+
+	return a, nil
+}
+
+// GetAvatar retrieves the avatar of a user
+func (r *attachmentRepository) GetAvatar(ctx context.Context, userID uint64) (*models.Attachment, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE owner_id = ? AND purpose = ?
+		ORDER BY id DESC LIMIT 1`,
+		userID, models.AttachmentAvatar)
+
+	a, err := scanAttachment(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan attachment: %w", err)
+	}
+
+	return a, nil
+}
+
+// List retrieves the attachments matching filter, newest first
+func (r *attachmentRepository) List(ctx context.Context, filter models.AttachmentFilter) ([]models.Attachment, error) {
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM attachments
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.OwnerID > 0 {
+		query += " AND owner_id = ?"
+		args = append(args, filter.OwnerID)
+	}
+	if filter.Purpose != "" {
+		query += " AND purpose = ?"
+		args = append(args, filter.Purpose)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		list = append(list, *a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+
+	return list, nil
+}
+
+// Delete removes the metadata of an attachment
+func (r *attachmentRepository) Delete(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM attachments WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
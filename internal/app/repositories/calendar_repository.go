@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/fieldcrypt"
+)
+
+// CalendarAccessToken and CalendarRefreshToken are the encrypted columns holding the OAuth
+// tokens of the connected calendar account; repositories read and write them in the clear
+var (
+	CalendarAccessToken  = fieldcrypt.Field{Table: "calendar_credentials", Key: "id", Column: "access_token"}
+	CalendarRefreshToken = fieldcrypt.Field{Table: "calendar_credentials", Key: "id", Column: "refresh_token"}
+)
+
+// CalendarRepository interface defines data access methods for the calendar the runs of
+// report schedules are published to: the events published, and the OAuth credentials of the
+// account they are published with
+type CalendarRepository interface {
+	// ListEvents returns every event published to provider
+	ListEvents(ctx context.Context, provider string) ([]models.ReportScheduleEvent, error)
+	InsertEvent(ctx context.Context, event models.ReportScheduleEvent) error
+	UpdateEvent(ctx context.Context, event models.ReportScheduleEvent) error
+	DeleteEvent(ctx context.Context, provider string, scheduleID uint64, runAt time.Time) error
+	// EventStats counts the events published to provider and tells when one was last written
+	EventStats(ctx context.Context, provider string) (int64, *time.Time, error)
+	// Credential returns the credentials for provider, sql.ErrNoRows when none are stored
+	Credential(ctx context.Context, provider string) (*models.CalendarCredential, error)
+	// InsertCredential stores the credentials for their provider, which has none
+	InsertCredential(ctx context.Context, credential models.CalendarCredential) error
+	// UpdateTokens stores the tokens a refresh granted
+	UpdateTokens(ctx context.Context, provider, accessToken, refreshToken string, expiresAt, now time.Time) error
+	DeleteCredential(ctx context.Context, provider string) error
+}
+
+// calendarRepository implements CalendarRepository
+type calendarRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarRepository creates a new calendar repository
+func NewCalendarRepository(db *sql.DB) CalendarRepository {
+	return &calendarRepository{db: db}
+}
+
+// ListEvents retrieves the events published to provider
+func (r *calendarRepository) ListEvents(ctx context.Context, provider string) ([]models.ReportScheduleEvent, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT schedule_id, run_at, provider, event_id, digest, synced_at
+		FROM report_schedule_events
+		WHERE provider = ?
+		ORDER BY schedule_id, run_at`,
+		provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar events: %w", err)
+	}
+	defer rows.Close()
+	events := []models.ReportScheduleEvent{}
+	for rows.Next() {
+		var e models.ReportScheduleEvent
+		if err := rows.Scan(&e.ScheduleID, &e.RunAt, &e.Provider, &e.EventID, &e.Digest, &e.SyncedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan calendar event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// InsertEvent records a newly published event
+func (r *calendarRepository) InsertEvent(ctx context.Context, e models.ReportScheduleEvent) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO report_schedule_events (schedule_id, run_at, provider, event_id, digest, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		e.ScheduleID, e.RunAt, e.Provider, e.EventID, e.Digest, e.SyncedAt); err != nil {
+		return fmt.Errorf("failed to insert calendar event: %w", err)
+	}
+	return nil
+}
+
+// UpdateEvent records an event published again
+func (r *calendarRepository) UpdateEvent(ctx context.Context, e models.ReportScheduleEvent) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE report_schedule_events
+		SET event_id = ?, digest = ?, synced_at = ?
+		WHERE provider = ? AND schedule_id = ? AND run_at = ?`,
+		e.EventID, e.Digest, e.SyncedAt, e.Provider, e.ScheduleID, e.RunAt); err != nil {
+		return fmt.Errorf("failed to update calendar event: %w", err)
+	}
+	return nil
+}
+
+// DeleteEvent forgets an event
+func (r *calendarRepository) DeleteEvent(ctx context.Context, provider string, scheduleID uint64, runAt time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM report_schedule_events
+		WHERE provider = ? AND schedule_id = ? AND run_at = ?`,
+		provider, scheduleID, runAt); err != nil {
+		return fmt.Errorf("failed to delete calendar event: %w", err)
+	}
+	return nil
+}
+
+// EventStats counts the events published to provider
+func (r *calendarRepository) EventStats(ctx context.Context, provider string) (int64, *time.Time, error) {
+	var count int64
+	var last *time.Time
+	if err := reader(ctx, r.db).QueryRowContext(ctx, `
+		SELECT COUNT(*), MAX(synced_at)
+		FROM report_schedule_events
+		WHERE provider = ?`,
+		provider).Scan(&count, &last); err != nil {
+		return 0, nil, fmt.Errorf("failed to count calendar events: %w", err)
+	}
+	return count, last, nil
+}
+
+// Credential retrieves and decrypts the credentials for provider
+func (r *calendarRepository) Credential(ctx context.Context, provider string) (*models.CalendarCredential, error) {
+	var c models.CalendarCredential
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, provider, access_token, refresh_token, expires_at, scope, connected_by, created_at, updated_at
+		FROM calendar_credentials
+		WHERE provider = ?`,
+		provider).Scan(&c.ID, &c.Provider, &c.AccessToken, &c.RefreshToken, &c.ExpiresAt, &c.Scope, &c.ConnectedBy, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan calendar credentials: %w", err)
+	}
+	if c.AccessToken, err = CalendarAccessToken.Decrypt(c.AccessToken); err != nil {
+		return nil, fmt.Errorf("calendar access token: %w", err)
+	}
+	if c.RefreshToken, err = CalendarRefreshToken.Decrypt(c.RefreshToken); err != nil {
+		return nil, fmt.Errorf("calendar refresh token: %w", err)
+	}
+	return &c, nil
+}
+
+// InsertCredential encrypts and stores credentials
+func (r *calendarRepository) InsertCredential(ctx context.Context, c models.CalendarCredential) error {
+	access, err := CalendarAccessToken.Encrypt(c.AccessToken)
+	if err != nil {
+		return err
+	}
+	refresh, err := CalendarRefreshToken.Encrypt(c.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO calendar_credentials (provider, access_token, refresh_token, expires_at, scope, connected_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Provider, access, refresh, c.ExpiresAt, c.Scope, c.ConnectedBy, c.CreatedAt, c.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to insert calendar credentials: %w", err)
+	}
+	return nil
+}
+
+// UpdateTokens encrypts and stores refreshed tokens
+func (r *calendarRepository) UpdateTokens(ctx context.Context, provider, accessToken, refreshToken string, expiresAt, now time.Time) error {
+	access, err := CalendarAccessToken.Encrypt(accessToken)
+	if err != nil {
+		return err
+	}
+	refresh, err := CalendarRefreshToken.Encrypt(refreshToken)
+	if err != nil {
+		return err
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE calendar_credentials
+		SET access_token = ?, refresh_token = ?, expires_at = ?, updated_at = ?
+		WHERE provider = ?`,
+		access, refresh, expiresAt, now, provider); err != nil {
+		return fmt.Errorf("failed to update calendar credentials: %w", err)
+	}
+	return nil
+}
+
+// DeleteCredential removes the credentials for provider
+func (r *calendarRepository) DeleteCredential(ctx context.Context, provider string) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM calendar_credentials WHERE provider = ?", provider); err != nil {
+		return fmt.Errorf("failed to delete calendar credentials: %w", err)
+	}
+	return nil
+}
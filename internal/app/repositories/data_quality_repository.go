@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+)
+
+// DataQualityRepository interface defines data access methods for the data quality checks:
+// the queries that find the records each check flags, and the findings kept between runs
+type DataQualityRepository interface {
+	// Detect runs the query of the check named check, returning at most limit of the records
+	// it flags with their record_key and detail. It reads the replica when one is configured.
+	Detect(ctx context.Context, check string, limit int) ([]models.DataQualityFinding, error)
+	// Record stores a run of check: findings replace the check's earlier ones, those found
+	// again keeping their first_seen_at, and the check's row gets the counts and checked_at
+	Record(ctx context.Context, check models.DataQualityCheck, findings []models.DataQualityFinding, now time.Time) error
+	ListChecks(ctx context.Context) ([]models.DataQualityCheck, error)
+	// ListFindings returns findings newest first, of one check and one severity unless they
+	// are empty
+	ListFindings(ctx context.Context, check, severity string, beforeID uint64, limit int) ([]models.DataQualityFinding, error)
+}
+
+// dataQualityRepository implements DataQualityRepository
+type dataQualityRepository struct {
+	db *sql.DB
+}
+
+// NewDataQualityRepository creates a new data quality repository
+func NewDataQualityRepository(db *sql.DB) DataQualityRepository {
+	return &dataQualityRepository{db: db}
+}
+
+// dataQualityQueries select the record_key and detail of every record a check flags, in a
+// stable order, with the limit as their one argument. They are written for both engines:
+// CONCAT and COALESCE are common to MySQL and PostgreSQL.
+var dataQualityQueries = map[string]string{
+	// Live links whose role or menu item was soft deleted since
+	models.CheckOrphanRoleMenus: `
+		SELECT CONCAT(rm.role_id, ':', rm.menu_id),
+			CONCAT('Role ', rm.role_id, ' still sees menu item ', rm.menu_id, ', but the ',
+				CASE WHEN r.id IS NULL OR r.deleted_at IS NOT NULL THEN 'role' ELSE 'menu item' END, ' is deleted')
+		FROM role_menu rm
+		LEFT JOIN roles r ON r.id = rm.role_id
+		LEFT JOIN menu m ON m.id = rm.menu_id
+		WHERE rm.deleted_at IS NULL
+			AND (r.id IS NULL OR r.deleted_at IS NOT NULL OR m.id IS NULL OR m.deleted_at IS NOT NULL)
+		ORDER BY rm.role_id, rm.menu_id
+		LIMIT ?`,
+	// Live users without a live role, who can sign in but reach nothing
+	models.CheckUsersWithoutRoles: `
+		SELECT CONCAT(u.id, ''), CONCAT('User ', u.username, ' has no role')
+		FROM users u
+		WHERE u.deleted_at IS NULL AND NOT EXISTS (
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id AND r.deleted_at IS NULL
+			WHERE ur.user_id = u.id AND ur.deleted_at IS NULL)
+		ORDER BY u.id
+		LIMIT ?`,
+	// Cities without a latitude and longitude, whose prayer schedules cannot be computed
+	models.CheckCitiesWithoutCoordinates: `
+		SELECT CONCAT(c.city_id, ''), CONCAT('City ', COALESCE(c.city_title, ''), ' (', c.city_id, ') has no coordinates')
+		FROM app_city c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM data_lintang_kota_cms_new dlk
+			WHERE dlk.nama_kota = c.city_id
+				AND COALESCE(TRIM(dlk.lintang_tempat), '') <> '' AND COALESCE(TRIM(dlk.bujur_tempat), '') <> '')
+		ORDER BY c.city_id
+		LIMIT ?`,
+	// Live menu items under a parent that is gone, which the navigation tree leaves out
+	models.CheckMenusWithDanglingParent: `
+		SELECT CONCAT(m.id, ''),
+			CONCAT('Menu item ', m.label, ' (', m.id, ') is under ',
+				CASE WHEN p.id IS NULL THEN 'missing' ELSE 'deleted' END, ' parent ', m.parent_id)
+		FROM menu m
+		LEFT JOIN menu p ON p.id = m.parent_id
+		WHERE m.deleted_at IS NULL AND m.parent_id IS NOT NULL AND (p.id IS NULL OR p.deleted_at IS NOT NULL)
+		ORDER BY m.id
+		LIMIT ?`,
+}
+
+// Detect runs a check's query
+func (r *dataQualityRepository) Detect(ctx context.Context, check string, limit int) ([]models.DataQualityFinding, error) {
+	query, ok := dataQualityQueries[check]
+	if !ok {
+		return nil, fmt.Errorf("unknown data quality check %q", check)
+	}
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run check %s: %w", check, err)
+	}
+	defer rows.Close()
+	var findings []models.DataQualityFinding
+	for rows.Next() {
+		f := models.DataQualityFinding{Check: check}
+		if err := rows.Scan(&f.RecordKey, &f.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan finding: %w", err)
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// Record replaces the findings of a check
+func (r *dataQualityRepository) Record(ctx context.Context, check models.DataQualityCheck, findings []models.DataQualityFinding, now time.Time) error {
+	db := conn(ctx, r.db)
+	rows, err := db.QueryContext(ctx, "SELECT id, record_key FROM data_quality_findings WHERE check_name = ? FOR UPDATE", check.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read findings: %w", err)
+	}
+	existing := map[string]uint64{}
+	for rows.Next() {
+		var id uint64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan finding: %w", err)
+		}
+		existing[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		if id, ok := existing[f.RecordKey]; ok {
+			delete(existing, f.RecordKey)
+			if _, err := db.ExecContext(ctx, "UPDATE data_quality_findings SET severity = ?, detail = ?, last_seen_at = ? WHERE id = ?",
+				check.Severity, truncateDetail(f.Detail), now, id); err != nil {
+				return fmt.Errorf("failed to update finding: %w", err)
+			}
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO data_quality_findings (check_name, severity, record_key, detail, first_seen_at, last_seen_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			check.Name, check.Severity, f.RecordKey, truncateDetail(f.Detail), now, now); err != nil {
+			return fmt.Errorf("failed to insert finding: %w", err)
+		}
+	}
+	// What is left was not found again
+	resolved := make([]uint64, 0, len(existing))
+	for _, id := range existing {
+		resolved = append(resolved, id)
+	}
+	if len(resolved) > 0 {
+		if _, err := db.ExecContext(ctx, "DELETE FROM data_quality_findings WHERE id IN "+inList(len(resolved)), uint64Args(resolved)...); err != nil {
+			return fmt.Errorf("failed to delete resolved findings: %w", err)
+		}
+	}
+
+	var found int
+	err = db.QueryRowContext(ctx, "SELECT 1 FROM data_quality_checks WHERE name = ? FOR UPDATE", check.Name).Scan(&found)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO data_quality_checks (name, severity, finding_count, truncated, checked_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			check.Name, check.Severity, check.FindingCount, check.Truncated, now)
+	case err == nil:
+		_, err = db.ExecContext(ctx, "UPDATE data_quality_checks SET severity = ?, finding_count = ?, truncated = ?, checked_at = ? WHERE name = ?",
+			check.Severity, check.FindingCount, check.Truncated, now, check.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record check: %w", err)
+	}
+	return nil
+}
+
+// ListChecks retrieves the checks that ran
+func (r *dataQualityRepository) ListChecks(ctx context.Context) ([]models.DataQualityCheck, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, "SELECT name, severity, finding_count, truncated, checked_at FROM data_quality_checks ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query checks: %w", err)
+	}
+	defer rows.Close()
+	var checks []models.DataQualityCheck
+	for rows.Next() {
+		var c models.DataQualityCheck
+		if err := rows.Scan(&c.Name, &c.Severity, &c.FindingCount, &c.Truncated, &c.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// ListFindings retrieves findings, newest first
+func (r *dataQualityRepository) ListFindings(ctx context.Context, check, severity string, beforeID uint64, limit int) ([]models.DataQualityFinding, error) {
+	query := "SELECT id, check_name, severity, record_key, detail, first_seen_at, last_seen_at FROM data_quality_findings WHERE 1 = 1"
+	var args []interface{}
+	if check != "" {
+		query += " AND check_name = ?"
+		args = append(args, check)
+	}
+	if severity != "" {
+		query += " AND severity = ?"
+		args = append(args, severity)
+	}
+	if beforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, beforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query findings: %w", err)
+	}
+	defer rows.Close()
+	findings := []models.DataQualityFinding{}
+	for rows.Next() {
+		var f models.DataQualityFinding
+		if err := rows.Scan(&f.ID, &f.Check, &f.Severity, &f.RecordKey, &f.Detail, &f.FirstSeenAt, &f.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan finding: %w", err)
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// truncateDetail fits a finding's detail in its column
+func truncateDetail(s string) string {
+	if r := []rune(s); len(r) > 255 {
+		return string(r[:254]) + "…"
+	}
+	return s
+}
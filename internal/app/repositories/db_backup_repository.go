@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// DBBackupRepository interface defines data access methods for database backups, which the
+// instances running the db_backup scheduler job claim from the queue
+type DBBackupRepository interface {
+	Create(ctx context.Context, backup models.DBBackup) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.DBBackup, error)
+	// List returns backups newest first
+	List(ctx context.Context, beforeID uint64, limit int) ([]models.DBBackup, error)
+	// Pending returns the newest queued or running backup, sql.ErrNoRows when there is none
+	Pending(ctx context.Context) (*models.DBBackup, error)
+	// Claim marks the oldest queued backup running and returns it, sql.ErrNoRows when none
+	// is queued. A backup is only ever claimed by one caller.
+	Claim(ctx context.Context, now time.Time) (*models.DBBackup, error)
+	// Progress records how many tables and rows a running backup dumped
+	Progress(ctx context.Context, id uint64, tables int, rows int64) error
+	// Finish records the outcome of a running backup, with its file when it succeeded
+	Finish(ctx context.Context, backup models.DBBackup, now time.Time) error
+	// Requeue puts a running backup back in the queue, to start over
+	Requeue(ctx context.Context, id uint64) error
+	// FailStale fails the running backups started before before, which their instance
+	// abandoned
+	FailStale(ctx context.Context, before, now time.Time) (int64, error)
+	// ListExpired returns at most limit succeeded backups finished before before
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]models.DBBackup, error)
+	// Expire marks a succeeded backup whose file was removed
+	Expire(ctx context.Context, id uint64) error
+}
+
+// dbBackupRepository implements DBBackupRepository
+type dbBackupRepository struct {
+	db *sql.DB
+}
+
+// NewDBBackupRepository creates a new database backup repository
+func NewDBBackupRepository(db *sql.DB) DBBackupRepository {
+	return &dbBackupRepository{db: db}
+}
+
+const dbBackupColumns = "id, status, storage_key, size, checksum, table_count, row_count, schema_version, error, created_by, created_at, started_at, finished_at"
+
+func scanDBBackup(scan func(dest ...interface{}) error) (*models.DBBackup, error) {
+	var b models.DBBackup
+	if err := scan(&b.ID, &b.Status, &b.StorageKey, &b.Size, &b.Checksum, &b.Tables, &b.Rows, &b.SchemaVersion,
+		&b.Error, &b.CreatedBy, &b.CreatedAt, &b.StartedAt, &b.FinishedAt); err != nil {
+		return nil, err
+	}
+	if b.StorageKey != nil {
+		filename := path.Base(*b.StorageKey)
+		b.Filename = &filename
+	}
+	return &b, nil
+}
+
+// Create inserts a new backup
+func (r *dbBackupRepository) Create(ctx context.Context, backup models.DBBackup) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO db_backups (status, created_by, created_at)
+		VALUES (?, ?, ?)`,
+		backup.Status, backup.CreatedBy, backup.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert backup: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetByID retrieves a backup by ID
+func (r *dbBackupRepository) GetByID(ctx context.Context, id uint64) (*models.DBBackup, error) {
+	backup, err := scanDBBackup(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+dbBackupColumns+" FROM db_backups WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan backup: %w", err)
+	}
+	return backup, nil
+}
+
+// List retrieves a page of backups
+func (r *dbBackupRepository) List(ctx context.Context, beforeID uint64, limit int) ([]models.DBBackup, error) {
+	query := "SELECT " + dbBackupColumns + " FROM db_backups"
+	var args []interface{}
+	if beforeID > 0 {
+		query += " WHERE id < ?"
+		args = append(args, beforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	defer rows.Close()
+	backups := []models.DBBackup{}
+	for rows.Next() {
+		backup, err := scanDBBackup(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, *backup)
+	}
+	return backups, rows.Err()
+}
+
+// Pending retrieves the newest unfinished backup
+func (r *dbBackupRepository) Pending(ctx context.Context) (*models.DBBackup, error) {
+	backup, err := scanDBBackup(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+dbBackupColumns+`
+		FROM db_backups
+		WHERE status IN (?, ?)
+		ORDER BY id DESC LIMIT 1`,
+		models.BackupQueued, models.BackupRunning).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan backup: %w", err)
+	}
+	return backup, nil
+}
+
+// Claim takes the oldest queued backup. Instances may race for it; the conditional UPDATE
+// lets one win, and the others try the next backup.
+func (r *dbBackupRepository) Claim(ctx context.Context, now time.Time) (*models.DBBackup, error) {
+	db := conn(ctx, r.db)
+	for {
+		var id uint64
+		err := db.QueryRowContext(ctx, "SELECT id FROM db_backups WHERE status = ? ORDER BY id LIMIT 1", models.BackupQueued).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the backup queue: %w", err)
+		}
+		result, err := db.ExecContext(ctx, `
+			UPDATE db_backups
+			SET status = ?, table_count = 0, row_count = 0, started_at = ?
+			WHERE id = ? AND status = ?`,
+			models.BackupRunning, now, id, models.BackupQueued)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim backup: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return r.GetByID(ctx, id)
+		}
+	}
+}
+
+// Progress updates the counts of a backup
+func (r *dbBackupRepository) Progress(ctx context.Context, id uint64, tables int, rows int64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE db_backups SET table_count = ?, row_count = ?
+		WHERE id = ? AND status = ?`,
+		tables, rows, id, models.BackupRunning); err != nil {
+		return fmt.Errorf("failed to update backup: %w", err)
+	}
+	return nil
+}
+
+// Finish records the outcome of a backup
+func (r *dbBackupRepository) Finish(ctx context.Context, backup models.DBBackup, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE db_backups
+		SET status = ?, storage_key = ?, size = ?, checksum = ?, table_count = ?, row_count = ?, schema_version = ?,
+			error = ?, finished_at = ?
+		WHERE id = ?`,
+		backup.Status, backup.StorageKey, backup.Size, backup.Checksum, backup.Tables, backup.Rows, backup.SchemaVersion,
+		backup.Error, now, backup.ID); err != nil {
+		return fmt.Errorf("failed to update backup: %w", err)
+	}
+	return nil
+}
+
+// Requeue returns a backup to the queue
+func (r *dbBackupRepository) Requeue(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE db_backups
+		SET status = ?, table_count = 0, row_count = 0, started_at = NULL
+		WHERE id = ? AND status = ?`,
+		models.BackupQueued, id, models.BackupRunning); err != nil {
+		return fmt.Errorf("failed to requeue backup: %w", err)
+	}
+	return nil
+}
+
+// FailStale fails the backups an instance abandoned
+func (r *dbBackupRepository) FailStale(ctx context.Context, before, now time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE db_backups
+		SET status = ?, error = ?, finished_at = ?
+		WHERE status = ? AND started_at < ?`,
+		models.BackupFailed, "interrupted: the instance running it stopped", now, models.BackupRunning, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail abandoned backups: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListExpired retrieves the succeeded backups past their retention, oldest first
+func (r *dbBackupRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]models.DBBackup, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+dbBackupColumns+`
+		FROM db_backups
+		WHERE status = ? AND finished_at < ?
+		ORDER BY id LIMIT ?`,
+		models.BackupSucceeded, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired backups: %w", err)
+	}
+	defer rows.Close()
+	var backups []models.DBBackup
+	for rows.Next() {
+		backup, err := scanDBBackup(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, *backup)
+	}
+	return backups, rows.Err()
+}
+
+// Expire marks a backup expired
+func (r *dbBackupRepository) Expire(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE db_backups SET status = ? WHERE id = ? AND status = ?",
+		models.BackupExpired, id, models.BackupSucceeded); err != nil {
+		return fmt.Errorf("failed to expire backup: %w", err)
+	}
+	return nil
+}
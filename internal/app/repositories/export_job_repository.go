@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// ExportJobRepository interface defines data access methods for export jobs, which the
+// instances running the export_jobs scheduler job claim from the queue
+type ExportJobRepository interface {
+	Create(ctx context.Context, job models.ExportJob) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.ExportJob, error)
+	// List returns jobs newest first
+	List(ctx context.Context, filter models.ExportJobFilter) ([]models.ExportJob, error)
+	// Claim marks the oldest queued job running and returns it, sql.ErrNoRows when none is
+	// queued. A job is only ever claimed by one caller.
+	Claim(ctx context.Context, now time.Time) (*models.ExportJob, error)
+	// Heartbeat records the progress of a running job
+	Heartbeat(ctx context.Context, id uint64, processed int64, total *int64, now time.Time) error
+	// Finish records the outcome of a running job, which wrote processed rows
+	Finish(ctx context.Context, id uint64, status string, processed int64, attachmentID *uint64, errMessage *string, now time.Time) error
+	// Requeue puts a running job back in the queue, to start over
+	Requeue(ctx context.Context, id uint64) error
+	// FailStale fails the running jobs whose last heartbeat is older than before, their
+	// instance having stopped without finishing them
+	FailStale(ctx context.Context, before, now time.Time) (int64, error)
+	// ListExpired returns at most limit succeeded jobs finished before before
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]models.ExportJob, error)
+	// Expire marks a succeeded job whose file was removed
+	Expire(ctx context.Context, id uint64) error
+}
+
+// exportJobRepository implements ExportJobRepository
+type exportJobRepository struct {
+	db *sql.DB
+}
+
+// NewExportJobRepository creates a new export job repository
+func NewExportJobRepository(db *sql.DB) ExportJobRepository {
+	return &exportJobRepository{db: db}
+}
+
+const exportJobColumns = "id, kind, format, params, status, processed, total, attachment_id, error, created_by, created_at, started_at, heartbeat_at, finished_at"
+
+func scanExportJob(scan func(dest ...interface{}) error) (*models.ExportJob, error) {
+	var job models.ExportJob
+	var params []byte
+	if err := scan(&job.ID, &job.Kind, &job.Format, &params, &job.Status, &job.Processed, &job.Total, &job.AttachmentID,
+		&job.Error, &job.CreatedBy, &job.CreatedAt, &job.StartedAt, &job.HeartbeatAt, &job.FinishedAt); err != nil {
+		return nil, err
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &job.Params); err != nil {
+			return nil, fmt.Errorf("failed to decode the params of export job %d: %w", job.ID, err)
+		}
+	}
+	return &job, nil
+}
+
+// Create inserts a new job
+func (r *exportJobRepository) Create(ctx context.Context, job models.ExportJob) (uint64, error) {
+	params, err := json.Marshal(job.Params)
+	if err != nil {
+		return 0, err
+	}
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO export_jobs (kind, format, params, status, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		job.Kind, job.Format, params, job.Status, job.CreatedBy, job.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert export job: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetByID retrieves a job by ID
+func (r *exportJobRepository) GetByID(ctx context.Context, id uint64) (*models.ExportJob, error) {
+	job, err := scanExportJob(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+exportJobColumns+`
+		FROM export_jobs
+		WHERE id = ?`,
+		id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan export job: %w", err)
+	}
+	return job, nil
+}
+
+// List retrieves a page of jobs
+func (r *exportJobRepository) List(ctx context.Context, filter models.ExportJobFilter) ([]models.ExportJob, error) {
+	query := "SELECT " + exportJobColumns + " FROM export_jobs WHERE 1 = 1"
+	var args []interface{}
+	if filter.CreatedBy > 0 {
+		query += " AND created_by = ?"
+		args = append(args, filter.CreatedBy)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list export jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []models.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// Claim takes the oldest queued job. Instances may race for it; the conditional UPDATE lets
+// one win, and the others try the next job.
+func (r *exportJobRepository) Claim(ctx context.Context, now time.Time) (*models.ExportJob, error) {
+	db := conn(ctx, r.db)
+	for {
+		var id uint64
+		err := db.QueryRowContext(ctx, "SELECT id FROM export_jobs WHERE status = ? ORDER BY id LIMIT 1", models.ExportJobQueued).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the export queue: %w", err)
+		}
+		result, err := db.ExecContext(ctx, `
+			UPDATE export_jobs
+			SET status = ?, processed = 0, total = NULL, started_at = ?, heartbeat_at = ?
+			WHERE id = ? AND status = ?`,
+			models.ExportJobRunning, now, now, id, models.ExportJobQueued)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim export job: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return r.GetByID(ctx, id)
+		}
+	}
+}
+
+// Heartbeat updates the progress of a job
+func (r *exportJobRepository) Heartbeat(ctx context.Context, id uint64, processed int64, total *int64, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE export_jobs
+		SET processed = ?, total = ?, heartbeat_at = ?
+		WHERE id = ? AND status = ?`,
+		processed, total, now, id, models.ExportJobRunning); err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+	return nil
+}
+
+// Finish records the outcome of a job
+func (r *exportJobRepository) Finish(ctx context.Context, id uint64, status string, processed int64, attachmentID *uint64, errMessage *string, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = ?, processed = ?, attachment_id = ?, error = ?, finished_at = ?, heartbeat_at = ?
+		WHERE id = ?`,
+		status, processed, attachmentID, errMessage, now, now, id); err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+	return nil
+}
+
+// Requeue returns a job to the queue
+func (r *exportJobRepository) Requeue(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = ?, processed = 0, total = NULL, started_at = NULL, heartbeat_at = NULL
+		WHERE id = ? AND status = ?`,
+		models.ExportJobQueued, id, models.ExportJobRunning); err != nil {
+		return fmt.Errorf("failed to requeue export job: %w", err)
+	}
+	return nil
+}
+
+// FailStale fails the jobs whose instance stopped sending heartbeats
+func (r *exportJobRepository) FailStale(ctx context.Context, before, now time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = ?, error = ?, finished_at = ?
+		WHERE status = ? AND heartbeat_at < ?`,
+		models.ExportJobFailed, "interrupted: the instance running it stopped", now, models.ExportJobRunning, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail stale export jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListExpired retrieves the succeeded jobs past their retention
+func (r *exportJobRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]models.ExportJob, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+exportJobColumns+`
+		FROM export_jobs
+		WHERE status = ? AND finished_at < ?
+		ORDER BY id
+		LIMIT ?`,
+		models.ExportJobSucceeded, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired export jobs: %w", err)
+	}
+	defer rows.Close()
+	jobs := []models.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export job: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// Expire marks a job's file removed
+func (r *exportJobRepository) Expire(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE export_jobs
+		SET status = ?, attachment_id = NULL
+		WHERE id = ?`,
+		models.ExportJobExpired, id); err != nil {
+		return fmt.Errorf("failed to expire export job: %w", err)
+	}
+	return nil
+}
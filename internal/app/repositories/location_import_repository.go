@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// LocationImportRepository interface defines data access methods for the journal of the
+// reference data imports, which rollbacks replay backwards
+type LocationImportRepository interface {
+	Create(ctx context.Context, imp models.LocationImport) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.LocationImport, error)
+	// List returns imports newest first, older than beforeID when it is not 0
+	List(ctx context.Context, beforeID uint64, limit int) ([]models.LocationImport, error)
+	// Latest returns the newest import still applied, sql.ErrNoRows when there is none
+	Latest(ctx context.Context) (*models.LocationImport, error)
+	// SetStatus records the outcome of an import and its counts of regions created and updated
+	SetStatus(ctx context.Context, id uint64, status string, created, updated int) error
+	MarkRolledBack(ctx context.Context, id uint64, by *uint64, at time.Time) error
+	AddChange(ctx context.Context, change models.LocationImportChange) error
+	// Changes returns the changes of an import in the order they were made
+	Changes(ctx context.Context, importID uint64) ([]models.LocationImportChange, error)
+}
+
+// locationImportRepository implements LocationImportRepository
+type locationImportRepository struct {
+	db *sql.DB
+}
+
+// NewLocationImportRepository creates a new location import repository
+func NewLocationImportRepository(db *sql.DB) LocationImportRepository {
+	return &locationImportRepository{db: db}
+}
+
+const locationImportColumns = "id, filename, source, status, created, updated, created_by, rolled_back_by, rolled_back_at, created_at"
+
+func scanLocationImport(scan func(dest ...interface{}) error) (*models.LocationImport, error) {
+	var imp models.LocationImport
+	if err := scan(&imp.ID, &imp.Filename, &imp.Source, &imp.Status, &imp.Created, &imp.Updated,
+		&imp.CreatedBy, &imp.RolledBackBy, &imp.RolledBackAt, &imp.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// Create inserts a new import
+func (r *locationImportRepository) Create(ctx context.Context, imp models.LocationImport) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO location_imports (filename, source, status, created, updated, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		imp.Filename, imp.Source, imp.Status, imp.Created, imp.Updated, imp.CreatedBy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert location import: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetByID retrieves an import by ID
+func (r *locationImportRepository) GetByID(ctx context.Context, id uint64) (*models.LocationImport, error) {
+	imp, err := scanLocationImport(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+locationImportColumns+`
+		FROM location_imports
+		WHERE id = ?`,
+		id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan location import: %w", err)
+	}
+	return imp, nil
+}
+
+// List retrieves a page of imports
+func (r *locationImportRepository) List(ctx context.Context, beforeID uint64, limit int) ([]models.LocationImport, error) {
+	query := "SELECT " + locationImportColumns + " FROM location_imports"
+	var args []interface{}
+	if beforeID > 0 {
+		query += " WHERE id < ?"
+		args = append(args, beforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list location imports: %w", err)
+	}
+	defer rows.Close()
+	imports := []models.LocationImport{}
+	for rows.Next() {
+		imp, err := scanLocationImport(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location import: %w", err)
+		}
+		imports = append(imports, *imp)
+	}
+	return imports, rows.Err()
+}
+
+// Latest retrieves the newest applied import
+func (r *locationImportRepository) Latest(ctx context.Context) (*models.LocationImport, error) {
+	imp, err := scanLocationImport(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+locationImportColumns+`
+		FROM location_imports
+		WHERE status = ?
+		ORDER BY id DESC
+		LIMIT 1`,
+		models.LocationImportApplied).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan location import: %w", err)
+	}
+	return imp, nil
+}
+
+// SetStatus updates the status and counts of an import
+func (r *locationImportRepository) SetStatus(ctx context.Context, id uint64, status string, created, updated int) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE location_imports
+		SET status = ?, created = ?, updated = ?
+		WHERE id = ?`,
+		status, created, updated, id); err != nil {
+		return fmt.Errorf("failed to update location import: %w", err)
+	}
+	return nil
+}
+
+// MarkRolledBack records who rolled an import back, and when
+func (r *locationImportRepository) MarkRolledBack(ctx context.Context, id uint64, by *uint64, at time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE location_imports
+		SET status = ?, rolled_back_by = ?, rolled_back_at = ?
+		WHERE id = ?`,
+		models.LocationImportRolledBack, by, at, id); err != nil {
+		return fmt.Errorf("failed to update location import: %w", err)
+	}
+	return nil
+}
+
+// AddChange journals a region an import wrote, with its values before and after
+func (r *locationImportRepository) AddChange(ctx context.Context, change models.LocationImportChange) error {
+	var oldJSON, newJSON []byte
+	var err error
+	if change.Old != nil {
+		if oldJSON, err = json.Marshal(change.Old); err != nil {
+			return err
+		}
+	}
+	if change.New != nil {
+		if newJSON, err = json.Marshal(change.New); err != nil {
+			return err
+		}
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO location_import_changes (import_id, kind, record_id, action, old_values, new_values)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		change.ImportID, change.Kind, change.RecordID, change.Action, oldJSON, newJSON); err != nil {
+		return fmt.Errorf("failed to insert location import change: %w", err)
+	}
+	return nil
+}
+
+// Changes retrieves the changes of an import
+func (r *locationImportRepository) Changes(ctx context.Context, importID uint64) ([]models.LocationImportChange, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT id, import_id, kind, record_id, action, old_values, new_values
+		FROM location_import_changes
+		WHERE import_id = ?
+		ORDER BY id`,
+		importID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list location import changes: %w", err)
+	}
+	defer rows.Close()
+	changes := []models.LocationImportChange{}
+	for rows.Next() {
+		var change models.LocationImportChange
+		var oldJSON, newJSON []byte
+		if err := rows.Scan(&change.ID, &change.ImportID, &change.Kind, &change.RecordID, &change.Action, &oldJSON, &newJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan location import change: %w", err)
+		}
+		for _, v := range []struct {
+			data []byte
+			dest **models.Region
+		}{{oldJSON, &change.Old}, {newJSON, &change.New}} {
+			if len(v.data) == 0 {
+				continue
+			}
+			var region models.Region
+			if err := json.Unmarshal(v.data, &region); err != nil {
+				return nil, fmt.Errorf("failed to decode location import change %d: %w", change.ID, err)
+			}
+			*v.dest = &region
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,327 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// LocationRepository interface defines data access methods for managing the provinces and
+// cities prayer times are computed for
+type LocationRepository interface {
+	GetProvince(ctx context.Context, id int) (*models.Province, error)
+	GetCity(ctx context.Context, id int) (*models.CityLocation, error)
+	// CreateCity adds the city to app_city and its coordinates to data_lintang_kota_cms_new
+	CreateCity(ctx context.Context, city models.CityLocation) (int, error)
+	// UpdateCity replaces the city's name, province and coordinates
+	UpdateCity(ctx context.Context, city models.CityLocation) error
+
+	// ListRegions returns every province and every city with its code and coordinates, as a
+	// reference data import compares them
+	ListRegions(ctx context.Context) (provinces, cities []models.Region, err error)
+	// SaveProvince inserts the province when its ID is 0, or replaces it, and returns its ID
+	SaveProvince(ctx context.Context, province models.Region) (int, error)
+	// SaveCity inserts the city when its ID is 0, or replaces it, and returns its ID. Its
+	// coordinates row is written, or removed when it has none.
+	SaveCity(ctx context.Context, city models.Region) (int, error)
+	DeleteProvince(ctx context.Context, id int) error
+	// DeleteCity removes the city and its coordinates
+	DeleteCity(ctx context.Context, id int) error
+	// CountCities counts the cities of a province
+	CountCities(ctx context.Context, provinceID int) (int, error)
+}
+
+// locationRepository implements LocationRepository
+type locationRepository struct {
+	db *sql.DB
+}
+
+// NewLocationRepository creates a new location repository
+func NewLocationRepository(db *sql.DB) LocationRepository {
+	return &locationRepository{db: db}
+}
+
+// GetProvince retrieves a province by ID
+func (r *locationRepository) GetProvince(ctx context.Context, id int) (*models.Province, error) {
+	var province models.Province
+	err := reader(ctx, r.db).QueryRowContext(ctx, `
+		SELECT province_id, province_title, province_id_new
+		FROM app_province
+		WHERE province_id = ?`,
+		id).Scan(&province.ID, &province.Title, &province.ProvinceIDNew)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan province: %w", err)
+	}
+
+	return &province, nil
+}
+
+// GetCity retrieves a city and its coordinates by ID
+func (r *locationRepository) GetCity(ctx context.Context, id int) (*models.CityLocation, error) {
+	var city models.CityLocation
+	var title, latitude, longitude sql.NullString
+	err := reader(ctx, r.db).QueryRowContext(ctx, `
+		SELECT c.city_id, c.city_province, c.city_title, dlk.lintang_tempat, dlk.bujur_tempat, dlk.h, dlk.time_zone
+		FROM app_city c
+		LEFT JOIN data_lintang_kota_cms_new dlk ON dlk.nama_kota = c.city_id
+		WHERE c.city_id = ?
+		LIMIT 1`,
+		id).Scan(&city.ID, &city.ProvinceID, &title, &latitude, &longitude, &city.Elevation, &city.TimeZone)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan city: %w", err)
+	}
+
+	city.Name = title.String
+	city.Latitude = parseCoordinate(latitude)
+	city.Longitude = parseCoordinate(longitude)
+	return &city, nil
+}
+
+// CreateCity inserts a city with its coordinates
+func (r *locationRepository) CreateCity(ctx context.Context, city models.CityLocation) (int, error) {
+	id, err := r.insertKeyed(ctx, "city_id", `
+		INSERT INTO app_city (city_title, city_province, city_id_new)
+		VALUES (?, ?, 0)`,
+		city.Name, city.ProvinceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert city: %w", err)
+	}
+
+	city.ID = id
+	if err := r.insertCoordinates(ctx, city); err != nil {
+		return 0, err
+	}
+	return city.ID, nil
+}
+
+// UpdateCity modifies a city and its coordinates, adding them when it had none
+func (r *locationRepository) UpdateCity(ctx context.Context, city models.CityLocation) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE app_city
+		SET city_title = ?, city_province = ?
+		WHERE city_id = ?`,
+		city.Name, city.ProvinceID, city.ID); err != nil {
+		return fmt.Errorf("failed to update city: %w", err)
+	}
+	return r.saveCoordinates(ctx, city)
+}
+
+// saveCoordinates replaces the data_lintang_kota_cms_new row of city, adding it when it had
+// none
+func (r *locationRepository) saveCoordinates(ctx context.Context, city models.CityLocation) error {
+	db := conn(ctx, r.db)
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM data_lintang_kota_cms_new WHERE nama_kota = ?", city.ID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count city coordinates: %w", err)
+	}
+	if count == 0 {
+		return r.insertCoordinates(ctx, city)
+	}
+	if _, err := db.ExecContext(ctx, `
+		UPDATE data_lintang_kota_cms_new
+		SET nama_propinsi = ?, lintang_tempat = ?, bujur_tempat = ?, h = ?, time_zone = ?
+		WHERE nama_kota = ?`,
+		city.ProvinceID, formatCoordinate(city.Latitude), formatCoordinate(city.Longitude), city.Elevation, city.TimeZone, city.ID); err != nil {
+		return fmt.Errorf("failed to update city coordinates: %w", err)
+	}
+	return nil
+}
+
+// insertKeyed runs an INSERT and returns the generated key. The reference tables are keyed
+// by their own columns (city_id, province_id), not the id InsertID reads back on PostgreSQL.
+func (r *locationRepository) insertKeyed(ctx context.Context, key, query string, args ...interface{}) (int, error) {
+	db := conn(ctx, r.db)
+	var id int64
+	if database.Current.Name() == database.DriverPostgres {
+		if err := db.QueryRowContext(ctx, query+" RETURNING "+key, args...).Scan(&id); err != nil {
+			return 0, err
+		}
+		return int(id), nil
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err = result.LastInsertId()
+	return int(id), err
+}
+
+// ListRegions retrieves every province and city. A city with several coordinates rows takes
+// the first, as GetCity does.
+func (r *locationRepository) ListRegions(ctx context.Context) ([]models.Region, []models.Region, error) {
+	db := conn(ctx, r.db)
+	rows, err := db.QueryContext(ctx, `
+		SELECT province_id, province_title, province_id_new
+		FROM app_province
+		ORDER BY province_id`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list provinces: %w", err)
+	}
+	defer rows.Close()
+	var provinces []models.Region
+	for rows.Next() {
+		var p models.Region
+		if err := rows.Scan(&p.ID, &p.Name, &p.Code); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan province: %w", err)
+		}
+		provinces = append(provinces, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT c.city_id, c.city_title, c.city_province, c.city_id_new,
+			dlk.id_kota, dlk.lintang_tempat, dlk.bujur_tempat, dlk.h, dlk.time_zone
+		FROM app_city c
+		LEFT JOIN data_lintang_kota_cms_new dlk ON dlk.nama_kota = c.city_id
+		ORDER BY c.city_id, dlk.id_kota`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list cities: %w", err)
+	}
+	defer rows.Close()
+	var cities []models.Region
+	for rows.Next() {
+		var c models.Region
+		var title, latitude, longitude sql.NullString
+		var coordinatesID sql.NullInt64
+		if err := rows.Scan(&c.ID, &title, &c.ProvinceID, &c.Code,
+			&coordinatesID, &latitude, &longitude, &c.Elevation, &c.TimeZone); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan city: %w", err)
+		}
+		if n := len(cities); n > 0 && cities[n-1].ID == c.ID {
+			continue
+		}
+		c.Name = title.String
+		c.HasCoordinates = coordinatesID.Valid
+		c.Latitude = parseCoordinate(latitude)
+		c.Longitude = parseCoordinate(longitude)
+		cities = append(cities, c)
+	}
+	return provinces, cities, rows.Err()
+}
+
+// SaveProvince inserts or updates a province
+func (r *locationRepository) SaveProvince(ctx context.Context, p models.Region) (int, error) {
+	if p.ID == 0 {
+		id, err := r.insertKeyed(ctx, "province_id", `
+			INSERT INTO app_province (province_title, province_id_new)
+			VALUES (?, ?)`,
+			p.Name, p.Code)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert province: %w", err)
+		}
+		return id, nil
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE app_province
+		SET province_title = ?, province_id_new = ?
+		WHERE province_id = ?`,
+		p.Name, p.Code, p.ID); err != nil {
+		return 0, fmt.Errorf("failed to update province: %w", err)
+	}
+	return p.ID, nil
+}
+
+// SaveCity inserts or updates a city with its coordinates
+func (r *locationRepository) SaveCity(ctx context.Context, c models.Region) (int, error) {
+	db := conn(ctx, r.db)
+	if c.ID == 0 {
+		id, err := r.insertKeyed(ctx, "city_id", `
+			INSERT INTO app_city (city_title, city_province, city_id_new)
+			VALUES (?, ?, ?)`,
+			c.Name, c.ProvinceID, c.Code)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert city: %w", err)
+		}
+		c.ID = id
+	} else if _, err := db.ExecContext(ctx, `
+		UPDATE app_city
+		SET city_title = ?, city_province = ?, city_id_new = ?
+		WHERE city_id = ?`,
+		c.Name, c.ProvinceID, c.Code, c.ID); err != nil {
+		return 0, fmt.Errorf("failed to update city: %w", err)
+	}
+
+	if !c.HasCoordinates {
+		if _, err := db.ExecContext(ctx, "DELETE FROM data_lintang_kota_cms_new WHERE nama_kota = ?", c.ID); err != nil {
+			return 0, fmt.Errorf("failed to delete city coordinates: %w", err)
+		}
+		return c.ID, nil
+	}
+	city := models.CityLocation{ID: c.ID, ProvinceID: c.ProvinceID, Name: c.Name,
+		Latitude: c.Latitude, Longitude: c.Longitude, Elevation: c.Elevation, TimeZone: c.TimeZone}
+	if err := r.saveCoordinates(ctx, city); err != nil {
+		return 0, err
+	}
+	return c.ID, nil
+}
+
+// DeleteProvince removes a province
+func (r *locationRepository) DeleteProvince(ctx context.Context, id int) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM app_province WHERE province_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete province: %w", err)
+	}
+	return nil
+}
+
+// DeleteCity removes a city and its coordinates
+func (r *locationRepository) DeleteCity(ctx context.Context, id int) error {
+	db := conn(ctx, r.db)
+	if _, err := db.ExecContext(ctx, "DELETE FROM data_lintang_kota_cms_new WHERE nama_kota = ?", id); err != nil {
+		return fmt.Errorf("failed to delete city coordinates: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM app_city WHERE city_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete city: %w", err)
+	}
+	return nil
+}
+
+// CountCities counts the cities of a province
+func (r *locationRepository) CountCities(ctx context.Context, provinceID int) (int, error) {
+	var count int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM app_city WHERE city_province = ?", provinceID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count cities: %w", err)
+	}
+	return count, nil
+}
+
+// insertCoordinates adds the data_lintang_kota_cms_new row of city
+func (r *locationRepository) insertCoordinates(ctx context.Context, city models.CityLocation) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO data_lintang_kota_cms_new (nama_propinsi, nama_kota, lintang_tempat, bujur_tempat, h, time_zone)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		city.ProvinceID, city.ID, formatCoordinate(city.Latitude), formatCoordinate(city.Longitude), city.Elevation, city.TimeZone); err != nil {
+		return fmt.Errorf("failed to insert city coordinates: %w", err)
+	}
+	return nil
+}
+
+// parseCoordinate reads a coordinate column, which holds decimal degrees as text
+func parseCoordinate(s sql.NullString) *float64 {
+	v, err := strconv.ParseFloat(s.String, 64)
+	if !s.Valid || err != nil {
+		return nil
+	}
+	return &v
+}
+
+// formatCoordinate writes a coordinate column, to six decimals (about 10 cm)
+func formatCoordinate(v *float64) *string {
+	if v == nil {
+		return nil
+	}
+	s := strconv.FormatFloat(math.Round(*v*1e6)/1e6, 'f', -1, 64)
+	return &s
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// MailDeliveryRepository interface defines data access methods for the mail delivery log
+type MailDeliveryRepository interface {
+	Create(ctx context.Context, d models.MailDelivery) (uint64, error)
+	Update(ctx context.Context, d models.MailDelivery) error
+	List(ctx context.Context, filter models.MailDeliveryFilter) ([]models.MailDelivery, error)
+}
+
+// mailDeliveryRepository implements MailDeliveryRepository
+type mailDeliveryRepository struct {
+	db *sql.DB
+}
+
+// NewMailDeliveryRepository creates a new mail delivery repository
+func NewMailDeliveryRepository(db *sql.DB) MailDeliveryRepository {
+	return &mailDeliveryRepository{db: db}
+}
+
+// Create records a delivery before its first attempt
+func (r *mailDeliveryRepository) Create(ctx context.Context, d models.MailDelivery) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO mail_deliveries (template, recipient, subject, user_id, status, attempts, last_error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.Template, d.Recipient, d.Subject, d.UserID, d.Status, d.Attempts, d.LastError, d.CreatedAt, d.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert mail delivery: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// Update records the outcome of an attempt
+func (r *mailDeliveryRepository) Update(ctx context.Context, d models.MailDelivery) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE mail_deliveries
+		SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, d.LastError, d.NextAttemptAt, time.Now(), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update mail delivery: %w", err)
+	}
+	return nil
+}
+
+// List retrieves the deliveries matching filter, newest first
+func (r *mailDeliveryRepository) List(ctx context.Context, filter models.MailDeliveryFilter) ([]models.MailDelivery, error) {
+	query := `
+		SELECT id, template, recipient, subject, user_id, status, attempts, last_error, next_attempt_at,
+			created_at, updated_at
+		FROM mail_deliveries
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if filter.Template != "" {
+		query += " AND template = ?"
+		args = append(args, filter.Template)
+	}
+	if filter.Recipient != "" {
+		query += " AND recipient = ?"
+		args = append(args, filter.Recipient)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mail deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.MailDelivery{}
+	for rows.Next() {
+		var d models.MailDelivery
+		if err := rows.Scan(&d.ID, &d.Template, &d.Recipient, &d.Subject, &d.UserID, &d.Status, &d.Attempts,
+			&d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mail delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mail deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -1,21 +1,35 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 )
 
 // MenuRepository interface defines data access methods for menus
 type MenuRepository interface {
-	GetAll() ([]models.Menu, error)
-	GetByID(id uint) (*models.Menu, error)
-	Create(req models.Menu) (uint, error)
-	Update(id uint, req map[string]interface{}) error
-	Delete(id uint, deletedBy *uint64) error
+	GetAll(ctx context.Context, sort []utils.SortTerm) ([]models.Menu, error)
+	GetByID(ctx context.Context, id uint) (*models.Menu, error)
+	Create(ctx context.Context, req models.Menu) (uint, error)
+	Update(ctx context.Context, id uint, req map[string]interface{}) error
+	Delete(ctx context.Context, id uint, deletedBy *uint64) error
+	// DeletedStates locks the menus among ids until the transaction ends and returns their
+	// soft delete state; IDs without a row are missing from the map
+	DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error)
+	DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error
+	RestoreMany(ctx context.Context, ids []uint64, at time.Time) error
+	// LockLive returns the live menu items from the primary, locking them until the
+	// transaction ends
+	LockLive(ctx context.Context) ([]models.Menu, error)
+	// Place sets a live menu item's label, URL, icon, parent and sort order
+	Place(ctx context.Context, menu models.Menu, at time.Time) error
 }
 
 // menuRepository implements MenuRepository
@@ -28,13 +42,34 @@ func NewMenuRepository(db *sql.DB) MenuRepository {
 	return &menuRepository{db: db}
 }
 
-// GetAll retrieves all active menus
-func (r *menuRepository) GetAll() ([]models.Menu, error) {
-	rows, err := r.db.Query(`
+// MenuListSpec whitelists the sort and fields parameters of the menu list
+var MenuListSpec = utils.ListSpec{
+	Sort: map[string]string{
+		"id":         "id",
+		"label":      "label",
+		"parent_id":  "parent_id",
+		"sort_order": "sort_order",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	Fields:   []string{"id", "label", "url", "icon", "parent_id", "sort_order", "created_at", "updated_at", "deleted_at", "deleted_by"},
+	Tiebreak: "id ASC",
+}
+
+// GetAll retrieves all active menus in sort_order unless sort (checked against
+// MenuListSpec) says otherwise
+func (r *menuRepository) GetAll(ctx context.Context, sort []utils.SortTerm) ([]models.Menu, error) {
+	orderBy, err := MenuListSpec.OrderBy(sort, "sort_order")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT id, label, url, icon, parent_id, sort_order, created_at, updated_at, deleted_at, deleted_by
 		FROM menu
-		WHERE deleted_at IS NULL
-		ORDER BY sort_order`)
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY `+orderBy,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query menus: %w", err)
 	}
@@ -57,13 +92,13 @@ func (r *menuRepository) GetAll() ([]models.Menu, error) {
 }
 
 // GetByID retrieves a menu by ID
-func (r *menuRepository) GetByID(id uint) (*models.Menu, error) {
+func (r *menuRepository) GetByID(ctx context.Context, id uint) (*models.Menu, error) {
 	var m models.Menu
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT id, label, url, icon, parent_id, sort_order, created_at, updated_at, deleted_at, deleted_by
 		FROM menu
-		WHERE id = ? AND deleted_at IS NULL`,
-		id)
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tenant.ID(ctx))
 
 	err := row.Scan(&m.ID, &m.Label, &m.Url, &m.Icon, &m.ParentID, &m.SortOrder, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -76,26 +111,35 @@ func (r *menuRepository) GetByID(id uint) (*models.Menu, error) {
 	return &m, nil
 }
 
-// Create inserts a new menu
-func (r *menuRepository) Create(req models.Menu) (uint, error) {
-	result, err := r.db.Exec(`
-		INSERT INTO menu (label, url, icon, parent_id, sort_order, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		req.Label, req.Url, req.Icon, req.ParentID, req.SortOrder, req.CreatedAt, req.UpdatedAt)
-	if err != nil {
-		return 0, fmt.Errorf("failed to insert menu: %w", err)
+// Create inserts a new menu into the tenant, under a parent of the same tenant
+func (r *menuRepository) Create(ctx context.Context, req models.Menu) (uint, error) {
+	db := conn(ctx, r.db)
+	if req.ParentID != nil {
+		if err := requireInTenant(ctx, db, "menu", uint64(*req.ParentID), "Parent menu"); err != nil {
+			return 0, err
+		}
 	}
-
-	menuID, err := result.LastInsertId()
+	menuID, err := database.InsertID(ctx, db, `
+		INSERT INTO menu (tenant_id, label, url, icon, parent_id, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		tenant.ID(ctx), req.Label, req.Url, req.Icon, req.ParentID, req.SortOrder, req.CreatedAt, req.UpdatedAt)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+		return 0, fmt.Errorf("failed to insert menu: %w", err)
 	}
 
 	return uint(menuID), nil
 }
 
-// Update modifies an existing menu with dynamic fields
-func (r *menuRepository) Update(id uint, req map[string]interface{}) error {
+// Update modifies an existing menu with dynamic fields. A key mapped to nil sets its column
+// to NULL, which only url, icon and parent_id allow.
+func (r *menuRepository) Update(ctx context.Context, id uint, req map[string]interface{}) error {
+	for _, column := range []string{"label", "sort_order"} {
+		if v, ok := req[column]; ok && v == nil {
+			return fmt.Errorf("%s cannot be null", column)
+		}
+	}
+
+	db := conn(ctx, r.db)
 	var setParts []string
 	var args []interface{}
 
@@ -112,6 +156,11 @@ func (r *menuRepository) Update(id uint, req map[string]interface{}) error {
 		args = append(args, icon)
 	}
 	if parentID, ok := req["parent_id"]; ok {
+		if id, ok := parentID.(uint); ok {
+			if err := requireInTenant(ctx, db, "menu", uint64(id), "Parent menu"); err != nil {
+				return err
+			}
+		}
 		setParts = append(setParts, "parent_id = ?")
 		args = append(args, parentID)
 	}
@@ -128,18 +177,69 @@ func (r *menuRepository) Update(id uint, req map[string]interface{}) error {
 	args = append(args, time.Now())
 
 	setClause := strings.Join(setParts, ", ")
-	query := fmt.Sprintf("UPDATE menu SET %s WHERE id = ? AND deleted_at IS NULL", setClause)
-	args = append(args, id)
+	query := fmt.Sprintf("UPDATE menu SET %s WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL", setClause)
+	args = append(args, id, tenant.ID(ctx))
 
-	_, err := r.db.Exec(query, args...)
+	_, err := db.ExecContext(ctx, query, args...)
 	return err
 }
 
 // Delete performs a soft delete
-func (r *menuRepository) Delete(id uint, deletedBy *uint64) error {
-	_, err := r.db.Exec(`
+func (r *menuRepository) Delete(ctx context.Context, id uint, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE menu SET deleted_at = ?, updated_at = ?, deleted_by = ?
-		WHERE id = ? AND deleted_at IS NULL`,
-		time.Now(), time.Now(), deletedBy, id)
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		time.Now(), time.Now(), deletedBy, id, tenant.ID(ctx))
 	return err
 }
+
+// DeletedStates reads and locks the soft delete state of menus
+func (r *menuRepository) DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error) {
+	return softDeleteStates(ctx, conn(ctx, r.db), "menu", ids)
+}
+
+// DeleteMany soft deletes menus
+func (r *menuRepository) DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error {
+	return softDeleteMany(ctx, conn(ctx, r.db), "menu", ids, deletedBy, at)
+}
+
+// RestoreMany restores soft-deleted menus
+func (r *menuRepository) RestoreMany(ctx context.Context, ids []uint64, at time.Time) error {
+	return restoreMany(ctx, conn(ctx, r.db), "menu", ids, at)
+}
+
+// LockLive reads and locks the live menus of the tenant
+func (r *menuRepository) LockLive(ctx context.Context) ([]models.Menu, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT id, label, url, icon, parent_id, sort_order, created_at, updated_at, deleted_at, deleted_by
+		FROM menu
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY sort_order, id
+		FOR UPDATE`,
+		tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menus: %w", err)
+	}
+	defer rows.Close()
+
+	var menus []models.Menu
+	for rows.Next() {
+		var m models.Menu
+		if err := rows.Scan(&m.ID, &m.Label, &m.Url, &m.Icon, &m.ParentID, &m.SortOrder, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt, &m.DeletedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan menu: %w", err)
+		}
+		menus = append(menus, m)
+	}
+	return menus, rows.Err()
+}
+
+// Place moves and relabels a menu
+func (r *menuRepository) Place(ctx context.Context, menu models.Menu, at time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE menu SET label = ?, url = ?, icon = ?, parent_id = ?, sort_order = ?, updated_at = ?
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		menu.Label, menu.Url, menu.Icon, menu.ParentID, menu.SortOrder, at, menu.ID, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to update menu: %w", err)
+	}
+	return nil
+}
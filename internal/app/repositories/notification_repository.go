@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// NotificationRepository interface defines data access methods for the notification center
+type NotificationRepository interface {
+	Create(ctx context.Context, n models.Notification) (uint64, error)
+	GetByID(ctx context.Context, userID, id uint64) (*models.Notification, error)
+	List(ctx context.Context, userID uint64, filter models.NotificationFilter) ([]models.Notification, error)
+	CountUnread(ctx context.Context, userID uint64) (int, error)
+	// MarkRead marks the unread notifications of userID among ids read, every unread one when
+	// ids is nil, and returns how many it marked
+	MarkRead(ctx context.Context, userID uint64, ids []uint64) (int64, error)
+}
+
+// notificationRepository implements NotificationRepository
+type notificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+const notificationColumns = "id, user_id, type, message, data, created_at, read_at"
+
+func scanNotification(scan func(dest ...interface{}) error) (*models.Notification, error) {
+	var n models.Notification
+	var data []byte
+	if err := scan(&n.ID, &n.UserID, &n.Type, &n.Message, &data, &n.CreatedAt, &n.ReadAt); err != nil {
+		return nil, err
+	}
+	n.Data = data
+	return &n, nil
+}
+
+// Create records a notification; within a transaction it is only kept if that commits
+func (r *notificationRepository) Create(ctx context.Context, n models.Notification) (uint64, error) {
+	var data interface{}
+	if len(n.Data) > 0 {
+		data = []byte(n.Data)
+	}
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO notifications (user_id, type, message, data, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		n.UserID, n.Type, n.Message, data, n.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert notification: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// GetByID retrieves a notification of userID by ID
+func (r *notificationRepository) GetByID(ctx context.Context, userID, id uint64) (*models.Notification, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+notificationColumns+`
+		FROM notifications
+		WHERE id = ? AND user_id = ?`,
+		id, userID)
+
+	n, err := scanNotification(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notification: %w", err)
+	}
+
+	return n, nil
+}
+
+// List retrieves the notifications of userID matching filter, newest first
+func (r *notificationRepository) List(ctx context.Context, userID uint64, filter models.NotificationFilter) ([]models.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE user_id = ?`
+	args := []interface{}{userID}
+	if filter.Unread {
+		query += " AND read_at IS NULL"
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		list = append(list, *n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notifications: %w", err)
+	}
+
+	return list, nil
+}
+
+// CountUnread counts the notifications of userID not yet read
+func (r *notificationRepository) CountUnread(ctx context.Context, userID uint64) (int, error) {
+	var count int
+	err := reader(ctx, r.db).QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks notifications read
+func (r *notificationRepository) MarkRead(ctx context.Context, userID uint64, ids []uint64) (int64, error) {
+	query := "UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL"
+	args := []interface{}{time.Now(), userID}
+	if ids != nil {
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		query += " AND id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// OTPRepository interface defines data access methods for the users' phone numbers and the
+// one-time codes sent to them
+type OTPRepository interface {
+	GetPhone(ctx context.Context, userID uint64) (*models.UserPhone, error)
+	// GetVerifiedPhone returns the phone verified with number, by any user
+	GetVerifiedPhone(ctx context.Context, number string) (*models.UserPhone, error)
+	// SavePhone stores p by its user, replacing the number they had
+	SavePhone(ctx context.Context, p models.UserPhone) error
+	// VerifyPhone marks the user's number verified, unless it changed meanwhile
+	VerifyPhone(ctx context.Context, userID uint64, number string, at time.Time) (bool, error)
+	// SetTwoFactor turns codes at sign-in on or off, reporting whether the user has a phone
+	SetTwoFactor(ctx context.Context, userID uint64, enabled bool, at time.Time) (bool, error)
+
+	CreateCode(ctx context.Context, code models.OTPCode) (uint64, error)
+	GetCodeByChallenge(ctx context.Context, challengeHash string) (*models.OTPCode, error)
+	IncrementAttempts(ctx context.Context, id uint64) error
+	// ConsumeCode marks a code used, reporting false when another request used it first
+	ConsumeCode(ctx context.Context, id uint64, at time.Time) (bool, error)
+	// ConsumeOpenCodes voids the unused codes of a user for purpose
+	ConsumeOpenCodes(ctx context.Context, userID uint64, purpose string, at time.Time) error
+	// DeleteExpiredCodes removes the codes that expired before before
+	DeleteExpiredCodes(ctx context.Context, before time.Time) (int64, error)
+}
+
+// otpRepository implements OTPRepository
+type otpRepository struct {
+	db *sql.DB
+}
+
+// NewOTPRepository creates a new OTP repository
+func NewOTPRepository(db *sql.DB) OTPRepository {
+	return &otpRepository{db: db}
+}
+
+const userPhoneColumns = "id, user_id, phone, verified_at, two_factor, created_at, updated_at"
+
+func scanUserPhone(scan func(dest ...interface{}) error) (*models.UserPhone, error) {
+	var p models.UserPhone
+	if err := scan(&p.ID, &p.UserID, &p.Phone, &p.VerifiedAt, &p.TwoFactor, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPhone retrieves a user's phone; from the primary, as it guards sign-in
+func (r *otpRepository) GetPhone(ctx context.Context, userID uint64) (*models.UserPhone, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+userPhoneColumns+`
+		FROM user_phones
+		WHERE user_id = ?`,
+		userID)
+
+	p, err := scanUserPhone(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan user phone: %w", err)
+	}
+	return p, nil
+}
+
+// GetVerifiedPhone retrieves the verified phone with a number
+func (r *otpRepository) GetVerifiedPhone(ctx context.Context, number string) (*models.UserPhone, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+userPhoneColumns+`
+		FROM user_phones
+		WHERE phone = ? AND verified_at IS NOT NULL
+		ORDER BY verified_at
+		LIMIT 1`,
+		number)
+
+	p, err := scanUserPhone(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan user phone: %w", err)
+	}
+	return p, nil
+}
+
+// SavePhone inserts or updates a user's phone. A phone saved concurrently by another
+// request is updated instead.
+func (r *otpRepository) SavePhone(ctx context.Context, p models.UserPhone) error {
+	db := conn(ctx, r.db)
+	for attempt := 0; ; attempt++ {
+		var id uint64
+		err := db.QueryRowContext(ctx, "SELECT id FROM user_phones WHERE user_id = ?", p.UserID).Scan(&id)
+		if err == nil {
+			_, err = db.ExecContext(ctx, `
+				UPDATE user_phones
+				SET phone = ?, verified_at = ?, two_factor = ?, updated_at = ?
+				WHERE id = ?`,
+				p.Phone, p.VerifiedAt, p.TwoFactor, p.UpdatedAt, id)
+			if err != nil {
+				return fmt.Errorf("failed to update user phone: %w", err)
+			}
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to look up user phone: %w", err)
+		}
+
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO user_phones (user_id, phone, verified_at, two_factor, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			p.UserID, p.Phone, p.VerifiedAt, p.TwoFactor, p.CreatedAt, p.UpdatedAt)
+		if database.IsDuplicateKey(err) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert user phone: %w", err)
+		}
+		return nil
+	}
+}
+
+// VerifyPhone sets verified_at if the user's number is still number
+func (r *otpRepository) VerifyPhone(ctx context.Context, userID uint64, number string, at time.Time) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE user_phones
+		SET verified_at = ?, updated_at = ?
+		WHERE user_id = ? AND phone = ?`,
+		at, at, userID, number)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify user phone: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetTwoFactor updates two_factor of a user's phone
+func (r *otpRepository) SetTwoFactor(ctx context.Context, userID uint64, enabled bool, at time.Time) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE user_phones
+		SET two_factor = ?, updated_at = ?
+		WHERE user_id = ?`,
+		enabled, at, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update two-factor setting: %w", err)
+	}
+	// MySQL counts only changed rows, so an unchanged setting is told apart by a lookup
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	_, err = r.GetPhone(ctx, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateCode stores a code sent
+func (r *otpRepository) CreateCode(ctx context.Context, code models.OTPCode) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO otp_codes (user_id, purpose, phone, code_hash, challenge_hash, attempts, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		code.UserID, code.Purpose, code.Phone, code.CodeHash, code.ChallengeHash, code.Attempts, code.ExpiresAt, code.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert OTP code: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetCodeByChallenge retrieves a code by the hash of its challenge
+func (r *otpRepository) GetCodeByChallenge(ctx context.Context, challengeHash string) (*models.OTPCode, error) {
+	var c models.OTPCode
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, user_id, purpose, phone, code_hash, challenge_hash, attempts, expires_at, consumed_at, created_at
+		FROM otp_codes
+		WHERE challenge_hash = ?`,
+		challengeHash).Scan(&c.ID, &c.UserID, &c.Purpose, &c.Phone, &c.CodeHash, &c.ChallengeHash, &c.Attempts,
+		&c.ExpiresAt, &c.ConsumedAt, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan OTP code: %w", err)
+	}
+	return &c, nil
+}
+
+// IncrementAttempts counts a wrong guess
+func (r *otpRepository) IncrementAttempts(ctx context.Context, id uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE otp_codes SET attempts = attempts + 1 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to count OTP attempt: %w", err)
+	}
+	return nil
+}
+
+// ConsumeCode sets consumed_at of an unused code
+func (r *otpRepository) ConsumeCode(ctx context.Context, id uint64, at time.Time) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE otp_codes SET consumed_at = ? WHERE id = ? AND consumed_at IS NULL", at, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume OTP code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ConsumeOpenCodes sets consumed_at of a user's unused codes for purpose
+func (r *otpRepository) ConsumeOpenCodes(ctx context.Context, userID uint64, purpose string, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE otp_codes SET consumed_at = ?
+		WHERE user_id = ? AND purpose = ? AND consumed_at IS NULL`,
+		at, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to void OTP codes: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredCodes removes codes past their expiry
+func (r *otpRepository) DeleteExpiredCodes(ctx context.Context, before time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM otp_codes WHERE expires_at < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired OTP codes: %w", err)
+	}
+	return res.RowsAffected()
+}
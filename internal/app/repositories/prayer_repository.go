@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
 	"context"
 	"database/sql"
 	"fmt"
@@ -76,7 +77,7 @@ func (r *prayerRepository) GetLocationData(ctx context.Context, provinceID, city
 	query += " LIMIT 1"
 
 	var locationData LocationData
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+	err := reader(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(
 		&locationData.ID,
 		&locationData.Latitude,
 		&locationData.Longitude,
@@ -106,7 +107,7 @@ func (r *prayerRepository) GetFastingData(ctx context.Context, year int) (*model
 	`
 
 	var fastingData models.FastingData
-	err := r.db.QueryRowContext(ctx, query, year).Scan(
+	err := reader(ctx, r.db).QueryRowContext(ctx, query, year).Scan(
 		&fastingData.Tahun,
 		&fastingData.TglHijriah,
 		&fastingData.TglStart,
@@ -131,7 +132,7 @@ func (r *prayerRepository) GetAllProvinces(ctx context.Context) ([]*ProvinceData
 		ORDER BY province_id ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get provinces: %w", err)
 	}
@@ -159,11 +160,11 @@ func (r *prayerRepository) GetCitiesByProvince(ctx context.Context, provinceHash
 	query := `
 		SELECT city_id, city_province, city_title
 		FROM app_city
-		WHERE MD5(city_province) = ?
+		WHERE ` + database.Current.MD5("city_province") + ` = ?
 		ORDER BY city_id ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, provinceHash)
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, provinceHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cities: %w", err)
 	}
@@ -199,17 +200,17 @@ func (r *prayerRepository) GetLocationDataByHashes(ctx context.Context, province
 
 	args := []interface{}{}
 	if provinceHash != "" {
-		query += " AND MD5(p.province_id) = ?"
+		query += " AND " + database.Current.MD5("p.province_id") + " = ?"
 		args = append(args, provinceHash)
 	}
 	if cityHash != "" {
-		query += " AND MD5(c.city_id) = ?"
+		query += " AND " + database.Current.MD5("c.city_id") + " = ?"
 		args = append(args, cityHash)
 	}
 	query += " LIMIT 1"
 
 	var locationData LocationData
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+	err := reader(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(
 		&locationData.ID,
 		&locationData.Latitude,
 		&locationData.Longitude,
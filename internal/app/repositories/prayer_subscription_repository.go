@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// PrayerSubscriptionRepository interface defines data access methods for the chats
+// subscribed to prayer times
+type PrayerSubscriptionRepository interface {
+	Create(ctx context.Context, sub models.PrayerSubscription) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.PrayerSubscription, error)
+	List(ctx context.Context, filter models.PrayerSubscriptionFilter) ([]models.PrayerSubscription, error)
+	Update(ctx context.Context, id uint64, req map[string]interface{}) error
+	Delete(ctx context.Context, id uint64) error
+	// RecordSend stores the outcome of a message to the subscription: sentAt when it was
+	// delivered, the error otherwise, and deactivates it when active is false
+	RecordSend(ctx context.Context, id uint64, sentAt *time.Time, lastError *string, active bool) error
+}
+
+// prayerSubscriptionRepository implements PrayerSubscriptionRepository
+type prayerSubscriptionRepository struct {
+	db *sql.DB
+}
+
+// NewPrayerSubscriptionRepository creates a new prayer subscription repository
+func NewPrayerSubscriptionRepository(db *sql.DB) PrayerSubscriptionRepository {
+	return &prayerSubscriptionRepository{db: db}
+}
+
+const prayerSubscriptionColumns = "id, channel, chat_id, kind, province_id, city_id, active, last_sent_at, last_error, created_by, created_at, updated_at"
+
+func scanPrayerSubscription(scan func(dest ...interface{}) error) (*models.PrayerSubscription, error) {
+	var s models.PrayerSubscription
+	if err := scan(&s.ID, &s.Channel, &s.ChatID, &s.Kind, &s.ProvinceID, &s.CityID, &s.Active,
+		&s.LastSentAt, &s.LastError, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create inserts a new subscription
+func (r *prayerSubscriptionRepository) Create(ctx context.Context, sub models.PrayerSubscription) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO prayer_subscriptions (channel, chat_id, kind, province_id, city_id, active, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sub.Channel, sub.ChatID, sub.Kind, sub.ProvinceID, sub.CityID, sub.Active, sub.CreatedBy, sub.CreatedAt, sub.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert prayer subscription: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// GetByID retrieves a subscription by ID
+func (r *prayerSubscriptionRepository) GetByID(ctx context.Context, id uint64) (*models.PrayerSubscription, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+prayerSubscriptionColumns+`
+		FROM prayer_subscriptions
+		WHERE id = ?`,
+		id)
+
+	s, err := scanPrayerSubscription(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan prayer subscription: %w", err)
+	}
+
+	return s, nil
+}
+
+// List retrieves the subscriptions matching filter, oldest first
+func (r *prayerSubscriptionRepository) List(ctx context.Context, filter models.PrayerSubscriptionFilter) ([]models.PrayerSubscription, error) {
+	query := `
+		SELECT ` + prayerSubscriptionColumns + `
+		FROM prayer_subscriptions
+		WHERE id > ?`
+	args := []interface{}{filter.AfterID}
+	if filter.Channel != "" {
+		query += " AND channel = ?"
+		args = append(args, filter.Channel)
+	}
+	if filter.ChatID != "" {
+		query += " AND chat_id = ?"
+		args = append(args, filter.ChatID)
+	}
+	if filter.Kind != "" {
+		query += " AND kind = ?"
+		args = append(args, filter.Kind)
+	}
+	if filter.Active != nil {
+		query += " AND active = ?"
+		args = append(args, *filter.Active)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prayer subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.PrayerSubscription{}
+	for rows.Next() {
+		s, err := scanPrayerSubscription(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prayer subscription: %w", err)
+		}
+		list = append(list, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating prayer subscriptions: %w", err)
+	}
+
+	return list, nil
+}
+
+// Update modifies a subscription; req maps columns (kind, province_id, city_id, active) to
+// their new values
+func (r *prayerSubscriptionRepository) Update(ctx context.Context, id uint64, req map[string]interface{}) error {
+	var setParts []string
+	var args []interface{}
+
+	for _, column := range []string{"kind", "province_id", "city_id", "active"} {
+		if value, ok := req[column]; ok {
+			setParts = append(setParts, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	if len(setParts) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = ?")
+	args = append(args, time.Now(), id)
+
+	query := fmt.Sprintf("UPDATE prayer_subscriptions SET %s WHERE id = ?", strings.Join(setParts, ", "))
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to update prayer subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a subscription
+func (r *prayerSubscriptionRepository) Delete(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM prayer_subscriptions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete prayer subscription: %w", err)
+	}
+	return nil
+}
+
+// RecordSend stores the outcome of a message; a failure keeps the previous last_sent_at
+func (r *prayerSubscriptionRepository) RecordSend(ctx context.Context, id uint64, sentAt *time.Time, lastError *string, active bool) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE prayer_subscriptions
+		SET last_sent_at = COALESCE(?, last_sent_at), last_error = ?, active = ?, updated_at = ?
+		WHERE id = ?`,
+		sentAt, lastError, active, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record prayer subscription send: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// PushLocation is a province and city some registered device is in
+type PushLocation struct {
+	ProvinceID int
+	CityID     int
+}
+
+// PushDeviceRepository interface defines data access methods for the devices registered for
+// push notifications
+type PushDeviceRepository interface {
+	// Register stores d by its token, replacing the device registered with it if any, and
+	// returns its ID
+	Register(ctx context.Context, d models.PushDevice) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.PushDevice, error)
+	List(ctx context.Context, filter models.PushDeviceFilter) ([]models.PushDevice, error)
+	// ReminderLocations lists the locations of the devices with reminders on
+	ReminderLocations(ctx context.Context) ([]PushLocation, error)
+	MarkReminded(ctx context.Context, ids []uint64, at time.Time) error
+	DeleteByToken(ctx context.Context, token string) (int64, error)
+	DeleteByIDs(ctx context.Context, ids []uint64) error
+}
+
+// pushDeviceRepository implements PushDeviceRepository
+type pushDeviceRepository struct {
+	db *sql.DB
+}
+
+// NewPushDeviceRepository creates a new push device repository
+func NewPushDeviceRepository(db *sql.DB) PushDeviceRepository {
+	return &pushDeviceRepository{db: db}
+}
+
+const pushDeviceColumns = "id, token, platform, province_id, city_id, reminders, announcements, user_id, last_reminder_at, created_at, updated_at"
+
+func scanPushDevice(scan func(dest ...interface{}) error) (*models.PushDevice, error) {
+	var d models.PushDevice
+	if err := scan(&d.ID, &d.Token, &d.Platform, &d.ProvinceID, &d.CityID, &d.Reminders, &d.Announcements,
+		&d.UserID, &d.LastReminderAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// inList returns "(?, ?, ...)" for n values
+func inList(n int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
+
+// Register inserts or updates a device. A token registered concurrently by another request
+// is updated instead.
+func (r *pushDeviceRepository) Register(ctx context.Context, d models.PushDevice) (uint64, error) {
+	db := conn(ctx, r.db)
+	for attempt := 0; ; attempt++ {
+		var id uint64
+		err := db.QueryRowContext(ctx, "SELECT id FROM push_devices WHERE token = ?", d.Token).Scan(&id)
+		if err == nil {
+			// Moving to another city may make the current prayer due again, so the reminder
+			// state is kept; it only ever suppresses reminders already sent
+			_, err = db.ExecContext(ctx, `
+				UPDATE push_devices
+				SET platform = ?, province_id = ?, city_id = ?, reminders = ?, announcements = ?, user_id = ?, updated_at = ?
+				WHERE id = ?`,
+				d.Platform, d.ProvinceID, d.CityID, d.Reminders, d.Announcements, d.UserID, d.UpdatedAt, id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to update push device: %w", err)
+			}
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to look up push device: %w", err)
+		}
+
+		newID, err := database.InsertID(ctx, db, `
+			INSERT INTO push_devices (token, platform, province_id, city_id, reminders, announcements, user_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.Token, d.Platform, d.ProvinceID, d.CityID, d.Reminders, d.Announcements, d.UserID, d.CreatedAt, d.UpdatedAt)
+		if database.IsDuplicateKey(err) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert push device: %w", err)
+		}
+		return uint64(newID), nil
+	}
+}
+
+// GetByID retrieves a device by ID
+func (r *pushDeviceRepository) GetByID(ctx context.Context, id uint64) (*models.PushDevice, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+pushDeviceColumns+`
+		FROM push_devices
+		WHERE id = ?`,
+		id)
+
+	d, err := scanPushDevice(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan push device: %w", err)
+	}
+
+	return d, nil
+}
+
+// List retrieves the devices matching filter, oldest first
+func (r *pushDeviceRepository) List(ctx context.Context, filter models.PushDeviceFilter) ([]models.PushDevice, error) {
+	query := `
+		SELECT ` + pushDeviceColumns + `
+		FROM push_devices
+		WHERE id > ?`
+	args := []interface{}{filter.AfterID}
+	if filter.Platform != "" {
+		query += " AND platform = ?"
+		args = append(args, filter.Platform)
+	}
+	if len(filter.CityIDs) > 0 {
+		query += " AND city_id IN " + inList(len(filter.CityIDs))
+		for _, id := range filter.CityIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Reminders {
+		query += " AND reminders = ?"
+		args = append(args, true)
+	}
+	if filter.Announcements {
+		query += " AND announcements = ?"
+		args = append(args, true)
+	}
+	if filter.RemindedBefore != nil {
+		query += " AND (last_reminder_at IS NULL OR last_reminder_at < ?)"
+		args = append(args, *filter.RemindedBefore)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query push devices: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.PushDevice{}
+	for rows.Next() {
+		d, err := scanPushDevice(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan push device: %w", err)
+		}
+		list = append(list, *d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating push devices: %w", err)
+	}
+
+	return list, nil
+}
+
+// ReminderLocations lists the distinct locations of devices with reminders on
+func (r *pushDeviceRepository) ReminderLocations(ctx context.Context) ([]PushLocation, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		"SELECT DISTINCT province_id, city_id FROM push_devices WHERE reminders = ?", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query push device locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []PushLocation
+	for rows.Next() {
+		var l PushLocation
+		if err := rows.Scan(&l.ProvinceID, &l.CityID); err != nil {
+			return nil, fmt.Errorf("failed to scan push device location: %w", err)
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// MarkReminded records that the devices were reminded of the prayer at at
+func (r *pushDeviceRepository) MarkReminded(ctx context.Context, ids []uint64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{at}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE push_devices SET last_reminder_at = ? WHERE id IN "+inList(len(ids)), args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark push devices reminded: %w", err)
+	}
+	return nil
+}
+
+// DeleteByToken removes the device registered with token and reports how many were removed
+func (r *pushDeviceRepository) DeleteByToken(ctx context.Context, token string) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM push_devices WHERE token = ?", token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete push device: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteByIDs removes devices, e.g. those whose tokens stopped working
+func (r *pushDeviceRepository) DeleteByIDs(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM push_devices WHERE id IN "+inList(len(ids)), args...); err != nil {
+		return fmt.Errorf("failed to delete push devices: %w", err)
+	}
+	return nil
+}
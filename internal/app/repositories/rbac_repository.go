@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+)
+
+// RBACRepository interface defines data access methods for the RBAC configuration as a
+// whole: roles, role inheritances, the menu and role-menu links, as an export reads them
+// and an import writes them. Reads go to the primary, so an import compares with what its
+// transaction sees. All of it is the tenant's.
+type RBACRepository interface {
+	// ListRoles returns every role, the deleted ones too
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	// ListMenus returns the menu items not deleted
+	ListMenus(ctx context.Context) ([]models.Menu, error)
+	ListInheritances(ctx context.Context) ([]models.RoleInheritance, error)
+	// ListRoleMenus returns every role-menu link, the deleted ones too
+	ListRoleMenus(ctx context.Context) ([]models.RoleMenu, error)
+	CreateRole(ctx context.Context, name string, description *string, now time.Time) (uint, error)
+	// UpdateRole sets a role's description, restoring it if it was deleted
+	UpdateRole(ctx context.Context, id uint, description *string, now time.Time) error
+	CreateMenu(ctx context.Context, menu models.Menu, now time.Time) (uint, error)
+	// UpdateMenu sets a menu item's URL, icon and sort order
+	UpdateMenu(ctx context.Context, menu models.Menu, now time.Time) error
+	CreateInheritance(ctx context.Context, roleID, parentRoleID uint, now time.Time) (uint64, error)
+	DeleteInheritance(ctx context.Context, id uint64) error
+	CreateRoleMenu(ctx context.Context, roleID, menuID uint) error
+	RestoreRoleMenu(ctx context.Context, roleID, menuID uint) error
+	DeleteRoleMenu(ctx context.Context, roleID, menuID uint, deletedBy *uint64, now time.Time) error
+}
+
+// rbacRepository implements RBACRepository
+type rbacRepository struct {
+	db *sql.DB
+}
+
+// NewRBACRepository creates a new RBAC repository
+func NewRBACRepository(db *sql.DB) RBACRepository {
+	return &rbacRepository{db: db}
+}
+
+// ListRoles retrieves all roles
+func (r *rbacRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT id, name, description, deleted_at FROM roles WHERE tenant_id = ? ORDER BY id", tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+	var roles []models.Role
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// ListMenus retrieves the live menu items
+func (r *rbacRepository) ListMenus(ctx context.Context) ([]models.Menu, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT id, label, url, icon, parent_id, sort_order
+		FROM menu
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY id`,
+		tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menus: %w", err)
+	}
+	defer rows.Close()
+	var menus []models.Menu
+	for rows.Next() {
+		var m models.Menu
+		if err := rows.Scan(&m.ID, &m.Label, &m.Url, &m.Icon, &m.ParentID, &m.SortOrder); err != nil {
+			return nil, fmt.Errorf("failed to scan menu: %w", err)
+		}
+		menus = append(menus, m)
+	}
+	return menus, rows.Err()
+}
+
+// ListInheritances retrieves all role inheritances
+func (r *rbacRepository) ListInheritances(ctx context.Context) ([]models.RoleInheritance, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT id, role_id, parent_role_id FROM role_inheritances WHERE "+RoleInTenant+" ORDER BY id", tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role inheritances: %w", err)
+	}
+	defer rows.Close()
+	var inheritances []models.RoleInheritance
+	for rows.Next() {
+		var ri models.RoleInheritance
+		if err := rows.Scan(&ri.ID, &ri.RoleID, &ri.ParentRoleID); err != nil {
+			return nil, fmt.Errorf("failed to scan role inheritance: %w", err)
+		}
+		inheritances = append(inheritances, ri)
+	}
+	return inheritances, rows.Err()
+}
+
+// ListRoleMenus retrieves all role-menu links
+func (r *rbacRepository) ListRoleMenus(ctx context.Context) ([]models.RoleMenu, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT role_id, menu_id, deleted_at FROM role_menu WHERE "+RoleInTenant+" ORDER BY role_id, menu_id", tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role menus: %w", err)
+	}
+	defer rows.Close()
+	var links []models.RoleMenu
+	for rows.Next() {
+		var rm models.RoleMenu
+		if err := rows.Scan(&rm.RoleID, &rm.MenuID, &rm.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role menu: %w", err)
+		}
+		links = append(links, rm)
+	}
+	return links, rows.Err()
+}
+
+// CreateRole inserts a role
+func (r *rbacRepository) CreateRole(ctx context.Context, name string, description *string, now time.Time) (uint, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO roles (tenant_id, name, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		tenant.ID(ctx), name, description, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert role: %w", err)
+	}
+	return uint(id), nil
+}
+
+// UpdateRole updates and restores a role
+func (r *rbacRepository) UpdateRole(ctx context.Context, id uint, description *string, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE roles SET description = ?, updated_at = ?, deleted_at = NULL, deleted_by = NULL
+		WHERE id = ? AND tenant_id = ?`,
+		description, now, id, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// CreateMenu inserts a menu item
+func (r *rbacRepository) CreateMenu(ctx context.Context, menu models.Menu, now time.Time) (uint, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO menu (tenant_id, label, url, icon, parent_id, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		tenant.ID(ctx), menu.Label, menu.Url, menu.Icon, menu.ParentID, menu.SortOrder, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert menu: %w", err)
+	}
+	return uint(id), nil
+}
+
+// UpdateMenu updates a menu item
+func (r *rbacRepository) UpdateMenu(ctx context.Context, menu models.Menu, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE menu SET url = ?, icon = ?, sort_order = ?, updated_at = ?
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		menu.Url, menu.Icon, menu.SortOrder, now, menu.ID, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to update menu: %w", err)
+	}
+	return nil
+}
+
+// CreateInheritance inserts a role inheritance
+func (r *rbacRepository) CreateInheritance(ctx context.Context, roleID, parentRoleID uint, now time.Time) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO role_inheritances (role_id, parent_role_id, created_at)
+		VALUES (?, ?, ?)`,
+		roleID, parentRoleID, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert role inheritance: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// DeleteInheritance deletes a role inheritance
+func (r *rbacRepository) DeleteInheritance(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM role_inheritances WHERE id = ? AND "+RoleInTenant, id, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to delete role inheritance: %w", err)
+	}
+	return nil
+}
+
+// CreateRoleMenu inserts a role-menu link
+func (r *rbacRepository) CreateRoleMenu(ctx context.Context, roleID, menuID uint) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO role_menu (role_id, menu_id) VALUES (?, ?)", roleID, menuID); err != nil {
+		return fmt.Errorf("failed to insert role menu: %w", err)
+	}
+	return nil
+}
+
+// RestoreRoleMenu brings back a deleted role-menu link
+func (r *rbacRepository) RestoreRoleMenu(ctx context.Context, roleID, menuID uint) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE role_menu SET deleted_at = NULL, deleted_by = NULL
+		WHERE role_id = ? AND menu_id = ? AND `+RoleInTenant,
+		roleID, menuID, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to restore role menu: %w", err)
+	}
+	return nil
+}
+
+// DeleteRoleMenu soft deletes a role-menu link
+func (r *rbacRepository) DeleteRoleMenu(ctx context.Context, roleID, menuID uint, deletedBy *uint64, now time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE role_menu SET deleted_at = ?, deleted_by = ?
+		WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND `+RoleInTenant,
+		now, deletedBy, roleID, menuID, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to delete role menu: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+)
+
+// ReportScheduleRepository interface defines data access methods for report schedules. The
+// calls made for a user are scoped to the tenant of ctx; those of the report_schedules and
+// calendar_sync jobs (ListDue, Claim, Finish, ListEnabled) see every tenant.
+type ReportScheduleRepository interface {
+	Create(ctx context.Context, schedule models.ReportSchedule) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.ReportSchedule, error)
+	// List returns schedules newest first
+	List(ctx context.Context, filter models.ReportScheduleFilter) ([]models.ReportSchedule, error)
+	// Update replaces the definition of a schedule: name, report, parameters, schedule,
+	// enabled and next run
+	Update(ctx context.Context, schedule models.ReportSchedule) error
+	Delete(ctx context.Context, id uint64) error
+	// ListDue returns at most limit enabled schedules whose next run is at or before now,
+	// of owners not deleted, the most overdue first
+	ListDue(ctx context.Context, now time.Time, limit int) ([]models.ReportSchedule, error)
+	// Claim moves a due schedule's next run from due to next, reporting false when another
+	// instance, or an edit, moved it first. A run is only ever claimed by one caller.
+	Claim(ctx context.Context, id uint64, due, next time.Time) (bool, error)
+	// Finish records the outcome of a run
+	Finish(ctx context.Context, id uint64, status string, errMessage *string, attachmentID *uint64, at time.Time) error
+	// ListEnabled returns every enabled schedule of owners not deleted
+	ListEnabled(ctx context.Context) ([]models.ReportSchedule, error)
+}
+
+// reportScheduleRepository implements ReportScheduleRepository
+type reportScheduleRepository struct {
+	db *sql.DB
+}
+
+// NewReportScheduleRepository creates a new report schedule repository
+func NewReportScheduleRepository(db *sql.DB) ReportScheduleRepository {
+	return &reportScheduleRepository{db: db}
+}
+
+const reportScheduleColumns = "s.id, s.tenant_id, s.owner_id, s.name, s.report_path, s.output_format, s.parameters, s.schedule, s.enabled, s.next_run_at, s.last_run_at, s.last_status, s.last_error, s.last_attachment_id, s.created_at, s.updated_at"
+
+func scanReportSchedule(scan func(dest ...interface{}) error) (*models.ReportSchedule, error) {
+	var s models.ReportSchedule
+	var params []byte
+	if err := scan(&s.ID, &s.TenantID, &s.OwnerID, &s.Name, &s.ReportPath, &s.OutputFormat, &params, &s.Schedule, &s.Enabled,
+		&s.NextRunAt, &s.LastRunAt, &s.LastStatus, &s.LastError, &s.LastAttachmentID, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &s.Parameters); err != nil {
+			return nil, fmt.Errorf("failed to decode the parameters of report schedule %d: %w", s.ID, err)
+		}
+	}
+	return &s, nil
+}
+
+// scanReportSchedules reads every row of rows
+func scanReportSchedules(rows *sql.Rows) ([]models.ReportSchedule, error) {
+	defer rows.Close()
+	schedules := []models.ReportSchedule{}
+	for rows.Next() {
+		s, err := scanReportSchedule(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report schedule: %w", err)
+		}
+		schedules = append(schedules, *s)
+	}
+	return schedules, rows.Err()
+}
+
+// Create inserts a new schedule into the tenant of ctx
+func (r *reportScheduleRepository) Create(ctx context.Context, s models.ReportSchedule) (uint64, error) {
+	params, err := json.Marshal(s.Parameters)
+	if err != nil {
+		return 0, err
+	}
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO report_schedules (tenant_id, owner_id, name, report_path, output_format, parameters, schedule, enabled, next_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tenant.ID(ctx), s.OwnerID, s.Name, s.ReportPath, s.OutputFormat, params, s.Schedule, s.Enabled, s.NextRunAt, s.CreatedAt, s.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert report schedule: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetByID retrieves a schedule of the tenant by ID
+func (r *reportScheduleRepository) GetByID(ctx context.Context, id uint64) (*models.ReportSchedule, error) {
+	s, err := scanReportSchedule(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+reportScheduleColumns+`
+		FROM report_schedules s
+		WHERE s.id = ? AND s.tenant_id = ?`,
+		id, tenant.ID(ctx)).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan report schedule: %w", err)
+	}
+	return s, nil
+}
+
+// List retrieves a page of the tenant's schedules
+func (r *reportScheduleRepository) List(ctx context.Context, filter models.ReportScheduleFilter) ([]models.ReportSchedule, error) {
+	query := "SELECT " + reportScheduleColumns + " FROM report_schedules s WHERE s.tenant_id = ?"
+	args := []interface{}{tenant.ID(ctx)}
+	if filter.OwnerID > 0 {
+		query += " AND s.owner_id = ?"
+		args = append(args, filter.OwnerID)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND s.id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY s.id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report schedules: %w", err)
+	}
+	return scanReportSchedules(rows)
+}
+
+// Update rewrites a schedule of the tenant
+func (r *reportScheduleRepository) Update(ctx context.Context, s models.ReportSchedule) error {
+	params, err := json.Marshal(s.Parameters)
+	if err != nil {
+		return err
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE report_schedules
+		SET name = ?, report_path = ?, output_format = ?, parameters = ?, schedule = ?, enabled = ?, next_run_at = ?, updated_at = ?
+		WHERE id = ? AND tenant_id = ?`,
+		s.Name, s.ReportPath, s.OutputFormat, params, s.Schedule, s.Enabled, s.NextRunAt, s.UpdatedAt, s.ID, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to update report schedule: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a schedule of the tenant; its calendar events go with the next sync
+func (r *reportScheduleRepository) Delete(ctx context.Context, id uint64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM report_schedules WHERE id = ? AND tenant_id = ?", id, tenant.ID(ctx)); err != nil {
+		return fmt.Errorf("failed to delete report schedule: %w", err)
+	}
+	return nil
+}
+
+// ListDue retrieves the schedules due to run
+func (r *reportScheduleRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]models.ReportSchedule, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+reportScheduleColumns+`
+		FROM report_schedules s
+		JOIN users u ON u.id = s.owner_id AND u.deleted_at IS NULL
+		WHERE s.enabled = ? AND s.next_run_at <= ?
+		ORDER BY s.next_run_at, s.id
+		LIMIT ?`,
+		true, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due report schedules: %w", err)
+	}
+	return scanReportSchedules(rows)
+}
+
+// Claim takes a due run. Instances may race for it; the conditional UPDATE lets one win.
+func (r *reportScheduleRepository) Claim(ctx context.Context, id uint64, due, next time.Time) (bool, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE report_schedules
+		SET next_run_at = ?
+		WHERE id = ? AND enabled = ? AND next_run_at = ?`,
+		next, id, true, due)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim report schedule: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// Finish records the outcome of a run
+func (r *reportScheduleRepository) Finish(ctx context.Context, id uint64, status string, errMessage *string, attachmentID *uint64, at time.Time) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE report_schedules
+		SET last_run_at = ?, last_status = ?, last_error = ?, last_attachment_id = COALESCE(?, last_attachment_id)
+		WHERE id = ?`,
+		at, status, errMessage, attachmentID, id); err != nil {
+		return fmt.Errorf("failed to update report schedule: %w", err)
+	}
+	return nil
+}
+
+// ListEnabled retrieves the schedules whose runs are published
+func (r *reportScheduleRepository) ListEnabled(ctx context.Context) ([]models.ReportSchedule, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+reportScheduleColumns+`
+		FROM report_schedules s
+		JOIN users u ON u.id = s.owner_id AND u.deleted_at IS NULL
+		WHERE s.enabled = ?
+		ORDER BY s.id`,
+		true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report schedules: %w", err)
+	}
+	return scanReportSchedules(rows)
+}
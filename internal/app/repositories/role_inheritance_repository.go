@@ -1,20 +1,23 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
 )
 
 // RoleInheritanceRepository interface defines data access methods for role inheritances
 type RoleInheritanceRepository interface {
-	GetAll() ([]models.RoleInheritance, error)
-	GetByID(id uint64) (*models.RoleInheritance, error)
-	Create(req models.RoleInheritance) (uint64, error)
-	Update(id uint64, req map[string]interface{}) error
-	Delete(id uint64) error
+	GetAll(ctx context.Context) ([]models.RoleInheritance, error)
+	GetByID(ctx context.Context, id uint64) (*models.RoleInheritance, error)
+	Create(ctx context.Context, req models.RoleInheritance) (uint64, error)
+	Update(ctx context.Context, id uint64, req map[string]interface{}) error
+	Delete(ctx context.Context, id uint64) error
 }
 
 // roleInheritanceRepository implements RoleInheritanceRepository
@@ -27,12 +30,14 @@ func NewRoleInheritanceRepository(db *sql.DB) RoleInheritanceRepository {
 	return &roleInheritanceRepository{db: db}
 }
 
-// GetAll retrieves all role inheritances
-func (r *roleInheritanceRepository) GetAll() ([]models.RoleInheritance, error) {
-	rows, err := r.db.Query(`
+// GetAll retrieves all role inheritances of the tenant
+func (r *roleInheritanceRepository) GetAll(ctx context.Context) ([]models.RoleInheritance, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT id, role_id, parent_role_id, created_at
 		FROM role_inheritances
-		ORDER BY created_at DESC`)
+		WHERE `+RoleInTenant+`
+		ORDER BY created_at DESC`,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query role inheritances: %w", err)
 	}
@@ -55,13 +60,13 @@ func (r *roleInheritanceRepository) GetAll() ([]models.RoleInheritance, error) {
 }
 
 // GetByID retrieves a role inheritance by ID
-func (r *roleInheritanceRepository) GetByID(id uint64) (*models.RoleInheritance, error) {
+func (r *roleInheritanceRepository) GetByID(ctx context.Context, id uint64) (*models.RoleInheritance, error) {
 	var ri models.RoleInheritance
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT id, role_id, parent_role_id, created_at
 		FROM role_inheritances
-		WHERE id = ?`,
-		id)
+		WHERE id = ? AND `+RoleInTenant,
+		id, tenant.ID(ctx))
 
 	err := row.Scan(&ri.ID, &ri.RoleID, &ri.ParentRoleID, &ri.CreatedAt)
 	if err == sql.ErrNoRows {
@@ -74,9 +79,15 @@ func (r *roleInheritanceRepository) GetByID(id uint64) (*models.RoleInheritance,
 	return &ri, nil
 }
 
-// Create inserts a new role inheritance
-func (r *roleInheritanceRepository) Create(req models.RoleInheritance) (uint64, error) {
-	result, err := r.db.Exec(`
+// Create inserts a new role inheritance; both roles must belong to the tenant
+func (r *roleInheritanceRepository) Create(ctx context.Context, req models.RoleInheritance) (uint64, error) {
+	db := conn(ctx, r.db)
+	for _, roleID := range []uint{req.RoleID, req.ParentRoleID} {
+		if err := requireInTenant(ctx, db, "roles", uint64(roleID), "Role"); err != nil {
+			return 0, err
+		}
+	}
+	id, err := database.InsertID(ctx, db, `
 		INSERT INTO role_inheritances (role_id, parent_role_id, created_at)
 		VALUES (?, ?, ?)`,
 		req.RoleID, req.ParentRoleID, req.CreatedAt)
@@ -84,42 +95,42 @@ func (r *roleInheritanceRepository) Create(req models.RoleInheritance) (uint64,
 		return 0, fmt.Errorf("failed to insert role inheritance: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	return uint64(id), nil
 }
 
-// Update modifies an existing role inheritance with dynamic fields
-func (r *roleInheritanceRepository) Update(id uint64, req map[string]interface{}) error {
+// Update modifies an existing role inheritance of the tenant with dynamic fields; new roles
+// must belong to it too
+func (r *roleInheritanceRepository) Update(ctx context.Context, id uint64, req map[string]interface{}) error {
+	db := conn(ctx, r.db)
 	var setParts []string
 	var args []interface{}
 
-	if roleID, ok := req["role_id"].(uint); ok && roleID > 0 {
-		setParts = append(setParts, "role_id = ?")
+	for _, column := range []string{"role_id", "parent_role_id"} {
+		roleID, ok := req[column].(uint)
+		if !ok || roleID == 0 {
+			continue
+		}
+		if err := requireInTenant(ctx, db, "roles", uint64(roleID), "Role"); err != nil {
+			return err
+		}
+		setParts = append(setParts, column+" = ?")
 		args = append(args, roleID)
 	}
-	if parentRoleID, ok := req["parent_role_id"].(uint); ok && parentRoleID > 0 {
-		setParts = append(setParts, "parent_role_id = ?")
-		args = append(args, parentRoleID)
-	}
 
 	if len(setParts) == 0 {
 		return fmt.Errorf("no fields to update")
 	}
 
 	setClause := strings.Join(setParts, ", ")
-	query := fmt.Sprintf("UPDATE role_inheritances SET %s WHERE id = ?", setClause)
-	args = append(args, id)
+	query := fmt.Sprintf("UPDATE role_inheritances SET %s WHERE id = ? AND %s", setClause, RoleInTenant)
+	args = append(args, id, tenant.ID(ctx))
 
-	_, err := r.db.Exec(query, args...)
+	_, err := db.ExecContext(ctx, query, args...)
 	return err
 }
 
 // Delete removes a role inheritance (hard delete)
-func (r *roleInheritanceRepository) Delete(id uint64) error {
-	_, err := r.db.Exec(`DELETE FROM role_inheritances WHERE id = ?`, id)
+func (r *roleInheritanceRepository) Delete(ctx context.Context, id uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM role_inheritances WHERE id = ? AND `+RoleInTenant, id, tenant.ID(ctx))
 	return err
 }
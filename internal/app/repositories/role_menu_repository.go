@@ -1,18 +1,20 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/tenant"
 )
 
 // RoleMenuRepository interface defines data access methods for role menus
 type RoleMenuRepository interface {
-	GetAll() ([]models.RoleMenu, error)
-	GetByRoleAndMenu(roleID, menuID uint) (*models.RoleMenu, error)
-	Create(req models.RoleMenu) error
-	Delete(roleID, menuID uint, deletedBy *uint64) error
+	GetAll(ctx context.Context) ([]models.RoleMenu, error)
+	GetByRoleAndMenu(ctx context.Context, roleID, menuID uint) (*models.RoleMenu, error)
+	Create(ctx context.Context, req models.RoleMenu) error
+	Delete(ctx context.Context, roleID, menuID uint, deletedBy *uint64) error
 }
 
 // roleMenuRepository implements RoleMenuRepository
@@ -25,12 +27,13 @@ func NewRoleMenuRepository(db *sql.DB) RoleMenuRepository {
 	return &roleMenuRepository{db: db}
 }
 
-// GetAll retrieves all active role-menu assignments
-func (r *roleMenuRepository) GetAll() ([]models.RoleMenu, error) {
-	rows, err := r.db.Query(`
+// GetAll retrieves all active role-menu assignments of the tenant
+func (r *roleMenuRepository) GetAll(ctx context.Context) ([]models.RoleMenu, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT role_id, menu_id, deleted_at, deleted_by
 		FROM role_menu
-		WHERE deleted_at IS NULL`)
+		WHERE deleted_at IS NULL AND `+RoleInTenant,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query role menus: %w", err)
 	}
@@ -53,13 +56,13 @@ func (r *roleMenuRepository) GetAll() ([]models.RoleMenu, error) {
 }
 
 // GetByRoleAndMenu retrieves a role-menu assignment by role and menu IDs
-func (r *roleMenuRepository) GetByRoleAndMenu(roleID, menuID uint) (*models.RoleMenu, error) {
+func (r *roleMenuRepository) GetByRoleAndMenu(ctx context.Context, roleID, menuID uint) (*models.RoleMenu, error) {
 	var rm models.RoleMenu
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT role_id, menu_id, deleted_at, deleted_by
 		FROM role_menu
-		WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL`,
-		roleID, menuID)
+		WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND `+RoleInTenant,
+		roleID, menuID, tenant.ID(ctx))
 
 	err := row.Scan(&rm.RoleID, &rm.MenuID, &rm.DeletedAt, &rm.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -72,9 +75,17 @@ func (r *roleMenuRepository) GetByRoleAndMenu(roleID, menuID uint) (*models.Role
 	return &rm, nil
 }
 
-// Create inserts a new role-menu assignment
-func (r *roleMenuRepository) Create(req models.RoleMenu) error {
-	_, err := r.db.Exec(`
+// Create inserts a new role-menu assignment; the role and the menu item must belong to the
+// tenant
+func (r *roleMenuRepository) Create(ctx context.Context, req models.RoleMenu) error {
+	db := conn(ctx, r.db)
+	if err := requireInTenant(ctx, db, "roles", uint64(req.RoleID), "Role"); err != nil {
+		return err
+	}
+	if err := requireInTenant(ctx, db, "menu", uint64(req.MenuID), "Menu"); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO role_menu (role_id, menu_id, deleted_at, deleted_by)
 		VALUES (?, ?, ?, ?)`,
 		req.RoleID, req.MenuID, req.DeletedAt, req.DeletedBy)
@@ -82,10 +93,10 @@ func (r *roleMenuRepository) Create(req models.RoleMenu) error {
 }
 
 // Delete performs a soft delete
-func (r *roleMenuRepository) Delete(roleID, menuID uint, deletedBy *uint64) error {
-	_, err := r.db.Exec(`
+func (r *roleMenuRepository) Delete(ctx context.Context, roleID, menuID uint, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE role_menu SET deleted_at = NOW(), deleted_by = ?
-		WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL`,
-		deletedBy, roleID, menuID)
+		WHERE role_id = ? AND menu_id = ? AND deleted_at IS NULL AND `+RoleInTenant,
+		deletedBy, roleID, menuID, tenant.ID(ctx))
 	return err
 }
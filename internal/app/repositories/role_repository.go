@@ -1,22 +1,31 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 )
 
 // RoleRepository interface defines data access methods for roles
 type RoleRepository interface {
-	GetAll() ([]models.Role, error)
-	GetByID(id uint) (*models.Role, error)
-	GetByName(name string) (*models.Role, error)
-	Create(req models.Role) (uint, error)
-	Update(id uint, req map[string]interface{}) error
-	Delete(id uint, deletedBy *uint64) error
+	GetAll(ctx context.Context, sort []utils.SortTerm) ([]models.Role, error)
+	GetByID(ctx context.Context, id uint) (*models.Role, error)
+	GetByName(ctx context.Context, name string) (*models.Role, error)
+	Create(ctx context.Context, req models.Role) (uint, error)
+	Update(ctx context.Context, id uint, req map[string]interface{}) error
+	Delete(ctx context.Context, id uint, deletedBy *uint64) error
+	// DeletedStates locks the roles among ids until the transaction ends and returns their
+	// soft delete state; IDs without a row are missing from the map
+	DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error)
+	DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error
+	RestoreMany(ctx context.Context, ids []uint64, at time.Time) error
 }
 
 // roleRepository implements RoleRepository
@@ -29,13 +38,32 @@ func NewRoleRepository(db *sql.DB) RoleRepository {
 	return &roleRepository{db: db}
 }
 
-// GetAll retrieves all active roles
-func (r *roleRepository) GetAll() ([]models.Role, error) {
-	rows, err := r.db.Query(`
+// RoleListSpec whitelists the sort and fields parameters of the role list
+var RoleListSpec = utils.ListSpec{
+	Sort: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	Fields:   []string{"id", "name", "description", "created_at", "updated_at", "deleted_at", "deleted_by"},
+	Tiebreak: "id DESC",
+}
+
+// GetAll retrieves all active roles, newest first unless sort (checked against
+// RoleListSpec) says otherwise
+func (r *roleRepository) GetAll(ctx context.Context, sort []utils.SortTerm) ([]models.Role, error) {
+	orderBy, err := RoleListSpec.OrderBy(sort, "created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT id, name, description, created_at, updated_at, deleted_at, deleted_by
 		FROM roles
-		WHERE deleted_at IS NULL
-		ORDER BY created_at DESC`)
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY `+orderBy,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query roles: %w", err)
 	}
@@ -58,13 +86,13 @@ func (r *roleRepository) GetAll() ([]models.Role, error) {
 }
 
 // GetByID retrieves a role by ID
-func (r *roleRepository) GetByID(id uint) (*models.Role, error) {
+func (r *roleRepository) GetByID(ctx context.Context, id uint) (*models.Role, error) {
 	var role models.Role
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT id, name, description, created_at, updated_at, deleted_at, deleted_by
 		FROM roles
-		WHERE id = ? AND deleted_at IS NULL`,
-		id)
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tenant.ID(ctx))
 
 	err := row.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &role.DeletedAt, &role.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -78,13 +106,13 @@ func (r *roleRepository) GetByID(id uint) (*models.Role, error) {
 }
 
 // GetByName retrieves a role by name
-func (r *roleRepository) GetByName(name string) (*models.Role, error) {
+func (r *roleRepository) GetByName(ctx context.Context, name string) (*models.Role, error) {
 	var role models.Role
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT id, name, description, created_at, updated_at, deleted_at, deleted_by
 		FROM roles
-		WHERE name = ? AND deleted_at IS NULL`,
-		name)
+		WHERE name = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		name, tenant.ID(ctx))
 
 	err := row.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &role.UpdatedAt, &role.DeletedAt, &role.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -97,26 +125,26 @@ func (r *roleRepository) GetByName(name string) (*models.Role, error) {
 	return &role, nil
 }
 
-// Create inserts a new role
-func (r *roleRepository) Create(req models.Role) (uint, error) {
-	result, err := r.db.Exec(`
-		INSERT INTO roles (name, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?)`,
-		req.Name, req.Description, req.CreatedAt, req.UpdatedAt)
+// Create inserts a new role into the tenant
+func (r *roleRepository) Create(ctx context.Context, req models.Role) (uint, error) {
+	roleID, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO roles (tenant_id, name, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		tenant.ID(ctx), req.Name, req.Description, req.CreatedAt, req.UpdatedAt)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert role: %w", err)
 	}
 
-	roleID, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	return uint(roleID), nil
 }
 
-// Update modifies an existing role with dynamic fields
-func (r *roleRepository) Update(id uint, req map[string]interface{}) error {
+// Update modifies an existing role with dynamic fields. A key mapped to nil sets its column
+// to NULL, which only description allows.
+func (r *roleRepository) Update(ctx context.Context, id uint, req map[string]interface{}) error {
+	if v, ok := req["name"]; ok && v == nil {
+		return fmt.Errorf("name cannot be null")
+	}
+
 	var setParts []string
 	var args []interface{}
 
@@ -137,18 +165,33 @@ func (r *roleRepository) Update(id uint, req map[string]interface{}) error {
 	args = append(args, time.Now())
 
 	setClause := strings.Join(setParts, ", ")
-	query := fmt.Sprintf("UPDATE roles SET %s WHERE id = ? AND deleted_at IS NULL", setClause)
-	args = append(args, id)
+	query := fmt.Sprintf("UPDATE roles SET %s WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL", setClause)
+	args = append(args, id, tenant.ID(ctx))
 
-	_, err := r.db.Exec(query, args...)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
 	return err
 }
 
 // Delete performs a soft delete
-func (r *roleRepository) Delete(id uint, deletedBy *uint64) error {
-	_, err := r.db.Exec(`
+func (r *roleRepository) Delete(ctx context.Context, id uint, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE roles SET deleted_at = ?, updated_at = ?, deleted_by = ?
-		WHERE id = ? AND deleted_at IS NULL`,
-		time.Now(), time.Now(), deletedBy, id)
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		time.Now(), time.Now(), deletedBy, id, tenant.ID(ctx))
 	return err
 }
+
+// DeletedStates reads and locks the soft delete state of roles
+func (r *roleRepository) DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error) {
+	return softDeleteStates(ctx, conn(ctx, r.db), "roles", ids)
+}
+
+// DeleteMany soft deletes roles
+func (r *roleRepository) DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error {
+	return softDeleteMany(ctx, conn(ctx, r.db), "roles", ids, deletedBy, at)
+}
+
+// RestoreMany restores soft-deleted roles
+func (r *roleRepository) RestoreMany(ctx context.Context, ids []uint64, at time.Time) error {
+	return restoreMany(ctx, conn(ctx, r.db), "roles", ids, at)
+}
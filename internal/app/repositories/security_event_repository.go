@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// SecurityEventRepository interface defines data access methods for security events
+type SecurityEventRepository interface {
+	Create(ctx context.Context, e models.SecurityEvent) (uint64, error)
+	GetByID(ctx context.Context, id uint64) (*models.SecurityEvent, error)
+	List(ctx context.Context, filter models.SecurityEventFilter) ([]models.SecurityEvent, error)
+	// Acknowledge closes an open event, reporting false when it was already closed
+	Acknowledge(ctx context.Context, id, by uint64, note *string) (bool, error)
+}
+
+// securityEventRepository implements SecurityEventRepository
+type securityEventRepository struct {
+	db *sql.DB
+}
+
+// NewSecurityEventRepository creates a new security event repository
+func NewSecurityEventRepository(db *sql.DB) SecurityEventRepository {
+	return &securityEventRepository{db: db}
+}
+
+const securityEventColumns = "id, type, severity, message, ip_address, user_id, details, created_at, acknowledged_at, acknowledged_by, acknowledgement_note"
+
+func scanSecurityEvent(scan func(dest ...interface{}) error) (*models.SecurityEvent, error) {
+	var e models.SecurityEvent
+	var details []byte
+	if err := scan(&e.ID, &e.Type, &e.Severity, &e.Message, &e.IPAddress, &e.UserID, &details, &e.CreatedAt,
+		&e.AcknowledgedAt, &e.AcknowledgedBy, &e.AcknowledgementNote); err != nil {
+		return nil, err
+	}
+	e.Details = details
+	return &e, nil
+}
+
+// Create records a security event
+func (r *securityEventRepository) Create(ctx context.Context, e models.SecurityEvent) (uint64, error) {
+	var details interface{}
+	if len(e.Details) > 0 {
+		details = []byte(e.Details)
+	}
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO security_events (type, severity, message, ip_address, user_id, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.Type, e.Severity, e.Message, e.IPAddress, e.UserID, details, e.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert security event: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// GetByID retrieves a security event by ID
+func (r *securityEventRepository) GetByID(ctx context.Context, id uint64) (*models.SecurityEvent, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+securityEventColumns+`
+		FROM security_events
+		WHERE id = ?`,
+		id)
+
+	e, err := scanSecurityEvent(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan security event: %w", err)
+	}
+
+	return e, nil
+}
+
+// List retrieves the security events matching filter, newest first
+func (r *securityEventRepository) List(ctx context.Context, filter models.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	query := `
+		SELECT ` + securityEventColumns + `
+		FROM security_events
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.Type != "" {
+		query += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.Severity != "" {
+		query += " AND severity = ?"
+		args = append(args, filter.Severity)
+	}
+	if filter.Acknowledged != nil {
+		if *filter.Acknowledged {
+			query += " AND acknowledged_at IS NOT NULL"
+		} else {
+			query += " AND acknowledged_at IS NULL"
+		}
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query security events: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.SecurityEvent{}
+	for rows.Next() {
+		e, err := scanSecurityEvent(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan security event: %w", err)
+		}
+		list = append(list, *e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating security events: %w", err)
+	}
+
+	return list, nil
+}
+
+// Acknowledge closes an open event
+func (r *securityEventRepository) Acknowledge(ctx context.Context, id, by uint64, note *string) (bool, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE security_events SET acknowledged_at = ?, acknowledged_by = ?, acknowledgement_note = ?
+		WHERE id = ? AND acknowledged_at IS NULL`,
+		time.Now(), by, note, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to acknowledge security event: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// SMSMessageRepository interface defines data access methods for the log of texts sent
+type SMSMessageRepository interface {
+	Create(ctx context.Context, m models.SMSMessage) (uint64, error)
+	GetByProviderID(ctx context.Context, provider, providerMessageID string) (*models.SMSMessage, error)
+	UpdateStatus(ctx context.Context, id uint64, status string, errMsg *string, at time.Time) error
+	List(ctx context.Context, filter models.SMSMessageFilter) ([]models.SMSMessage, error)
+}
+
+// smsMessageRepository implements SMSMessageRepository
+type smsMessageRepository struct {
+	db *sql.DB
+}
+
+// NewSMSMessageRepository creates a new SMS message repository
+func NewSMSMessageRepository(db *sql.DB) SMSMessageRepository {
+	return &smsMessageRepository{db: db}
+}
+
+const smsMessageColumns = "id, provider, provider_message_id, phone, purpose, user_id, status, error, created_at, updated_at"
+
+func scanSMSMessage(scan func(dest ...interface{}) error) (*models.SMSMessage, error) {
+	var m models.SMSMessage
+	if err := scan(&m.ID, &m.Provider, &m.ProviderMessageID, &m.Phone, &m.Purpose, &m.UserID, &m.Status, &m.Error,
+		&m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Create records a text once the provider answered
+func (r *smsMessageRepository) Create(ctx context.Context, m models.SMSMessage) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO sms_messages (provider, provider_message_id, phone, purpose, user_id, status, error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Provider, m.ProviderMessageID, m.Phone, m.Purpose, m.UserID, m.Status, m.Error, m.CreatedAt, m.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert SMS message: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// GetByProviderID retrieves a text by the ID the provider gave it
+func (r *smsMessageRepository) GetByProviderID(ctx context.Context, provider, providerMessageID string) (*models.SMSMessage, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+smsMessageColumns+`
+		FROM sms_messages
+		WHERE provider = ? AND provider_message_id = ?`,
+		provider, providerMessageID)
+
+	m, err := scanSMSMessage(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SMS message: %w", err)
+	}
+	return m, nil
+}
+
+// UpdateStatus records a delivery report
+func (r *smsMessageRepository) UpdateStatus(ctx context.Context, id uint64, status string, errMsg *string, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE sms_messages
+		SET status = ?, error = ?, updated_at = ?
+		WHERE id = ?`,
+		status, errMsg, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to update SMS message: %w", err)
+	}
+	return nil
+}
+
+// List retrieves the texts matching filter, newest first
+func (r *smsMessageRepository) List(ctx context.Context, filter models.SMSMessageFilter) ([]models.SMSMessage, error) {
+	query := `
+		SELECT ` + smsMessageColumns + `
+		FROM sms_messages
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if filter.Purpose != "" {
+		query += " AND purpose = ?"
+		args = append(args, filter.Purpose)
+	}
+	if filter.Phone != "" {
+		query += " AND phone = ?"
+		args = append(args, filter.Phone)
+	}
+	if filter.UserID > 0 {
+		query += " AND user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	if filter.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SMS messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.SMSMessage{}
+	for rows.Next() {
+		m, err := scanSMSMessage(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SMS message: %w", err)
+		}
+		messages = append(messages, *m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating SMS messages: %w", err)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/tenant"
+)
+
+// The bulk soft delete and restore of the users, roles and menu tables, which share their
+// deleted_at and deleted_by columns, and their tenant_id: rows of other tenants are left
+// alone. Run them in a transaction: softDeleteStates locks the rows it reads until it ends.
+
+// uint64Args converts ids to query arguments
+func uint64Args(ids []uint64) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// softDeleteStates locks the rows of table among ids and returns their states; IDs without
+// a row in the tenant are missing from the map
+func softDeleteStates(ctx context.Context, db DBTX, table string, ids []uint64) (map[uint64]models.SoftDeleteState, error) {
+	states := make(map[uint64]models.SoftDeleteState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+	args := append([]interface{}{tenant.ID(ctx)}, uint64Args(ids)...)
+	rows, err := db.QueryContext(ctx, "SELECT id, deleted_at, deleted_by FROM "+table+" WHERE tenant_id = ? AND id IN "+inList(len(ids))+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint64
+		var state models.SoftDeleteState
+		if err := rows.Scan(&id, &state.DeletedAt, &state.DeletedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		states[id] = state
+	}
+	return states, rows.Err()
+}
+
+// softDeleteMany soft deletes the live rows of table among ids
+func softDeleteMany(ctx context.Context, db DBTX, table string, ids []uint64, deletedBy *uint64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := append([]interface{}{at, at, deletedBy, tenant.ID(ctx)}, uint64Args(ids)...)
+	if _, err := db.ExecContext(ctx, "UPDATE "+table+" SET deleted_at = ?, updated_at = ?, deleted_by = ? WHERE tenant_id = ? AND id IN "+inList(len(ids))+" AND deleted_at IS NULL", args...); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", table, err)
+	}
+	return nil
+}
+
+// restoreMany brings back the soft-deleted rows of table among ids
+func restoreMany(ctx context.Context, db DBTX, table string, ids []uint64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := append([]interface{}{at, tenant.ID(ctx)}, uint64Args(ids)...)
+	if _, err := db.ExecContext(ctx, "UPDATE "+table+" SET deleted_at = NULL, deleted_by = NULL, updated_at = ? WHERE tenant_id = ? AND id IN "+inList(len(ids))+" AND deleted_at IS NOT NULL", args...); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", table, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+)
+
+// TenantRepository interface defines data access methods for tenants. Tenants are not
+// scoped to the tenant of ctx; only the default tenant manages them.
+type TenantRepository interface {
+	List(ctx context.Context) ([]models.Tenant, error)
+	GetByID(ctx context.Context, id uint) (*models.Tenant, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	Create(ctx context.Context, t models.Tenant) (uint, error)
+	// Update renames the tenant and sets its status
+	Update(ctx context.Context, t models.Tenant) error
+	// Settings retrieves the tenant's settings by name
+	Settings(ctx context.Context, id uint) (map[string]string, error)
+	// ReplaceSettings replaces all of the tenant's settings with settings
+	ReplaceSettings(ctx context.Context, id uint, settings map[string]string) error
+}
+
+// tenantRepository implements TenantRepository
+type tenantRepository struct {
+	db *sql.DB
+}
+
+// NewTenantRepository creates a new tenant repository
+func NewTenantRepository(db *sql.DB) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+const tenantColumns = "id, slug, name, status, created_at, updated_at"
+
+// scanTenant reads a row of tenantColumns
+func scanTenant(scan func(dest ...interface{}) error) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := scan(&t.ID, &t.Slug, &t.Name, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// List retrieves every tenant, the default one first
+func (r *tenantRepository) List(ctx context.Context) ([]models.Tenant, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, "SELECT "+tenantColumns+" FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.Tenant{}
+	for rows.Next() {
+		t, err := scanTenant(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// GetByID retrieves a tenant by ID
+func (r *tenantRepository) GetByID(ctx context.Context, id uint) (*models.Tenant, error) {
+	t, err := scanTenant(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = ?", id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tenant: %w", err)
+	}
+	return t, nil
+}
+
+// GetBySlug retrieves a tenant by slug
+func (r *tenantRepository) GetBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	t, err := scanTenant(conn(ctx, r.db).QueryRowContext(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE slug = ?", slug).Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan tenant: %w", err)
+	}
+	return t, nil
+}
+
+// Create inserts a new tenant
+func (r *tenantRepository) Create(ctx context.Context, t models.Tenant) (uint, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO tenants (slug, name, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		t.Slug, t.Name, t.Status, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert tenant: %w", err)
+	}
+	return uint(id), nil
+}
+
+// Update renames the tenant and sets its status
+func (r *tenantRepository) Update(ctx context.Context, t models.Tenant) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE tenants SET name = ?, status = ?, updated_at = ? WHERE id = ?",
+		t.Name, t.Status, time.Now(), t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
+	return nil
+}
+
+// Settings retrieves the tenant's settings by name
+func (r *tenantRepository) Settings(ctx context.Context, id uint) (map[string]string, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, "SELECT name, value FROM tenant_settings WHERE tenant_id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenant settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant setting: %w", err)
+		}
+		settings[name] = value
+	}
+	return settings, rows.Err()
+}
+
+// ReplaceSettings deletes the tenant's settings and inserts settings; run it in a
+// transaction so readers never see them half replaced
+func (r *tenantRepository) ReplaceSettings(ctx context.Context, id uint, settings map[string]string) error {
+	db := conn(ctx, r.db)
+	if _, err := db.ExecContext(ctx, "DELETE FROM tenant_settings WHERE tenant_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete tenant settings: %w", err)
+	}
+	now := time.Now()
+	for name, value := range settings {
+		_, err := db.ExecContext(ctx, "INSERT INTO tenant_settings (tenant_id, name, value, updated_at) VALUES (?, ?, ?, ?)",
+			id, name, value, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert tenant setting %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+)
+
+// Users, roles and menu items carry the tenant they belong to; the tables linking them
+// (user_roles, user_menu, role_menu, role_inheritances) belong to the tenant of the rows they
+// link, so their queries are scoped through one end of the link.
+const (
+	// UserInTenant scopes a query on a table with a user_id column to one tenant
+	UserInTenant = "user_id IN (SELECT id FROM users WHERE tenant_id = ?)"
+	// RoleInTenant scopes a query on a table with a role_id column to one tenant
+	RoleInTenant = "role_id IN (SELECT id FROM roles WHERE tenant_id = ?)"
+)
+
+// tenantTables are the tables InTenant looks rows up in
+var tenantTables = map[string]bool{"users": true, "roles": true, "menu": true}
+
+// InTenant reports whether the row id of table (users, roles or menu) belongs to the tenant
+// of ctx, deleted or not. Links are only made between rows of one tenant.
+func InTenant(ctx context.Context, db *sql.DB, table string, id uint64) (bool, error) {
+	return inTenant(ctx, conn(ctx, db), table, id)
+}
+
+// inTenant is InTenant on a connection or transaction
+func inTenant(ctx context.Context, db DBTX, table string, id uint64) (bool, error) {
+	if !tenantTables[table] {
+		return false, fmt.Errorf("%s has no tenant", table)
+	}
+	var n int
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE id = ? AND tenant_id = ?", id, tenant.ID(ctx)).Scan(&n)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %s: %w", table, err)
+	}
+	return true, nil
+}
+
+// requireInTenant returns a NotFound error naming resource unless the row id of table
+// belongs to the tenant of ctx; to another tenant the row does not exist
+func requireInTenant(ctx context.Context, db DBTX, table string, id uint64, resource string) error {
+	ok, err := inTenant(ctx, db, table, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return utils.NewNotFoundError(resource)
+	}
+	return nil
+}
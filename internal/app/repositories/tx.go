@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"adminbe/internal/pkg/database"
+)
+
+// DBTX is the query surface shared by *sql.DB and *sql.Tx
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// TxManager runs a unit of work in a single database transaction
+type TxManager interface {
+	// WithinTx runs fn in a transaction carried by ctx. Repository calls made with that ctx
+	// join the transaction. It commits when fn returns nil and rolls back on error or panic.
+	// Nested calls reuse the outer transaction.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// txKey is the context key holding the active *sql.Tx
+type txKey struct{}
+
+// txHooksKey is the context key holding the functions to run once the active transaction commits
+type txHooksKey struct{}
+
+// AfterCommit runs fn once the transaction carried by ctx commits, or straight away when
+// ctx carries none. A rolled-back transaction drops fn. Use it for side effects other
+// systems can see, such as published events, which must not announce writes that may
+// still be undone.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(txHooksKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
+}
+
+// txManager implements TxManager
+type txManager struct {
+	db *sql.DB
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(db *sql.DB) TxManager {
+	return &txManager{db: db}
+}
+
+// WithinTx runs fn inside a transaction
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	var hooks []func()
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+			return
+		}
+		for _, hook := range hooks {
+			hook()
+		}
+	}()
+
+	ctx = context.WithValue(ctx, txHooksKey{}, &hooks)
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
+
+// conn returns the transaction carried by ctx, or db when there is none
+func conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return translatingDBTX{tx}
+	}
+	return translatingDBTX{db}
+}
+
+// reader is conn for read-only queries: outside a transaction, a ctx marked with
+// database.WithReplica is served by the read replica when one is configured
+func reader(ctx context.Context, db *sql.DB) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return translatingDBTX{tx}
+	}
+	return translatingDBTX{database.Reader(ctx, db)}
+}
+
+// translatingDBTX passes Exec/Query errors through database.TranslateError so every
+// repository reports duplicates, foreign key violations and deadlocks as typed AppErrors.
+// QueryRow errors surface at Scan and are returned as-is (callers compare them to sql.ErrNoRows).
+type translatingDBTX struct {
+	DBTX
+}
+
+// ExecContext executes a statement, translating driver errors
+func (t translatingDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := t.DBTX.ExecContext(ctx, query, args...)
+	return result, database.TranslateError(err)
+}
+
+// QueryContext runs a query, translating driver errors
+func (t translatingDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := t.DBTX.QueryContext(ctx, query, args...)
+	return rows, database.TranslateError(err)
+}
@@ -1,18 +1,20 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/tenant"
 )
 
 // UserMenuRepository interface defines data access methods for user menus
 type UserMenuRepository interface {
-	GetAll() ([]models.UserMenu, error)
-	GetByUserAndMenu(userID uint64, menuID uint) (*models.UserMenu, error)
-	Create(req models.UserMenu) error
-	Delete(userID uint64, menuID uint, deletedBy *uint64) error
+	GetAll(ctx context.Context) ([]models.UserMenu, error)
+	GetByUserAndMenu(ctx context.Context, userID uint64, menuID uint) (*models.UserMenu, error)
+	Create(ctx context.Context, req models.UserMenu) error
+	Delete(ctx context.Context, userID uint64, menuID uint, deletedBy *uint64) error
 }
 
 // userMenuRepository implements UserMenuRepository
@@ -25,12 +27,13 @@ func NewUserMenuRepository(db *sql.DB) UserMenuRepository {
 	return &userMenuRepository{db: db}
 }
 
-// GetAll retrieves all active user-menu assignments
-func (r *userMenuRepository) GetAll() ([]models.UserMenu, error) {
-	rows, err := r.db.Query(`
+// GetAll retrieves all active user-menu assignments of the tenant
+func (r *userMenuRepository) GetAll(ctx context.Context) ([]models.UserMenu, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT user_id, menu_id, deleted_at, deleted_by
 		FROM user_menu
-		WHERE deleted_at IS NULL`)
+		WHERE deleted_at IS NULL AND `+UserInTenant,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user menus: %w", err)
 	}
@@ -53,13 +56,13 @@ func (r *userMenuRepository) GetAll() ([]models.UserMenu, error) {
 }
 
 // GetByUserAndMenu retrieves a user-menu assignment by user and menu IDs
-func (r *userMenuRepository) GetByUserAndMenu(userID uint64, menuID uint) (*models.UserMenu, error) {
+func (r *userMenuRepository) GetByUserAndMenu(ctx context.Context, userID uint64, menuID uint) (*models.UserMenu, error) {
 	var um models.UserMenu
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT user_id, menu_id, deleted_at, deleted_by
 		FROM user_menu
-		WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL`,
-		userID, menuID)
+		WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND `+UserInTenant,
+		userID, menuID, tenant.ID(ctx))
 
 	err := row.Scan(&um.UserID, &um.MenuID, &um.DeletedAt, &um.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -72,9 +75,17 @@ func (r *userMenuRepository) GetByUserAndMenu(userID uint64, menuID uint) (*mode
 	return &um, nil
 }
 
-// Create inserts a new user-menu assignment
-func (r *userMenuRepository) Create(req models.UserMenu) error {
-	_, err := r.db.Exec(`
+// Create inserts a new user-menu assignment; the user and the menu item must belong to the
+// tenant
+func (r *userMenuRepository) Create(ctx context.Context, req models.UserMenu) error {
+	db := conn(ctx, r.db)
+	if err := requireInTenant(ctx, db, "users", req.UserID, "User"); err != nil {
+		return err
+	}
+	if err := requireInTenant(ctx, db, "menu", uint64(req.MenuID), "Menu"); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO user_menu (user_id, menu_id, deleted_at, deleted_by)
 		VALUES (?, ?, ?, ?)`,
 		req.UserID, req.MenuID, req.DeletedAt, req.DeletedBy)
@@ -82,10 +93,10 @@ func (r *userMenuRepository) Create(req models.UserMenu) error {
 }
 
 // Delete performs a soft delete
-func (r *userMenuRepository) Delete(userID uint64, menuID uint, deletedBy *uint64) error {
-	_, err := r.db.Exec(`
+func (r *userMenuRepository) Delete(ctx context.Context, userID uint64, menuID uint, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE user_menu SET deleted_at = NOW(), deleted_by = ?
-		WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL`,
-		deletedBy, userID, menuID)
+		WHERE user_id = ? AND menu_id = ? AND deleted_at IS NULL AND `+UserInTenant,
+		deletedBy, userID, menuID, tenant.ID(ctx))
 	return err
 }
@@ -1,21 +1,59 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 )
 
 // UserRepository interface defines data access methods for users
 type UserRepository interface {
-	GetAll(limit, offset int) ([]models.User, error)
-	GetByID(id uint64) (*models.User, error)
-	Create(req models.CreateUserRequest, hashedPassword string) (uint64, error)
-	Update(id uint64, req models.UpdateUserRequest, hashedPassword string) error
-	Delete(id uint64) error
-	CountActive() (int, error)
+	GetAll(ctx context.Context, limit, offset int, sort []utils.SortTerm) ([]models.User, error)
+	GetAllAfter(ctx context.Context, after *utils.Cursor, limit int) ([]models.User, error)
+	StreamActive(ctx context.Context, sort []utils.SortTerm, fn func(*models.User) error) error
+	GetByID(ctx context.Context, id uint64) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetPasswordHash returns the password hash of an active user, which GetByID leaves out
+	GetPasswordHash(ctx context.Context, id uint64) (string, error)
+	// TakenLogins returns which of usernames and emails any user has, deleted or not, lower-cased
+	TakenLogins(ctx context.Context, usernames, emails []string) (map[string]bool, map[string]bool, error)
+	Create(ctx context.Context, req models.CreateUserRequest, hashedPassword string) (uint64, error)
+	Update(ctx context.Context, id uint64, req models.UpdateUserRequest, hashedPassword string) error
+	Delete(ctx context.Context, id uint64) error
+	// DeletedStates locks the users among ids until the transaction ends and returns their
+	// soft delete state; IDs without a row are missing from the map
+	DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error)
+	DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error
+	RestoreMany(ctx context.Context, ids []uint64, at time.Time) error
+	// FlagPasswordReset makes the active users holding roleID, and not signed in since
+	// lastLoginBefore, change their password at their next sign-in; nil filters select
+	// everyone. It returns the users it flagged, leaving out those already flagged. Call it
+	// in a transaction.
+	FlagPasswordReset(ctx context.Context, roleID *uint, lastLoginBefore *time.Time) ([]models.User, error)
+	CountActive(ctx context.Context) (int, error)
+	EstimateCount(ctx context.Context) (int64, error)
+}
+
+// UserListSpec whitelists the sort and fields parameters of the user list
+var UserListSpec = utils.ListSpec{
+	Sort: map[string]string{
+		"id":         "id",
+		"username":   "username",
+		"email":      "email",
+		"status":     "status",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	Fields:   []string{"id", "username", "email", "status", "created_at", "updated_at", "deleted_at", "deleted_by"},
+	Tiebreak: "id DESC",
 }
 
 // userRepository implements UserRepository
@@ -28,15 +66,21 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-// GetAll retrieves all active users with pagination
-func (r *userRepository) GetAll(limit, offset int) ([]models.User, error) {
-	rows, err := r.db.Query(`
+// GetAll retrieves all active users with pagination, newest first unless sort (checked
+// against UserListSpec) says otherwise
+func (r *userRepository) GetAll(ctx context.Context, limit, offset int, sort []utils.SortTerm) ([]models.User, error) {
+	orderBy, err := UserListSpec.OrderBy(sort, "created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
 		FROM users
-		WHERE deleted_at IS NULL
-		ORDER BY created_at DESC
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY `+orderBy+`
 		LIMIT ? OFFSET ?`,
-		limit, offset)
+		tenant.ID(ctx), limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %w", err)
 	}
@@ -59,14 +103,108 @@ func (r *userRepository) GetAll(limit, offset int) ([]models.User, error) {
 	return users, nil
 }
 
+// GetAllAfter retrieves up to limit active users positioned after the cursor (keyset pagination).
+// A nil cursor starts from the newest user.
+func (r *userRepository) GetAllAfter(ctx context.Context, after *utils.Cursor, limit int) ([]models.User, error) {
+	query := `
+		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
+		FROM users
+		WHERE tenant_id = ? AND deleted_at IS NULL`
+	args := []interface{}{tenant.ID(ctx)}
+	if after != nil {
+		query += " AND (created_at < ? OR (created_at = ? AND id < ?))"
+		args = append(args, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
+// StreamActive calls fn for every active user, newest first unless sort (checked against
+// UserListSpec) says otherwise, while iterating the result set. Rows are not accumulated, so
+// exports of any size use constant memory; an error from fn stops the scan.
+func (r *userRepository) StreamActive(ctx context.Context, sort []utils.SortTerm, fn func(*models.User) error) error {
+	orderBy, err := UserListSpec.OrderBy(sort, "created_at DESC, id DESC")
+	if err != nil {
+		return err
+	}
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
+		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
+		FROM users
+		WHERE tenant_id = ? AND deleted_at IS NULL
+		ORDER BY `+orderBy,
+		tenant.ID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var u models.User
+	for rows.Next() {
+		u = models.User{}
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy); err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		if err := fn(&u); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating users: %w", err)
+	}
+	return nil
+}
+
 // GetByID retrieves a user by ID
-func (r *userRepository) GetByID(id uint64) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint64) (*models.User, error) {
+	var u models.User
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
+		FROM users
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tenant.ID(ctx))
+
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan user: %w", err)
+	}
+
+	return &u, nil
+}
+
+// GetByUsername retrieves an active user by username
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var u models.User
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
 		FROM users
-		WHERE id = ? AND deleted_at IS NULL`,
-		id)
+		WHERE username = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		username, tenant.ID(ctx))
 
 	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -79,31 +217,106 @@ func (r *userRepository) GetByID(id uint64) (*models.User, error) {
 	return &u, nil
 }
 
-// Create inserts a new user
-func (r *userRepository) Create(req models.CreateUserRequest, hashedPassword string) (uint64, error) {
+// GetByEmail retrieves an active user by email
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
+		FROM users
+		WHERE email = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		email, tenant.ID(ctx))
+
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan user: %w", err)
+	}
+
+	return &u, nil
+}
+
+// GetPasswordHash reads the hash from the primary, so a password changed a moment ago is
+// the one checked
+func (r *userRepository) GetPasswordHash(ctx context.Context, id uint64) (string, error) {
+	var hash string
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT password_hash FROM users
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tenant.ID(ctx)).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read password hash: %w", err)
+	}
+	return hash, nil
+}
+
+// takenLoginsChunk is how many values one TakenLogins query looks up
+const takenLoginsChunk = 500
+
+// TakenLogins looks usernames and emails up in the tenant on the primary, deleted users
+// included, as the unique indexes cover them too
+func (r *userRepository) TakenLogins(ctx context.Context, usernames, emails []string) (map[string]bool, map[string]bool, error) {
+	takenUsernames := make(map[string]bool)
+	takenEmails := make(map[string]bool)
+	for _, lookup := range []struct {
+		column string
+		values []string
+		taken  map[string]bool
+	}{{"username", usernames, takenUsernames}, {"email", emails, takenEmails}} {
+		for start := 0; start < len(lookup.values); start += takenLoginsChunk {
+			chunk := lookup.values[start:min(start+takenLoginsChunk, len(lookup.values))]
+			args := make([]interface{}, 0, len(chunk)+1)
+			args = append(args, tenant.ID(ctx))
+			for _, v := range chunk {
+				args = append(args, v)
+			}
+			rows, err := conn(ctx, r.db).QueryContext(ctx,
+				"SELECT "+lookup.column+" FROM users WHERE tenant_id = ? AND "+lookup.column+" IN "+inList(len(chunk)), args...)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to look up %ss: %w", lookup.column, err)
+			}
+			for rows.Next() {
+				var v string
+				if err := rows.Scan(&v); err != nil {
+					rows.Close()
+					return nil, nil, fmt.Errorf("failed to scan %s: %w", lookup.column, err)
+				}
+				lookup.taken[strings.ToLower(v)] = true
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("error iterating %ss: %w", lookup.column, err)
+			}
+		}
+	}
+	return takenUsernames, takenEmails, nil
+}
+
+// Create inserts a new user into the tenant
+func (r *userRepository) Create(ctx context.Context, req models.CreateUserRequest, hashedPassword string) (uint64, error) {
 	status := uint8(1) // default active
 	if req.Status != nil {
 		status = *req.Status
 	}
 
-	result, err := r.db.Exec(`
-		INSERT INTO users (username, email, password_hash, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, NOW(), NOW())`,
-		req.Username, req.Email, hashedPassword, status)
+	userID, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO users (tenant_id, username, email, password_hash, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, NOW(), NOW())`,
+		tenant.ID(ctx), req.Username, req.Email, hashedPassword, status)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert user: %w", err)
 	}
 
-	userID, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get last insert id: %w", err)
-	}
-
 	return uint64(userID), nil
 }
 
 // Update modifies an existing user
-func (r *userRepository) Update(id uint64, req models.UpdateUserRequest, hashedPassword string) error {
+func (r *userRepository) Update(ctx context.Context, id uint64, req models.UpdateUserRequest, hashedPassword string) error {
 	var setParts []string
 	var args []interface{}
 
@@ -115,9 +328,10 @@ func (r *userRepository) Update(id uint64, req models.UpdateUserRequest, hashedP
 		setParts = append(setParts, "email = ?")
 		args = append(args, req.Email)
 	}
+	// A new password is the change a forced reset asks for
 	if req.Password != "" {
-		setParts = append(setParts, "password_hash = ?")
-		args = append(args, hashedPassword)
+		setParts = append(setParts, "password_hash = ?", "password_reset_required = ?")
+		args = append(args, hashedPassword, false)
 	}
 	if req.Status != nil {
 		setParts = append(setParts, "status = ?")
@@ -129,28 +343,100 @@ func (r *userRepository) Update(id uint64, req models.UpdateUserRequest, hashedP
 	}
 
 	setClause := strings.Join(setParts, ", ")
-	query := fmt.Sprintf("UPDATE users SET %s, updated_at = NOW() WHERE id = ? AND deleted_at IS NULL", setClause)
-	args = append(args, id)
+	query := fmt.Sprintf("UPDATE users SET %s, updated_at = NOW() WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL", setClause)
+	args = append(args, id, tenant.ID(ctx))
 
-	_, err := r.db.Exec(query, args...)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
 	return err
 }
 
 // Delete performs a soft delete
-func (r *userRepository) Delete(id uint64) error {
-	_, err := r.db.Exec(`
+func (r *userRepository) Delete(ctx context.Context, id uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE users SET deleted_at = NOW(), updated_at = NOW()
-		WHERE id = ? AND deleted_at IS NULL`,
-		id)
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tenant.ID(ctx))
 	return err
 }
 
-// CountActive counts active users
-func (r *userRepository) CountActive() (int, error) {
+// FlagPasswordReset flags the users a forced reset selects
+func (r *userRepository) FlagPasswordReset(ctx context.Context, roleID *uint, lastLoginBefore *time.Time) ([]models.User, error) {
+	where := "tenant_id = ? AND deleted_at IS NULL AND password_reset_required = ?"
+	args := []interface{}{tenant.ID(ctx), false}
+	if roleID != nil {
+		where += " AND EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = users.id AND ur.role_id = ? AND ur.deleted_at IS NULL)"
+		args = append(args, *roleID)
+	}
+	if lastLoginBefore != nil {
+		where += " AND (last_login_at IS NULL OR last_login_at < ?)"
+		args = append(args, *lastLoginBefore)
+	}
+
+	db := conn(ctx, r.db)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, username, email, status, created_at, updated_at, deleted_at, deleted_by
+		FROM users
+		WHERE `+where+`
+		ORDER BY id
+		FOR UPDATE`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		u.PasswordResetRequired = true
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	// The rows are locked, so the same filter flags the users just read
+	if _, err := db.ExecContext(ctx, "UPDATE users SET password_reset_required = ? WHERE "+where, append([]interface{}{true}, args...)...); err != nil {
+		return nil, fmt.Errorf("failed to flag users: %w", err)
+	}
+	return users, nil
+}
+
+// DeletedStates reads and locks the soft delete state of users
+func (r *userRepository) DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error) {
+	return softDeleteStates(ctx, conn(ctx, r.db), "users", ids)
+}
+
+// DeleteMany soft deletes users
+func (r *userRepository) DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error {
+	return softDeleteMany(ctx, conn(ctx, r.db), "users", ids, deletedBy, at)
+}
+
+// RestoreMany restores soft-deleted users
+func (r *userRepository) RestoreMany(ctx context.Context, ids []uint64, at time.Time) error {
+	return restoreMany(ctx, conn(ctx, r.db), "users", ids, at)
+}
+
+// CountActive counts the active users of the tenant
+func (r *userRepository) CountActive(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE deleted_at IS NULL").Scan(&count)
+	err := reader(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE tenant_id = ? AND deleted_at IS NULL", tenant.ID(ctx)).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
 	}
 	return count, nil
 }
+
+// EstimateCount returns the engine's row estimate for the users table, soft-deleted rows
+// included. It covers every tenant, so with tenancy enabled there is none (-1).
+func (r *userRepository) EstimateCount(ctx context.Context) (int64, error) {
+	if tenant.Enabled() {
+		return -1, nil
+	}
+	return database.EstimateRows(ctx, reader(ctx, r.db), "users")
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database/dbtest"
+)
+
+// TestUserRepositoryIntegration runs the user queries on the engine of DB_DRIVER: ids
+// from database.InsertID (LAST_INSERT_ID on MySQL, RETURNING on PostgreSQL), rebound ?
+// placeholders, and the NOW() timestamps of creation, updates and soft deletes
+func TestUserRepositoryIntegration(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	suffix := time.Now().UnixNano()
+	var ids []uint64
+	t.Cleanup(func() {
+		for _, id := range ids {
+			dbtest.Exec(t, db, "DELETE FROM users WHERE id = ?", id)
+		}
+	})
+	create := func(name string) uint64 {
+		t.Helper()
+		id, err := repo.Create(ctx, models.CreateUserRequest{
+			Username: fmt.Sprintf("%s%d", name, suffix),
+			Email:    fmt.Sprintf("%s%d@example.com", name, suffix),
+		}, "hash")
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, id)
+		return id
+	}
+
+	first, second := create("ita"), create("itb")
+	if first == 0 || second <= first {
+		t.Fatalf("Create returned ids %d then %d, want increasing generated ids", first, second)
+	}
+
+	user, err := repo.GetByID(ctx, second)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if want := fmt.Sprintf("itb%d", suffix); user.ID != second || user.Username != want {
+		t.Errorf("GetByID(%d) = #%d %q, want #%d %q", second, user.ID, user.Username, second, want)
+	}
+	// NOW() is the database's clock in the session's zone, so allow for the offset
+	if user.CreatedAt == nil || time.Since(*user.CreatedAt).Abs() > 14*time.Hour {
+		t.Errorf("created_at = %v, want about now", user.CreatedAt)
+	}
+	if user.Status != 1 {
+		t.Errorf("status = %d, want the default 1", user.Status)
+	}
+
+	email := fmt.Sprintf("itc%d@example.com", suffix)
+	if err := repo.Update(ctx, first, models.UpdateUserRequest{Email: email}, ""); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if user, err := repo.GetByUsername(ctx, fmt.Sprintf("ita%d", suffix)); err != nil || user.Email != email {
+		t.Errorf("GetByUsername after Update = %+v, %v; want email %s", user, err, email)
+	}
+
+	if err := repo.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, first); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID of a deleted user: %v, want sql.ErrNoRows", err)
+	}
+
+	states, err := repo.DeletedStates(ctx, []uint64{first, second})
+	if err != nil {
+		t.Fatalf("DeletedStates: %v", err)
+	}
+	if states[first].DeletedAt == nil || states[second].DeletedAt != nil {
+		t.Errorf("DeletedStates = %+v, want only %d deleted", states, first)
+	}
+
+	taken, _, err := repo.TakenLogins(ctx, []string{fmt.Sprintf("ita%d", suffix), "nobody"}, nil)
+	if err != nil {
+		t.Fatalf("TakenLogins: %v", err)
+	}
+	if !taken[fmt.Sprintf("ita%d", suffix)] || taken["nobody"] {
+		t.Errorf("TakenLogins = %v, want the deleted user's username only", taken)
+	}
+}
@@ -1,18 +1,21 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/tenant"
 )
 
 // UserRoleRepository interface defines data access methods for user roles
 type UserRoleRepository interface {
-	GetAll() ([]models.UserRole, error)
-	GetByUserAndRole(userID uint64, roleID uint) (*models.UserRole, error)
-	Create(req models.UserRole) error
-	Delete(userID uint64, roleID uint, deletedBy *uint64) error
+	GetAll(ctx context.Context) ([]models.UserRole, error)
+	GetByUserAndRole(ctx context.Context, userID uint64, roleID uint) (*models.UserRole, error)
+	GetUsersByRole(ctx context.Context, roleID uint) ([]models.User, error)
+	Create(ctx context.Context, req models.UserRole) error
+	Delete(ctx context.Context, userID uint64, roleID uint, deletedBy *uint64) error
 }
 
 // userRoleRepository implements UserRoleRepository
@@ -25,12 +28,13 @@ func NewUserRoleRepository(db *sql.DB) UserRoleRepository {
 	return &userRoleRepository{db: db}
 }
 
-// GetAll retrieves all active user-role assignments
-func (r *userRoleRepository) GetAll() ([]models.UserRole, error) {
-	rows, err := r.db.Query(`
+// GetAll retrieves all active user-role assignments of the tenant
+func (r *userRoleRepository) GetAll(ctx context.Context) ([]models.UserRole, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
 		SELECT user_id, role_id, deleted_at, deleted_by
 		FROM user_roles
-		WHERE deleted_at IS NULL`)
+		WHERE deleted_at IS NULL AND `+UserInTenant,
+		tenant.ID(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user roles: %w", err)
 	}
@@ -53,13 +57,13 @@ func (r *userRoleRepository) GetAll() ([]models.UserRole, error) {
 }
 
 // GetByUserAndRole retrieves a user-role assignment by user and role IDs
-func (r *userRoleRepository) GetByUserAndRole(userID uint64, roleID uint) (*models.UserRole, error) {
+func (r *userRoleRepository) GetByUserAndRole(ctx context.Context, userID uint64, roleID uint) (*models.UserRole, error) {
 	var ur models.UserRole
-	row := r.db.QueryRow(`
+	row := reader(ctx, r.db).QueryRowContext(ctx, `
 		SELECT user_id, role_id, deleted_at, deleted_by
 		FROM user_roles
-		WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL`,
-		userID, roleID)
+		WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND `+UserInTenant,
+		userID, roleID, tenant.ID(ctx))
 
 	err := row.Scan(&ur.UserID, &ur.RoleID, &ur.DeletedAt, &ur.DeletedBy)
 	if err == sql.ErrNoRows {
@@ -72,9 +76,59 @@ func (r *userRoleRepository) GetByUserAndRole(userID uint64, roleID uint) (*mode
 	return &ur, nil
 }
 
-// Create inserts a new user-role assignment
-func (r *userRoleRepository) Create(req models.UserRole) error {
-	_, err := r.db.Exec(`
+// GetUsersByRole retrieves the active users holding a role, by ID
+func (r *userRoleRepository) GetUsersByRole(ctx context.Context, roleID uint) ([]models.User, error) {
+	rows, err := reader(ctx, r.db).QueryContext(ctx, `
+		SELECT u.id, u.username, u.email, u.status, u.created_at, u.updated_at, u.deleted_at, u.deleted_by
+		FROM user_roles ur
+		JOIN users u ON u.id = ur.user_id AND u.deleted_at IS NULL
+		WHERE ur.role_id = ? AND ur.deleted_at IS NULL AND u.tenant_id = ?
+		ORDER BY u.id`,
+		roleID, tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt, &u.DeletedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Create inserts a new user-role assignment. A deleted assignment of the same user and role
+// is reinstated instead, as the pair is the table's primary key. The user and the role must
+// belong to the tenant.
+func (r *userRoleRepository) Create(ctx context.Context, req models.UserRole) error {
+	db := conn(ctx, r.db)
+	if err := requireInTenant(ctx, db, "users", req.UserID, "User"); err != nil {
+		return err
+	}
+	if err := requireInTenant(ctx, db, "roles", uint64(req.RoleID), "Role"); err != nil {
+		return err
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE user_roles SET deleted_at = NULL, deleted_by = NULL
+		WHERE user_id = ? AND role_id = ? AND deleted_at IS NOT NULL`,
+		req.UserID, req.RoleID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, `
 		INSERT INTO user_roles (user_id, role_id, deleted_at, deleted_by)
 		VALUES (?, ?, ?, ?)`,
 		req.UserID, req.RoleID, req.DeletedAt, req.DeletedBy)
@@ -82,10 +136,10 @@ func (r *userRoleRepository) Create(req models.UserRole) error {
 }
 
 // Delete performs a soft delete
-func (r *userRoleRepository) Delete(userID uint64, roleID uint, deletedBy *uint64) error {
-	_, err := r.db.Exec(`
+func (r *userRoleRepository) Delete(ctx context.Context, userID uint64, roleID uint, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
 		UPDATE user_roles SET deleted_at = NOW(), deleted_by = ?
-		WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL`,
-		deletedBy, userID, roleID)
+		WHERE user_id = ? AND role_id = ? AND deleted_at IS NULL AND `+UserInTenant,
+		deletedBy, userID, roleID, tenant.ID(ctx))
 	return err
 }
@@ -0,0 +1,240 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/fieldcrypt"
+)
+
+// WebhookRepository interface defines data access methods for webhooks and their deliveries
+type WebhookRepository interface {
+	GetAll(ctx context.Context) ([]models.Webhook, error)
+	GetByID(ctx context.Context, id uint64) (*models.Webhook, error)
+	Create(ctx context.Context, req models.Webhook) (uint64, error)
+	Update(ctx context.Context, id uint64, req map[string]interface{}) error
+	Delete(ctx context.Context, id uint64, deletedBy *uint64) error
+	CreateDelivery(ctx context.Context, d models.WebhookDelivery) (uint64, error)
+	UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookID uint64, status string, limit int) ([]models.WebhookDelivery, error)
+}
+
+// webhookRepository implements WebhookRepository
+type webhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// WebhookSecret is the encrypted column holding the signing secrets; repositories read and
+// write it in the clear
+var WebhookSecret = fieldcrypt.Field{Table: "webhooks", Key: "id", Column: "secret"}
+
+// EncryptedFields are every encrypted column, for the re-encryption job
+var EncryptedFields = []fieldcrypt.Field{WebhookSecret, CalendarAccessToken, CalendarRefreshToken}
+
+const webhookColumns = "id, url, secret, events, active, description, created_by, created_at, updated_at, deleted_at, deleted_by"
+
+// scanWebhook reads a row of webhookColumns; events are stored comma-separated
+func scanWebhook(scan func(dest ...interface{}) error) (*models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	if err := scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.Description, &w.CreatedBy,
+		&w.CreatedAt, &w.UpdatedAt, &w.DeletedAt, &w.DeletedBy); err != nil {
+		return nil, err
+	}
+	w.Events = strings.Split(events, ",")
+	secret, err := WebhookSecret.Decrypt(w.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("webhook %d secret: %w", w.ID, err)
+	}
+	w.Secret = secret
+	return &w, nil
+}
+
+// GetAll retrieves all webhooks that are not deleted, oldest first
+func (r *webhookRepository) GetAll(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE deleted_at IS NULL
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// GetByID retrieves a webhook by ID
+func (r *webhookRepository) GetByID(ctx context.Context, id uint64) (*models.Webhook, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE id = ? AND deleted_at IS NULL`,
+		id)
+
+	w, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhook: %w", err)
+	}
+
+	return w, nil
+}
+
+// Create inserts a new webhook
+func (r *webhookRepository) Create(ctx context.Context, req models.Webhook) (uint64, error) {
+	secret, err := WebhookSecret.Encrypt(req.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO webhooks (url, secret, events, active, description, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.URL, secret, strings.Join(req.Events, ","), req.Active, req.Description, req.CreatedBy, req.CreatedAt, req.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// Update modifies an existing webhook; req maps columns (url, secret, events, active,
+// description) to their new values, with events as a []string
+func (r *webhookRepository) Update(ctx context.Context, id uint64, req map[string]interface{}) error {
+	var setParts []string
+	var args []interface{}
+
+	for _, column := range []string{"url", "secret", "events", "active", "description"} {
+		value, ok := req[column]
+		if !ok {
+			continue
+		}
+		if events, ok := value.([]string); ok {
+			value = strings.Join(events, ",")
+		}
+		if secret, ok := value.(string); ok && column == "secret" {
+			sealed, err := WebhookSecret.Encrypt(secret)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt webhook secret: %w", err)
+			}
+			value = sealed
+		}
+		setParts = append(setParts, column+" = ?")
+		args = append(args, value)
+	}
+
+	if len(setParts) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = ?")
+	args = append(args, time.Now())
+
+	query := fmt.Sprintf("UPDATE webhooks SET %s WHERE id = ? AND deleted_at IS NULL", strings.Join(setParts, ", "))
+	args = append(args, id)
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	return err
+}
+
+// Delete performs a soft delete; the delivery log is kept
+func (r *webhookRepository) Delete(ctx context.Context, id uint64, deletedBy *uint64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhooks SET deleted_at = ?, updated_at = ?, deleted_by = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		time.Now(), time.Now(), deletedBy, id)
+	return err
+}
+
+// CreateDelivery records a delivery before its first attempt
+func (r *webhookRepository) CreateDelivery(ctx context.Context, d models.WebhookDelivery) (uint64, error) {
+	id, err := database.InsertID(ctx, conn(ctx, r.db), `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, attempts, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.WebhookID, d.EventID, d.Event, []byte(d.Payload), d.Status, d.Attempts, d.CreatedAt, d.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+// UpdateDelivery records the outcome of an attempt
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, time.Now(), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDeliveries retrieves the latest deliveries to a webhook, newest first, optionally
+// only those with status
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID uint64, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event_id, event, payload, status, attempts, response_status, last_error,
+			next_attempt_at, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE webhook_id = ?`
+	args := []interface{}{webhookID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := reader(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/utils"
+)
+
+// maxUserAnnouncements caps the banners the admin panel is handed at once
+const maxUserAnnouncements = 50
+
+// announcementSeverityRank orders banners, most severe first
+var announcementSeverityRank = map[string]int{
+	models.AnnouncementCritical: 0,
+	models.AnnouncementWarning:  1,
+	models.AnnouncementInfo:     2,
+}
+
+// AnnouncementService interface defines business logic for the banners operators broadcast
+// to admin panel users
+type AnnouncementService interface {
+	ListAnnouncements(ctx context.Context, filter models.AnnouncementFilter) ([]models.Announcement, error)
+	GetAnnouncement(ctx context.Context, id string) (*models.Announcement, error)
+	CreateAnnouncement(ctx context.Context, req models.AnnouncementRequest, createdBy *uint64) (*models.Announcement, error)
+	// UpdateAnnouncement replaces the announcement with req and returns it before and after
+	UpdateAnnouncement(ctx context.Context, id string, req models.AnnouncementRequest, updatedBy *uint64) (old, updated *models.Announcement, err error)
+	// DeleteAnnouncement removes the announcement and returns what it was
+	DeleteAnnouncement(ctx context.Context, id string) (*models.Announcement, error)
+	// ActiveForRoles returns the announcements showing now to a user holding roles, most
+	// severe first, then newest
+	ActiveForRoles(ctx context.Context, roles []string) ([]models.Announcement, error)
+}
+
+// announcementService implements AnnouncementService
+type announcementService struct {
+	repo  repositories.AnnouncementRepository
+	roles repositories.RoleRepository
+}
+
+// NewAnnouncementService creates a new announcement service; target roles are checked
+// against roles
+func NewAnnouncementService(repo repositories.AnnouncementRepository, roles repositories.RoleRepository) AnnouncementService {
+	return &announcementService{repo: repo, roles: roles}
+}
+
+// ListAnnouncements handles listing announcements, newest first
+func (s *announcementService) ListAnnouncements(ctx context.Context, filter models.AnnouncementFilter) ([]models.Announcement, error) {
+	switch filter.Status {
+	case "", models.AnnouncementScheduled, models.AnnouncementActive, models.AnnouncementExpired:
+	default:
+		return nil, utils.NewValidationError("Invalid status", "status must be scheduled, active or expired")
+	}
+	filter.Now = time.Now()
+
+	list, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list announcements: %w", err)
+	}
+	for i := range list {
+		list[i].Status = announcementStatus(&list[i], filter.Now)
+	}
+	return list, nil
+}
+
+// GetAnnouncement handles getting an announcement by ID
+func (s *announcementService) GetAnnouncement(ctx context.Context, id string) (*models.Announcement, error) {
+	announcementID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || announcementID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+
+	a, err := s.repo.GetByID(ctx, announcementID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Announcement")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcement: %w", err)
+	}
+	a.Status = announcementStatus(a, time.Now())
+	return a, nil
+}
+
+// CreateAnnouncement handles creating an announcement
+func (s *announcementService) CreateAnnouncement(ctx context.Context, req models.AnnouncementRequest, createdBy *uint64) (*models.Announcement, error) {
+	now := time.Now()
+	a := models.Announcement{CreatedBy: createdBy, UpdatedBy: createdBy, CreatedAt: &now, UpdatedAt: &now}
+	if err := s.apply(ctx, &a, req, now); err != nil {
+		return nil, err
+	}
+
+	id, err := s.repo.Create(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create announcement: %w", err)
+	}
+	a.ID = id
+	a.Status = announcementStatus(&a, now)
+	return &a, nil
+}
+
+// UpdateAnnouncement handles replacing an announcement
+func (s *announcementService) UpdateAnnouncement(ctx context.Context, id string, req models.AnnouncementRequest, updatedBy *uint64) (*models.Announcement, *models.Announcement, error) {
+	old, err := s.GetAnnouncement(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	a := *old
+	a.UpdatedBy = updatedBy
+	a.UpdatedAt = &now
+	if err := s.apply(ctx, &a, req, now); err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.Update(ctx, a); err != nil {
+		return nil, nil, fmt.Errorf("failed to update announcement: %w", err)
+	}
+	a.Status = announcementStatus(&a, now)
+	return old, &a, nil
+}
+
+// DeleteAnnouncement handles deleting an announcement
+func (s *announcementService) DeleteAnnouncement(ctx context.Context, id string) (*models.Announcement, error) {
+	a, err := s.GetAnnouncement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, a.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	return a, nil
+}
+
+// ActiveForRoles handles listing the banners a user sees
+func (s *announcementService) ActiveForRoles(ctx context.Context, roles []string) ([]models.Announcement, error) {
+	now := time.Now()
+	// Targeted announcements are filtered here, so read more than are handed out
+	active, err := s.repo.List(ctx, models.AnnouncementFilter{Status: models.AnnouncementActive, Now: now, Limit: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list announcements: %w", err)
+	}
+
+	list := []models.Announcement{}
+	for _, a := range active {
+		if len(a.TargetRoles) == 0 || slices.ContainsFunc(a.TargetRoles, func(role string) bool { return slices.Contains(roles, role) }) {
+			a.Status = models.AnnouncementActive
+			list = append(list, a)
+		}
+	}
+	// List is newest first; a stable sort keeps that within a severity
+	slices.SortStableFunc(list, func(a, b models.Announcement) int {
+		return announcementSeverityRank[a.Severity] - announcementSeverityRank[b.Severity]
+	})
+	if len(list) > maxUserAnnouncements {
+		list = list[:maxUserAnnouncements]
+	}
+	return list, nil
+}
+
+// apply checks req and copies it onto a
+func (s *announcementService) apply(ctx context.Context, a *models.Announcement, req models.AnnouncementRequest, now time.Time) error {
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		return utils.NewValidationError("Message is required")
+	}
+	if _, ok := announcementSeverityRank[req.Severity]; !ok {
+		return utils.NewValidationError("Invalid severity", "severity must be info, warning or critical")
+	}
+
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return utils.NewValidationError("Invalid validity window", "ends_at must be after starts_at")
+	}
+
+	targets := []string{}
+	for _, name := range req.TargetRoles {
+		name = strings.TrimSpace(name)
+		if slices.Contains(targets, name) {
+			continue
+		}
+		// Target roles are stored comma-separated
+		if name == "" || strings.Contains(name, ",") {
+			return utils.NewValidationError("Invalid target role", fmt.Sprintf("%q is not a role name", name))
+		}
+		if _, err := s.roles.GetByName(ctx, name); err == sql.ErrNoRows {
+			return utils.NewValidationError("Unknown target role", fmt.Sprintf("role %q does not exist", name))
+		} else if err != nil {
+			return fmt.Errorf("failed to get role: %w", err)
+		}
+		targets = append(targets, name)
+	}
+
+	a.Message = message
+	a.Severity = req.Severity
+	a.StartsAt = startsAt
+	a.EndsAt = req.EndsAt
+	a.TargetRoles = targets
+	return nil
+}
+
+// announcementStatus is whether a is scheduled, active or expired at now
+func announcementStatus(a *models.Announcement, now time.Time) string {
+	switch {
+	case a.StartsAt.After(now):
+		return models.AnnouncementScheduled
+	case a.EndsAt != nil && !a.EndsAt.After(now):
+		return models.AnnouncementExpired
+	default:
+		return models.AnnouncementActive
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/events"
+)
+
+// softDeleter is the part of the user, role and menu repositories a bulk delete or
+// restore uses
+type softDeleter interface {
+	DeletedStates(ctx context.Context, ids []uint64) (map[uint64]models.SoftDeleteState, error)
+	DeleteMany(ctx context.Context, ids []uint64, deletedBy *uint64, at time.Time) error
+	RestoreMany(ctx context.Context, ids []uint64, at time.Time) error
+}
+
+// bulkSoftDelete soft deletes the live rows among ids, or restores the deleted ones when
+// restore is set, in one transaction, and reports each ID's outcome. IDs that do not exist
+// or are already in the wanted state are skipped rather than failing the rest. entity
+// names the invalidation rule to fire for each row changed.
+func bulkSoftDelete(ctx context.Context, tx repositories.TxManager, repo softDeleter, entity string, ids []uint64, by *uint64, restore bool) (*models.BulkResult, error) {
+	result := &models.BulkResult{At: time.Now(), Outcomes: []models.BulkOutcome{}}
+	ids = uniqueIDs(ids)
+
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		states, err := repo.DeletedStates(ctx, ids)
+		if err != nil {
+			return err
+		}
+		changed := make([]uint64, 0, len(ids))
+		outcomes := make([]models.BulkOutcome, len(ids))
+		for i, id := range ids {
+			outcomes[i].ID = id
+			state, ok := states[id]
+			switch {
+			case !ok:
+				outcomes[i].Result = models.BulkNotFound
+			case restore && state.DeletedAt == nil:
+				outcomes[i].Result = models.BulkNotDeleted
+			case !restore && state.DeletedAt != nil:
+				outcomes[i].Result = models.BulkAlreadyDeleted
+			default:
+				outcomes[i].Before = &state
+				outcomes[i].Result = models.BulkDeleted
+				if restore {
+					outcomes[i].Result = models.BulkRestored
+				}
+				changed = append(changed, id)
+			}
+		}
+
+		if restore {
+			err = repo.RestoreMany(ctx, changed, result.At)
+		} else {
+			err = repo.DeleteMany(ctx, changed, by, result.At)
+		}
+		if err != nil {
+			return err
+		}
+		result.Outcomes = outcomes
+		result.Changed, result.Skipped = len(changed), len(ids)-len(changed)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	action := events.ActionDeleted
+	if restore {
+		action = events.ActionUpdated
+	}
+	for _, o := range result.Outcomes {
+		if o.Before != nil {
+			events.EntityChanged(entity, action, strconv.FormatUint(o.ID, 10))
+		}
+	}
+	return result, nil
+}
+
+// uniqueIDs drops the repeats from ids, keeping the first of each
+func uniqueIDs(ids []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(ids))
+	unique := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
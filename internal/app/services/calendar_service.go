@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/utils"
+)
+
+const (
+	// calendarStateTTL is how long an administrator has to consent on Google's page
+	calendarStateTTL = 10 * time.Minute
+	// calendarTokenLeeway refreshes an access token this long before it expires
+	calendarTokenLeeway = time.Minute
+)
+
+// CalendarService interface defines business logic for publishing the runs of report
+// schedules to the shared calendar of calendar.Default, so report owners see when their
+// reports are delivered. Sync runs from the calendar_sync scheduler job. With Google
+// Calendar, an administrator first connects the account events are written as, through
+// AuthorizeURL and the consent page's callback to Authorize; its tokens are stored
+// encrypted.
+type CalendarService interface {
+	calendar.TokenSource
+	Status(ctx context.Context) (*models.CalendarStatus, error)
+	// AuthorizeURL returns the Google consent page connecting an account for adminID
+	AuthorizeURL(ctx context.Context, adminID uint64) (*models.CalendarAuthorization, error)
+	// Authorize connects the account that consented, given the state AuthorizeURL handed
+	// out and the code Google returned; a state is only good once
+	Authorize(ctx context.Context, state, code string) (*models.CalendarStatus, error)
+	// Disconnect forgets the connected account; the events it published stay
+	Disconnect(ctx context.Context) error
+	// Sync publishes the runs of the enabled schedules within CALENDAR_HORIZON, updates the
+	// events of runs that changed and removes those of future runs no longer planned. Past
+	// runs stay on the calendar.
+	Sync(ctx context.Context) (string, error)
+}
+
+// calendarService implements CalendarService
+type calendarService struct {
+	repo      repositories.CalendarRepository
+	schedules repositories.ReportScheduleRepository
+	tx        repositories.TxManager
+	cache     cache.Cache
+	client    *calendar.Client
+	now       func() time.Time
+}
+
+// NewCalendarService creates a new calendar service publishing through client; OAuth states
+// are kept in c, so the callback may reach another instance
+func NewCalendarService(repo repositories.CalendarRepository, schedules repositories.ReportScheduleRepository, tx repositories.TxManager, c cache.Cache, client *calendar.Client) CalendarService {
+	return &calendarService{repo: repo, schedules: schedules, tx: tx, cache: c, client: client, now: time.Now}
+}
+
+// Status describes the calendar in use
+func (s *calendarService) Status(ctx context.Context) (*models.CalendarStatus, error) {
+	cfg := s.client.Config()
+	status := &models.CalendarStatus{Provider: cfg.Provider}
+	switch cfg.Provider {
+	case calendar.ProviderCalDAV:
+		status.Connected = true
+	case calendar.ProviderGoogle:
+		status.CalendarID = cfg.GoogleCalendarID
+		credential, err := s.repo.Credential(ctx, calendar.ProviderGoogle)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if credential != nil {
+			status.Connected = true
+			status.ConnectedBy, status.ConnectedAt, status.Scope = credential.ConnectedBy, credential.CreatedAt, credential.Scope
+		}
+	default:
+		return status, nil
+	}
+	count, last, err := s.repo.EventStats(ctx, cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	status.Events, status.LastSyncedAt = count, last
+	return status, nil
+}
+
+// AuthorizeURL hands out a state for the callback and the consent page to send it with
+func (s *calendarService) AuthorizeURL(ctx context.Context, adminID uint64) (*models.CalendarAuthorization, error) {
+	if s.client.Config().Provider != calendar.ProviderGoogle {
+		return nil, utils.NewConflictError("Accounts are only connected for Google Calendar (CALENDAR_PROVIDER=google)", nil)
+	}
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.Set(fmt.Sprintf(cache.CacheKeyCalendarState, state), adminID, calendarStateTTL); err != nil {
+		return nil, err
+	}
+	url, err := s.client.AuthCodeURL(state)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CalendarAuthorization{URL: url, ExpiresAt: s.now().Add(calendarStateTTL)}, nil
+}
+
+// Authorize trades the code for tokens and stores them in place of any earlier account's
+func (s *calendarService) Authorize(ctx context.Context, state, code string) (*models.CalendarStatus, error) {
+	if state == "" || code == "" {
+		return nil, utils.NewValidationError("state and code are required")
+	}
+	var adminID uint64
+	key := fmt.Sprintf(cache.CacheKeyCalendarState, state)
+	if err := s.cache.Get(key, &adminID); err != nil {
+		if cache.IsCacheMiss(err) {
+			return nil, utils.NewValidationError("The authorization expired or was already used; start again")
+		}
+		return nil, err
+	}
+	if err := s.cache.Delete(key); err != nil {
+		return nil, err
+	}
+
+	token, err := s.client.Exchange(ctx, code)
+	if errors.Is(err, calendar.ErrNotConnected) {
+		return nil, utils.NewValidationError("Google refused the authorization code; start again")
+	}
+	if err != nil {
+		return nil, utils.NewExternalError("Google OAuth", err)
+	}
+	if token.RefreshToken == "" {
+		return nil, utils.NewValidationError("Google granted no refresh token; remove the app's access from the Google account and start again")
+	}
+
+	now := s.now()
+	credential := models.CalendarCredential{
+		Provider:     calendar.ProviderGoogle,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    &token.ExpiresAt,
+		Scope:        token.Scope,
+		ConnectedBy:  &adminID,
+		CreatedAt:    &now,
+		UpdatedAt:    &now,
+	}
+	if err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteCredential(ctx, calendar.ProviderGoogle); err != nil {
+			return err
+		}
+		return s.repo.InsertCredential(ctx, credential)
+	}); err != nil {
+		return nil, err
+	}
+	return s.Status(ctx)
+}
+
+// Disconnect deletes the stored tokens
+func (s *calendarService) Disconnect(ctx context.Context) error {
+	return s.repo.DeleteCredential(ctx, calendar.ProviderGoogle)
+}
+
+// AccessToken returns the stored access token, refreshed first when it is about to expire.
+// Credentials Google no longer honours are deleted, so the status shows the account must
+// be connected again.
+func (s *calendarService) AccessToken(ctx context.Context) (string, error) {
+	credential, err := s.repo.Credential(ctx, calendar.ProviderGoogle)
+	if err == sql.ErrNoRows {
+		return "", calendar.ErrNotConnected
+	}
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	if credential.ExpiresAt != nil && now.Add(calendarTokenLeeway).Before(*credential.ExpiresAt) {
+		return credential.AccessToken, nil
+	}
+
+	token, err := s.client.Refresh(ctx, credential.RefreshToken)
+	if errors.Is(err, calendar.ErrNotConnected) {
+		slog.Warn("Google Calendar access was revoked; connect the account again", "connected_by", credential.ConnectedBy)
+		if err := s.repo.DeleteCredential(ctx, calendar.ProviderGoogle); err != nil {
+			slog.Error("Failed to delete revoked calendar credentials", "error", err)
+		}
+		return "", calendar.ErrNotConnected
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.UpdateTokens(ctx, calendar.ProviderGoogle, token.AccessToken, token.RefreshToken, token.ExpiresAt, now); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// plannedRun is the event of one run of a schedule
+type plannedRun struct {
+	scheduleID uint64
+	runAt      time.Time
+	event      calendar.Event
+	digest     string
+}
+
+// runKey identifies a run across the planned and the published ones, whatever location
+// the database hands times back in
+func runKey(scheduleID uint64, runAt time.Time) string {
+	return fmt.Sprintf("%d@%d", scheduleID, runAt.Unix())
+}
+
+// Sync brings the calendar in line with the schedules
+func (s *calendarService) Sync(ctx context.Context) (string, error) {
+	if !s.client.Enabled() {
+		return "calendar disabled", nil
+	}
+	cfg := s.client.Config()
+	now := s.now()
+
+	schedules, err := s.schedules.ListEnabled(ctx)
+	if err != nil {
+		return "", err
+	}
+	planned := map[string]plannedRun{}
+	for _, schedule := range schedules {
+		for _, run := range s.plan(schedule, cfg, now) {
+			planned[runKey(run.scheduleID, run.runAt)] = run
+		}
+	}
+	published, err := s.repo.ListEvents(ctx, cfg.Provider)
+	if err != nil {
+		return "", err
+	}
+
+	var written, unchanged, removed, failed int
+	summary := func() string {
+		return fmt.Sprintf("%d published, %d unchanged, %d removed, %d failed", written, unchanged, removed, failed)
+	}
+	// A revoked account fails every call; the first one stops the sync
+	stop := func(err error) bool {
+		if err != nil {
+			failed++
+			slog.Warn("Failed to sync a calendar event", "error", err)
+		}
+		return errors.Is(err, calendar.ErrNotConnected)
+	}
+
+	known := map[string]models.ReportScheduleEvent{}
+	for _, event := range published {
+		key := runKey(event.ScheduleID, event.RunAt)
+		if _, ok := planned[key]; ok {
+			known[key] = event
+			continue
+		}
+		// Runs no longer planned leave the calendar unless they are past, and so happened
+		if event.RunAt.After(now) {
+			if err := s.client.Delete(ctx, event.EventID); err != nil {
+				if stop(err) {
+					return summary(), err
+				}
+				continue
+			}
+		}
+		if err := s.repo.DeleteEvent(ctx, event.Provider, event.ScheduleID, event.RunAt); err != nil {
+			return summary(), err
+		}
+		removed++
+	}
+
+	for key, run := range planned {
+		if ctx.Err() != nil {
+			return summary(), ctx.Err()
+		}
+		event, exists := known[key]
+		if exists && event.Digest == run.digest {
+			unchanged++
+			continue
+		}
+		id, err := s.client.Put(ctx, run.event)
+		if err != nil {
+			if stop(err) {
+				return summary(), err
+			}
+			continue
+		}
+		syncedAt := s.now()
+		row := models.ReportScheduleEvent{ScheduleID: run.scheduleID, RunAt: run.runAt, Provider: cfg.Provider, EventID: id, Digest: run.digest, SyncedAt: &syncedAt}
+		if exists {
+			row.RunAt = event.RunAt
+			err = s.repo.UpdateEvent(ctx, row)
+		} else {
+			err = s.repo.InsertEvent(ctx, row)
+		}
+		if err != nil {
+			return summary(), err
+		}
+		written++
+	}
+
+	if failed > 0 {
+		return summary(), fmt.Errorf("%d calendar events failed to sync; see the server logs", failed)
+	}
+	return summary(), nil
+}
+
+// plan lists the runs of schedule within the horizon, at most CALENDAR_MAX_EVENTS of them
+func (s *calendarService) plan(schedule models.ReportSchedule, cfg calendar.Config, now time.Time) []plannedRun {
+	spec, err := scheduler.Parse(schedule.Schedule)
+	if err != nil {
+		slog.Error("Report schedule has an invalid schedule", "schedule_id", schedule.ID, "error", err)
+		return nil
+	}
+	// The destination is part of the digest, so events are written again when it changes
+	destination := cfg.CalDAVURL
+	if cfg.Provider == calendar.ProviderGoogle {
+		destination = cfg.GoogleCalendarID
+	}
+
+	var runs []plannedRun
+	end := now.Add(cfg.Horizon)
+	for t := spec.Next(now); !t.IsZero() && !t.After(end) && len(runs) < cfg.MaxEvents; t = spec.Next(t) {
+		runAt := t.Truncate(time.Second)
+		event := calendar.Event{
+			UID:     fmt.Sprintf("report-schedule-%d-%d", schedule.ID, runAt.Unix()),
+			Summary: "Report: " + schedule.Name,
+			Description: fmt.Sprintf("%s as %s, scheduled %q; the file is delivered to the owner's files (user %d).",
+				schedule.ReportPath, strings.ToUpper(schedule.OutputFormat), schedule.Schedule, schedule.OwnerID),
+			Start: runAt,
+			End:   runAt.Add(cfg.EventDuration),
+		}
+		sum := sha256.Sum256([]byte(strings.Join([]string{destination, event.UID, event.Summary, event.Description,
+			event.Start.UTC().Format(time.RFC3339), event.End.UTC().Format(time.RFC3339)}, "\n")))
+		runs = append(runs, plannedRun{scheduleID: schedule.ID, runAt: runAt, event: event, digest: hex.EncodeToString(sum[:])})
+	}
+	return runs
+}
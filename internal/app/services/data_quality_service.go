@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var dataQualityFindings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "data_quality",
+	Name:      "findings",
+	Help:      "Records flagged by the last data quality run on this instance, by check and severity.",
+}, []string{"check", "severity"})
+
+func init() {
+	metrics.Registry.MustRegister(dataQualityFindings)
+}
+
+// dataQualityMaxFindings is how many findings a run keeps per check; a check flagging more is
+// marked truncated
+const dataQualityMaxFindings = 1000
+
+// dataQualityChecks are the checks the data_quality job runs, most severe first
+var dataQualityChecks = []models.DataQualityCheck{
+	{
+		Name:        models.CheckCitiesWithoutCoordinates,
+		Description: "Cities without a latitude and longitude, whose prayer times cannot be computed",
+		Severity:    models.SeverityCritical,
+	},
+	{
+		Name:        models.CheckUsersWithoutRoles,
+		Description: "Active users without an active role, who can sign in but reach nothing",
+		Severity:    models.SeverityWarning,
+	},
+	{
+		Name:        models.CheckMenusWithDanglingParent,
+		Description: "Menu items under a deleted or missing parent, left out of the navigation",
+		Severity:    models.SeverityWarning,
+	},
+	{
+		Name:        models.CheckOrphanRoleMenus,
+		Description: "Role menu links whose role or menu item was deleted",
+		Severity:    models.SeverityInfo,
+	},
+}
+
+// DataQualityService interface defines business logic for the data quality checks: Run, from
+// the data_quality scheduler job, looks for inconsistent reference data and keeps what it
+// found in the database, so every instance reports the same findings
+type DataQualityService interface {
+	// Run runs every check and records its findings, replacing those of the last run
+	Run(ctx context.Context) (string, error)
+	// Report lists every check with its last run, and the findings of one check and one
+	// severity unless they are empty, newest first
+	Report(ctx context.Context, check, severity string, beforeID uint64, limit int) (*models.DataQualityReport, error)
+}
+
+// dataQualityService implements DataQualityService
+type dataQualityService struct {
+	repo repositories.DataQualityRepository
+	tx   repositories.TxManager
+	now  func() time.Time
+}
+
+// NewDataQualityService creates a new data quality service
+func NewDataQualityService(repo repositories.DataQualityRepository, tx repositories.TxManager) DataQualityService {
+	return &dataQualityService{repo: repo, tx: tx, now: time.Now}
+}
+
+// Run runs the checks one by one; a failing check does not keep the others from running
+func (s *dataQualityService) Run(ctx context.Context) (string, error) {
+	var summary []string
+	var errs []error
+	for _, check := range dataQualityChecks {
+		findings, err := s.repo.Detect(ctx, check.Name, dataQualityMaxFindings+1)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(findings) > dataQualityMaxFindings {
+			findings = findings[:dataQualityMaxFindings]
+			check.Truncated = true
+		}
+		check.FindingCount = len(findings)
+
+		if err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			return s.repo.Record(ctx, check, findings, s.now())
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to record check %s: %w", check.Name, err))
+			continue
+		}
+		dataQualityFindings.WithLabelValues(check.Name, check.Severity).Set(float64(check.FindingCount))
+
+		count := fmt.Sprint(check.FindingCount)
+		if check.Truncated {
+			count += "+"
+		}
+		summary = append(summary, fmt.Sprintf("%s: %s", check.Name, count))
+	}
+	return strings.Join(summary, ", "), errors.Join(errs...)
+}
+
+// Report merges the checks with their last runs
+func (s *dataQualityService) Report(ctx context.Context, check, severity string, beforeID uint64, limit int) (*models.DataQualityReport, error) {
+	if check != "" && findDataQualityCheck(check) == nil {
+		return nil, utils.NewValidationError("Unknown check " + check)
+	}
+	switch severity {
+	case "", models.SeverityCritical, models.SeverityWarning, models.SeverityInfo:
+	default:
+		return nil, utils.NewValidationError("severity must be critical, warning or info")
+	}
+
+	ran, err := s.repo.ListChecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lastRuns := make(map[string]models.DataQualityCheck, len(ran))
+	for _, c := range ran {
+		lastRuns[c.Name] = c
+	}
+	// Checks that never ran are listed too, without a checked_at
+	checks := make([]models.DataQualityCheck, len(dataQualityChecks))
+	for i, c := range dataQualityChecks {
+		checks[i] = c
+		if last, ok := lastRuns[c.Name]; ok {
+			checks[i].FindingCount = last.FindingCount
+			checks[i].Truncated = last.Truncated
+			checks[i].CheckedAt = last.CheckedAt
+		}
+	}
+
+	findings, err := s.repo.ListFindings(ctx, check, severity, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DataQualityReport{Checks: checks, Findings: findings}, nil
+}
+
+// findDataQualityCheck returns the check named name, nil when there is none
+func findDataQualityCheck(name string) *models.DataQualityCheck {
+	for i := range dataQualityChecks {
+		if dataQualityChecks[i].Name == name {
+			return &dataQualityChecks[i]
+		}
+	}
+	return nil
+}
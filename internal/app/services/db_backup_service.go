@@ -0,0 +1,291 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/buildinfo"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/migrate"
+	"adminbe/internal/pkg/storage"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	dbBackupsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "db_backup",
+		Name:      "runs_total",
+		Help:      "Database backups by status: succeeded, failed, or requeued when the instance stopped.",
+	}, []string{"status"})
+	dbBackupLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "db_backup",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "When the last database backup made by this instance succeeded, as a Unix time.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(dbBackupsFinished, dbBackupLastSuccess)
+}
+
+// backupExpireBatch is how many expired backups one run removes at most
+const backupExpireBatch = 100
+
+// DBBackupConfig tunes how backups run
+type DBBackupConfig struct {
+	// Timeout bounds one backup
+	Timeout time.Duration
+	// Retention is how long the file of a succeeded backup is kept
+	Retention time.Duration
+}
+
+// DBBackupService interface defines business logic for logical backups of the database: a
+// backup is queued by Create, or by the schedule, and made by Run on whichever instance the
+// db_backup scheduler job runs, its gzipped SQL kept in the configured storage.
+type DBBackupService interface {
+	// Create queues a backup, or returns the one already queued; a backup already running
+	// is a conflict
+	Create(ctx context.Context, createdBy uint64) (*models.DBBackup, error)
+	Get(ctx context.Context, id string) (*models.DBBackup, error)
+	// List lists backups, newest first
+	List(ctx context.Context, beforeID uint64, limit int) ([]models.DBBackup, error)
+	// Open reads the file of a succeeded backup; the caller closes it
+	Open(ctx context.Context, id string) (*models.DBBackup, io.ReadCloser, error)
+	// Run makes the queued backups, queueing one first if none is, after failing those an
+	// instance abandoned and removing the files past their retention
+	Run(ctx context.Context) (string, error)
+}
+
+// dbBackupService implements DBBackupService
+type dbBackupService struct {
+	repo  repositories.DBBackupRepository
+	db    *sql.DB
+	store *storage.Store
+	cfg   DBBackupConfig
+	now   func() time.Time
+}
+
+// NewDBBackupService creates a new backup service dumping db into store
+func NewDBBackupService(repo repositories.DBBackupRepository, db *sql.DB, store *storage.Store, cfg DBBackupConfig) DBBackupService {
+	return &dbBackupService{repo: repo, db: db, store: store, cfg: cfg, now: time.Now}
+}
+
+// Create queues a backup unless one is pending
+func (s *dbBackupService) Create(ctx context.Context, createdBy uint64) (*models.DBBackup, error) {
+	pending, err := s.repo.Pending(ctx)
+	switch {
+	case err == nil && pending.Status == models.BackupRunning:
+		return nil, utils.NewConflictError(fmt.Sprintf("Backup %d is running", pending.ID), nil)
+	case err == nil:
+		return pending, nil
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+	now := s.now()
+	backup := models.DBBackup{Status: models.BackupQueued, CreatedBy: &createdBy, CreatedAt: &now}
+	if backup.ID, err = s.repo.Create(ctx, backup); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// Get retrieves a backup
+func (s *dbBackupService) Get(ctx context.Context, id string) (*models.DBBackup, error) {
+	backupID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || backupID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+	backup, err := s.repo.GetByID(ctx, backupID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Backup")
+	}
+	return backup, err
+}
+
+// List retrieves backups, newest first
+func (s *dbBackupService) List(ctx context.Context, beforeID uint64, limit int) ([]models.DBBackup, error) {
+	return s.repo.List(ctx, beforeID, limit)
+}
+
+// Open opens the file of a backup that succeeded
+func (s *dbBackupService) Open(ctx context.Context, id string) (*models.DBBackup, io.ReadCloser, error) {
+	backup, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if backup.Status != models.BackupSucceeded || backup.StorageKey == nil {
+		return nil, nil, utils.NewConflictError("The backup is "+backup.Status, nil)
+	}
+	file, err := s.store.Open(ctx, *backup.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, utils.NewConflictError("The backup's file is missing from storage", nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return backup, file, nil
+}
+
+// Run fails what was abandoned, expires old files and makes the queued backups. A
+// scheduled run queues its own backup; one started by Create finds it queued.
+func (s *dbBackupService) Run(ctx context.Context) (string, error) {
+	now := s.now()
+	// DB_BACKUP_TIMEOUT ends every backup, so one running for longer was abandoned
+	abandoned, err := s.repo.FailStale(ctx, now.Add(-s.cfg.Timeout-time.Minute), now)
+	if err != nil {
+		return "", err
+	}
+	expired, err := s.expire(ctx, now.Add(-s.cfg.Retention))
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.repo.Pending(ctx); err == sql.ErrNoRows {
+		if _, err := s.repo.Create(ctx, models.DBBackup{Status: models.BackupQueued, CreatedAt: &now}); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	counts := map[string]int{}
+	for ctx.Err() == nil {
+		backup, err := s.repo.Claim(ctx, s.now())
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return backupSummary(counts, abandoned, expired), err
+		}
+		counts[s.run(ctx, backup)]++
+	}
+	return backupSummary(counts, abandoned, expired), nil
+}
+
+// backupSummary describes a Run for the job history
+func backupSummary(counts map[string]int, abandoned int64, expired int) string {
+	return fmt.Sprintf("%d succeeded, %d failed, %d requeued; %d abandoned, %d expired",
+		counts[models.BackupSucceeded], counts[models.BackupFailed], counts[models.BackupQueued], abandoned, expired)
+}
+
+// expire removes the files of the succeeded backups finished before before
+func (s *dbBackupService) expire(ctx context.Context, before time.Time) (int, error) {
+	backups, err := s.repo.ListExpired(ctx, before, backupExpireBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, backup := range backups {
+		if backup.StorageKey != nil {
+			if err := s.store.Remove(ctx, *backup.StorageKey); err != nil {
+				return 0, err
+			}
+		}
+		if err := s.repo.Expire(ctx, backup.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(backups), nil
+}
+
+// run makes a claimed backup and records its outcome, returning the status it ended in: a
+// backup interrupted by shutdown goes back to the queue for the next run to start over.
+func (s *dbBackupService) run(ctx context.Context, backup *models.DBBackup) string {
+	// The backup's row must be updated even when ctx is cancelled by shutdown
+	bg := context.WithoutCancel(ctx)
+	log := slog.With("backup_id", backup.ID)
+
+	err := s.dump(ctx, backup)
+	if err != nil && ctx.Err() != nil {
+		if err := s.repo.Requeue(bg, backup.ID); err != nil {
+			log.Error("Failed to requeue backup", "error", err)
+		}
+		dbBackupsFinished.WithLabelValues("requeued").Inc()
+		return models.BackupQueued
+	}
+
+	backup.Status = models.BackupSucceeded
+	if err != nil {
+		backup.Status = models.BackupFailed
+		message := truncate(err.Error(), 500)
+		backup.Error = &message
+		backup.StorageKey, backup.Size, backup.Checksum = nil, nil, nil
+		log.Error("Database backup failed", "error", err)
+	} else {
+		dbBackupLastSuccess.Set(float64(s.now().Unix()))
+		log.Info("Database backup succeeded", "tables", backup.Tables, "rows", backup.Rows, "size", *backup.Size)
+	}
+	if err := s.repo.Finish(bg, *backup, s.now()); err != nil {
+		log.Error("Failed to record the outcome of a backup", "status", backup.Status, "error", err)
+	}
+	dbBackupsFinished.WithLabelValues(backup.Status).Inc()
+	return backup.Status
+}
+
+// dump writes the database to a gzipped temporary file, then puts the file in storage,
+// filling in backup's file, counts and schema version
+func (s *dbBackupService) dump(ctx context.Context, backup *models.DBBackup) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	version, _, err := database.SchemaVersion(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "backup-*.sql.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	started := s.now()
+	hash := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(file, hash))
+	header := fmt.Sprintf("adminbe database backup %d, %s, taken %s\n"+
+		"Data only: restore into a %s database migrated to schema version %d.",
+		backup.ID, buildinfo.Version, started.UTC().Format(time.RFC3339), database.Current.Name(), version)
+	// Tables come from the replica when one is configured, sparing the primary the scans
+	stats, err := database.Dump(ctx, database.Reader(database.WithReplica(ctx), s.db), zw, header, func(stats database.DumpStats) {
+		if err := s.repo.Progress(context.WithoutCancel(ctx), backup.ID, stats.Tables, stats.Rows); err != nil {
+			slog.Warn("Failed to record backup progress", "backup_id", backup.ID, "error", err)
+		}
+	}, migrate.TableName)
+	backup.Tables, backup.Rows, backup.SchemaVersion = stats.Tables, stats.Rows, &version
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("backups/adminbe-%s-%d.sql.gz", started.UTC().Format("20060102-150405"), backup.ID)
+	if err := s.store.Put(ctx, key, file, size, "application/gzip"); err != nil {
+		return fmt.Errorf("failed to store the backup: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	backup.StorageKey, backup.Size, backup.Checksum = &key, &size, &checksum
+	return nil
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"context"
+
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/logging"
+)
+
+// publishEvent hands e to publisher once the transaction ctx carries, if any, commits, so
+// consumers never hear of writes that were rolled back. A nil publisher publishes nothing.
+func publishEvent(ctx context.Context, publisher domainevents.EventPublisher, e domainevents.Event) {
+	if publisher == nil {
+		return
+	}
+	repositories.AfterCommit(ctx, func() {
+		if err := publisher.Publish(ctx, e); err != nil {
+			logging.FromContext(ctx).Warn("Failed to publish domain event", "type", e.Type, "id", e.ID, "error", err)
+		}
+	})
+}
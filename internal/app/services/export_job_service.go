@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/tabular"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var exportJobsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "export",
+	Name:      "jobs_total",
+	Help:      "Export jobs run by kind and status: succeeded, failed, or requeued when the instance stopped.",
+}, []string{"kind", "status"})
+
+func init() {
+	metrics.Registry.MustRegister(exportJobsFinished)
+}
+
+const (
+	// exportHeartbeat is how often a running export records its progress
+	exportHeartbeat = 10 * time.Second
+	// exportStaleAfter is how long a running export may go without a heartbeat before it
+	// is failed as abandoned; well above exportHeartbeat, so a slow write is not mistaken
+	// for a stopped instance
+	exportStaleAfter = 2 * time.Minute
+	// exportExpireBatch is how many expired exports one run removes at most
+	exportExpireBatch = 100
+)
+
+// ExportWriter receives the rows of an export
+type ExportWriter interface {
+	// SetTotal records how many rows the export will write, for its progress; exporters
+	// that cannot tell beforehand need not call it, and may correct it as they go
+	SetTotal(n int64)
+	// Write adds one row, encoded as its JSON; for CSV each column takes the member of
+	// that name
+	Write(row interface{}) error
+}
+
+// Exporter is a kind of export job. Check validates the params of a new job, so a job is
+// only queued when it can run; Export writes its rows.
+type Exporter struct {
+	Kind        string
+	Description string
+	// Columns are the CSV header, in order
+	Columns []string
+	Check   func(params map[string]string) error
+	Export  func(ctx context.Context, params map[string]string, w ExportWriter) error
+}
+
+// ExportJobConfig tunes how export jobs run
+type ExportJobConfig struct {
+	// Timeout bounds one export
+	Timeout time.Duration
+	// Retention is how long the file of a succeeded export is kept
+	Retention time.Duration
+}
+
+// ExportJobService interface defines business logic for exports run in the background: a
+// job is queued by Create, run by RunQueued on whichever instance the export_jobs
+// scheduler job runs, and its file kept as an attachment of the user who created it.
+// Callers other than administrators only reach their own jobs.
+type ExportJobService interface {
+	Create(ctx context.Context, req models.CreateExportJobRequest, createdBy uint64) (*models.ExportJob, error)
+	// Get retrieves a job with its progress, and the file with a signed download URL once
+	// it succeeded
+	Get(ctx context.Context, id string, callerID uint64, admin bool) (*models.ExportJob, error)
+	// List lists the caller's jobs; administrators may list anyone's, or everyone's
+	List(ctx context.Context, filter models.ExportJobFilter, callerID uint64, admin bool) ([]models.ExportJob, error)
+	// RunQueued runs the queued jobs one after the other until none is left, after failing
+	// the abandoned ones and removing the files past their retention
+	RunQueued(ctx context.Context) (string, error)
+}
+
+// exportJobService implements ExportJobService
+type exportJobService struct {
+	repo          repositories.ExportJobRepository
+	attachments   AttachmentService
+	notifications NotificationService
+	exporters     map[string]Exporter
+	cfg           ExportJobConfig
+	now           func() time.Time
+}
+
+// NewExportJobService creates a new export job service running exporters. Finished exports
+// are saved through attachments, and their creators told through notifications.
+func NewExportJobService(repo repositories.ExportJobRepository, attachments AttachmentService, notifications NotificationService, cfg ExportJobConfig, exporters ...Exporter) ExportJobService {
+	byKind := make(map[string]Exporter, len(exporters))
+	for _, e := range exporters {
+		byKind[e.Kind] = e
+	}
+	return &exportJobService{repo: repo, attachments: attachments, notifications: notifications, exporters: byKind, cfg: cfg, now: time.Now}
+}
+
+// Create checks the params against the kind and queues the job
+func (s *exportJobService) Create(ctx context.Context, req models.CreateExportJobRequest, createdBy uint64) (*models.ExportJob, error) {
+	exporter, ok := s.exporters[req.Kind]
+	if !ok {
+		kinds := make([]string, 0, len(s.exporters))
+		for kind := range s.exporters {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return nil, utils.NewValidationError(fmt.Sprintf("Unknown export kind %q; one of %s", req.Kind, strings.Join(kinds, ", ")))
+	}
+	if req.Format == "" {
+		req.Format = tabular.FormatCSV
+	}
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+	if exporter.Check != nil {
+		if err := exporter.Check(req.Params); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.now()
+	job := models.ExportJob{Kind: req.Kind, Format: req.Format, Params: req.Params, Status: models.ExportJobQueued, CreatedBy: createdBy, CreatedAt: &now}
+	id, err := s.repo.Create(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = id
+	return &job, nil
+}
+
+// Get retrieves a job the caller may reach
+func (s *exportJobService) Get(ctx context.Context, id string, callerID uint64, admin bool) (*models.ExportJob, error) {
+	jobID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || jobID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+	job, err := s.repo.GetByID(ctx, jobID)
+	if err == sql.ErrNoRows || (err == nil && job.CreatedBy != callerID && !admin) {
+		return nil, utils.NewNotFoundError("Export job")
+	}
+	if err != nil {
+		return nil, err
+	}
+	setExportPercent(job)
+
+	// The creator may have deleted the file under /api/files, which leaves the job without one
+	if job.Status == models.ExportJobSucceeded && job.AttachmentID != nil {
+		file, err := s.attachments.Get(ctx, strconv.FormatUint(*job.AttachmentID, 10), job.CreatedBy, true)
+		if err != nil && !utils.IsNotFound(err) {
+			return nil, err
+		}
+		job.File = file
+	}
+	return job, nil
+}
+
+// List retrieves jobs, newest first, with their progress
+func (s *exportJobService) List(ctx context.Context, filter models.ExportJobFilter, callerID uint64, admin bool) ([]models.ExportJob, error) {
+	if !admin {
+		filter.CreatedBy = callerID
+	}
+	jobs, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		setExportPercent(&jobs[i])
+	}
+	return jobs, nil
+}
+
+// setExportPercent fills in how far a job got, once its total is known
+func setExportPercent(job *models.ExportJob) {
+	var percent float64
+	switch {
+	case job.Status == models.ExportJobSucceeded || job.Status == models.ExportJobExpired:
+		percent = 100
+	case job.Total != nil && *job.Total > 0:
+		percent = math.Min(100, math.Round(float64(job.Processed)*1000/float64(*job.Total))/10)
+	default:
+		return
+	}
+	job.Percent = &percent
+}
+
+// RunQueued drains the queue. Instances running it at once each claim different jobs.
+func (s *exportJobService) RunQueued(ctx context.Context) (string, error) {
+	now := s.now()
+	stale, err := s.repo.FailStale(ctx, now.Add(-exportStaleAfter), now)
+	if err != nil {
+		return "", err
+	}
+	expired, err := s.expire(ctx, now.Add(-s.cfg.Retention))
+	if err != nil {
+		return "", err
+	}
+
+	counts := map[string]int{}
+	for ctx.Err() == nil {
+		job, err := s.repo.Claim(ctx, s.now())
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return exportSummary(counts, stale, expired), err
+		}
+		counts[s.run(ctx, job)]++
+	}
+	return exportSummary(counts, stale, expired), nil
+}
+
+// exportSummary describes a RunQueued for the job history
+func exportSummary(counts map[string]int, stale int64, expired int) string {
+	return fmt.Sprintf("%d succeeded, %d failed, %d requeued; %d abandoned, %d expired",
+		counts[models.ExportJobSucceeded], counts[models.ExportJobFailed], counts[models.ExportJobQueued], stale, expired)
+}
+
+// expire removes the files of the succeeded jobs finished before before
+func (s *exportJobService) expire(ctx context.Context, before time.Time) (int, error) {
+	jobs, err := s.repo.ListExpired(ctx, before, exportExpireBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		if job.AttachmentID != nil {
+			_, err := s.attachments.Delete(ctx, strconv.FormatUint(*job.AttachmentID, 10), job.CreatedBy, true)
+			if err != nil && !utils.IsNotFound(err) {
+				return 0, err
+			}
+		}
+		if err := s.repo.Expire(ctx, job.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(jobs), nil
+}
+
+// run runs a claimed job and records its outcome, returning the status it ended in: a job
+// interrupted by shutdown goes back to the queue for the next run to start over.
+func (s *exportJobService) run(ctx context.Context, job *models.ExportJob) string {
+	// The job's rows must be updated even when ctx is cancelled by shutdown
+	bg := context.WithoutCancel(ctx)
+	log := slog.With("job_id", job.ID, "kind", job.Kind)
+
+	file, processed, err := s.export(ctx, job)
+	if file != nil {
+		defer os.Remove(file.Name())
+		defer file.Close()
+	}
+	if err != nil && ctx.Err() != nil {
+		if err := s.repo.Requeue(bg, job.ID); err != nil {
+			log.Error("Failed to requeue export job", "error", err)
+		}
+		exportJobsFinished.WithLabelValues(job.Kind, "requeued").Inc()
+		return models.ExportJobQueued
+	}
+
+	status := models.ExportJobSucceeded
+	var attachmentID *uint64
+	var message *string
+	if err == nil {
+		var a *models.Attachment
+		if a, err = s.save(bg, job, file); err == nil {
+			attachmentID = &a.ID
+		}
+	}
+	if err != nil {
+		status = models.ExportJobFailed
+		text := exportFailure(err)
+		message = &text
+		log.Error("Export job failed", "error", err)
+	}
+
+	if err := s.repo.Finish(bg, job.ID, status, processed, attachmentID, message, s.now()); err != nil {
+		log.Error("Failed to record the outcome of an export job", "status", status, "error", err)
+	}
+	exportJobsFinished.WithLabelValues(job.Kind, status).Inc()
+
+	text := "Your export is ready"
+	if status == models.ExportJobFailed {
+		text = "Your export failed"
+	}
+	notifyUser(bg, s.notifications, job.CreatedBy, notify.Notification{
+		Type:    notify.TypeExportFinished,
+		Message: text,
+		Data:    map[string]any{"job_id": job.ID, "kind": job.Kind, "status": status},
+	})
+	return status
+}
+
+// export writes a job's rows to a temporary file, recording its progress as it goes, and
+// returns the file with the number of rows written; the caller removes the file
+func (s *exportJobService) export(ctx context.Context, job *models.ExportJob) (*os.File, int64, error) {
+	exporter, ok := s.exporters[job.Kind]
+	if !ok {
+		return nil, 0, fmt.Errorf("no exporter for kind %q on this instance", job.Kind)
+	}
+	file, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	w, err := tabular.NewWriter(file, job.Format, exporter.Columns)
+	if err != nil {
+		return file, 0, err
+	}
+	progress := &exportProgress{w: w}
+	progress.total.Store(-1)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(exportHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.repo.Heartbeat(context.WithoutCancel(ctx), job.ID, progress.processed.Load(), progress.Total(), s.now()); err != nil {
+					slog.Warn("Failed to record export progress", "job_id", job.ID, "error", err)
+				}
+			}
+		}
+	}()
+
+	exportCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	if err := exporter.Export(exportCtx, job.Params, progress); err != nil {
+		if ctx.Err() == nil && errors.Is(exportCtx.Err(), context.DeadlineExceeded) {
+			return file, 0, utils.NewTimeoutError("export", err)
+		}
+		return file, 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return file, 0, err
+	}
+	return file, progress.processed.Load(), nil
+}
+
+// save stores a finished export as an attachment of the job's creator
+func (s *exportJobService) save(ctx context.Context, job *models.ExportJob, file *os.File) (*models.Attachment, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	contentType := "text/csv"
+	if job.Format == tabular.FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("%s-%d.%s", job.Kind, job.ID, job.Format)
+	return s.attachments.SaveExport(ctx, job.CreatedBy, filename, contentType, file, info.Size())
+}
+
+// exportFailure is the error a failed job reports to its creator: the message of an
+// AppError, which is meant for them, and nothing of other errors, which are logged instead
+func exportFailure(err error) string {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		return truncate(appErr.Message, 500)
+	}
+	return "The export failed; see the server logs"
+}
+
+// exportProgress is the ExportWriter of a running job, counting the rows for its heartbeats
+type exportProgress struct {
+	w         *tabular.Writer
+	processed atomic.Int64
+	total     atomic.Int64
+}
+
+// SetTotal records the expected number of rows
+func (p *exportProgress) SetTotal(n int64) {
+	p.total.Store(n)
+}
+
+// Total returns the expected number of rows, nil while unknown
+func (p *exportProgress) Total() *int64 {
+	n := p.total.Load()
+	if n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// Write writes and counts a row
+func (p *exportProgress) Write(row interface{}) error {
+	if err := p.w.Write(row); err != nil {
+		return err
+	}
+	p.processed.Add(1)
+	return nil
+}
@@ -0,0 +1,632 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/tabular"
+	"adminbe/internal/pkg/utils"
+	"adminbe/internal/pkg/validation"
+)
+
+// The columns of a reference data import, in English or as the Kemendagri datasets name them
+var (
+	locationImportCode      = []string{"code", "kode", "kode_wilayah", "kode_kemendagri"}
+	locationImportName      = []string{"name", "nama", "nama_wilayah"}
+	locationImportLatitude  = []string{"latitude", "lat", "lintang"}
+	locationImportLongitude = []string{"longitude", "lng", "lon", "bujur"}
+	locationImportElevation = []string{"elevation", "h", "ketinggian", "altitude"}
+	locationImportTimeZone  = []string{"time_zone", "timezone", "tz", "zona_waktu"}
+)
+
+// The longest names app_province and app_city hold
+const (
+	maxProvinceName = 100
+	maxCityName     = 40
+)
+
+// LocationImportService interface defines business logic for loading the Kemendagri region
+// codes and coordinates into app_province, app_city and data_lintang_kota_cms_new
+type LocationImportService interface {
+	// Import compares table with the provinces and cities and, unless dryRun or a row is
+	// invalid, applies the difference, journaled as an import of origin's file, source and
+	// user
+	Import(ctx context.Context, table *tabular.Table, dryRun bool, origin models.LocationImport) (*models.LocationImportResult, error)
+	ListImports(ctx context.Context, beforeID uint64, limit int) ([]models.LocationImport, error)
+	// GetImport returns an import with the changes it made
+	GetImport(ctx context.Context, id string) (*models.LocationImport, []models.LocationImportChange, error)
+	// Rollback restores what the newest applied import created or changed
+	Rollback(ctx context.Context, id string, userID *uint64) (*models.LocationImport, error)
+}
+
+// locationImportService implements LocationImportService
+type locationImportService struct {
+	repo    repositories.LocationRepository
+	imports repositories.LocationImportRepository
+}
+
+// NewLocationImportService creates a location import service
+func NewLocationImportService(repo repositories.LocationRepository, imports repositories.LocationImportRepository) LocationImportService {
+	return &locationImportService{repo: repo, imports: imports}
+}
+
+// regionPlan is a row of an import: the region as it is (old, nil for a new one) and as the
+// row leaves it
+type regionPlan struct {
+	row      int
+	kind     string
+	old      *models.Region
+	new      models.Region
+	province *regionPlan // a city's province, when the import creates it
+}
+
+// Import handles comparing and applying a reference data file
+func (s *locationImportService) Import(ctx context.Context, table *tabular.Table, dryRun bool, origin models.LocationImport) (*models.LocationImportResult, error) {
+	col := map[string]int{
+		"code":      table.Column(locationImportCode...),
+		"name":      table.Column(locationImportName...),
+		"latitude":  table.Column(locationImportLatitude...),
+		"longitude": table.Column(locationImportLongitude...),
+		"elevation": table.Column(locationImportElevation...),
+		"time_zone": table.Column(locationImportTimeZone...),
+	}
+	if col["code"] < 0 {
+		return nil, utils.NewValidationError("The file needs a code column",
+			"optional columns: name, latitude, longitude, elevation, time_zone")
+	}
+	if len(table.Rows) == 0 {
+		return nil, utils.NewValidationError("The file has no rows below its header")
+	}
+	cell := func(row tabular.Row, name string) string {
+		if i := col[name]; i >= 0 {
+			return row.Cells[i]
+		}
+		return ""
+	}
+
+	provinces, cities, err := s.repo.ListRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d := newRegionDiff(provinces, cities)
+
+	result := &models.LocationImportResult{DryRun: dryRun, Total: len(table.Rows), Rows: make([]models.LocationImportRow, len(table.Rows))}
+	plans := make([]*regionPlan, len(table.Rows))
+	// Provinces first, so cities find the ones the file adds wherever they are
+	for _, pass := range []string{models.RegionProvince, models.RegionCity} {
+		for i, row := range table.Rows {
+			code := strings.NewReplacer(".", "", " ", "", "-", "").Replace(cell(row, "code"))
+			kind := regionKind(code)
+			if pass == models.RegionProvince {
+				result.Rows[i] = models.LocationImportRow{Row: row.Line, Code: cell(row, "code"), Kind: kind, Name: cell(row, "name")}
+			}
+			if kind != pass && !(pass == models.RegionProvince && (kind == "" || kind == models.RegionSkipped)) {
+				continue
+			}
+			out := &result.Rows[i]
+			errs := map[string]validation.FieldError{}
+			switch kind {
+			case models.RegionSkipped:
+				out.Kind = ""
+				out.Action = models.RegionSkipped
+				continue
+			case "":
+				errs["code"] = validation.FieldError{Code: validation.CodeInvalid, Message: "must be a Kemendagri code of 2 (province) or 4 (regency or city) digits"}
+			default:
+				plans[i] = d.plan(kind, code, row, cell, errs)
+			}
+			if len(errs) > 0 {
+				out.Action = models.RegionInvalid
+				out.Errors = errs
+				plans[i] = nil
+				continue
+			}
+			out.Action, out.Changes = plans[i].diff(d)
+			if plans[i].old != nil {
+				id := plans[i].old.ID
+				out.ID = &id
+			}
+		}
+	}
+
+	// Provinces are written before the cities that may be in them
+	var apply []*regionPlan
+	for _, kind := range []string{models.RegionProvince, models.RegionCity} {
+		for i, p := range plans {
+			if p != nil && p.kind == kind && (result.Rows[i].Action == models.RegionCreate || result.Rows[i].Action == models.RegionUpdate) {
+				apply = append(apply, p)
+			}
+		}
+	}
+	for _, row := range result.Rows {
+		switch row.Action {
+		case models.RegionCreate:
+			result.Created++
+		case models.RegionUpdate:
+			result.Updated++
+		case models.RegionUnchanged:
+			result.Unchanged++
+		case models.RegionSkipped:
+			result.Skipped++
+		case models.RegionInvalid:
+			result.Invalid++
+		}
+	}
+	for action, n := range map[string]int{models.RegionCreate: result.Created, models.RegionUpdate: result.Updated,
+		models.RegionUnchanged: result.Unchanged, models.RegionSkipped: result.Skipped, models.RegionInvalid: result.Invalid} {
+		if n > 0 {
+			importRows.WithLabelValues("locations", action).Add(float64(n))
+		}
+	}
+	if dryRun || result.Invalid > 0 || len(apply) == 0 {
+		return result, nil
+	}
+
+	importID, err := s.apply(ctx, apply, origin, result.Created, result.Updated)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range plans {
+		if p != nil && p.old == nil {
+			id := p.new.ID
+			result.Rows[i].ID = &id
+		}
+	}
+	result.ImportID = &importID
+	result.Committed = true
+	return result, nil
+}
+
+// regionKind tells a province code from a city's, and those of districts (6 digits) and
+// villages (10), which are skipped; "" is not a code
+func regionKind(code string) string {
+	for _, ch := range code {
+		if ch < '0' || ch > '9' {
+			return ""
+		}
+	}
+	switch len(code) {
+	case 2:
+		return models.RegionProvince
+	case 4:
+		return models.RegionCity
+	case 6, 10:
+		return models.RegionSkipped
+	}
+	return ""
+}
+
+// regionDiff holds the provinces and cities an import is compared with, and what its rows
+// have claimed so far
+type regionDiff struct {
+	provinceByCode map[int]*models.Region
+	provinceByName map[string]*models.Region
+	provinceByID   map[int]*models.Region
+	cityByCode     map[int]*models.Region
+	cityByName     map[string]*models.Region // by province ID and name
+	// claimed are the regions matched by a row, and the rows of the codes seen
+	claimed   map[*models.Region]int
+	codeRows  map[string]int
+	provinces map[int]*regionPlan // the provinces of the file, by code
+}
+
+func newRegionDiff(provinces, cities []models.Region) *regionDiff {
+	d := &regionDiff{
+		provinceByCode: map[int]*models.Region{},
+		provinceByName: map[string]*models.Region{},
+		provinceByID:   map[int]*models.Region{},
+		cityByCode:     map[int]*models.Region{},
+		cityByName:     map[string]*models.Region{},
+		claimed:        map[*models.Region]int{},
+		codeRows:       map[string]int{},
+		provinces:      map[int]*regionPlan{},
+	}
+	for i := range provinces {
+		p := &provinces[i]
+		d.provinceByID[p.ID] = p
+		if _, dup := d.provinceByCode[p.Code]; p.Code > 0 && !dup {
+			d.provinceByCode[p.Code] = p
+		}
+		if key := regionName(p.Name); key != "" {
+			if _, dup := d.provinceByName[key]; !dup {
+				d.provinceByName[key] = p
+			}
+		}
+	}
+	for i := range cities {
+		c := &cities[i]
+		if _, dup := d.cityByCode[c.Code]; c.Code > 0 && !dup {
+			d.cityByCode[c.Code] = c
+		}
+		if key := strconv.Itoa(c.ProvinceID) + "/" + regionName(c.Name); regionName(c.Name) != "" {
+			if _, dup := d.cityByName[key]; !dup {
+				d.cityByName[key] = c
+			}
+		}
+	}
+	return d
+}
+
+// regionName is a name as regions are matched by it: case and spacing aside
+func regionName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// plan reads a row of kind and matches it with a region: by code, or else by name (within
+// the province, for a city) among the regions no other row has
+func (d *regionDiff) plan(kind, code string, row tabular.Row, cell func(tabular.Row, string) string, errs map[string]validation.FieldError) *regionPlan {
+	if first, dup := d.codeRows[code]; dup {
+		errs["code"] = validation.FieldError{Code: validation.CodeDuplicate, Message: fmt.Sprintf("is also in row %d", first)}
+		return nil
+	}
+	d.codeRows[code] = row.Line
+	n, _ := strconv.Atoi(code)
+	name := strings.Join(strings.Fields(cell(row, "name")), " ")
+	p := &regionPlan{row: row.Line, kind: kind}
+
+	match := func(byCode, byName *models.Region) {
+		for _, r := range []*models.Region{byCode, byName} {
+			if r == nil {
+				continue
+			}
+			if r == byName && r.Code > 0 && r.Code != n {
+				// Named alike, but another region by its code
+				continue
+			}
+			if _, taken := d.claimed[r]; taken {
+				continue
+			}
+			d.claimed[r] = row.Line
+			old := *r
+			p.old = &old
+			p.new = old
+			return
+		}
+	}
+
+	maxName := maxProvinceName
+	if kind == models.RegionProvince {
+		match(d.provinceByCode[n], d.provinceByName[regionName(name)])
+		d.provinces[n] = p
+	} else {
+		maxName = maxCityName
+		provinceCode := n / 100
+		province := d.provinces[provinceCode]
+		var provinceID int
+		switch {
+		case province != nil:
+			p.province = province
+			if province.old != nil {
+				provinceID = province.old.ID
+			}
+		case d.provinceByCode[provinceCode] != nil:
+			provinceID = d.provinceByCode[provinceCode].ID
+		default:
+			errs["code"] = validation.FieldError{Code: validation.CodeNotFound, Message: fmt.Sprintf("no province %02d, in the file or the database", provinceCode)}
+			return nil
+		}
+		var byName *models.Region
+		if provinceID > 0 {
+			byName = d.cityByName[strconv.Itoa(provinceID)+"/"+regionName(name)]
+		}
+		match(d.cityByCode[n], byName)
+		p.new.ProvinceID = provinceID
+		if province != nil && province.old == nil {
+			p.new.ProvinceID = 0
+		}
+	}
+
+	p.new.Code = n
+	switch {
+	case name != "":
+		p.new.Name = name
+	case p.old == nil:
+		errs["name"] = validation.FieldError{Code: validation.CodeRequired, Message: "is required for a new " + kind}
+	}
+	if len(name) > maxName {
+		errs["name"] = validation.FieldError{Code: validation.CodeTooLong, Message: fmt.Sprintf("must be at most %d characters", maxName)}
+	}
+
+	latitude, longitude := cell(row, "latitude"), cell(row, "longitude")
+	elevation, timeZone := cell(row, "elevation"), cell(row, "time_zone")
+	if kind == models.RegionProvince {
+		for field, v := range map[string]string{"latitude": latitude, "longitude": longitude, "elevation": elevation, "time_zone": timeZone} {
+			if v != "" {
+				errs[field] = validation.FieldError{Code: validation.CodeNotAllowed, Message: "only cities have coordinates"}
+			}
+		}
+		return p
+	}
+	p.coordinates(latitude, longitude, elevation, timeZone, errs)
+	return p
+}
+
+// coordinates applies a city row's coordinates, elevation and time zone; blank cells keep
+// what the city has
+func (p *regionPlan) coordinates(latitude, longitude, elevation, timeZone string, errs map[string]validation.FieldError) {
+	if (latitude == "") != (longitude == "") {
+		errs["latitude"] = validation.FieldError{Code: validation.CodeRequired, Message: "latitude and longitude go together"}
+		return
+	}
+	if latitude != "" {
+		lat, err := parseDegrees(latitude, 90)
+		if err != nil {
+			errs["latitude"] = validation.FieldError{Code: validation.CodeInvalid, Message: err.Error()}
+		}
+		lng, err := parseDegrees(longitude, 180)
+		if err != nil {
+			errs["longitude"] = validation.FieldError{Code: validation.CodeInvalid, Message: err.Error()}
+		}
+		p.new.Latitude, p.new.Longitude = &lat, &lng
+		p.new.HasCoordinates = true
+	}
+	if elevation != "" {
+		meters, err := strconv.ParseFloat(strings.Replace(elevation, ",", ".", 1), 64)
+		if err != nil || meters < -500 || meters > 9000 {
+			errs["elevation"] = validation.FieldError{Code: validation.CodeInvalid, Message: "must be meters above sea level, from -500 to 9000"}
+		} else {
+			m := int(math.Round(meters))
+			p.new.Elevation = &m
+		}
+	}
+	if timeZone != "" {
+		tz := strings.TrimPrefix(timeZone, "+")
+		if hours, err := strconv.ParseFloat(tz, 64); err != nil || hours < -12 || hours > 14 {
+			errs["time_zone"] = validation.FieldError{Code: validation.CodeInvalid, Message: "must be hours east of UTC, e.g. 7 for WIB"}
+		} else {
+			p.new.TimeZone = &tz
+		}
+	}
+	if !p.new.HasCoordinates && (elevation != "" || timeZone != "") {
+		errs["latitude"] = validation.FieldError{Code: validation.CodeRequired, Message: "the city has no coordinates yet; give latitude and longitude"}
+		return
+	}
+	if latitude != "" && p.new.TimeZone == nil {
+		errs["time_zone"] = validation.FieldError{Code: validation.CodeRequired, Message: "is required for a city's first coordinates"}
+	}
+}
+
+// parseDegrees reads decimal degrees within ±limit, with a comma or a point
+func parseDegrees(v string, limit float64) (float64, error) {
+	f, err := strconv.ParseFloat(strings.Replace(v, ",", ".", 1), 64)
+	if err != nil || math.IsNaN(f) || f < -limit || f > limit {
+		return 0, fmt.Errorf("must be decimal degrees from %g to %g", -limit, limit)
+	}
+	return f, nil
+}
+
+// diff returns what a plan does to its region and the values it changes. Provinces are
+// shown by code.
+func (p *regionPlan) diff(d *regionDiff) (string, map[string]models.ValueChange) {
+	changes := map[string]models.ValueChange{}
+	var old models.Region
+	if p.old != nil {
+		old = *p.old
+	}
+	if old.Name != p.new.Name {
+		changes["name"] = models.ValueChange{Old: nullIfZero(old.Name), New: p.new.Name}
+	}
+	if old.Code != p.new.Code {
+		changes["code"] = models.ValueChange{Old: nullIfZero(old.Code), New: p.new.Code}
+	}
+	if p.kind == models.RegionCity {
+		oldProvince, newProvince := 0, 0
+		if q := d.provinceByID[old.ProvinceID]; q != nil {
+			oldProvince = q.Code
+		}
+		if p.province != nil {
+			newProvince = p.province.new.Code
+		} else if q := d.provinceByID[p.new.ProvinceID]; q != nil {
+			newProvince = q.Code
+		}
+		if p.old == nil || oldProvince != newProvince || old.ProvinceID != p.new.ProvinceID {
+			changes["province"] = models.ValueChange{Old: nullIfZero(oldProvince), New: newProvince}
+		}
+		if !sameCoordinate(old.Latitude, p.new.Latitude) {
+			changes["latitude"] = models.ValueChange{Old: old.Latitude, New: p.new.Latitude}
+		}
+		if !sameCoordinate(old.Longitude, p.new.Longitude) {
+			changes["longitude"] = models.ValueChange{Old: old.Longitude, New: p.new.Longitude}
+		}
+		if !samePtr(old.Elevation, p.new.Elevation) {
+			changes["elevation"] = models.ValueChange{Old: old.Elevation, New: p.new.Elevation}
+		}
+		if !samePtr(old.TimeZone, p.new.TimeZone) {
+			changes["time_zone"] = models.ValueChange{Old: old.TimeZone, New: p.new.TimeZone}
+		}
+	}
+	switch {
+	case p.old == nil:
+		return models.RegionCreate, changes
+	case len(changes) == 0:
+		return models.RegionUnchanged, nil
+	}
+	return models.RegionUpdate, changes
+}
+
+func nullIfZero[T comparable](v T) interface{} {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+func samePtr[T comparable](a, b *T) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// sameCoordinate compares coordinates as they are stored, to six decimals
+func sameCoordinate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Round(*a*1e6) == math.Round(*b*1e6)
+}
+
+// apply writes the regions of plans, provinces before the cities that may be in them, and
+// journals each. The reference tables are MyISAM on MySQL, where a transaction would not
+// undo anything, so an import failing midway is undone from what it wrote instead.
+func (s *locationImportService) apply(ctx context.Context, plans []*regionPlan, origin models.LocationImport, created, updated int) (uint64, error) {
+	origin.Status = models.LocationImportApplying
+	importID, err := s.imports.Create(ctx, origin)
+	if err != nil {
+		return 0, err
+	}
+
+	var done []models.LocationImportChange
+	for _, p := range plans {
+		if p.province != nil && p.new.ProvinceID == 0 {
+			p.new.ProvinceID = p.province.new.ID
+		}
+		if p.kind == models.RegionProvince {
+			p.new.ID, err = s.repo.SaveProvince(ctx, p.new)
+		} else {
+			p.new.ID, err = s.repo.SaveCity(ctx, p.new)
+		}
+		if err != nil {
+			err = fmt.Errorf("row %d: %w", p.row, err)
+			break
+		}
+		change := models.LocationImportChange{ImportID: importID, Kind: p.kind, RecordID: p.new.ID, Action: models.RegionUpdate, Old: p.old}
+		if p.old == nil {
+			change.Action = models.RegionCreate
+		}
+		newValues := p.new
+		change.New = &newValues
+		done = append(done, change)
+		if err = s.imports.AddChange(ctx, change); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		if undoErr := s.undo(ctx, done); undoErr != nil {
+			err = fmt.Errorf("%w; undoing the import failed too, roll import %d back: %v", err, importID, undoErr)
+		} else {
+			s.imports.SetStatus(ctx, importID, models.LocationImportFailed, 0, 0)
+		}
+		invalidateLocations()
+		return 0, err
+	}
+
+	if err := s.imports.SetStatus(ctx, importID, models.LocationImportApplied, created, updated); err != nil {
+		return 0, err
+	}
+	invalidateLocations()
+	return importID, nil
+}
+
+// undo restores the regions of changes, newest first
+func (s *locationImportService) undo(ctx context.Context, changes []models.LocationImportChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		var err error
+		switch {
+		case c.Action == models.RegionCreate && c.Kind == models.RegionProvince:
+			err = s.repo.DeleteProvince(ctx, c.RecordID)
+		case c.Action == models.RegionCreate:
+			err = s.repo.DeleteCity(ctx, c.RecordID)
+		case c.Old == nil:
+			err = fmt.Errorf("change %d has no previous values", c.ID)
+		case c.Kind == models.RegionProvince:
+			_, err = s.repo.SaveProvince(ctx, *c.Old)
+		default:
+			_, err = s.repo.SaveCity(ctx, *c.Old)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s %d: %w", c.Kind, c.RecordID, err)
+		}
+	}
+	return nil
+}
+
+// invalidateLocations drops the cached province and city lists on every instance
+func invalidateLocations() {
+	events.EntityChanged("locations", events.ActionUpdated, "")
+}
+
+// ListImports handles listing imports
+func (s *locationImportService) ListImports(ctx context.Context, beforeID uint64, limit int) ([]models.LocationImport, error) {
+	return s.imports.List(ctx, beforeID, limit)
+}
+
+// GetImport handles getting an import and its changes
+func (s *locationImportService) GetImport(ctx context.Context, id string) (*models.LocationImport, []models.LocationImportChange, error) {
+	importID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || importID == 0 {
+		return nil, nil, utils.NewValidationError("Invalid ID")
+	}
+	imp, err := s.imports.GetByID(ctx, importID)
+	if err == sql.ErrNoRows {
+		return nil, nil, utils.NewNotFoundError("Import")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, err := s.imports.Changes(ctx, importID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return imp, changes, nil
+}
+
+// Rollback handles rolling back the newest import. Changes made by hand since are
+// overwritten, and a province the import created is kept while cities added since are in it.
+func (s *locationImportService) Rollback(ctx context.Context, id string, userID *uint64) (*models.LocationImport, error) {
+	imp, changes, err := s.GetImport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if imp.Status != models.LocationImportApplied {
+		return nil, utils.NewConflictError(fmt.Sprintf("Import %d is %s, not applied", imp.ID, imp.Status), nil)
+	}
+	latest, err := s.imports.Latest(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if latest != nil && latest.ID != imp.ID {
+		return nil, utils.NewConflictError(fmt.Sprintf("Only the latest import can be rolled back; roll back import %d first", latest.ID), nil)
+	}
+
+	// Cities the import created are deleted before their province is
+	createdIn := map[int]int{}
+	for _, c := range changes {
+		if c.Kind == models.RegionCity && c.Action == models.RegionCreate && c.New != nil {
+			createdIn[c.New.ProvinceID]++
+		}
+	}
+	for _, c := range changes {
+		if c.Kind != models.RegionProvince || c.Action != models.RegionCreate {
+			continue
+		}
+		count, err := s.repo.CountCities(ctx, c.RecordID)
+		if err != nil {
+			return nil, err
+		}
+		if count > createdIn[c.RecordID] {
+			return nil, utils.NewConflictError(fmt.Sprintf("Province %d, created by import %d, has cities added since; move or delete them first", c.RecordID, imp.ID), nil)
+		}
+	}
+
+	if err := s.undo(ctx, changes); err != nil {
+		invalidateLocations()
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.imports.MarkRolledBack(ctx, imp.ID, userID, now); err != nil {
+		return nil, err
+	}
+	invalidateLocations()
+	imp.Status = models.LocationImportRolledBack
+	imp.RolledBackBy = userID
+	imp.RolledBackAt = &now
+	return imp, nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var geocodeLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "geocode",
+	Name:      "lookups_total",
+	Help:      "Geocoder lookups by kind (place, elevation) and result: cached, found, not_found, rate_limited or failed.",
+}, []string{"kind", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(geocodeLookups)
+}
+
+// maxGeocodeQuery bounds the length of a place name looked up
+const maxGeocodeQuery = 200
+
+// LocationService interface defines business logic for managing the cities prayer times are
+// computed for, with coordinates from the geocoder
+type LocationService interface {
+	// Geocode looks a place up through geocode.Default, caching answers for GEOCODER_CACHE_TTL
+	Geocode(ctx context.Context, query string) (*geocode.Place, error)
+	GetCity(ctx context.Context, id string) (*models.CityLocation, error)
+	CreateCity(ctx context.Context, req models.CityLocationRequest) (*models.CityLocation, error)
+	// UpdateCity replaces the city with req and returns it before and after
+	UpdateCity(ctx context.Context, id string, req models.CityLocationRequest) (old, updated *models.CityLocation, err error)
+}
+
+// locationService implements LocationService
+type locationService struct {
+	repo     repositories.LocationRepository
+	tx       repositories.TxManager
+	cache    cache.Cache
+	geocoder *geocode.Client
+}
+
+// NewLocationService creates a new location service; changed cities are evicted from c, which
+// also holds the geocoder's answers
+func NewLocationService(repo repositories.LocationRepository, tx repositories.TxManager, c cache.Cache, geocoder *geocode.Client) LocationService {
+	return &locationService{repo: repo, tx: tx, cache: c, geocoder: geocoder}
+}
+
+// Geocode handles looking a place up by name
+func (s *locationService) Geocode(ctx context.Context, query string) (*geocode.Place, error) {
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" || len(query) > maxGeocodeQuery {
+		return nil, utils.NewValidationError("Invalid query", fmt.Sprintf("a place name of 1 to %d characters is required", maxGeocodeQuery))
+	}
+	return s.lookup(ctx, "place", strings.ToLower(query), func(ctx context.Context) (geocode.Place, error) {
+		place, err := s.geocoder.Geocode(ctx, query)
+		if err != nil {
+			return geocode.Place{}, err
+		}
+		return *place, nil
+	})
+}
+
+// elevation handles looking the elevation at coordinates up; nil when the provider cannot tell
+func (s *locationService) elevation(ctx context.Context, latitude, longitude float64) (*float64, error) {
+	// Four decimals is about 10 m, near enough for the same elevation
+	key := strconv.FormatFloat(latitude, 'f', 4, 64) + "," + strconv.FormatFloat(longitude, 'f', 4, 64)
+	place, err := s.lookup(ctx, "elevation", key, func(ctx context.Context) (geocode.Place, error) {
+		elevation, err := s.geocoder.Elevation(ctx, latitude, longitude)
+		return geocode.Place{Latitude: latitude, Longitude: longitude, Elevation: elevation}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return place.Elevation, nil
+}
+
+// lookup answers key of kind from the cache, or from the provider through load, and turns
+// the geocoder's errors into AppErrors
+func (s *locationService) lookup(ctx context.Context, kind, key string, load func(ctx context.Context) (geocode.Place, error)) (*geocode.Place, error) {
+	cfg := s.geocoder.Config()
+	loadAndCount := func(ctx context.Context) (geocode.Place, error) {
+		place, err := load(ctx)
+		geocodeLookups.WithLabelValues(kind, geocodeResult(err)).Inc()
+		return place, err
+	}
+
+	var place geocode.Place
+	var err error
+	if cfg.CacheTTL > 0 && s.geocoder.Enabled() {
+		hash := sha256.Sum256([]byte(cfg.Provider + "\x00" + cfg.Countries + "\x00" + cfg.Language + "\x00" + kind + "\x00" + key))
+		var cached bool
+		place, cached, err = cache.GetOrLoad(ctx, s.cache, fmt.Sprintf(cache.CacheKeyGeocode, fmt.Sprintf("%x", hash[:16])), cfg.CacheTTL, loadAndCount)
+		if cached {
+			geocodeLookups.WithLabelValues(kind, "cached").Inc()
+		}
+	} else {
+		place, err = loadAndCount(ctx)
+	}
+
+	switch {
+	case err == nil:
+		return &place, nil
+	case errors.Is(err, geocode.ErrDisabled):
+		return nil, utils.NewValidationError("No geocoder is configured", "set GEOCODER_PROVIDER, or give the coordinates")
+	case errors.Is(err, geocode.ErrNotFound):
+		return nil, utils.NewNotFoundError("Place")
+	case errors.Is(err, geocode.ErrRateLimited):
+		return nil, utils.NewRateLimitedError("The geocoder is busy, try again shortly", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, utils.NewTimeoutError("geocode "+kind, err)
+	default:
+		return nil, utils.NewExternalError("Geocoder", err)
+	}
+}
+
+// geocodeResult is the metric label of a provider call's outcome
+func geocodeResult(err error) string {
+	switch {
+	case err == nil:
+		return "found"
+	case errors.Is(err, geocode.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, geocode.ErrRateLimited):
+		return "rate_limited"
+	default:
+		return "failed"
+	}
+}
+
+// GetCity handles getting a city by ID
+func (s *locationService) GetCity(ctx context.Context, id string) (*models.CityLocation, error) {
+	cityID, err := strconv.Atoi(id)
+	if err != nil || cityID <= 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+
+	city, err := s.repo.GetCity(ctx, cityID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("City")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get city: %w", err)
+	}
+	return city, nil
+}
+
+// CreateCity handles adding a city
+func (s *locationService) CreateCity(ctx context.Context, req models.CityLocationRequest) (*models.CityLocation, error) {
+	var city models.CityLocation
+	if err := s.apply(ctx, &city, req); err != nil {
+		return nil, err
+	}
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateCity(ctx, city)
+		city.ID = id
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create city: %w", err)
+	}
+	s.evict(city.ProvinceID)
+	return &city, nil
+}
+
+// UpdateCity handles replacing a city
+func (s *locationService) UpdateCity(ctx context.Context, id string, req models.CityLocationRequest) (*models.CityLocation, *models.CityLocation, error) {
+	old, err := s.GetCity(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	city := models.CityLocation{ID: old.ID}
+	if err := s.apply(ctx, &city, req); err != nil {
+		return nil, nil, err
+	}
+	if err := s.tx.WithinTx(ctx, func(ctx context.Context) error { return s.repo.UpdateCity(ctx, city) }); err != nil {
+		return nil, nil, fmt.Errorf("failed to update city: %w", err)
+	}
+	s.evict(old.ProvinceID)
+	if city.ProvinceID != old.ProvinceID {
+		s.evict(city.ProvinceID)
+	}
+	return old, &city, nil
+}
+
+// apply checks req and copies it onto city, geocoding the coordinates and elevation it
+// leaves out
+func (s *locationService) apply(ctx context.Context, city *models.CityLocation, req models.CityLocationRequest) error {
+	name := strings.Join(strings.Fields(req.Name), " ")
+	if name == "" {
+		return utils.NewValidationError("Name is required")
+	}
+	timeZone := strings.TrimPrefix(strings.TrimSpace(req.TimeZone), "+")
+	if hours, err := strconv.ParseFloat(timeZone, 64); err != nil || hours < -12 || hours > 14 {
+		return utils.NewValidationError("Invalid time zone", "time_zone is hours east of UTC, e.g. 7 for WIB")
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return utils.NewValidationError("Latitude and longitude go together")
+	}
+	province, err := s.repo.GetProvince(ctx, req.ProvinceID)
+	if err == sql.ErrNoRows {
+		return utils.NewValidationError("Unknown province", fmt.Sprintf("province %d does not exist", req.ProvinceID))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get province: %w", err)
+	}
+
+	city.ProvinceID = province.ID
+	city.Name = name
+	city.TimeZone = &timeZone
+	city.Latitude, city.Longitude, city.Elevation = req.Latitude, req.Longitude, req.Elevation
+	city.Geocoded = nil
+	if req.Geocode != nil && !*req.Geocode {
+		if city.Latitude == nil {
+			return utils.NewValidationError("Latitude and longitude are required when geocode is false")
+		}
+		return nil
+	}
+
+	var elevation *float64
+	switch {
+	case city.Latitude == nil:
+		place, err := s.Geocode(ctx, name+", "+province.Title)
+		if err != nil {
+			return err
+		}
+		city.Latitude, city.Longitude = &place.Latitude, &place.Longitude
+		city.Geocoded = append(city.Geocoded, "latitude", "longitude")
+		elevation = place.Elevation
+	case city.Elevation == nil && s.geocoder.Enabled():
+		if elevation, err = s.elevation(ctx, *city.Latitude, *city.Longitude); err != nil {
+			return err
+		}
+	}
+	if city.Elevation == nil && elevation != nil {
+		meters := int(math.Round(*elevation))
+		city.Elevation = &meters
+		city.Geocoded = append(city.Geocoded, "elevation")
+	}
+	return nil
+}
+
+// evict drops the cached city lists of a province, so the change shows at once
+func (s *locationService) evict(provinceID int) {
+	s.cache.Delete(fmt.Sprintf(cache.CacheKeyCities, LocationHash(provinceID)))
+	s.cache.Delete(fmt.Sprintf(cache.CacheKeyCityLocations, provinceID))
+}
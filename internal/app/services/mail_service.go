@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var mailDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "mail",
+	Name:      "deliveries_total",
+	Help:      "Mail delivery attempts by result: succeeded, retrying, failed, or dropped when the queue was full.",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(mailDeliveries)
+}
+
+// MailRequest is an email to send: template rendered with Data for To
+type MailRequest struct {
+	Template string
+	To       string
+	// UserID is the recipient's account, when they have one, for the delivery log
+	UserID      *uint64
+	Data        map[string]any
+	Attachments []mail.Attachment
+}
+
+// MailService interface defines business logic for outgoing email: sending templated
+// messages in the background, retrying failures, and the delivery log
+type MailService interface {
+	// Send queues req. It never blocks and reports false when mail is disabled or the queue
+	// is full.
+	Send(req MailRequest) bool
+	ListDeliveries(ctx context.Context, filter models.MailDeliveryFilter) ([]models.MailDelivery, error)
+	// Close stops sending; retries still waiting are abandoned and stay pending in the log
+	Close()
+}
+
+// MailConfig tunes mail delivery
+type MailConfig struct {
+	// Workers is the number of messages sent at once
+	Workers int
+	// MaxAttempts is how often a message is tried before it is marked failed
+	MaxAttempts int
+	// RetryBackoff is the wait before the second attempt; it doubles after each failure
+	RetryBackoff time.Duration
+}
+
+// mailQueueSize bounds the messages and retries waiting for a worker
+const mailQueueSize = 1000
+
+// mailJob is a message to render and send, or a rendered one to try again
+type mailJob struct {
+	req      MailRequest
+	msg      *mail.Message
+	delivery *models.MailDelivery
+}
+
+// mailService implements MailService
+type mailService struct {
+	mailer *mail.Mailer
+	repo   repositories.MailDeliveryRepository
+	cfg    MailConfig
+	queue  chan mailJob
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	closed atomic.Bool
+}
+
+// NewMailService creates a mail service sending through mailer and starts its workers
+func NewMailService(mailer *mail.Mailer, repo repositories.MailDeliveryRepository, cfg MailConfig) MailService {
+	cfg.MaxAttempts = max(cfg.MaxAttempts, 1)
+	s := &mailService{
+		mailer: mailer,
+		repo:   repo,
+		cfg:    cfg,
+		queue:  make(chan mailJob, mailQueueSize),
+		stop:   make(chan struct{}),
+	}
+	for i := 0; i < max(cfg.Workers, 1); i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// Send queues req for rendering and delivery
+func (s *mailService) Send(req MailRequest) bool {
+	if s.closed.Load() || !s.mailer.Enabled() {
+		return false
+	}
+	select {
+	case s.queue <- mailJob{req: req}:
+		return true
+	default:
+		mailDeliveries.WithLabelValues("dropped").Inc()
+		slog.Warn("Mail queue full, dropping message", "template", req.Template)
+		return false
+	}
+}
+
+// ListDeliveries handles listing the latest deliveries, newest first
+func (s *mailService) ListDeliveries(ctx context.Context, filter models.MailDeliveryFilter) ([]models.MailDelivery, error) {
+	switch filter.Status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		return nil, utils.NewValidationError("status must be pending, succeeded or failed")
+	}
+	deliveries, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mail deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Close stops the workers
+func (s *mailService) Close() {
+	if s.closed.CompareAndSwap(false, true) {
+		close(s.stop)
+		s.wg.Wait()
+	}
+}
+
+func (s *mailService) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case job := <-s.queue:
+			if job.delivery == nil {
+				s.render(job.req)
+			} else {
+				s.attempt(job.msg, job.delivery)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// render fills in the template of req, records the delivery and makes its first attempt
+func (s *mailService) render(req MailRequest) {
+	ctx := context.Background()
+	now := time.Now()
+	d := &models.MailDelivery{
+		Template:  req.Template,
+		Recipient: req.To,
+		UserID:    req.UserID,
+		Status:    models.DeliveryPending,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+
+	msg, err := s.mailer.Render(req.Template, req.To, req.Data)
+	if err != nil {
+		// Logged as failed so a broken custom template shows up in the delivery log
+		reason := err.Error()
+		d.Status, d.LastError = models.DeliveryFailed, &reason
+		if _, err := s.repo.Create(ctx, *d); err != nil {
+			slog.Error("Failed to record mail delivery", "template", req.Template, "error", err)
+		}
+		mailDeliveries.WithLabelValues(models.DeliveryFailed).Inc()
+		slog.Error("Failed to render mail", "template", req.Template, "error", reason)
+		return
+	}
+	msg.Attachments = req.Attachments
+	d.Subject = truncate(msg.Subject, 255)
+
+	if d.ID, err = s.repo.Create(ctx, *d); err != nil {
+		// Still send; the attempt just goes unlogged
+		slog.Error("Failed to record mail delivery", "template", req.Template, "error", err)
+	}
+	s.attempt(msg, d)
+}
+
+// attempt sends msg once and records the outcome
+func (s *mailService) attempt(msg *mail.Message, d *models.MailDelivery) {
+	ctx := context.Background()
+	d.Attempts++
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.schedule(ctx, msg, d, err.Error())
+		return
+	}
+	s.finish(ctx, d, models.DeliverySucceeded, "")
+}
+
+// schedule retries d after the backoff for its attempt count, or marks it failed once it
+// has had every attempt
+func (s *mailService) schedule(ctx context.Context, msg *mail.Message, d *models.MailDelivery, reason string) {
+	if d.Attempts >= s.cfg.MaxAttempts {
+		s.finish(ctx, d, models.DeliveryFailed, reason)
+		return
+	}
+
+	delay := s.cfg.RetryBackoff << (d.Attempts - 1)
+	next := time.Now().Add(delay)
+	d.NextAttemptAt = &next
+	s.record(ctx, d, models.DeliveryPending, reason)
+	mailDeliveries.WithLabelValues("retrying").Inc()
+
+	retry := *d
+	time.AfterFunc(delay, func() {
+		select {
+		case s.queue <- mailJob{msg: msg, delivery: &retry}:
+		case <-s.stop:
+		}
+	})
+}
+
+// finish records the final outcome of d
+func (s *mailService) finish(ctx context.Context, d *models.MailDelivery, status, reason string) {
+	d.NextAttemptAt = nil
+	s.record(ctx, d, status, reason)
+	mailDeliveries.WithLabelValues(status).Inc()
+	if status == models.DeliveryFailed {
+		slog.Warn("Mail delivery failed", "template", d.Template, "delivery_id", d.ID,
+			"attempts", d.Attempts, "error", reason)
+	}
+}
+
+// record writes d's status and latest error to the delivery log
+func (s *mailService) record(ctx context.Context, d *models.MailDelivery, status, reason string) {
+	d.Status = status
+	d.LastError = nil
+	if reason != "" {
+		if len(reason) > maxDeliveryError {
+			reason = reason[:maxDeliveryError]
+		}
+		d.LastError = &reason
+	}
+	if d.ID == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.repo.Update(ctx, *d); err != nil {
+		slog.Error("Failed to record mail delivery outcome", "delivery_id", d.ID, "error", err)
+	}
+}
@@ -1,38 +1,49 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"time"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/repositories"
-
-	"github.com/gin-gonic/gin"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/utils"
 )
 
 // MenuService interface defines business logic for menus
 type MenuService interface {
-	ListMenus() ([]models.Menu, error)
-	GetMenu(id string) (*models.Menu, error)
-	CreateMenu(req models.Menu) (*models.Menu, error)
-	UpdateMenu(id string, req map[string]interface{}) (*models.Menu, error)
-	DeleteMenu(id string) error
+	ListMenus(ctx context.Context, sort []utils.SortTerm) ([]models.Menu, error)
+	GetMenu(ctx context.Context, id string) (*models.Menu, error)
+	CreateMenu(ctx context.Context, req models.Menu) (*models.Menu, error)
+	UpdateMenu(ctx context.Context, id string, req map[string]interface{}) (*models.Menu, error)
+	DeleteMenu(ctx context.Context, id string) error
+	// DeleteMenus soft deletes menus in one transaction, by the user deletedBy; RestoreMenus
+	// brings deleted ones back. Either reports each ID's outcome.
+	DeleteMenus(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error)
+	RestoreMenus(ctx context.Context, ids []uint64) (*models.BulkResult, error)
+	// ApplyTree makes the menu the tree items in one transaction: nodes without an ID are
+	// created, the others moved, relabelled and reordered, and the live items left out are
+	// deleted by the user deletedBy
+	ApplyTree(ctx context.Context, items []models.MenuNode, deletedBy *uint64) (*models.MenuTreeResult, error)
 }
 
 // menuService implements MenuService
 type menuService struct {
 	repo repositories.MenuRepository
+	tx   repositories.TxManager
 }
 
 // NewMenuService creates a new menu service
-func NewMenuService(repo repositories.MenuRepository) MenuService {
-	return &menuService{repo: repo}
+func NewMenuService(repo repositories.MenuRepository, tx repositories.TxManager) MenuService {
+	return &menuService{repo: repo, tx: tx}
 }
 
-// ListMenus handles listing all menus
-func (s *menuService) ListMenus() ([]models.Menu, error) {
-	menus, err := s.repo.GetAll()
+// ListMenus handles listing all menus in the given order (nil for the default)
+func (s *menuService) ListMenus(ctx context.Context, sort []utils.SortTerm) ([]models.Menu, error) {
+	menus, err := s.repo.GetAll(ctx, sort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get menus: %w", err)
 	}
@@ -41,18 +52,15 @@ func (s *menuService) ListMenus() ([]models.Menu, error) {
 }
 
 // GetMenu handles getting a menu by ID
-func (s *menuService) GetMenu(id string) (*models.Menu, error) {
+func (s *menuService) GetMenu(ctx context.Context, id string) (*models.Menu, error) {
 	menuID, err := parseUint(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	menu, err := s.repo.GetByID(menuID)
+	menu, err := s.repo.GetByID(ctx, menuID)
 	if err == sql.ErrNoRows {
-		return nil, gin.Error{
-			Err:  fmt.Errorf("menu not found"),
-			Type: gin.ErrorTypePublic,
-		}
+		return nil, utils.NewNotFoundError("menu")
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get menu: %w", err)
@@ -62,47 +70,217 @@ func (s *menuService) GetMenu(id string) (*models.Menu, error) {
 }
 
 // CreateMenu handles creating a new menu
-func (s *menuService) CreateMenu(req models.Menu) (*models.Menu, error) {
+func (s *menuService) CreateMenu(ctx context.Context, req models.Menu) (*models.Menu, error) {
 	s.setTimestamps(&req)
 
-	menuID, err := s.repo.Create(req)
+	menuID, err := s.repo.Create(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create menu: %w", err)
 	}
 
-	return s.retrieveMenuByID(menuID)
+	events.EntityChanged("menu", events.ActionCreated, strconv.FormatUint(uint64(menuID), 10))
+
+	return s.retrieveMenuByID(ctx, menuID)
 }
 
 // UpdateMenu handles updating an existing menu
-func (s *menuService) UpdateMenu(id string, req map[string]interface{}) (*models.Menu, error) {
+func (s *menuService) UpdateMenu(ctx context.Context, id string, req map[string]interface{}) (*models.Menu, error) {
 	menuID, err := parseUint(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	if err := s.ensureMenuExists(menuID); err != nil {
+	if err := s.ensureMenuExists(ctx, menuID); err != nil {
 		return nil, err
 	}
 
-	if err := s.repo.Update(menuID, req); err != nil {
+	if err := s.repo.Update(ctx, menuID, req); err != nil {
 		return nil, fmt.Errorf("failed to update menu: %w", err)
 	}
 
-	return s.retrieveMenuByID(menuID)
+	events.EntityChanged("menu", events.ActionUpdated, strconv.FormatUint(uint64(menuID), 10))
+
+	return s.retrieveMenuByID(ctx, menuID)
 }
 
 // DeleteMenu handles deleting a menu
-func (s *menuService) DeleteMenu(id string) error {
+func (s *menuService) DeleteMenu(ctx context.Context, id string) error {
 	menuID, err := parseUint(id)
 	if err != nil {
 		return fmt.Errorf("invalid ID: %w", err)
 	}
 
-	if err := s.ensureMenuExists(menuID); err != nil {
+	if err := s.ensureMenuExists(ctx, menuID); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, menuID, nil); err != nil { // TODO: get current user ID for audit
 		return err
 	}
 
-	return s.repo.Delete(menuID, nil) // TODO: get current user ID for audit
+	events.EntityChanged("menu", events.ActionDeleted, strconv.FormatUint(uint64(menuID), 10))
+	return nil
+}
+
+// DeleteMenus handles soft deleting menus in bulk
+func (s *menuService) DeleteMenus(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "menu", ids, deletedBy, false)
+}
+
+// RestoreMenus handles restoring soft-deleted menus in bulk
+func (s *menuService) RestoreMenus(ctx context.Context, ids []uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "menu", ids, nil, true)
+}
+
+// Limits of the tree ApplyTree takes
+const (
+	menuTreeMaxNodes = 1000
+	menuTreeMaxDepth = 10
+)
+
+// ApplyTree handles reconciling the menu with a whole tree
+func (s *menuService) ApplyTree(ctx context.Context, items []models.MenuNode, deletedBy *uint64) (*models.MenuTreeResult, error) {
+	if err := checkMenuTree(items); err != nil {
+		return nil, err
+	}
+
+	result := &models.MenuTreeResult{Changes: []models.MenuTreeChange{}}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		menus, err := s.repo.LockLive(ctx)
+		if err != nil {
+			return err
+		}
+		live := make(map[uint]models.Menu, len(menus))
+		for _, m := range menus {
+			live[m.ID] = m
+		}
+
+		now := time.Now()
+		kept := make(map[uint]bool, len(menus))
+		var place func(nodes []models.MenuNode, parentID *uint) error
+		place = func(nodes []models.MenuNode, parentID *uint) error {
+			for i, node := range nodes {
+				menu := models.Menu{Label: node.Label, Url: node.Url, Icon: node.Icon, ParentID: parentID, SortOrder: uint16(i + 1)}
+				if node.ID == nil {
+					menu.CreatedAt, menu.UpdatedAt = &now, &now
+					if menu.ID, err = s.repo.Create(ctx, menu); err != nil {
+						return err
+					}
+					result.Changes = append(result.Changes, models.MenuTreeChange{ID: menu.ID, After: &menu})
+				} else {
+					old, ok := live[*node.ID]
+					if !ok {
+						return utils.NewValidationError(fmt.Sprintf("Menu item %d does not exist", *node.ID))
+					}
+					menu.ID = old.ID
+					kept[menu.ID] = true
+					if old.Label != menu.Label || !samePtr(old.Url, menu.Url) || !samePtr(old.Icon, menu.Icon) ||
+						!samePtr(old.ParentID, menu.ParentID) || old.SortOrder != menu.SortOrder {
+						if err := s.repo.Place(ctx, menu, now); err != nil {
+							return err
+						}
+						result.Changes = append(result.Changes, models.MenuTreeChange{ID: menu.ID, Before: &old, After: &menu})
+					}
+				}
+				id := menu.ID
+				if err := place(node.Children, &id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := place(items, nil); err != nil {
+			return err
+		}
+
+		var removed []uint64
+		for _, m := range menus {
+			if !kept[m.ID] {
+				old := m
+				removed = append(removed, uint64(m.ID))
+				result.Changes = append(result.Changes, models.MenuTreeChange{ID: m.ID, Before: &old})
+			}
+		}
+		if err := s.repo.DeleteMany(ctx, removed, deletedBy, now); err != nil {
+			return err
+		}
+
+		// The tree is read back as the transaction wrote it
+		if menus, err = s.repo.LockLive(ctx); err != nil {
+			return err
+		}
+		result.Tree = buildMenuTree(menus)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range result.Changes {
+		action := events.ActionUpdated
+		switch {
+		case change.Before == nil:
+			action = events.ActionCreated
+		case change.After == nil:
+			action = events.ActionDeleted
+		}
+		events.EntityChanged("menu", action, strconv.FormatUint(uint64(change.ID), 10))
+	}
+	return result, nil
+}
+
+// checkMenuTree rejects a tree too large or too deep, or listing an item twice
+func checkMenuTree(items []models.MenuNode) error {
+	seen := map[uint]bool{}
+	count := 0
+	var walk func(nodes []models.MenuNode, depth int) error
+	walk = func(nodes []models.MenuNode, depth int) error {
+		if depth > menuTreeMaxDepth {
+			return utils.NewValidationError(fmt.Sprintf("The menu cannot be more than %d levels deep", menuTreeMaxDepth))
+		}
+		for _, node := range nodes {
+			if count++; count > menuTreeMaxNodes {
+				return utils.NewValidationError(fmt.Sprintf("The menu cannot have more than %d items", menuTreeMaxNodes))
+			}
+			if node.ID != nil {
+				if seen[*node.ID] {
+					return utils.NewValidationError(fmt.Sprintf("Menu item %d is listed more than once", *node.ID))
+				}
+				seen[*node.ID] = true
+			}
+			if err := walk(node.Children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(items, 1)
+}
+
+// buildMenuTree nests menus, given in sort order, under their parents
+func buildMenuTree(menus []models.Menu) []models.MenuNode {
+	children := make(map[uint][]models.Menu, len(menus))
+	var roots []models.Menu
+	for _, m := range menus {
+		if m.ParentID == nil {
+			roots = append(roots, m)
+		} else {
+			children[*m.ParentID] = append(children[*m.ParentID], m)
+		}
+	}
+	var nest func(level []models.Menu) []models.MenuNode
+	nest = func(level []models.Menu) []models.MenuNode {
+		nodes := make([]models.MenuNode, 0, len(level))
+		for _, m := range level {
+			id := m.ID
+			nodes = append(nodes, models.MenuNode{
+				ID: &id, Label: m.Label, Url: m.Url, Icon: m.Icon, SortOrder: m.SortOrder,
+				Children: nest(children[m.ID]),
+			})
+		}
+		return nodes
+	}
+	return nest(roots)
 }
 
 // parseUint is a helper function to parse uint from string
@@ -120,13 +298,10 @@ func (s *menuService) setTimestamps(menu *models.Menu) {
 }
 
 // ensureMenuExists checks if a menu exists by ID
-func (s *menuService) ensureMenuExists(menuID uint) error {
-	_, err := s.repo.GetByID(menuID)
+func (s *menuService) ensureMenuExists(ctx context.Context, menuID uint) error {
+	_, err := s.repo.GetByID(ctx, menuID)
 	if err == sql.ErrNoRows {
-		return gin.Error{
-			Err:  fmt.Errorf("menu not found"),
-			Type: gin.ErrorTypePublic,
-		}
+		return utils.NewNotFoundError("menu")
 	}
 	if err != nil {
 		return fmt.Errorf("failed to check menu existence: %w", err)
@@ -135,8 +310,8 @@ func (s *menuService) ensureMenuExists(menuID uint) error {
 }
 
 // retrieveMenuByID fetches a menu by ID with error formatting
-func (s *menuService) retrieveMenuByID(menuID uint) (*models.Menu, error) {
-	menu, err := s.repo.GetByID(menuID)
+func (s *menuService) retrieveMenuByID(ctx context.Context, menuID uint) (*models.Menu, error) {
+	menu, err := s.repo.GetByID(ctx, menuID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve menu: %w", err)
 	}
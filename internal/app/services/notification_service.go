@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/utils"
+)
+
+// NotificationService interface defines business logic for the notification center: storing
+// what the services tell a user, pushing it to their open connections, and listing and
+// marking it read
+type NotificationService interface {
+	// Notify stores n for userID and, once the transaction carried by ctx commits, pushes it
+	// to the user's /ws connections with its stored ID. A failure is logged rather than
+	// returned, so it never undoes the change being announced.
+	Notify(ctx context.Context, userID uint64, n notify.Notification)
+	// ListNotifications returns the user's notifications, newest first, and how many are unread
+	ListNotifications(ctx context.Context, userID uint64, filter models.NotificationFilter) ([]models.Notification, int, error)
+	MarkRead(ctx context.Context, userID uint64, id string) (*models.Notification, error)
+	// MarkManyRead marks the listed notifications, or all of them, read and returns how many
+	// were unread
+	MarkManyRead(ctx context.Context, userID uint64, req models.MarkNotificationsReadRequest) (int64, error)
+}
+
+// notificationService implements NotificationService
+type notificationService struct {
+	repo repositories.NotificationRepository
+	hub  *notify.Hub
+}
+
+// NewNotificationService creates a notification service pushing through hub
+func NewNotificationService(repo repositories.NotificationRepository, hub *notify.Hub) NotificationService {
+	return &notificationService{repo: repo, hub: hub}
+}
+
+// notifyUser hands n for userID to notifications, when there is a notification service
+func notifyUser(ctx context.Context, notifications NotificationService, userID uint64, n notify.Notification) {
+	if notifications != nil {
+		notifications.Notify(ctx, userID, n)
+	}
+}
+
+// Notify stores and pushes a notification
+func (s *notificationService) Notify(ctx context.Context, userID uint64, n notify.Notification) {
+	now := time.Now()
+	stored := models.Notification{
+		UserID:    userID,
+		Type:      n.Type,
+		Message:   truncate(n.Message, 500),
+		CreatedAt: &now,
+	}
+	if len(n.Data) > 0 {
+		data, err := json.Marshal(n.Data)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to encode notification data", "type", n.Type, "error", err)
+			return
+		}
+		stored.Data = data
+	}
+
+	id, err := s.repo.Create(ctx, stored)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to store notification", "type", n.Type, "user_id", userID, "error", err)
+		return
+	}
+	n.ID, n.Time = strconv.FormatUint(id, 10), now
+	repositories.AfterCommit(ctx, func() { s.hub.Send(userID, n) })
+}
+
+// ListNotifications handles listing a user's notifications
+func (s *notificationService) ListNotifications(ctx context.Context, userID uint64, filter models.NotificationFilter) ([]models.Notification, int, error) {
+	list, err := s.repo.List(ctx, userID, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	unread, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, unread, nil
+}
+
+// MarkRead handles marking one of the user's notifications read; marking it again is a no-op
+func (s *notificationService) MarkRead(ctx context.Context, userID uint64, id string) (*models.Notification, error) {
+	notificationID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || notificationID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+	// Another user's notification is not found rather than forbidden, so IDs reveal nothing
+	if _, err := s.repo.GetByID(ctx, userID, notificationID); err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Notification")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+
+	if _, err := s.repo.MarkRead(ctx, userID, []uint64{notificationID}); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, userID, notificationID)
+}
+
+// MarkManyRead handles bulk mark-as-read
+func (s *notificationService) MarkManyRead(ctx context.Context, userID uint64, req models.MarkNotificationsReadRequest) (int64, error) {
+	if req.All == (len(req.IDs) > 0) {
+		return 0, utils.NewValidationError("Send either ids or all: true")
+	}
+	ids := req.IDs
+	if req.All {
+		ids = nil
+	}
+	return s.repo.MarkRead(ctx, userID, ids)
+}
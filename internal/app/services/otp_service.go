@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	smsMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sms",
+		Name:      "messages_total",
+		Help:      "Texts by purpose (verify_phone, login, password_reset) and result: sent, failed, or rate_limited when SMS_RATE_PER_NUMBER refused one.",
+	}, []string{"purpose", "result"})
+	otpVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "otp",
+		Name:      "verifications_total",
+		Help:      "One-time code checks by purpose and result: success, invalid, expired or exhausted (OTP_MAX_ATTEMPTS reached).",
+	}, []string{"purpose", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(smsMessages, otpVerifications)
+}
+
+// otpCleanupGrace is how long expired codes are kept, to tell callers redeeming them late
+// that they expired rather than that they never existed
+const otpCleanupGrace = 24 * time.Hour
+
+// errInvalidCode is the answer to every code that cannot be redeemed, whatever the reason,
+// so guessing learns nothing
+var errInvalidCode = utils.NewValidationError("Invalid or expired code")
+
+// OTPService interface defines business logic for one-time codes sent by SMS: the users'
+// phone numbers, two-factor sign-in and password resets
+type OTPService interface {
+	GetPhone(ctx context.Context, userID uint64) (*models.UserPhone, error)
+	// SetPhone replaces the user's number, once password is theirs, and sends it a code to
+	// verify it with
+	SetPhone(ctx context.Context, userID uint64, number, password string) (*models.OTPChallenge, error)
+	VerifyPhone(ctx context.Context, userID uint64, challenge, code string) (*models.UserPhone, error)
+	// SetTwoFactor turns codes at sign-in on or off, once password is the user's; turning
+	// them on needs a verified number
+	SetTwoFactor(ctx context.Context, userID uint64, enabled bool, password string) (*models.UserPhone, error)
+	// ResetTwoFactor turns a user's codes at sign-in off, for administrators helping a user
+	// who lost their phone
+	ResetTwoFactor(ctx context.Context, id string) (*models.UserPhone, error)
+
+	// StartLogin sends a sign-in code to the user when they have two-factor sign-in on, and
+	// returns nil when they do not
+	StartLogin(ctx context.Context, userID uint64) (*models.OTPChallenge, error)
+	// CompleteLogin redeems a sign-in code and returns the user it was sent to
+	CompleteLogin(ctx context.Context, challenge, code string) (uint64, error)
+	// StartPasswordReset sends a reset code to the verified number of the account with that
+	// email or number. It answers a challenge either way, so it tells no one which accounts
+	// exist; Phone is left out for the same reason.
+	StartPasswordReset(ctx context.Context, email, number string) (*models.OTPChallenge, error)
+	// ResetPassword redeems a reset code, sets the new password and returns the user
+	ResetPassword(ctx context.Context, challenge, code, newPassword string) (uint64, error)
+
+	ListMessages(ctx context.Context, filter models.SMSMessageFilter) ([]models.SMSMessage, error)
+	// RecordStatus stores a delivery report of driver and returns the message before and
+	// after; reports for unknown messages are not found
+	RecordStatus(ctx context.Context, driver string, update sms.StatusUpdate) (old, updated *models.SMSMessage, err error)
+	// DeleteExpiredCodes removes the codes that expired a while before now
+	DeleteExpiredCodes(ctx context.Context, now time.Time) (string, error)
+}
+
+// otpService implements OTPService
+type otpService struct {
+	repo     repositories.OTPRepository
+	messages repositories.SMSMessageRepository
+	userRepo repositories.UserRepository
+	users    UserService
+	hasher   *password.Hasher
+	client   *sms.Client
+	limiter  *ratelimit.Limiter
+	now      func() time.Time
+}
+
+// NewOTPService creates an OTP service sending through client, within SMS_RATE_PER_NUMBER
+// kept in limiter; passwords are reset through users
+func NewOTPService(repo repositories.OTPRepository, messages repositories.SMSMessageRepository, userRepo repositories.UserRepository,
+	users UserService, hasher *password.Hasher, client *sms.Client, limiter *ratelimit.Limiter) OTPService {
+	return &otpService{repo: repo, messages: messages, userRepo: userRepo, users: users, hasher: hasher,
+		client: client, limiter: limiter, now: time.Now}
+}
+
+// GetPhone handles getting the user's phone
+func (s *otpService) GetPhone(ctx context.Context, userID uint64) (*models.UserPhone, error) {
+	p, err := s.repo.GetPhone(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Phone")
+	}
+	return p, err
+}
+
+// SetPhone handles replacing the user's phone
+func (s *otpService) SetPhone(ctx context.Context, userID uint64, number, password string) (*models.OTPChallenge, error) {
+	if err := s.checkPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+	phone, err := sms.NormalizePhone(number, s.client.Config().DefaultCountryCode)
+	if err != nil {
+		return nil, utils.NewValidationError("Invalid phone number", "an international number, or a national one starting with 0")
+	}
+	if err := s.checkUnclaimed(ctx, userID, phone); err != nil {
+		return nil, err
+	}
+	if err := s.allow(ctx, models.OTPVerifyPhone, phone); err != nil {
+		return nil, err
+	}
+
+	// A new number is unverified, and two-factor sign-in stays off until it is
+	now := s.now()
+	if err := s.repo.SavePhone(ctx, models.UserPhone{UserID: userID, Phone: phone, CreatedAt: &now, UpdatedAt: &now}); err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, userID, models.OTPVerifyPhone, phone)
+}
+
+// VerifyPhone handles proving the user received the code sent to their new number
+func (s *otpService) VerifyPhone(ctx context.Context, userID uint64, challenge, code string) (*models.UserPhone, error) {
+	otp, err := s.redeem(ctx, models.OTPVerifyPhone, userID, challenge, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUnclaimed(ctx, userID, otp.Phone); err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.VerifyPhone(ctx, userID, otp.Phone, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// The number was replaced after the code was sent
+		return nil, errInvalidCode
+	}
+	return s.repo.GetPhone(ctx, userID)
+}
+
+// SetTwoFactor handles turning codes at sign-in on or off
+func (s *otpService) SetTwoFactor(ctx context.Context, userID uint64, enabled bool, password string) (*models.UserPhone, error) {
+	if err := s.checkPassword(ctx, userID, password); err != nil {
+		return nil, err
+	}
+	p, err := s.GetPhone(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled && p.VerifiedAt == nil {
+		return nil, utils.NewValidationError("Verify your phone number first")
+	}
+	if _, err := s.repo.SetTwoFactor(ctx, userID, enabled, s.now()); err != nil {
+		return nil, err
+	}
+	return s.repo.GetPhone(ctx, userID)
+}
+
+// ResetTwoFactor handles turning a user's codes at sign-in off
+func (s *otpService) ResetTwoFactor(ctx context.Context, id string) (*models.UserPhone, error) {
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+	found, err := s.repo.SetTwoFactor(ctx, userID, false, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, utils.NewNotFoundError("Phone")
+	}
+	return s.repo.GetPhone(ctx, userID)
+}
+
+// StartLogin handles sending a sign-in code
+func (s *otpService) StartLogin(ctx context.Context, userID uint64) (*models.OTPChallenge, error) {
+	p, err := s.repo.GetPhone(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !p.TwoFactor || p.VerifiedAt == nil {
+		return nil, nil
+	}
+	if err := s.allow(ctx, models.OTPLogin, p.Phone); err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, userID, models.OTPLogin, p.Phone)
+}
+
+// CompleteLogin handles redeeming a sign-in code
+func (s *otpService) CompleteLogin(ctx context.Context, challenge, code string) (uint64, error) {
+	otp, err := s.redeem(ctx, models.OTPLogin, 0, challenge, code)
+	if err != nil {
+		return 0, err
+	}
+	return otp.UserID, nil
+}
+
+// StartPasswordReset handles sending a reset code
+func (s *otpService) StartPasswordReset(ctx context.Context, email, number string) (*models.OTPChallenge, error) {
+	phone, err := s.resetPhone(ctx, email, number)
+	if err != nil {
+		return nil, err
+	}
+	if phone != nil {
+		var challenge *models.OTPChallenge
+		err = s.allow(ctx, models.OTPPasswordReset, phone.Phone)
+		if err == nil {
+			challenge, err = s.issue(ctx, phone.UserID, models.OTPPasswordReset, phone.Phone)
+		}
+		if err == nil {
+			challenge.Phone = ""
+			return challenge, nil
+		}
+		// Failing here would tell the account exists; the user simply gets no code
+		slog.Warn("Password reset code not sent", "user_id", phone.UserID, "error", err)
+	}
+
+	// A challenge no code was sent for, which no code redeems
+	challenge, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &models.OTPChallenge{Challenge: challenge, ExpiresAt: s.now().Add(s.client.Config().OTPTTL)}, nil
+}
+
+// resetPhone finds the verified phone of the active account with email or number, nil when
+// there is none
+func (s *otpService) resetPhone(ctx context.Context, email, number string) (*models.UserPhone, error) {
+	var userID uint64
+	if email != "" {
+		user, err := s.userRepo.GetByEmail(ctx, email)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		userID = user.ID
+	} else {
+		phone, err := sms.NormalizePhone(number, s.client.Config().DefaultCountryCode)
+		if err != nil {
+			return nil, utils.NewValidationError("Invalid phone number", "an international number, or a national one starting with 0")
+		}
+		p, err := s.repo.GetVerifiedPhone(ctx, phone)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		userID = p.UserID
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != 1 {
+		return nil, nil
+	}
+	p, err := s.repo.GetPhone(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.VerifiedAt == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// ResetPassword handles setting a new password with a reset code
+func (s *otpService) ResetPassword(ctx context.Context, challenge, code, newPassword string) (uint64, error) {
+	otp, err := s.redeem(ctx, models.OTPPasswordReset, 0, challenge, code)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.users.UpdateUser(ctx, strconv.FormatUint(otp.UserID, 10), models.UpdateUserRequest{Password: newPassword}); err != nil {
+		return 0, err
+	}
+	return otp.UserID, nil
+}
+
+// ListMessages handles listing the texts sent
+func (s *otpService) ListMessages(ctx context.Context, filter models.SMSMessageFilter) ([]models.SMSMessage, error) {
+	if filter.Phone != "" {
+		phone, err := sms.NormalizePhone(filter.Phone, s.client.Config().DefaultCountryCode)
+		if err != nil {
+			return nil, utils.NewValidationError("Invalid phone number")
+		}
+		filter.Phone = phone
+	}
+	return s.messages.List(ctx, filter)
+}
+
+// RecordStatus handles a delivery report
+func (s *otpService) RecordStatus(ctx context.Context, driver string, update sms.StatusUpdate) (*models.SMSMessage, *models.SMSMessage, error) {
+	old, err := s.messages.GetByProviderID(ctx, driver, update.MessageID)
+	if err == sql.ErrNoRows {
+		return nil, nil, utils.NewNotFoundError("SMS message")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Reports may arrive out of order; a final status is not undone by an earlier one
+	if old.Status == sms.StatusDelivered || (old.Status == sms.StatusFailed && update.Status != sms.StatusDelivered) {
+		return old, old, nil
+	}
+
+	updated := *old
+	now := s.now()
+	updated.Status, updated.UpdatedAt = update.Status, &now
+	if update.Error != "" {
+		reason := truncate(update.Error, 1024)
+		updated.Error = &reason
+	}
+	if err := s.messages.UpdateStatus(ctx, old.ID, updated.Status, updated.Error, now); err != nil {
+		return nil, nil, err
+	}
+	return old, &updated, nil
+}
+
+// DeleteExpiredCodes handles removing the codes that expired
+func (s *otpService) DeleteExpiredCodes(ctx context.Context, now time.Time) (string, error) {
+	deleted, err := s.repo.DeleteExpiredCodes(ctx, now.Add(-otpCleanupGrace))
+	return fmt.Sprintf("deleted %d codes", deleted), err
+}
+
+// checkPassword refuses a password that is not the user's
+func (s *otpService) checkPassword(ctx context.Context, userID uint64, plain string) error {
+	hash, err := s.userRepo.GetPasswordHash(ctx, userID)
+	if err == sql.ErrNoRows {
+		return utils.NewNotFoundError("User")
+	}
+	if err != nil {
+		return err
+	}
+	err = s.hasher.Verify(ctx, hash, plain)
+	if errors.Is(err, password.ErrMismatch) {
+		return utils.NewForbiddenError("Incorrect password")
+	}
+	return err
+}
+
+// checkUnclaimed refuses a number another account has verified, as codes for either would
+// go to the same phone
+func (s *otpService) checkUnclaimed(ctx context.Context, userID uint64, phone string) error {
+	other, err := s.repo.GetVerifiedPhone(ctx, phone)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if other.UserID != userID {
+		return utils.NewConflictError("This phone number belongs to another account", nil)
+	}
+	return nil
+}
+
+// allow takes a send from the number's SMS_RATE_PER_NUMBER budget
+func (s *otpService) allow(ctx context.Context, purpose, phone string) error {
+	if !s.client.Enabled() {
+		return utils.NewExternalError("SMS", sms.ErrDisabled)
+	}
+	rate := s.client.Config().RatePerNumber
+	if !rate.Enabled() || s.limiter == nil {
+		return nil
+	}
+	// When Redis fails the local bucket decides
+	result, _ := s.limiter.Allow(ctx, "sms:"+phone, rate)
+	if result.Allowed {
+		return nil
+	}
+	smsMessages.WithLabelValues(purpose, "rate_limited").Inc()
+	wait := result.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return utils.NewRateLimitedError(fmt.Sprintf("Too many codes sent to this number, try again in %s", wait), nil)
+}
+
+// issue voids the user's open codes for purpose, stores a new one and texts it to phone
+func (s *otpService) issue(ctx context.Context, userID uint64, purpose, phone string) (*models.OTPChallenge, error) {
+	cfg := s.client.Config()
+	code, err := randomCode(cfg.OTPLength)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if err := s.repo.ConsumeOpenCodes(ctx, userID, purpose, now); err != nil {
+		return nil, err
+	}
+	challengeHash := hashToken(challenge)
+	expires := now.Add(cfg.OTPTTL)
+	_, err = s.repo.CreateCode(ctx, models.OTPCode{
+		UserID:        userID,
+		Purpose:       purpose,
+		Phone:         phone,
+		CodeHash:      hashCode(challenge, code),
+		ChallengeHash: challengeHash,
+		ExpiresAt:     expires,
+		CreatedAt:     &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.send(ctx, userID, purpose, phone, otpText(purpose, code, cfg.OTPTTL)); err != nil {
+		return nil, err
+	}
+	return &models.OTPChallenge{Challenge: challenge, Phone: sms.MaskPhone(phone), ExpiresAt: expires}, nil
+}
+
+// send texts phone and records the message, failed or not
+func (s *otpService) send(ctx context.Context, userID uint64, purpose, phone, text string) error {
+	result, sendErr := s.client.Send(ctx, sms.Message{To: phone, Text: text})
+
+	now := s.now()
+	m := models.SMSMessage{
+		Provider:  s.client.Config().Driver,
+		Phone:     phone,
+		Purpose:   purpose,
+		UserID:    &userID,
+		Status:    result.Status,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if result.MessageID != "" {
+		m.ProviderMessageID = &result.MessageID
+	}
+	if sendErr != nil {
+		reason := truncate(sendErr.Error(), 1024)
+		m.Status, m.Error = sms.StatusFailed, &reason
+	}
+	if _, err := s.messages.Create(ctx, m); err != nil {
+		// The text went out all the same; only its delivery reports will find nothing
+		slog.Error("Failed to record SMS message", "purpose", purpose, "error", err)
+	}
+
+	if sendErr != nil {
+		smsMessages.WithLabelValues(purpose, "failed").Inc()
+		if errors.Is(sendErr, context.DeadlineExceeded) {
+			return utils.NewTimeoutError("send the code", sendErr)
+		}
+		return utils.NewExternalError("SMS", sendErr)
+	}
+	smsMessages.WithLabelValues(purpose, "sent").Inc()
+	return nil
+}
+
+// redeem checks code against the code of challenge, which must be for purpose and, unless
+// userID is 0, sent to that user, and marks it used. Every way of failing answers
+// errInvalidCode.
+func (s *otpService) redeem(ctx context.Context, purpose string, userID uint64, challenge, code string) (*models.OTPCode, error) {
+	otp, err := s.repo.GetCodeByChallenge(ctx, hashToken(challenge))
+	if err == sql.ErrNoRows {
+		otpVerifications.WithLabelValues(purpose, "invalid").Inc()
+		return nil, errInvalidCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case otp.Purpose != purpose || otp.ConsumedAt != nil || (userID != 0 && otp.UserID != userID):
+		otpVerifications.WithLabelValues(purpose, "invalid").Inc()
+		return nil, errInvalidCode
+	case !s.now().Before(otp.ExpiresAt):
+		otpVerifications.WithLabelValues(purpose, "expired").Inc()
+		return nil, errInvalidCode
+	case otp.Attempts >= s.client.Config().OTPMaxAttempts:
+		otpVerifications.WithLabelValues(purpose, "exhausted").Inc()
+		return nil, errInvalidCode
+	}
+
+	want := []byte(otp.CodeHash)
+	if subtle.ConstantTimeCompare([]byte(hashCode(challenge, strings.TrimSpace(code))), want) != 1 {
+		otpVerifications.WithLabelValues(purpose, "invalid").Inc()
+		if err := s.repo.IncrementAttempts(ctx, otp.ID); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCode
+	}
+	ok, err := s.repo.ConsumeCode(ctx, otp.ID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		otpVerifications.WithLabelValues(purpose, "invalid").Inc()
+		return nil, errInvalidCode
+	}
+	otpVerifications.WithLabelValues(purpose, "success").Inc()
+	return otp, nil
+}
+
+// otpText is the message carrying code
+func otpText(purpose, code string, ttl time.Duration) string {
+	what := "verification"
+	switch purpose {
+	case models.OTPLogin:
+		what = "sign-in"
+	case models.OTPPasswordReset:
+		what = "password reset"
+	}
+	return fmt.Sprintf("%s is your %s code. It expires in %d minutes. Never share it with anyone.",
+		code, what, int(ttl.Minutes()))
+}
+
+// randomCode returns n random decimal digits
+func randomCode(n int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + d.Int64()))
+	}
+	return b.String(), nil
+}
+
+// randomToken returns 32 random bytes, URL-safe
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashCode hashes code with the challenge it was sent with, which is only stored hashed, so
+// the codes of a leaked table cannot be found by trying the million possible
+func hashCode(challenge, code string) string {
+	return hashToken(challenge + ":" + code)
+}
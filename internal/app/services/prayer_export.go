@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/utils"
+)
+
+// prayerExportRow is a row of the prayer_schedules export: a day at a city
+type prayerExportRow struct {
+	ProvinceID int    `json:"province_id"`
+	Province   string `json:"province"`
+	CityID     int    `json:"city_id"`
+	City       string `json:"city"`
+	models.PrayerDay
+}
+
+// PrayerScheduleExporter exports the prayer times of every day of a year, for every city or
+// for the cities of one province. Params: year, and optionally province_id.
+func PrayerScheduleExporter(prayer PrayerService) Exporter {
+	return Exporter{
+		Kind:        "prayer_schedules",
+		Description: "Prayer times of every day of a year (year), for every city or those of one province (province_id)",
+		Columns:     []string{"province_id", "province", "city_id", "city", "date", "imsak", "subuh", "terbit", "dhuha", "dzuhur", "ashar", "maghrib", "isya"},
+		Check: func(params map[string]string) error {
+			_, _, err := prayerExportParams(params)
+			return err
+		},
+		Export: func(ctx context.Context, params map[string]string, w ExportWriter) error {
+			year, provinceID, err := prayerExportParams(params)
+			if err != nil {
+				return err
+			}
+			return exportPrayerSchedules(ctx, prayer, year, provinceID, w)
+		},
+	}
+}
+
+// prayerExportParams reads the year and the optional province ID of a prayer_schedules job
+func prayerExportParams(params map[string]string) (int, int, error) {
+	year, err := strconv.Atoi(params["year"])
+	if err != nil || year < 1 || year > 9999 {
+		return 0, 0, utils.NewValidationError("params.year must be a year between 1 and 9999")
+	}
+	var provinceID int
+	if v, ok := params["province_id"]; ok {
+		if provinceID, err = strconv.Atoi(v); err != nil || provinceID < 1 {
+			return 0, 0, utils.NewValidationError("params.province_id must be a positive integer")
+		}
+	}
+	return year, provinceID, nil
+}
+
+// exportPrayerSchedules lists the cities first, so the total is known before the first
+// schedule is computed. Cities without coordinates are left out, and the total corrected.
+func exportPrayerSchedules(ctx context.Context, prayer PrayerService, year, provinceID int, w ExportWriter) error {
+	provinces, err := prayer.ListProvinces(ctx)
+	if err != nil {
+		return err
+	}
+	type city struct{ province, city Location }
+	var cities []city
+	for _, p := range provinces {
+		if provinceID != 0 && p.ID != provinceID {
+			continue
+		}
+		list, err := prayer.ListCities(ctx, LocationHash(p.ID))
+		if err != nil {
+			return err
+		}
+		for _, c := range list {
+			cities = append(cities, city{province: p, city: c})
+		}
+	}
+	if provinceID != 0 && len(cities) == 0 {
+		return utils.NewNotFoundError(fmt.Sprintf("Province %d", provinceID))
+	}
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := start.AddDate(1, 0, -1).YearDay()
+	total := int64(len(cities) * days)
+	w.SetTotal(total)
+	for _, c := range cities {
+		schedule, err := prayer.GetSchedule(ctx, LocationHash(c.province.ID), LocationHash(c.city.ID), start, days)
+		if utils.IsNotFound(err) {
+			total -= int64(days)
+			w.SetTotal(total)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, day := range schedule.Days {
+			if err := w.Write(prayerExportRow{ProvinceID: c.province.ID, Province: c.province.Name, CityID: c.city.ID, City: c.city.Name, PrayerDay: day}); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
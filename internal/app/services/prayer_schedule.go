@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"adminbe/internal/app/repositories"
+)
+
+// minDaysPerWorker keeps chunks large enough that handing work to a goroutine costs
+// less than computing the days inline
+const minDaysPerWorker = 8
+
+// schedulePool bounds the goroutines computing multi-day schedules across all requests.
+// The requesting goroutine always computes a share itself and only borrows helpers that
+// are free, so a busy pool degrades to sequential computation instead of queueing.
+type schedulePool struct {
+	slots chan struct{}
+}
+
+// newSchedulePool creates a pool letting a schedule use up to workers goroutines, the
+// caller included; workers <= 0 uses GOMAXPROCS and 1 computes sequentially
+func newSchedulePool(workers int) *schedulePool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &schedulePool{slots: make(chan struct{}, workers-1)}
+}
+
+// tryAcquire reserves a helper slot without blocking
+func (p *schedulePool) tryAcquire() bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a helper slot
+func (p *schedulePool) release() { <-p.slots }
+
+// dayWriter fills out with the schedule for one day. date is in YYYY-MM-DD form and
+// times points at a buffer reused for every day of a chunk, so values must be copied out.
+type dayWriter[T any] func(out *T, date string, times *PrayerTimes)
+
+// computeDays computes the schedules of days consecutive days from start into a slice
+// allocated once up front. Chunks are contiguous, so each goroutine writes only its own
+// part of the slice and no locking or reordering is needed.
+func computeDays[T any](ctx context.Context, s *prayerService, location *repositories.LocationData, start time.Time, days int, write dayWriter[T]) ([]T, error) {
+	out := make([]T, days)
+	if days == 0 {
+		return out, nil
+	}
+
+	chunks := max(days/minDaysPerWorker, 1)
+	chunks = min(chunks, cap(s.pool.slots)+1) // helpers plus the caller
+	size := (days + chunks - 1) / chunks
+
+	var wg sync.WaitGroup
+	for from := size; from < days; from += size {
+		to := min(from+size, days)
+		if !s.pool.tryAcquire() {
+			computeChunk(ctx, s, location, start, out, from, to, write)
+			continue
+		}
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			defer s.pool.release()
+			computeChunk(ctx, s, location, start, out, from, to, write)
+		}(from, to)
+	}
+	computeChunk(ctx, s, location, start, out, 0, min(size, days), write)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// computeChunk fills out[from:to]; it stops early once ctx is done
+func computeChunk[T any](ctx context.Context, s *prayerService, location *repositories.LocationData, start time.Time, out []T, from, to int, write dayWriter[T]) {
+	var times PrayerTimes
+	var buf [len("2006-01-02")]byte
+	method := s.method(ctx)
+	for i := from; i < to; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+		date := start.AddDate(0, 0, i)
+		s.calculatePrayerTimes(location, date, method, &times)
+		write(&out[i], string(date.AppendFormat(buf[:0], "2006-01-02")), &times)
+	}
+}
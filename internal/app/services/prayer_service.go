@@ -5,11 +5,15 @@ import (
 	"crypto/md5"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
 )
 
 // PrayerTimes holds calculated prayer times
@@ -29,23 +33,64 @@ type CityAPIResponse struct {
 	KabkoNama string `json:"kabkoNama"`
 }
 
-// PrayerService interface defines business logic for prayer calculations
+// Location is a province or city/regency
+type Location struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Schedule is the prayer times of consecutive days at a location, computed with Method.
+// Hijriah is set for the fasting period only.
+type Schedule struct {
+	Province string
+	City     string
+	Hijriah  string
+	Method   string
+	Days     []models.PrayerDay
+	// Zone is the location's time zone, which the times are in
+	Zone *time.Location
+}
+
+// PrayerService interface defines business logic for prayer calculations.
+// The Get*Schedule, GetAllProvinces and GetCitiesByProvince methods answer in the shapes
+// of the PHP apiv1 API; the others return plain results and AppErrors for /api/v2.
 type PrayerService interface {
 	GetPrayerSchedule(ctx context.Context, provinceID, cityID, dateStr string) (*models.ShalatResponse, error)
 	GetAllProvinces(ctx context.Context) ([]*ProvinceAPIResponse, error)
 	GetCitiesByProvince(ctx context.Context, provinceHash string) ([]*CityAPIResponse, error)
 	GetMonthlyPrayerSchedule(ctx context.Context, year, month, provinceHash, cityHash string) (*models.MonthlyShalatResponse, error)
+	GetYearlyPrayerSchedule(ctx context.Context, year, provinceHash, cityHash string) (*models.MonthlyShalatResponse, error)
 	GetImsakiyahSchedule(ctx context.Context, year string, provinceHash, cityHash string) (*models.ImsakiyahResponse, error)
+	ListProvinces(ctx context.Context) ([]Location, error)
+	ListCities(ctx context.Context, provinceHash string) ([]Location, error)
+	GetSchedule(ctx context.Context, provinceHash, cityHash string, start time.Time, days int) (*Schedule, error)
+	GetFastingSchedule(ctx context.Context, year int, provinceHash, cityHash string) (*Schedule, error)
+	// GetFastingPeriod returns year's fasting period as its Hijri year, first day and number
+	// of days; a year without one is a not found AppError
+	GetFastingPeriod(ctx context.Context, year int) (string, time.Time, int, error)
 }
 
 // prayerService implements PrayerService
 type prayerService struct {
-	repo repositories.PrayerRepository
+	repo          repositories.PrayerRepository
+	pool          *schedulePool
+	defaultMethod string
 }
 
-// NewPrayerService creates a new prayer service
-func NewPrayerService(repo repositories.PrayerRepository) PrayerService {
-	return &prayerService{repo: repo}
+// NewPrayerService creates a new prayer service.
+// Monthly, yearly and imsakiyah schedules are computed by up to workers goroutines, with the
+// helpers shared by all requests (GOMAXPROCS when workers <= 0, sequential when 1). Times are
+// computed with method (see models.PrayerMethods) unless the tenant chose another.
+func NewPrayerService(repo repositories.PrayerRepository, workers int, method string) PrayerService {
+	return &prayerService{repo: repo, pool: newSchedulePool(workers), defaultMethod: method}
+}
+
+// method returns the calculation method of the tenant of ctx
+func (s *prayerService) method(ctx context.Context) string {
+	if method := tenant.SettingsFrom(ctx).PrayerMethod; method != "" {
+		return method
+	}
+	return s.defaultMethod
 }
 
 // Indonesian day and month names - initialized once
@@ -59,11 +104,13 @@ func formatIndonesianDate(dateParsed time.Time) string {
 	return formattedDate
 }
 
-// calculatePrayerTimes returns placeholder prayer times (to be implemented with actual astronomical calculations)
-func (s *prayerService) calculatePrayerTimes(locationData *repositories.LocationData, dateParsed time.Time) *PrayerTimes {
+// calculatePrayerTimes writes placeholder prayer times into dst (to be implemented with actual
+// astronomical calculations, whose angles will depend on method; the placeholder ignores it).
+// It is called concurrently for multi-day schedules, so it must only read locationData.
+func (s *prayerService) calculatePrayerTimes(locationData *repositories.LocationData, dateParsed time.Time, method string, dst *PrayerTimes) {
 	// TODO: Implement actual prayer time calculation using jadwal_sholat_perhari logic
 	// For now, returning placeholder times based on Indonesian standard times
-	return &PrayerTimes{
+	*dst = PrayerTimes{
 		Imsak:   "04:30",
 		Subuh:   "04:45",
 		Terbit:  "06:00",
@@ -75,6 +122,51 @@ func (s *prayerService) calculatePrayerTimes(locationData *repositories.Location
 	}
 }
 
+// monthlyItem copies one day's times into a monthly/yearly schedule row
+func monthlyItem(out *models.MonthlyScheduleItem, date string, t *PrayerTimes) {
+	*out = models.MonthlyScheduleItem{
+		Date:    date,
+		Imsak:   t.Imsak,
+		Subuh:   t.Subuh,
+		Terbit:  t.Terbit,
+		Dhuha:   t.Dhuha,
+		Dzuhur:  t.Dzuhur,
+		Ashar:   t.Ashar,
+		Maghrib: t.Maghrib,
+		Isya:    t.Isya,
+	}
+}
+
+// prayerDay copies one day's times into a /api/v2 schedule row
+func prayerDay(out *models.PrayerDay, date string, t *PrayerTimes) {
+	*out = models.PrayerDay{
+		Date:    date,
+		Imsak:   t.Imsak,
+		Subuh:   t.Subuh,
+		Terbit:  t.Terbit,
+		Dhuha:   t.Dhuha,
+		Dzuhur:  t.Dzuhur,
+		Ashar:   t.Ashar,
+		Maghrib: t.Maghrib,
+		Isya:    t.Isya,
+	}
+}
+
+// imsakiyahItem copies one day's times into an imsakiyah schedule row
+func imsakiyahItem(out *models.ImsakiyahScheduleItem, date string, t *PrayerTimes) {
+	*out = models.ImsakiyahScheduleItem{
+		Date:    date,
+		Imsak:   t.Imsak,
+		Subuh:   t.Subuh,
+		Terbit:  t.Terbit,
+		Dhuha:   t.Dhuha,
+		Dzuhur:  t.Dzuhur,
+		Ashar:   t.Ashar,
+		Maghrib: t.Maghrib,
+		Isya:    t.Isya,
+	}
+}
+
 // GetPrayerSchedule retrieves prayer schedule for given location and date
 func (s *prayerService) GetPrayerSchedule(ctx context.Context, provinceID, cityID, dateStr string) (*models.ShalatResponse, error) {
 	// Parse and validate date
@@ -103,7 +195,8 @@ func (s *prayerService) GetPrayerSchedule(ctx context.Context, provinceID, cityI
 	formattedDate := formatIndonesianDate(dateParsed)
 
 	// Calculate prayer times
-	prayerTimes := s.calculatePrayerTimes(locationData, dateParsed)
+	var prayerTimes PrayerTimes
+	s.calculatePrayerTimes(locationData, dateParsed, s.method(ctx), &prayerTimes)
 
 	// Build response
 	response := &models.ShalatResponse{
@@ -127,78 +220,70 @@ func (s *prayerService) GetPrayerSchedule(ctx context.Context, provinceID, cityI
 	return response, nil
 }
 
-// GetImsakiyahSchedule retrieves fasting/imsakiyah prayer schedule (matching PHP getApiimsakiyah)
-func (s *prayerService) GetImsakiyahSchedule(ctx context.Context, year string, provinceHash, cityHash string) (*models.ImsakiyahResponse, error) {
-	// Convert year string to int for repository
-	yearInt := 0
-	if year != "" {
-		fmt.Sscanf(year, "%d", &yearInt)
-	}
+// jakartaProvinceID and jakartaCityID get special treatment, as in the PHP API: the city
+// is named KOTA JAKARTA and the province lists two fixed cities
+const (
+	jakartaProvinceID = 13
+	jakartaCityID     = 192
+)
 
-	// Get fasting data first
-	fastingData, err := s.repo.GetFastingData(ctx, yearInt)
-	if err == sql.ErrNoRows {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: fmt.Sprintf("Jadwal Imsakiyah tahun %s belum ditetapkan", year),
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
-	}
-	if err != nil {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: "Database error",
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
-	}
+// LocationHash returns the MD5 code a province or city ID has in the apiv1 API
+func LocationHash(id int) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(strconv.Itoa(id))))
+}
 
-	// Validate fasting data
-	if fastingData.TglHijriah == "" || fastingData.TglStart == "" || fastingData.TglEnd == "" {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: fmt.Sprintf("Jadwal Imsakiyah tahun %s belum ditetapkan", year),
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
+// place is the location of a multi-day schedule
+type place struct {
+	data     *repositories.LocationData
+	province string
+	city     string
+}
+
+// zone returns the time zone of the place, stored as hours east of UTC (7 for WIB); the
+// local one when that cannot be read
+func (p *place) zone() *time.Location {
+	hours, err := strconv.ParseFloat(strings.TrimPrefix(*p.data.TimeZone, "+"), 64)
+	if err != nil || hours < -12 || hours > 14 {
+		return time.Local
 	}
+	return time.FixedZone(fmt.Sprintf("UTC%+g", hours), int(hours*3600))
+}
 
-	// Get location data
+// locate finds the location of a multi-day schedule by the MD5 codes of its province and
+// city. A location without coordinates cannot be scheduled, so it is not found either.
+func (s *prayerService) locate(ctx context.Context, provinceHash, cityHash string) (*place, error) {
 	locationData, err := s.repo.GetLocationDataByHashes(ctx, provinceHash, cityHash)
 	if err == sql.ErrNoRows {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: "Error Parameter",
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
+		return nil, utils.NewNotFoundError("location")
 	}
 	if err != nil {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: "Database error",
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
+		return nil, err
 	}
-
-	// Parameter validation (matching PHP logic)
 	if locationData.Latitude == nil || *locationData.Latitude == "" ||
 		locationData.Longitude == nil || *locationData.Longitude == "" ||
-		locationData.TimeZone == nil || *locationData.TimeZone == "" ||
-		year == "" {
-		return &models.ImsakiyahResponse{
-			Status:  0,
-			Message: "Error Parameter",
-			Data:    []models.ImsakiyahScheduleItem{},
-		}, nil
+		locationData.TimeZone == nil || *locationData.TimeZone == "" {
+		return nil, utils.NewNotFoundError("location")
 	}
 
 	// Handle Jakarta special case
 	cityName := locationData.CityName
-	if cityHash == fmt.Sprintf("%x", md5.Sum([]byte("192"))) {
+	if cityHash == LocationHash(jakartaCityID) {
 		cityName = "KOTA JAKARTA"
 	}
+	return &place{data: locationData, province: locationData.ProvinceName, city: cityName}, nil
+}
 
-	// TODO: Implement actual jadwal_imsak_by_date logic
-	// For now, generate placeholder fasting schedule for the period
-	fastingSchedule := []models.ImsakiyahScheduleItem{}
+// fastingPeriod returns the fasting period of year as its Hijri year, first day and number
+// of days
+func (s *prayerService) fastingPeriod(ctx context.Context, year int) (string, time.Time, int, error) {
+	fastingData, err := s.repo.GetFastingData(ctx, year)
+	if err == sql.ErrNoRows || (err == nil &&
+		(fastingData.TglHijriah == "" || fastingData.TglStart == "" || fastingData.TglEnd == "")) {
+		return "", time.Time{}, 0, utils.NewNotFoundError(fmt.Sprintf("fasting period of %d", year))
+	}
+	if err != nil {
+		return "", time.Time{}, 0, err
+	}
 
 	// Parse date range
 	startDate, err := time.Parse("2006-01-02", fastingData.TglStart)
@@ -210,28 +295,69 @@ func (s *prayerService) GetImsakiyahSchedule(ctx context.Context, year string, p
 		endDate = startDate.AddDate(0, 0, 30) // 30 day fallback
 	}
 
-	// Generate dates for fasting period
-	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
-		dateStr := d.Format("2006-01-02")
-		fastingSchedule = append(fastingSchedule, models.ImsakiyahScheduleItem{
-			Date:    dateStr,
-			Imsak:   "04:30",
-			Subuh:   "04:45",
-			Terbit:  "06:00",
-			Dhuha:   "07:00",
-			Dzuhur:  "12:00",
-			Ashar:   "15:00",
-			Maghrib: "18:00",
-			Isya:    "19:30",
-		})
+	days := 0
+	if !endDate.Before(startDate) {
+		days = int(endDate.Sub(startDate).Hours()/24) + 1
+	}
+	return fastingData.TglHijriah, startDate, days, nil
+}
+
+// legacyMessage is the message an apiv1 status 0 body gives for a failed location lookup
+func legacyMessage(err error) string {
+	if utils.IsNotFound(err) {
+		return "Error Parameter"
+	}
+	return "Database error"
+}
+
+// GetImsakiyahSchedule retrieves fasting/imsakiyah prayer schedule (matching PHP getApiimsakiyah)
+func (s *prayerService) GetImsakiyahSchedule(ctx context.Context, year string, provinceHash, cityHash string) (*models.ImsakiyahResponse, error) {
+	// Convert year string to int for repository
+	yearInt := 0
+	if year != "" {
+		fmt.Sscanf(year, "%d", &yearInt)
+	}
+
+	// Get fasting data first
+	hijriah, startDate, days, err := s.fastingPeriod(ctx, yearInt)
+	if err != nil {
+		message := "Database error"
+		if utils.IsNotFound(err) {
+			message = fmt.Sprintf("Jadwal Imsakiyah tahun %s belum ditetapkan", year)
+		}
+		return &models.ImsakiyahResponse{
+			Status:  0,
+			Message: message,
+			Data:    []models.ImsakiyahScheduleItem{},
+		}, nil
+	}
+
+	// Get location data (matching PHP parameter validation)
+	location, err := s.locate(ctx, provinceHash, cityHash)
+	if err == nil && year == "" {
+		err = utils.NewValidationError("year is required")
+	}
+	if err != nil {
+		return &models.ImsakiyahResponse{
+			Status:  0,
+			Message: legacyMessage(err),
+			Data:    []models.ImsakiyahScheduleItem{},
+		}, nil
+	}
+
+	// TODO: Implement actual jadwal_imsak_by_date logic
+	// Compute every day of the fasting period in parallel
+	fastingSchedule, err := computeDays(ctx, s, location.data, startDate, days, imsakiyahItem)
+	if err != nil {
+		return nil, err
 	}
 
 	return &models.ImsakiyahResponse{
 		Status:  1,
 		Message: "Success",
-		Prov:    locationData.ProvinceName,
-		Kabko:   cityName,
-		Hijriah: fastingData.TglHijriah,
+		Prov:    location.province,
+		Kabko:   location.city,
+		Hijriah: hijriah,
 		Tahun:   year,
 		Data:    fastingSchedule,
 	}, nil
@@ -239,107 +365,157 @@ func (s *prayerService) GetImsakiyahSchedule(ctx context.Context, year string, p
 
 // GetMonthlyPrayerSchedule retrieves prayer schedule for entire month (matching PHP getApiSholatbln)
 func (s *prayerService) GetMonthlyPrayerSchedule(ctx context.Context, year, month, provinceHash, cityHash string) (*models.MonthlyShalatResponse, error) {
-	// Retrieve location data using repository
-	locationData, err := s.repo.GetLocationDataByHashes(ctx, provinceHash, cityHash)
-	if err == sql.ErrNoRows {
-		return &models.MonthlyShalatResponse{
-			Status:  0,
-			Message: "Error Parameter",
-			Data:    []models.MonthlyScheduleItem{},
-		}, nil
+	// Retrieve location data, then validate parameters (matching PHP logic)
+	location, err := s.locate(ctx, provinceHash, cityHash)
+	if err == nil && (year == "" || month == "") {
+		err = utils.NewValidationError("year and month are required")
 	}
 	if err != nil {
 		return &models.MonthlyShalatResponse{
 			Status:  0,
-			Message: "Database error",
+			Message: legacyMessage(err),
 			Data:    []models.MonthlyScheduleItem{},
 		}, nil
 	}
 
-	// Parameter validation (matching PHP logic)
-	if locationData.Latitude == nil || *locationData.Latitude == "" ||
-		locationData.Longitude == nil || *locationData.Longitude == "" ||
-		locationData.TimeZone == nil || *locationData.TimeZone == "" ||
-		year == "" || month == "" {
+	// TODO: Implement actual jadwal_sholat_perbulan logic
+	// An unparseable year or month yields an empty schedule, as before
+	days := 0
+	first, err := time.Parse("2006-01-02", fmt.Sprintf("%s-%s-01", year, month))
+	if err == nil {
+		days = first.AddDate(0, 1, -1).Day()
+	}
+	monthlyData, err := computeDays(ctx, s, location.data, first, days, monthlyItem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MonthlyShalatResponse{
+		Status:  1,
+		Message: "Success",
+		Prov:    location.province,
+		Kabko:   location.city,
+		Data:    monthlyData,
+	}, nil
+}
+
+// GetYearlyPrayerSchedule retrieves the prayer schedule for every day of a year, in the
+// same shape as the monthly schedule
+func (s *prayerService) GetYearlyPrayerSchedule(ctx context.Context, year, provinceHash, cityHash string) (*models.MonthlyShalatResponse, error) {
+	location, err := s.locate(ctx, provinceHash, cityHash)
+	first, parseErr := time.Parse("2006", year)
+	if err == nil && parseErr != nil {
+		err = utils.NewValidationError("invalid year")
+	}
+	if err != nil {
 		return &models.MonthlyShalatResponse{
 			Status:  0,
-			Message: "Error Parameter",
+			Message: legacyMessage(err),
 			Data:    []models.MonthlyScheduleItem{},
 		}, nil
 	}
 
-	// Handle Jakarta special case
-	cityName := locationData.CityName
-	if cityHash == fmt.Sprintf("%x", md5.Sum([]byte("192"))) {
-		cityName = "KOTA JAKARTA"
-	}
-
-	// TODO: Implement actual jadwal_sholat_perbulan logic
-	// For now, generate placeholder monthly schedule
-	monthlyData := []models.MonthlyScheduleItem{}
-
-	// Generate dates for the month (proper date validation)
-	for day := 1; day <= 31; day++ {
-		dateStr := fmt.Sprintf("%s-%s-%02d", year, month, day)
-		if _, err := time.Parse("2006-01-02", dateStr); err != nil {
-			break // Stop if invalid date (e.g., Feb 30)
-		}
-
-		monthlyData = append(monthlyData, models.MonthlyScheduleItem{
-			Date:    dateStr,
-			Imsak:   "04:30",
-			Subuh:   "04:45",
-			Terbit:  "06:00",
-			Dhuha:   "07:00",
-			Dzuhur:  "12:00",
-			Ashar:   "15:00",
-			Maghrib: "18:00",
-			Isya:    "19:30",
-		})
+	days := first.AddDate(1, 0, -1).YearDay() // 365, or 366 in a leap year
+	yearlyData, err := computeDays(ctx, s, location.data, first, days, monthlyItem)
+	if err != nil {
+		return nil, err
 	}
 
 	return &models.MonthlyShalatResponse{
 		Status:  1,
 		Message: "Success",
-		Prov:    locationData.ProvinceName,
-		Kabko:   cityName,
-		Data:    monthlyData,
+		Prov:    location.province,
+		Kabko:   location.city,
+		Data:    yearlyData,
 	}, nil
 }
 
-// GetCitiesByProvince retrieves cities/regencies by province hash (matching PHP getApiKabko special logic)
-func (s *prayerService) GetCitiesByProvince(ctx context.Context, provinceHash string) ([]*CityAPIResponse, error) {
-	// Special handling for Jakarta (province hash for ID=13)
-	jakartaHash := fmt.Sprintf("%x", md5.Sum([]byte("13")))
+// GetSchedule computes days consecutive days of prayer times from start for the location
+// with the given MD5 codes. A missing location is a not found AppError.
+func (s *prayerService) GetSchedule(ctx context.Context, provinceHash, cityHash string, start time.Time, days int) (*Schedule, error) {
+	location, err := s.locate(ctx, provinceHash, cityHash)
+	if err != nil {
+		return nil, err
+	}
+	scheduleDays, err := computeDays(ctx, s, location.data, start, days, prayerDay)
+	if err != nil {
+		return nil, err
+	}
+	return &Schedule{Province: location.province, City: location.city, Method: s.method(ctx), Days: scheduleDays, Zone: location.zone()}, nil
+}
+
+// GetFastingSchedule computes the prayer times of every day of year's fasting period for
+// the location with the given MD5 codes. A year without a fasting period, like a missing
+// location, is a not found AppError.
+func (s *prayerService) GetFastingSchedule(ctx context.Context, year int, provinceHash, cityHash string) (*Schedule, error) {
+	hijriah, start, days, err := s.fastingPeriod(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.GetSchedule(ctx, provinceHash, cityHash, start, days)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Hijriah = hijriah
+	return schedule, nil
+}
+
+// GetFastingPeriod looks up the fasting period of year
+func (s *prayerService) GetFastingPeriod(ctx context.Context, year int) (string, time.Time, int, error) {
+	return s.fastingPeriod(ctx, year)
+}
 
-	if provinceHash == jakartaHash {
+// ListCities retrieves the cities/regencies of a province by its MD5 code, with the same
+// Jakarta special case as the PHP getApiKabko
+func (s *prayerService) ListCities(ctx context.Context, provinceHash string) ([]Location, error) {
+	if provinceHash == LocationHash(jakartaProvinceID) {
 		// Return hardcoded Jakarta cities (matching PHP logic)
-		return []*CityAPIResponse{
-			{
-				KabkoKode: fmt.Sprintf("%x", md5.Sum([]byte("192"))),
-				KabkoNama: "KOTA JAKARTA",
-			},
-			{
-				KabkoKode: fmt.Sprintf("%x", md5.Sum([]byte("190"))),
-				KabkoNama: "KAB. KEPULAUAN SERIBU",
-			},
+		return []Location{
+			{ID: jakartaCityID, Name: "KOTA JAKARTA"},
+			{ID: 190, Name: "KAB. KEPULAUAN SERIBU"},
 		}, nil
 	}
 
 	// For non-Jakarta provinces, get cities from database
-	cities, err := s.repo.GetCitiesByProvince(ctx, provinceHash)
+	cities, err := s.repo.GetCitiesByProvince(database.WithReplica(ctx), provinceHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve cities: %w", err)
 	}
 
-	var response []*CityAPIResponse
+	locations := make([]Location, 0, len(cities))
 	for _, city := range cities {
-		// Create MD5 hash of city ID (matching PHP md5() function)
-		hashedID := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d", city.ID))))
+		locations = append(locations, Location{ID: city.ID, Name: strings.ToUpper(city.Title)})
+	}
+	return locations, nil
+}
+
+// ListProvinces retrieves all provinces
+func (s *prayerService) ListProvinces(ctx context.Context) ([]Location, error) {
+	provinces, err := s.repo.GetAllProvinces(database.WithReplica(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve provinces: %w", err)
+	}
+
+	locations := make([]Location, 0, len(provinces))
+	for _, province := range provinces {
+		locations = append(locations, Location{ID: province.ID, Name: strings.ToUpper(province.Title)})
+	}
+	return locations, nil
+}
+
+// GetCitiesByProvince retrieves cities/regencies by province hash (matching PHP getApiKabko special logic)
+func (s *prayerService) GetCitiesByProvince(ctx context.Context, provinceHash string) ([]*CityAPIResponse, error) {
+	cities, err := s.ListCities(ctx, provinceHash)
+	if err != nil {
+		return nil, err
+	}
 
+	var response []*CityAPIResponse
+	for _, city := range cities {
+		// MD5 hash of the city ID (matching PHP md5() function)
 		response = append(response, &CityAPIResponse{
-			KabkoKode: hashedID,
-			KabkoNama: strings.ToUpper(city.Title),
+			KabkoKode: LocationHash(city.ID),
+			KabkoNama: city.Name,
 		})
 	}
 
@@ -348,19 +524,17 @@ func (s *prayerService) GetCitiesByProvince(ctx context.Context, provinceHash st
 
 // GetAllProvinces retrieves all provinces with MD5 hashed IDs (matching PHP getApiProv)
 func (s *prayerService) GetAllProvinces(ctx context.Context) ([]*ProvinceAPIResponse, error) {
-	provinces, err := s.repo.GetAllProvinces(ctx)
+	provinces, err := s.ListProvinces(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve provinces: %w", err)
+		return nil, err
 	}
 
 	var response []*ProvinceAPIResponse
 	for _, province := range provinces {
-		// Create MD5 hash of province ID (matching PHP md5() function)
-		hashedID := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d", province.ID))))
-
+		// MD5 hash of the province ID (matching PHP md5() function)
 		response = append(response, &ProvinceAPIResponse{
-			ProvKode: hashedID,
-			ProvNama: strings.ToUpper(province.Title),
+			ProvKode: LocationHash(province.ID),
+			ProvNama: province.Name,
 		})
 	}
 
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/jsonenc"
+)
+
+// stubPrayerRepository returns a fixed, valid location for every lookup, so the benchmarks
+// measure schedule computation only
+type stubPrayerRepository struct{}
+
+func (stubPrayerRepository) location() *repositories.LocationData {
+	lat, lng, tz, h := "-6.1751", "106.8650", "7", 8
+	return &repositories.LocationData{ID: 192, Latitude: &lat, Longitude: &lng, TimeZone: &tz, Elevation: &h,
+		ProvinceName: "DKI JAKARTA", CityName: "KOTA JAKARTA PUSAT"}
+}
+
+func (r stubPrayerRepository) GetLocationData(context.Context, string, string) (*repositories.LocationData, error) {
+	return r.location(), nil
+}
+
+func (r stubPrayerRepository) GetLocationDataByHashes(context.Context, string, string) (*repositories.LocationData, error) {
+	return r.location(), nil
+}
+
+func (stubPrayerRepository) GetAllProvinces(context.Context) ([]*repositories.ProvinceData, error) {
+	return nil, nil
+}
+
+func (stubPrayerRepository) GetCitiesByProvince(context.Context, string) ([]*repositories.CityData, error) {
+	return nil, nil
+}
+
+func (stubPrayerRepository) GetFastingData(context.Context, int) (*models.FastingData, error) {
+	return nil, sql.ErrNoRows
+}
+
+// BenchmarkYearlyPrayerSchedule times the 366-day schedule behind POST
+// /api/apiv1/getApiSholatthn, computed sequentially and with the shared worker pool
+func BenchmarkYearlyPrayerSchedule(b *testing.B) {
+	for _, bm := range []struct {
+		name    string
+		workers int
+	}{{"sequential", 1}, {"pooled", 0}} {
+		b.Run(bm.name, func(b *testing.B) {
+			svc := NewPrayerService(stubPrayerRepository{}, bm.workers, models.PrayerMethodKemenag)
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				resp, err := svc.GetYearlyPrayerSchedule(ctx, "2024", "prov", "kabko")
+				if err != nil || len(resp.Data) != 366 {
+					b.Fatalf("unexpected yearly schedule: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMonthlyPrayerSchedule times the schedule of a leap February with the worker pool
+func BenchmarkMonthlyPrayerSchedule(b *testing.B) {
+	svc := NewPrayerService(stubPrayerRepository{}, 0, models.PrayerMethodKemenag)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp, err := svc.GetMonthlyPrayerSchedule(ctx, "2024", "02", "prov", "kabko")
+		if err != nil || len(resp.Data) != 29 {
+			b.Fatalf("unexpected monthly schedule: %v", err)
+		}
+	}
+}
+
+// BenchmarkYearlyPrayerScheduleJSON times encoding a yearly schedule with each encoder that
+// SHALAT_JSON_ENCODER can select, after checking its output matches encoding/json
+func BenchmarkYearlyPrayerScheduleJSON(b *testing.B) {
+	resp, err := NewPrayerService(stubPrayerRepository{}, 1, models.PrayerMethodKemenag).
+		GetYearlyPrayerSchedule(context.Background(), "2024", "prov", "kabko")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range []string{jsonenc.NameStd, jsonenc.NameJsoniter, jsonenc.NameSonic} {
+		b.Run(name, func(b *testing.B) {
+			enc, err := jsonenc.New(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := jsonenc.Verify(enc, resp); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := enc.Marshal(resp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var prayerMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "prayer",
+	Name:      "chat_messages_total",
+	Help:      "Prayer time messages sent to subscribed chats by channel and result: succeeded, failed, or deactivated for an unreachable chat.",
+}, []string{"channel", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(prayerMessages)
+}
+
+// prayerSendBatch is how many subscriptions a send run reads at a time
+const prayerSendBatch = 500
+
+// PrayerSubscriptionService interface defines business logic for the chats subscribed to
+// prayer times: managing the subscriptions and sending them their messages through
+// the chat bots
+type PrayerSubscriptionService interface {
+	ListSubscriptions(ctx context.Context, filter models.PrayerSubscriptionFilter) ([]models.PrayerSubscription, error)
+	GetSubscription(ctx context.Context, id string) (*models.PrayerSubscription, error)
+	CreateSubscription(ctx context.Context, req models.CreatePrayerSubscriptionRequest, createdBy *uint64) (*models.PrayerSubscription, error)
+	UpdateSubscription(ctx context.Context, id string, req models.UpdatePrayerSubscriptionRequest) (*models.PrayerSubscription, error)
+	DeleteSubscription(ctx context.Context, id string) (*models.PrayerSubscription, error)
+	// SendTest sends the subscription its message for today now, whether or not it is
+	// active or was already sent one today
+	SendTest(ctx context.Context, id string) (*models.PrayerSubscription, error)
+	// SendDue sends every active subscription of kind its message for day, skipping those
+	// already sent one that day, and summarizes the run. Imsakiyah messages only go out on
+	// the days of the fasting period.
+	SendDue(ctx context.Context, kind string, day time.Time) (string, error)
+}
+
+// prayerSubscriptionService implements PrayerSubscriptionService
+type prayerSubscriptionService struct {
+	repo   repositories.PrayerSubscriptionRepository
+	prayer PrayerService
+	codes  *locationcode.Codec
+	bot    *chatbot.Bot
+}
+
+// NewPrayerSubscriptionService creates a prayer subscription service sending through bot,
+// with locations in the codes of codes
+func NewPrayerSubscriptionService(repo repositories.PrayerSubscriptionRepository, prayer PrayerService, codes *locationcode.Codec, bot *chatbot.Bot) PrayerSubscriptionService {
+	return &prayerSubscriptionService{repo: repo, prayer: prayer, codes: codes, bot: bot}
+}
+
+// withCodes fills in the location codes of sub
+func (s *prayerSubscriptionService) withCodes(sub *models.PrayerSubscription) *models.PrayerSubscription {
+	sub.Province = s.codes.Encode(locationcode.Province, sub.ProvinceID)
+	sub.City = s.codes.Encode(locationcode.City, sub.CityID)
+	return sub
+}
+
+// ListSubscriptions handles listing subscriptions
+func (s *prayerSubscriptionService) ListSubscriptions(ctx context.Context, filter models.PrayerSubscriptionFilter) ([]models.PrayerSubscription, error) {
+	list, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prayer subscriptions: %w", err)
+	}
+	for i := range list {
+		s.withCodes(&list[i])
+	}
+	return list, nil
+}
+
+// GetSubscription handles getting a subscription by ID
+func (s *prayerSubscriptionService) GetSubscription(ctx context.Context, id string) (*models.PrayerSubscription, error) {
+	subscriptionID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || subscriptionID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+
+	sub, err := s.repo.GetByID(ctx, subscriptionID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Prayer subscription")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prayer subscription: %w", err)
+	}
+	return s.withCodes(sub), nil
+}
+
+// locate decodes the location codes of a request, checking the city is in the province and
+// has the coordinates a schedule needs
+func (s *prayerSubscriptionService) locate(ctx context.Context, province, city string) (int, int, error) {
+	provinceID, err := s.codes.Decode(locationcode.Province, province)
+	if err != nil {
+		return 0, 0, utils.NewValidationError("Invalid province code")
+	}
+	cityID, err := s.codes.Decode(locationcode.City, city)
+	if err != nil {
+		return 0, 0, utils.NewValidationError("Invalid city code")
+	}
+	_, err = s.prayer.GetSchedule(ctx, LocationHash(provinceID), LocationHash(cityID), today(), 1)
+	if utils.IsNotFound(err) {
+		return 0, 0, utils.NewValidationError("The city is not in the province, or has no prayer times")
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return provinceID, cityID, nil
+}
+
+// CreateSubscription handles subscribing a chat; a chat subscribes to each kind once per city
+func (s *prayerSubscriptionService) CreateSubscription(ctx context.Context, req models.CreatePrayerSubscriptionRequest, createdBy *uint64) (*models.PrayerSubscription, error) {
+	chatID := strings.TrimSpace(req.ChatID)
+	if chatID == "" {
+		return nil, utils.NewValidationError("chat_id is required")
+	}
+	if !s.bot.Enabled(req.Channel) {
+		return nil, utils.NewValidationError(fmt.Sprintf("The %s channel is not configured", req.Channel))
+	}
+	provinceID, cityID, err := s.locate(ctx, req.Province, req.City)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id, err := s.repo.Create(ctx, models.PrayerSubscription{
+		Channel:    req.Channel,
+		ChatID:     chatID,
+		Kind:       req.Kind,
+		ProvinceID: provinceID,
+		CityID:     cityID,
+		Active:     req.Active == nil || *req.Active,
+		CreatedBy:  createdBy,
+		CreatedAt:  &now,
+		UpdatedAt:  &now,
+	})
+	if database.IsDuplicateKey(err) {
+		return nil, utils.NewConflictError("The chat is already subscribed to this kind for the city", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prayer subscription: %w", err)
+	}
+	return s.GetSubscription(ctx, strconv.FormatUint(id, 10))
+}
+
+// UpdateSubscription handles updating a subscription; members left out of req keep their
+// values
+func (s *prayerSubscriptionService) UpdateSubscription(ctx context.Context, id string, req models.UpdatePrayerSubscriptionRequest) (*models.PrayerSubscription, error) {
+	existing, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	if req.Kind != nil {
+		changes["kind"] = *req.Kind
+	}
+	if (req.Province == nil) != (req.City == nil) {
+		return nil, utils.NewValidationError("province and city must be changed together")
+	}
+	if req.Province != nil {
+		provinceID, cityID, err := s.locate(ctx, *req.Province, *req.City)
+		if err != nil {
+			return nil, err
+		}
+		changes["province_id"], changes["city_id"] = provinceID, cityID
+	}
+	if req.Active != nil {
+		changes["active"] = *req.Active
+	}
+	if len(changes) == 0 {
+		return existing, nil
+	}
+
+	err = s.repo.Update(ctx, existing.ID, changes)
+	if database.IsDuplicateKey(err) {
+		return nil, utils.NewConflictError("The chat is already subscribed to this kind for the city", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSubscription(ctx, id)
+}
+
+// DeleteSubscription handles unsubscribing, returning the subscription removed
+func (s *prayerSubscriptionService) DeleteSubscription(ctx context.Context, id string) (*models.PrayerSubscription, error) {
+	existing, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, existing.ID); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// SendTest handles sending a subscription its message now
+func (s *prayerSubscriptionService) SendTest(ctx context.Context, id string) (*models.PrayerSubscription, error) {
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	day := today()
+	run := &prayerSendRun{service: s, kind: sub.Kind, day: day, schedules: map[int]*Schedule{}}
+	if sub.Kind == models.SubscriptionImsakiyah {
+		// Outside the fasting period the reminder goes out without the Hijri date
+		if err := run.fastingDay(ctx); err != nil && !utils.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	text, err := run.message(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bot.Send(ctx, sub.Channel, sub.ChatID, text); err != nil {
+		if errors.Is(err, chatbot.ErrDisabled) {
+			return nil, utils.NewValidationError(fmt.Sprintf("The %s channel is not configured", sub.Channel))
+		}
+		return nil, utils.NewValidationError("The message was not delivered", err.Error())
+	}
+	return sub, nil
+}
+
+// SendDue handles a scheduled send
+func (s *prayerSubscriptionService) SendDue(ctx context.Context, kind string, day time.Time) (string, error) {
+	if len(s.bot.EnabledChannels()) == 0 {
+		return "no chat channel is configured", nil
+	}
+	run := &prayerSendRun{service: s, kind: kind, day: day, schedules: map[int]*Schedule{}}
+	if kind == models.SubscriptionImsakiyah {
+		err := run.fastingDay(ctx)
+		if utils.IsNotFound(err) {
+			return "not in the fasting period", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if run.ramadanDay == 0 {
+			return "not in the fasting period", nil
+		}
+	}
+
+	active := true
+	filter := models.PrayerSubscriptionFilter{Kind: kind, Active: &active, Limit: prayerSendBatch}
+	for {
+		batch, err := s.repo.List(ctx, filter)
+		if err != nil {
+			return run.summary(), err
+		}
+		for i := range batch {
+			if err := ctx.Err(); err != nil {
+				return run.summary(), err
+			}
+			run.send(ctx, &batch[i])
+		}
+		if len(batch) < prayerSendBatch {
+			break
+		}
+		filter.AfterID = batch[len(batch)-1].ID
+	}
+
+	if run.failed > 0 {
+		return run.summary(), fmt.Errorf("%d of %d messages failed", run.failed, run.sent+run.failed)
+	}
+	return run.summary(), nil
+}
+
+// prayerSendRun is one send to the subscriptions of a kind, computing each city's schedule
+// once
+type prayerSendRun struct {
+	service   *prayerSubscriptionService
+	kind      string
+	day       time.Time
+	schedules map[int]*Schedule
+	// hijriYear and ramadanDay place day in the fasting period, for imsakiyah messages;
+	// ramadanDay is 0 outside it
+	hijriYear  string
+	ramadanDay int
+
+	sent, failed, deactivated, skipped int
+}
+
+// fastingDay looks up where day falls in its year's fasting period
+func (r *prayerSendRun) fastingDay(ctx context.Context) error {
+	hijriah, start, days, err := r.service.prayer.GetFastingPeriod(ctx, r.day.Year())
+	if err != nil {
+		return err
+	}
+	// The period's dates are calendar dates, parsed as UTC midnight
+	day := time.Date(r.day.Year(), r.day.Month(), r.day.Day(), 0, 0, 0, 0, time.UTC)
+	if n := int(day.Sub(start).Hours()/24) + 1; !day.Before(start) && n <= days {
+		r.hijriYear, r.ramadanDay = hijriah, n
+	}
+	return nil
+}
+
+// message writes the text sub is sent for the day of the run
+func (r *prayerSendRun) message(ctx context.Context, sub *models.PrayerSubscription) (string, error) {
+	schedule, ok := r.schedules[sub.CityID]
+	if !ok {
+		var err error
+		schedule, err = r.service.prayer.GetSchedule(ctx, LocationHash(sub.ProvinceID), LocationHash(sub.CityID), r.day, 1)
+		if err != nil {
+			return "", err
+		}
+		r.schedules[sub.CityID] = schedule
+	}
+	if len(schedule.Days) == 0 {
+		return "", utils.NewNotFoundError("prayer times")
+	}
+	times := schedule.Days[0]
+	date := formatIndonesianDate(r.day)
+
+	var b strings.Builder
+	if r.kind == models.SubscriptionImsakiyah {
+		if r.ramadanDay > 0 {
+			fmt.Fprintf(&b, "Imsakiyah Ramadhan %s H, hari ke-%d\n", r.hijriYear, r.ramadanDay)
+		} else {
+			b.WriteString("Imsakiyah\n")
+		}
+		fmt.Fprintf(&b, "%s, %s\n%s\n\n", schedule.City, schedule.Province, date)
+		fmt.Fprintf(&b, "Imsak: %s\nSubuh: %s\nMaghrib (berbuka): %s", times.Imsak, times.Subuh, times.Maghrib)
+		return b.String(), nil
+	}
+	fmt.Fprintf(&b, "Jadwal Sholat %s, %s\n%s\n\n", schedule.City, schedule.Province, date)
+	fmt.Fprintf(&b, "Imsak: %s\nSubuh: %s\nTerbit: %s\nDhuha: %s\nDzuhur: %s\nAshar: %s\nMaghrib: %s\nIsya: %s",
+		times.Imsak, times.Subuh, times.Terbit, times.Dhuha, times.Dzuhur, times.Ashar, times.Maghrib, times.Isya)
+	return b.String(), nil
+}
+
+// send delivers sub its message unless it already had one on the day of the run, and records
+// the outcome. A chat that can no longer be reached is deactivated.
+func (r *prayerSendRun) send(ctx context.Context, sub *models.PrayerSubscription) {
+	if sub.LastSentAt != nil && sameDay(sub.LastSentAt.In(r.day.Location()), r.day) {
+		r.skipped++
+		return
+	}
+
+	text, err := r.message(ctx, sub)
+	if err == nil {
+		err = r.service.bot.Send(ctx, sub.Channel, sub.ChatID, text)
+	}
+	var sentAt *time.Time
+	var lastError *string
+	active := true
+	switch {
+	case err == nil:
+		now := time.Now()
+		sentAt = &now
+		r.sent++
+		prayerMessages.WithLabelValues(sub.Channel, "succeeded").Inc()
+	case errors.Is(err, chatbot.ErrUnreachable):
+		active = false
+		r.deactivated++
+		prayerMessages.WithLabelValues(sub.Channel, "deactivated").Inc()
+	default:
+		r.failed++
+		prayerMessages.WithLabelValues(sub.Channel, "failed").Inc()
+	}
+	if err != nil {
+		reason := truncate(err.Error(), maxDeliveryError)
+		lastError = &reason
+		slog.Warn("Failed to send prayer times", "subscription_id", sub.ID, "channel", sub.Channel,
+			"kind", sub.Kind, "deactivated", !active, "error", reason)
+	}
+
+	if err := r.service.repo.RecordSend(ctx, sub.ID, sentAt, lastError, active); err != nil {
+		slog.Error("Failed to record prayer subscription send", "subscription_id", sub.ID, "error", err)
+	}
+}
+
+// summary describes the run for the job history
+func (r *prayerSendRun) summary() string {
+	return fmt.Sprintf("sent %d, failed %d, deactivated %d, already sent %d", r.sent, r.failed, r.deactivated, r.skipped)
+}
+
+// today is the current date at midnight, local time
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// sameDay reports whether a and b fall on the same calendar date
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/locationcode"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/push"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var pushMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "push",
+	Name:      "messages_total",
+	Help:      "Push notifications by kind (reminder, announcement) and result: succeeded, failed, or unregistered for a token that no longer works.",
+}, []string{"kind", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(pushMessages)
+}
+
+// pushBatch is how many devices are read, and sent to, at a time
+const pushBatch = 500
+
+// announcementTimeout bounds sending one announcement to every device
+const announcementTimeout = time.Hour
+
+// PushService interface defines business logic for push notifications to the mobile prayer
+// apps: registering devices, and sending them prayer reminders and announcements
+type PushService interface {
+	RegisterDevice(ctx context.Context, req models.RegisterPushDeviceRequest, userID *uint64) (*models.PushDevice, error)
+	// UnregisterDevice forgets token; an unknown token is not an error
+	UnregisterDevice(ctx context.Context, token string) error
+	ListDevices(ctx context.Context, filter models.PushDeviceFilter) ([]models.PushDevice, error)
+	// Announce checks req and sends it to the devices of its cities in the background
+	Announce(ctx context.Context, req models.PushAnnouncementRequest) error
+	// SendReminders reminds the devices of every city of the prayer that has come due there
+	// at now, if any, and summarizes the run
+	SendReminders(ctx context.Context, now time.Time) (string, error)
+	// Close cancels the announcements still being sent and waits for them to stop
+	Close()
+}
+
+// pushService implements PushService
+type pushService struct {
+	repo   repositories.PushDeviceRepository
+	prayer PrayerService
+	codes  *locationcode.Codec
+	client *push.Client
+
+	// stop cancels the announcements running in the background
+	stop    context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewPushService creates a push service sending through client, with locations in the codes
+// of codes
+func NewPushService(repo repositories.PushDeviceRepository, prayer PrayerService, codes *locationcode.Codec, client *push.Client) PushService {
+	stop, cancel := context.WithCancel(context.Background())
+	return &pushService{repo: repo, prayer: prayer, codes: codes, client: client, stop: stop, cancel: cancel}
+}
+
+// withCodes fills in the location codes and token hint of d
+func (s *pushService) withCodes(d *models.PushDevice) *models.PushDevice {
+	d.Province = s.codes.Encode(locationcode.Province, d.ProvinceID)
+	d.City = s.codes.Encode(locationcode.City, d.CityID)
+	// Enough of the token to tell devices apart, not enough to send to one
+	d.TokenHint = d.Token
+	if len(d.Token) > 8 {
+		d.TokenHint = "…" + d.Token[len(d.Token)-8:]
+	}
+	return d
+}
+
+// RegisterDevice handles registering a device or updating one registered with the same token
+func (s *pushService) RegisterDevice(ctx context.Context, req models.RegisterPushDeviceRequest, userID *uint64) (*models.PushDevice, error) {
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		return nil, utils.NewValidationError("token is required")
+	}
+	provinceID, err := s.codes.Decode(locationcode.Province, req.Province)
+	if err != nil {
+		return nil, utils.NewValidationError("Invalid province code")
+	}
+	cityID, err := s.codes.Decode(locationcode.City, req.City)
+	if err != nil {
+		return nil, utils.NewValidationError("Invalid city code")
+	}
+	if _, err := s.prayer.GetSchedule(ctx, LocationHash(provinceID), LocationHash(cityID), today(), 1); utils.IsNotFound(err) {
+		return nil, utils.NewValidationError("The city is not in the province, or has no prayer times")
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id, err := s.repo.Register(ctx, models.PushDevice{
+		Token:         token,
+		Platform:      req.Platform,
+		ProvinceID:    provinceID,
+		CityID:        cityID,
+		Reminders:     req.Reminders == nil || *req.Reminders,
+		Announcements: req.Announcements == nil || *req.Announcements,
+		UserID:        userID,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	device, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Push device")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.withCodes(device), nil
+}
+
+// UnregisterDevice handles removing a device
+func (s *pushService) UnregisterDevice(ctx context.Context, token string) error {
+	_, err := s.repo.DeleteByToken(ctx, strings.TrimSpace(token))
+	return err
+}
+
+// ListDevices handles listing devices
+func (s *pushService) ListDevices(ctx context.Context, filter models.PushDeviceFilter) ([]models.PushDevice, error) {
+	list, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get push devices: %w", err)
+	}
+	for i := range list {
+		s.withCodes(&list[i])
+	}
+	return list, nil
+}
+
+// Announce handles an announcement
+func (s *pushService) Announce(ctx context.Context, req models.PushAnnouncementRequest) error {
+	if !s.client.Enabled() {
+		return utils.NewValidationError("Push notifications are not configured")
+	}
+	cityIDs := make([]int, 0, len(req.Cities))
+	for _, code := range req.Cities {
+		id, err := s.codes.Decode(locationcode.City, code)
+		if err != nil {
+			return utils.NewValidationError("Invalid city code", code)
+		}
+		cityIDs = append(cityIDs, id)
+	}
+	data := map[string]string{}
+	for k, v := range req.Data {
+		data[k] = v
+	}
+	data["type"] = "announcement"
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		ctx, cancel := context.WithTimeout(s.stop, announcementTimeout)
+		defer cancel()
+		run := &pushRun{service: s, kind: "announcement"}
+		filter := models.PushDeviceFilter{CityIDs: cityIDs, Announcements: true, Limit: pushBatch}
+		err := run.sendAll(ctx, filter, func(models.PushDevice) push.Message {
+			return push.Message{Title: req.Title, Body: req.Body, Data: data}
+		}, nil)
+		if err != nil {
+			slog.Error("Push announcement stopped", "title", req.Title, "result", run.summary(), "error", err)
+			return
+		}
+		slog.Info("Push announcement sent", "title", req.Title, "result", run.summary())
+	}()
+	return nil
+}
+
+// Close stops the announcements
+func (s *pushService) Close() {
+	s.cancel()
+	s.running.Wait()
+}
+
+// reminderPrayers are the prayers devices are reminded of; imsak only in the fasting period
+var reminderPrayers = []struct {
+	name string
+	time func(models.PrayerDay) string
+}{
+	{"Imsak", func(d models.PrayerDay) string { return d.Imsak }},
+	{"Subuh", func(d models.PrayerDay) string { return d.Subuh }},
+	{"Dzuhur", func(d models.PrayerDay) string { return d.Dzuhur }},
+	{"Ashar", func(d models.PrayerDay) string { return d.Ashar }},
+	{"Maghrib", func(d models.PrayerDay) string { return d.Maghrib }},
+	{"Isya", func(d models.PrayerDay) string { return d.Isya }},
+}
+
+// dueReminder is the prayer a city's devices are to be reminded of
+type dueReminder struct {
+	prayer string
+	clock  string
+	at     time.Time
+	city   string
+	day    time.Time
+	// ramadanDay is the day of the fasting period, 0 outside it
+	ramadanDay int
+	hijriYear  string
+}
+
+// SendReminders handles a reminder run
+func (s *pushService) SendReminders(ctx context.Context, now time.Time) (string, error) {
+	if !s.client.Enabled() {
+		return "push is not configured", nil
+	}
+	cfg := s.client.Config()
+	locations, err := s.repo.ReminderLocations(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	run := &pushRun{service: s, kind: "reminder"}
+	cities := 0
+	for _, loc := range locations {
+		if err := ctx.Err(); err != nil {
+			return run.summary(), err
+		}
+		due, err := s.dueReminder(ctx, loc, now, cfg.ReminderLead, cfg.ReminderWindow)
+		if err != nil {
+			// One city without a schedule must not hold up the others
+			slog.Warn("No prayer reminder for city", "city_id", loc.CityID, "error", err)
+			continue
+		}
+		if due == nil {
+			continue
+		}
+		cities++
+		filter := models.PushDeviceFilter{CityIDs: []int{loc.CityID}, Reminders: true, RemindedBefore: &due.at, Limit: pushBatch}
+		err = run.sendAll(ctx, filter, func(d models.PushDevice) push.Message {
+			return reminderMessage(due, s.codes.Encode(locationcode.City, d.CityID), cfg.ReminderLead)
+		}, func(ids []uint64) error {
+			return s.repo.MarkReminded(ctx, ids, due.at)
+		})
+		if err != nil {
+			return run.summary(), err
+		}
+	}
+
+	summary := fmt.Sprintf("%d cities due, %s", cities, run.summary())
+	if run.failed > 0 {
+		return summary, fmt.Errorf("%d of %d reminders failed", run.failed, run.sent+run.failed)
+	}
+	return summary, nil
+}
+
+// dueReminder finds the prayer of loc whose reminder has come due at now and is at most
+// window late, or nil. Times are in the city's time zone.
+func (s *pushService) dueReminder(ctx context.Context, loc repositories.PushLocation, now time.Time, lead, window time.Duration) (*dueReminder, error) {
+	provinceHash, cityHash := LocationHash(loc.ProvinceID), LocationHash(loc.CityID)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	schedule, err := s.prayer.GetSchedule(ctx, provinceHash, cityHash, day, 1)
+	if err != nil {
+		return nil, err
+	}
+	// The city's date may differ from the server's
+	if local := now.In(schedule.Zone); !sameDay(local, day) {
+		day = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+		if schedule, err = s.prayer.GetSchedule(ctx, provinceHash, cityHash, day, 1); err != nil {
+			return nil, err
+		}
+	}
+	if len(schedule.Days) == 0 {
+		return nil, nil
+	}
+
+	var due *dueReminder
+	for _, p := range reminderPrayers {
+		clock := p.time(schedule.Days[0])
+		at, err := time.ParseInLocation("2006-01-02 15:04", day.Format("2006-01-02")+" "+clock, schedule.Zone)
+		if err != nil {
+			continue
+		}
+		if remind := at.Add(-lead); !remind.After(now) && now.Sub(remind) <= window {
+			due = &dueReminder{prayer: p.name, clock: clock, at: at, city: schedule.City, day: day}
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	hijriah, start, days, err := s.prayer.GetFastingPeriod(ctx, day.Year())
+	if err != nil && !utils.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if n := int(date.Sub(start).Hours()/24) + 1; !date.Before(start) && n <= days {
+			due.ramadanDay, due.hijriYear = n, hijriah
+		}
+	}
+	if due.prayer == "Imsak" && due.ramadanDay == 0 {
+		return nil, nil
+	}
+	return due, nil
+}
+
+// reminderMessage writes the reminder of due for a device in the city with code city
+func reminderMessage(due *dueReminder, city string, lead time.Duration) push.Message {
+	title := "Waktu " + due.prayer
+	body := fmt.Sprintf("Telah masuk waktu %s pukul %s untuk %s dan sekitarnya", due.prayer, due.clock, due.city)
+	if lead > 0 {
+		body = fmt.Sprintf("%s pukul %s di %s, %d menit lagi", due.prayer, due.clock, due.city, int(lead.Minutes()))
+	}
+	if due.ramadanDay > 0 {
+		switch due.prayer {
+		case "Imsak":
+			body += fmt.Sprintf(". Hari ke-%d Ramadhan %s H", due.ramadanDay, due.hijriYear)
+		case "Maghrib":
+			body += ". Selamat berbuka puasa"
+		}
+	}
+	return push.Message{Title: title, Body: body, Data: map[string]string{
+		"type":   "prayer_reminder",
+		"prayer": strings.ToLower(due.prayer),
+		"time":   due.clock,
+		"date":   due.day.Format("2006-01-02"),
+		"city":   city,
+	}}
+}
+
+// pushRun sends one kind of message to the devices of a filter, batch by batch, and counts
+// the outcomes
+type pushRun struct {
+	service                    *pushService
+	kind                       string
+	sent, failed, unregistered int
+}
+
+// sendAll sends each device matching filter the message of build. Devices whose token no
+// longer works are removed; delivered ones are handed to delivered, when set.
+func (r *pushRun) sendAll(ctx context.Context, filter models.PushDeviceFilter, build func(models.PushDevice) push.Message, delivered func(ids []uint64) error) error {
+	for {
+		batch, err := r.service.repo.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		msgs := make([]push.Message, len(batch))
+		for i, d := range batch {
+			msgs[i] = build(d)
+			msgs[i].Token = d.Token
+		}
+
+		var succeeded, gone []uint64
+		for i, err := range r.service.client.SendAll(ctx, msgs) {
+			switch {
+			case err == nil:
+				succeeded = append(succeeded, batch[i].ID)
+				r.sent++
+				pushMessages.WithLabelValues(r.kind, "succeeded").Inc()
+			case errors.Is(err, push.ErrUnregistered):
+				gone = append(gone, batch[i].ID)
+				r.unregistered++
+				pushMessages.WithLabelValues(r.kind, "unregistered").Inc()
+			default:
+				r.failed++
+				pushMessages.WithLabelValues(r.kind, "failed").Inc()
+				slog.Warn("Failed to send push notification", "kind", r.kind, "device_id", batch[i].ID, "error", err)
+			}
+		}
+		if err := r.service.repo.DeleteByIDs(ctx, gone); err != nil {
+			return err
+		}
+		if delivered != nil {
+			if err := delivered(succeeded); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < pushBatch {
+			return ctx.Err()
+		}
+		filter.AfterID = batch[len(batch)-1].ID
+	}
+}
+
+// summary describes the run for the job history and the log
+func (r *pushRun) summary() string {
+	return fmt.Sprintf("sent %d, failed %d, unregistered %d", r.sent, r.failed, r.unregistered)
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/utils"
+)
+
+// Entities of an RBAC import's changes
+const (
+	rbacRole        = "role"
+	rbacInheritance = "inheritance"
+	rbacMenu        = "menu"
+	rbacRoleMenu    = "role_menu"
+)
+
+// RBACService interface defines business logic for moving the RBAC configuration between
+// environments, e.g. promoting what was set up on staging to production: Export writes it
+// as a bundle naming everything, and Import applies a bundle, matching roles by name and
+// menu items by their path of labels.
+type RBACService interface {
+	Export(ctx context.Context) (*models.RBACBundle, error)
+	// Import creates and updates what the bundle has and the database lacks or differs in,
+	// in one transaction; running it again changes nothing. Nothing is deleted unless
+	// prune is set, which removes the inheritances and menu links of the bundle's roles
+	// that the bundle does not list.
+	Import(ctx context.Context, bundle models.RBACBundle, dryRun, prune bool, userID *uint64) (*models.RBACImportResult, error)
+}
+
+// rbacService implements RBACService
+type rbacService struct {
+	repo repositories.RBACRepository
+	tx   repositories.TxManager
+}
+
+// NewRBACService creates a new RBAC service
+func NewRBACService(repo repositories.RBACRepository, tx repositories.TxManager) RBACService {
+	return &rbacService{repo: repo, tx: tx}
+}
+
+// rbacKey is how names are matched: case-insensitively, as the database collation does
+func rbacKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// menuPathKey is the key of a path of menu labels
+func menuPathKey(path []string) string {
+	keys := make([]string, len(path))
+	for i, label := range path {
+		keys[i] = rbacKey(label)
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// menuPaths returns the path of labels of every menu item, a parent that is deleted or
+// missing making its children top-level items
+func menuPaths(menus []models.Menu) map[uint][]string {
+	byID := make(map[uint]models.Menu, len(menus))
+	for _, m := range menus {
+		byID[m.ID] = m
+	}
+	paths := make(map[uint][]string, len(menus))
+	var pathOf func(m models.Menu, depth int) []string
+	pathOf = func(m models.Menu, depth int) []string {
+		if p, ok := paths[m.ID]; ok {
+			return p
+		}
+		var path []string
+		// depth guards against a parent_id loop, which the admin API does not prevent
+		if parent, ok := byID[derefUint(m.ParentID)]; ok && m.ParentID != nil && depth < len(menus) {
+			path = append(append(path, pathOf(parent, depth+1)...), m.Label)
+		} else {
+			path = []string{m.Label}
+		}
+		paths[m.ID] = path
+		return path
+	}
+	for _, m := range menus {
+		pathOf(m, 0)
+	}
+	return paths
+}
+
+func derefUint(v *uint) uint {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// Export reads the live roles and menu items and the links between them
+func (s *rbacService) Export(ctx context.Context) (*models.RBACBundle, error) {
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	menus, err := s.repo.ListMenus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inheritances, err := s.repo.ListInheritances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.repo.ListRoleMenus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	bundle := &models.RBACBundle{Version: models.RBACBundleVersion, ExportedAt: &now, Roles: []models.RBACRole{},
+		Inheritances: []models.RBACInheritance{}, Menus: []models.RBACMenu{}, RoleMenus: []models.RBACRoleMenu{}}
+	roleNames := map[uint]string{}
+	for _, r := range roles {
+		if r.DeletedAt == nil {
+			roleNames[r.ID] = r.Name
+			bundle.Roles = append(bundle.Roles, models.RBACRole{Name: r.Name, Description: r.Description})
+		}
+	}
+	for _, ri := range inheritances {
+		role, ok1 := roleNames[ri.RoleID]
+		parent, ok2 := roleNames[ri.ParentRoleID]
+		if ok1 && ok2 {
+			bundle.Inheritances = append(bundle.Inheritances, models.RBACInheritance{Role: role, Parent: parent})
+		}
+	}
+
+	paths := menuPaths(menus)
+	// Parents before their children, then in menu order
+	sort.SliceStable(menus, func(i, j int) bool {
+		if a, b := len(paths[menus[i].ID]), len(paths[menus[j].ID]); a != b {
+			return a < b
+		}
+		return menus[i].SortOrder < menus[j].SortOrder
+	})
+	seen := map[string]bool{}
+	var duplicates []string
+	for _, m := range menus {
+		key := menuPathKey(paths[m.ID])
+		if seen[key] {
+			duplicates = append(duplicates, strings.Join(paths[m.ID], " > "))
+			continue
+		}
+		seen[key] = true
+		bundle.Menus = append(bundle.Menus, models.RBACMenu{Path: paths[m.ID], URL: m.Url, Icon: m.Icon, SortOrder: m.SortOrder})
+	}
+	if len(duplicates) > 0 {
+		return nil, utils.NewConflictError("Menu items share a path, which a bundle cannot tell apart; rename them first: "+strings.Join(duplicates, ", "), nil)
+	}
+	for _, l := range links {
+		role, ok := roleNames[l.RoleID]
+		path, live := paths[l.MenuID]
+		if l.DeletedAt == nil && ok && live {
+			bundle.RoleMenus = append(bundle.RoleMenus, models.RBACRoleMenu{Role: role, Menu: path})
+		}
+	}
+	return bundle, nil
+}
+
+// rbacRolePlan is a role of a bundle and what the import does with it; id is set once the
+// role exists
+type rbacRolePlan struct {
+	role   models.RBACRole
+	id     uint
+	action string
+	change int // index of its change in the result, if it has one
+}
+
+// rbacMenuPlan is a menu item of a bundle and what the import does with it
+type rbacMenuPlan struct {
+	menu   models.RBACMenu
+	id     uint
+	parent *rbacMenuPlan
+	action string
+	change int
+}
+
+// rbacLinkPlan is a role-menu link an import creates or restores
+type rbacLinkPlan struct {
+	role *rbacRolePlan
+	menu *rbacMenuPlan
+}
+
+// rbacPlan is what an import writes, in order
+type rbacPlan struct {
+	roles        []*rbacRolePlan
+	menus        []*rbacMenuPlan
+	removeEdges  []uint64
+	addEdges     [][2]*rbacRolePlan
+	removeLinks  [][2]uint
+	restoreLinks []rbacLinkPlan
+	addLinks     []rbacLinkPlan
+}
+
+// checkBundle reports everything wrong with a bundle before anything is compared
+func checkBundle(bundle models.RBACBundle) error {
+	var problems []string
+	if bundle.Version != models.RBACBundleVersion {
+		problems = append(problems, fmt.Sprintf("version %d is not supported; this server reads version %d", bundle.Version, models.RBACBundleVersion))
+	}
+	roles := map[string]bool{}
+	for _, r := range bundle.Roles {
+		key := rbacKey(r.Name)
+		if roles[key] {
+			problems = append(problems, fmt.Sprintf("role %q is listed twice", r.Name))
+		}
+		roles[key] = true
+	}
+	menus := map[string]bool{}
+	for _, m := range bundle.Menus {
+		key := menuPathKey(m.Path)
+		if menus[key] {
+			problems = append(problems, fmt.Sprintf("menu item %q is listed twice", strings.Join(m.Path, " > ")))
+		}
+		if len(m.Path) > 1 && !menus[menuPathKey(m.Path[:len(m.Path)-1])] {
+			problems = append(problems, fmt.Sprintf("menu item %q comes before its parent, or without it", strings.Join(m.Path, " > ")))
+		}
+		menus[key] = true
+	}
+	for _, ri := range bundle.Inheritances {
+		switch {
+		case !roles[rbacKey(ri.Role)] || !roles[rbacKey(ri.Parent)]:
+			problems = append(problems, fmt.Sprintf("inheritance %q < %q names a role the bundle does not list", ri.Role, ri.Parent))
+		case rbacKey(ri.Role) == rbacKey(ri.Parent):
+			problems = append(problems, fmt.Sprintf("role %q inherits from itself", ri.Role))
+		}
+	}
+	for _, l := range bundle.RoleMenus {
+		if !roles[rbacKey(l.Role)] || !menus[menuPathKey(l.Menu)] {
+			problems = append(problems, fmt.Sprintf("menu link %q: %q names a role or menu item the bundle does not list", l.Role, strings.Join(l.Menu, " > ")))
+		}
+	}
+	if len(problems) > 0 {
+		return utils.NewValidationError("The bundle is invalid", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Import compares the bundle with the database and applies the difference
+func (s *rbacService) Import(ctx context.Context, bundle models.RBACBundle, dryRun, prune bool, userID *uint64) (*models.RBACImportResult, error) {
+	if err := checkBundle(bundle); err != nil {
+		return nil, err
+	}
+
+	result := &models.RBACImportResult{DryRun: dryRun, Prune: prune, Changes: []models.RBACChange{}}
+	var plan *rbacPlan
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if plan, err = s.plan(ctx, bundle, prune, result); err != nil {
+			return err
+		}
+		if dryRun || result.Created+result.Updated+result.Restored+result.Removed == 0 {
+			return nil
+		}
+		if err := s.apply(ctx, plan, userID); err != nil {
+			return err
+		}
+		result.Committed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Committed {
+		// Created roles and menu items have their IDs now
+		for _, p := range plan.roles {
+			if p.action != models.RBACUnchanged {
+				id := uint64(p.id)
+				result.Changes[p.change].ID = &id
+			}
+		}
+		for _, p := range plan.menus {
+			if p.action != models.RBACUnchanged {
+				id := uint64(p.id)
+				result.Changes[p.change].ID = &id
+			}
+		}
+		invalidateRBAC(plan)
+	}
+	return result, nil
+}
+
+// plan reads the RBAC tables and records in result, and returns, what the bundle changes
+func (s *rbacService) plan(ctx context.Context, bundle models.RBACBundle, prune bool, result *models.RBACImportResult) (*rbacPlan, error) {
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	menus, err := s.repo.ListMenus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inheritances, err := s.repo.ListInheritances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.repo.ListRoleMenus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// record counts an item's action and lists it if it changes, returning its index
+	record := func(entity, key, action string, changes map[string]models.ValueChange) int {
+		switch action {
+		case models.RBACCreate:
+			result.Created++
+		case models.RBACUpdate:
+			result.Updated++
+		case models.RBACRestore:
+			result.Restored++
+		case models.RBACRemove:
+			result.Removed++
+		default:
+			result.Unchanged++
+			return -1
+		}
+		result.Changes = append(result.Changes, models.RBACChange{Entity: entity, Key: key, Action: action, Changes: changes})
+		return len(result.Changes) - 1
+	}
+	plan := &rbacPlan{}
+
+	// Roles, by name; a deleted role of the same name is restored, names being unique
+	existingRoles := make(map[string]models.Role, len(roles))
+	roleKeys := make(map[uint]string, len(roles))
+	roleNames := make(map[uint]string, len(roles))
+	for _, r := range roles {
+		existingRoles[rbacKey(r.Name)] = r
+		roleKeys[r.ID] = rbacKey(r.Name)
+		roleNames[r.ID] = r.Name
+	}
+	rolePlans := make(map[string]*rbacRolePlan, len(bundle.Roles))
+	for _, r := range bundle.Roles {
+		p := &rbacRolePlan{role: r, action: models.RBACCreate}
+		var changes map[string]models.ValueChange
+		if old, ok := existingRoles[rbacKey(r.Name)]; ok {
+			p.id = old.ID
+			p.action = models.RBACUnchanged
+			if !samePtr(old.Description, r.Description) {
+				changes = map[string]models.ValueChange{"description": {Old: old.Description, New: r.Description}}
+				p.action = models.RBACUpdate
+			}
+			if old.DeletedAt != nil {
+				p.action = models.RBACRestore
+			}
+		}
+		rolePlans[rbacKey(r.Name)] = p
+		plan.roles = append(plan.roles, p)
+		p.change = record(rbacRole, r.Name, p.action, changes)
+	}
+
+	// Menu items, by path; of items sharing a path the oldest is matched
+	paths := menuPaths(menus)
+	existingMenus := make(map[string]models.Menu, len(menus))
+	for _, m := range menus {
+		if key := menuPathKey(paths[m.ID]); existingMenus[key].ID == 0 {
+			existingMenus[key] = m
+		}
+	}
+	menuPlans := make(map[string]*rbacMenuPlan, len(bundle.Menus))
+	for _, m := range bundle.Menus {
+		key := menuPathKey(m.Path)
+		p := &rbacMenuPlan{menu: m, action: models.RBACCreate}
+		if len(m.Path) > 1 {
+			p.parent = menuPlans[menuPathKey(m.Path[:len(m.Path)-1])]
+		}
+		changes := map[string]models.ValueChange{}
+		if old, ok := existingMenus[key]; ok {
+			p.id = old.ID
+			p.action = models.RBACUnchanged
+			if !samePtr(old.Url, m.URL) {
+				changes["url"] = models.ValueChange{Old: old.Url, New: m.URL}
+			}
+			if !samePtr(old.Icon, m.Icon) {
+				changes["icon"] = models.ValueChange{Old: old.Icon, New: m.Icon}
+			}
+			if old.SortOrder != m.SortOrder {
+				changes["sort_order"] = models.ValueChange{Old: old.SortOrder, New: m.SortOrder}
+			}
+			if len(changes) > 0 {
+				p.action = models.RBACUpdate
+			}
+		}
+		if len(changes) == 0 {
+			changes = nil
+		}
+		menuPlans[key] = p
+		plan.menus = append(plan.menus, p)
+		p.change = record(rbacMenu, strings.Join(m.Path, " > "), p.action, changes)
+	}
+
+	// Inheritances, between roles that exist; prune drops those of the bundle's roles the
+	// bundle leaves out
+	type edge struct{ role, parent string }
+	wanted := map[edge]bool{}
+	for _, ri := range bundle.Inheritances {
+		wanted[edge{rbacKey(ri.Role), rbacKey(ri.Parent)}] = true
+	}
+	graph := map[string][]string{}
+	existingEdges := map[edge]bool{}
+	for _, ri := range inheritances {
+		e := edge{roleKeys[ri.RoleID], roleKeys[ri.ParentRoleID]}
+		existingEdges[e] = true
+		if prune && rolePlans[e.role] != nil && !wanted[e] {
+			plan.removeEdges = append(plan.removeEdges, ri.ID)
+			record(rbacInheritance, roleNames[ri.RoleID]+" < "+roleNames[ri.ParentRoleID], models.RBACRemove, nil)
+			continue
+		}
+		graph[e.role] = append(graph[e.role], e.parent)
+	}
+	added := map[edge]bool{}
+	for _, ri := range bundle.Inheritances {
+		e := edge{rbacKey(ri.Role), rbacKey(ri.Parent)}
+		if added[e] {
+			continue
+		}
+		added[e] = true
+		if existingEdges[e] {
+			record(rbacInheritance, ri.Role+" < "+ri.Parent, models.RBACUnchanged, nil)
+			continue
+		}
+		graph[e.role] = append(graph[e.role], e.parent)
+		plan.addEdges = append(plan.addEdges, [2]*rbacRolePlan{rolePlans[e.role], rolePlans[e.parent]})
+		record(rbacInheritance, ri.Role+" < "+ri.Parent, models.RBACCreate, nil)
+	}
+	if cycle := inheritanceCycle(graph); cycle != nil {
+		return nil, utils.NewValidationError("The import would make roles inherit from themselves: " + strings.Join(cycle, " < "))
+	}
+
+	// Role-menu links; a deleted link is restored
+	type link struct {
+		role uint
+		menu uint
+	}
+	existingLinks := map[link]bool{} // the value is whether the link is live
+	for _, l := range links {
+		existingLinks[link{l.RoleID, l.MenuID}] = l.DeletedAt == nil
+	}
+	wantedLinks := map[link]bool{}
+	seenLinks := map[string]bool{}
+	for _, l := range bundle.RoleMenus {
+		r, m := rolePlans[rbacKey(l.Role)], menuPlans[menuPathKey(l.Menu)]
+		key := l.Role + ": " + strings.Join(l.Menu, " > ")
+		if seenLinks[rbacKey(key)] {
+			continue
+		}
+		seenLinks[rbacKey(key)] = true
+		ends := rbacLinkPlan{role: r, menu: m}
+		if r.id == 0 || m.id == 0 {
+			plan.addLinks = append(plan.addLinks, ends)
+			record(rbacRoleMenu, key, models.RBACCreate, nil)
+			continue
+		}
+		k := link{r.id, m.id}
+		wantedLinks[k] = true
+		live, ok := existingLinks[k]
+		switch {
+		case !ok:
+			plan.addLinks = append(plan.addLinks, ends)
+			record(rbacRoleMenu, key, models.RBACCreate, nil)
+		case !live:
+			plan.restoreLinks = append(plan.restoreLinks, ends)
+			record(rbacRoleMenu, key, models.RBACRestore, nil)
+		default:
+			record(rbacRoleMenu, key, models.RBACUnchanged, nil)
+		}
+	}
+	if prune {
+		for _, l := range links {
+			k := link{l.RoleID, l.MenuID}
+			if l.DeletedAt != nil || wantedLinks[k] || rolePlans[roleKeys[l.RoleID]] == nil {
+				continue
+			}
+			path, ok := paths[l.MenuID]
+			if !ok {
+				// A link to a deleted menu item is not shown anyway
+				continue
+			}
+			plan.removeLinks = append(plan.removeLinks, [2]uint{l.RoleID, l.MenuID})
+			record(rbacRoleMenu, roleNames[l.RoleID]+": "+strings.Join(path, " > "), models.RBACRemove, nil)
+		}
+	}
+	return plan, nil
+}
+
+// inheritanceCycle returns a chain of roles leading back to its first, if graph, each role's
+// parents, has one
+func inheritanceCycle(graph map[string][]string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(role string) []string
+	visit = func(role string) []string {
+		switch state[role] {
+		case visiting:
+			for i, r := range stack {
+				if r == role {
+					return append(append([]string{}, stack[i:]...), role)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[role] = visiting
+		stack = append(stack, role)
+		for _, parent := range graph[role] {
+			if cycle := visit(parent); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[role] = done
+		return nil
+	}
+	roles := make([]string, 0, len(graph))
+	for role := range graph {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		if cycle := visit(role); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// apply writes a plan: roles and menu items first, so the links find their IDs
+func (s *rbacService) apply(ctx context.Context, plan *rbacPlan, userID *uint64) error {
+	now := time.Now()
+	var err error
+	for _, p := range plan.roles {
+		switch p.action {
+		case models.RBACCreate:
+			p.id, err = s.repo.CreateRole(ctx, p.role.Name, p.role.Description, now)
+		case models.RBACUpdate, models.RBACRestore:
+			err = s.repo.UpdateRole(ctx, p.id, p.role.Description, now)
+		}
+		if err != nil {
+			return fmt.Errorf("role %q: %w", p.role.Name, err)
+		}
+	}
+	for _, p := range plan.menus {
+		menu := models.Menu{ID: p.id, Label: p.menu.Path[len(p.menu.Path)-1], Url: p.menu.URL, Icon: p.menu.Icon, SortOrder: p.menu.SortOrder}
+		switch p.action {
+		case models.RBACCreate:
+			if p.parent != nil {
+				menu.ParentID = &p.parent.id
+			}
+			p.id, err = s.repo.CreateMenu(ctx, menu, now)
+		case models.RBACUpdate:
+			err = s.repo.UpdateMenu(ctx, menu, now)
+		}
+		if err != nil {
+			return fmt.Errorf("menu item %q: %w", strings.Join(p.menu.Path, " > "), err)
+		}
+	}
+	for _, id := range plan.removeEdges {
+		if err := s.repo.DeleteInheritance(ctx, id); err != nil {
+			return err
+		}
+	}
+	for _, e := range plan.addEdges {
+		if _, err := s.repo.CreateInheritance(ctx, e[0].id, e[1].id, now); err != nil {
+			return err
+		}
+	}
+	for _, l := range plan.removeLinks {
+		if err := s.repo.DeleteRoleMenu(ctx, l[0], l[1], userID, now); err != nil {
+			return err
+		}
+	}
+	for _, l := range plan.restoreLinks {
+		if err := s.repo.RestoreRoleMenu(ctx, l.role.id, l.menu.id); err != nil {
+			return err
+		}
+	}
+	for _, l := range plan.addLinks {
+		if err := s.repo.CreateRoleMenu(ctx, l.role.id, l.menu.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateRBAC drops the cached roles and menus an applied import changed
+func invalidateRBAC(plan *rbacPlan) {
+	for _, p := range plan.roles {
+		if p.action != models.RBACUnchanged {
+			events.EntityChanged("roles", events.ActionUpdated, strconv.FormatUint(uint64(p.id), 10))
+		}
+	}
+	for _, p := range plan.menus {
+		if p.action != models.RBACUnchanged {
+			events.EntityChanged("menu", events.ActionUpdated, strconv.FormatUint(uint64(p.id), 10))
+		}
+	}
+	if len(plan.removeEdges)+len(plan.addEdges) > 0 {
+		events.EntityChanged("role_inheritances", events.ActionUpdated, "")
+	}
+	if len(plan.removeLinks)+len(plan.restoreLinks)+len(plan.addLinks) > 0 {
+		events.EntityChanged("role_menu", events.ActionUpdated, "")
+	}
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var reportScheduleRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "report",
+	Name:      "schedule_runs_total",
+	Help:      "Scheduled report runs by status: succeeded or failed.",
+}, []string{"status"})
+
+func init() {
+	metrics.Registry.MustRegister(reportScheduleRuns)
+}
+
+const (
+	// reportScheduleMinInterval is the shortest time allowed between two runs of a schedule,
+	// which keeps JasperServer and the owner's files from being flooded
+	reportScheduleMinInterval = 15 * time.Minute
+	// reportScheduleBatch is how many due schedules are read at a time
+	reportScheduleBatch = 20
+)
+
+// ReportContentType returns the media type of a binary report output format; false for the
+// formats answered as JSON
+func ReportContentType(format string) (string, bool) {
+	switch format {
+	case "pdf":
+		return "application/pdf", true
+	case "excel", "xlsx", "xls":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", true
+	case "pptx":
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation", true
+	case "docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document", true
+	case "rtf":
+		return "application/rtf", true
+	case "png":
+		return "image/png", true
+	}
+	return "", false
+}
+
+// ReportScheduleService interface defines business logic for reports run on a schedule: a
+// schedule is run by RunDue on whichever instance the report_schedules scheduler job runs,
+// as its owner and within the owner's tenant, and each rendered file is kept as one of the
+// owner's attachments. Callers other than administrators only reach their own schedules.
+type ReportScheduleService interface {
+	Create(ctx context.Context, req models.ReportScheduleRequest, ownerID uint64) (*models.ReportSchedule, error)
+	Get(ctx context.Context, id string, callerID uint64, admin bool) (*models.ReportSchedule, error)
+	// List lists the caller's schedules; administrators may list anyone's, or everyone's
+	List(ctx context.Context, filter models.ReportScheduleFilter, callerID uint64, admin bool) ([]models.ReportSchedule, error)
+	// Update replaces a schedule's definition and plans its next run again
+	Update(ctx context.Context, id string, req models.ReportScheduleRequest, callerID uint64, admin bool) (old, updated *models.ReportSchedule, err error)
+	// Delete removes a schedule; its published runs leave the calendar with the next sync
+	Delete(ctx context.Context, id string, callerID uint64, admin bool) (*models.ReportSchedule, error)
+	// RunDue runs the schedules whose time has come, one after the other. A run missed while
+	// no instance was running the job is run once, late, not once per missed time.
+	RunDue(ctx context.Context) (string, error)
+}
+
+// reportScheduleService implements ReportScheduleService
+type reportScheduleService struct {
+	repo          repositories.ReportScheduleRepository
+	reports       ReportService
+	attachments   AttachmentService
+	notifications NotificationService
+	tenants       TenantService
+	// timeout bounds one run
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// NewReportScheduleService creates a new report schedule service running reports through
+// reports, each bounded by timeout. Rendered files are saved through attachments, failed
+// runs reported through notifications, and runs get their tenant's settings from tenants.
+func NewReportScheduleService(repo repositories.ReportScheduleRepository, reports ReportService, attachments AttachmentService, notifications NotificationService, tenants TenantService, timeout time.Duration) ReportScheduleService {
+	return &reportScheduleService{repo: repo, reports: reports, attachments: attachments, notifications: notifications,
+		tenants: tenants, timeout: timeout, now: time.Now}
+}
+
+// nextReportRun checks spec and returns its first run after now
+func nextReportRun(spec string, now time.Time) (time.Time, error) {
+	schedule, err := scheduler.Parse(spec)
+	if err != nil {
+		return time.Time{}, utils.NewValidationError(err.Error())
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return time.Time{}, utils.NewValidationError(fmt.Sprintf("Schedule %q never runs", spec))
+	}
+	if following := schedule.Next(next); !following.IsZero() && following.Sub(next) < reportScheduleMinInterval {
+		return time.Time{}, utils.NewValidationError(fmt.Sprintf("Schedule %q runs more often than every %s", spec, reportScheduleMinInterval))
+	}
+	return next.Truncate(time.Second), nil
+}
+
+// apply copies req onto schedule and plans its next run
+func (s *reportScheduleService) apply(schedule *models.ReportSchedule, req models.ReportScheduleRequest, now time.Time) error {
+	next, err := nextReportRun(req.Schedule, now)
+	if err != nil {
+		return err
+	}
+	schedule.Name, schedule.ReportPath, schedule.OutputFormat = req.Name, req.ReportPath, req.OutputFormat
+	schedule.Parameters, schedule.Schedule = req.Parameters, req.Schedule
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	schedule.UpdatedAt = &now
+	return nil
+}
+
+// Create checks the schedule and plans its first run
+func (s *reportScheduleService) Create(ctx context.Context, req models.ReportScheduleRequest, ownerID uint64) (*models.ReportSchedule, error) {
+	now := s.now()
+	schedule := models.ReportSchedule{OwnerID: ownerID, TenantID: tenant.ID(ctx), CreatedAt: &now}
+	if err := s.apply(&schedule, req, now); err != nil {
+		return nil, err
+	}
+	id, err := s.repo.Create(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = id
+	return &schedule, nil
+}
+
+// Get retrieves a schedule the caller may reach
+func (s *reportScheduleService) Get(ctx context.Context, id string, callerID uint64, admin bool) (*models.ReportSchedule, error) {
+	scheduleID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || scheduleID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+	schedule, err := s.repo.GetByID(ctx, scheduleID)
+	if err == sql.ErrNoRows || (err == nil && schedule.OwnerID != callerID && !admin) {
+		return nil, utils.NewNotFoundError("Report schedule")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// List retrieves schedules, newest first
+func (s *reportScheduleService) List(ctx context.Context, filter models.ReportScheduleFilter, callerID uint64, admin bool) ([]models.ReportSchedule, error) {
+	if !admin {
+		filter.OwnerID = callerID
+	}
+	return s.repo.List(ctx, filter)
+}
+
+// Update rewrites a schedule the caller may reach
+func (s *reportScheduleService) Update(ctx context.Context, id string, req models.ReportScheduleRequest, callerID uint64, admin bool) (*models.ReportSchedule, *models.ReportSchedule, error) {
+	old, err := s.Get(ctx, id, callerID, admin)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated := *old
+	if err := s.apply(&updated, req, s.now()); err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.Update(ctx, updated); err != nil {
+		return nil, nil, err
+	}
+	return old, &updated, nil
+}
+
+// Delete removes a schedule the caller may reach
+func (s *reportScheduleService) Delete(ctx context.Context, id string, callerID uint64, admin bool) (*models.ReportSchedule, error) {
+	schedule, err := s.Get(ctx, id, callerID, admin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, schedule.ID); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// RunDue claims and runs the due schedules. Instances running it at once each claim
+// different runs.
+func (s *reportScheduleService) RunDue(ctx context.Context) (string, error) {
+	counts := map[string]int{}
+	summary := func() string {
+		return fmt.Sprintf("%d succeeded, %d failed", counts[models.ReportRunSucceeded], counts[models.ReportRunFailed])
+	}
+	for ctx.Err() == nil {
+		now := s.now()
+		due, err := s.repo.ListDue(ctx, now, reportScheduleBatch)
+		if err != nil {
+			return summary(), err
+		}
+		if len(due) == 0 {
+			break
+		}
+		claimedAny := false
+		for i := range due {
+			schedule := &due[i]
+			// A claimed run moves the schedule past now, so it is not listed again
+			next, err := nextReportRun(schedule.Schedule, now)
+			if err != nil {
+				// Only a schedule edited outside the API gets here; it stays due until fixed
+				slog.Error("Report schedule has an invalid schedule", "schedule_id", schedule.ID, "error", err)
+				continue
+			}
+			claimed, err := s.repo.Claim(ctx, schedule.ID, *schedule.NextRunAt, next)
+			if err != nil {
+				return summary(), err
+			}
+			if claimed {
+				claimedAny = true
+				counts[s.run(ctx, schedule)]++
+			}
+		}
+		// What is left is being run by other instances, or cannot be
+		if !claimedAny || len(due) < reportScheduleBatch {
+			break
+		}
+	}
+	return summary(), nil
+}
+
+// run renders a claimed schedule's report as its owner and records the outcome, returning
+// the status it ended in
+func (s *reportScheduleService) run(ctx context.Context, schedule *models.ReportSchedule) string {
+	// The schedule's row must be updated even when ctx is cancelled by shutdown
+	bg := context.WithoutCancel(ctx)
+	log := slog.With("schedule_id", schedule.ID, "report_path", schedule.ReportPath)
+
+	attachment, err := s.render(ctx, schedule)
+	status := models.ReportRunSucceeded
+	var attachmentID *uint64
+	var message *string
+	if err == nil {
+		attachmentID = &attachment.ID
+	} else {
+		status = models.ReportRunFailed
+		text := "The report failed; see the server logs"
+		var appErr *utils.AppError
+		if errors.As(err, &appErr) {
+			text = truncate(appErr.Message, 500)
+		}
+		message = &text
+		log.Error("Scheduled report failed", "error", err)
+	}
+
+	if err := s.repo.Finish(bg, schedule.ID, status, message, attachmentID, s.now()); err != nil {
+		log.Error("Failed to record the outcome of a scheduled report", "status", status, "error", err)
+	}
+	reportScheduleRuns.WithLabelValues(status).Inc()
+
+	// RunReport told the owner of a report that rendered
+	if status == models.ReportRunFailed {
+		notifyUser(bg, s.notifications, schedule.OwnerID, notify.Notification{
+			Type:    notify.TypeReportFinished,
+			Message: fmt.Sprintf("Your scheduled report %q failed", schedule.Name),
+			Data:    map[string]any{"schedule_id": schedule.ID, "report_path": schedule.ReportPath, "status": status},
+		})
+	}
+	return status
+}
+
+// render runs the report within the owner's tenant and saves the file as theirs
+func (s *reportScheduleService) render(ctx context.Context, schedule *models.ReportSchedule) (*models.Attachment, error) {
+	ctx = tenant.WithID(ctx, schedule.TenantID)
+	settings, err := s.tenants.Settings(ctx, schedule.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = tenant.WithSettings(ctx, settings)
+
+	runCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	owner := schedule.OwnerID
+	req := &models.JasperReportRequest{ReportPath: schedule.ReportPath, OutputFormat: schedule.OutputFormat, Parameters: schedule.Parameters}
+	_, data, err := s.reports.RunReport(runCtx, req, &owner)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, utils.NewTimeoutError("report", err)
+		}
+		return nil, err
+	}
+
+	contentType, ok := ReportContentType(schedule.OutputFormat)
+	if !ok {
+		contentType = "text/html; charset=utf-8"
+	}
+	filename := fmt.Sprintf("%s-%s.%s", path.Base(schedule.ReportPath), s.now().Format("20060102-1504"), schedule.OutputFormat)
+	return s.attachments.SaveExport(context.WithoutCancel(ctx), owner, filename, contentType, bytes.NewReader(data), int64(len(data)))
+}
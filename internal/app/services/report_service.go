@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+	"adminbe/pkg/jasper"
+)
+
+// ReportService interface defines business logic for JasperServer reports
+type ReportService interface {
+	// RunReport runs req for userID (nil when unknown) and returns the JSON result and the
+	// rendered report. Parameters of the form "attachment:<id>" pass one of the user's
+	// uploaded files, as a URL JasperServer downloads it from.
+	RunReport(ctx context.Context, req *models.JasperReportRequest, userID *uint64) (*models.JasperReportResponse, []byte, error)
+}
+
+// reportService implements ReportService
+type reportService struct {
+	client        *jasper.Client
+	publisher     domainevents.EventPublisher
+	notifications NotificationService
+	attachments   AttachmentService
+}
+
+// NewReportService creates a new report service over client; ReportCompleted events go to
+// publisher, the user who ran a report is notified through notifications, and file
+// parameters are resolved by attachments
+func NewReportService(client *jasper.Client, publisher domainevents.EventPublisher, notifications NotificationService, attachments AttachmentService) ReportService {
+	return &reportService{client: client, publisher: publisher, notifications: notifications, attachments: attachments}
+}
+
+// RunReport runs a report and announces it once it has rendered
+func (s *reportService) RunReport(ctx context.Context, req *models.JasperReportRequest, userID *uint64) (*models.JasperReportResponse, []byte, error) {
+	if s.client == nil {
+		return nil, nil, errors.New("JasperServer client is not initialized")
+	}
+
+	// The signed URLs go to JasperServer only, not back into the caller's request, which is
+	// audited and replayed
+	run := req
+	if hasAttachmentParameters(req.Parameters) {
+		if userID == nil {
+			return nil, nil, utils.NewForbiddenError("File parameters need a signed-in user")
+		}
+		params, err := s.attachments.ResolveReportParameters(ctx, req.Parameters, *userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved := *req
+		resolved.Parameters = params
+		run = &resolved
+	}
+
+	// Tenants may keep their reports in an organization of their own
+	start := time.Now()
+	result, data, err := s.client.RunReport(jasper.WithOrganization(ctx, tenant.SettingsFrom(ctx).JasperOrganization), run)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := ""
+	if userID != nil {
+		subject = strconv.FormatUint(*userID, 10)
+	}
+	publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeReportCompleted, subject, domainevents.ReportCompletedData{
+		ReportPath:   req.ReportPath,
+		OutputFormat: req.OutputFormat,
+		Bytes:        len(data),
+		DurationMs:   float64(time.Since(start).Microseconds()) / 1000,
+		UserID:       userID,
+	}))
+	// The user's other tabs and windows, and their notification center, learn it is ready
+	if userID != nil {
+		notifyUser(ctx, s.notifications, *userID, notify.Notification{
+			Type:    notify.TypeReportFinished,
+			Message: "Your report is ready",
+			Data:    map[string]any{"report_path": req.ReportPath, "output_format": req.OutputFormat},
+		})
+	}
+
+	return result, data, nil
+}
+
+// hasAttachmentParameters reports whether any parameter, or item of a list parameter, names
+// an uploaded file
+func hasAttachmentParameters(params map[string]interface{}) bool {
+	isRef := func(v interface{}) bool {
+		ref, ok := v.(string)
+		return ok && strings.HasPrefix(ref, attachmentParameterPrefix)
+	}
+	for _, value := range params {
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				if isRef(item) {
+					return true
+				}
+			}
+		} else if isRef(value) {
+			return true
+		}
+	}
+	return false
+}
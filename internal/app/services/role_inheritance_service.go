@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -13,11 +14,11 @@ import (
 
 // RoleInheritanceService interface defines business logic for role inheritances
 type RoleInheritanceService interface {
-	ListRoleInheritances() ([]models.RoleInheritance, error)
-	GetRoleInheritance(id string) (*models.RoleInheritance, error)
-	CreateRoleInheritance(req models.CreateRoleInheritanceRequest) (*models.RoleInheritance, error)
-	UpdateRoleInheritance(id string, req models.UpdateRoleInheritanceRequest) (*models.RoleInheritance, error)
-	DeleteRoleInheritance(id string) error
+	ListRoleInheritances(ctx context.Context) ([]models.RoleInheritance, error)
+	GetRoleInheritance(ctx context.Context, id string) (*models.RoleInheritance, error)
+	CreateRoleInheritance(ctx context.Context, req models.CreateRoleInheritanceRequest) (*models.RoleInheritance, error)
+	UpdateRoleInheritance(ctx context.Context, id string, req models.UpdateRoleInheritanceRequest) (*models.RoleInheritance, error)
+	DeleteRoleInheritance(ctx context.Context, id string) error
 }
 
 // roleInheritanceService implements RoleInheritanceService
@@ -31,8 +32,8 @@ func NewRoleInheritanceService(repo repositories.RoleInheritanceRepository) Role
 }
 
 // ListRoleInheritances handles listing all role inheritances
-func (s *roleInheritanceService) ListRoleInheritances() ([]models.RoleInheritance, error) {
-	inheritances, err := s.repo.GetAll()
+func (s *roleInheritanceService) ListRoleInheritances(ctx context.Context) ([]models.RoleInheritance, error) {
+	inheritances, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role inheritances: %w", err)
 	}
@@ -41,13 +42,13 @@ func (s *roleInheritanceService) ListRoleInheritances() ([]models.RoleInheritanc
 }
 
 // GetRoleInheritance handles getting a role inheritance by ID
-func (s *roleInheritanceService) GetRoleInheritance(id string) (*models.RoleInheritance, error) {
+func (s *roleInheritanceService) GetRoleInheritance(ctx context.Context, id string) (*models.RoleInheritance, error) {
 	inheritanceID, err := parseUint64(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	inheritance, err := s.repo.GetByID(inheritanceID)
+	inheritance, err := s.repo.GetByID(ctx, inheritanceID)
 	if err == sql.ErrNoRows {
 		return nil, gin.Error{
 			Err:  fmt.Errorf("role inheritance not found"),
@@ -62,7 +63,7 @@ func (s *roleInheritanceService) GetRoleInheritance(id string) (*models.RoleInhe
 }
 
 // CreateRoleInheritance handles creating a new role inheritance
-func (s *roleInheritanceService) CreateRoleInheritance(req models.CreateRoleInheritanceRequest) (*models.RoleInheritance, error) {
+func (s *roleInheritanceService) CreateRoleInheritance(ctx context.Context, req models.CreateRoleInheritanceRequest) (*models.RoleInheritance, error) {
 	now := time.Now()
 	inheritance := models.RoleInheritance{
 		RoleID:       req.RoleID,
@@ -70,13 +71,13 @@ func (s *roleInheritanceService) CreateRoleInheritance(req models.CreateRoleInhe
 		CreatedAt:    &now,
 	}
 
-	id, err := s.repo.Create(inheritance)
+	id, err := s.repo.Create(ctx, inheritance)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create role inheritance: %w", err)
 	}
 
 	// Return the created inheritance
-	createdInheritance, err := s.repo.GetByID(id)
+	createdInheritance, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve created role inheritance: %w", err)
 	}
@@ -85,14 +86,14 @@ func (s *roleInheritanceService) CreateRoleInheritance(req models.CreateRoleInhe
 }
 
 // UpdateRoleInheritance handles updating an existing role inheritance
-func (s *roleInheritanceService) UpdateRoleInheritance(id string, req models.UpdateRoleInheritanceRequest) (*models.RoleInheritance, error) {
+func (s *roleInheritanceService) UpdateRoleInheritance(ctx context.Context, id string, req models.UpdateRoleInheritanceRequest) (*models.RoleInheritance, error) {
 	inheritanceID, err := parseUint64(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
 	// Check if inheritance exists
-	_, err = s.repo.GetByID(inheritanceID)
+	_, err = s.repo.GetByID(ctx, inheritanceID)
 	if err == sql.ErrNoRows {
 		return nil, gin.Error{
 			Err:  fmt.Errorf("role inheritance not found"),
@@ -111,13 +112,13 @@ func (s *roleInheritanceService) UpdateRoleInheritance(id string, req models.Upd
 		updateData["parent_role_id"] = *req.ParentRoleID
 	}
 
-	err = s.repo.Update(inheritanceID, updateData)
+	err = s.repo.Update(ctx, inheritanceID, updateData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update role inheritance: %w", err)
 	}
 
 	// Return updated inheritance
-	updatedInheritance, err := s.repo.GetByID(inheritanceID)
+	updatedInheritance, err := s.repo.GetByID(ctx, inheritanceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve updated role inheritance: %w", err)
 	}
@@ -126,14 +127,14 @@ func (s *roleInheritanceService) UpdateRoleInheritance(id string, req models.Upd
 }
 
 // DeleteRoleInheritance handles deleting a role inheritance
-func (s *roleInheritanceService) DeleteRoleInheritance(id string) error {
+func (s *roleInheritanceService) DeleteRoleInheritance(ctx context.Context, id string) error {
 	inheritanceID, err := parseUint64(id)
 	if err != nil {
 		return fmt.Errorf("invalid ID: %w", err)
 	}
 
 	// Check if inheritance exists
-	_, err = s.repo.GetByID(inheritanceID)
+	_, err = s.repo.GetByID(ctx, inheritanceID)
 	if err == sql.ErrNoRows {
 		return gin.Error{
 			Err:  fmt.Errorf("role inheritance not found"),
@@ -144,7 +145,7 @@ func (s *roleInheritanceService) DeleteRoleInheritance(id string) error {
 		return fmt.Errorf("failed to check role inheritance existence: %w", err)
 	}
 
-	return s.repo.Delete(inheritanceID)
+	return s.repo.Delete(ctx, inheritanceID)
 }
 
 // parseUint64 is a helper function to parse uint64 from string
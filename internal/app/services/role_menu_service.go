@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -12,10 +13,10 @@ import (
 
 // RoleMenuService interface defines business logic for role menus
 type RoleMenuService interface {
-	ListRoleMenus() ([]models.RoleMenu, error)
-	GetRoleMenu(roleIDStr, menuIDStr string) (*models.RoleMenu, error)
-	CreateRoleMenu(req models.CreateRoleMenuRequest) (*models.RoleMenu, error)
-	DeleteRoleMenu(roleIDStr, menuIDStr string) error
+	ListRoleMenus(ctx context.Context) ([]models.RoleMenu, error)
+	GetRoleMenu(ctx context.Context, roleIDStr, menuIDStr string) (*models.RoleMenu, error)
+	CreateRoleMenu(ctx context.Context, req models.CreateRoleMenuRequest) (*models.RoleMenu, error)
+	DeleteRoleMenu(ctx context.Context, roleIDStr, menuIDStr string) error
 }
 
 // roleMenuService implements RoleMenuService
@@ -29,8 +30,8 @@ func NewRoleMenuService(repo repositories.RoleMenuRepository) RoleMenuService {
 }
 
 // ListRoleMenus handles listing all role-menu assignments
-func (s *roleMenuService) ListRoleMenus() ([]models.RoleMenu, error) {
-	roleMenus, err := s.repo.GetAll()
+func (s *roleMenuService) ListRoleMenus(ctx context.Context) ([]models.RoleMenu, error) {
+	roleMenus, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role menus: %w", err)
 	}
@@ -39,7 +40,7 @@ func (s *roleMenuService) ListRoleMenus() ([]models.RoleMenu, error) {
 }
 
 // GetRoleMenu handles getting a role-menu assignment by role and menu IDs
-func (s *roleMenuService) GetRoleMenu(roleIDStr, menuIDStr string) (*models.RoleMenu, error) {
+func (s *roleMenuService) GetRoleMenu(ctx context.Context, roleIDStr, menuIDStr string) (*models.RoleMenu, error) {
 	roleID, err := parseUint(roleIDStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid role ID: %w", err)
@@ -50,7 +51,7 @@ func (s *roleMenuService) GetRoleMenu(roleIDStr, menuIDStr string) (*models.Role
 		return nil, fmt.Errorf("invalid menu ID: %w", err)
 	}
 
-	roleMenu, err := s.repo.GetByRoleAndMenu(roleID, menuID)
+	roleMenu, err := s.repo.GetByRoleAndMenu(ctx, roleID, menuID)
 	if err == sql.ErrNoRows {
 		return nil, gin.Error{
 			Err:  fmt.Errorf("role-menu assignment not found"),
@@ -65,9 +66,9 @@ func (s *roleMenuService) GetRoleMenu(roleIDStr, menuIDStr string) (*models.Role
 }
 
 // CreateRoleMenu handles creating a new role-menu assignment
-func (s *roleMenuService) CreateRoleMenu(req models.CreateRoleMenuRequest) (*models.RoleMenu, error) {
+func (s *roleMenuService) CreateRoleMenu(ctx context.Context, req models.CreateRoleMenuRequest) (*models.RoleMenu, error) {
 	// Check if assignment already exists
-	existing, err := s.repo.GetByRoleAndMenu(req.RoleID, req.MenuID)
+	existing, err := s.repo.GetByRoleAndMenu(ctx, req.RoleID, req.MenuID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to check role menu existence: %w", err)
 	}
@@ -84,13 +85,13 @@ func (s *roleMenuService) CreateRoleMenu(req models.CreateRoleMenuRequest) (*mod
 		// deleted_at and deleted_by are nil for active
 	}
 
-	err = s.repo.Create(roleMenu)
+	err = s.repo.Create(ctx, roleMenu)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create role menu: %w", err)
 	}
 
 	// Return the created assignment
-	createdRoleMenu, err := s.repo.GetByRoleAndMenu(req.RoleID, req.MenuID)
+	createdRoleMenu, err := s.repo.GetByRoleAndMenu(ctx, req.RoleID, req.MenuID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve created role menu: %w", err)
 	}
@@ -99,7 +100,7 @@ func (s *roleMenuService) CreateRoleMenu(req models.CreateRoleMenuRequest) (*mod
 }
 
 // DeleteRoleMenu handles deleting a role-menu assignment
-func (s *roleMenuService) DeleteRoleMenu(roleIDStr, menuIDStr string) error {
+func (s *roleMenuService) DeleteRoleMenu(ctx context.Context, roleIDStr, menuIDStr string) error {
 	roleID, err := parseUint(roleIDStr)
 	if err != nil {
 		return fmt.Errorf("invalid role ID: %w", err)
@@ -111,7 +112,7 @@ func (s *roleMenuService) DeleteRoleMenu(roleIDStr, menuIDStr string) error {
 	}
 
 	// Check if assignment exists
-	_, err = s.repo.GetByRoleAndMenu(roleID, menuID)
+	_, err = s.repo.GetByRoleAndMenu(ctx, roleID, menuID)
 	if err == sql.ErrNoRows {
 		return gin.Error{
 			Err:  fmt.Errorf("role-menu assignment not found"),
@@ -122,5 +123,5 @@ func (s *roleMenuService) DeleteRoleMenu(roleIDStr, menuIDStr string) error {
 		return fmt.Errorf("failed to check role menu existence: %w", err)
 	}
 
-	return s.repo.Delete(roleID, menuID, nil) // TODO: get current user ID for audit
+	return s.repo.Delete(ctx, roleID, menuID, nil) // TODO: get current user ID for audit
 }
@@ -1,39 +1,47 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/repositories"
-
-	"github.com/gin-gonic/gin"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/utils"
 )
 
 // RoleService interface defines business logic for roles
 type RoleService interface {
-	ListRoles() ([]models.Role, error)
-	GetRole(id string) (*models.Role, error)
-	CreateRole(req models.CreateRoleRequest) (*models.Role, error)
-	UpdateRole(id string, req models.UpdateRoleRequest) (*models.Role, error)
-	DeleteRole(id string) error
+	ListRoles(ctx context.Context, sort []utils.SortTerm) ([]models.Role, error)
+	GetRole(ctx context.Context, id string) (*models.Role, error)
+	CreateRole(ctx context.Context, req models.CreateRoleRequest) (*models.Role, error)
+	UpdateRole(ctx context.Context, id string, req models.UpdateRoleRequest) (*models.Role, error)
+	PatchRole(ctx context.Context, id string, changes map[string]interface{}) (*models.Role, error)
+	DeleteRole(ctx context.Context, id string) error
+	// DeleteRoles soft deletes roles in one transaction, by the user deletedBy; RestoreRoles
+	// brings deleted ones back. Either reports each ID's outcome.
+	DeleteRoles(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error)
+	RestoreRoles(ctx context.Context, ids []uint64) (*models.BulkResult, error)
 }
 
 // roleService implements RoleService
 type roleService struct {
 	repo repositories.RoleRepository
+	tx   repositories.TxManager
 }
 
 // NewRoleService creates a new role service
-func NewRoleService(repo repositories.RoleRepository) RoleService {
-	return &roleService{repo: repo}
+func NewRoleService(repo repositories.RoleRepository, tx repositories.TxManager) RoleService {
+	return &roleService{repo: repo, tx: tx}
 }
 
-// ListRoles handles listing all roles
-func (s *roleService) ListRoles() ([]models.Role, error) {
-	roles, err := s.repo.GetAll()
+// ListRoles handles listing all roles in the given order (nil for the default)
+func (s *roleService) ListRoles(ctx context.Context, sort []utils.SortTerm) ([]models.Role, error) {
+	roles, err := s.repo.GetAll(ctx, sort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get roles: %w", err)
 	}
@@ -42,18 +50,15 @@ func (s *roleService) ListRoles() ([]models.Role, error) {
 }
 
 // GetRole handles getting a role by ID
-func (s *roleService) GetRole(id string) (*models.Role, error) {
+func (s *roleService) GetRole(ctx context.Context, id string) (*models.Role, error) {
 	roleID, err := parseUint(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	role, err := s.repo.GetByID(roleID)
+	role, err := s.repo.GetByID(ctx, roleID)
 	if err == sql.ErrNoRows {
-		return nil, gin.Error{
-			Err:  fmt.Errorf("role not found"),
-			Type: gin.ErrorTypePublic,
-		}
+		return nil, utils.NewNotFoundError("role")
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role: %w", err)
@@ -63,8 +68,8 @@ func (s *roleService) GetRole(id string) (*models.Role, error) {
 }
 
 // CreateRole handles creating a new role
-func (s *roleService) CreateRole(req models.CreateRoleRequest) (*models.Role, error) {
-	if err := s.validateRoleNameUniqueness(req.Name, 0); err != nil {
+func (s *roleService) CreateRole(ctx context.Context, req models.CreateRoleRequest) (*models.Role, error) {
+	if err := s.validateRoleNameUniqueness(ctx, req.Name, 0); err != nil {
 		return nil, err
 	}
 
@@ -76,40 +81,27 @@ func (s *roleService) CreateRole(req models.CreateRoleRequest) (*models.Role, er
 		UpdatedAt:   &now,
 	}
 
-	roleID, err := s.repo.Create(role)
+	roleID, err := s.repo.Create(ctx, role)
 	if err != nil {
-		// Check for duplicate key error
-		if strings.Contains(err.Error(), "1062") {
-			return nil, gin.Error{
-				Err:  fmt.Errorf("role name already exists"),
-				Type: gin.ErrorTypePublic,
-			}
+		if database.IsDuplicateKey(err) {
+			return nil, utils.NewConflictError("role name already exists", err)
 		}
 		return nil, fmt.Errorf("failed to create role: %w", err)
 	}
 
+	events.EntityChanged("roles", events.ActionCreated, strconv.FormatUint(uint64(roleID), 10))
+
 	// Return the created role
-	return s.retrieveRoleByID(roleID)
+	return s.retrieveRoleByID(ctx, roleID)
 }
 
 // UpdateRole handles updating an existing role
-func (s *roleService) UpdateRole(id string, req models.UpdateRoleRequest) (*models.Role, error) {
+func (s *roleService) UpdateRole(ctx context.Context, id string, req models.UpdateRoleRequest) (*models.Role, error) {
 	roleID, err := parseUint(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	if err := s.ensureRoleExists(roleID); err != nil {
-		return nil, err
-	}
-
-	// Check name uniqueness if name is being updated
-	if req.Name != nil {
-		if err := s.validateRoleNameUniqueness(*req.Name, roleID); err != nil {
-			return nil, err
-		}
-	}
-
 	updateData := make(map[string]interface{})
 	if req.Name != nil {
 		updateData["name"] = *req.Name
@@ -118,58 +110,92 @@ func (s *roleService) UpdateRole(id string, req models.UpdateRoleRequest) (*mode
 		updateData["description"] = req.Description
 	}
 
-	if err := s.repo.Update(roleID, updateData); err != nil {
-		// Check for duplicate key error
-		if strings.Contains(err.Error(), "1062") {
-			return nil, gin.Error{
-				Err:  fmt.Errorf("role name already exists"),
-				Type: gin.ErrorTypePublic,
-			}
+	return s.updateRole(ctx, roleID, updateData)
+}
+
+// PatchRole applies the columns of a merge patch (see models.PatchRoleRequest.Changes),
+// where a nil description clears it
+func (s *roleService) PatchRole(ctx context.Context, id string, changes map[string]interface{}) (*models.Role, error) {
+	roleID, err := parseUint(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID: %w", err)
+	}
+
+	return s.updateRole(ctx, roleID, changes)
+}
+
+// updateRole writes changes to an existing role and returns it
+func (s *roleService) updateRole(ctx context.Context, roleID uint, changes map[string]interface{}) (*models.Role, error) {
+	if err := s.ensureRoleExists(ctx, roleID); err != nil {
+		return nil, err
+	}
+
+	// Check name uniqueness if name is being updated
+	if name, ok := changes["name"].(string); ok {
+		if err := s.validateRoleNameUniqueness(ctx, name, roleID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Update(ctx, roleID, changes); err != nil {
+		if database.IsDuplicateKey(err) {
+			return nil, utils.NewConflictError("role name already exists", err)
 		}
 		return nil, fmt.Errorf("failed to update role: %w", err)
 	}
 
+	events.EntityChanged("roles", events.ActionUpdated, strconv.FormatUint(uint64(roleID), 10))
+
 	// Return updated role
-	return s.retrieveRoleByID(roleID)
+	return s.retrieveRoleByID(ctx, roleID)
 }
 
 // DeleteRole handles deleting a role
-func (s *roleService) DeleteRole(id string) error {
+func (s *roleService) DeleteRole(ctx context.Context, id string) error {
 	roleID, err := parseUint(id)
 	if err != nil {
 		return fmt.Errorf("invalid ID: %w", err)
 	}
 
-	if err := s.ensureRoleExists(roleID); err != nil {
+	if err := s.ensureRoleExists(ctx, roleID); err != nil {
 		return err
 	}
 
-	return s.repo.Delete(roleID, nil) // TODO: get current user ID for audit
+	if err := s.repo.Delete(ctx, roleID, nil); err != nil { // TODO: get current user ID for audit
+		return err
+	}
+
+	events.EntityChanged("roles", events.ActionDeleted, strconv.FormatUint(uint64(roleID), 10))
+	return nil
+}
+
+// DeleteRoles handles soft deleting roles in bulk
+func (s *roleService) DeleteRoles(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "roles", ids, deletedBy, false)
+}
+
+// RestoreRoles handles restoring soft-deleted roles in bulk
+func (s *roleService) RestoreRoles(ctx context.Context, ids []uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "roles", ids, nil, true)
 }
 
 // validateRoleNameUniqueness checks if a role name is unique, excluding a specific ID
-func (s *roleService) validateRoleNameUniqueness(name string, excludeID uint) error {
-	existing, err := s.repo.GetByName(name)
+func (s *roleService) validateRoleNameUniqueness(ctx context.Context, name string, excludeID uint) error {
+	existing, err := s.repo.GetByName(ctx, name)
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("failed to check role name uniqueness: %w", err)
 	}
 	if existing != nil && existing.ID != excludeID {
-		return gin.Error{
-			Err:  fmt.Errorf("role name already exists"),
-			Type: gin.ErrorTypePublic,
-		}
+		return utils.NewConflictError("role name already exists", nil)
 	}
 	return nil
 }
 
 // ensureRoleExists checks if a role exists by ID
-func (s *roleService) ensureRoleExists(roleID uint) error {
-	_, err := s.repo.GetByID(roleID)
+func (s *roleService) ensureRoleExists(ctx context.Context, roleID uint) error {
+	_, err := s.repo.GetByID(ctx, roleID)
 	if err == sql.ErrNoRows {
-		return gin.Error{
-			Err:  fmt.Errorf("role not found"),
-			Type: gin.ErrorTypePublic,
-		}
+		return utils.NewNotFoundError("role")
 	}
 	if err != nil {
 		return fmt.Errorf("failed to check role existence: %w", err)
@@ -178,8 +204,8 @@ func (s *roleService) ensureRoleExists(roleID uint) error {
 }
 
 // retrieveRoleByID fetches a role by ID with error formatting
-func (s *roleService) retrieveRoleByID(roleID uint) (*models.Role, error) {
-	role, err := s.repo.GetByID(roleID)
+func (s *roleService) retrieveRoleByID(ctx context.Context, roleID uint) (*models.Role, error) {
+	role, err := s.repo.GetByID(ctx, roleID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve role: %w", err)
 	}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database/dbtest"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/utils"
+)
+
+// TestRoleServicesIntegration runs the role and user-role services on the engine of
+// DB_DRIVER: the generated role id, the name lookups and updates with rebound placeholders,
+// a bulk delete and restore in a transaction, and the NOW() soft delete of an assignment
+func TestRoleServicesIntegration(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	roleRepo := repositories.NewRoleRepository(db)
+	roles := NewRoleService(roleRepo, repositories.NewTxManager(db))
+	userRoles := NewUserRoleService(repositories.NewUserRoleRepository(db), domainevents.Nop(), nil)
+
+	suffix := time.Now().UnixNano()
+	role, err := roles.CreateRole(ctx, models.CreateRoleRequest{Name: fmt.Sprintf("it-role-%d", suffix)})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	userID, err := repositories.NewUserRepository(db).Create(ctx, models.CreateUserRequest{
+		Username: fmt.Sprintf("itrole%d", suffix), Email: fmt.Sprintf("itrole%d@example.com", suffix),
+	}, "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		dbtest.Exec(t, db, "DELETE FROM user_roles WHERE user_id = ?", userID)
+		dbtest.Exec(t, db, "DELETE FROM roles WHERE id = ?", role.ID)
+		dbtest.Exec(t, db, "DELETE FROM users WHERE id = ?", userID)
+	})
+	if role.ID == 0 || role.CreatedAt == nil {
+		t.Fatalf("CreateRole = %+v, want the stored role with its generated id", role)
+	}
+	id := strconv.FormatUint(uint64(role.ID), 10)
+
+	if _, err := roles.CreateRole(ctx, models.CreateRoleRequest{Name: role.Name}); !isConflict(err) {
+		t.Errorf("CreateRole with a taken name: %v, want a conflict", err)
+	}
+
+	description := "integration"
+	updated, err := roles.UpdateRole(ctx, id, models.UpdateRoleRequest{Description: &description})
+	if err != nil {
+		t.Fatalf("UpdateRole: %v", err)
+	}
+	if updated.Description == nil || *updated.Description != description {
+		t.Errorf("UpdateRole description = %v, want %q", updated.Description, description)
+	}
+
+	assigned, err := userRoles.CreateUserRole(ctx, models.CreateUserRoleRequest{UserID: userID, RoleID: role.ID})
+	if err != nil {
+		t.Fatalf("CreateUserRole: %v", err)
+	}
+	if assigned.UserID != userID || assigned.RoleID != role.ID || assigned.DeletedAt != nil {
+		t.Errorf("CreateUserRole = %+v, want an active assignment of role %d to user %d", assigned, role.ID, userID)
+	}
+	if err := userRoles.DeleteUserRole(ctx, strconv.FormatUint(userID, 10), id); err != nil {
+		t.Fatalf("DeleteUserRole: %v", err)
+	}
+	var deletedAt *time.Time
+	if err := db.QueryRow("SELECT deleted_at FROM user_roles WHERE user_id = ? AND role_id = ?", userID, role.ID).Scan(&deletedAt); err != nil {
+		t.Fatalf("read the deleted assignment: %v", err)
+	}
+	if deletedAt == nil {
+		t.Error("DeleteUserRole left deleted_at empty, want NOW()")
+	}
+	if _, err := userRoles.CreateUserRole(ctx, models.CreateUserRoleRequest{UserID: userID, RoleID: role.ID}); err != nil {
+		t.Errorf("CreateUserRole reinstating a deleted assignment: %v", err)
+	}
+
+	missing := uint64(role.ID) + 1_000_000
+	deleted, err := roles.DeleteRoles(ctx, []uint64{uint64(role.ID), missing}, &userID)
+	if err != nil {
+		t.Fatalf("DeleteRoles: %v", err)
+	}
+	if deleted.Changed != 1 || len(deleted.Outcomes) != 2 || deleted.Outcomes[0].Result != models.BulkDeleted || deleted.Outcomes[1].Result != models.BulkNotFound {
+		t.Errorf("DeleteRoles = %+v, want role %d deleted and %d not found", deleted, role.ID, missing)
+	}
+	if _, err := roles.GetRole(ctx, id); err == nil {
+		t.Error("GetRole found a deleted role")
+	}
+
+	restored, err := roles.RestoreRoles(ctx, []uint64{uint64(role.ID)})
+	if err != nil {
+		t.Fatalf("RestoreRoles: %v", err)
+	}
+	if restored.Changed != 1 {
+		t.Errorf("RestoreRoles = %+v, want the role restored", restored)
+	}
+	if got, err := roles.GetRole(ctx, id); err != nil || got.Name != role.Name {
+		t.Errorf("GetRole after RestoreRoles = %+v, %v; want %q", got, err, role.Name)
+	}
+}
+
+// isConflict reports whether err is the service's 409
+func isConflict(err error) bool {
+	appErr, ok := err.(*utils.AppError)
+	return ok && appErr.Code == 409
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/utils"
+	"adminbe/internal/pkg/validation"
+)
+
+// SCIMService interface defines the SCIM 2.0 provisioning of users, and of roles as groups.
+// IDs are the users' and roles' own, as strings.
+type SCIMService interface {
+	// ListUsers returns a page of users, oldest first, or only the one with userName when it
+	// is not empty; startIndex is 1-based
+	ListUsers(ctx context.Context, userName string, startIndex, count int) (*models.SCIMListResponse, error)
+	GetUser(ctx context.Context, id string) (*models.SCIMUser, error)
+	CreateUser(ctx context.Context, user models.SCIMUser) (*models.SCIMUser, error)
+	ReplaceUser(ctx context.Context, id string, user models.SCIMUser) (*models.SCIMUser, error)
+	PatchUser(ctx context.Context, id string, ops []models.SCIMPatchOperation) (*models.SCIMUser, error)
+	DeleteUser(ctx context.Context, id string) error
+
+	// ListGroups returns a page of groups, oldest first, or only the one with displayName
+	// when it is not empty. Members are left out unless withMembers is set.
+	ListGroups(ctx context.Context, displayName string, startIndex, count int, withMembers bool) (*models.SCIMListResponse, error)
+	GetGroup(ctx context.Context, id string) (*models.SCIMGroup, error)
+	CreateGroup(ctx context.Context, group models.SCIMGroup) (*models.SCIMGroup, error)
+	ReplaceGroup(ctx context.Context, id string, group models.SCIMGroup) (*models.SCIMGroup, error)
+	PatchGroup(ctx context.Context, id string, ops []models.SCIMPatchOperation) (*models.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+// scimService implements SCIMService over the user, role and user-role services, so
+// provisioned changes are cached, announced and published like any other
+type scimService struct {
+	users         UserService
+	roles         RoleService
+	userRoles     UserRoleService
+	userRepo      repositories.UserRepository
+	roleRepo      repositories.RoleRepository
+	userRolesRepo repositories.UserRoleRepository
+	tx            repositories.TxManager
+}
+
+// NewSCIMService creates a new SCIM service
+func NewSCIMService(users UserService, roles RoleService, userRoles UserRoleService,
+	userRepo repositories.UserRepository, roleRepo repositories.RoleRepository,
+	userRolesRepo repositories.UserRoleRepository, tx repositories.TxManager) SCIMService {
+	return &scimService{
+		users:         users,
+		roles:         roles,
+		userRoles:     userRoles,
+		userRepo:      userRepo,
+		roleRepo:      roleRepo,
+		userRolesRepo: userRolesRepo,
+		tx:            tx,
+	}
+}
+
+// memberFilterPattern matches the path of a remove operation on one group member
+var memberFilterPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+// ListUsers handles listing users
+func (s *scimService) ListUsers(ctx context.Context, userName string, startIndex, count int) (*models.SCIMListResponse, error) {
+	if userName != "" {
+		user, err := s.userRepo.GetByUsername(ctx, userName)
+		if err == sql.ErrNoRows {
+			return scimList(0, startIndex, nil), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		var resources []any
+		if startIndex == 1 && count > 0 {
+			resources = append(resources, scimUser(user))
+		}
+		return scimList(1, startIndex, resources), nil
+	}
+
+	total, err := s.userRepo.CountActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resources []any
+	if count > 0 {
+		users, err := s.userRepo.GetAll(ctx, count, startIndex-1, []utils.SortTerm{{Field: "id"}})
+		if err != nil {
+			return nil, err
+		}
+		for i := range users {
+			resources = append(resources, scimUser(&users[i]))
+		}
+	}
+	return scimList(total, startIndex, resources), nil
+}
+
+// GetUser handles getting a user
+func (s *scimService) GetUser(ctx context.Context, id string) (*models.SCIMUser, error) {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	scim := scimUser(user)
+	return &scim, nil
+}
+
+// getUser loads the user with a SCIM ID; IDs that are not numbers are not found either
+func (s *scimService) getUser(ctx context.Context, id string) (*models.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("user")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// CreateUser handles provisioning a user. Users provisioned without a password get a random
+// one: they are expected to sign in through the identity provider.
+func (s *scimService) CreateUser(ctx context.Context, in models.SCIMUser) (*models.SCIMUser, error) {
+	email, err := validateSCIMUser(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureUserNameFree(ctx, in.UserName, 0); err != nil {
+		return nil, err
+	}
+
+	password := in.Password
+	if password == "" {
+		password = randomPassword()
+	}
+	status := uint8(1)
+	if in.Active != nil && !*in.Active {
+		status = 0
+	}
+
+	user, err := s.users.CreateUser(ctx, models.CreateUserRequest{
+		Username: in.UserName,
+		Email:    email,
+		Password: password,
+		Status:   &status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	scim := scimUser(user)
+	return &scim, nil
+}
+
+// ReplaceUser handles replacing a user. active keeps its value when omitted.
+func (s *scimService) ReplaceUser(ctx context.Context, id string, in models.SCIMUser) (*models.SCIMUser, error) {
+	current, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.replaceUser(ctx, current, in)
+}
+
+// replaceUser writes in over the current user
+func (s *scimService) replaceUser(ctx context.Context, current *models.User, in models.SCIMUser) (*models.SCIMUser, error) {
+	email, err := validateSCIMUser(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureUserNameFree(ctx, in.UserName, current.ID); err != nil {
+		return nil, err
+	}
+
+	req := models.UpdateUserRequest{Username: in.UserName, Email: email, Password: in.Password}
+	if in.Active != nil {
+		status := uint8(0)
+		if *in.Active {
+			status = 1
+		}
+		req.Status = &status
+	}
+
+	user, err := s.users.UpdateUser(ctx, strconv.FormatUint(current.ID, 10), req)
+	if err != nil {
+		return nil, err
+	}
+	scim := scimUser(user)
+	return &scim, nil
+}
+
+// PatchUser handles a PATCH of a user: userName, emails, active and password can change,
+// and operations on other attributes are ignored
+func (s *scimService) PatchUser(ctx context.Context, id string, ops []models.SCIMPatchOperation) (*models.SCIMUser, error) {
+	current, err := s.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := scimUser(current)
+	for _, op := range ops {
+		if err := applyUserPatch(&user, op); err != nil {
+			return nil, err
+		}
+	}
+	return s.replaceUser(ctx, current, user)
+}
+
+// DeleteUser handles deprovisioning a user
+func (s *scimService) DeleteUser(ctx context.Context, id string) error {
+	user, err := s.getUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.users.DeleteUser(ctx, strconv.FormatUint(user.ID, 10))
+}
+
+// ensureUserNameFree fails when another user than exceptID has userName
+func (s *scimService) ensureUserNameFree(ctx context.Context, userName string, exceptID uint64) error {
+	existing, err := s.userRepo.GetByUsername(ctx, userName)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check username uniqueness: %w", err)
+	}
+	if existing != nil && existing.ID != exceptID {
+		return utils.NewConflictError("userName is already taken", nil)
+	}
+	return nil
+}
+
+// ListGroups handles listing groups
+func (s *scimService) ListGroups(ctx context.Context, displayName string, startIndex, count int, withMembers bool) (*models.SCIMListResponse, error) {
+	var roles []models.Role
+	if displayName != "" {
+		role, err := s.roleRepo.GetByName(ctx, displayName)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get role: %w", err)
+		}
+		if role != nil {
+			roles = append(roles, *role)
+		}
+	} else {
+		var err error
+		// Roles are few, so they are paged here rather than in the query
+		roles, err = s.roleRepo.GetAll(ctx, []utils.SortTerm{{Field: "id"}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total := len(roles)
+	page := roles[min(startIndex-1, total):min(startIndex-1+count, total)]
+	var resources []any
+	for i := range page {
+		var members []models.User
+		if withMembers {
+			var err error
+			if members, err = s.userRolesRepo.GetUsersByRole(ctx, page[i].ID); err != nil {
+				return nil, err
+			}
+		}
+		resources = append(resources, scimGroup(&page[i], members))
+	}
+	return scimList(total, startIndex, resources), nil
+}
+
+// GetGroup handles getting a group with its members
+func (s *scimService) GetGroup(ctx context.Context, id string) (*models.SCIMGroup, error) {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.userRolesRepo.GetUsersByRole(ctx, role.ID)
+	if err != nil {
+		return nil, err
+	}
+	group := scimGroup(role, members)
+	return &group, nil
+}
+
+// getRole loads the role with a SCIM ID; IDs that are not numbers are not found either
+func (s *scimService) getRole(ctx context.Context, id string) (*models.Role, error) {
+	roleID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, utils.NewNotFoundError("group")
+	}
+	role, err := s.roleRepo.GetByID(ctx, uint(roleID))
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("group")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// CreateGroup handles provisioning a group, with its members, as a role
+func (s *scimService) CreateGroup(ctx context.Context, in models.SCIMGroup) (*models.SCIMGroup, error) {
+	if err := validateSCIMGroupName(in.DisplayName); err != nil {
+		return nil, err
+	}
+	members, err := memberIDs(in.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	var role *models.Role
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		role, err = s.roles.CreateRole(ctx, models.CreateRoleRequest{Name: in.DisplayName})
+		if err != nil {
+			return err
+		}
+		return s.setMembers(ctx, role.ID, members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, strconv.FormatUint(uint64(role.ID), 10))
+}
+
+// ReplaceGroup handles replacing a group's name and members; omitted members are removed
+func (s *scimService) ReplaceGroup(ctx context.Context, id string, in models.SCIMGroup) (*models.SCIMGroup, error) {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := memberIDs(in.Members)
+	if err != nil {
+		return nil, err
+	}
+	return s.writeGroup(ctx, role, in.DisplayName, members)
+}
+
+// PatchGroup handles a PATCH of a group: displayName and members can change, and
+// operations on other attributes are ignored
+func (s *scimService) PatchGroup(ctx context.Context, id string, ops []models.SCIMPatchOperation) (*models.SCIMGroup, error) {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.userRolesRepo.GetUsersByRole(ctx, role.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := role.Name
+	members := make(map[uint64]bool, len(current))
+	for _, u := range current {
+		members[u.ID] = true
+	}
+	for _, op := range ops {
+		if err := applyGroupPatch(&name, members, op); err != nil {
+			return nil, err
+		}
+	}
+	return s.writeGroup(ctx, role, name, members)
+}
+
+// writeGroup renames role to name when it differs and gives it exactly members, atomically
+func (s *scimService) writeGroup(ctx context.Context, role *models.Role, name string, members map[uint64]bool) (*models.SCIMGroup, error) {
+	if err := validateSCIMGroupName(name); err != nil {
+		return nil, err
+	}
+
+	id := strconv.FormatUint(uint64(role.ID), 10)
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if name != role.Name {
+			if _, err := s.roles.UpdateRole(ctx, id, models.UpdateRoleRequest{Name: &name}); err != nil {
+				return err
+			}
+		}
+		return s.setMembers(ctx, role.ID, members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, id)
+}
+
+// setMembers assigns roleID to the users in members and takes it from everyone else
+func (s *scimService) setMembers(ctx context.Context, roleID uint, members map[uint64]bool) error {
+	current, err := s.userRolesRepo.GetUsersByRole(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	has := make(map[uint64]bool, len(current))
+	for _, u := range current {
+		has[u.ID] = true
+		if !members[u.ID] {
+			err := s.userRoles.DeleteUserRole(ctx, strconv.FormatUint(u.ID, 10), strconv.FormatUint(uint64(roleID), 10))
+			if err != nil {
+				return fmt.Errorf("failed to remove member %d: %w", u.ID, err)
+			}
+		}
+	}
+	for userID := range members {
+		if has[userID] {
+			continue
+		}
+		if _, err := s.userRepo.GetByID(ctx, userID); err == sql.ErrNoRows {
+			return utils.NewValidationError(fmt.Sprintf("Member %d is not a user", userID))
+		} else if err != nil {
+			return fmt.Errorf("failed to check member %d: %w", userID, err)
+		}
+		if _, err := s.userRoles.CreateUserRole(ctx, models.CreateUserRoleRequest{UserID: userID, RoleID: roleID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteGroup handles deleting a group's role
+func (s *scimService) DeleteGroup(ctx context.Context, id string) error {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.roles.DeleteRole(ctx, strconv.FormatUint(uint64(role.ID), 10))
+}
+
+// scimList builds a list response
+func scimList(total, startIndex int, resources []any) *models.SCIMListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// scimUser represents a user as a SCIM user
+func scimUser(u *models.User) models.SCIMUser {
+	active := u.Status == 1
+	return models.SCIMUser{
+		Schemas:  []string{models.SCIMSchemaUser},
+		ID:       strconv.FormatUint(u.ID, 10),
+		UserName: u.Username,
+		Emails:   []models.SCIMEmail{{Value: u.Email, Type: "work", Primary: true}},
+		Active:   &active,
+		Meta:     &models.SCIMMeta{ResourceType: "User", Created: u.CreatedAt, LastModified: u.UpdatedAt},
+	}
+}
+
+// scimGroup represents a role and the users holding it as a SCIM group
+func scimGroup(r *models.Role, members []models.User) models.SCIMGroup {
+	group := models.SCIMGroup{
+		Schemas:     []string{models.SCIMSchemaGroup},
+		ID:          strconv.FormatUint(uint64(r.ID), 10),
+		DisplayName: r.Name,
+		Meta:        &models.SCIMMeta{ResourceType: "Group", Created: r.CreatedAt, LastModified: r.UpdatedAt},
+	}
+	for _, u := range members {
+		group.Members = append(group.Members, models.SCIMMember{Value: strconv.FormatUint(u.ID, 10), Display: u.Username})
+	}
+	return group
+}
+
+// validateSCIMUser checks a user against the rules of the users API and returns its
+// primary email
+func validateSCIMUser(u models.SCIMUser) (string, error) {
+	if len(u.UserName) < 3 || len(u.UserName) > 100 || !validation.IsUsername(u.UserName) {
+		return "", utils.NewValidationError("userName must be 3 to 100 letters, digits, dots, underscores or hyphens, starting with a letter or digit")
+	}
+	email := primaryEmail(u.Emails)
+	if email == "" {
+		return "", utils.NewValidationError("emails must hold an address")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return "", utils.NewValidationError(fmt.Sprintf("%q is not an email address", email))
+	}
+	if u.Password != "" && len(u.Password) < 6 {
+		return "", utils.NewValidationError("password must be at least 6 characters")
+	}
+	return email, nil
+}
+
+// validateSCIMGroupName checks a group name against the rules of the roles API
+func validateSCIMGroupName(name string) error {
+	if name == "" || len(name) > 100 {
+		return utils.NewValidationError("displayName must be 1 to 100 characters")
+	}
+	return nil
+}
+
+// primaryEmail picks the primary address, or else the first
+func primaryEmail(emails []models.SCIMEmail) string {
+	for _, e := range emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+// memberIDs reads the user IDs of group members
+func memberIDs(members []models.SCIMMember) (map[uint64]bool, error) {
+	ids := make(map[uint64]bool, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseUint(m.Value, 10, 64)
+		if err != nil {
+			return nil, utils.NewValidationError(fmt.Sprintf("Member %q is not a user ID", m.Value))
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// applyUserPatch applies one PATCH operation to u
+func applyUserPatch(u *models.SCIMUser, op models.SCIMPatchOperation) error {
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+	case "remove":
+		switch attr := strings.ToLower(op.Path); {
+		case attr == "username", attr == "active", strings.HasPrefix(attr, "emails"):
+			return utils.NewValidationError(fmt.Sprintf("%s cannot be removed", op.Path))
+		}
+		return nil
+	default:
+		return utils.NewValidationError(fmt.Sprintf("Unknown operation %q", op.Op))
+	}
+
+	if op.Path != "" {
+		return setUserAttribute(u, op.Path, op.Value)
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &attrs); err != nil {
+		return utils.NewValidationError("The value of an operation without a path must be an object")
+	}
+	for name, value := range attrs {
+		if err := setUserAttribute(u, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUserAttribute sets the attribute at path; attributes that are not stored are ignored
+func setUserAttribute(u *models.SCIMUser, path string, value json.RawMessage) error {
+	var err error
+	switch attr := strings.ToLower(path); {
+	case attr == "username":
+		err = json.Unmarshal(value, &u.UserName)
+	case attr == "active":
+		var active bool
+		if active, err = scimBool(value); err == nil {
+			u.Active = &active
+		}
+	case attr == "password":
+		err = json.Unmarshal(value, &u.Password)
+	case attr == "emails":
+		err = json.Unmarshal(value, &u.Emails)
+	case attr == "emails.value", strings.HasPrefix(attr, "emails[") && strings.HasSuffix(attr, "].value"):
+		var email string
+		if err = json.Unmarshal(value, &email); err == nil {
+			u.Emails = []models.SCIMEmail{{Value: email, Type: "work", Primary: true}}
+		}
+	}
+	if err != nil {
+		return utils.NewValidationError(fmt.Sprintf("Invalid value for %s", path))
+	}
+	return nil
+}
+
+// scimBool reads a boolean, which some identity providers send as the string "True" or "False"
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+// applyGroupPatch applies one PATCH operation to a group's name and member set
+func applyGroupPatch(name *string, members map[uint64]bool, op models.SCIMPatchOperation) error {
+	path := strings.ToLower(op.Path)
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		replace := strings.EqualFold(op.Op, "replace")
+		switch path {
+		case "members":
+			return patchMembers(members, op.Value, replace, false)
+		case "displayname":
+			if err := json.Unmarshal(op.Value, name); err != nil {
+				return utils.NewValidationError("Invalid value for displayName")
+			}
+			return nil
+		case "":
+			var attrs struct {
+				DisplayName *string         `json:"displayName"`
+				Members     json.RawMessage `json:"members"`
+			}
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return utils.NewValidationError("The value of an operation without a path must be an object")
+			}
+			if attrs.DisplayName != nil {
+				*name = *attrs.DisplayName
+			}
+			if attrs.Members != nil {
+				return patchMembers(members, attrs.Members, replace, false)
+			}
+		}
+		return nil
+
+	case "remove":
+		if m := memberFilterPattern.FindStringSubmatch(op.Path); m != nil {
+			id, err := strconv.ParseUint(m[1], 10, 64)
+			if err == nil {
+				delete(members, id)
+			}
+			return nil
+		}
+		switch path {
+		case "members":
+			if len(op.Value) == 0 || string(op.Value) == "null" {
+				clear(members)
+				return nil
+			}
+			return patchMembers(members, op.Value, false, true)
+		case "displayname":
+			return utils.NewValidationError("displayName cannot be removed")
+		}
+		return nil
+
+	default:
+		return utils.NewValidationError(fmt.Sprintf("Unknown operation %q", op.Op))
+	}
+}
+
+// patchMembers adds the members listed in value, replaces the set with them, or removes them
+func patchMembers(members map[uint64]bool, value json.RawMessage, replace, remove bool) error {
+	var list []models.SCIMMember
+	if err := json.Unmarshal(value, &list); err != nil {
+		return utils.NewValidationError("members must be a list of {\"value\": \"<user id>\"}")
+	}
+	ids, err := memberIDs(list)
+	if err != nil {
+		return err
+	}
+	if replace {
+		clear(members)
+	}
+	for id := range ids {
+		if remove {
+			delete(members, id)
+		} else {
+			members[id] = true
+		}
+	}
+	return nil
+}
+
+// randomPassword is the password of users provisioned without one, which nobody knows
+func randomPassword() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
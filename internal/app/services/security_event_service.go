@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/utils"
+)
+
+// SecurityEventTypes are the types security events may have
+var SecurityEventTypes = []string{secevents.TypeAuthFailures, secevents.TypePrivilegeEscalation, secevents.TypeAuditExport, secevents.TypeBackupDownload}
+
+// SecurityEventService interface defines business logic for security events: recording
+// what the detectors raise, and reviewing and acknowledging it
+type SecurityEventService interface {
+	Record(ctx context.Context, e secevents.Event) (*models.SecurityEvent, error)
+	ListEvents(ctx context.Context, filter models.SecurityEventFilter) ([]models.SecurityEvent, error)
+	GetEvent(ctx context.Context, id string) (*models.SecurityEvent, error)
+	// Acknowledge closes an open event; acknowledging a closed one is a conflict
+	Acknowledge(ctx context.Context, id string, by uint64, note string) (*models.SecurityEvent, error)
+}
+
+// securityEventService implements SecurityEventService
+type securityEventService struct {
+	repo repositories.SecurityEventRepository
+}
+
+// NewSecurityEventService creates a new security event service
+func NewSecurityEventService(repo repositories.SecurityEventRepository) SecurityEventService {
+	return &securityEventService{repo: repo}
+}
+
+// Record stores a raised event
+func (s *securityEventService) Record(ctx context.Context, e secevents.Event) (*models.SecurityEvent, error) {
+	event := models.SecurityEvent{
+		Type:      e.Type,
+		Severity:  e.Severity,
+		Message:   truncate(e.Message, 500),
+		UserID:    e.UserID,
+		CreatedAt: &e.Time,
+	}
+	if e.IP != "" {
+		event.IPAddress = &e.IP
+	}
+	if len(e.Details) > 0 {
+		details, err := json.Marshal(e.Details)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode security event details: %w", err)
+		}
+		event.Details = details
+	}
+
+	id, err := s.repo.Create(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	event.ID = id
+	return &event, nil
+}
+
+// ListEvents handles listing security events, newest first
+func (s *securityEventService) ListEvents(ctx context.Context, filter models.SecurityEventFilter) ([]models.SecurityEvent, error) {
+	if filter.Type != "" && !slices.Contains(SecurityEventTypes, filter.Type) {
+		return nil, utils.NewValidationError("type must be one of " + strings.Join(SecurityEventTypes, ", "))
+	}
+	if filter.Severity != "" && !slices.Contains(secevents.Severities, filter.Severity) {
+		return nil, utils.NewValidationError("severity must be one of " + strings.Join(secevents.Severities, ", "))
+	}
+	events, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security events: %w", err)
+	}
+	return events, nil
+}
+
+// GetEvent handles getting a security event by ID
+func (s *securityEventService) GetEvent(ctx context.Context, id string) (*models.SecurityEvent, error) {
+	eventID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || eventID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+
+	event, err := s.repo.GetByID(ctx, eventID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Security event")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security event: %w", err)
+	}
+	return event, nil
+}
+
+// Acknowledge handles closing a security event
+func (s *securityEventService) Acknowledge(ctx context.Context, id string, by uint64, note string) (*models.SecurityEvent, error) {
+	event, err := s.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var notePtr *string
+	if note != "" {
+		notePtr = &note
+	}
+	ok, err := s.repo.Acknowledge(ctx, event.ID, by, notePtr)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, utils.NewConflictError("Security event already acknowledged", nil)
+	}
+	return s.GetEvent(ctx, id)
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/tenant"
+	"adminbe/internal/pkg/utils"
+)
+
+// tenantSlugPattern is what a slug may look like: it names the tenant in a header and, with
+// TENANT_BASE_DOMAIN, as a subdomain
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// TenantService interface defines business logic for the tenants one deployment serves
+type TenantService interface {
+	ListTenants(ctx context.Context) ([]models.Tenant, error)
+	GetTenant(ctx context.Context, id string) (*models.Tenant, error)
+	// CreateTenant creates a tenant with an admin role and its first administrator
+	CreateTenant(ctx context.Context, req models.CreateTenantRequest) (*models.CreatedTenant, error)
+	// UpdateTenant renames or disables the tenant and returns it before and after
+	UpdateTenant(ctx context.Context, id string, req models.UpdateTenantRequest) (old, updated *models.Tenant, err error)
+	// Resolve returns the ID of the active tenant with slug, reporting whether there is one;
+	// it is the middleware.TenantLookup of every request naming a tenant, so it is cached
+	Resolve(ctx context.Context, slug string) (uint, bool, error)
+	// GetSettings returns what the tenant overrides of the global configuration
+	GetSettings(ctx context.Context, id string) (*models.TenantSettings, error)
+	// UpdateSettings replaces the tenant's settings and returns them before and after
+	UpdateSettings(ctx context.Context, id string, req models.TenantSettings) (old, updated *models.TenantSettings, err error)
+	// Settings returns the settings of tenantID as requests apply them; it is the
+	// middleware.TenantSettingsLoader of every request naming a tenant, so it is cached
+	Settings(ctx context.Context, tenantID uint) (*tenant.Settings, error)
+}
+
+// tenantService implements TenantService
+type tenantService struct {
+	repo      repositories.TenantRepository
+	roles     repositories.RoleRepository
+	users     UserService
+	tx        repositories.TxManager
+	cache     cache.Cache
+	adminRole string
+}
+
+// NewTenantService creates a new tenant service; new tenants get a role named adminRole,
+// held by their first user
+func NewTenantService(repo repositories.TenantRepository, roles repositories.RoleRepository, users UserService, tx repositories.TxManager, c cache.Cache, adminRole string) TenantService {
+	return &tenantService{repo: repo, roles: roles, users: users, tx: tx, cache: c, adminRole: adminRole}
+}
+
+// ListTenants handles listing the tenants
+func (s *tenantService) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	return list, nil
+}
+
+// GetTenant handles getting a tenant by ID
+func (s *tenantService) GetTenant(ctx context.Context, id string) (*models.Tenant, error) {
+	tenantID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || tenantID == 0 {
+		return nil, utils.NewValidationError("Invalid ID")
+	}
+
+	t, err := s.repo.GetByID(ctx, uint(tenantID))
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Tenant")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return t, nil
+}
+
+// CreateTenant handles creating a tenant. The tenant, its admin role and its administrator
+// are written atomically.
+func (s *tenantService) CreateTenant(ctx context.Context, req models.CreateTenantRequest) (*models.CreatedTenant, error) {
+	if !tenantSlugPattern.MatchString(req.Slug) {
+		return nil, utils.NewValidationError("Invalid slug", "slug may only hold lowercase letters, digits and inner hyphens")
+	}
+
+	now := time.Now()
+	created := models.CreatedTenant{Tenant: models.Tenant{
+		Slug: req.Slug, Name: req.Name, Status: models.TenantActive, CreatedAt: &now, UpdatedAt: &now,
+	}}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		id, err := s.repo.Create(ctx, created.Tenant)
+		if database.IsDuplicateKey(err) {
+			return utils.NewConflictError("A tenant with this slug already exists", nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create tenant: %w", err)
+		}
+		created.ID = id
+
+		// The role and the user are the new tenant's
+		ctx = tenant.WithID(ctx, id)
+		description := "Administrator of " + req.Name
+		created.AdminRoleID, err = s.roles.Create(ctx, models.Role{Name: s.adminRole, Description: &description, CreatedAt: &now, UpdatedAt: &now})
+		if err != nil {
+			return fmt.Errorf("failed to create admin role: %w", err)
+		}
+		admin, err := s.users.CreateUser(ctx, models.CreateUserRequest{
+			Username: req.AdminUsername,
+			Email:    req.AdminEmail,
+			Password: req.AdminPassword,
+			RoleIDs:  []uint{created.AdminRoleID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create tenant administrator: %w", err)
+		}
+		created.AdminUserID = admin.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events.EntityChanged("tenants", events.ActionCreated, strconv.FormatUint(uint64(created.ID), 10))
+	return &created, nil
+}
+
+// UpdateTenant handles renaming or disabling a tenant; the default tenant cannot be disabled
+func (s *tenantService) UpdateTenant(ctx context.Context, id string, req models.UpdateTenantRequest) (*models.Tenant, *models.Tenant, error) {
+	old, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := *old
+	if req.Name != nil {
+		t.Name = *req.Name
+	}
+	if req.Status != nil {
+		if t.ID == tenant.DefaultID && *req.Status != models.TenantActive {
+			return nil, nil, utils.NewValidationError("The default tenant cannot be disabled")
+		}
+		t.Status = *req.Status
+	}
+	if err := s.repo.Update(ctx, t); err != nil {
+		return nil, nil, fmt.Errorf("failed to update tenant: %w", err)
+	}
+
+	events.EntityChanged("tenants", events.ActionUpdated, strconv.FormatUint(uint64(t.ID), 10))
+	return old, &t, nil
+}
+
+// resolvedTenant is what Resolve caches for a slug; ID is 0 when no active tenant has it
+type resolvedTenant struct {
+	ID uint `json:"id"`
+}
+
+// Resolve handles looking a tenant up by slug
+func (s *tenantService) Resolve(ctx context.Context, slug string) (uint, bool, error) {
+	if !tenantSlugPattern.MatchString(slug) {
+		return 0, false, nil
+	}
+	// Unknown slugs are cached too, and dropped with the rest when a tenant changes
+	resolved, _, err := cache.GetOrLoad(ctx, s.cache, fmt.Sprintf(cache.CacheKeyTenant, slug), cache.TTL("tenants", cache.TTLDetail), func(ctx context.Context) (resolvedTenant, error) {
+		t, err := s.repo.GetBySlug(ctx, slug)
+		if err == sql.ErrNoRows {
+			return resolvedTenant{}, nil
+		}
+		if err != nil {
+			return resolvedTenant{}, err
+		}
+		if t.Status != models.TenantActive {
+			return resolvedTenant{}, nil
+		}
+		return resolvedTenant{ID: t.ID}, nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to resolve tenant: %w", err)
+	}
+	return resolved.ID, resolved.ID != 0, nil
+}
+
+// Names of the tenant_settings rows; branding strings and cache TTLs are stored one per row
+// under a prefix
+const (
+	settingPrayerMethod       = "prayer_method"
+	settingJasperOrganization = "jasper_organization"
+	settingBrandingPrefix     = "branding."
+	settingCacheTTLPrefix     = "cache_ttl."
+)
+
+// maxSettingValue is the longest value a tenant_settings row holds
+const maxSettingValue = 255
+
+// GetSettings handles getting a tenant's settings
+func (s *tenantService) GetSettings(ctx context.Context, id string) (*models.TenantSettings, error) {
+	t, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.Settings(ctx, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant settings: %w", err)
+	}
+	return settingsFromRows(rows), nil
+}
+
+// UpdateSettings handles replacing a tenant's settings. Empty strings are left out, keeping
+// the global value. The default tenant has no settings: the global configuration is its own.
+func (s *tenantService) UpdateSettings(ctx context.Context, id string, req models.TenantSettings) (*models.TenantSettings, *models.TenantSettings, error) {
+	t, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.ID == tenant.DefaultID {
+		return nil, nil, utils.NewValidationError("The default tenant has no settings", "change the global configuration instead")
+	}
+	rows, err := settingsRows(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var old map[string]string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.repo.Settings(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		old = current
+		return s.repo.ReplaceSettings(ctx, t.ID, rows)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update tenant settings: %w", err)
+	}
+
+	events.EntityChanged("tenants", events.ActionUpdated, strconv.FormatUint(uint64(t.ID), 10))
+	return settingsFromRows(old), settingsFromRows(rows), nil
+}
+
+// Settings handles loading the settings requests for tenantID apply
+func (s *tenantService) Settings(ctx context.Context, tenantID uint) (*tenant.Settings, error) {
+	settings, _, err := cache.GetOrLoad(ctx, s.cache, fmt.Sprintf(cache.CacheKeyTenantSettings, tenantID), cache.TTL("tenants", cache.TTLDetail), func(ctx context.Context) (*tenant.Settings, error) {
+		rows, err := s.repo.Settings(ctx, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		return appliedSettings(rows), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant settings: %w", err)
+	}
+	return settings, nil
+}
+
+// settingsRows validates req and flattens it into tenant_settings rows by name
+func settingsRows(req models.TenantSettings) (map[string]string, error) {
+	rows := map[string]string{}
+	var problems []string
+	set := func(name, value string) {
+		if len(value) > maxSettingValue {
+			problems = append(problems, fmt.Sprintf("%s may be at most %d characters", name, maxSettingValue))
+			return
+		}
+		if value != "" {
+			rows[name] = value
+		}
+	}
+
+	if req.PrayerMethod != "" && !slices.Contains(models.PrayerMethods, req.PrayerMethod) {
+		problems = append(problems, "prayer_method must be one of "+strings.Join(models.PrayerMethods, ", "))
+	}
+	set(settingPrayerMethod, req.PrayerMethod)
+	set(settingJasperOrganization, req.JasperOrganization)
+	for name, value := range req.Branding {
+		if !slices.Contains(models.BrandingFields, name) {
+			problems = append(problems, fmt.Sprintf("branding.%s is not a branding string (want one of %s)", name, strings.Join(models.BrandingFields, ", ")))
+			continue
+		}
+		set(settingBrandingPrefix+name, value)
+	}
+	for key, value := range req.CacheTTLs {
+		if !cache.KnownTTLKey(key) {
+			problems = append(problems, fmt.Sprintf("cache_ttls.%s does not name a TTL class or an entity and class", key))
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			problems = append(problems, fmt.Sprintf("cache_ttls.%s must be a positive duration such as 10m", key))
+			continue
+		}
+		set(settingCacheTTLPrefix+key, value)
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return nil, utils.NewValidationError("Invalid tenant settings", strings.Join(problems, "; "))
+	}
+	return rows, nil
+}
+
+// settingsFromRows is the inverse of settingsRows
+func settingsFromRows(rows map[string]string) *models.TenantSettings {
+	var settings models.TenantSettings
+	for name, value := range rows {
+		switch {
+		case name == settingPrayerMethod:
+			settings.PrayerMethod = value
+		case name == settingJasperOrganization:
+			settings.JasperOrganization = value
+		case strings.HasPrefix(name, settingBrandingPrefix):
+			if settings.Branding == nil {
+				settings.Branding = map[string]string{}
+			}
+			settings.Branding[strings.TrimPrefix(name, settingBrandingPrefix)] = value
+		case strings.HasPrefix(name, settingCacheTTLPrefix):
+			if settings.CacheTTLs == nil {
+				settings.CacheTTLs = map[string]string{}
+			}
+			settings.CacheTTLs[strings.TrimPrefix(name, settingCacheTTLPrefix)] = value
+		}
+	}
+	return &settings
+}
+
+// appliedSettings turns tenant_settings rows into the settings requests apply, skipping
+// durations that no longer parse
+func appliedSettings(rows map[string]string) *tenant.Settings {
+	stored := settingsFromRows(rows)
+	settings := &tenant.Settings{
+		PrayerMethod:       stored.PrayerMethod,
+		JasperOrganization: stored.JasperOrganization,
+		Branding:           stored.Branding,
+	}
+	for key, value := range stored.CacheTTLs {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			if settings.CacheTTLs == nil {
+				settings.CacheTTLs = map[string]time.Duration{}
+			}
+			settings.CacheTTLs[key] = d
+		}
+	}
+	return settings
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/tabular"
+	"adminbe/internal/pkg/utils"
+	"adminbe/internal/pkg/validation"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+var importRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "import",
+	Name:      "rows_total",
+	Help:      "Rows of uploaded imports by entity and result: for users valid, invalid or created; for locations create, update, unchanged, skipped or invalid.",
+}, []string{"entity", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(importRows)
+}
+
+// The columns of a user import; the first name of each is the one documented
+var (
+	userImportUsername = []string{"username", "user", "login"}
+	userImportEmail    = []string{"email", "e-mail", "mail"}
+	userImportPassword = []string{"password"}
+	userImportStatus   = []string{"status", "active"}
+	userImportRoles    = []string{"roles", "role"}
+)
+
+// UserImportService interface defines business logic for creating users in bulk from an
+// uploaded spreadsheet
+type UserImportService interface {
+	// Import checks every row of table and, unless dryRun or a row is invalid, creates all
+	// the users with their roles in one transaction
+	Import(ctx context.Context, table *tabular.Table, dryRun bool) (*models.UserImportResult, error)
+}
+
+// userImportService implements UserImportService
+type userImportService struct {
+	repo      repositories.UserRepository
+	roles     repositories.RoleRepository
+	userRoles repositories.UserRoleRepository
+	tx        repositories.TxManager
+	hasher    *password.Hasher
+	publisher domainevents.EventPublisher
+}
+
+// NewUserImportService creates a user import service. Passwords are hashed by hasher, and
+// UserCreated and RoleAssigned events go to publisher as for users created one at a time.
+func NewUserImportService(repo repositories.UserRepository, roles repositories.RoleRepository, userRoles repositories.UserRoleRepository,
+	tx repositories.TxManager, hasher *password.Hasher, publisher domainevents.EventPublisher) UserImportService {
+	return &userImportService{repo: repo, roles: roles, userRoles: userRoles, tx: tx, hasher: hasher, publisher: publisher}
+}
+
+// Import handles validating and creating the users of an uploaded file
+func (s *userImportService) Import(ctx context.Context, table *tabular.Table, dryRun bool) (*models.UserImportResult, error) {
+	col := map[string]int{
+		"username": table.Column(userImportUsername...),
+		"email":    table.Column(userImportEmail...),
+		"password": table.Column(userImportPassword...),
+		"status":   table.Column(userImportStatus...),
+		"roles":    table.Column(userImportRoles...),
+	}
+	if col["username"] < 0 || col["email"] < 0 {
+		return nil, utils.NewValidationError("The file needs username and email columns",
+			"optional columns: password, status (1 or 0), roles (names or IDs separated by ';')")
+	}
+	if len(table.Rows) == 0 {
+		return nil, utils.NewValidationError("The file has no rows below its header")
+	}
+	cell := func(row tabular.Row, name string) string {
+		if i := col[name]; i >= 0 {
+			return row.Cells[i]
+		}
+		return ""
+	}
+
+	result := &models.UserImportResult{DryRun: dryRun, Total: len(table.Rows), Rows: make([]models.UserImportRow, len(table.Rows))}
+	records := make([]models.UserImportRecord, len(table.Rows))
+	for i, row := range table.Rows {
+		rec := models.UserImportRecord{
+			Username: cell(row, "username"),
+			Email:    cell(row, "email"),
+			Password: cell(row, "password"),
+			Roles:    splitList(cell(row, "roles")),
+		}
+		errs := map[string]validation.FieldError{}
+		if v := cell(row, "status"); v != "" {
+			if status, ok := parseImportStatus(v); ok {
+				rec.Status = &status
+			} else {
+				errs["status"] = validation.FieldError{Code: validation.CodeNotAllowed, Message: "must be 1 (active) or 0 (inactive)"}
+			}
+		}
+		for field, fe := range validation.Fields(rec) {
+			if _, seen := errs[field]; !seen {
+				errs[field] = fe
+			}
+		}
+		records[i] = rec
+		result.Rows[i] = models.UserImportRow{Row: row.Line, Username: rec.Username, Email: rec.Email, Roles: rec.Roles, Errors: errs}
+	}
+
+	if err := s.checkDuplicates(ctx, records, result.Rows); err != nil {
+		return nil, err
+	}
+	roleIDs, err := s.resolveRoles(ctx, records, result.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result.Rows {
+		row := &result.Rows[i]
+		if len(row.Errors) > 0 {
+			row.Errors = validation.Localize(ctx, row.Errors)
+			row.Result = models.ImportRowInvalid
+			result.Invalid++
+		} else {
+			row.Errors = nil
+			row.Result = models.ImportRowValid
+			result.Valid++
+		}
+	}
+	if dryRun || result.Invalid > 0 {
+		importRows.WithLabelValues("users", models.ImportRowValid).Add(float64(result.Valid))
+		importRows.WithLabelValues("users", models.ImportRowInvalid).Add(float64(result.Invalid))
+		return result, nil
+	}
+
+	if err := s.create(ctx, records, roleIDs, result); err != nil {
+		return nil, err
+	}
+	importRows.WithLabelValues("users", models.ImportRowCreated).Add(float64(result.Created))
+	return result, nil
+}
+
+// checkDuplicates flags the usernames and emails that an earlier row of the file or an
+// existing user has, case-insensitively as the database compares them
+func (s *userImportService) checkDuplicates(ctx context.Context, records []models.UserImportRecord, rows []models.UserImportRow) error {
+	var usernames, emails []string
+	firstUsername := make(map[string]int)
+	firstEmail := make(map[string]int)
+	for i, rec := range records {
+		for _, f := range []struct {
+			field, value string
+			first        map[string]int
+			all          *[]string
+		}{{"username", rec.Username, firstUsername, &usernames}, {"email", rec.Email, firstEmail, &emails}} {
+			if f.value == "" {
+				continue
+			}
+			key := strings.ToLower(f.value)
+			if j, dup := f.first[key]; dup {
+				if _, seen := rows[i].Errors[f.field]; !seen {
+					rows[i].Errors[f.field] = validation.FieldError{Code: validation.CodeDuplicate, Message: fmt.Sprintf("is also in row %d", rows[j].Row)}
+				}
+				continue
+			}
+			f.first[key] = i
+			*f.all = append(*f.all, f.value)
+		}
+	}
+
+	takenUsernames, takenEmails, err := s.repo.TakenLogins(ctx, usernames, emails)
+	if err != nil {
+		return err
+	}
+	for i, rec := range records {
+		if takenUsernames[strings.ToLower(rec.Username)] {
+			if _, seen := rows[i].Errors["username"]; !seen {
+				rows[i].Errors["username"] = validation.FieldError{Code: validation.CodeDuplicate, Message: "is taken by another user"}
+			}
+		}
+		if takenEmails[strings.ToLower(rec.Email)] {
+			if _, seen := rows[i].Errors["email"]; !seen {
+				rows[i].Errors["email"] = validation.FieldError{Code: validation.CodeDuplicate, Message: "is taken by another user"}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveRoles maps the roles of each row, by name or ID, to role IDs, flagging the rows
+// naming a role that does not exist
+func (s *userImportService) resolveRoles(ctx context.Context, records []models.UserImportRecord, rows []models.UserImportRow) ([][]uint, error) {
+	known := make(map[string]uint)
+	missing := make(map[string]bool)
+	roleIDs := make([][]uint, len(records))
+	for i, rec := range records {
+		var unknown []string
+		for _, ref := range rec.Roles {
+			key := strings.ToLower(ref)
+			if _, ok := known[key]; !ok && !missing[key] {
+				id, found, err := s.lookupRole(ctx, ref)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					known[key] = id
+				} else {
+					missing[key] = true
+				}
+			}
+			if missing[key] {
+				unknown = append(unknown, ref)
+				continue
+			}
+			if !slices.Contains(roleIDs[i], known[key]) {
+				roleIDs[i] = append(roleIDs[i], known[key])
+			}
+		}
+		if len(unknown) > 0 {
+			if _, seen := rows[i].Errors["roles"]; !seen {
+				rows[i].Errors["roles"] = validation.FieldError{Code: validation.CodeNotFound, Message: "no such role: " + strings.Join(unknown, ", ")}
+			}
+		}
+	}
+	return roleIDs, nil
+}
+
+// lookupRole finds a role by ID when ref is a number, by name otherwise
+func (s *userImportService) lookupRole(ctx context.Context, ref string) (uint, bool, error) {
+	var role *models.Role
+	var err error
+	if id, convErr := strconv.ParseUint(ref, 10, 32); convErr == nil {
+		role, err = s.roles.GetByID(ctx, uint(id))
+	} else {
+		role, err = s.roles.GetByName(ctx, ref)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if role.DeletedAt != nil {
+		return 0, false, nil
+	}
+	return role.ID, true, nil
+}
+
+// create hashes the passwords, in parallel as bcrypt and argon2id are slow by design, then
+// inserts every user and role assignment in one transaction
+func (s *userImportService) create(ctx context.Context, records []models.UserImportRecord, roleIDs [][]uint, result *models.UserImportResult) error {
+	hashes := make([]string, len(records))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for i, rec := range records {
+		g.Go(func() error {
+			plain := rec.Password
+			if plain == "" {
+				plain = randomPassword()
+			}
+			hash, err := s.hasher.Hash(gctx, plain)
+			if err != nil {
+				return fmt.Errorf("password hash failed: %w", err)
+			}
+			hashes[i] = hash
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	userIDs := make([]uint64, len(records))
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for i, rec := range records {
+			req := models.CreateUserRequest{Username: rec.Username, Email: rec.Email, Status: rec.Status, RoleIDs: roleIDs[i]}
+			id, err := s.repo.Create(ctx, req, hashes[i])
+			if database.IsDuplicateKey(err) {
+				return utils.NewConflictError(fmt.Sprintf("Row %d: the username or email was taken meanwhile; nothing was imported", result.Rows[i].Row), err)
+			}
+			if err != nil {
+				return fmt.Errorf("row %d: %w", result.Rows[i].Row, err)
+			}
+			for _, roleID := range roleIDs[i] {
+				if err := s.userRoles.Create(ctx, models.UserRole{UserID: id, RoleID: roleID}); err != nil {
+					return fmt.Errorf("row %d: failed to assign role %d: %w", result.Rows[i].Row, roleID, err)
+				}
+			}
+			userIDs[i] = id
+
+			subject := strconv.FormatUint(id, 10)
+			status := uint8(1)
+			if rec.Status != nil {
+				status = *rec.Status
+			}
+			ids := roleIDs[i]
+			if ids == nil {
+				ids = []uint{}
+			}
+			publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeUserCreated, subject, domainevents.UserCreatedData{
+				UserID:   id,
+				Username: rec.Username,
+				Email:    rec.Email,
+				Status:   status,
+				RoleIDs:  ids,
+			}))
+			for _, roleID := range roleIDs[i] {
+				publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeRoleAssigned, subject,
+					domainevents.RoleAssignedData{UserID: id, RoleID: roleID}))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range result.Rows {
+		id := userIDs[i]
+		result.Rows[i].UserID = &id
+		result.Rows[i].Result = models.ImportRowCreated
+		events.EntityChanged("users", events.ActionCreated, strconv.FormatUint(id, 10))
+	}
+	result.Committed = true
+	result.Created = len(records)
+	return nil
+}
+
+// parseImportStatus reads a status cell: 1 or 0, or the words for them
+func parseImportStatus(v string) (uint8, bool) {
+	switch strings.ToLower(v) {
+	case "1", "active", "true", "yes", "aktif":
+		return 1, true
+	case "0", "inactive", "false", "no", "nonaktif":
+		return 0, true
+	}
+	return 0, false
+}
+
+// splitList splits a cell listing values with ';', ',' or '|', dropping blanks
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' || r == '|' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
@@ -13,10 +14,10 @@ import (
 
 // UserMenuService interface defines business logic for user menus
 type UserMenuService interface {
-	ListUserMenus() ([]models.UserMenu, error)
-	GetUserMenu(userIDStr, menuIDStr string) (*models.UserMenu, error)
-	CreateUserMenu(req models.CreateUserMenuRequest) (*models.UserMenu, error)
-	DeleteUserMenu(userIDStr, menuIDStr string) error
+	ListUserMenus(ctx context.Context) ([]models.UserMenu, error)
+	GetUserMenu(ctx context.Context, userIDStr, menuIDStr string) (*models.UserMenu, error)
+	CreateUserMenu(ctx context.Context, req models.CreateUserMenuRequest) (*models.UserMenu, error)
+	DeleteUserMenu(ctx context.Context, userIDStr, menuIDStr string) error
 }
 
 // userMenuService implements UserMenuService
@@ -30,8 +31,8 @@ func NewUserMenuService(repo repositories.UserMenuRepository) UserMenuService {
 }
 
 // ListUserMenus handles listing all user-menu assignments
-func (s *userMenuService) ListUserMenus() ([]models.UserMenu, error) {
-	userMenus, err := s.repo.GetAll()
+func (s *userMenuService) ListUserMenus(ctx context.Context) ([]models.UserMenu, error) {
+	userMenus, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user menus: %w", err)
 	}
@@ -40,7 +41,7 @@ func (s *userMenuService) ListUserMenus() ([]models.UserMenu, error) {
 }
 
 // GetUserMenu handles getting a user-menu assignment by user and menu IDs
-func (s *userMenuService) GetUserMenu(userIDStr, menuIDStr string) (*models.UserMenu, error) {
+func (s *userMenuService) GetUserMenu(ctx context.Context, userIDStr, menuIDStr string) (*models.UserMenu, error) {
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID: %w", err)
@@ -51,7 +52,7 @@ func (s *userMenuService) GetUserMenu(userIDStr, menuIDStr string) (*models.User
 		return nil, fmt.Errorf("invalid menu ID: %w", err)
 	}
 
-	userMenu, err := s.repo.GetByUserAndMenu(userID, menuID)
+	userMenu, err := s.repo.GetByUserAndMenu(ctx, userID, menuID)
 	if err == sql.ErrNoRows {
 		return nil, gin.Error{
 			Err:  fmt.Errorf("user-menu assignment not found"),
@@ -66,9 +67,9 @@ func (s *userMenuService) GetUserMenu(userIDStr, menuIDStr string) (*models.User
 }
 
 // CreateUserMenu handles creating a new user-menu assignment
-func (s *userMenuService) CreateUserMenu(req models.CreateUserMenuRequest) (*models.UserMenu, error) {
+func (s *userMenuService) CreateUserMenu(ctx context.Context, req models.CreateUserMenuRequest) (*models.UserMenu, error) {
 	// Check if assignment already exists
-	existing, err := s.repo.GetByUserAndMenu(req.UserID, req.MenuID)
+	existing, err := s.repo.GetByUserAndMenu(ctx, req.UserID, req.MenuID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to check user menu existence: %w", err)
 	}
@@ -85,13 +86,13 @@ func (s *userMenuService) CreateUserMenu(req models.CreateUserMenuRequest) (*mod
 		// deleted_at and deleted_by are nil for active
 	}
 
-	err = s.repo.Create(userMenu)
+	err = s.repo.Create(ctx, userMenu)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user menu: %w", err)
 	}
 
 	// Return the created assignment
-	createdUserMenu, err := s.repo.GetByUserAndMenu(req.UserID, req.MenuID)
+	createdUserMenu, err := s.repo.GetByUserAndMenu(ctx, req.UserID, req.MenuID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve created user menu: %w", err)
 	}
@@ -100,7 +101,7 @@ func (s *userMenuService) CreateUserMenu(req models.CreateUserMenuRequest) (*mod
 }
 
 // DeleteUserMenu handles deleting a user-menu assignment
-func (s *userMenuService) DeleteUserMenu(userIDStr, menuIDStr string) error {
+func (s *userMenuService) DeleteUserMenu(ctx context.Context, userIDStr, menuIDStr string) error {
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid user ID: %w", err)
@@ -112,7 +113,7 @@ func (s *userMenuService) DeleteUserMenu(userIDStr, menuIDStr string) error {
 	}
 
 	// Check if assignment exists
-	_, err = s.repo.GetByUserAndMenu(userID, menuID)
+	_, err = s.repo.GetByUserAndMenu(ctx, userID, menuID)
 	if err == sql.ErrNoRows {
 		return gin.Error{
 			Err:  fmt.Errorf("user-menu assignment not found"),
@@ -123,5 +124,5 @@ func (s *userMenuService) DeleteUserMenu(userIDStr, menuIDStr string) error {
 		return fmt.Errorf("failed to check user menu existence: %w", err)
 	}
 
-	return s.repo.Delete(userID, menuID, nil) // TODO: get current user ID for audit
+	return s.repo.Delete(ctx, userID, menuID, nil) // TODO: get current user ID for audit
 }
@@ -1,37 +1,44 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UserRoleService interface defines business logic for user roles
 type UserRoleService interface {
-	ListUserRoles() ([]models.UserRole, error)
-	GetUserRole(userIDStr, roleIDStr string) (*models.UserRole, error)
-	CreateUserRole(req models.CreateUserRoleRequest) (*models.UserRole, error)
-	DeleteUserRole(userIDStr, roleIDStr string) error
+	ListUserRoles(ctx context.Context) ([]models.UserRole, error)
+	GetUserRole(ctx context.Context, userIDStr, roleIDStr string) (*models.UserRole, error)
+	CreateUserRole(ctx context.Context, req models.CreateUserRoleRequest) (*models.UserRole, error)
+	DeleteUserRole(ctx context.Context, userIDStr, roleIDStr string) error
 }
 
 // userRoleService implements UserRoleService
 type userRoleService struct {
-	repo repositories.UserRoleRepository
+	repo          repositories.UserRoleRepository
+	publisher     domainevents.EventPublisher
+	notifications NotificationService
 }
 
-// NewUserRoleService creates a new user role service
-func NewUserRoleService(repo repositories.UserRoleRepository) UserRoleService {
-	return &userRoleService{repo: repo}
+// NewUserRoleService creates a new user role service; RoleAssigned events go to publisher,
+// and the user is notified through notifications
+func NewUserRoleService(repo repositories.UserRoleRepository, publisher domainevents.EventPublisher, notifications NotificationService) UserRoleService {
+	return &userRoleService{repo: repo, publisher: publisher, notifications: notifications}
 }
 
 // ListUserRoles handles listing all user-role assignments
-func (s *userRoleService) ListUserRoles() ([]models.UserRole, error) {
-	userRoles, err := s.repo.GetAll()
+func (s *userRoleService) ListUserRoles(ctx context.Context) ([]models.UserRole, error) {
+	userRoles, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user roles: %w", err)
 	}
@@ -40,7 +47,7 @@ func (s *userRoleService) ListUserRoles() ([]models.UserRole, error) {
 }
 
 // GetUserRole handles getting a user-role assignment by user and role IDs
-func (s *userRoleService) GetUserRole(userIDStr, roleIDStr string) (*models.UserRole, error) {
+func (s *userRoleService) GetUserRole(ctx context.Context, userIDStr, roleIDStr string) (*models.UserRole, error) {
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID: %w", err)
@@ -51,7 +58,7 @@ func (s *userRoleService) GetUserRole(userIDStr, roleIDStr string) (*models.User
 		return nil, fmt.Errorf("invalid role ID: %w", err)
 	}
 
-	userRole, err := s.repo.GetByUserAndRole(userID, roleID)
+	userRole, err := s.repo.GetByUserAndRole(ctx, userID, roleID)
 	if err == sql.ErrNoRows {
 		return nil, gin.Error{
 			Err:  fmt.Errorf("user-role assignment not found"),
@@ -66,17 +73,14 @@ func (s *userRoleService) GetUserRole(userIDStr, roleIDStr string) (*models.User
 }
 
 // CreateUserRole handles creating a new user-role assignment
-func (s *userRoleService) CreateUserRole(req models.CreateUserRoleRequest) (*models.UserRole, error) {
+func (s *userRoleService) CreateUserRole(ctx context.Context, req models.CreateUserRoleRequest) (*models.UserRole, error) {
 	// Check if assignment already exists
-	existing, err := s.repo.GetByUserAndRole(req.UserID, req.RoleID)
+	existing, err := s.repo.GetByUserAndRole(ctx, req.UserID, req.RoleID)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to check user role existence: %w", err)
 	}
 	if existing != nil {
-		return nil, gin.Error{
-			Err:  fmt.Errorf("user-role assignment already exists"),
-			Type: gin.ErrorTypePublic,
-		}
+		return nil, utils.NewConflictError("User-role assignment already exists", nil)
 	}
 
 	userRole := models.UserRole{
@@ -85,22 +89,31 @@ func (s *userRoleService) CreateUserRole(req models.CreateUserRoleRequest) (*mod
 		// deleted_at and deleted_by are nil for active
 	}
 
-	err = s.repo.Create(userRole)
+	err = s.repo.Create(ctx, userRole)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user role: %w", err)
 	}
 
 	// Return the created assignment
-	createdUserRole, err := s.repo.GetByUserAndRole(req.UserID, req.RoleID)
+	createdUserRole, err := s.repo.GetByUserAndRole(ctx, req.UserID, req.RoleID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve created user role: %w", err)
 	}
 
+	publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeRoleAssigned, strconv.FormatUint(req.UserID, 10),
+		domainevents.RoleAssignedData{UserID: req.UserID, RoleID: req.RoleID}))
+	// Roles are read from the token, so the new one applies from the user's next sign-in
+	notifyUser(ctx, s.notifications, req.UserID, notify.Notification{
+		Type:    notify.TypeRoleGranted,
+		Message: "You have been granted a new role; sign in again to use it",
+		Data:    map[string]any{"role_id": req.RoleID},
+	})
+
 	return createdUserRole, nil
 }
 
 // DeleteUserRole handles deleting a user-role assignment
-func (s *userRoleService) DeleteUserRole(userIDStr, roleIDStr string) error {
+func (s *userRoleService) DeleteUserRole(ctx context.Context, userIDStr, roleIDStr string) error {
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid user ID: %w", err)
@@ -112,7 +125,7 @@ func (s *userRoleService) DeleteUserRole(userIDStr, roleIDStr string) error {
 	}
 
 	// Check if assignment exists
-	_, err = s.repo.GetByUserAndRole(userID, roleID)
+	_, err = s.repo.GetByUserAndRole(ctx, userID, roleID)
 	if err == sql.ErrNoRows {
 		return gin.Error{
 			Err:  fmt.Errorf("user-role assignment not found"),
@@ -123,5 +136,5 @@ func (s *userRoleService) DeleteUserRole(userIDStr, roleIDStr string) error {
 		return fmt.Errorf("failed to check user role existence: %w", err)
 	}
 
-	return s.repo.Delete(userID, roleID, nil) // TODO: get current user ID for audit
+	return s.repo.Delete(ctx, userID, roleID, nil) // TODO: get current user ID for audit
 }
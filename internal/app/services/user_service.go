@@ -1,73 +1,169 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
+	"time"
 
 	"adminbe/internal/app/models"
 	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/tenant"
 	"adminbe/internal/pkg/utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserService interface defines business logic for users
 type UserService interface {
-	ListUsers(page, limit int) (map[string]interface{}, error)
-	GetUser(id string) (*models.User, error)
-	CreateUser(req models.CreateUserRequest) (*models.User, error)
-	UpdateUser(id string, req models.UpdateUserRequest) (*models.User, error)
-	DeleteUser(id string) error
+	ListUsers(ctx context.Context, page, limit int, sort []utils.SortTerm) (map[string]interface{}, error)
+	ListUsersAfter(ctx context.Context, cursor string, limit int) (map[string]interface{}, error)
+	ExportUsers(ctx context.Context, sort []utils.SortTerm, fn func(*models.User) error) error
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error)
+	UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest) (*models.User, error)
+	DeleteUser(ctx context.Context, id string) error
+	// DeleteUsers soft deletes users in one transaction, by the user deletedBy; RestoreUsers
+	// brings deleted ones back. Either reports each ID's outcome.
+	DeleteUsers(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error)
+	RestoreUsers(ctx context.Context, ids []uint64) (*models.BulkResult, error)
+	// ForcePasswordReset makes the users req selects change their password at their next
+	// sign-in, returning those it flagged
+	ForcePasswordReset(ctx context.Context, req models.ForcePasswordResetRequest) ([]models.User, error)
 }
 
 // userService implements UserService
 type userService struct {
-	repo repositories.UserRepository
+	repo      repositories.UserRepository
+	userRoles repositories.UserRoleRepository
+	tx        repositories.TxManager
+	cache     cache.Cache
+	hasher    *password.Hasher
+	publisher domainevents.EventPublisher
+}
+
+// NewUserService creates a new user service.
+// Reads go through c; entries are dropped by the "users" invalidation rule on every change.
+// Passwords are hashed by hasher. UserCreated and RoleAssigned events go to publisher.
+func NewUserService(repo repositories.UserRepository, userRoles repositories.UserRoleRepository, tx repositories.TxManager, c cache.Cache, hasher *password.Hasher, publisher domainevents.EventPublisher) UserService {
+	return &userService{repo: repo, userRoles: userRoles, tx: tx, cache: c, hasher: hasher, publisher: publisher}
 }
 
-// NewUserService creates a new user service
-func NewUserService(repo repositories.UserRepository) UserService {
-	return &userService{repo: repo}
+// ListUsers handles listing users with pagination (read-through cached per page/limit/sort)
+func (s *userService) ListUsers(ctx context.Context, page, limit int, sort []utils.SortTerm) (map[string]interface{}, error) {
+	key := tenant.CacheKey(ctx, fmt.Sprintf(cache.CacheKeyUsersList, page, limit, utils.ListQuery{Sort: sort}.SortKey()))
+	result, _, err := cache.GetOrLoad(ctx, s.cache, key, cache.TTLFor(ctx, "users", cache.TTLList), func(ctx context.Context) (map[string]interface{}, error) {
+		return s.listUsers(ctx, page, limit, sort)
+	})
+	return result, err
 }
 
-// ListUsers handles listing users with pagination
-func (s *userService) ListUsers(page, limit int) (map[string]interface{}, error) {
+// listUsers loads a page of users and pagination metadata from the repository
+func (s *userService) listUsers(ctx context.Context, page, limit int, sort []utils.SortTerm) (map[string]interface{}, error) {
 	offset := (page - 1) * limit
+	ctx = database.WithReplica(ctx) // list pages tolerate replication lag
 
-	users, err := s.repo.GetAll(limit, offset)
+	users, err := s.repo.GetAll(ctx, limit, offset, sort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
 
-	total, err := s.repo.CountActive()
+	count, err := s.countUsers(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count users: %w", err)
 	}
 
+	total := count.Total
 	totalPages := (total + limit - 1) / limit
 
 	return map[string]interface{}{
 		"data": users,
 		"pagination": map[string]interface{}{
-			"page":        page,
+			"page":            page,
+			"limit":           limit,
+			"total":           total,
+			"total_estimated": count.Estimated,
+			"total_pages":     totalPages,
+			"has_next":        page < totalPages,
+			"has_prev":        page > 1,
+		},
+	}, nil
+}
+
+// userCount is the cached total of active users
+type userCount struct {
+	Total     int  `json:"total"`
+	Estimated bool `json:"estimated"`
+}
+
+// countUsers returns the active user total, shared by every page and limit until a user
+// changes (the "users" invalidation rule drops it). LIST_COUNT_MODE may make it an estimate.
+func (s *userService) countUsers(ctx context.Context) (userCount, error) {
+	count, _, err := cache.GetOrLoad(ctx, s.cache, tenant.CacheKey(ctx, cache.CacheKeyUsersCount), cache.TTLFor(ctx, "users", cache.TTLCount), func(ctx context.Context) (userCount, error) {
+		total, estimated, err := database.Total(ctx, s.repo.EstimateCount, s.repo.CountActive)
+		return userCount{Total: total, Estimated: estimated}, err
+	})
+	return count, err
+}
+
+// ListUsersAfter handles keyset pagination: the page after cursor ("" for the first page)
+func (s *userService) ListUsersAfter(ctx context.Context, cursor string, limit int) (map[string]interface{}, error) {
+	after, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	users, err := s.repo.GetAllAfter(database.WithReplica(ctx), after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	hasNext := len(users) > limit
+	nextCursor := ""
+	if hasNext {
+		users = users[:limit]
+		last := users[len(users)-1]
+		nextCursor = utils.NextCursor(last.CreatedAt, last.ID)
+	}
+
+	return map[string]interface{}{
+		"data": users,
+		"pagination": map[string]interface{}{
 			"limit":       limit,
-			"total":       total,
-			"total_pages": totalPages,
-			"has_next":    page < totalPages,
-			"has_prev":    page > 1,
+			"next_cursor": nextCursor,
+			"has_next":    hasNext,
 		},
 	}, nil
 }
 
-// GetUser handles getting a user by ID
-func (s *userService) GetUser(id string) (*models.User, error) {
+// ExportUsers streams every active user to fn from the replica in the given order (nil for
+// the default), bypassing the cache
+func (s *userService) ExportUsers(ctx context.Context, sort []utils.SortTerm, fn func(*models.User) error) error {
+	return s.repo.StreamActive(database.WithReplica(ctx), sort, fn)
+}
+
+// GetUser handles getting a user by ID (read-through cached)
+func (s *userService) GetUser(ctx context.Context, id string) (*models.User, error) {
 	userID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
-	user, err := s.repo.GetByID(userID)
+	key := tenant.CacheKey(ctx, fmt.Sprintf(cache.CacheKeyUser, strconv.FormatUint(userID, 10)))
+	user, _, err := cache.GetOrLoad(ctx, s.cache, key, cache.TTLFor(ctx, "users", cache.TTLDetail), func(ctx context.Context) (*models.User, error) {
+		return s.getUser(ctx, userID)
+	})
+	return user, err
+}
+
+// getUser loads a user from the repository, mapping missing rows to a not-found error
+func (s *userService) getUser(ctx context.Context, userID uint64) (*models.User, error) {
+	user, err := s.repo.GetByID(ctx, userID)
 	if err == sql.ErrNoRows {
 		return nil, utils.NewNotFoundError("user")
 	}
@@ -79,36 +175,69 @@ func (s *userService) GetUser(id string) (*models.User, error) {
 }
 
 // CreateUser handles creating a new user
-func (s *userService) CreateUser(req models.CreateUserRequest) (*models.User, error) {
+func (s *userService) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
 	// Hash password
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashed, err := s.hasher.Hash(ctx, req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("password hash failed: %w", err)
 	}
 
-	userID, err := s.repo.Create(req, string(hashed))
+	// The user and its role assignments are written atomically
+	var userID uint64
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		userID, err = s.repo.Create(ctx, req, hashed)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		for _, roleID := range req.RoleIDs {
+			if err := s.userRoles.Create(ctx, models.UserRole{UserID: userID, RoleID: roleID}); err != nil {
+				return fmt.Errorf("failed to assign role %d: %w", roleID, err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, err
 	}
 
+	events.EntityChanged("users", events.ActionCreated, strconv.FormatUint(userID, 10))
+
 	// Return the created user (without password)
-	user, err := s.repo.GetByID(userID)
+	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve created user: %w", err)
 	}
 
+	subject := strconv.FormatUint(userID, 10)
+	roleIDs := req.RoleIDs
+	if roleIDs == nil {
+		roleIDs = []uint{}
+	}
+	publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeUserCreated, subject, domainevents.UserCreatedData{
+		UserID:   userID,
+		Username: user.Username,
+		Email:    user.Email,
+		Status:   user.Status,
+		RoleIDs:  roleIDs,
+	}))
+	for _, roleID := range req.RoleIDs {
+		publishEvent(ctx, s.publisher, domainevents.NewEvent(domainevents.TypeRoleAssigned, subject,
+			domainevents.RoleAssignedData{UserID: userID, RoleID: roleID}))
+	}
+
 	return user, nil
 }
 
 // UpdateUser handles updating an existing user
-func (s *userService) UpdateUser(id string, req models.UpdateUserRequest) (*models.User, error) {
+func (s *userService) UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest) (*models.User, error) {
 	userID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ID: %w", err)
 	}
 
 	// Check if user exists
-	_, err = s.repo.GetByID(userID)
+	_, err = s.repo.GetByID(ctx, userID)
 	if err == sql.ErrNoRows {
 		return nil, utils.NewNotFoundError("user")
 	}
@@ -119,20 +248,21 @@ func (s *userService) UpdateUser(id string, req models.UpdateUserRequest) (*mode
 	// Hash password if provided
 	var hashedPassword string
 	if req.Password != "" {
-		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		hashedPassword, err = s.hasher.Hash(ctx, req.Password)
 		if err != nil {
 			return nil, fmt.Errorf("password hash failed: %w", err)
 		}
-		hashedPassword = string(hashed)
 	}
 
-	err = s.repo.Update(userID, req, hashedPassword)
+	err = s.repo.Update(ctx, userID, req, hashedPassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	events.EntityChanged("users", events.ActionUpdated, strconv.FormatUint(userID, 10))
+
 	// Return updated user
-	updatedUser, err := s.repo.GetByID(userID)
+	updatedUser, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve updated user: %w", err)
 	}
@@ -140,15 +270,45 @@ func (s *userService) UpdateUser(id string, req models.UpdateUserRequest) (*mode
 	return updatedUser, nil
 }
 
+// ForcePasswordReset flags the selected users in one transaction
+func (s *userService) ForcePasswordReset(ctx context.Context, req models.ForcePasswordResetRequest) ([]models.User, error) {
+	if req.RoleID == nil && req.LastLoginBefore == "" && !req.All {
+		return nil, utils.NewValidationError("Give role_id, last_login_before, or all to select every user")
+	}
+	var lastLoginBefore *time.Time
+	if req.LastLoginBefore != "" {
+		// The start of that day, in the server's time zone
+		day, err := time.ParseInLocation(time.DateOnly, req.LastLoginBefore, time.Local)
+		if err != nil {
+			return nil, utils.NewValidationError("last_login_before must be a date, YYYY-MM-DD")
+		}
+		lastLoginBefore = &day
+	}
+
+	var flagged []models.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		flagged, err = s.repo.FlagPasswordReset(ctx, req.RoleID, lastLoginBefore)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if flagged == nil {
+		flagged = []models.User{}
+	}
+	return flagged, nil
+}
+
 // DeleteUser handles soft deleting a user
-func (s *userService) DeleteUser(id string) error {
+func (s *userService) DeleteUser(ctx context.Context, id string) error {
 	userID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid ID: %w", err)
 	}
 
 	// Check if user exists
-	_, err = s.repo.GetByID(userID)
+	_, err = s.repo.GetByID(ctx, userID)
 	if err == sql.ErrNoRows {
 		return utils.NewNotFoundError("user")
 	}
@@ -156,5 +316,20 @@ func (s *userService) DeleteUser(id string) error {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
 
-	return s.repo.Delete(userID)
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	events.EntityChanged("users", events.ActionDeleted, strconv.FormatUint(userID, 10))
+	return nil
+}
+
+// DeleteUsers handles soft deleting users in bulk
+func (s *userService) DeleteUsers(ctx context.Context, ids []uint64, deletedBy *uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "users", ids, deletedBy, false)
+}
+
+// RestoreUsers handles restoring soft-deleted users in bulk
+func (s *userService) RestoreUsers(ctx context.Context, ids []uint64) (*models.BulkResult, error) {
+	return bulkSoftDelete(ctx, s.tx, s.repo, "users", ids, nil, true)
 }
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/utils"
+
+	"github.com/gin-gonic/gin/render"
+)
+
+// benchPageRows is the list page size of the benchmarks
+const benchPageRows = 50
+
+// stubUserRepository serves a fixed page; methods the list path does not use are left nil
+type stubUserRepository struct {
+	repositories.UserRepository
+	page []models.User
+}
+
+func (r stubUserRepository) GetAll(context.Context, int, int, []utils.SortTerm) ([]models.User, error) {
+	// A fresh slice per call, as the real repository scans into one
+	return append([]models.User(nil), r.page...), nil
+}
+
+func (r stubUserRepository) CountActive(context.Context) (int, error) { return 10000, nil }
+
+func (r stubUserRepository) EstimateCount(context.Context) (int64, error) { return 10000, nil }
+
+// missCache never returns a stored value, so every request takes the load path
+type missCache struct{ *cache.MemoryCache }
+
+func (missCache) Get(string, interface{}) error { return cache.ErrCacheMiss }
+
+// discardWriter is an http.ResponseWriter that drops the body
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkListUsers times GET /api/users above the database: the service building a page
+// and its pagination, plus JSON rendering, on a cache miss and on a cache hit
+func BenchmarkListUsers(b *testing.B) {
+	now := time.Now()
+	page := make([]models.User, benchPageRows)
+	for i := range page {
+		page[i] = models.User{ID: uint64(i + 1), Username: "user", Email: "user@example.com", Status: 1, CreatedAt: &now, UpdatedAt: &now}
+	}
+
+	for _, bm := range []struct {
+		name  string
+		store cache.Cache
+	}{
+		{"miss", missCache{cache.NewMemoryCache(cache.DefaultMemorySize)}},
+		{"hit", cache.NewMemoryCache(cache.DefaultMemorySize)},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			svc := NewUserService(stubUserRepository{page: page}, nil, nil, bm.store, password.NewHasher(config.Default().Password), nil)
+			w := &discardWriter{header: make(http.Header)}
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				resp, err := svc.ListUsers(ctx, 1, benchPageRows, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := (render.JSON{Data: resp}).Render(w); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
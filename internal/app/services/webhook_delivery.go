@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/metrics"
+	"adminbe/pkg/webhook"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "webhook",
+	Name:      "deliveries_total",
+	Help:      "Webhook delivery attempts by result: succeeded, retrying, failed, or dropped when the queue was full.",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(webhookDeliveries)
+}
+
+// maxDeliveryError is the longest last_error kept in the delivery log
+const maxDeliveryError = 1024
+
+// webhookJob is either a new event, to be sent to every webhook subscribed to it, or a
+// delivery to try again
+type webhookJob struct {
+	event    *webhook.Payload
+	delivery *models.WebhookDelivery
+}
+
+// Publish queues event for delivery
+func (s *webhookService) Publish(event, entityID string) bool {
+	if s.closed.Load() {
+		return false
+	}
+	entity, _, _ := strings.Cut(event, ".")
+	payload := &webhook.Payload{
+		ID:         randomHex(16),
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+		Data:       webhook.Data{Entity: entity, ID: entityID},
+	}
+	select {
+	case s.queue <- webhookJob{event: payload}:
+		return true
+	default:
+		webhookDeliveries.WithLabelValues("dropped").Inc()
+		return false
+	}
+}
+
+// Close stops the workers
+func (s *webhookService) Close() {
+	if s.closed.CompareAndSwap(false, true) {
+		close(s.stop)
+		s.wg.Wait()
+	}
+}
+
+func (s *webhookService) worker() {
+	defer s.wg.Done()
+	for {
+		select {
+		case job := <-s.queue:
+			if job.event != nil {
+				s.fanOut(job.event)
+			} else {
+				s.retry(job.delivery)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// fanOut records and attempts a delivery of payload to every active webhook subscribed to it
+func (s *webhookService) fanOut(payload *webhook.Payload) {
+	ctx := context.Background()
+	webhooks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		slog.Error("Failed to load webhooks, dropping event", "event", payload.Event, "event_id", payload.ID, "error", err)
+		webhookDeliveries.WithLabelValues("dropped").Inc()
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "event", payload.Event, "error", err)
+		return
+	}
+
+	for i := range webhooks {
+		w := &webhooks[i]
+		if !w.Active || !webhookMatches(w.Events, payload.Event) {
+			continue
+		}
+		now := time.Now()
+		d := &models.WebhookDelivery{
+			WebhookID: w.ID,
+			EventID:   payload.ID,
+			Event:     payload.Event,
+			Payload:   body,
+			Status:    models.DeliveryPending,
+			CreatedAt: &now,
+			UpdatedAt: &now,
+		}
+		if d.ID, err = s.repo.CreateDelivery(ctx, *d); err != nil {
+			// Still deliver; the attempt just goes unlogged
+			slog.Error("Failed to record webhook delivery", "webhook_id", w.ID, "event_id", payload.ID, "error", err)
+		}
+		s.attempt(ctx, w, d)
+	}
+}
+
+// retry attempts a delivery again, unless its webhook has since been deleted or deactivated
+func (s *webhookService) retry(d *models.WebhookDelivery) {
+	ctx := context.Background()
+	w, err := s.repo.GetByID(ctx, d.WebhookID)
+	if err == sql.ErrNoRows || (err == nil && !w.Active) {
+		s.finish(ctx, d, models.DeliveryFailed, "webhook deleted or deactivated before the retry")
+		return
+	}
+	if err != nil {
+		// Count it as a failed attempt rather than lose the delivery
+		slog.Error("Failed to load webhook for retry", "webhook_id", d.WebhookID, "error", err)
+		d.Attempts++
+		s.schedule(ctx, d, "loading the webhook failed: "+err.Error())
+		return
+	}
+	s.attempt(ctx, w, d)
+}
+
+// attempt sends d to w once and records the outcome
+func (s *webhookService) attempt(ctx context.Context, w *models.Webhook, d *models.WebhookDelivery) {
+	d.Attempts++
+	d.ResponseStatus = nil
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		s.finish(ctx, d, models.DeliveryFailed, err.Error())
+		return
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "adminbe-webhooks")
+	req.Header.Set(webhook.HeaderID, d.EventID)
+	req.Header.Set(webhook.HeaderEvent, d.Event)
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(w.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.schedule(ctx, d, err.Error())
+		return
+	}
+	// Drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	status := resp.StatusCode
+	d.ResponseStatus = &status
+	if status >= 200 && status < 300 {
+		s.finish(ctx, d, models.DeliverySucceeded, "")
+		return
+	}
+	s.schedule(ctx, d, fmt.Sprintf("endpoint answered %d", status))
+}
+
+// schedule retries d after the backoff for its attempt count, or marks it failed once it
+// has had every attempt
+func (s *webhookService) schedule(ctx context.Context, d *models.WebhookDelivery, reason string) {
+	if d.Attempts >= s.cfg.MaxAttempts {
+		s.finish(ctx, d, models.DeliveryFailed, reason)
+		return
+	}
+
+	delay := s.cfg.RetryBackoff << (d.Attempts - 1)
+	next := time.Now().Add(delay)
+	d.NextAttemptAt = &next
+	s.record(ctx, d, models.DeliveryPending, reason)
+	webhookDeliveries.WithLabelValues("retrying").Inc()
+
+	retry := *d
+	time.AfterFunc(delay, func() {
+		select {
+		case s.queue <- webhookJob{delivery: &retry}:
+		case <-s.stop:
+		}
+	})
+}
+
+// finish records the final outcome of d
+func (s *webhookService) finish(ctx context.Context, d *models.WebhookDelivery, status, reason string) {
+	d.NextAttemptAt = nil
+	s.record(ctx, d, status, reason)
+	webhookDeliveries.WithLabelValues(status).Inc()
+	if status == models.DeliveryFailed {
+		slog.Warn("Webhook delivery failed", "webhook_id", d.WebhookID, "event", d.Event,
+			"event_id", d.EventID, "attempts", d.Attempts, "error", reason)
+	}
+}
+
+// record writes d's status and latest error to the delivery log
+func (s *webhookService) record(ctx context.Context, d *models.WebhookDelivery, status, reason string) {
+	d.Status = status
+	d.LastError = nil
+	if reason != "" {
+		if len(reason) > maxDeliveryError {
+			reason = reason[:maxDeliveryError]
+		}
+		d.LastError = &reason
+	}
+	if d.ID == 0 {
+		return
+	}
+	// The attempt's own deadline may have passed; the log write gets a fresh one
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.repo.UpdateDelivery(ctx, *d); err != nil {
+		slog.Error("Failed to record webhook delivery outcome", "delivery_id", d.ID, "error", err)
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/app/models"
+	"adminbe/internal/app/repositories"
+	"adminbe/internal/pkg/utils"
+)
+
+// WebhookEntities are the entities whose create, update and delete events webhooks can
+// subscribe to, as "<entity>.created", "<entity>.updated" and "<entity>.deleted". Security
+// events are created when raised and updated when acknowledged.
+var WebhookEntities = []string{"user", "role", "menu", "security_event"}
+
+// WebhookService interface defines business logic for outbound webhooks: managing the
+// subscriptions, and delivering events to them in the background
+type WebhookService interface {
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	CreateWebhook(ctx context.Context, req models.CreateWebhookRequest, createdBy *uint64) (*models.CreatedWebhook, error)
+	UpdateWebhook(ctx context.Context, id string, req models.UpdateWebhookRequest) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string, deletedBy *uint64) error
+	ListDeliveries(ctx context.Context, id string, status string, limit int) ([]models.WebhookDelivery, error)
+	// Publish queues event (e.g. "user.created") about the entity with the given ID for
+	// every active webhook subscribed to it. It never blocks and reports false when the
+	// queue is full.
+	Publish(event, entityID string) bool
+	// Close stops delivering; retries still waiting are abandoned and stay pending in the log
+	Close()
+}
+
+// WebhookConfig tunes webhook delivery
+type WebhookConfig struct {
+	// Workers is the number of deliveries made at once
+	Workers int
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked failed
+	MaxAttempts int
+	// RetryBackoff is the wait before the second attempt; it doubles after each failure
+	RetryBackoff time.Duration
+}
+
+// webhookQueueSize bounds the events and retries waiting for a worker
+const webhookQueueSize = 1000
+
+// webhookService implements WebhookService
+type webhookService struct {
+	repo   repositories.WebhookRepository
+	cfg    WebhookConfig
+	client *http.Client
+	queue  chan webhookJob
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	closed atomic.Bool
+}
+
+// NewWebhookService creates a webhook service and starts its delivery workers
+func NewWebhookService(repo repositories.WebhookRepository, cfg WebhookConfig) WebhookService {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	cfg.MaxAttempts = max(cfg.MaxAttempts, 1)
+	s := &webhookService{
+		repo: repo,
+		cfg:  cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// A redirect is an answer like any other non-2xx; the URL is what was registered
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		queue: make(chan webhookJob, webhookQueueSize),
+		stop:  make(chan struct{}),
+	}
+	for i := 0; i < max(cfg.Workers, 1); i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// ListWebhooks handles listing all webhooks
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	webhooks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook handles getting a webhook by ID
+func (s *webhookService) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	webhookID, err := parseWebhookID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repo.GetByID(ctx, webhookID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFoundError("Webhook")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// CreateWebhook handles creating a webhook, generating its secret when req has none
+func (s *webhookService) CreateWebhook(ctx context.Context, req models.CreateWebhookRequest, createdBy *uint64) (*models.CreatedWebhook, error) {
+	if err := validateWebhook(req.URL, req.Events); err != nil {
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = "whsec_" + randomHex(24)
+	}
+
+	now := time.Now()
+	webhook := models.Webhook{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      req.Events,
+		Active:      req.Active == nil || *req.Active,
+		Description: req.Description,
+		CreatedBy:   createdBy,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+	id, err := s.repo.Create(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	created, err := s.GetWebhook(ctx, strconv.FormatUint(id, 10))
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreatedWebhook{Webhook: *created, Secret: secret}, nil
+}
+
+// UpdateWebhook handles updating a webhook; members left out of req keep their values
+func (s *webhookService) UpdateWebhook(ctx context.Context, id string, req models.UpdateWebhookRequest) (*models.Webhook, error) {
+	existing, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+	url, events := existing.URL, existing.Events
+	if req.URL != nil {
+		url = *req.URL
+		changes["url"] = url
+	}
+	if req.Events != nil {
+		events = req.Events
+		changes["events"] = events
+	}
+	if err := validateWebhook(url, events); err != nil {
+		return nil, err
+	}
+	if req.Secret != nil {
+		changes["secret"] = *req.Secret
+	}
+	if req.Active != nil {
+		changes["active"] = *req.Active
+	}
+	if req.Description != nil {
+		changes["description"] = req.Description
+	}
+	if len(changes) == 0 {
+		return existing, nil
+	}
+
+	if err := s.repo.Update(ctx, existing.ID, changes); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return s.GetWebhook(ctx, id)
+}
+
+// DeleteWebhook handles deleting a webhook; its delivery log is kept
+func (s *webhookService) DeleteWebhook(ctx context.Context, id string, deletedBy *uint64) error {
+	existing, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, existing.ID, deletedBy); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries handles listing the latest deliveries to a webhook, newest first
+func (s *webhookService) ListDeliveries(ctx context.Context, id string, status string, limit int) ([]models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		return nil, utils.NewValidationError("status must be pending, succeeded or failed")
+	}
+
+	deliveries, err := s.repo.GetDeliveries(ctx, webhook.ID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// parseWebhookID reads a webhook ID path parameter
+func parseWebhookID(id string) (uint64, error) {
+	webhookID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || webhookID == 0 {
+		return 0, utils.NewValidationError("Invalid ID")
+	}
+	return webhookID, nil
+}
+
+// validateWebhook checks that url is an absolute http(s) URL and that every event filter
+// is "*", "<entity>.*" or an event name
+func validateWebhook(url string, events []string) error {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return utils.NewValidationError("url must be an http or https URL")
+	}
+	for _, filter := range events {
+		if filter == "*" {
+			continue
+		}
+		entity, action, ok := strings.Cut(filter, ".")
+		if !ok || !slices.Contains(WebhookEntities, entity) ||
+			(action != "*" && action != "created" && action != "updated" && action != "deleted") {
+			return utils.NewValidationError(fmt.Sprintf("unknown event %q; use \"*\", \"<entity>.*\" or \"<entity>.created|updated|deleted\" with entity one of %s",
+				filter, strings.Join(WebhookEntities, ", ")))
+		}
+	}
+	return nil
+}
+
+// webhookMatches reports whether a webhook subscribed to filters receives event
+func webhookMatches(filters []string, event string) bool {
+	entity, _, _ := strings.Cut(event, ".")
+	for _, filter := range filters {
+		if filter == "*" || filter == event || filter == entity+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// randomHex returns n random bytes, hex-encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
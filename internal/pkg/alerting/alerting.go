@@ -0,0 +1,240 @@
+// Package alerting posts operational alerts to Slack and Microsoft Teams incoming webhooks.
+// Conditions that hold for a while, such as a stalled audit queue or an unreachable
+// JasperServer, are reported with Fire and Resolve, which only send when the state changes;
+// one-off events, such as a failed job run, with Event, which sends at most one notice per
+// key per ALERT_THROTTLE. Alerter also implements Notifier for callers that track state
+// themselves, like the SLO evaluator. State is per process, so each replica alerts on what
+// it sees.
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Alert states
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Severities
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+var alertsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "alerts",
+	Name:      "sent_total",
+	Help:      "Operational alerts posted by source (slo, audit, jasper, jobs), sink (slack, teams) and result (ok, error).",
+}, []string{"source", "sink", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(alertsSent)
+}
+
+// Alert is one notice. Key identifies the condition or event within its source, e.g. a job
+// name; Fields are shown as a table under Text.
+type Alert struct {
+	Source   string
+	Key      string
+	Status   string
+	Severity string
+	Title    string
+	Text     string
+	Fields   map[string]string
+	Time     time.Time
+}
+
+// Notifier delivers alerts
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Config is the alerting section of the configuration
+type Config struct {
+	// SlackWebhookURL and TeamsWebhookURL are incoming webhooks; alerts go to every one set,
+	// and none leaves them to the logs
+	SlackWebhookURL string `yaml:"slack_webhook_url" env:"ALERT_SLACK_WEBHOOK_URL"`
+	TeamsWebhookURL string `yaml:"teams_webhook_url" env:"ALERT_TEAMS_WEBHOOK_URL"`
+	// Timeout bounds one delivery
+	Timeout time.Duration `yaml:"timeout" env:"ALERT_TIMEOUT" default:"5s"`
+	// Throttle is the least time between two notices of one event, e.g. a job failing every
+	// minute
+	Throttle time.Duration `yaml:"throttle" env:"ALERT_THROTTLE" default:"15m"`
+	// CheckInterval is how often the audit queue and JasperServer are checked; 0 turns the
+	// checks off
+	CheckInterval time.Duration `yaml:"check_interval" env:"ALERT_CHECK_INTERVAL" default:"1m"`
+	// CheckJasper includes JasperServer in the checks; turn it off where reports are unused
+	CheckJasper bool `yaml:"check_jasper" env:"ALERT_CHECK_JASPER" default:"true"`
+}
+
+// Validate checks the webhook URLs and durations
+func (c *Config) Validate() error {
+	var errs []error
+	for _, u := range []struct{ name, url string }{
+		{"ALERT_SLACK_WEBHOOK_URL", c.SlackWebhookURL},
+		{"ALERT_TEAMS_WEBHOOK_URL", c.TeamsWebhookURL},
+	} {
+		if u.url != "" && !strings.HasPrefix(u.url, "https://") && !strings.HasPrefix(u.url, "http://") {
+			errs = append(errs, fmt.Errorf("%s must be an http or https URL", u.name))
+		}
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("ALERT_TIMEOUT must be positive"))
+	}
+	if c.Throttle < 0 || c.CheckInterval < 0 {
+		errs = append(errs, errors.New("ALERT_THROTTLE and ALERT_CHECK_INTERVAL must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// sink is one configured webhook
+type sink struct {
+	name string
+	Notifier
+}
+
+// Alerter sends alerts to the configured webhooks and keeps the state behind Fire, Resolve
+// and Event
+type Alerter struct {
+	instance string
+
+	mu    sync.Mutex
+	cfg   Config
+	sinks []sink
+	// firing holds the severity and title last sent for each firing condition
+	firing map[string]string
+	// sent is when each event key was last sent
+	sent map[string]time.Time
+}
+
+// Default is the process-wide alerter, sending nothing until Configure
+var Default = New()
+
+// New creates an alerter without webhooks
+func New() *Alerter {
+	instance, _ := os.Hostname()
+	return &Alerter{instance: instance, firing: make(map[string]string), sent: make(map[string]time.Time)}
+}
+
+// Configure replaces the webhooks, e.g. on startup or a configuration reload; conditions
+// already firing stay firing
+func (a *Alerter) Configure(cfg Config) {
+	var sinks []sink
+	if cfg.SlackWebhookURL != "" {
+		sinks = append(sinks, sink{"slack", newSlack(cfg.SlackWebhookURL, cfg.Timeout)})
+	}
+	if cfg.TeamsWebhookURL != "" {
+		sinks = append(sinks, sink{"teams", newTeams(cfg.TeamsWebhookURL, cfg.Timeout)})
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg, a.sinks = cfg, sinks
+}
+
+// Enabled reports whether any webhook is configured
+func (a *Alerter) Enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.sinks) > 0
+}
+
+// Notify posts al to every webhook, filling in the time and this instance
+func (a *Alerter) Notify(ctx context.Context, al Alert) error {
+	a.mu.Lock()
+	sinks := a.sinks
+	a.mu.Unlock()
+	if al.Time.IsZero() {
+		al.Time = time.Now()
+	}
+	if a.instance != "" {
+		fields := map[string]string{"instance": a.instance}
+		for k, v := range al.Fields {
+			fields[k] = v
+		}
+		al.Fields = fields
+	}
+
+	var errs []error
+	for _, s := range sinks {
+		if err := s.Notify(ctx, al); err != nil {
+			alertsSent.WithLabelValues(al.Source, s.name, "error").Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		alertsSent.WithLabelValues(al.Source, s.name, "ok").Inc()
+	}
+	return errors.Join(errs...)
+}
+
+// Fire reports that the condition of al holds. Only the first report is sent, and any
+// later one that changes the severity or title.
+func (a *Alerter) Fire(ctx context.Context, al Alert) {
+	key := al.Source + "/" + al.Key
+	state := al.Severity + "/" + al.Title
+	a.mu.Lock()
+	if a.firing[key] == state {
+		a.mu.Unlock()
+		return
+	}
+	a.firing[key] = state
+	a.mu.Unlock()
+
+	al.Status = StatusFiring
+	slog.Warn("Alert firing", "source", al.Source, "key", al.Key, "severity", al.Severity, "title", al.Title, "text", al.Text)
+	a.send(ctx, al)
+}
+
+// Resolve reports that the condition of al no longer holds; nothing is sent unless it fired
+func (a *Alerter) Resolve(ctx context.Context, al Alert) {
+	key := al.Source + "/" + al.Key
+	a.mu.Lock()
+	if _, ok := a.firing[key]; !ok {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.firing, key)
+	a.mu.Unlock()
+
+	al.Status = StatusResolved
+	slog.Info("Alert resolved", "source", al.Source, "key", al.Key, "title", al.Title)
+	a.send(ctx, al)
+}
+
+// Event reports something that happened, sending it unless the same key was sent within
+// ALERT_THROTTLE
+func (a *Alerter) Event(ctx context.Context, al Alert) {
+	key := al.Source + "/" + al.Key
+	now := time.Now()
+	a.mu.Lock()
+	if last, ok := a.sent[key]; ok && now.Sub(last) < a.cfg.Throttle {
+		a.mu.Unlock()
+		return
+	}
+	a.sent[key] = now
+	a.mu.Unlock()
+
+	al.Status = StatusFiring
+	a.send(ctx, al)
+}
+
+// send notifies, logging rather than returning failures; alerting must never fail the
+// caller
+func (a *Alerter) send(ctx context.Context, al Alert) {
+	if err := a.Notify(ctx, al); err != nil {
+		slog.Error("Failed to send alert", "source", al.Source, "key", al.Key, "error", err)
+	}
+}
@@ -0,0 +1,137 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// colors of the message bar by state, Slack's "#rrggbb" without the hash for Teams
+var colors = map[string]string{
+	SeverityCritical: "d00000",
+	SeverityWarning:  "f2a900",
+	StatusResolved:   "2eb67d",
+}
+
+func color(a Alert) string {
+	if a.Status == StatusResolved {
+		return colors[StatusResolved]
+	}
+	if c, ok := colors[a.Severity]; ok {
+		return c
+	}
+	return colors[SeverityWarning]
+}
+
+// heading is the first line of a notice, e.g. "[FIRING] Audit queue dropping entries"
+func heading(a Alert) string {
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(a.Status), a.Title)
+}
+
+// sortedFields lists a's fields by name so messages read the same every time
+func sortedFields(a Alert) []string {
+	names := make([]string, 0, len(a.Fields))
+	for name := range a.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// webhook POSTs a JSON body built by encode to an incoming webhook URL
+type webhook struct {
+	url    string
+	client *http.Client
+	encode func(Alert) any
+}
+
+func (w *webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(w.encode(a))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The URL is the webhook's secret, so it is kept out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// newSlack posts Slack incoming webhook messages: the heading as text, for notifications,
+// and an attachment colored by severity with the fields
+func newSlack(u string, timeout time.Duration) Notifier {
+	return &webhook{url: u, client: &http.Client{Timeout: timeout}, encode: func(a Alert) any {
+		type field struct {
+			Title string `json:"title"`
+			Value string `json:"value"`
+			Short bool   `json:"short"`
+		}
+		fields := []field{{Title: "source", Value: a.Source, Short: true}}
+		if a.Severity != "" {
+			fields = append(fields, field{Title: "severity", Value: a.Severity, Short: true})
+		}
+		for _, name := range sortedFields(a) {
+			fields = append(fields, field{Title: name, Value: a.Fields[name], Short: len(a.Fields[name]) < 40})
+		}
+		return map[string]any{
+			"text": heading(a),
+			"attachments": []map[string]any{{
+				"color":  "#" + color(a),
+				"text":   a.Text,
+				"fields": fields,
+				"ts":     a.Time.Unix(),
+			}},
+		}
+	}}
+}
+
+// newTeams posts Microsoft Teams incoming webhook messages as a MessageCard with the fields
+// as facts
+func newTeams(u string, timeout time.Duration) Notifier {
+	return &webhook{url: u, client: &http.Client{Timeout: timeout}, encode: func(a Alert) any {
+		type fact struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		facts := []fact{{"source", a.Source}}
+		if a.Severity != "" {
+			facts = append(facts, fact{"severity", a.Severity})
+		}
+		for _, name := range sortedFields(a) {
+			facts = append(facts, fact{name, a.Fields[name]})
+		}
+		facts = append(facts, fact{"time", a.Time.Format(time.RFC3339)})
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    heading(a),
+			"themeColor": color(a),
+			"title":      heading(a),
+			"text":       a.Text,
+			"sections":   []map[string]any{{"facts": facts}},
+		}
+	}}
+}
@@ -0,0 +1,227 @@
+// Package broadcast pushes live messages to the subscribers connected to this instance (the
+// /api/events/stream clients) and, with Redis attached, to those of every other instance.
+// Delivery is best effort: a subscriber that falls behind is dropped rather than slowing
+// the publisher down, and nothing is kept for subscribers that connect later.
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Message types
+const (
+	TypeAudit      = "audit"      // an audit log entry
+	TypeInvalidate = "invalidate" // an entity changed, so cached copies of it are stale
+	TypeSecurity   = "security"   // a security event was raised
+)
+
+// DefaultChannel is the Redis pub/sub channel messages cross instances on
+const DefaultChannel = "cms:broadcast"
+
+var (
+	subscriberGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "broadcast",
+		Name:      "subscribers",
+		Help:      "Live stream subscribers connected to this instance.",
+	})
+	droppedSubscribers = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "broadcast",
+		Name:      "dropped_subscribers_total",
+		Help:      "Live stream subscribers disconnected because they fell behind.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(subscriberGauge, droppedSubscribers)
+}
+
+// Message is one pushed item
+type Message struct {
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	Origin string          `json:"origin"` // Instance that published the message
+	Time   time.Time       `json:"time"`
+}
+
+// Subscription receives the messages published after it was made, on C. C is closed when
+// the subscription is closed or dropped for falling behind.
+type Subscription struct {
+	C <-chan Message
+
+	ch      chan Message
+	hub     *Hub
+	lagging bool // guarded by hub.mu
+}
+
+// Hub fans messages out to its subscribers
+type Hub struct {
+	mu         sync.Mutex
+	subs       map[*Subscription]struct{}
+	instanceID string
+
+	redis   redis.UniversalClient
+	channel string
+	cancel  context.CancelFunc
+}
+
+// NewHub creates a hub that stamps its messages with instanceID
+func NewHub(instanceID string) *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{}), instanceID: instanceID}
+}
+
+// Default is the process-wide hub; it shares the event bus's instance ID
+var Default = NewHub(events.Default.InstanceID())
+
+// Subscribe starts a subscription holding up to buffer undelivered messages
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	ch := make(chan Message, max(buffer, 1))
+	s := &Subscription{C: ch, ch: ch, hub: h}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	subscriberGauge.Inc()
+	return s
+}
+
+// Close ends the subscription; it is safe to call more than once
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Lagging reports whether the subscription was dropped for falling behind
+func (s *Subscription) Lagging() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagging
+}
+
+// remove closes s if it is still subscribed; h.mu must be held
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.ch)
+	subscriberGauge.Dec()
+}
+
+// Publish sends a message of type typ carrying v to the subscribers of every instance
+func (h *Hub) Publish(typ string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode broadcast message", "type", typ, "error", err)
+		return
+	}
+	msg := Message{Type: typ, Data: data, Origin: h.instanceID, Time: time.Now()}
+	h.Deliver(msg)
+
+	h.mu.Lock()
+	client, channel := h.redis, h.channel
+	h.mu.Unlock()
+	if client == nil {
+		return
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("Failed to encode broadcast message", "type", typ, "error", err)
+		return
+	}
+	if err := client.Publish(context.Background(), channel, payload).Err(); err != nil {
+		slog.Warn("Failed to broadcast message", "type", typ, "error", err)
+	}
+}
+
+// Deliver sends msg to this instance's subscribers only, for messages that already reach
+// every instance by other means (entity events travel on their own bus). A subscriber
+// whose buffer is full is dropped.
+func (h *Hub) Deliver(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		select {
+		case s.ch <- msg:
+		default:
+			s.lagging = true
+			h.remove(s)
+			droppedSubscribers.Inc()
+		}
+	}
+}
+
+// FollowEntityEvents delivers every entity change on bus, local or from another instance,
+// as a TypeInvalidate message
+func (h *Hub) FollowEntityEvents(bus *events.Bus) {
+	bus.Subscribe(events.WildcardEntity, func(e events.Event) {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		h.Deliver(Message{Type: TypeInvalidate, Data: data, Origin: e.Origin, Time: e.Time})
+	})
+}
+
+// AttachRedis broadcasts published messages over Redis pub/sub and delivers those of other
+// instances to local subscribers
+func (h *Hub) AttachRedis(client redis.UniversalClient, channel string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mu.Lock()
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.redis = client
+	h.channel = channel
+	h.cancel = cancel
+	h.mu.Unlock()
+
+	pubsub := client.Subscribe(ctx, channel)
+	go func() {
+		defer pubsub.Close()
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Broadcast subscription error", "error", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			var m Message
+			if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+				slog.Warn("Failed to decode broadcast message", "error", err)
+				continue
+			}
+			if m.Origin == h.instanceID {
+				continue
+			}
+			h.Deliver(m)
+		}
+	}()
+}
+
+// Close stops the Redis subscription, if any
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+	}
+	h.redis = nil
+}
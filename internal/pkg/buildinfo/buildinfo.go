@@ -0,0 +1,48 @@
+// Package buildinfo identifies the running build. Version, Commit and BuildTime are set at
+// link time:
+//
+//	go build -ldflags "-X adminbe/internal/pkg/buildinfo.Version=v1.4.0 \
+//	  -X adminbe/internal/pkg/buildinfo.Commit=$(git rev-parse --short HEAD)" ./cmd/server
+//
+// Without them, the VCS revision and time the Go toolchain embeds are used.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Set with -ldflags "-X ..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// startedAt is when the process started
+var startedAt = time.Now()
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value[:min(len(s.Value), 12)]
+		case s.Key == "vcs.time" && BuildTime == "":
+			BuildTime = s.Value
+		}
+	}
+}
+
+// StartedAt returns when the process started
+func StartedAt() time.Time {
+	return startedAt
+}
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(startedAt)
+}
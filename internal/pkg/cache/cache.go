@@ -1,123 +1,73 @@
 package cache
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
-// Cache provides Redis-based caching functionality
-type Cache struct {
-	client *redis.Client
-	ctx    context.Context
-}
-
-// NewCache creates a new cache instance
-func NewCache(client *redis.Client) *Cache {
-	return &Cache{
-		client: client,
-		ctx:    context.Background(),
-	}
-}
-
-// Set serializes and stores data in Redis with expiration
-func (c *Cache) Set(key string, value interface{}, expiration time.Duration) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("failed to marshal cache data: %w", err)
-	}
-
-	return c.client.Set(c.ctx, key, data, expiration).Err()
-}
-
-// Get retrieves and deserializes data from Redis
-func (c *Cache) Get(key string, dest interface{}) error {
-	data, err := c.client.Get(c.ctx, key).Result()
-	if err == redis.Nil {
-		return fmt.Errorf("cache miss for key: %s", key)
-	}
-	if err != nil {
-		return fmt.Errorf("failed to get from cache: %w", err)
-	}
-
-	err = json.Unmarshal([]byte(data), dest)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal cache data: %w", err)
-	}
-
-	return nil
-}
-
-// Delete removes a key from Redis
-func (c *Cache) Delete(key string) error {
-	return c.client.Del(c.ctx, key).Err()
+// ErrCacheMiss is returned by Get when the key does not exist
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache defines the caching operations used by handlers and services.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Set(key string, value interface{}, expiration time.Duration) error
+	Get(key string, dest interface{}) error
+	Delete(key string) error
+	DeletePattern(pattern string) error
+	Exists(key string) bool
+	Increment(key string) (int64, error)
+	SetNX(key string, value interface{}, expiration time.Duration) (bool, error)
+	GetTTL(key string) (time.Duration, error)
+	Keys(pattern string, limit int) ([]string, error)
 }
 
-// DeletePattern removes all keys matching a pattern
-func (c *Cache) DeletePattern(pattern string) error {
-	keys, err := c.client.Keys(c.ctx, pattern).Result()
-	if err != nil {
-		return fmt.Errorf("failed to find keys for pattern %s: %w", pattern, err)
-	}
-
-	if len(keys) > 0 {
-		return c.client.Del(c.ctx, keys...).Err()
-	}
-
-	return nil
-}
-
-// Exists checks if a key exists in Redis
-func (c *Cache) Exists(key string) bool {
-	count, err := c.client.Exists(c.ctx, key).Result()
-	if err != nil {
-		log.Printf("Error checking cache existence: %v", err)
-		return false
-	}
-	return count > 0
-}
-
-// Increment increments a numeric value in Redis
-func (c *Cache) Increment(key string) (int64, error) {
-	return c.client.Incr(c.ctx, key).Result()
+// IsCacheMiss reports whether err is a cache miss
+func IsCacheMiss(err error) bool {
+	return errors.Is(err, ErrCacheMiss)
 }
 
-// SetNX sets a key only if it doesn't exist (useful for locking)
-func (c *Cache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal cache data: %w", err)
-	}
-
-	return c.client.SetNX(c.ctx, key, data, expiration).Result()
-}
-
-// GetTTL returns the remaining time to live of a key
-func (c *Cache) GetTTL(key string) (time.Duration, error) {
-	return c.client.TTL(c.ctx, key).Result()
-}
+// KeyVersion is embedded in every key. Bump it whenever a cached struct's
+// serialized shape changes so a new deploy never decodes payloads written by the old one.
+const KeyVersion = "v1"
 
 // Common cache key patterns
 const (
-	CacheKeyPrefix         = "cms:"
+	CacheKeyRoot           = "cms:"
+	CacheKeyPrefix         = CacheKeyRoot + KeyVersion + ":"
 	CacheKeyMenuList       = CacheKeyPrefix + "menus:list"
 	CacheKeyRolesList      = CacheKeyPrefix + "roles:list"
-	CacheKeyUsersList      = CacheKeyPrefix + "users:list:%d:%d" // page:limit
+	CacheKeyUsersList      = CacheKeyPrefix + "users:list:%d:%d:%s" // page:limit:sort
 	CacheKeyUsersCount     = CacheKeyPrefix + "users:count"
 	CacheKeyMenuNavigation = CacheKeyPrefix + "menu:navigation"
 	CacheKeyUser           = CacheKeyPrefix + "user:%s" // user_id
 	CacheKeyRole           = CacheKeyPrefix + "role:%s" // role_id
 	CacheKeyMenu           = CacheKeyPrefix + "menu:%s" // menu_id
+	CacheKeyProvinces      = CacheKeyPrefix + "prayer:provinces"
+	CacheKeyCities         = CacheKeyPrefix + "prayer:cities:%s" // province hash
+	// Provinces and cities by ID, as /api/v2 lists them
+	CacheKeyProvinceLocations = CacheKeyPrefix + "prayer:locations:provinces"
+	CacheKeyCityLocations     = CacheKeyPrefix + "prayer:locations:cities:%d" // province ID
+	CacheKeyHTTPResponse      = CacheKeyPrefix + "http:%s:%s"                 // namespace:request hash
+	CacheKeyIdempotency       = CacheKeyPrefix + "idempotency:%s:%s"          // scope:caller and key hash
+	CacheKeyGeocode           = CacheKeyPrefix + "geocode:%s"                 // provider and query hash
+	CacheKeyTenant            = CacheKeyPrefix + "tenant:%s"                  // slug
+	CacheKeyTenantSettings    = CacheKeyPrefix + "tenant:settings:%d"         // tenant ID
+	CacheKeyCalendarState     = CacheKeyPrefix + "calendar:oauth_state:%s"    // OAuth state
 )
 
-// Default expirations
+// HotKeyPrefixes lists read-heavy keys served from the in-process tier of TieredCache
+var HotKeyPrefixes = []string{
+	CacheKeyMenuNavigation,
+	CacheKeyMenuList,
+	CacheKeyProvinces,
+	CacheKeyPrefix + "prayer:cities:",
+	CacheKeyPrefix + "prayer:locations:",
+}
+
+// In-process tier defaults; entity expirations are configured through TTL
 const (
-	DefaultListExpiration       = 10 * time.Minute // For list endpoints
-	DefaultDetailExpiration     = 5 * time.Minute  // For individual items
-	DefaultCountExpiration      = 15 * time.Minute // For counts
-	DefaultNavigationExpiration = 30 * time.Minute // For navigation (less frequent changes)
+	DefaultLocalExpiration = 30 * time.Second // For the in-process tier in front of Redis
+	DefaultLocalSize       = 256              // Max entries in the in-process tier
+	DefaultMemorySize      = 100000           // Max entries in MemoryCache
 )
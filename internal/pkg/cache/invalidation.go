@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/tenant"
+)
+
+// invalidationRules maps an entity to the cache keys affected by its changes.
+// "%s" is replaced by the event record ID; keys containing "*" are deleted by pattern.
+var invalidationRules = map[string][]string{
+	"menu":  {CacheKeyMenuList, CacheKeyMenuNavigation, CacheKeyMenu, CacheKeyPrefix + "http:menu:*"},
+	"roles": {CacheKeyRolesList, CacheKeyRole},
+	"users": {CacheKeyPrefix + "users:*", CacheKeyUser},
+	// Provinces and cities, changed in bulk by reference data imports
+	"locations": {CacheKeyPrefix + "prayer:*"},
+	// Tenants resolved from their slugs, by TENANCY_ENABLED requests
+	"tenants": {CacheKeyPrefix + "tenant:*"},
+}
+
+// RegisterInvalidation subscribes c to entity-changed events on bus so related
+// keys are dropped whenever an entity changes, on this and every other instance.
+func RegisterInvalidation(bus *events.Bus, c Cache) {
+	for entity := range invalidationRules {
+		bus.Subscribe(entity, func(e events.Event) {
+			InvalidateEntity(c, e.Entity, e.ID)
+		})
+	}
+}
+
+// InvalidateEntity deletes every cache key registered for entity and record id. Events do
+// not say which tenant changed, so with tenancy enabled exact keys go for every tenant.
+func InvalidateEntity(c Cache, entity, id string) {
+	for _, key := range invalidationRules[entity] {
+		if strings.Contains(key, "%s") {
+			if id == "" {
+				continue
+			}
+			key = fmt.Sprintf(key, id)
+		}
+
+		var err error
+		if strings.Contains(key, "*") {
+			err = c.DeletePattern(key)
+		} else {
+			err = c.Delete(key)
+			if err == nil && tenant.Enabled() {
+				err = c.DeletePattern(tenant.KeyPattern(key))
+			}
+		}
+		if err != nil {
+			slog.Warn("Failed to invalidate cache key", "key", key, "error", err)
+		}
+	}
+}
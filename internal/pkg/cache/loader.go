@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Stampede protection settings
+const (
+	lockKeySuffix    = ":lock"
+	lockExpiration   = 5 * time.Second       // Upper bound on how long a rebuild may hold the lock
+	lockWaitTimeout  = 2 * time.Second       // How long other instances wait for the rebuild
+	lockPollInterval = 50 * time.Millisecond // How often waiting instances re-check the cache
+	loadTimeout      = 30 * time.Second      // Upper bound on a shared rebuild, which no one caller can cancel
+)
+
+// loadGroup collapses concurrent in-process rebuilds of the same key
+var loadGroup singleflight.Group
+
+// loadResult carries the rebuilt value through singleflight
+type loadResult struct {
+	data   json.RawMessage
+	cached bool
+}
+
+// GetOrLoad returns the cached value for key, or rebuilds it with load on a miss.
+// Concurrent misses inside the process share one load via singleflight, and a short
+// SetNX lock key keeps other instances from rebuilding the same key at the same time.
+// The returned bool reports whether the value was served from cache. With caching turned
+// off (see SetEnabled) it simply calls load with ctx.
+//
+// A shared load gets ctx without its cancellation, bounded by loadTimeout instead: the
+// callers waiting on it must not fail because the one that started it went away.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, expiration time.Duration, load func(ctx context.Context) (T, error)) (T, bool, error) {
+	if !Enabled() {
+		value, err := load(ctx)
+		return value, false, err
+	}
+
+	var value T
+	if err := c.Get(key, &value); err == nil {
+		return value, true, nil
+	}
+
+	result, err, _ := loadGroup.Do(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return loadWithLock(c, key, expiration, func() (interface{}, error) {
+			return load(loadCtx)
+		})
+	})
+	if err != nil {
+		return value, false, err
+	}
+
+	res := result.(loadResult)
+	// Each caller decodes its own copy so shared results are never mutated concurrently
+	if err := json.Unmarshal(res.data, &value); err != nil {
+		return value, false, fmt.Errorf("failed to decode loaded value: %w", err)
+	}
+	return value, res.cached, nil
+}
+
+// loadWithLock rebuilds key while holding the distributed lock, or waits for another
+// instance to finish its rebuild. If the wait times out the value is loaded anyway.
+func loadWithLock(c Cache, key string, expiration time.Duration, load func() (interface{}, error)) (loadResult, error) {
+	lockKey := key + lockKeySuffix
+
+	acquired, err := c.SetNX(lockKey, 1, lockExpiration)
+	if err != nil {
+		slog.Warn("Failed to acquire cache lock", "key", key, "error", err)
+	}
+
+	if err == nil && !acquired {
+		deadline := time.Now().Add(lockWaitTimeout)
+		for time.Now().Before(deadline) {
+			time.Sleep(lockPollInterval)
+			var raw json.RawMessage
+			if err := c.Get(key, &raw); err == nil {
+				return loadResult{data: raw, cached: true}, nil
+			}
+		}
+	}
+
+	if acquired {
+		defer func() {
+			if err := c.Delete(lockKey); err != nil {
+				slog.Warn("Failed to release cache lock", "key", key, "error", err)
+			}
+		}()
+	}
+
+	value, err := load()
+	if err != nil {
+		return loadResult{}, err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return loadResult{}, fmt.Errorf("failed to marshal loaded value: %w", err)
+	}
+
+	if err := c.Set(key, json.RawMessage(data), expiration); err != nil {
+		slog.Warn("Failed to cache value", "key", key, "error", err)
+	}
+
+	return loadResult{data: data}, nil
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"container/list"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lruEntry is a single serialized value tracked by the LRU; a zero expiresAt never expires
+type lruEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func (e *lruEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// LRU is a fixed-size, TTL-aware least-recently-used store of serialized values
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// NewLRU creates an LRU holding at most capacity entries
+func NewLRU(capacity int) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// Get returns the value for key if present and not expired
+func (l *LRU) Get(key string) ([]byte, bool) {
+	entry, ok := l.lookup(key)
+	return entry.data, ok
+}
+
+// lookup returns a copy of the live entry for key, marking it recently used, and drops it
+// when it has expired
+func (l *LRU) lookup(key string) (lruEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return lruEntry{}, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if entry.expired(time.Now()) {
+		l.removeElement(elem)
+		return lruEntry{}, false
+	}
+
+	l.ll.MoveToFront(elem)
+	return *entry, true
+}
+
+// Set stores data under key for ttl, evicting the least recently used entry when full
+func (l *LRU) Set(key string, data []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	l.put(key, data, time.Now().Add(ttl))
+}
+
+// put stores data under key until expiresAt (zero for never), evicting the least recently
+// used entry when full
+func (l *LRU) put(key string, data []byte, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.data = data
+		entry.expiresAt = expiresAt
+		l.ll.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry{key: key, data: data, expiresAt: expiresAt})
+	for l.ll.Len() > l.capacity {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+// Delete removes key from the LRU
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.removeElement(elem)
+	}
+}
+
+// DeletePattern removes all keys matching a Redis-style glob pattern
+func (l *LRU) DeletePattern(pattern string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, elem := range l.items {
+		if matched, _ := path.Match(pattern, key); matched {
+			l.removeElement(elem)
+		}
+	}
+}
+
+// sweep removes every expired entry and returns how many there were
+func (l *LRU) sweep(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	removed := 0
+	for _, elem := range l.items {
+		if elem.Value.(*lruEntry).expired(now) {
+			l.removeElement(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// keys returns the live keys matching a Redis-style glob pattern, sorted
+func (l *LRU) keys(pattern string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, elem := range l.items {
+		if elem.Value.(*lruEntry).expired(now) {
+			continue
+		}
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Len returns the number of entries currently held
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+// removeElement unlinks an element; caller must hold l.mu
+func (l *LRU) removeElement(elem *list.Element) {
+	l.ll.Remove(elem)
+	delete(l.items, elem.Value.(*lruEntry).key)
+}
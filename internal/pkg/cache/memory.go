@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often writes first drop every expired entry, so keys written
+// once and never read again (response hashes, idempotency keys, locks) do not pile up
+const memorySweepInterval = time.Minute
+
+// MemoryCache is an in-process fallback cache used when Redis is unavailable.
+// Values are JSON-encoded like RedisCache so callers observe identical semantics.
+// It holds at most its size in entries, evicting the least recently used.
+type MemoryCache struct {
+	// mu orders the writes, so Increment and SetNX read and write atomically
+	mu        sync.Mutex
+	entries   *LRU
+	lastSweep time.Time
+}
+
+// NewMemoryCache creates an in-memory cache holding at most size entries
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		entries:   NewLRU(size),
+		lastSweep: time.Now(),
+	}
+}
+
+// expiryFor converts a Redis-style expiration into an absolute deadline
+func expiryFor(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
+
+// sweep drops the expired entries once memorySweepInterval has passed since the last time;
+// caller must hold c.mu
+func (c *MemoryCache) sweep() {
+	now := time.Now()
+	if now.Sub(c.lastSweep) < memorySweepInterval {
+		return
+	}
+	c.lastSweep = now
+	c.entries.sweep(now)
+}
+
+// Set serializes and stores data in memory with expiration
+func (c *MemoryCache) Set(key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep()
+	c.entries.put(key, data, expiryFor(expiration))
+	return nil
+}
+
+// Get retrieves and deserializes data from memory
+func (c *MemoryCache) Get(key string, dest interface{}) error {
+	entry, ok := c.entries.lookup(key)
+	if !ok {
+		return fmt.Errorf("%w for key: %s", ErrCacheMiss, key)
+	}
+
+	if err := json.Unmarshal(entry.data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal cache data: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a key from memory
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Delete(key)
+	return nil
+}
+
+// DeletePattern removes all keys matching a Redis-style glob pattern
+func (c *MemoryCache) DeletePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.DeletePattern(pattern)
+	return nil
+}
+
+// Exists checks if a key exists in memory
+func (c *MemoryCache) Exists(key string) bool {
+	_, ok := c.entries.lookup(key)
+	return ok
+}
+
+// Increment increments a numeric value in memory
+func (c *MemoryCache) Increment(key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep()
+
+	var value int64
+	entry, ok := c.entries.lookup(key)
+	if ok {
+		parsed, err := strconv.ParseInt(string(entry.data), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value at key %s is not an integer", key)
+		}
+		value = parsed
+	}
+
+	value++
+	c.entries.put(key, []byte(strconv.FormatInt(value, 10)), entry.expiresAt)
+	return value, nil
+}
+
+// SetNX sets a key only if it doesn't exist (useful for locking)
+func (c *MemoryCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweep()
+
+	if _, ok := c.entries.lookup(key); ok {
+		return false, nil
+	}
+	c.entries.put(key, data, expiryFor(expiration))
+	return true, nil
+}
+
+// GetTTL returns the remaining time to live of a key.
+// Mirrors Redis: -2 when the key does not exist, -1 when it has no expiry.
+func (c *MemoryCache) GetTTL(key string) (time.Duration, error) {
+	entry, ok := c.entries.lookup(key)
+	if !ok {
+		return -2, nil
+	}
+	if entry.expiresAt.IsZero() {
+		return -1, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+// Keys returns up to limit live keys matching a Redis-style glob pattern
+func (c *MemoryCache) Keys(pattern string, limit int) ([]string, error) {
+	keys, err := c.entries.keys(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Operation results recorded by InstrumentedCache
+const (
+	resultHit   = "hit"
+	resultMiss  = "miss"
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+var (
+	cacheOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "cache",
+		Name:      "operations_total",
+		Help:      "Cache operations by operation, key prefix and result (hit, miss, ok, error).",
+	}, []string{"operation", "prefix", "result"})
+
+	cacheLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "cache",
+		Name:      "operation_duration_seconds",
+		Help:      "Cache operation latency by operation and key prefix.",
+		Buckets:   []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+	}, []string{"operation", "prefix"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheOperations, cacheLatency)
+}
+
+// KeyPrefix returns the metric label for key: the first segment after CacheKeyPrefix
+func KeyPrefix(key string) string {
+	key = strings.TrimPrefix(key, CacheKeyPrefix)
+	if i := strings.IndexAny(key, ":*"); i >= 0 {
+		key = key[:i]
+	}
+	if key == "" {
+		return "other"
+	}
+	return key
+}
+
+// InstrumentedCache records hit/miss/error counts and latency for every operation of the wrapped cache
+type InstrumentedCache struct {
+	next Cache
+}
+
+// NewInstrumentedCache wraps next with metrics
+func NewInstrumentedCache(next Cache) *InstrumentedCache {
+	return &InstrumentedCache{next: next}
+}
+
+// observe records the outcome of one operation
+func observe(operation, key string, start time.Time, result string) {
+	prefix := KeyPrefix(key)
+	cacheLatency.WithLabelValues(operation, prefix).Observe(time.Since(start).Seconds())
+	cacheOperations.WithLabelValues(operation, prefix, result).Inc()
+}
+
+// resultOf maps an error to ok/error
+func resultOf(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
+
+// Set stores data and records the outcome
+func (c *InstrumentedCache) Set(key string, value interface{}, expiration time.Duration) error {
+	start := time.Now()
+	err := c.next.Set(key, value, expiration)
+	observe("set", key, start, resultOf(err))
+	return err
+}
+
+// Get retrieves data and records a hit, miss or error
+func (c *InstrumentedCache) Get(key string, dest interface{}) error {
+	start := time.Now()
+	err := c.next.Get(key, dest)
+	result := resultHit
+	switch {
+	case IsCacheMiss(err):
+		result = resultMiss
+	case err != nil:
+		result = resultError
+	}
+	observe("get", key, start, result)
+	return err
+}
+
+// Delete removes a key and records the outcome
+func (c *InstrumentedCache) Delete(key string) error {
+	start := time.Now()
+	err := c.next.Delete(key)
+	observe("delete", key, start, resultOf(err))
+	return err
+}
+
+// DeletePattern removes matching keys and records the outcome
+func (c *InstrumentedCache) DeletePattern(pattern string) error {
+	start := time.Now()
+	err := c.next.DeletePattern(pattern)
+	observe("delete_pattern", pattern, start, resultOf(err))
+	return err
+}
+
+// Exists checks a key and records a hit or miss
+func (c *InstrumentedCache) Exists(key string) bool {
+	start := time.Now()
+	exists := c.next.Exists(key)
+	result := resultMiss
+	if exists {
+		result = resultHit
+	}
+	observe("exists", key, start, result)
+	return exists
+}
+
+// Increment increments a counter and records the outcome
+func (c *InstrumentedCache) Increment(key string) (int64, error) {
+	start := time.Now()
+	value, err := c.next.Increment(key)
+	observe("incr", key, start, resultOf(err))
+	return value, err
+}
+
+// SetNX sets a key if absent and records the outcome
+func (c *InstrumentedCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.SetNX(key, value, expiration)
+	observe("setnx", key, start, resultOf(err))
+	return ok, err
+}
+
+// GetTTL returns a key's TTL and records the outcome
+func (c *InstrumentedCache) GetTTL(key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.next.GetTTL(key)
+	observe("ttl", key, start, resultOf(err))
+	return ttl, err
+}
+
+// Keys lists matching keys and records the outcome
+func (c *InstrumentedCache) Keys(pattern string, limit int) ([]string, error) {
+	start := time.Now()
+	keys, err := c.next.Keys(pattern, limit)
+	observe("keys", pattern, start, resultOf(err))
+	return keys, err
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisCache provides Redis-based caching functionality
+type RedisCache struct {
+	client redis.UniversalClient
+	ctx    context.Context
+}
+
+// NewRedisCache creates a new Redis-backed cache instance.
+// client may be a standalone, sentinel (failover) or cluster client.
+func NewRedisCache(client redis.UniversalClient) *RedisCache {
+	return &RedisCache{
+		client: client,
+		ctx:    context.Background(),
+	}
+}
+
+// Set serializes and stores data in Redis with expiration
+func (c *RedisCache) Set(key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	return c.client.Set(c.ctx, key, data, expiration).Err()
+}
+
+// Get retrieves and deserializes data from Redis
+func (c *RedisCache) Get(key string, dest interface{}) error {
+	data, err := c.client.Get(c.ctx, key).Result()
+	if err == redis.Nil {
+		return fmt.Errorf("%w for key: %s", ErrCacheMiss, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	err = json.Unmarshal([]byte(data), dest)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal cache data: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a key from Redis
+func (c *RedisCache) Delete(key string) error {
+	return c.client.Del(c.ctx, key).Err()
+}
+
+// DeletePattern removes all keys matching a pattern.
+// Keys are deleted one by one because a cluster rejects multi-key DEL across hash slots.
+func (c *RedisCache) DeletePattern(pattern string) error {
+	keys, err := c.Keys(pattern, 0)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(c.ctx, key)
+	}
+	if _, err := pipe.Exec(c.ctx); err != nil {
+		return fmt.Errorf("failed to delete keys for pattern %s: %w", pattern, err)
+	}
+	return nil
+}
+
+// Exists checks if a key exists in Redis
+func (c *RedisCache) Exists(key string) bool {
+	count, err := c.client.Exists(c.ctx, key).Result()
+	if err != nil {
+		slog.Warn("Failed to check cache key existence", "key", key, "error", err)
+		return false
+	}
+	return count > 0
+}
+
+// Increment increments a numeric value in Redis
+func (c *RedisCache) Increment(key string) (int64, error) {
+	return c.client.Incr(c.ctx, key).Result()
+}
+
+// SetNX sets a key only if it doesn't exist (useful for locking)
+func (c *RedisCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	return c.client.SetNX(c.ctx, key, data, expiration).Result()
+}
+
+// GetTTL returns the remaining time to live of a key
+func (c *RedisCache) GetTTL(key string) (time.Duration, error) {
+	return c.client.TTL(c.ctx, key).Result()
+}
+
+// Keys returns up to limit keys matching pattern using SCAN so Redis is never blocked.
+// In cluster mode every master is scanned, since each only holds its own slots.
+func (c *RedisCache) Keys(pattern string, limit int) ([]string, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(c.ctx, c.client, pattern, limit)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(c.ctx, func(ctx context.Context, master *redis.Client) error {
+		found, err := scanKeys(ctx, master, pattern, limit)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+// scanKeys iterates SCAN on a single node until limit keys are found (all when limit <= 0)
+func scanKeys(ctx context.Context, client redis.Cmdable, pattern string, limit int) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan keys for pattern %s: %w", pattern, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TieredCache consults an in-process LRU before the backing cache for hot keys.
+// Only keys matching one of the configured prefixes are kept locally; the local
+// TTL is deliberately short so instances converge quickly after invalidation.
+type TieredCache struct {
+	backend     Cache
+	local       *LRU
+	localTTL    time.Duration
+	hotPrefixes []string
+}
+
+// NewTieredCache wraps backend with an LRU of the given size for keys matching hotPrefixes
+func NewTieredCache(backend Cache, size int, localTTL time.Duration, hotPrefixes ...string) *TieredCache {
+	return &TieredCache{
+		backend:     backend,
+		local:       NewLRU(size),
+		localTTL:    localTTL,
+		hotPrefixes: hotPrefixes,
+	}
+}
+
+// isHot reports whether key should be cached in-process
+func (c *TieredCache) isHot(key string) bool {
+	for _, prefix := range c.hotPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// localExpiration caps the local TTL to the remote expiration
+func (c *TieredCache) localExpiration(expiration time.Duration) time.Duration {
+	if expiration > 0 && expiration < c.localTTL {
+		return expiration
+	}
+	return c.localTTL
+}
+
+// Set stores data in the backing cache and, for hot keys, in the local LRU
+func (c *TieredCache) Set(key string, value interface{}, expiration time.Duration) error {
+	if !c.isHot(key) {
+		return c.backend.Set(key, value, expiration)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache data: %w", err)
+	}
+
+	if err := c.backend.Set(key, json.RawMessage(data), expiration); err != nil {
+		c.local.Delete(key)
+		return err
+	}
+
+	c.local.Set(key, data, c.localExpiration(expiration))
+	return nil
+}
+
+// Get retrieves data from the local LRU for hot keys, falling back to the backing cache
+func (c *TieredCache) Get(key string, dest interface{}) error {
+	if !c.isHot(key) {
+		return c.backend.Get(key, dest)
+	}
+
+	if data, ok := c.local.Get(key); ok {
+		if err := json.Unmarshal(data, dest); err != nil {
+			return fmt.Errorf("failed to unmarshal cache data: %w", err)
+		}
+		return nil
+	}
+
+	var raw json.RawMessage
+	if err := c.backend.Get(key, &raw); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal cache data: %w", err)
+	}
+
+	c.local.Set(key, raw, c.remoteRemaining(key))
+	return nil
+}
+
+// remoteRemaining is the local TTL of a key read from the backing cache: the local TTL, or
+// less when the key expires sooner there, so the local copy never outlives it. Zero, which
+// keeps nothing locally, when the remaining time is unknown or under the TTL's resolution.
+func (c *TieredCache) remoteRemaining(key string) time.Duration {
+	ttl, err := c.backend.GetTTL(key)
+	switch {
+	case err != nil:
+		return 0
+	case ttl == -1: // No expiry
+		return c.localTTL
+	case ttl <= 0: // Gone, or about to be
+		return 0
+	}
+	return c.localExpiration(ttl)
+}
+
+// Delete removes a key from both tiers
+func (c *TieredCache) Delete(key string) error {
+	c.local.Delete(key)
+	return c.backend.Delete(key)
+}
+
+// DeletePattern removes all keys matching a pattern from both tiers
+func (c *TieredCache) DeletePattern(pattern string) error {
+	c.local.DeletePattern(pattern)
+	return c.backend.DeletePattern(pattern)
+}
+
+// Exists checks if a key exists in either tier
+func (c *TieredCache) Exists(key string) bool {
+	if c.isHot(key) {
+		if _, ok := c.local.Get(key); ok {
+			return true
+		}
+	}
+	return c.backend.Exists(key)
+}
+
+// Increment increments a numeric value in the backing cache
+func (c *TieredCache) Increment(key string) (int64, error) {
+	c.local.Delete(key)
+	return c.backend.Increment(key)
+}
+
+// SetNX sets a key in the backing cache only if it doesn't exist
+func (c *TieredCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return c.backend.SetNX(key, value, expiration)
+}
+
+// GetTTL returns the remaining time to live of a key in the backing cache
+func (c *TieredCache) GetTTL(key string) (time.Duration, error) {
+	return c.backend.GetTTL(key)
+}
+
+// Keys returns keys matching pattern from the backing cache
+func (c *TieredCache) Keys(pattern string, limit int) ([]string, error) {
+	return c.backend.Keys(pattern, limit)
+}
@@ -0,0 +1,18 @@
+package cache
+
+import "sync/atomic"
+
+// disabled turns off cache reads at runtime (see SetEnabled)
+var disabled atomic.Bool
+
+// SetEnabled turns cache reads on or off for this process. While off, GetOrLoad always
+// loads and response caching is skipped, but invalidations still run, so entries are not
+// stale when caching is turned back on.
+func SetEnabled(on bool) {
+	disabled.Store(!on)
+}
+
+// Enabled reports whether cache reads are on
+func Enabled() bool {
+	return !disabled.Load()
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/tenant"
+)
+
+// TTLClass groups cached data by how often it changes
+type TTLClass string
+
+// TTL classes
+const (
+	TTLList       TTLClass = "list"       // For list endpoints
+	TTLDetail     TTLClass = "detail"     // For individual items
+	TTLCount      TTLClass = "count"      // For counts
+	TTLNavigation TTLClass = "navigation" // For navigation (less frequent changes)
+	TTLReference  TTLClass = "reference"  // For reference data (provinces, cities)
+)
+
+// ttlEnvPrefix prefixes TTL overrides: CACHE_TTL_<CLASS> or CACHE_TTL_<ENTITY>_<CLASS>
+const ttlEnvPrefix = "CACHE_TTL_"
+
+// defaultTTLs are used when no override is configured
+var defaultTTLs = map[TTLClass]time.Duration{
+	TTLList:       10 * time.Minute,
+	TTLDetail:     5 * time.Minute,
+	TTLCount:      15 * time.Minute,
+	TTLNavigation: 30 * time.Minute,
+	TTLReference:  24 * time.Hour,
+}
+
+var (
+	ttlMu      sync.RWMutex
+	classTTLs  = map[TTLClass]time.Duration{}
+	entityTTLs = map[string]time.Duration{} // "entity:class"
+)
+
+// LoadTTLConfig reads TTL overrides from the environment, e.g.
+// CACHE_TTL_LIST=5m applies to every list, CACHE_TTL_USERS_DETAIL=1m only to user details.
+// Invalid or non-positive durations are logged and ignored.
+func LoadTTLConfig() {
+	classes := map[TTLClass]time.Duration{}
+	entities := map[string]time.Duration{}
+
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, ttlEnvPrefix) {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			slog.Warn("Ignoring invalid cache TTL", "name", name, "value", value)
+			continue
+		}
+
+		suffix := strings.ToLower(strings.TrimPrefix(name, ttlEnvPrefix))
+		i := strings.LastIndex(suffix, "_")
+		class := TTLClass(suffix[i+1:])
+		if _, known := defaultTTLs[class]; !known {
+			slog.Warn("Ignoring cache TTL of unknown class", "name", name, "class", class)
+			continue
+		}
+		if i < 0 {
+			classes[class] = d
+		} else {
+			entities[suffix[:i]+":"+string(class)] = d
+		}
+	}
+
+	ttlMu.Lock()
+	classTTLs, entityTTLs = classes, entities
+	ttlMu.Unlock()
+}
+
+// TTL returns the expiration for entity data of the given class.
+// Per-entity overrides win over per-class overrides, which win over the defaults.
+func TTL(entity string, class TTLClass) time.Duration {
+	ttlMu.RLock()
+	defer ttlMu.RUnlock()
+
+	if d, ok := entityTTLs[entity+":"+string(class)]; ok {
+		return d
+	}
+	if d, ok := classTTLs[class]; ok {
+		return d
+	}
+	return defaultTTLs[class]
+}
+
+// TTLFor is TTL for data of the tenant of ctx, whose settings may override the expiration
+// the same way, by entity and class or by class
+func TTLFor(ctx context.Context, entity string, class TTLClass) time.Duration {
+	overrides := tenant.SettingsFrom(ctx).CacheTTLs
+	if d, ok := overrides[entity+":"+string(class)]; ok {
+		return d
+	}
+	if d, ok := overrides[string(class)]; ok {
+		return d
+	}
+	return TTL(entity, class)
+}
+
+// KnownTTLKey reports whether key names a TTL class, "<class>" or "<entity>:<class>", as
+// tenant settings do
+func KnownTTLKey(key string) bool {
+	i := strings.LastIndex(key, ":")
+	_, known := defaultTTLs[TTLClass(key[i+1:])]
+	return known && i != 0
+}
+
+// TTLOverrides returns the overrides in effect, keyed by their environment variable
+func TTLOverrides() map[string]time.Duration {
+	ttlMu.RLock()
+	defer ttlMu.RUnlock()
+
+	overrides := make(map[string]time.Duration, len(classTTLs)+len(entityTTLs))
+	for class, d := range classTTLs {
+		overrides[ttlEnvPrefix+strings.ToUpper(string(class))] = d
+	}
+	for key, d := range entityTTLs {
+		overrides[ttlEnvPrefix+strings.ToUpper(strings.ReplaceAll(key, ":", "_"))] = d
+	}
+	return overrides
+}
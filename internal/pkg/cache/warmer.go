@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// warmer rebuilds a known cache key from its source of truth
+type warmer struct {
+	load       func() (interface{}, error)
+	expiration time.Duration
+}
+
+var (
+	warmersMu sync.RWMutex
+	warmers   = make(map[string]warmer)
+)
+
+// RegisterWarmer registers a loader that can repopulate key on demand
+func RegisterWarmer(key string, expiration time.Duration, load func() (interface{}, error)) {
+	warmersMu.Lock()
+	defer warmersMu.Unlock()
+	warmers[key] = warmer{load: load, expiration: expiration}
+}
+
+// WarmableKeys returns the keys that have a registered warmer
+func WarmableKeys() []string {
+	warmersMu.RLock()
+	defer warmersMu.RUnlock()
+
+	keys := make([]string, 0, len(warmers))
+	for key := range warmers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Warm reloads the given keys (all registered keys when none are given) into c.
+// It returns a per-key error map; keys that warmed successfully map to nil.
+func Warm(c Cache, keys ...string) map[string]error {
+	if len(keys) == 0 {
+		keys = WarmableKeys()
+	}
+
+	results := make(map[string]error, len(keys))
+	for _, key := range keys {
+		warmersMu.RLock()
+		w, ok := warmers[key]
+		warmersMu.RUnlock()
+		if !ok {
+			results[key] = fmt.Errorf("no warmer registered for key %s", key)
+			continue
+		}
+
+		value, err := w.load()
+		if err != nil {
+			results[key] = fmt.Errorf("failed to load %s: %w", key, err)
+			continue
+		}
+		results[key] = c.Set(key, value, w.expiration)
+	}
+	return results
+}
@@ -0,0 +1,110 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// caldav writes events as iCalendar resources of a CalDAV collection, one per UID
+type caldav struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newCalDAV(cfg Config) *caldav {
+	return &caldav{cfg: cfg, client: &http.Client{}}
+}
+
+// Put writes the event's resource, which replaces any earlier version
+func (d *caldav) Put(ctx context.Context, event Event) (string, error) {
+	id := url.PathEscape(event.UID) + ".ics"
+	if err := d.do(ctx, http.MethodPut, id, strings.NewReader(iCalendar(event, time.Now())), http.StatusOK, http.StatusCreated, http.StatusNoContent); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Delete removes the event's resource
+func (d *caldav) Delete(ctx context.Context, id string) error {
+	return d.do(ctx, http.MethodDelete, id, nil, http.StatusOK, http.StatusNoContent, http.StatusNotFound, http.StatusGone)
+}
+
+// do sends a request for the resource id of the collection, expecting one of want
+func (d *caldav) do(ctx context.Context, method, id string, body io.Reader, want ...int) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(d.cfg.CalDAVURL, "/")+"/"+id, body)
+	if err != nil {
+		return fmt.Errorf("caldav: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	}
+	if d.cfg.CalDAVUsername != "" {
+		req.SetBasicAuth(d.cfg.CalDAVUsername, d.cfg.CalDAVPassword)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("caldav: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("caldav %s %s answered %d", method, id, resp.StatusCode)
+}
+
+// iCalendar renders event as an RFC 5545 VCALENDAR stamped now
+func iCalendar(event Event, now time.Time) string {
+	const stamp = "20060102T150405Z"
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//adminbe//report schedules//EN",
+		"BEGIN:VEVENT",
+		"UID:" + escapeText(event.UID),
+		"DTSTAMP:" + now.UTC().Format(stamp),
+		"DTSTART:" + event.Start.UTC().Format(stamp),
+		"DTEND:" + event.End.UTC().Format(stamp),
+		"SUMMARY:" + escapeText(event.Summary),
+	}
+	if event.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escapeText(event.Description))
+	}
+	lines = append(lines, "TRANSP:TRANSPARENT", "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(fold(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// escapeText escapes a TEXT value: backslashes, semicolons, commas and line breaks
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold breaks a content line into lines of at most 75 octets, continued by a leading space,
+// without splitting a UTF-8 sequence
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
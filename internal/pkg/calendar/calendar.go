@@ -0,0 +1,221 @@
+// Package calendar publishes events to a shared calendar: a CalDAV collection (Nextcloud,
+// Radicale, iCloud and the like) reached with basic auth, or a Google Calendar written with
+// the OAuth tokens of the account an administrator connected. Events are addressed by a UID
+// the caller chooses, so publishing one again updates it in place.
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Providers
+const (
+	ProviderNone   = "none"
+	ProviderCalDAV = "caldav"
+	ProviderGoogle = "google"
+)
+
+// ErrDisabled is returned while no provider is configured
+var ErrDisabled = errors.New("calendar is disabled")
+
+// ErrNotConnected means Google Calendar is configured but no account has been connected,
+// or its access was revoked
+var ErrNotConnected = errors.New("no Google account is connected to the calendar")
+
+// Config is the calendar section of the configuration
+type Config struct {
+	// Provider is none, caldav or google
+	Provider string `yaml:"provider" env:"CALENDAR_PROVIDER" default:"none"`
+	// CalDAVURL is the calendar collection events are written to, e.g.
+	// https://cloud.example.com/remote.php/dav/calendars/reports/deliveries/
+	CalDAVURL      string `yaml:"caldav_url" env:"CALDAV_URL"`
+	CalDAVUsername string `yaml:"caldav_username" env:"CALDAV_USERNAME"`
+	CalDAVPassword string `yaml:"caldav_password" env:"CALDAV_PASSWORD"`
+	// GoogleCalendarID is the calendar of the connected account events are written to
+	GoogleCalendarID string `yaml:"google_calendar_id" env:"GOOGLE_CALENDAR_ID" default:"primary"`
+	// GoogleClientID and GoogleClientSecret are the OAuth client of a Google Cloud project
+	// with the Calendar API enabled
+	GoogleClientID     string `yaml:"google_client_id" env:"GOOGLE_OAUTH_CLIENT_ID"`
+	GoogleClientSecret string `yaml:"google_client_secret" env:"GOOGLE_OAUTH_CLIENT_SECRET"`
+	// GoogleRedirectURL is this service's GET /api/calendar/oauth/callback, as registered
+	// with the OAuth client
+	GoogleRedirectURL string `yaml:"google_redirect_url" env:"GOOGLE_OAUTH_REDIRECT_URL"`
+	GoogleAPIURL      string `yaml:"google_api_url" env:"GOOGLE_CALENDAR_API_URL" default:"https://www.googleapis.com/calendar/v3"`
+	GoogleAuthURL     string `yaml:"google_auth_url" env:"GOOGLE_OAUTH_AUTH_URL" default:"https://accounts.google.com/o/oauth2/v2/auth"`
+	GoogleTokenURL    string `yaml:"google_token_url" env:"GOOGLE_OAUTH_TOKEN_URL" default:"https://oauth2.googleapis.com/token"`
+	// Horizon is how far ahead the runs of report schedules are published
+	Horizon time.Duration `yaml:"horizon" env:"CALENDAR_HORIZON" default:"168h"`
+	// MaxEvents bounds the runs published per schedule, so one running every minute does
+	// not flood the calendar
+	MaxEvents int `yaml:"max_events" env:"CALENDAR_MAX_EVENTS" default:"50" min:"1" max:"1000"`
+	// EventDuration is how long the event of a run lasts on the calendar
+	EventDuration time.Duration `yaml:"event_duration" env:"CALENDAR_EVENT_DURATION" default:"15m"`
+	// Timeout bounds one call to the provider
+	Timeout time.Duration `yaml:"timeout" env:"CALENDAR_TIMEOUT" default:"10s"`
+}
+
+// Validate checks the configured provider has what it needs
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Provider {
+	case ProviderNone:
+	case ProviderCalDAV:
+		if !isHTTPURL(c.CalDAVURL) {
+			errs = append(errs, errors.New("CALENDAR_PROVIDER=caldav needs CALDAV_URL, an http or https URL"))
+		}
+	case ProviderGoogle:
+		if c.GoogleClientID == "" || c.GoogleClientSecret == "" {
+			errs = append(errs, errors.New("CALENDAR_PROVIDER=google needs GOOGLE_OAUTH_CLIENT_ID and GOOGLE_OAUTH_CLIENT_SECRET"))
+		}
+		if !isHTTPURL(c.GoogleRedirectURL) {
+			errs = append(errs, errors.New("CALENDAR_PROVIDER=google needs GOOGLE_OAUTH_REDIRECT_URL, an http or https URL"))
+		}
+		if strings.TrimSpace(c.GoogleCalendarID) == "" {
+			errs = append(errs, errors.New("GOOGLE_CALENDAR_ID must not be empty"))
+		}
+		for _, u := range []struct{ env, url string }{
+			{"GOOGLE_CALENDAR_API_URL", c.GoogleAPIURL}, {"GOOGLE_OAUTH_AUTH_URL", c.GoogleAuthURL}, {"GOOGLE_OAUTH_TOKEN_URL", c.GoogleTokenURL},
+		} {
+			if !isHTTPURL(u.url) {
+				errs = append(errs, fmt.Errorf("%s must be an http or https URL", u.env))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CALENDAR_PROVIDER must be none, caldav or google, not %q", c.Provider))
+	}
+	if c.Horizon < time.Hour {
+		errs = append(errs, errors.New("CALENDAR_HORIZON must be at least 1h"))
+	}
+	if c.EventDuration <= 0 {
+		errs = append(errs, errors.New("CALENDAR_EVENT_DURATION must be positive"))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("CALENDAR_TIMEOUT must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// Event is an event to publish. UID identifies it across updates and must be unique within
+// the calendar.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
+// Provider writes events to one calendar; each kind of calendar is one
+type Provider interface {
+	// Put creates the event, or updates the one of the same UID, and returns the ID the
+	// calendar knows it by
+	Put(ctx context.Context, event Event) (string, error)
+	// Delete removes the event of that ID; one already gone is not an error
+	Delete(ctx context.Context, id string) error
+}
+
+// TokenSource hands out the access token of the connected Google account, refreshing it
+// as needed. It returns ErrNotConnected when there is none.
+type TokenSource interface {
+	AccessToken(ctx context.Context) (string, error)
+}
+
+// Client writes events through the configured provider
+type Client struct {
+	mu       sync.RWMutex
+	cfg      Config
+	provider Provider
+	tokens   TokenSource
+}
+
+// Default is the process-wide client, with no provider until Configure
+var Default = &Client{}
+
+// Configure replaces the provider, e.g. on startup or a configuration reload
+func (c *Client) Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var provider Provider
+	switch cfg.Provider {
+	case ProviderCalDAV:
+		provider = newCalDAV(cfg)
+	case ProviderGoogle:
+		provider = newGoogle(cfg, c.accessToken)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg, c.provider = cfg, provider
+	return nil
+}
+
+// SetTokenSource sets where the Google provider gets its access tokens from
+func (c *Client) SetTokenSource(tokens TokenSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = tokens
+}
+
+// accessToken asks the token source for a token; the Google provider calls it per request
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.RLock()
+	tokens := c.tokens
+	c.mu.RUnlock()
+	if tokens == nil {
+		return "", ErrNotConnected
+	}
+	return tokens.AccessToken(ctx)
+}
+
+// Enabled reports whether a provider is configured
+func (c *Client) Enabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.provider != nil
+}
+
+// Config returns the configuration in effect
+func (c *Client) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg
+}
+
+// Put publishes event, bounded by CALENDAR_TIMEOUT
+func (c *Client) Put(ctx context.Context, event Event) (string, error) {
+	var id string
+	err := c.call(ctx, func(ctx context.Context, p Provider) (err error) {
+		id, err = p.Put(ctx, event)
+		return err
+	})
+	return id, err
+}
+
+// Delete removes the event of that ID, bounded by CALENDAR_TIMEOUT
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.call(ctx, func(ctx context.Context, p Provider) error {
+		return p.Delete(ctx, id)
+	})
+}
+
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context, p Provider) error) error {
+	c.mu.RLock()
+	provider, timeout := c.provider, c.cfg.Timeout
+	c.mu.RUnlock()
+	if provider == nil {
+		return ErrDisabled
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx, provider)
+}
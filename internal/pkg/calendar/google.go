@@ -0,0 +1,207 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GoogleScope is the OAuth scope asked for: the events of the account's calendars, nothing else
+const GoogleScope = "https://www.googleapis.com/auth/calendar.events"
+
+// google writes events through the Google Calendar API, as the connected account
+type google struct {
+	cfg    Config
+	client *http.Client
+	token  func(ctx context.Context) (string, error)
+}
+
+func newGoogle(cfg Config, token func(ctx context.Context) (string, error)) *google {
+	return &google{cfg: cfg, client: &http.Client{}, token: token}
+}
+
+// googleEventID derives the event ID from the UID. Google takes IDs the caller chooses
+// when they are base32hex, which hex digits are.
+func googleEventID(uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return hex.EncodeToString(sum[:])
+}
+
+// Put updates the event of the UID, inserting it when the calendar has none. An event
+// deleted earlier is revived by the update, as Google keeps its ID taken.
+func (g *google) Put(ctx context.Context, event Event) (string, error) {
+	id := googleEventID(event.UID)
+	body := map[string]any{
+		"id":           id,
+		"summary":      event.Summary,
+		"description":  event.Description,
+		"start":        map[string]string{"dateTime": event.Start.UTC().Format(time.RFC3339)},
+		"end":          map[string]string{"dateTime": event.End.UTC().Format(time.RFC3339)},
+		"status":       "confirmed",
+		"transparency": "transparent",
+	}
+	status, err := g.do(ctx, http.MethodPut, "/events/"+id, body)
+	if err == nil && status == http.StatusNotFound {
+		status, err = g.do(ctx, http.MethodPost, "/events", body)
+	}
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("google calendar answered %d", status)
+	}
+	return id, nil
+}
+
+// Delete cancels the event
+func (g *google) Delete(ctx context.Context, id string) error {
+	status, err := g.do(ctx, http.MethodDelete, "/events/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound, http.StatusGone:
+		return nil
+	}
+	return fmt.Errorf("google calendar answered %d", status)
+}
+
+// do calls the API at path under the configured calendar and returns the status; a token
+// Google refuses is ErrNotConnected
+func (g *google) do(ctx context.Context, method, path string, body any) (int, error) {
+	token, err := g.token(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := strings.TrimSuffix(g.cfg.GoogleAPIURL, "/") + "/calendars/" + url.PathEscape(g.cfg.GoogleCalendarID) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, errors.New("google calendar: invalid API URL")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("google calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode == http.StatusUnauthorized {
+		return 0, ErrNotConnected
+	}
+	return resp.StatusCode, nil
+}
+
+// Token is what Google's token endpoint grants: an access token good until ExpiresAt, and
+// the refresh token that gets the next one
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	Scope        string
+}
+
+// AuthCodeURL returns the Google consent page asking for GoogleScope, offline so a refresh
+// token is granted; state comes back to the callback unchanged
+func (c *Client) AuthCodeURL(state string) (string, error) {
+	cfg := c.Config()
+	if cfg.Provider != ProviderGoogle {
+		return "", ErrDisabled
+	}
+	params := url.Values{
+		"client_id":     {cfg.GoogleClientID},
+		"redirect_uri":  {cfg.GoogleRedirectURL},
+		"response_type": {"code"},
+		"scope":         {GoogleScope},
+		"access_type":   {"offline"},
+		"prompt":        {"consent"},
+		"state":         {state},
+	}
+	return cfg.GoogleAuthURL + "?" + params.Encode(), nil
+}
+
+// Exchange trades the code the consent page returned for a token
+func (c *Client) Exchange(ctx context.Context, code string) (*Token, error) {
+	return c.grant(ctx, url.Values{"grant_type": {"authorization_code"}, "code": {code}})
+}
+
+// Refresh gets a new access token with refreshToken. A refresh token Google no longer
+// honours, revoked or expired, is ErrNotConnected.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	token, err := c.grant(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	if err != nil {
+		return nil, err
+	}
+	// Google only sends a refresh token with the first grant
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// grant calls the token endpoint with params and the client's credentials
+func (c *Client) grant(ctx context.Context, params url.Values) (*Token, error) {
+	cfg := c.Config()
+	if cfg.Provider != ProviderGoogle {
+		return nil, ErrDisabled
+	}
+	params.Set("client_id", cfg.GoogleClientID)
+	params.Set("client_secret", cfg.GoogleClientSecret)
+	params.Set("redirect_uri", cfg.GoogleRedirectURL)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.GoogleTokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.New("google oauth: invalid token URL")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("google oauth: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Scope            string `json:"scope"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("google oauth answered %d: %w", resp.StatusCode, err)
+	}
+	if result.Error == "invalid_grant" {
+		return nil, ErrNotConnected
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return nil, fmt.Errorf("google oauth answered %d: %s %s", resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+	return &Token{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+		Scope:        result.Scope,
+	}, nil
+}
@@ -0,0 +1,317 @@
+// Package challenge asks clients that exceed an abuse threshold to prove they are not a
+// script before they are served: either a proof-of-work puzzle the server issues and checks
+// on its own, or a reCAPTCHA token checked with Google. The thresholds themselves are rate
+// limit budgets (see middleware.RateLimitPolicy.ChallengeMiddleware), set below the hard
+// limits, so a client under normal load never sees a challenge.
+//
+// A proof-of-work challenge is "<expiry>.<nonce>.<difficulty>.<mac>", signed so the server
+// keeps no state for it. The client answers "<challenge>:<counter>" with a counter making the
+// SHA-256 of that string start with difficulty zero bits, about 2^difficulty hashes of work.
+// Each challenge is accepted once; with Redis attached, once across every instance.
+package challenge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/ratelimit"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Providers
+const (
+	ProviderNone      = "none"
+	ProviderPoW       = "pow"
+	ProviderRecaptcha = "recaptcha"
+)
+
+// DefaultPrefix is prepended to the Redis keys of spent challenges
+const DefaultPrefix = "cms:challenge:"
+
+// ErrRejected wraps every reason an answer is refused, as opposed to the verifier failing
+var ErrRejected = errors.New("challenge answer rejected")
+
+// Config is the challenge section of the configuration
+type Config struct {
+	// Provider is none (never challenge), pow or recaptcha
+	Provider string `yaml:"provider" env:"CHALLENGE_PROVIDER" default:"none"`
+	// LoginAfter and PrayerAfter are the budgets past which a client must answer a challenge,
+	// per address for sign-in and per user for the shalat POSTs; "0" never asks. Keep them
+	// below RATE_LIMIT_LOGIN and RATE_LIMIT_PRAYER, which refuse outright.
+	LoginAfter  ratelimit.Rate `yaml:"login_after" env:"CHALLENGE_LOGIN_AFTER" default:"5/1m"`
+	PrayerAfter ratelimit.Rate `yaml:"prayer_after" env:"CHALLENGE_PRAYER_AFTER" default:"60/1m"`
+	// Secret signs proof-of-work challenges; every instance needs the same one. Unset, each
+	// process makes up its own, which only works with a single instance.
+	Secret     string        `yaml:"secret" env:"CHALLENGE_SECRET"`
+	Difficulty int           `yaml:"pow_difficulty" env:"CHALLENGE_POW_DIFFICULTY" default:"18" min:"8" max:"28"`
+	TTL        time.Duration `yaml:"pow_ttl" env:"CHALLENGE_POW_TTL" default:"2m"`
+	// reCAPTCHA keys from the admin console; v3 tokens must score at least MinScore
+	RecaptchaSiteKey   string  `yaml:"recaptcha_site_key" env:"RECAPTCHA_SITE_KEY"`
+	RecaptchaSecret    string  `yaml:"recaptcha_secret" env:"RECAPTCHA_SECRET"`
+	RecaptchaMinScore  float64 `yaml:"recaptcha_min_score" env:"RECAPTCHA_MIN_SCORE" default:"0.5"`
+	RecaptchaVerifyURL string  `yaml:"recaptcha_verify_url" env:"RECAPTCHA_VERIFY_URL" default:"https://www.google.com/recaptcha/api/siteverify"`
+}
+
+// Validate checks the provider has what it needs
+func (c *Config) Validate() error {
+	c.Provider = strings.ToLower(strings.TrimSpace(c.Provider))
+	switch c.Provider {
+	case ProviderNone:
+	case ProviderPoW:
+		if c.TTL < 10*time.Second {
+			return errors.New("CHALLENGE_POW_TTL must be at least 10s")
+		}
+	case ProviderRecaptcha:
+		if c.RecaptchaSiteKey == "" || c.RecaptchaSecret == "" {
+			return errors.New("CHALLENGE_PROVIDER=recaptcha needs RECAPTCHA_SITE_KEY and RECAPTCHA_SECRET")
+		}
+		if c.RecaptchaMinScore < 0 || c.RecaptchaMinScore > 1 {
+			return errors.New("RECAPTCHA_MIN_SCORE must be between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("CHALLENGE_PROVIDER must be none, pow or recaptcha, not %q", c.Provider)
+	}
+	return nil
+}
+
+// Challenge is what a client is told to answer
+type Challenge struct {
+	Type string `json:"type"`
+	// Token and Difficulty are the proof-of-work puzzle
+	Token      string     `json:"token,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	// SiteKey renders the reCAPTCHA widget
+	SiteKey string `json:"site_key,omitempty"`
+}
+
+// Challenger issues and checks challenges
+type Challenger struct {
+	mu     sync.RWMutex
+	cfg    Config
+	key    []byte
+	random []byte // the key used while no secret is configured
+	redis  redis.UniversalClient
+	prefix string
+	client *http.Client
+
+	localMu sync.Mutex
+	spent   map[string]time.Time
+}
+
+// NewChallenger creates a challenger that asks nothing until configured
+func NewChallenger() *Challenger {
+	return &Challenger{
+		cfg:    Config{Provider: ProviderNone},
+		client: &http.Client{Timeout: 5 * time.Second},
+		spent:  make(map[string]time.Time),
+	}
+}
+
+// Default is the process-wide challenger
+var Default = NewChallenger()
+
+// Configure sets the provider and its settings, e.g. on startup or a configuration reload
+func (ch *Challenger) Configure(cfg Config) error {
+	key := []byte(cfg.Secret)
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(key) == 0 {
+		if ch.random == nil {
+			ch.random = make([]byte, 32)
+			if _, err := rand.Read(ch.random); err != nil {
+				ch.random = nil
+				return err
+			}
+		}
+		key = ch.random
+	}
+	ch.cfg, ch.key = cfg, key
+	return nil
+}
+
+// AttachRedis records spent challenges with every instance using client, under keys
+// starting with prefix
+func (ch *Challenger) AttachRedis(client redis.UniversalClient, prefix string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.redis, ch.prefix = client, prefix
+}
+
+// Enabled reports whether clients past a threshold are challenged
+func (ch *Challenger) Enabled() bool {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.cfg.Provider != ProviderNone
+}
+
+// Issue returns a challenge for a client to answer
+func (ch *Challenger) Issue() (Challenge, error) {
+	ch.mu.RLock()
+	cfg, key := ch.cfg, ch.key
+	ch.mu.RUnlock()
+
+	if cfg.Provider == ProviderRecaptcha {
+		return Challenge{Type: ProviderRecaptcha, SiteKey: cfg.RecaptchaSiteKey}, nil
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return Challenge{}, err
+	}
+	expires := time.Now().Add(cfg.TTL).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%s.%d", expires.Unix(), hex.EncodeToString(nonce), cfg.Difficulty)
+	return Challenge{
+		Type:       ProviderPoW,
+		Token:      payload + "." + sign(key, payload),
+		Difficulty: cfg.Difficulty,
+		ExpiresAt:  &expires,
+	}, nil
+}
+
+// Verify checks a client's answer; errors wrapping ErrRejected mean it is wrong, others that
+// it could not be checked
+func (ch *Challenger) Verify(ctx context.Context, answer, clientIP string) error {
+	ch.mu.RLock()
+	cfg, key := ch.cfg, ch.key
+	ch.mu.RUnlock()
+
+	switch cfg.Provider {
+	case ProviderPoW:
+		return ch.verifyPoW(ctx, key, answer)
+	case ProviderRecaptcha:
+		return ch.verifyRecaptcha(ctx, cfg, answer, clientIP)
+	}
+	return nil
+}
+
+func (ch *Challenger) verifyPoW(ctx context.Context, key []byte, answer string) error {
+	token, counter, ok := strings.Cut(answer, ":")
+	if !ok || counter == "" || len(counter) > 32 {
+		return fmt.Errorf("%w: want \"<token>:<counter>\"", ErrRejected)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return fmt.Errorf("%w: malformed token", ErrRejected)
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(sign(key, payload))) {
+		return fmt.Errorf("%w: token was not issued here", ErrRejected)
+	}
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return fmt.Errorf("%w: token expired", ErrRejected)
+	}
+	difficulty, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrRejected)
+	}
+	if zeroBits(sha256.Sum256([]byte(answer))) < difficulty {
+		return fmt.Errorf("%w: not enough work", ErrRejected)
+	}
+	if !ch.spend(ctx, parts[1], time.Until(time.Unix(expiry, 0))) {
+		return fmt.Errorf("%w: token already used", ErrRejected)
+	}
+	return nil
+}
+
+// recaptchaReply is the siteverify answer; Score is only set for v3 tokens
+type recaptchaReply struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (ch *Challenger) verifyRecaptcha(ctx context.Context, cfg Config, answer, clientIP string) error {
+	form := url.Values{"secret": {cfg.RecaptchaSecret}, "response": {answer}}
+	if clientIP != "" {
+		form.Set("remoteip", clientIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.RecaptchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := ch.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("reCAPTCHA verification failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("reCAPTCHA verification answered %d", resp.StatusCode)
+	}
+	var reply recaptchaReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("reCAPTCHA verification: %w", err)
+	}
+	if !reply.Success {
+		return fmt.Errorf("%w: %s", ErrRejected, strings.Join(reply.ErrorCodes, ", "))
+	}
+	if reply.Score != nil && *reply.Score < cfg.RecaptchaMinScore {
+		return fmt.Errorf("%w: score %.1f", ErrRejected, *reply.Score)
+	}
+	return nil
+}
+
+// spend records nonce as used for ttl, reporting false when it already was. When Redis
+// fails this process's record decides.
+func (ch *Challenger) spend(ctx context.Context, nonce string, ttl time.Duration) bool {
+	ch.mu.RLock()
+	client, prefix := ch.redis, ch.prefix
+	ch.mu.RUnlock()
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	if client != nil {
+		ok, err := client.SetNX(ctx, prefix+"spent:"+nonce, 1, ttl).Result()
+		if err == nil {
+			return ok
+		}
+	}
+
+	now := time.Now()
+	ch.localMu.Lock()
+	defer ch.localMu.Unlock()
+	for n, until := range ch.spent {
+		if now.After(until) {
+			delete(ch.spent, n)
+		}
+	}
+	if _, used := ch.spent[nonce]; used {
+		return false
+	}
+	ch.spent[nonce] = now.Add(ttl)
+	return true
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("challenge|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// zeroBits counts the leading zero bits of sum
+func zeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
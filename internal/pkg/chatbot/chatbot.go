@@ -0,0 +1,133 @@
+// Package chatbot sends text messages to chats through messaging bots: Telegram, through the
+// Bot API, and WhatsApp, through the Cloud API. A channel is enabled by configuring its
+// credentials; what is sent, to whom and when is left to the caller.
+package chatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Channels
+const (
+	ChannelTelegram = "telegram"
+	ChannelWhatsApp = "whatsapp"
+)
+
+// Channels lists every channel, configured or not
+var Channels = []string{ChannelTelegram, ChannelWhatsApp}
+
+// ErrDisabled is returned by Send for a channel without credentials
+var ErrDisabled = errors.New("chat channel is not configured")
+
+// ErrUnreachable wraps the refusals that will not go away by retrying: the chat does not
+// exist, or the user blocked the bot or never started it
+var ErrUnreachable = errors.New("chat is unreachable")
+
+// Config is the chatbot section of the configuration
+type Config struct {
+	// TelegramToken is the bot token @BotFather issued; empty disables Telegram
+	TelegramToken  string `yaml:"telegram_token" env:"TELEGRAM_BOT_TOKEN"`
+	TelegramAPIURL string `yaml:"telegram_api_url" env:"TELEGRAM_API_URL" default:"https://api.telegram.org"`
+	// WhatsAppToken is a Cloud API access token; empty disables WhatsApp
+	WhatsAppToken string `yaml:"whatsapp_token" env:"WHATSAPP_TOKEN"`
+	// WhatsAppPhoneNumberID is the ID of the business phone number messages are sent from
+	WhatsAppPhoneNumberID string `yaml:"whatsapp_phone_number_id" env:"WHATSAPP_PHONE_NUMBER_ID"`
+	WhatsAppAPIURL        string `yaml:"whatsapp_api_url" env:"WHATSAPP_API_URL" default:"https://graph.facebook.com/v19.0"`
+	// Timeout bounds one message
+	Timeout time.Duration `yaml:"timeout" env:"CHATBOT_TIMEOUT" default:"15s"`
+}
+
+// Validate checks the configured channels have what they need
+func (c *Config) Validate() error {
+	var errs []error
+	if c.TelegramToken != "" && !isHTTPURL(c.TelegramAPIURL) {
+		errs = append(errs, errors.New("TELEGRAM_API_URL must be an http or https URL"))
+	}
+	if c.WhatsAppToken != "" {
+		if c.WhatsAppPhoneNumberID == "" {
+			errs = append(errs, errors.New("WHATSAPP_TOKEN needs WHATSAPP_PHONE_NUMBER_ID"))
+		}
+		if !isHTTPURL(c.WhatsAppAPIURL) {
+			errs = append(errs, errors.New("WHATSAPP_API_URL must be an http or https URL"))
+		}
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("CHATBOT_TIMEOUT must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// Sender delivers a text message to a chat; each channel is one
+type Sender interface {
+	Send(ctx context.Context, chatID, text string) error
+}
+
+// Bot sends messages through the configured channels
+type Bot struct {
+	mu      sync.RWMutex
+	cfg     Config
+	senders map[string]Sender
+}
+
+// Default is the process-wide bot, with no channel until Configure
+var Default = &Bot{}
+
+// Configure replaces the channels, e.g. on startup or a configuration reload
+func (b *Bot) Configure(cfg Config) {
+	senders := map[string]Sender{}
+	if cfg.TelegramToken != "" {
+		senders[ChannelTelegram] = newTelegramSender(cfg)
+	}
+	if cfg.WhatsAppToken != "" {
+		senders[ChannelWhatsApp] = newWhatsAppSender(cfg)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cfg, b.senders = cfg, senders
+}
+
+// Enabled reports whether channel is configured
+func (b *Bot) Enabled(channel string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.senders[channel] != nil
+}
+
+// EnabledChannels lists the configured channels, sorted
+func (b *Bot) EnabledChannels() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	channels := make([]string, 0, len(b.senders))
+	for channel := range b.senders {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Send delivers text to chatID on channel once, bounded by CHATBOT_TIMEOUT
+func (b *Bot) Send(ctx context.Context, channel, chatID, text string) error {
+	b.mu.RLock()
+	sender, timeout := b.senders[channel], b.cfg.Timeout
+	b.mu.RUnlock()
+	if sender == nil {
+		return fmt.Errorf("%s: %w", channel, ErrDisabled)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return sender.Send(ctx, chatID, text)
+}
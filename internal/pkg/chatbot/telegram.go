@@ -0,0 +1,80 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// telegramMaxText is the longest message the Bot API accepts, in characters
+const telegramMaxText = 4096
+
+// telegramSender calls sendMessage of the Telegram Bot API
+type telegramSender struct {
+	url    string
+	client *http.Client
+}
+
+func newTelegramSender(cfg Config) *telegramSender {
+	return &telegramSender{
+		url:    strings.TrimSuffix(cfg.TelegramAPIURL, "/") + "/bot" + cfg.TelegramToken + "/sendMessage",
+		client: &http.Client{},
+	}
+}
+
+type telegramRequest struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (s *telegramSender) Send(ctx context.Context, chatID, text string) error {
+	if runes := []rune(text); len(runes) > telegramMaxText {
+		text = string(runes[:telegramMaxText])
+	}
+	body, err := json.Marshal(telegramRequest{ChatID: chatID, Text: text, DisableWebPagePreview: true})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("telegram: invalid API URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The URL carries the bot token, so only the cause is kept
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && result.OK {
+		return nil
+	}
+	err = fmt.Errorf("telegram answered %d: %s", resp.StatusCode, result.Description)
+	// 403 is a blocked bot or a chat it was removed from; 400 "chat not found" an unknown ID
+	if resp.StatusCode == http.StatusForbidden ||
+		(resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(result.Description), "chat not found")) {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return err
+}
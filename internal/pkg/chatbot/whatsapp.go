@@ -0,0 +1,90 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// whatsAppUndeliverable is the Cloud API error code for a number that is not on WhatsApp
+const whatsAppUndeliverable = 131026
+
+// whatsAppSender posts text messages to the WhatsApp Cloud API. WhatsApp only delivers free
+// text within 24 hours of the user's last message to the business number; outside that
+// window the API refuses it and the caller sees the error.
+type whatsAppSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newWhatsAppSender(cfg Config) *whatsAppSender {
+	return &whatsAppSender{
+		url:    strings.TrimSuffix(cfg.WhatsAppAPIURL, "/") + "/" + cfg.WhatsAppPhoneNumberID + "/messages",
+		token:  cfg.WhatsAppToken,
+		client: &http.Client{},
+	}
+}
+
+type whatsAppText struct {
+	Body string `json:"body"`
+}
+
+type whatsAppRequest struct {
+	MessagingProduct string       `json:"messaging_product"`
+	RecipientType    string       `json:"recipient_type"`
+	To               string       `json:"to"`
+	Type             string       `json:"type"`
+	Text             whatsAppText `json:"text"`
+}
+
+type whatsAppResponse struct {
+	Error *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+func (s *whatsAppSender) Send(ctx context.Context, chatID, text string) error {
+	body, err := json.Marshal(whatsAppRequest{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               chatID,
+		Type:             "text",
+		Text:             whatsAppText{Body: text},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("whatsapp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+
+	var result whatsAppResponse
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if result.Error == nil {
+		return fmt.Errorf("whatsapp answered %d", resp.StatusCode)
+	}
+	err = fmt.Errorf("whatsapp answered %d: %s (code %d)", resp.StatusCode, result.Error.Message, result.Error.Code)
+	if result.Error.Code == whatsAppUndeliverable {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return err
+}
@@ -0,0 +1,514 @@
+// Package config loads the service configuration at startup: defaults, overridden by
+// configs/config.yaml, overridden by the environment (which .env feeds). Subsystems get
+// their section from main instead of reading the environment themselves. A reload reads it
+// again and Diff tells what changed; applying the changes is up to the caller.
+//
+// Every setting has a YAML key and most have an environment variable; see the struct tags
+// and load.go for the syntax. Cache TTL overrides (CACHE_TTL_<ENTITY>_<CLASS>) are the one
+// exception: their names are open-ended, so the cache package scans the environment for them.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"adminbe/internal/app/middleware"
+	"adminbe/internal/app/models"
+	"adminbe/internal/pkg/alerting"
+	"adminbe/internal/pkg/calendar"
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/chatbot"
+	"adminbe/internal/pkg/database"
+	"adminbe/internal/pkg/domainevents"
+	"adminbe/internal/pkg/errortracking"
+	"adminbe/internal/pkg/fieldcrypt"
+	"adminbe/internal/pkg/geocode"
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/jwtkeys"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/mail"
+	"adminbe/internal/pkg/password"
+	"adminbe/internal/pkg/push"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/scheduler"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/internal/pkg/secrets"
+	"adminbe/internal/pkg/slo"
+	"adminbe/internal/pkg/sms"
+	"adminbe/internal/pkg/storage"
+)
+
+// Config is the whole configuration
+type Config struct {
+	Server          Server                           `yaml:"server"`
+	API             API                              `yaml:"api"`
+	CORS            middleware.CORSConfig            `yaml:"cors"`
+	SecurityHeaders middleware.SecurityHeadersConfig `yaml:"security_headers"`
+	IPFilter        ipfilter.Config                  `yaml:"ip_filter"`
+	SecurityEvents  secevents.Config                 `yaml:"security_events"`
+	JWT             JWT                              `yaml:"jwt"`
+	CookieAuth      middleware.CookieAuthConfig      `yaml:"cookie_auth"`
+	Tenancy         middleware.TenancyConfig         `yaml:"tenancy"`
+	Database        database.Config                  `yaml:"database"`
+	Redis           database.RedisConfig             `yaml:"redis"`
+	Jasper          models.JasperServerConfig        `yaml:"jasper"`
+	Branding        models.Branding                  `yaml:"branding"`
+	Password        password.Config                  `yaml:"password"`
+	Encryption      fieldcrypt.Config                `yaml:"encryption"`
+	Logging         logging.Config                   `yaml:"logging"`
+	ErrorTracking   errortracking.Config             `yaml:"error_tracking"`
+	Events          domainevents.Config              `yaml:"events"`
+	Webhooks        Webhooks                         `yaml:"webhooks"`
+	Mail            mail.Config                      `yaml:"mail"`
+	Chatbot         chatbot.Config                   `yaml:"chatbot"`
+	Push            push.Config                      `yaml:"push"`
+	Storage         storage.Config                   `yaml:"storage"`
+	Geocoder        geocode.Config                   `yaml:"geocoder"`
+	Calendar        calendar.Config                  `yaml:"calendar"`
+	SMS             sms.Config                       `yaml:"sms"`
+	Limits          Limits                           `yaml:"limits"`
+	RateLimit       RateLimit                        `yaml:"rate_limit"`
+	Challenge       challenge.Config                 `yaml:"challenge"`
+	Timeouts        Timeouts                         `yaml:"timeouts"`
+	Health          Health                           `yaml:"health"`
+	PayloadLog      PayloadLog                       `yaml:"payload_log"`
+	WebSocket       WebSocket                        `yaml:"websocket"`
+	EventStream     EventStream                      `yaml:"event_stream"`
+	GraphQL         GraphQL                          `yaml:"graphql"`
+	SLO             slo.Config                       `yaml:"slo"`
+	Alerting        alerting.Config                  `yaml:"alerting"`
+	Startup         Startup                          `yaml:"startup"`
+	Jobs            Jobs                             `yaml:"jobs"`
+	Secrets         secrets.Config                   `yaml:"secrets"`
+
+	// secretRefs are the secrets manager references settings were resolved from, by path
+	secretRefs map[string]string
+}
+
+// Server configures the listeners and the operator endpoints
+type Server struct {
+	Port     string `yaml:"port" env:"PORT" default:"8080"`
+	GRPCPort string `yaml:"grpc_port" env:"GRPC_PORT" default:"9090"` // "0" turns gRPC off
+	// PprofEnabled is the initial state of the pprof feature, switchable at runtime
+	PprofEnabled    bool   `yaml:"pprof_enabled" env:"PPROF_ENABLED" default:"true"`
+	SwaggerUIAssets string `yaml:"swagger_ui_assets" env:"SWAGGER_UI_ASSETS" default:"https://unpkg.com/swagger-ui-dist@5"`
+	// DeployedAt is shown by /status; the deploy pipeline sets it, else the start time is used
+	DeployedAt time.Time `yaml:"deployed_at" env:"DEPLOYED_AT"`
+	// ShutdownTimeout bounds draining in-flight requests on SIGTERM; requests still running
+	// then are cut off
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is believed for the
+	// client address (logs, per-address rate limits, the IP filter); empty believes no
+	// sender, and the client address is the connection's
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	TLS            TLS      `yaml:"tls"`
+}
+
+// TLS serves HTTPS (and HTTP/2) from the server itself, for small deployments without a
+// proxy in front. Either CertFile and KeyFile or AutocertDomains turn it on.
+type TLS struct {
+	Port     string `yaml:"port" env:"TLS_PORT" default:"8443"`
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"` // PEM, the chain after the leaf
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// AutocertDomains get certificates from Let's Encrypt, renewed automatically; the
+	// server must be reachable on 443 for them, or on 80 with PORT=80
+	AutocertDomains  []string `yaml:"autocert_domains" env:"TLS_AUTOCERT_DOMAINS"`
+	AutocertEmail    string   `yaml:"autocert_email" env:"TLS_AUTOCERT_EMAIL"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir" env:"TLS_AUTOCERT_CACHE_DIR" default:"certs"`
+	// RedirectHTTP makes the plain PORT listener redirect to HTTPS rather than serve the API
+	RedirectHTTP bool `yaml:"redirect_http" env:"TLS_REDIRECT_HTTP" default:"true"`
+}
+
+// Enabled reports whether HTTPS is configured
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.AutocertDomains) > 0
+}
+
+// Validate requires a certificate source and only one
+func (t *TLS) Validate() error {
+	switch {
+	case !t.Enabled():
+		return nil
+	case (t.CertFile == "") != (t.KeyFile == ""):
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	case t.CertFile != "" && len(t.AutocertDomains) > 0:
+		return errors.New("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are exclusive")
+	case len(t.AutocertDomains) > 0 && t.AutocertCacheDir == "":
+		return errors.New("TLS_AUTOCERT_CACHE_DIR is required with TLS_AUTOCERT_DOMAINS")
+	case t.Port == "" || t.Port == "0":
+		return errors.New("TLS_PORT is required with TLS")
+	}
+	return nil
+}
+
+// API configures request handling shared by many routes
+type API struct {
+	// ResponseVersion is the format of requests without Accept-Version: "2" or "1"
+	ResponseVersion string `yaml:"response_version" env:"API_RESPONSE_VERSION" default:"2"`
+	// DefaultLanguage localizes the messages of requests asking for no supported language
+	// with lang or Accept-Language: "en" or "id"
+	DefaultLanguage string `yaml:"default_language" env:"DEFAULT_LANGUAGE" default:"en"`
+	// ProblemTypeBase prefixes the type of problem+json errors; empty makes them about:blank
+	ProblemTypeBase   string `yaml:"problem_type_base" env:"PROBLEM_TYPE_BASE"`
+	ShalatJSONEncoder string `yaml:"shalat_json_encoder" env:"SHALAT_JSON_ENCODER" default:"std"`
+	// QueryCountWarn logs requests making more database queries than this; 0 disables
+	QueryCountWarn   int           `yaml:"query_count_warn" env:"DB_QUERY_COUNT_WARN" default:"25" min:"0" max:"10000"`
+	IdempotencyTTL   time.Duration `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL" default:"24h"`
+	BatchMaxRequests int           `yaml:"batch_max_requests" env:"BATCH_MAX_REQUESTS" default:"20" min:"1" max:"1000"`
+	// BulkMaxIDs caps the IDs a bulk-delete or bulk-restore request lists
+	BulkMaxIDs int `yaml:"bulk_max_ids" env:"BULK_MAX_IDS" default:"500" min:"1" max:"10000"`
+	// MaxBodyBytes caps request bodies, answering larger ones with 413; 0 leaves them
+	// unlimited. BatchMaxBodyBytes replaces it for /api/batch.
+	MaxBodyBytes      int64 `yaml:"max_body_bytes" env:"MAX_BODY_BYTES" default:"1048576" min:"0"`
+	BatchMaxBodyBytes int64 `yaml:"batch_max_body_bytes" env:"BATCH_MAX_BODY_BYTES" default:"4194304" min:"0"`
+	// ImportMaxBytes caps the files uploaded to the import endpoints, ImportMaxRows their
+	// rows below the header
+	ImportMaxBytes int64 `yaml:"import_max_bytes" env:"IMPORT_MAX_BYTES" default:"10485760" min:"1024"`
+	ImportMaxRows  int   `yaml:"import_max_rows" env:"IMPORT_MAX_ROWS" default:"5000" min:"1" max:"100000"`
+	// PrayerWorkers computes multi-day schedules; 0 uses GOMAXPROCS, 1 is sequential
+	PrayerWorkers int `yaml:"prayer_workers" env:"PRAYER_WORKERS" default:"0" min:"0" max:"256"`
+	// PrayerMethod is the calculation method of prayer schedules (see models.PrayerMethods);
+	// tenants may choose their own
+	PrayerMethod string `yaml:"prayer_method" env:"PRAYER_METHOD" default:"kemenag"`
+	// LocationCodeSecret keys the opaque /api/v2 location codes; it must stay the same
+	// across deploys and replicas
+	LocationCodeSecret string `yaml:"location_code_secret" env:"LOCATION_CODE_SECRET"`
+	// SCIMToken authenticates identity providers on /scim/v2; empty turns SCIM off
+	SCIMToken string `yaml:"scim_token" env:"SCIM_TOKEN"`
+	// AuditIngestKeys are "service:key" entries authenticating sibling services on POST
+	// /api/audit_logs/batch, the service naming the entries it sends; empty turns it off.
+	// AuditIngestMaxEntries caps the entries one request carries.
+	AuditIngestKeys       []string `yaml:"audit_ingest_keys" env:"AUDIT_INGEST_KEYS"`
+	AuditIngestMaxEntries int      `yaml:"audit_ingest_max_entries" env:"AUDIT_INGEST_MAX_ENTRIES" default:"500" min:"1" max:"2000"`
+	// V1DeprecatedAt and V1Sunset announce the deprecation of the /api/apiv1 routes
+	V1DeprecatedAt time.Time `yaml:"v1_deprecated_at" env:"API_V1_DEPRECATED_AT" default:"2026-10-17T00:00:00Z"`
+	V1Sunset       time.Time `yaml:"v1_sunset" env:"API_V1_SUNSET"`
+}
+
+// JWT configures the access tokens issued by /api/auth/login
+type JWT struct {
+	// Secret signs tokens while Keys is empty; after that it only verifies the tokens it
+	// signed, which carry no kid
+	Secret string `yaml:"secret" env:"JWT_SECRET"`
+	// Keys are "id:secret" entries, the id becoming the token's kid; the first signs and the
+	// rest only verify. "id:secret:<RFC 3339 time>" marks when a key stopped signing: it is
+	// dropped once Expiration has passed since. adminctl rotate-jwt-secret maintains them.
+	Keys       []string      `yaml:"keys" env:"JWT_KEYS"`
+	Expiration time.Duration `yaml:"expiration" env:"JWT_EXPIRATION" default:"24h"`
+}
+
+// auditIngestServicePattern is what a service name of AUDIT_INGEST_KEYS may be
+var auditIngestServicePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// AuditIngestServices reads AUDIT_INGEST_KEYS into the service each key belongs to
+func (a *API) AuditIngestServices() (map[string]string, error) {
+	services := make(map[string]string, len(a.AuditIngestKeys))
+	seen := make(map[string]bool, len(a.AuditIngestKeys))
+	for i, entry := range a.AuditIngestKeys {
+		service, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !auditIngestServicePattern.MatchString(service) {
+			// The entry itself is never echoed: it holds the key
+			return nil, fmt.Errorf("AUDIT_INGEST_KEYS entry %d: want \"service:key\" with a service of lower-case letters, digits, - and _", i+1)
+		}
+		if seen[service] {
+			return nil, fmt.Errorf("AUDIT_INGEST_KEYS lists service %q twice", service)
+		}
+		seen[service] = true
+		if len(key) < 32 {
+			return nil, fmt.Errorf("AUDIT_INGEST_KEYS key of %q must be at least 32 characters", service)
+		}
+		if _, taken := services[key]; taken {
+			return nil, fmt.Errorf("AUDIT_INGEST_KEYS key of %q is shared with another service", service)
+		}
+		services[key] = service
+	}
+	return services, nil
+}
+
+// Webhooks configures outbound webhook delivery
+type Webhooks struct {
+	Workers     int           `yaml:"workers" env:"WEBHOOK_WORKERS" default:"4" min:"1" max:"64"`
+	Timeout     time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" default:"10s"`
+	MaxAttempts int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"5" min:"1" max:"20"`
+	// RetryBackoff is the wait before the first retry, doubled after each failure
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"WEBHOOK_RETRY_BACKOFF" default:"30s"`
+}
+
+// Limits bounds concurrent requests; a 0 limit turns the limiter off
+type Limits struct {
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests" env:"MAX_CONCURRENT_REQUESTS" default:"256" min:"0" max:"100000"`
+	MaxQueuedRequests     int `yaml:"max_queued_requests" env:"MAX_QUEUED_REQUESTS" default:"512" min:"0" max:"100000"`
+	// QueueTimeout is how long a queued request waits for a slot before it gets 429
+	QueueTimeout        time.Duration `yaml:"queue_timeout" env:"LIMIT_QUEUE_TIMEOUT" default:"500ms"`
+	ReportMaxConcurrent int           `yaml:"report_max_concurrent" env:"REPORT_MAX_CONCURRENT" default:"4" min:"0" max:"1000"`
+	ExportMaxConcurrent int           `yaml:"export_max_concurrent" env:"EXPORT_MAX_CONCURRENT" default:"2" min:"0" max:"1000"`
+}
+
+// RateLimit budgets requests per client in token buckets, shared through Redis. A rate is
+// "N/period": bursts of N, refilled at N per period; "0" turns it off.
+type RateLimit struct {
+	// Login is per client address, against password guessing
+	Login ratelimit.Rate `yaml:"login" env:"RATE_LIMIT_LOGIN" default:"10/1m"`
+	// Prayer covers the shalat lookups (/api/apiv1, /api/v2/prayer), per user
+	Prayer ratelimit.Rate `yaml:"prayer" env:"RATE_LIMIT_PRAYER" default:"120/1m"`
+	// API covers the other authenticated routes and GraphQL, per user
+	API ratelimit.Rate `yaml:"api" env:"RATE_LIMIT_API" default:"600/1m"`
+}
+
+// Timeouts are response-time budgets; 0 disables one
+type Timeouts struct {
+	Request time.Duration `yaml:"request" env:"REQUEST_TIMEOUT" default:"2s"`
+	Report  time.Duration `yaml:"report" env:"REPORT_TIMEOUT" default:"30s"`
+	// Export covers the streaming exports and CSV lists
+	Export time.Duration `yaml:"export" env:"EXPORT_TIMEOUT" default:"10m"`
+	Batch  time.Duration `yaml:"batch" env:"BATCH_TIMEOUT" default:"10s"`
+	Pprof  time.Duration `yaml:"pprof" env:"PPROF_TIMEOUT" default:"2m"`
+}
+
+// Health configures the readiness probe and the status page
+type Health struct {
+	// ReadinessRequired lists the dependencies that must be up for the pod to take traffic
+	ReadinessRequired   []string      `yaml:"readiness_required" env:"READINESS_REQUIRED" default:"database"`
+	CheckTimeout        time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"1s"`
+	CheckInterval       time.Duration `yaml:"check_interval" env:"HEALTH_CHECK_INTERVAL" default:"1s"`
+	StatusCheckInterval time.Duration `yaml:"status_check_interval" env:"STATUS_CHECK_INTERVAL" default:"15s"`
+}
+
+// PayloadLog configures the redacted request/response capture
+type PayloadLog struct {
+	// Enabled is the initial state of the payload_log feature, switchable at runtime
+	Enabled  bool     `yaml:"enabled" env:"PAYLOAD_LOG_ENABLED" default:"false"`
+	Size     int      `yaml:"size" env:"PAYLOAD_LOG_SIZE" default:"200" min:"1" max:"10000"`
+	MaxBytes int      `yaml:"max_bytes" env:"PAYLOAD_LOG_MAX_BYTES" default:"8192" min:"0" max:"1048576"`
+	Routes   []string `yaml:"routes" env:"PAYLOAD_LOG_ROUTES" default:"/api"`
+}
+
+// WebSocket configures /ws
+type WebSocket struct {
+	MaxClients   int           `yaml:"max_clients" env:"WS_MAX_CLIENTS" default:"1000" min:"0" max:"100000"`
+	Buffer       int           `yaml:"buffer" env:"WS_BUFFER" default:"64" min:"1" max:"10000"`
+	PingInterval time.Duration `yaml:"ping_interval" env:"WS_PING_INTERVAL" default:"30s"`
+	AuthTimeout  time.Duration `yaml:"auth_timeout" env:"WS_AUTH_TIMEOUT" default:"10s"`
+}
+
+// EventStream configures /api/events/stream
+type EventStream struct {
+	MaxClients  int           `yaml:"max_clients" env:"EVENT_STREAM_MAX_CLIENTS" default:"100" min:"0" max:"100000"`
+	Buffer      int           `yaml:"buffer" env:"EVENT_STREAM_BUFFER" default:"256" min:"1" max:"100000"`
+	Heartbeat   time.Duration `yaml:"heartbeat" env:"EVENT_STREAM_HEARTBEAT" default:"15s"`
+	MaxDuration time.Duration `yaml:"max_duration" env:"EVENT_STREAM_MAX_DURATION" default:"1h"`
+}
+
+// GraphQL bounds the queries /graphql accepts
+type GraphQL struct {
+	MaxDepth      int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" default:"8" min:"1" max:"100"`
+	MaxQueryBytes int `yaml:"max_query_bytes" env:"GRAPHQL_MAX_QUERY_BYTES" default:"8192" min:"256" max:"1048576"`
+}
+
+// Startup configures the self-checks run before serving
+type Startup struct {
+	// FailFast refuses to start when a hard check fails; false logs the failures and serves anyway
+	FailFast     bool          `yaml:"fail_fast" env:"STARTUP_FAIL_FAST" default:"true"`
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"STARTUP_CHECK_TIMEOUT" default:"10s"`
+}
+
+// Jobs configures the background job scheduler and the jobs it runs. Schedules use
+// scheduler.Parse's syntax: five cron fields in local time, a descriptor such as @daily, or
+// "@every 15m".
+type Jobs struct {
+	// Enabled runs jobs on schedule; false still lists them and lets admins run them by hand
+	Enabled         bool               `yaml:"enabled" env:"JOBS_ENABLED" default:"true"`
+	HistorySize     int                `yaml:"history_size" env:"JOB_HISTORY_SIZE" default:"20" min:"1" max:"1000"`
+	AuditRetention  AuditRetentionJob  `yaml:"audit_retention"`
+	CacheWarm       CacheWarmJob       `yaml:"cache_warm"`
+	FieldReencrypt  FieldReencryptJob  `yaml:"field_reencrypt"`
+	PrayerDaily     PrayerDailyJob     `yaml:"prayer_daily"`
+	PrayerImsakiyah PrayerImsakiyahJob `yaml:"prayer_imsakiyah"`
+	PushReminders   PushRemindersJob   `yaml:"push_reminders"`
+	OTPCleanup      OTPCleanupJob      `yaml:"otp_cleanup"`
+	ExportJobs      ExportJobsJob      `yaml:"export_jobs"`
+	DBBackup        DBBackupJob        `yaml:"db_backup"`
+	DataQuality     DataQualityJob     `yaml:"data_quality"`
+	ReportSchedules ReportSchedulesJob `yaml:"report_schedules"`
+	CalendarSync    CalendarSyncJob    `yaml:"calendar_sync"`
+}
+
+// AuditRetentionJob deletes audit log entries older than MaxAge
+type AuditRetentionJob struct {
+	Enabled  bool          `yaml:"enabled" env:"JOB_AUDIT_RETENTION_ENABLED" default:"false"`
+	Schedule string        `yaml:"schedule" env:"JOB_AUDIT_RETENTION_SCHEDULE" default:"30 3 * * *"`
+	MaxAge   time.Duration `yaml:"max_age" env:"AUDIT_RETENTION" default:"2160h"`
+}
+
+// CacheWarmJob refills the warmable cache keys before they expire
+type CacheWarmJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_CACHE_WARM_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_CACHE_WARM_SCHEDULE" default:"@every 15m"`
+}
+
+// FieldReencryptJob rewrites encrypted columns still sealed with an old key, or in the clear
+type FieldReencryptJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_FIELD_REENCRYPT_ENABLED" default:"false"`
+	Schedule string `yaml:"schedule" env:"JOB_FIELD_REENCRYPT_SCHEDULE" default:"0 4 * * 0"`
+}
+
+// PrayerDailyJob sends the day's prayer times to the daily chat subscriptions
+type PrayerDailyJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_PRAYER_DAILY_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_PRAYER_DAILY_SCHEDULE" default:"0 4 * * *"`
+}
+
+// PrayerImsakiyahJob sends the imsak reminder to the imsakiyah chat subscriptions, on the
+// days of the fasting period only
+type PrayerImsakiyahJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_PRAYER_IMSAKIYAH_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_PRAYER_IMSAKIYAH_SCHEDULE" default:"0 2 * * *"`
+}
+
+// PushRemindersJob sends the prayer reminders that have come due to the registered devices;
+// it should run every minute or so for them to arrive on time
+type PushRemindersJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_PUSH_REMINDERS_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_PUSH_REMINDERS_SCHEDULE" default:"* * * * *"`
+}
+
+// OTPCleanupJob deletes the one-time codes that expired more than a day ago
+type OTPCleanupJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_OTP_CLEANUP_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_OTP_CLEANUP_SCHEDULE" default:"15 * * * *"`
+}
+
+// ExportJobsJob runs the queued export jobs of /api/jobs. Timeout bounds one export and
+// Retention is how long a finished export's file is kept.
+type ExportJobsJob struct {
+	Enabled   bool          `yaml:"enabled" env:"JOB_EXPORT_JOBS_ENABLED" default:"true"`
+	Schedule  string        `yaml:"schedule" env:"JOB_EXPORT_JOBS_SCHEDULE" default:"@every 30s"`
+	Timeout   time.Duration `yaml:"timeout" env:"EXPORT_JOB_TIMEOUT" default:"1h"`
+	Retention time.Duration `yaml:"retention" env:"EXPORT_JOB_RETENTION" default:"168h"`
+}
+
+// DBBackupJob dumps the database to storage, on schedule and for POST /api/admin/backups.
+// Timeout bounds one backup and Retention is how long a backup's file is kept.
+type DBBackupJob struct {
+	Enabled   bool          `yaml:"enabled" env:"JOB_DB_BACKUP_ENABLED" default:"false"`
+	Schedule  string        `yaml:"schedule" env:"JOB_DB_BACKUP_SCHEDULE" default:"0 2 * * *"`
+	Timeout   time.Duration `yaml:"timeout" env:"DB_BACKUP_TIMEOUT" default:"1h"`
+	Retention time.Duration `yaml:"retention" env:"DB_BACKUP_RETENTION" default:"720h"`
+}
+
+// DataQualityJob runs the data quality checks of /api/admin/data-quality
+type DataQualityJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_DATA_QUALITY_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_DATA_QUALITY_SCHEDULE" default:"0 5 * * *"`
+}
+
+// ReportSchedulesJob runs the report schedules of /api/reports/schedules that are due; it
+// should run every minute or so for reports to arrive on time. Timeout bounds one report.
+type ReportSchedulesJob struct {
+	Enabled  bool          `yaml:"enabled" env:"JOB_REPORT_SCHEDULES_ENABLED" default:"true"`
+	Schedule string        `yaml:"schedule" env:"JOB_REPORT_SCHEDULES_SCHEDULE" default:"* * * * *"`
+	Timeout  time.Duration `yaml:"timeout" env:"REPORT_SCHEDULE_TIMEOUT" default:"10m"`
+}
+
+// CalendarSyncJob publishes the upcoming runs of the report schedules to the calendar
+// configured under calendar
+type CalendarSyncJob struct {
+	Enabled  bool   `yaml:"enabled" env:"JOB_CALENDAR_SYNC_ENABLED" default:"true"`
+	Schedule string `yaml:"schedule" env:"JOB_CALENDAR_SYNC_SCHEDULE" default:"@every 15m"`
+}
+
+// Validate checks every schedule parses and the retention keeps at least a day
+func (j *Jobs) Validate() error {
+	var errs []error
+	for _, s := range []struct{ env, spec string }{
+		{"JOB_AUDIT_RETENTION_SCHEDULE", j.AuditRetention.Schedule},
+		{"JOB_CACHE_WARM_SCHEDULE", j.CacheWarm.Schedule},
+		{"JOB_FIELD_REENCRYPT_SCHEDULE", j.FieldReencrypt.Schedule},
+		{"JOB_PRAYER_DAILY_SCHEDULE", j.PrayerDaily.Schedule},
+		{"JOB_PRAYER_IMSAKIYAH_SCHEDULE", j.PrayerImsakiyah.Schedule},
+		{"JOB_PUSH_REMINDERS_SCHEDULE", j.PushReminders.Schedule},
+		{"JOB_OTP_CLEANUP_SCHEDULE", j.OTPCleanup.Schedule},
+		{"JOB_EXPORT_JOBS_SCHEDULE", j.ExportJobs.Schedule},
+		{"JOB_DB_BACKUP_SCHEDULE", j.DBBackup.Schedule},
+		{"JOB_DATA_QUALITY_SCHEDULE", j.DataQuality.Schedule},
+		{"JOB_REPORT_SCHEDULES_SCHEDULE", j.ReportSchedules.Schedule},
+		{"JOB_CALENDAR_SYNC_SCHEDULE", j.CalendarSync.Schedule},
+	} {
+		if _, err := scheduler.Parse(s.spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	if j.AuditRetention.MaxAge < 24*time.Hour {
+		errs = append(errs, errors.New("AUDIT_RETENTION must be at least 24h"))
+	}
+	if j.ExportJobs.Timeout < time.Minute {
+		errs = append(errs, errors.New("EXPORT_JOB_TIMEOUT must be at least 1m"))
+	}
+	if j.ExportJobs.Retention < time.Hour {
+		errs = append(errs, errors.New("EXPORT_JOB_RETENTION must be at least 1h"))
+	}
+	if j.DBBackup.Timeout < time.Minute {
+		errs = append(errs, errors.New("DB_BACKUP_TIMEOUT must be at least 1m"))
+	}
+	if j.DBBackup.Retention < 24*time.Hour {
+		errs = append(errs, errors.New("DB_BACKUP_RETENTION must be at least 24h"))
+	}
+	if j.ReportSchedules.Timeout < time.Minute {
+		errs = append(errs, errors.New("REPORT_SCHEDULE_TIMEOUT must be at least 1m"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks the settings the service cannot start without, then every section,
+// and reports all problems together. Sections may normalize values, e.g. lower-case names.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.JWT.Secret {
+	case "":
+		if len(c.JWT.Keys) == 0 {
+			errs = append(errs, errors.New("JWT_SECRET or JWT_KEYS is required"))
+		}
+	case "change_this_in_production", "default_secret_change_in_prod":
+		errs = append(errs, errors.New("JWT_SECRET is still the example value"))
+	}
+	if _, err := jwtkeys.ParseKeys(c.JWT.Keys); err != nil {
+		errs = append(errs, fmt.Errorf("JWT_KEYS: %w", err))
+	}
+	if c.JWT.Expiration <= 0 {
+		errs = append(errs, errors.New("JWT_EXPIRATION must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.Startup.CheckTimeout <= 0 {
+		errs = append(errs, errors.New("STARTUP_CHECK_TIMEOUT must be positive"))
+	}
+	if c.Server.TLS.Enabled() && c.Server.TLS.Port == c.Server.Port {
+		errs = append(errs, errors.New("TLS_PORT must differ from PORT"))
+	}
+	if c.API.ResponseVersion != "1" && c.API.ResponseVersion != "2" {
+		errs = append(errs, fmt.Errorf("unsupported API_RESPONSE_VERSION %q (want 1 or 2)", c.API.ResponseVersion))
+	}
+	if !i18n.Supported(c.API.DefaultLanguage) {
+		errs = append(errs, fmt.Errorf("unsupported DEFAULT_LANGUAGE %q (want one of %s)", c.API.DefaultLanguage, strings.Join(i18n.Languages, ", ")))
+	}
+	if !slices.Contains(models.PrayerMethods, c.API.PrayerMethod) {
+		errs = append(errs, fmt.Errorf("unsupported PRAYER_METHOD %q (want one of %s)", c.API.PrayerMethod, strings.Join(models.PrayerMethods, ", ")))
+	}
+	if _, err := c.API.AuditIngestServices(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, checkBounds(c))
+
+	for _, section := range []interface{ Validate() error }{
+		&c.Server.TLS, &c.CORS, &c.SecurityHeaders, &c.IPFilter, &c.SecurityEvents, &c.CookieAuth, &c.Tenancy, &c.Database, &c.Redis, &c.Password, &c.Challenge, &c.Encryption, &c.Logging, &c.ErrorTracking, &c.Events, &c.Mail, &c.Chatbot, &c.Push, &c.Storage, &c.Geocoder, &c.Calendar, &c.SMS, &c.SLO, &c.Alerting, &c.Jobs,
+	} {
+		errs = append(errs, section.Validate())
+	}
+	return errors.Join(errs...)
+}
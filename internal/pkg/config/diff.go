@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change is a setting whose value differs between two configurations
+type Change struct {
+	// Setting is the environment variable, else the dotted YAML path (health.readiness_required)
+	Setting string
+	Old     string
+	New     string
+}
+
+// Diff lists the settings that differ from old to new, in declaration order
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffSection(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+func diffSection(old, new reflect.Value, path string, changes *[]Change) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if path != "" {
+			key = path + "." + key
+		}
+		o, n := old.Field(i), new.Field(i)
+		if field.Type.Kind() == reflect.Struct && !isScalar(field.Type) {
+			diffSection(o, n, key, changes)
+			continue
+		}
+		if reflect.DeepEqual(o.Interface(), n.Interface()) {
+			continue
+		}
+		name := field.Tag.Get("env")
+		if name == "" {
+			name = key
+		}
+		*changes = append(*changes, Change{Setting: name, Old: formatValue(o), New: formatValue(n)})
+	}
+}
+
+// formatValue renders a setting in the syntax of its environment variable
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
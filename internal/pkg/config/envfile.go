@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	envFileMu sync.Mutex
+	// envFileKeys are the variables the env file set, as opposed to the real environment
+	envFileKeys = map[string]bool{}
+)
+
+// LoadEnvFile sets the variables of the env file at path that the real environment does not
+// set, like godotenv.Load. Called again, e.g. on a configuration reload, it applies the
+// file's new values and unsets the variables removed from it (all of them once the file is
+// gone), still never touching what the process was started with.
+func LoadEnvFile(path string) error {
+	values, err := godotenv.Read(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	envFileMu.Lock()
+	defer envFileMu.Unlock()
+	for key := range envFileKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(envFileKeys, key)
+		}
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !envFileKeys[key] {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+		envFileKeys[key] = true
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Settings are described by struct tags:
+//
+//	yaml:"name"        key in the YAML file
+//	env:"NAME"         environment variable overriding the file; empty counts as unset
+//	default:"value"    value when neither sets it, in the environment variable's syntax
+//	min:"1" max:"64"   bounds of an integer
+//
+// Durations take Go syntax ("30s") and may not be negative, lists are comma-separated in
+// the environment, and types implementing encoding.TextUnmarshaler (time.Time, slog.Level)
+// parse themselves. Sections are nested structs.
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	textType     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Where the server and its tools look for their settings
+const (
+	DefaultPath    = "configs/config.yaml"
+	DefaultEnvFile = ".env"
+)
+
+// Default returns the configuration made of defaults only, e.g. for tools that build the
+// routes without serving them
+func Default() *Config {
+	cfg := &Config{}
+	if err := walk(reflect.ValueOf(cfg).Elem(), applyDefault); err != nil {
+		panic(err) // a malformed default tag is a programming error
+	}
+	return cfg
+}
+
+// Read returns the defaults overridden by the YAML file at path (a missing file is
+// skipped) and then by the environment, with secrets manager references resolved, without
+// validating the result
+func Read(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	if err := walk(reflect.ValueOf(cfg).Elem(), applyEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.resolveSecrets(context.Background()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Load reads the configuration like Read and validates it, reporting every problem at once
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// walk calls fn for every setting of the struct v, descending into sections
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && !isScalar(field.Type) {
+			if err := walk(value, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(value, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isScalar reports whether values of t are set from one string rather than being sections
+func isScalar(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textType)
+}
+
+func applyDefault(v reflect.Value, field reflect.StructField) error {
+	def, ok := field.Tag.Lookup("default")
+	if !ok {
+		return nil
+	}
+	if err := set(v, def); err != nil {
+		return fmt.Errorf("default of %s: %w", field.Name, err)
+	}
+	return nil
+}
+
+func applyEnv(v reflect.Value, field reflect.StructField) error {
+	name := field.Tag.Get("env")
+	if name == "" {
+		return nil
+	}
+	s := os.Getenv(name)
+	if s == "" {
+		return nil
+	}
+	if err := set(v, s); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, s, err)
+	}
+	return nil
+}
+
+// set parses s into v
+func set(v reflect.Value, s string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return errors.New("must not be negative")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("want true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("want a whole number")
+		}
+		v.SetInt(n)
+	case reflect.Uint8, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("want a whole number")
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("want a number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list of %s", v.Type().Elem())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// checkBounds returns an error for every integer setting outside its min and max tags
+func checkBounds(cfg *Config) error {
+	var errs []error
+	walk(reflect.ValueOf(cfg).Elem(), func(v reflect.Value, field reflect.StructField) error {
+		if v.Kind() != reflect.Int && v.Kind() != reflect.Int64 {
+			return nil
+		}
+		n := v.Int()
+		minTag, hasMin := field.Tag.Lookup("min")
+		maxTag, hasMax := field.Tag.Lookup("max")
+		lo, _ := strconv.ParseInt(minTag, 10, 64)
+		hi, _ := strconv.ParseInt(maxTag, 10, 64)
+		if (hasMin && n < lo) || (hasMax && n > hi) {
+			errs = append(errs, fmt.Errorf("%s is %d, want %s", settingName(field), n, bounds(hasMin, minTag, hasMax, maxTag)))
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// bounds describes the range of an integer setting
+func bounds(hasMin bool, lo string, hasMax bool, hi string) string {
+	switch {
+	case hasMin && hasMax:
+		return "from " + lo + " to " + hi
+	case hasMin:
+		return "at least " + lo
+	}
+	return "at most " + hi
+}
+
+// settingName names a setting in errors by its environment variable, else its YAML key
+func settingName(field reflect.StructField) string {
+	if name := field.Tag.Get("env"); name != "" {
+		return name
+	}
+	return field.Tag.Get("yaml")
+}
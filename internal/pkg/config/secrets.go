@@ -0,0 +1,93 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"adminbe/internal/pkg/secrets"
+)
+
+// resolveSecrets replaces every string setting holding a secrets manager reference with the
+// secret it names, remembering the references in c so RefreshSecrets can fetch them again
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	refs := make(map[string]string)
+	for path, ref := range c.secretRefs {
+		refs[path] = ref
+	}
+	targets := make(map[string]reflect.Value)
+	collectSecrets(reflect.ValueOf(c).Elem(), "", refs, targets)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	list := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		list = append(list, ref)
+	}
+	values, err := secrets.NewResolver(c.Secrets).Resolve(ctx, list)
+	if err != nil {
+		return fmt.Errorf("resolve secrets: %w", err)
+	}
+	for path, ref := range refs {
+		if v, ok := targets[path]; ok {
+			if err := set(v, values[ref]); err != nil {
+				return fmt.Errorf("secret %s: %w", path, err)
+			}
+		}
+	}
+	c.secretRefs = refs
+	return nil
+}
+
+// collectSecrets records, by dotted YAML path, the settings holding a reference and those
+// resolved from one before, and where to write their values
+func collectSecrets(v reflect.Value, path string, refs map[string]string, targets map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if path != "" {
+			key = path + "." + key
+		}
+		value := v.Field(i)
+		if field.Type.Kind() == reflect.Struct && !isScalar(field.Type) {
+			// The stores' own settings cannot come from them
+			if field.Type != reflect.TypeOf(secrets.Config{}) {
+				collectSecrets(value, key, refs, targets)
+			}
+			continue
+		}
+		if value.Kind() != reflect.String {
+			continue
+		}
+		if secrets.IsReference(value.String()) {
+			refs[key] = value.String()
+		}
+		if _, ok := refs[key]; ok {
+			targets[key] = value
+		}
+	}
+}
+
+// UsesSecrets reports whether any setting came from a secrets manager
+func (c *Config) UsesSecrets() bool {
+	return len(c.secretRefs) > 0
+}
+
+// RefreshSecrets returns a copy of c with every referenced secret fetched again and
+// validated, for applying rotated credentials without re-reading the files
+func (c *Config) RefreshSecrets(ctx context.Context) (*Config, error) {
+	next := *c
+	if err := next.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config is the database section of the configuration
+type Config struct {
+	Driver   string `yaml:"driver" env:"DB_DRIVER" default:"mysql"`
+	Host     string `yaml:"host" env:"DB_HOST" default:"127.0.0.1"`
+	Port     string `yaml:"port" env:"DB_PORT"` // defaults to the driver's port
+	User     string `yaml:"user" env:"DB_USER" default:"root"`
+	Password string `yaml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" env:"DB_NAME" default:"db_cms"`
+	// SSLMode is the PostgreSQL sslmode
+	SSLMode string        `yaml:"sslmode" env:"DB_SSLMODE" default:"disable"`
+	Replica ReplicaConfig `yaml:"replica"`
+	Pool    PoolConfig    `yaml:"pool"`
+	// MigrationCheck refuses to start while embedded migrations are pending; AutoMigrate
+	// applies them first
+	MigrationCheck bool           `yaml:"migration_check" env:"DB_MIGRATION_CHECK" default:"true"`
+	AutoMigrate    bool           `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" default:"false"`
+	QueryLog       QueryLogConfig `yaml:"query_log"`
+	Count          CountConfig    `yaml:"count"`
+}
+
+// ReplicaConfig is the optional read replica: DSN as a whole, or Host with the other
+// fields falling back to the primary's
+type ReplicaConfig struct {
+	DSN      string `yaml:"dsn" env:"DB_REPLICA_DSN"`
+	Host     string `yaml:"host" env:"DB_REPLICA_HOST"`
+	Port     string `yaml:"port" env:"DB_REPLICA_PORT"`
+	User     string `yaml:"user" env:"DB_REPLICA_USER"`
+	Password string `yaml:"password" env:"DB_REPLICA_PASSWORD"`
+}
+
+// Validate checks the connection settings, accepting driver aliases in any case, and fills
+// in the driver's default port
+func (c *Config) Validate() error {
+	var errs []error
+	dialect, err := dialectFor(c.Driver)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		c.Driver = dialect.Name()
+		if c.Port == "" {
+			c.Port = dialect.DefaultPort()
+		}
+	}
+	for _, required := range []struct{ name, value string }{
+		{"DB_HOST", c.Host}, {"DB_USER", c.User}, {"DB_NAME", c.Name},
+	} {
+		if required.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", required.name))
+		}
+	}
+	c.Count.Mode = strings.ToLower(c.Count.Mode)
+	switch c.Count.Mode {
+	case CountExact, CountEstimated, CountAuto:
+	default:
+		errs = append(errs, fmt.Errorf("unknown LIST_COUNT_MODE %q (want %s, %s or %s)", c.Count.Mode, CountExact, CountEstimated, CountAuto))
+	}
+	return errors.Join(errs...)
+}
+
+// DSN is the data source name of the primary
+func (c Config) DSN() string {
+	dialect, err := dialectFor(c.Driver)
+	if err != nil {
+		dialect = Current
+	}
+	if c.Port == "" {
+		c.Port = dialect.DefaultPort()
+	}
+	return dialect.DSN(c)
+}
+
+// ReplicaDSN is the data source name of the read replica, or "" when none is configured
+func (c Config) ReplicaDSN() string {
+	if c.Replica.DSN != "" {
+		return c.Replica.DSN
+	}
+	if c.Replica.Host == "" {
+		return ""
+	}
+	replica := c
+	replica.Host = c.Replica.Host
+	if c.Replica.Port != "" {
+		replica.Port = c.Replica.Port
+	}
+	if c.Replica.User != "" {
+		replica.User = c.Replica.User
+	}
+	if c.Replica.Password != "" {
+		replica.Password = c.Replica.Password
+	}
+	return replica.DSN()
+}
+
+// RedisConfig is the redis section of the configuration. Mode is standalone, sentinel or
+// cluster; Addrs lists sentinel or cluster nodes and defaults to Host:Port.
+type RedisConfig struct {
+	// Enabled false uses the in-memory cache only
+	Enabled          bool     `yaml:"enabled" env:"REDIS_ENABLED" default:"true"`
+	Mode             string   `yaml:"mode" env:"REDIS_MODE" default:"standalone"`
+	Host             string   `yaml:"host" env:"REDIS_HOST" default:"127.0.0.1"`
+	Port             string   `yaml:"port" env:"REDIS_PORT" default:"6379"`
+	Addrs            []string `yaml:"addrs" env:"REDIS_ADDRS"`
+	MasterName       string   `yaml:"master_name" env:"REDIS_MASTER_NAME"` // required for sentinel
+	Username         string   `yaml:"username" env:"REDIS_USERNAME"`
+	Password         string   `yaml:"password" env:"REDIS_PASSWORD"`
+	SentinelPassword string   `yaml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD"`
+	DB               int      `yaml:"db" env:"REDIS_DB" default:"0" min:"0"` // ignored in cluster mode
+	TLS              bool     `yaml:"tls" env:"REDIS_TLS" default:"false"`
+	// TLSCAFile is a PEM bundle verifying the server certificate; TLSSkipVerify is for testing only
+	TLSCAFile     string `yaml:"tls_ca_file" env:"REDIS_TLS_CA_FILE"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify" env:"REDIS_TLS_SKIP_VERIFY" default:"false"`
+	// LocalSize and LocalTTL size the in-process tier in front of Redis; 0 turns it off
+	LocalSize int           `yaml:"local_cache_size" env:"CACHE_LOCAL_SIZE" default:"256" min:"0"`
+	LocalTTL  time.Duration `yaml:"local_cache_ttl" env:"CACHE_LOCAL_TTL" default:"30s"`
+	// MemorySize caps the in-memory cache used without Redis; past it the least recently
+	// used entries are evicted
+	MemorySize int `yaml:"memory_cache_size" env:"CACHE_MEMORY_SIZE" default:"100000" min:"1"`
+}
+
+// Validate accepts a Mode in any case and requires MasterName for sentinel
+func (c *RedisConfig) Validate() error {
+	c.Mode = strings.ToLower(c.Mode)
+	switch c.Mode {
+	case RedisModeStandalone, RedisModeCluster:
+	case RedisModeSentinel:
+		if c.MasterName == "" {
+			return fmt.Errorf("REDIS_MASTER_NAME is required when REDIS_MODE=%s", RedisModeSentinel)
+		}
+	default:
+		return fmt.Errorf("unknown REDIS_MODE %q (want %s, %s or %s)", c.Mode, RedisModeStandalone, RedisModeSentinel, RedisModeCluster)
+	}
+	return nil
+}
+
+// addrs returns Addrs, or Host:Port when it is empty
+func (c RedisConfig) addrs() []string {
+	if len(c.Addrs) > 0 {
+		return c.Addrs
+	}
+	return []string{c.Host + ":" + c.Port}
+}
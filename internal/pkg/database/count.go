@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"adminbe/internal/pkg/logging"
+)
+
+// List total modes selected by LIST_COUNT_MODE
+const (
+	CountExact     = "exact"     // COUNT(*) on every uncached request
+	CountEstimated = "estimated" // the planner's table statistics, no scan
+	CountAuto      = "auto"      // the estimate when it is at least the threshold, else COUNT(*)
+)
+
+// CountConfig controls how paginated lists compute their total
+type CountConfig struct {
+	Mode string `yaml:"mode" env:"LIST_COUNT_MODE" default:"exact"`
+	// AutoThreshold is the estimate below which auto mode counts exactly
+	AutoThreshold int64 `yaml:"auto_threshold" env:"LIST_COUNT_AUTO_THRESHOLD" default:"1000000" min:"0"`
+}
+
+// countConfig holds the active settings; replaced once at startup by ConnectDB
+var countConfig = CountConfig{Mode: CountExact, AutoThreshold: 1000000}
+
+// RowQuerier is the part of *sql.DB and *sql.Tx EstimateRows needs
+type RowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// EstimateRows returns the engine's row estimate for table from its statistics
+// (information_schema on MySQL, pg_class on PostgreSQL). It covers the whole table,
+// soft-deleted rows included, and can be off by a wide margin on InnoDB. A negative
+// result means the engine has no estimate yet.
+func EstimateRows(ctx context.Context, q RowQuerier, table string) (int64, error) {
+	var rows int64
+	if err := q.QueryRowContext(ctx, Current.EstimateRowsQuery(), table).Scan(&rows); err != nil {
+		return 0, fmt.Errorf("failed to estimate rows of %s: %w", table, err)
+	}
+	return rows, nil
+}
+
+// Total returns a paginated list's total following LIST_COUNT_MODE, and whether it is an
+// estimate. estimate is only called outside exact mode; exact is called in exact mode, when
+// auto mode finds a small table, and whenever no estimate is available.
+func Total(ctx context.Context, estimate func(context.Context) (int64, error), exact func(context.Context) (int, error)) (int, bool, error) {
+	if countConfig.Mode != CountExact {
+		rows, err := estimate(ctx)
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "Row estimate failed, falling back to an exact count", "error", err)
+		} else if rows >= 0 && (countConfig.Mode == CountEstimated || rows >= countConfig.AutoThreshold) {
+			return int(rows), true, nil
+		}
+	}
+	total, err := exact(ctx)
+	return total, false, err
+}
@@ -1,48 +1,57 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"log/slog"
+	"time"
 
+	"adminbe/internal/pkg/broadcast"
 	"adminbe/internal/pkg/cache"
+	"adminbe/internal/pkg/challenge"
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/ipfilter"
+	"adminbe/internal/pkg/lock"
+	"adminbe/internal/pkg/maintenance"
+	"adminbe/internal/pkg/migrate"
+	"adminbe/internal/pkg/notify"
+	"adminbe/internal/pkg/ratelimit"
+	"adminbe/internal/pkg/secevents"
+	"adminbe/migrations"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var (
-	RedisClient *redis.Client
-	Cache       *cache.Cache
-	StmtCache   *PreparedStmts
+	RedisClient redis.UniversalClient
+	// Cache defaults to an in-memory implementation so callers never see a nil cache,
+	// even before ConnectDB runs or when Redis is unavailable
+	Cache     cache.Cache = cache.NewInstrumentedCache(cache.NewMemoryCache(cache.DefaultMemorySize))
+	StmtCache *PreparedStmts
 )
 
-func ConnectDB() *gorm.DB {
-	user := os.Getenv("DB_USER")
-	if user == "" {
-		user = "root"
-	}
-	pass := os.Getenv("DB_PASSWORD")
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "127.0.0.1"
-	}
-	port := os.Getenv("DB_PORT")
-	if port == "" {
-		port = "3306"
-	}
-	name := os.Getenv("DB_NAME")
-	if name == "" {
-		name = "db_cms"
+// ConnectDB connects to the database of cfg and to Redis, falling back to an in-memory cache
+// without it; cfg and redisCfg must have been validated
+func ConnectDB(cfg Config, redisCfg RedisConfig) *gorm.DB {
+	dialect, err := UseDialect(cfg.Driver)
+	if err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", user, pass, host, port, name)
+	queryLog = cfg.QueryLog
+	countConfig = cfg.Count
+	openedMu.Lock()
+	opened = cfg
+	openedMu.Unlock()
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := openGorm(dialect, cfg.DSN())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -55,39 +64,170 @@ func ConnectDB() *gorm.DB {
 	if err := sqlDB.Ping(); err != nil {
 		log.Fatal("Failed to ping database:", err)
 	}
-	log.Println("Connected to MySQL database with GORM")
+	slog.Info("Connected to database with GORM", "dialect", dialect.Name())
 
-	// Connect Redis
-	redisHost := os.Getenv("REDIS_HOST")
-	if redisHost == "" {
-		redisHost = "127.0.0.1"
+	configurePool(sqlDB, "primary", cfg.Pool)
+
+	if err := checkSchema(sqlDB, cfg); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
 	}
-	redisPort := os.Getenv("REDIS_PORT")
-	if redisPort == "" {
-		redisPort = "6379"
+
+	ReplicaDB = connectReplica(cfg)
+	if ReplicaDB != nil {
+		configurePool(ReplicaDB, "replica", cfg.Pool)
 	}
-	redisAddr := fmt.Sprintf("%s:%s", redisHost, redisPort)
-	redisPass := os.Getenv("REDIS_PASSWORD")
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPass,
-		DB:       0,
-	})
+	// Connect Redis
+	var redisConnected bool
+	cache.LoadTTLConfig()
+	Cache, redisConnected = connectCache(redisCfg)
+	Cache = cache.NewInstrumentedCache(Cache)
 
-	if err := RedisClient.Ping(RedisClient.Context()).Err(); err != nil {
-		log.Printf("Failed to connect to Redis: %v", err)
-	} else {
-		log.Println("Connected to Redis")
+	// Drop related cache keys whenever an entity changes; with Redis attached the
+	// events also reach other instances so their in-process tiers are cleared too
+	cache.RegisterInvalidation(events.Default, Cache)
+	if redisConnected {
+		events.Default.AttachRedis(RedisClient, events.DefaultChannel)
+		slog.Info("Attached cache invalidation bus to Redis pub/sub")
 	}
 
-	// Initialize cache wrapper
-	Cache = cache.NewCache(RedisClient)
-	log.Println("Initialized Redis cache wrapper")
+	// Live stream (/api/events/stream): entity changes arrive through the bus above, which
+	// already crosses instances; audit entries are broadcast over the hub's own channel
+	broadcast.Default.FollowEntityEvents(events.Default)
+	if redisConnected {
+		broadcast.Default.AttachRedis(RedisClient, broadcast.DefaultChannel)
+		// Notifications reach the user's /ws connections on whichever instance holds them
+		notify.Default.AttachRedis(RedisClient, notify.DefaultChannel)
+		// Maintenance mode is switched for every instance at once
+		maintenance.Default.AttachRedis(RedisClient, maintenance.DefaultKey)
+		// So are the IP lists administrators set, which also outlive restarts there
+		ipfilter.Default.AttachRedis(RedisClient, ipfilter.DefaultKey)
+		// Authentication failures are counted per address across instances
+		secevents.Default.AttachRedis(RedisClient, secevents.DefaultPrefix)
+		// Background jobs run on one instance at a time
+		lock.Default.AttachRedis(RedisClient, lock.DefaultPrefix)
+		// Rate limit budgets hold across instances
+		ratelimit.Default.AttachRedis(RedisClient, ratelimit.DefaultPrefix)
+		// A solved challenge is accepted once, whichever instance it is answered on
+		challenge.Default.AttachRedis(RedisClient, challenge.DefaultPrefix)
+	}
 
 	// Initialize prepared statements cache
 	StmtCache = NewPreparedStmts(sqlDB)
-	log.Println("Initialized prepared statements cache")
+	slog.Info("Initialized prepared statements cache")
 
 	return db
 }
+
+// Close releases what ConnectDB opened: the Redis subscriptions and client, the prepared
+// statements, the replica and the primary pool. Call it once no request is running.
+func Close(db *gorm.DB) error {
+	events.Default.Close()
+	broadcast.Default.Close()
+	notify.Default.Close()
+	maintenance.Default.Close()
+	ipfilter.Default.Close()
+
+	var errs []error
+	if StmtCache != nil {
+		errs = append(errs, StmtCache.Close())
+	}
+	if ReplicaDB != nil {
+		errs = append(errs, ReplicaDB.Close())
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		errs = append(errs, sqlDB.Close())
+	}
+	if RedisClient != nil {
+		errs = append(errs, RedisClient.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openGorm opens GORM on the dialect's instrumented driver. PostgreSQL connections are
+// opened first so GORM and the raw SQL layer share one pool.
+func openGorm(dialect Dialect, dsn string) (*gorm.DB, error) {
+	config := &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+	}
+	if dialect.Name() != DriverPostgres {
+		return gorm.Open(mysql.New(mysql.Config{DriverName: dialect.DriverName(), DSN: dsn}), config)
+	}
+
+	sqlDB, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), config)
+}
+
+// mysqlDSN formats a go-sql-driver DSN with the options the models rely on
+func mysqlDSN(user, pass, host, port, name string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", user, pass, host, port, name)
+}
+
+// checkSchema verifies every embedded migration has been applied.
+// DB_AUTO_MIGRATE=true applies pending migrations first; DB_MIGRATION_CHECK=false skips the check.
+func checkSchema(sqlDB *sql.DB, cfg Config) error {
+	if !cfg.MigrationCheck {
+		slog.Warn("Schema migration check disabled via DB_MIGRATION_CHECK")
+		return nil
+	}
+
+	fsys, err := migrations.ForDriver(Current.Name())
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(sqlDB, fsys)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if cfg.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("Applied pending migrations", "count", applied)
+	}
+
+	if err := migrator.Check(ctx); err != nil {
+		return fmt.Errorf("%w (run: go run ./cmd/migrate up)", err)
+	}
+	slog.Info("Database schema is up to date")
+	return nil
+}
+
+// connectCache connects to Redis and returns a Redis-backed cache and whether Redis is in use.
+// Falls back to an in-memory cache when Redis is disabled or unreachable so the API keeps working.
+func connectCache(cfg RedisConfig) (cache.Cache, bool) {
+	if !cfg.Enabled {
+		slog.Info("Redis disabled via REDIS_ENABLED, using in-memory cache")
+		return cache.NewMemoryCache(cfg.MemorySize), false
+	}
+
+	client, err := NewRedisClient(cfg)
+	if err != nil {
+		slog.Error("Invalid Redis configuration, falling back to in-memory cache", "error", err)
+		return cache.NewMemoryCache(cfg.MemorySize), false
+	}
+	RedisClient = client
+
+	if err := RedisClient.Ping(RedisClient.Context()).Err(); err != nil {
+		slog.Warn("Failed to connect to Redis, falling back to in-memory cache", "error", err)
+		return cache.NewMemoryCache(cfg.MemorySize), false
+	}
+
+	slog.Info("Connected to Redis", "mode", cfg.Mode)
+
+	if cfg.LocalSize <= 0 || cfg.LocalTTL <= 0 {
+		slog.Info("Initialized Redis cache wrapper")
+		return cache.NewRedisCache(RedisClient), true
+	}
+
+	slog.Info("Initialized two-tier cache in front of Redis", "local_size", cfg.LocalSize, "local_ttl", cfg.LocalTTL)
+	return cache.NewTieredCache(cache.NewRedisCache(RedisClient), cfg.LocalSize, cfg.LocalTTL, cache.HotKeyPrefixes...), true
+}
@@ -0,0 +1,61 @@
+// Package dbtest connects integration tests to a migrated database, the one the CI database
+// job brings up for each engine. Tests using it are skipped unless DB_INTEGRATION is true,
+// so go test ./... needs no database.
+//
+//	DB_INTEGRATION=true DB_DRIVER=postgres DB_PORT=5432 DB_USER=postgres DB_PASSWORD=secret \
+//	  go test -run Integration ./...
+package dbtest
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/database"
+)
+
+// EnvEnabled turns the integration tests on
+const EnvEnabled = "DB_INTEGRATION"
+
+// Open returns a connection to the database of DB_DRIVER, DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD and DB_NAME, with its dialect made database.Current, closed when t ends. It
+// skips t unless DB_INTEGRATION is true. The schema must already be migrated.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	if on, _ := strconv.ParseBool(os.Getenv(EnvEnabled)); !on {
+		t.Skip(EnvEnabled + " is not true")
+	}
+
+	// Only the environment counts: the tests do not run where configs/config.yaml is
+	cfg, err := config.Read("")
+	if err != nil {
+		t.Fatalf("load configuration: %v", err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		t.Fatalf("invalid database configuration: %v", err)
+	}
+	dialect, err := database.UseDialect(cfg.Database.Driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(dialect.DriverName(), cfg.Database.DSN())
+	if err != nil {
+		t.Fatalf("open %s: %v", dialect.Name(), err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connect to %s: %v", dialect.Name(), err)
+	}
+	return db
+}
+
+// Exec runs query on db and fails t on an error; for fixtures and cleanups
+func Exec(t testing.TB, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
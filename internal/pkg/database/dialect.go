@@ -0,0 +1,270 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Supported DB_DRIVER values
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+)
+
+// Dialect hides the SQL differences between the supported engines.
+//
+// Queries throughout the repositories and handlers are written once, with ? placeholders
+// and SQL both engines accept (NOW(), LIMIT/OFFSET, row comparisons). The PostgreSQL
+// driver registered by this package rebinds the placeholders, so only the constructs
+// that genuinely differ go through a Dialect method.
+type Dialect interface {
+	// Name is the DB_DRIVER value selecting this dialect
+	Name() string
+	// DriverName is the database/sql driver to open connections with
+	DriverName() string
+	// DefaultPort is used when DB_PORT is not set
+	DefaultPort() string
+	// DSN formats the data source name of cfg for the driver
+	DSN(cfg Config) string
+	// Rebind converts ? placeholders to the engine's native form
+	Rebind(query string) string
+	// MD5 returns an expression hashing expr as text, whatever its column type
+	MD5(expr string) string
+	// EstimateRowsQuery returns a query yielding the statistics-based row estimate of the
+	// table named by its single ? argument
+	EstimateRowsQuery() string
+	// InsertID runs an INSERT into a table with an auto-generated id column and returns the new id
+	InsertID(ctx context.Context, q Execer, query string, args ...interface{}) (int64, error)
+	// TablesQuery returns a query yielding the names of the base tables of the current
+	// schema, in order
+	TablesQuery() string
+	// QuoteIdent quotes a table or column name
+	QuoteIdent(name string) string
+	// QuoteString returns s as a string literal
+	QuoteString(s string) string
+	// BinaryLiteral returns b as a literal of the engine's binary type
+	BinaryLiteral(b []byte) string
+	// ForeignKeyChecks returns the statement turning foreign key checks of the session on
+	// or off, as a restore of a dump needs while it loads tables in any order
+	ForeignKeyChecks(on bool) string
+	// ResetSequence returns a statement moving the sequence of the id column of table past
+	// its rows after they were inserted with their ids, or "" when the engine does that by
+	// itself
+	ResetSequence(table string) string
+}
+
+// Execer is the part of *sql.DB, *sql.Tx and *sql.Conn InsertID needs
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Current is the dialect of the configured database; set by UseDialect
+var Current Dialect = mysqlDialect{}
+
+// UseDialect makes the dialect of driver, a DB_DRIVER value, Current
+func UseDialect(driver string) (Dialect, error) {
+	dialect, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	Current = dialect
+	return dialect, nil
+}
+
+// dialectFor returns the dialect of driver, mysql when it is empty
+func dialectFor(driver string) (Dialect, error) {
+	switch driver = strings.ToLower(driver); driver {
+	case DriverMySQL, "":
+		return mysqlDialect{}, nil
+	case DriverPostgres, "postgresql", "pgx":
+		return postgresDialect{}, nil
+	}
+	return nil, fmt.Errorf("unsupported DB_DRIVER %q (want %s or %s)", driver, DriverMySQL, DriverPostgres)
+}
+
+// InsertID runs an INSERT through the current dialect and translates constraint errors
+func InsertID(ctx context.Context, q Execer, query string, args ...interface{}) (int64, error) {
+	id, err := Current.InsertID(ctx, q, query, args...)
+	return id, TranslateError(err)
+}
+
+// mysqlDialect is the original engine; queries run unchanged
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string        { return DriverMySQL }
+func (mysqlDialect) DriverName() string  { return mysqlDriverName }
+func (mysqlDialect) DefaultPort() string { return "3306" }
+
+func (mysqlDialect) DSN(cfg Config) string {
+	return mysqlDSN(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+}
+
+func (mysqlDialect) Rebind(query string) string { return query }
+
+func (mysqlDialect) MD5(expr string) string { return "MD5(" + expr + ")" }
+
+func (mysqlDialect) EstimateRowsQuery() string {
+	return "SELECT COALESCE(TABLE_ROWS, -1) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+}
+
+func (mysqlDialect) InsertID(ctx context.Context, q Execer, query string, args ...interface{}) (int64, error) {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (mysqlDialect) TablesQuery() string {
+	return "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME"
+}
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteString also escapes backslashes, which MySQL reads as escapes unless the
+// NO_BACKSLASH_ESCAPES mode is set
+func (mysqlDialect) QuoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''", "\x00", `\0`, "\x1a", `\Z`).Replace(s) + "'"
+}
+
+func (mysqlDialect) BinaryLiteral(b []byte) string { return fmt.Sprintf("X'%x'", b) }
+
+func (mysqlDialect) ForeignKeyChecks(on bool) string {
+	if on {
+		return "SET FOREIGN_KEY_CHECKS = 1"
+	}
+	return "SET FOREIGN_KEY_CHECKS = 0"
+}
+
+// ResetSequence is not needed: inserting an id moves AUTO_INCREMENT past it
+func (mysqlDialect) ResetSequence(string) string { return "" }
+
+// postgresDialect targets PostgreSQL through pgx
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string        { return DriverPostgres }
+func (postgresDialect) DriverName() string  { return postgresDriverName }
+func (postgresDialect) DefaultPort() string { return "5432" }
+
+// DSN builds a keyword/value connection string
+func (postgresDialect) DSN(cfg Config) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, quoteDSNValue(cfg.Password), cfg.Name, cfg.SSLMode)
+}
+
+func (postgresDialect) Rebind(query string) string { return rebindDollar(query) }
+
+func (postgresDialect) MD5(expr string) string { return "MD5(CAST(" + expr + " AS TEXT))" }
+
+// EstimateRowsQuery reads pg_class.reltuples, which is -1 until the table is first analyzed
+func (postgresDialect) EstimateRowsQuery() string {
+	return "SELECT CAST(reltuples AS BIGINT) FROM pg_class WHERE oid = to_regclass(?)"
+}
+
+// InsertID uses RETURNING because pgx does not support LastInsertId
+func (postgresDialect) InsertID(ctx context.Context, q Execer, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+func (postgresDialect) TablesQuery() string {
+	return "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name"
+}
+
+func (postgresDialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteString relies on standard_conforming_strings, on by default, so only quotes are doubled
+func (postgresDialect) QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (postgresDialect) BinaryLiteral(b []byte) string { return fmt.Sprintf(`'\x%x'::bytea`, b) }
+
+// ForeignKeyChecks switches triggers, foreign keys among them, off for the session, which
+// takes a superuser
+func (postgresDialect) ForeignKeyChecks(on bool) string {
+	if on {
+		return "SET session_replication_role = DEFAULT"
+	}
+	return "SET session_replication_role = replica"
+}
+
+// ResetSequence moves the sequence behind the id column, if it has one, to the largest id;
+// setval ignores the NULL of a column without one, or of an empty table
+func (d postgresDialect) ResetSequence(table string) string {
+	return fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, 'id'), MAX(id)) FROM %s",
+		d.QuoteString(d.QuoteIdent(table)), d.QuoteIdent(table))
+}
+
+// quoteDSNValue quotes a keyword/value connection string value when it needs it
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// rebindDollar rewrites ? placeholders as $1..$n, leaving quoted strings,
+// quoted identifiers and comments untouched
+func rebindDollar(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := i + 1
+			for end < len(query) {
+				if query[end] == ch {
+					// A doubled quote is an escaped quote inside the literal
+					if end+1 < len(query) && query[end+1] == ch {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end >= len(query) {
+				end = len(query) - 1
+			}
+			b.WriteString(query[i : end+1])
+			i = end
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+2+end+2])
+			i += 2 + end + 1
+		case ch == '?':
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
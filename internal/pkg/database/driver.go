@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Drivers registered by this package. Both wrap the real driver so every query path
+// (GORM, repositories, raw handler SQL, prepared statements, migrations) is timed for the
+// slow query log and request traces. The PostgreSQL one also rebinds ? placeholders to $n
+// so SQL is written once for both engines.
+const (
+	mysqlDriverName    = "mysql-instrumented"
+	postgresDriverName = "pgx-rebind"
+)
+
+func init() {
+	sql.Register(mysqlDriverName, wrappedDriver{parent: &mysql.MySQLDriver{}})
+	sql.Register(postgresDriverName, wrappedDriver{parent: stdlib.GetDefaultDriver(), rebind: rebindDollar})
+}
+
+// wrappedDriver opens connections that rebind and observe queries
+type wrappedDriver struct {
+	parent driver.Driver
+	rebind func(string) string // nil leaves queries unchanged
+}
+
+// Open opens a connection with the parent driver and wraps it, with the credentials last
+// set by SetCredentials
+func (d wrappedDriver) Open(name string) (driver.Conn, error) {
+	if current, ok := rotatedDSNs.Load(name); ok {
+		name = current.(string)
+	}
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, rebind: d.rebind}, nil
+}
+
+// opened is the configuration the pools were opened with, and rotatedDSNs maps their data
+// source names to the ones new connections use once credentials rotate
+var (
+	openedMu    sync.Mutex
+	opened      Config
+	rotatedDSNs sync.Map
+)
+
+// SetCredentials makes new connections of the primary and replica pools log in with the
+// user and password of cfg, e.g. rotated in a secrets manager. Open connections keep their
+// session until DB_CONN_MAX_LIFETIME recycles them; every other setting needs a restart.
+func SetCredentials(cfg Config) {
+	openedMu.Lock()
+	defer openedMu.Unlock()
+	next := opened
+	next.User, next.Password = cfg.User, cfg.Password
+	next.Replica.DSN, next.Replica.User, next.Replica.Password = cfg.Replica.DSN, cfg.Replica.User, cfg.Replica.Password
+	rotatedDSNs.Store(opened.DSN(), next.DSN())
+	if dsn := opened.ReplicaDSN(); dsn != "" {
+		rotatedDSNs.Store(dsn, next.ReplicaDSN())
+	}
+}
+
+// wrappedConn forwards to the real connection after rebinding the query text
+type wrappedConn struct {
+	driver.Conn
+	rebind func(string) string
+}
+
+// bind applies the placeholder rewrite, if any
+func (c *wrappedConn) bind(query string) string {
+	if c.rebind == nil {
+		return query
+	}
+	return c.rebind(query)
+}
+
+// Prepare prepares a rebound statement
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.Prepare(c.bind(query))
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+// PrepareContext prepares a rebound statement
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	p, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return c.Prepare(query)
+	}
+	stmt, err := p.PrepareContext(ctx, c.bind(query))
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+// ExecContext executes a rebound statement
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := e.ExecContext(ctx, c.bind(query), args)
+	if err != driver.ErrSkip {
+		observeQuery(ctx, spanExec, query, args, start, err)
+	}
+	return result, err
+}
+
+// QueryContext runs a rebound query. The span covers the round trip until the first
+// rows are available, not the caller's iteration over them.
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, c.bind(query), args)
+	if err != driver.ErrSkip {
+		observeQuery(ctx, spanQuery, query, args, start, err)
+	}
+	return rows, err
+}
+
+// BeginTx starts a transaction with the requested options
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the connection is alive
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets the real driver encode its own argument types
+func (c *wrappedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+// ResetSession resets the connection before it is reused from the pool
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection may be reused
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// wrappedStmt observes executions of a prepared statement
+type wrappedStmt struct {
+	driver.Stmt
+	conn  *wrappedConn
+	query string // as written by the caller, before rebinding
+}
+
+// ExecContext executes the statement
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = e.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedToValues(args))
+	}
+	observeQuery(ctx, spanExec, s.query, args, start, err)
+	return result, err
+}
+
+// QueryContext runs the statement
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedToValues(args))
+	}
+	observeQuery(ctx, spanQuery, s.query, args, start, err)
+	return rows, err
+}
+
+// CheckNamedValue lets the real statement, or else its connection, encode argument types.
+// database/sql only asks the connection when the statement has no checker, so the
+// wrapper must fall back itself.
+func (s *wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(v)
+	}
+	return s.conn.CheckNamedValue(v)
+}
+
+// namedToValues adapts arguments for drivers without context-aware statements
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
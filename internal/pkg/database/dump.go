@@ -0,0 +1,177 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dumpBatch is how many rows one INSERT of a dump carries
+const dumpBatch = 100
+
+// binaryTypes are the column types whose values a dump writes as binary literals
+var binaryTypes = map[string]bool{
+	"BINARY": true, "VARBINARY": true, "BLOB": true, "TINYBLOB": true, "MEDIUMBLOB": true, "LONGBLOB": true, "BYTEA": true,
+}
+
+// DumpStats describes a finished dump
+type DumpStats struct {
+	Tables int
+	Rows   int64
+}
+
+// Dump writes the rows of every base table of the current schema to w as SQL INSERT
+// statements, skipping the tables in exclude, and calls progress after each table. It is a
+// logical backup of the data only: it is restored into a database the migrations brought to
+// the same schema version, and the tables are read one after the other, so a database
+// written to meanwhile is not captured at a single instant.
+func Dump(ctx context.Context, db *sql.DB, w io.Writer, header string, progress func(DumpStats), exclude ...string) (DumpStats, error) {
+	var stats DumpStats
+	skip := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	tables, err := listTables(ctx, db)
+	if err != nil {
+		return stats, err
+	}
+
+	bw := bufio.NewWriterSize(w, 64*1024)
+	for _, line := range strings.Split(header, "\n") {
+		fmt.Fprintf(bw, "-- %s\n", line)
+	}
+	fmt.Fprintf(bw, "\n%s;\n", Current.ForeignKeyChecks(false))
+	for _, table := range tables {
+		if skip[table] {
+			continue
+		}
+		n, err := dumpTable(ctx, db, bw, table)
+		stats.Rows += n
+		if err != nil {
+			return stats, fmt.Errorf("failed to dump %s: %w", table, err)
+		}
+		stats.Tables++
+		if progress != nil {
+			progress(stats)
+		}
+	}
+	fmt.Fprintf(bw, "\n%s;\n", Current.ForeignKeyChecks(true))
+	return stats, bw.Flush()
+}
+
+// listTables returns the base tables of the current schema
+func listTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, Current.TablesQuery())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// dumpTable writes the rows of table in batches of dumpBatch, returning how many it wrote
+func dumpTable(ctx context.Context, db *sql.DB, w io.Writer, table string) (int64, error) {
+	quoted := Current.QuoteIdent(table)
+	// The name comes from the catalog and is quoted, so formatting it into the query is safe
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+quoted)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	columns := make([]string, len(types))
+	binary := make([]bool, len(types))
+	hasID := false
+	for i, t := range types {
+		columns[i] = Current.QuoteIdent(t.Name())
+		binary[i] = binaryTypes[strings.ToUpper(t.DatabaseTypeName())]
+		hasID = hasID || t.Name() == "id"
+	}
+	insert := "INSERT INTO " + quoted + " (" + strings.Join(columns, ", ") + ") VALUES\n"
+
+	fmt.Fprintf(w, "\n-- %s\n", table)
+	values := make([]interface{}, len(types))
+	dest := make([]interface{}, len(types))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var n int64
+	literals := make([]string, len(types))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			literals[i] = sqlLiteral(v, binary[i])
+		}
+		sep := ",\n"
+		switch {
+		case n == 0:
+			sep = insert
+		case n%dumpBatch == 0:
+			sep = ";\n" + insert
+		}
+		if _, err := fmt.Fprintf(w, "%s(%s)", sep, strings.Join(literals, ", ")); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if n > 0 {
+		fmt.Fprint(w, ";\n")
+		if hasID {
+			if stmt := Current.ResetSequence(table); stmt != "" {
+				fmt.Fprintf(w, "%s;\n", stmt)
+			}
+		}
+	}
+	return n, nil
+}
+
+// sqlLiteral writes a scanned value as a literal both engines coerce to the column's type
+func sqlLiteral(v interface{}, binary bool) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return Current.QuoteString(v.Format("2006-01-02 15:04:05.999999"))
+	case []byte:
+		if binary {
+			return Current.BinaryLiteral(v)
+		}
+		return Current.QuoteString(string(v))
+	case string:
+		return Current.QuoteString(v)
+	default:
+		return Current.QuoteString(fmt.Sprint(v))
+	}
+}
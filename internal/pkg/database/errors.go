@@ -0,0 +1,134 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"adminbe/internal/pkg/utils"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MySQL server error codes translated by TranslateError
+const (
+	ErrCodeDuplicateEntry    = 1062 // ER_DUP_ENTRY
+	ErrCodeRowIsReferenced   = 1451 // ER_ROW_IS_REFERENCED_2: parent row still has children
+	ErrCodeNoReferencedRow   = 1452 // ER_NO_REFERENCED_ROW_2: child points at a missing parent
+	ErrCodeLockWaitTimeout   = 1205 // ER_LOCK_WAIT_TIMEOUT
+	ErrCodeDeadlock          = 1213 // ER_LOCK_DEADLOCK
+	ErrCodeBadNull           = 1048 // ER_BAD_NULL_ERROR
+	ErrCodeDataTooLong       = 1406 // ER_DATA_TOO_LONG
+	ErrCodeTruncatedWrongVal = 1366 // ER_TRUNCATED_WRONG_VALUE_FOR_FIELD
+)
+
+// PostgreSQL SQLSTATE codes translated by TranslateError
+const (
+	PgCodeUniqueViolation     = "23505"
+	PgCodeForeignKeyViolation = "23503"
+	PgCodeNotNullViolation    = "23502"
+	PgCodeStringTooLong       = "22001"
+	PgCodeInvalidText         = "22P02"
+	PgCodeDeadlock            = "40P01"
+	PgCodeSerialization       = "40001"
+	PgCodeLockNotAvailable    = "55P03"
+)
+
+// errorKind is an engine-neutral classification of a driver error
+type errorKind int
+
+const (
+	kindOther errorKind = iota
+	kindDuplicate
+	kindStillReferenced
+	kindMissingReference
+	kindInvalidValue
+	kindRetryable
+)
+
+// classify maps a MySQL error number or PostgreSQL SQLSTATE in err's chain to an errorKind.
+// AppErrors are left alone so translating twice is harmless.
+func classify(err error) errorKind {
+	var appErr *utils.AppError
+	if errors.As(err, &appErr) {
+		return kindOther
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case ErrCodeDuplicateEntry:
+			return kindDuplicate
+		case ErrCodeRowIsReferenced:
+			return kindStillReferenced
+		case ErrCodeNoReferencedRow:
+			return kindMissingReference
+		case ErrCodeBadNull, ErrCodeDataTooLong, ErrCodeTruncatedWrongVal:
+			return kindInvalidValue
+		case ErrCodeDeadlock, ErrCodeLockWaitTimeout:
+			return kindRetryable
+		}
+		return kindOther
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case PgCodeUniqueViolation:
+			return kindDuplicate
+		case PgCodeForeignKeyViolation:
+			// PostgreSQL uses one code for both directions; the detail tells them apart
+			if strings.Contains(pgErr.Detail, "still referenced") {
+				return kindStillReferenced
+			}
+			return kindMissingReference
+		case PgCodeNotNullViolation, PgCodeStringTooLong, PgCodeInvalidText:
+			return kindInvalidValue
+		case PgCodeDeadlock, PgCodeSerialization, PgCodeLockNotAvailable:
+			return kindRetryable
+		}
+	}
+	return kindOther
+}
+
+// IsDuplicateKey reports whether err is a unique/primary key violation
+func IsDuplicateKey(err error) bool {
+	return classify(err) == kindDuplicate
+}
+
+// IsForeignKeyViolation reports whether err is a foreign key violation in either direction
+func IsForeignKeyViolation(err error) bool {
+	kind := classify(err)
+	return kind == kindStillReferenced || kind == kindMissingReference
+}
+
+// IsDeadlock reports whether err is a deadlock or lock wait timeout that is worth retrying
+func IsDeadlock(err error) bool {
+	return classify(err) == kindRetryable
+}
+
+// TranslateError maps MySQL and PostgreSQL constraint and locking errors to typed AppErrors so
+// utils.HandleError answers with 409/400/503 instead of 500. Other errors are returned unchanged.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch classify(err) {
+	case kindDuplicate:
+		return utils.NewConflictError("Resource already exists", err)
+	case kindStillReferenced:
+		return utils.NewConflictError("Resource is still referenced by other records", err)
+	case kindMissingReference:
+		appErr := utils.NewValidationError("Referenced resource does not exist")
+		appErr.Internal = err
+		return appErr
+	case kindInvalidValue:
+		appErr := utils.NewValidationError("Invalid field value")
+		appErr.Internal = err
+		return appErr
+	case kindRetryable:
+		return utils.NewTransientError("Database is busy, please retry", err)
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Pool defaults, also the defaults of the DB_* pool settings
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 10
+	DefaultConnMaxLifetime = 5 * time.Minute
+	DefaultConnMaxIdleTime = 2 * time.Minute
+)
+
+// PoolConfig holds connection pool limits
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25" min:"0"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10" min:"0"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"5m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" default:"2m"`
+}
+
+// configurePool applies cfg to db and exports its sql.DBStats (open/in-use/idle connections,
+// wait count and wait duration) on the metrics endpoint under db_name=name
+func configurePool(db *sql.DB, name string, cfg PoolConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := metrics.Registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		slog.Error("Failed to register pool metrics", "db_name", name, "error", err)
+	}
+
+	slog.Info("Configured connection pool", "db_name", name, "max_open", cfg.MaxOpenConns, "max_idle", cfg.MaxIdleConns,
+		"max_lifetime", cfg.ConnMaxLifetime, "max_idle_time", cfg.ConnMaxIdleTime)
+}
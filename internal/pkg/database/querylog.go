@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/tracing"
+)
+
+// Span names recorded for database calls
+const (
+	spanQuery = "db.query"
+	spanExec  = "db.exec"
+)
+
+// maxLoggedQueryLen truncates long statements in logs and spans
+const maxLoggedQueryLen = 1000
+
+// QueryLogConfig controls slow query logging and query spans
+type QueryLogConfig struct {
+	// SlowThreshold logs queries at least this slow; 0 disables the log
+	SlowThreshold time.Duration `yaml:"slow_threshold" env:"DB_SLOW_QUERY_THRESHOLD" default:"200ms"`
+	// TraceQueries records a span per query on the request trace
+	TraceQueries bool `yaml:"trace_queries" env:"DB_TRACE_QUERIES" default:"true"`
+}
+
+// queryLog holds the active settings; replaced once at startup by ConnectDB
+var queryLog = QueryLogConfig{SlowThreshold: 200 * time.Millisecond, TraceQueries: true}
+
+// observeQuery records a finished driver call on the request trace and logs it when slow.
+// Bound arguments are never logged, only their types, so credentials and personal data stay out of logs.
+func observeQuery(ctx context.Context, name, query string, args []driver.NamedValue, start time.Time, err error) {
+	elapsed := time.Since(start)
+	slow := queryLog.SlowThreshold > 0 && elapsed >= queryLog.SlowThreshold
+
+	trace := tracing.FromContext(ctx)
+	if !slow && (trace == nil || !queryLog.TraceQueries) {
+		return
+	}
+
+	statement := normalizeQuery(query)
+	if slow {
+		logging.FromContext(ctx).WarnContext(ctx, "Slow query", "duration_ms", float64(elapsed.Microseconds())/1000, "query", statement, "args", redactArgs(args))
+	}
+
+	if trace != nil && queryLog.TraceQueries {
+		span := tracing.Span{
+			Name:     name,
+			Start:    start,
+			Duration: elapsed,
+			Attrs:    map[string]string{"db.statement": statement, "db.system": Current.Name()},
+			Slow:     slow,
+		}
+		if err != nil {
+			span.Error = err.Error()
+		}
+		trace.AddSpan(span)
+	}
+}
+
+// normalizeQuery collapses whitespace and truncates the statement for logging
+func normalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQueryLen {
+		query = query[:maxLoggedQueryLen] + "..."
+	}
+	return query
+}
+
+// redactArgs describes bound arguments by type only, e.g. [int64 string <nil>]
+func redactArgs(args []driver.NamedValue) string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		if arg.Value == nil {
+			types[i] = "<nil>"
+			continue
+		}
+		types[i] = fmt.Sprintf("%T", arg.Value)
+	}
+	return "[" + strings.Join(types, " ") + "]"
+}
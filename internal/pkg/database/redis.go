@@ -0,0 +1,73 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis topologies selected with REDIS_MODE
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// NewRedisClient builds a Redis client for the topology cfg selects
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.addrs(),
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+	}
+
+	if cfg.TLS {
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	switch cfg.Mode {
+	case "", RedisModeStandalone:
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("REDIS_MASTER_NAME is required when REDIS_MODE=%s", RedisModeSentinel)
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown REDIS_MODE %q", cfg.Mode)
+	}
+}
+
+// redisTLSConfig builds the TLS configuration from REDIS_TLS_CA_FILE and REDIS_TLS_SKIP_VERIFY
+func redisTLSConfig(cfg RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+	}
+
+	if caFile := cfg.TLSCAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read REDIS_TLS_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in REDIS_TLS_CA_FILE %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// ReplicaDB is an optional read-only connection; nil when no replica is configured
+var ReplicaDB *sql.DB
+
+// replicaKey marks a context whose reads may be served by the replica
+type replicaKey struct{}
+
+// WithReplica marks ctx so reads made with it may go to the read replica.
+// Use it only for queries that tolerate replication lag (lists, reports, reference data).
+func WithReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// UseReplica reports whether ctx was marked by WithReplica
+func UseReplica(ctx context.Context) bool {
+	use, _ := ctx.Value(replicaKey{}).(bool)
+	return use
+}
+
+// Reader returns the replica when ctx allows it and one is connected, otherwise primary
+func Reader(ctx context.Context, primary *sql.DB) *sql.DB {
+	if ReplicaDB != nil && UseReplica(ctx) {
+		return ReplicaDB
+	}
+	return primary
+}
+
+// connectReplica opens the read replica if one is configured.
+// A replica that cannot be reached is logged and skipped so reads fall back to the primary.
+func connectReplica(cfg Config) *sql.DB {
+	dsn := cfg.ReplicaDSN()
+	if dsn == "" {
+		return nil
+	}
+
+	replica, err := sql.Open(Current.DriverName(), dsn)
+	if err != nil {
+		slog.Error("Failed to open read replica, reads will use the primary", "error", err)
+		return nil
+	}
+	if err := replica.Ping(); err != nil {
+		slog.Error("Failed to ping read replica, reads will use the primary", "error", err)
+		replica.Close()
+		return nil
+	}
+
+	slog.Info("Connected to read replica", "dialect", Current.Name())
+	return replica
+}
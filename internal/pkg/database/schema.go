@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"adminbe/internal/pkg/migrate"
+	"adminbe/migrations"
+)
+
+// RequiredRelations are the tables and views the API queries; the startup self-check
+// refuses to serve without any of them
+var RequiredRelations = []string{
+	"users", "roles", "role_inheritances", "user_roles", "menu", "role_menu", "user_menu",
+	"audit_logs", "webhooks", "webhook_deliveries", "security_events", "mail_deliveries", "notifications",
+	"prayer_subscriptions", "push_devices", "attachments", "announcements",
+	"user_phones", "otp_codes", "sms_messages", "location_imports", "location_import_changes", "export_jobs", "db_backups",
+	"data_quality_checks", "data_quality_findings", "tenants", "tenant_settings",
+	"report_schedules", "report_schedule_events", "calendar_credentials",
+	"app_province", "app_city", "data_lintang_kota_cms_new", "hisab_tgl_puasa",
+	"menu_navigation", "v_roles",
+}
+
+// SchemaVersion returns the newest applied migration and the embedded migrations still
+// pending. A migration left dirty is reported as an error.
+func SchemaVersion(ctx context.Context, sqlDB *sql.DB) (version int64, pending []string, err error) {
+	fsys, err := migrations.ForDriver(Current.Name())
+	if err != nil {
+		return 0, nil, err
+	}
+	migrator, err := migrate.New(sqlDB, fsys)
+	if err != nil {
+		return 0, nil, err
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, s := range statuses {
+		switch {
+		case s.Dirty:
+			return 0, nil, fmt.Errorf("%w: migration %d_%s did not complete", migrate.ErrDirty, s.Version, s.Name)
+		case s.AppliedAt == nil:
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		default:
+			version = max(version, s.Version)
+		}
+	}
+	return version, pending, nil
+}
+
+// MissingRelations returns the RequiredRelations that cannot be queried
+func MissingRelations(ctx context.Context, sqlDB *sql.DB) ([]string, error) {
+	// A connection error would otherwise report every table missing
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range RequiredRelations {
+		// Names are constants, so formatting them into the query is safe
+		rows, err := sqlDB.QueryContext(ctx, "SELECT 1 FROM "+name+" WHERE 1 = 0")
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			missing = append(missing, name)
+			continue
+		}
+		rows.Close()
+	}
+	return missing, nil
+}
@@ -0,0 +1,148 @@
+// Package deprecation is the registry of deprecated routes: when each was deprecated, when
+// it stops working and what replaces it. DeprecationMiddleware announces this on every
+// response of a registered route, and the registry counts who still calls it, so
+// /api/admin/deprecations shows when a route is safe to remove. Usage is per process.
+package deprecation
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxCallers bounds the distinct callers tracked per route
+	maxCallers = 1000
+	// topCallers is how many of them List reports
+	topCallers = 10
+)
+
+// Route describes a deprecated route
+type Route struct {
+	Method string `json:"method"`
+	// Path is the route template, e.g. "/api/users/:id"
+	Path  string    `json:"path"`
+	Since time.Time `json:"since"`
+	// Sunset is when the route stops working; zero while undecided
+	Sunset time.Time `json:"-"`
+	// Successor is the path replacing the route, if any
+	Successor string `json:"successor,omitempty"`
+}
+
+// Caller is a client still calling a deprecated route
+type Caller struct {
+	// ID is the user ID, or "anonymous"
+	ID       string    `json:"id"`
+	Requests uint64    `json:"requests"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Usage is a deprecated route with its calls since the process started
+type Usage struct {
+	Route
+	Sunset   *time.Time `json:"sunset,omitempty"`
+	Requests uint64     `json:"requests"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	// Callers counts distinct callers, up to 1000; TopCallers are the busiest ten
+	Callers    int      `json:"callers"`
+	TopCallers []Caller `json:"top_callers"`
+}
+
+type entry struct {
+	route    Route
+	requests uint64
+	lastSeen time.Time
+	callers  map[string]*Caller
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*entry{}
+)
+
+func key(method, path string) string {
+	return method + " " + path
+}
+
+// Register flags a route as deprecated, or replaces its metadata keeping its usage
+func Register(r Route) {
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(r.Method, r.Path)
+	if e, ok := registry[k]; ok {
+		e.route = r
+		return
+	}
+	registry[k] = &entry{route: r, callers: map[string]*Caller{}}
+}
+
+// Lookup returns the metadata of a deprecated route
+func Lookup(method, path string) (Route, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := registry[key(method, path)]
+	if !ok {
+		return Route{}, false
+	}
+	return e.route, true
+}
+
+// Record counts a call of a deprecated route by caller; calls of other routes are ignored
+func Record(method, path, caller string, at time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	e, ok := registry[key(method, path)]
+	if !ok {
+		return
+	}
+	e.requests++
+	e.lastSeen = at
+	c, ok := e.callers[caller]
+	if !ok {
+		if len(e.callers) >= maxCallers {
+			return
+		}
+		c = &Caller{ID: caller}
+		e.callers[caller] = c
+	}
+	c.Requests++
+	c.LastSeen = at
+}
+
+// List returns every deprecated route with its usage, by path then method
+func List() []Usage {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Usage, 0, len(registry))
+	for _, e := range registry {
+		u := Usage{Route: e.route, Requests: e.requests, Callers: len(e.callers), TopCallers: []Caller{}}
+		if !e.route.Sunset.IsZero() {
+			sunset := e.route.Sunset
+			u.Sunset = &sunset
+		}
+		if !e.lastSeen.IsZero() {
+			lastSeen := e.lastSeen
+			u.LastSeen = &lastSeen
+		}
+		for _, c := range e.callers {
+			u.TopCallers = append(u.TopCallers, *c)
+		}
+		sort.Slice(u.TopCallers, func(i, j int) bool {
+			if u.TopCallers[i].Requests != u.TopCallers[j].Requests {
+				return u.TopCallers[i].Requests > u.TopCallers[j].Requests
+			}
+			return u.TopCallers[i].ID < u.TopCallers[j].ID
+		})
+		if len(u.TopCallers) > topCallers {
+			u.TopCallers = u.TopCallers[:topCallers]
+		}
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
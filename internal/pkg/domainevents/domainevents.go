@@ -0,0 +1,237 @@
+// Package domainevents publishes domain events (UserCreated, RoleAssigned, ReportCompleted)
+// to a message broker for consumers outside this codebase. Services publish through the
+// EventPublisher interface; which broker, if any, receives the events is configuration.
+//
+// Events are CloudEvents 1.0 in structured JSON mode. Publishing is asynchronous and best
+// effort: a request never waits for the broker, and events are dropped (and counted) when
+// the broker is down long enough for the queue to fill.
+package domainevents
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event types
+const (
+	TypeUserCreated     = "UserCreated"
+	TypeRoleAssigned    = "RoleAssigned"
+	TypeReportCompleted = "ReportCompleted"
+)
+
+// Source is the CloudEvents source of every event published here
+const Source = "adminbe"
+
+var published = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "domain_events",
+	Name:      "published_total",
+	Help:      "Domain events by type and result: ok, error (the broker refused it), or dropped when the queue was full.",
+}, []string{"type", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(published)
+}
+
+// Event is a domain event as consumers receive it
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"` // ID of the entity the event is about
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+}
+
+// NewEvent builds an event of type typ about subject, carrying data
+func NewEvent(typ, subject string, data any) Event {
+	return Event{
+		SpecVersion:     "1.0",
+		ID:              newID(),
+		Source:          Source,
+		Type:            typ,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// EventPublisher hands domain events to a broker
+type EventPublisher interface {
+	// Publish sends e. Implementations may queue it and return before it reaches the broker.
+	Publish(ctx context.Context, e Event) error
+	// Close flushes what is queued and releases the broker connection
+	Close() error
+}
+
+// UserCreatedData is the data of a UserCreated event
+type UserCreatedData struct {
+	UserID   uint64 `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Status   uint8  `json:"status"`
+	RoleIDs  []uint `json:"role_ids"`
+}
+
+// RoleAssignedData is the data of a RoleAssigned event
+type RoleAssignedData struct {
+	UserID uint64 `json:"user_id"`
+	RoleID uint   `json:"role_id"`
+}
+
+// ReportCompletedData is the data of a ReportCompleted event
+type ReportCompletedData struct {
+	ReportPath   string  `json:"report_path"`
+	OutputFormat string  `json:"output_format"`
+	Bytes        int     `json:"bytes"`
+	DurationMs   float64 `json:"duration_ms"`
+	UserID       *uint64 `json:"user_id,omitempty"`
+}
+
+// Config selects and configures the broker
+type Config struct {
+	// Broker is "none" (the default; events are discarded), "nats" or "kafka"
+	Broker string `yaml:"broker" env:"EVENT_BROKER" default:"none"`
+	// URL is the NATS server URL, or the comma-separated Kafka bootstrap brokers
+	URL string `yaml:"url" env:"EVENT_BROKER_URL"`
+	// Topic is the Kafka topic, or the NATS subject prefix (events go to <Topic>.<Type>)
+	Topic string `yaml:"topic" env:"EVENT_TOPIC" default:"adminbe.events"`
+	// QueueSize is how many events may wait for the broker
+	QueueSize int `yaml:"queue_size" env:"EVENT_QUEUE_SIZE" default:"1000" min:"1"`
+}
+
+// Broker names
+const (
+	BrokerNone  = "none"
+	BrokerNATS  = "nats"
+	BrokerKafka = "kafka"
+)
+
+// Validate requires a known Broker, in any case, and a URL for a real one
+func (c *Config) Validate() error {
+	c.Broker = strings.ToLower(c.Broker)
+	switch c.Broker {
+	case BrokerNone:
+	case BrokerNATS, BrokerKafka:
+		if c.URL == "" {
+			return fmt.Errorf("EVENT_BROKER_URL is required for EVENT_BROKER=%s", c.Broker)
+		}
+	default:
+		return fmt.Errorf("unknown EVENT_BROKER %q; use none, nats or kafka", c.Broker)
+	}
+	return nil
+}
+
+// New connects to the configured broker and returns a publisher that queues events for it
+func New(cfg Config) (EventPublisher, error) {
+	var sender sender
+	var err error
+	switch cfg.Broker {
+	case BrokerNone, "":
+		return Nop(), nil
+	case BrokerNATS:
+		sender, err = newNATSSender(cfg.URL, cfg.Topic)
+	case BrokerKafka:
+		sender, err = newKafkaSender(strings.Split(cfg.URL, ","), cfg.Topic)
+	default:
+		return nil, fmt.Errorf("unknown broker %q", cfg.Broker)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newAsyncPublisher(sender, cfg.QueueSize), nil
+}
+
+// Nop returns a publisher that discards every event
+func Nop() EventPublisher {
+	return nopPublisher{}
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(context.Context, Event) error { return nil }
+func (nopPublisher) Close() error                         { return nil }
+
+// sender delivers one encoded event to a broker
+type sender interface {
+	send(ctx context.Context, e Event, body []byte) error
+	close() error
+}
+
+// sendTimeout bounds one delivery to the broker
+const sendTimeout = 10 * time.Second
+
+// asyncPublisher queues events and sends them from a single goroutine, in order
+type asyncPublisher struct {
+	sender sender
+	queue  chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newAsyncPublisher(s sender, queueSize int) *asyncPublisher {
+	p := &asyncPublisher{sender: s, queue: make(chan Event, max(queueSize, 1)), done: make(chan struct{})}
+	go p.run()
+	return p
+}
+
+// Publish queues e without blocking
+func (p *asyncPublisher) Publish(ctx context.Context, e Event) error {
+	select {
+	case p.queue <- e:
+		return nil
+	default:
+		published.WithLabelValues(e.Type, "dropped").Inc()
+		return fmt.Errorf("event queue full, dropped %s %s", e.Type, e.ID)
+	}
+}
+
+func (p *asyncPublisher) run() {
+	defer close(p.done)
+	for e := range p.queue {
+		body, err := json.Marshal(e)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			err = p.sender.send(ctx, e, body)
+			cancel()
+		}
+		if err != nil {
+			slog.Warn("Failed to publish domain event", "type", e.Type, "id", e.ID, "error", err)
+			published.WithLabelValues(e.Type, "error").Inc()
+			continue
+		}
+		published.WithLabelValues(e.Type, "ok").Inc()
+	}
+}
+
+// Close sends what is queued, then closes the broker connection. Publish must not be
+// called afterwards.
+func (p *asyncPublisher) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.queue)
+		<-p.done
+		err = p.sender.close()
+	})
+	return err
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,39 @@
+package domainevents
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaSender writes every event to one topic, keyed by subject so the events of an entity
+// stay in order on one partition
+type kafkaSender struct {
+	writer *kafka.Writer
+}
+
+func newKafkaSender(brokers []string, topic string) (*kafkaSender, error) {
+	return &kafkaSender{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: false,
+	}}, nil
+}
+
+func (s *kafkaSender) send(ctx context.Context, e Event, body []byte) error {
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(e.Subject),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/cloudevents+json")},
+			{Key: "ce_type", Value: []byte(e.Type)},
+			{Key: "ce_id", Value: []byte(e.ID)},
+		},
+	})
+}
+
+func (s *kafkaSender) close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,39 @@
+package domainevents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsSender publishes each event to <prefix>.<type>, e.g. adminbe.events.UserCreated
+type natsSender struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func newNATSSender(url, prefix string) (*natsSender, error) {
+	// Keep reconnecting for as long as the process runs; events queue meanwhile
+	conn, err := nats.Connect(url, nats.Name(Source), nats.MaxReconnects(-1), nats.RetryOnFailedConnect(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &natsSender{conn: conn, prefix: prefix}, nil
+}
+
+func (s *natsSender) send(ctx context.Context, e Event, body []byte) error {
+	msg := nats.NewMsg(s.prefix + "." + e.Type)
+	msg.Data = body
+	msg.Header.Set("Content-Type", "application/cloudevents+json")
+	msg.Header.Set("Nats-Msg-Id", e.ID)
+	if err := s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// Core NATS publishes are buffered; flushing surfaces a dead connection here
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *natsSender) close() error {
+	return s.conn.Drain()
+}
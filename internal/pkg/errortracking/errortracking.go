@@ -0,0 +1,116 @@
+// Package errortracking reports panics and server errors to a Sentry-compatible service
+// (Sentry, GlitchTip, self-hosted Sentry), so production failures are not only in the logs.
+//
+// Reporting is off unless ERROR_TRACKING_ENABLED=true; until Init succeeds every capture
+// is a no-op. Events carry the request method, URL, route and non-sensitive headers, the
+// request ID and the authenticated user ID, but never the body, cookies or Authorization.
+package errortracking
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Config selects where and how events are sent
+type Config struct {
+	Enabled     bool    `yaml:"enabled" env:"ERROR_TRACKING_ENABLED" default:"false"`
+	DSN         string  `yaml:"dsn" env:"SENTRY_DSN"`
+	Environment string  `yaml:"environment" env:"SENTRY_ENVIRONMENT"`
+	Release     string  `yaml:"release" env:"SENTRY_RELEASE"`
+	SampleRate  float64 `yaml:"sample_rate" env:"SENTRY_SAMPLE_RATE" default:"1"`
+}
+
+// Validate requires a DSN when reporting is enabled and a SampleRate from 0 to 1
+func (c *Config) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("invalid SENTRY_SAMPLE_RATE %v (want a number from 0 to 1)", c.SampleRate)
+	}
+	if c.Enabled && c.DSN == "" {
+		return fmt.Errorf("ERROR_TRACKING_ENABLED=true requires SENTRY_DSN")
+	}
+	return nil
+}
+
+// enabled is set once Init has configured a client
+var enabled atomic.Bool
+
+// Init configures the client. It does nothing when cfg.Enabled is false.
+func Init(cfg Config) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      cfg.Environment,
+		Release:          cfg.Release,
+		SampleRate:       cfg.SampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return fmt.Errorf("initialize error tracking: %w", err)
+	}
+	enabled.Store(true)
+	return nil
+}
+
+// Enabled reports whether events are being sent
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Flush waits up to timeout for queued events to be sent; call it before the process exits
+func Flush(timeout time.Duration) {
+	if Enabled() {
+		sentry.Flush(timeout)
+	}
+}
+
+// CapturePanic reports a panic recovered while serving c, with the stack of the panicking
+// goroutine; call it from the recovery handler
+func CapturePanic(c *gin.Context, recovered interface{}) {
+	if !Enabled() {
+		return
+	}
+	requestHub(c).RecoverWithContext(c.Request.Context(), recovered)
+}
+
+// CaptureError reports err, which made the request fail with status
+func CaptureError(c *gin.Context, err error, status int, errorType string) {
+	if !Enabled() {
+		return
+	}
+	hub := requestHub(c)
+	hub.Scope().SetTag("status", strconv.Itoa(status))
+	hub.Scope().SetTag("error_type", errorType)
+	hub.CaptureException(err)
+}
+
+// requestHub returns a hub whose scope describes the request, so concurrent requests never
+// share tags or user
+func requestHub(c *gin.Context) *sentry.Hub {
+	hub := sentry.CurrentHub().Clone()
+	scope := hub.Scope()
+	scope.SetTag("request_id", tracing.RequestID(c.Request.Context()))
+	if route := c.FullPath(); route != "" {
+		scope.SetTag("route", route)
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		scope.SetUser(sentry.User{ID: fmt.Sprint(userID)})
+	}
+
+	// Headers only, without Cookie, Authorization and forwarding headers; Scope.SetRequest
+	// would also buffer the body, which may hold passwords
+	request := sentry.NewRequest(c.Request)
+	scope.AddEventProcessor(func(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+		event.Request = request
+		return event
+	})
+	return hub
+}
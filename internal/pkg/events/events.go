@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Entity change actions
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// DefaultChannel is the Redis pub/sub channel used to fan events out across instances
+const DefaultChannel = "cms:events"
+
+// WildcardEntity subscribes a handler to events for every entity
+const WildcardEntity = "*"
+
+// Event describes a change to a domain entity
+type Event struct {
+	Entity string    `json:"entity"`       // Table/entity name, e.g. "users", "menu"
+	Action string    `json:"action"`       // One of the Action* constants
+	ID     string    `json:"id,omitempty"` // Primary key of the changed record (composite keys joined with ":")
+	Origin string    `json:"origin"`       // Instance that published the event
+	Time   time.Time `json:"time"`         // When the event was published
+	Remote bool      `json:"-"`            // True when received from another instance via pub/sub
+}
+
+// Handler processes an event. Handlers must not block for long.
+type Handler func(Event)
+
+// Bus dispatches entity-changed events to in-process subscribers and,
+// when Redis is attached, to subscribers on other instances.
+type Bus struct {
+	mu         sync.RWMutex
+	handlers   map[string][]Handler
+	instanceID string
+
+	redis   redis.UniversalClient
+	channel string
+	cancel  context.CancelFunc
+}
+
+// NewBus creates a new event bus with a unique instance ID
+func NewBus() *Bus {
+	return &Bus{
+		handlers:   make(map[string][]Handler),
+		instanceID: newInstanceID(),
+	}
+}
+
+// Default is the process-wide event bus
+var Default = NewBus()
+
+// newInstanceID builds an identifier used to ignore our own pub/sub echoes
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return host + "-" + hex.EncodeToString(buf)
+}
+
+// InstanceID returns the identifier of this process on the bus
+func (b *Bus) InstanceID() string {
+	return b.instanceID
+}
+
+// Subscribe registers h for events on entity (or WildcardEntity for all entities)
+func (b *Bus) Subscribe(entity string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[entity] = append(b.handlers[entity], h)
+}
+
+// Publish dispatches e to local subscribers and broadcasts it to other instances
+func (b *Bus) Publish(e Event) {
+	e.Origin = b.instanceID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.dispatch(e)
+
+	b.mu.RLock()
+	client, channel := b.redis, b.channel
+	b.mu.RUnlock()
+	if client == nil {
+		return
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		slog.Warn("Failed to encode event", "entity", e.Entity, "action", e.Action, "error", err)
+		return
+	}
+	if err := client.Publish(context.Background(), channel, payload).Err(); err != nil {
+		slog.Warn("Failed to broadcast event", "entity", e.Entity, "action", e.Action, "error", err)
+	}
+}
+
+// dispatch runs all handlers registered for the event's entity and the wildcard
+func (b *Bus) dispatch(e Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers[e.Entity])+len(b.handlers[WildcardEntity]))
+	handlers = append(handlers, b.handlers[e.Entity]...)
+	handlers = append(handlers, b.handlers[WildcardEntity]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("Event handler panic", "entity", e.Entity, "action", e.Action, "panic", r)
+				}
+			}()
+			h(e)
+		}()
+	}
+}
+
+// AttachRedis broadcasts published events over Redis pub/sub and dispatches
+// events from other instances to local subscribers with Remote set.
+func (b *Bus) AttachRedis(client redis.UniversalClient, channel string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.redis = client
+	b.channel = channel
+	b.cancel = cancel
+	b.mu.Unlock()
+
+	pubsub := client.Subscribe(ctx, channel)
+	go func() {
+		defer pubsub.Close()
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Event subscription error", "error", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				slog.Warn("Failed to decode event", "error", err)
+				continue
+			}
+			if e.Origin == b.instanceID {
+				continue
+			}
+			e.Remote = true
+			b.dispatch(e)
+		}
+	}()
+}
+
+// Close stops the Redis subscription, if any
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		b.cancel()
+		b.cancel = nil
+	}
+	b.redis = nil
+}
+
+// Publish dispatches an event on the default bus
+func Publish(e Event) {
+	Default.Publish(e)
+}
+
+// EntityChanged publishes an entity change on the default bus
+func EntityChanged(entity, action, id string) {
+	Default.Publish(Event{Entity: entity, Action: action, ID: id})
+}
@@ -0,0 +1,95 @@
+// Package features holds named debug switches that operators can flip at runtime through
+// /api/admin/runtime, without restarting the process. Switches are per process.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Built-in switches
+const (
+	// Pprof serves /debug/pprof; its default comes from PPROF_ENABLED
+	Pprof = "pprof"
+	// TraceAll keeps every request's trace for /api/admin/traces, not only flagged ones
+	TraceAll = "trace_all"
+	// PayloadLog records redacted request and response bodies for /api/admin/payloads
+	PayloadLog = "payload_log"
+)
+
+// Feature is one switch
+type Feature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type feature struct {
+	description string
+	enabled     atomic.Bool
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*feature{}
+)
+
+func init() {
+	Register(Pprof, "Serve runtime profiles on /debug/pprof (ops role)", true)
+	Register(TraceAll, "Keep every request trace for /api/admin/traces, not only slow or N+1 requests", false)
+	Register(PayloadLog, "Record redacted request and response bodies for /api/admin/payloads", false)
+}
+
+// Register adds a switch, or resets the default of an existing one
+func Register(name, description string, enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	f, ok := registry[name]
+	if !ok {
+		f = &feature{}
+		registry[name] = f
+	}
+	f.description = description
+	f.enabled.Store(enabled)
+}
+
+// Enabled reports whether the switch is on; unknown switches are off
+func Enabled(name string) bool {
+	mu.RLock()
+	f := registry[name]
+	mu.RUnlock()
+	return f != nil && f.enabled.Load()
+}
+
+// Set turns a registered switch on or off
+func Set(name string, enabled bool) error {
+	mu.RLock()
+	f := registry[name]
+	mu.RUnlock()
+	if f == nil {
+		return fmt.Errorf("unknown feature %q", name)
+	}
+	f.enabled.Store(enabled)
+	return nil
+}
+
+// Known reports whether name is a registered switch
+func Known(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return registry[name] != nil
+}
+
+// List returns every switch sorted by name
+func List() []Feature {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Feature, 0, len(registry))
+	for name, f := range registry {
+		out = append(out, Feature{Name: name, Description: f.description, Enabled: f.enabled.Load()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,100 @@
+package fieldcrypt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Field is an encrypted column. Key is the table's integer primary key, which the
+// re-encryption job walks the table by.
+type Field struct {
+	Table  string
+	Key    string
+	Column string
+}
+
+// Context is what values of the field are bound to, so they only decrypt in this column
+func (f Field) Context() string {
+	return f.Table + "." + f.Column
+}
+
+// Encrypt seals plain for the field with the Default keyring
+func (f Field) Encrypt(plain string) (string, error) {
+	return Default.Encrypt(plain, f.Context())
+}
+
+// Decrypt opens a value of the field with the Default keyring
+func (f Field) Decrypt(value string) (string, error) {
+	return Default.Decrypt(value, f.Context())
+}
+
+// Reencryption counts the rows a Reencrypt pass looked at and rewrote
+type Reencryption struct {
+	Scanned   int64
+	Rewritten int64
+}
+
+// Reencrypt rewrites, batch rows at a time, every value of f not sealed with the primary key
+// of the Default keyring, including values still in the clear. A row changed since it was
+// read is left for the next pass rather than overwritten. A value sealed with a key no
+// longer listed stops the pass, as it cannot be read either.
+func Reencrypt(ctx context.Context, db *sql.DB, f Field, batch int) (Reencryption, error) {
+	var res Reencryption
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > ? AND %s IS NOT NULL ORDER BY %s LIMIT ?",
+		f.Key, f.Column, f.Table, f.Key, f.Column, f.Key)
+	update := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s = ?", f.Table, f.Column, f.Key, f.Column)
+
+	var after int64
+	for {
+		type row struct {
+			id    int64
+			value string
+		}
+		var stale []row
+		rows, err := db.QueryContext(ctx, query, after, batch)
+		if err != nil {
+			return res, fmt.Errorf("failed to read %s: %w", f.Context(), err)
+		}
+		n := 0
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value); err != nil {
+				rows.Close()
+				return res, fmt.Errorf("failed to scan %s: %w", f.Context(), err)
+			}
+			n++
+			after = r.id
+			if !Default.Current(r.value) {
+				stale = append(stale, r)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return res, fmt.Errorf("error iterating %s: %w", f.Context(), err)
+		}
+		res.Scanned += int64(n)
+
+		for _, r := range stale {
+			plain, err := f.Decrypt(r.value)
+			if err != nil {
+				return res, fmt.Errorf("%s of %s %d: %w", f.Column, f.Table, r.id, err)
+			}
+			sealed, err := f.Encrypt(plain)
+			if err != nil {
+				return res, err
+			}
+			result, err := db.ExecContext(ctx, update, sealed, r.id, r.value)
+			if err != nil {
+				return res, fmt.Errorf("failed to rewrite %s of %s %d: %w", f.Column, f.Table, r.id, err)
+			}
+			if affected, err := result.RowsAffected(); err == nil {
+				res.Rewritten += affected
+			}
+		}
+
+		if n < batch {
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package fieldcrypt encrypts sensitive columns, such as webhook signing secrets, before they
+// reach the database.
+//
+// Values are sealed with AES-256-GCM and stored as "enc:v1:<key id>:<base64 nonce and
+// ciphertext>". The table and column are authenticated with the value, so a ciphertext copied
+// into another column does not decrypt. A keyring holds every key still needed to read old
+// values and names the primary one new values are written with: rotating adds a key, makes it
+// primary, and the re-encryption job then rewrites what older keys sealed. Values without the
+// prefix are read as they are, so columns written before encryption was turned on keep
+// working until they are rewritten.
+package fieldcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// prefix marks an encrypted value and the version of its format
+const prefix = "enc:v1:"
+
+// KeySize is the length of a key: AES-256
+const KeySize = 32
+
+// ErrUnknownKey is returned for a value sealed with a key the keyring does not hold
+var ErrUnknownKey = errors.New("value encrypted with an unknown key")
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Config is the encryption section of the configuration. With no keys values are stored in
+// the clear, and encrypted ones cannot be read.
+type Config struct {
+	// Keys are "id:base64 key" pairs of 32-byte keys; the first is the primary, the rest
+	// only decrypt. Generate one with go run ./cmd/secret.
+	Keys []string `yaml:"keys" env:"FIELD_ENCRYPTION_KEYS"`
+	// KeysFile reads the same pairs from a file instead, one per line, e.g. one a KMS or
+	// secret manager agent keeps up to date
+	KeysFile string `yaml:"keys_file" env:"FIELD_ENCRYPTION_KEYS_FILE"`
+}
+
+// Validate checks the keys can be loaded
+func (c *Config) Validate() error {
+	_, _, err := c.load()
+	return err
+}
+
+// load reads and parses the configured keys, primary first
+func (c *Config) load() ([]string, map[string]cipher.AEAD, error) {
+	pairs := c.Keys
+	if c.KeysFile != "" {
+		if len(c.Keys) > 0 {
+			return nil, nil, errors.New("set FIELD_ENCRYPTION_KEYS or FIELD_ENCRYPTION_KEYS_FILE, not both")
+		}
+		data, err := os.ReadFile(c.KeysFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("FIELD_ENCRYPTION_KEYS_FILE: %w", err)
+		}
+		pairs = nil
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				pairs = append(pairs, line)
+			}
+		}
+	}
+
+	var ids []string
+	keys := make(map[string]cipher.AEAD, len(pairs))
+	for _, pair := range pairs {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			// The pair itself is never echoed: it holds the key
+			return nil, nil, fmt.Errorf("field encryption key %d: want \"id:base64 key\" with an id of letters, digits, - and _", len(ids)+1)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, nil, fmt.Errorf("field encryption key %q is listed twice", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != KeySize {
+			return nil, nil, fmt.Errorf("field encryption key %q must be %d bytes in base64", id, KeySize)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		keys[id] = aead
+	}
+	return ids, keys, nil
+}
+
+// Keyring encrypts with its primary key and decrypts with any of its keys
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// Default is the process-wide keyring, empty until Configure
+var Default = &Keyring{}
+
+// Configure replaces the keys, e.g. on startup or a configuration reload
+func (k *Keyring) Configure(cfg Config) error {
+	ids, keys, err := cfg.load()
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary, k.keys = "", keys
+	if len(ids) > 0 {
+		k.primary = ids[0]
+	}
+	return nil
+}
+
+// Enabled reports whether new values are encrypted
+func (k *Keyring) Enabled() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary != ""
+}
+
+// Primary returns the ID of the key new values are encrypted with, "" when encryption is off
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Encrypt seals plain for the column named by context with the primary key. Without keys
+// plain is returned as it is.
+func (k *Keyring) Encrypt(plain, context string) (string, error) {
+	k.mu.RLock()
+	id := k.primary
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return plain, nil
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(context))
+	return prefix + id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value Encrypt sealed for context; a value without the prefix is returned as
+// it is
+func (k *Keyring) Decrypt(value, context string) (string, error) {
+	id, data, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	k.mu.RLock()
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return "", fmt.Errorf("encrypted value does not open with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// Current reports whether value is stored as Encrypt would store it now: sealed with the
+// primary key, or in the clear when encryption is off
+func (k *Keyring) Current(value string) bool {
+	id, _, ok := split(value)
+	primary := k.Primary()
+	if !ok {
+		return primary == ""
+	}
+	return id == primary
+}
+
+// KeyID returns the ID of the key value was sealed with, "" for a value in the clear
+func KeyID(value string) string {
+	id, _, _ := split(value)
+	return id
+}
+
+// split parses an encrypted value into its key ID and payload
+func split(value string) (id, data string, ok bool) {
+	rest, found := strings.CutPrefix(value, prefix)
+	if !found {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
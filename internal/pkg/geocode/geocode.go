@@ -0,0 +1,211 @@
+// Package geocode finds the coordinates and elevation of places by name through an external
+// geocoder: Nominatim (OpenStreetMap, with elevations from an Open-Meteo compatible API) or
+// the Google Maps Platform Geocoding and Elevation APIs. Calls to the provider are rate
+// limited to GEOCODER_RATE, across instances once the limiter has Redis; caching answers is
+// left to the caller.
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/ratelimit"
+)
+
+// Providers
+const (
+	ProviderNone      = "none"
+	ProviderNominatim = "nominatim"
+	ProviderGoogle    = "google"
+)
+
+// ErrDisabled is returned while no provider is configured
+var ErrDisabled = errors.New("geocoder is disabled")
+
+// ErrNotFound means the provider knows no place by that name
+var ErrNotFound = errors.New("place not found")
+
+// ErrRateLimited means GEOCODER_RATE, or the provider's own quota, allows no call for
+// longer than GEOCODER_TIMEOUT
+var ErrRateLimited = errors.New("geocoder rate limit reached")
+
+// Config is the geocoder section of the configuration
+type Config struct {
+	// Provider is none, nominatim or google
+	Provider     string `yaml:"provider" env:"GEOCODER_PROVIDER" default:"none"`
+	NominatimURL string `yaml:"nominatim_url" env:"NOMINATIM_URL" default:"https://nominatim.openstreetmap.org"`
+	// UserAgent identifies this application to Nominatim, as its usage policy requires; add
+	// a contact address for a public instance
+	UserAgent string `yaml:"user_agent" env:"GEOCODER_USER_AGENT" default:"adminbe-geocoder"`
+	// ElevationURL answers elevations for Nominatim results, which have none; empty leaves
+	// them out
+	ElevationURL string `yaml:"elevation_url" env:"GEOCODER_ELEVATION_URL" default:"https://api.open-meteo.com/v1/elevation"`
+	GoogleAPIKey string `yaml:"google_api_key" env:"GOOGLE_MAPS_API_KEY"`
+	GoogleAPIURL string `yaml:"google_api_url" env:"GOOGLE_MAPS_API_URL" default:"https://maps.googleapis.com"`
+	// Countries restricts results to these ISO 3166-1 alpha-2 codes, comma-separated; empty
+	// searches everywhere
+	Countries string `yaml:"countries" env:"GEOCODER_COUNTRIES" default:"id"`
+	// Language of the place names answered
+	Language string `yaml:"language" env:"GEOCODER_LANGUAGE" default:"id"`
+	// Rate bounds the calls to the provider; the public Nominatim allows one a second
+	Rate ratelimit.Rate `yaml:"rate" env:"GEOCODER_RATE" default:"1/1s"`
+	// Timeout bounds a lookup, including the wait for the rate limit
+	Timeout time.Duration `yaml:"timeout" env:"GEOCODER_TIMEOUT" default:"10s"`
+	// CacheTTL is how long answers are cached; places rarely move
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"GEOCODER_CACHE_TTL" default:"720h"`
+}
+
+// Validate checks the configured provider has what it needs
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Provider {
+	case ProviderNone:
+	case ProviderNominatim:
+		if !isHTTPURL(c.NominatimURL) {
+			errs = append(errs, errors.New("NOMINATIM_URL must be an http or https URL"))
+		}
+		if c.ElevationURL != "" && !isHTTPURL(c.ElevationURL) {
+			errs = append(errs, errors.New("GEOCODER_ELEVATION_URL must be an http or https URL"))
+		}
+		if strings.TrimSpace(c.UserAgent) == "" {
+			errs = append(errs, errors.New("GEOCODER_USER_AGENT is required by Nominatim"))
+		}
+	case ProviderGoogle:
+		if c.GoogleAPIKey == "" {
+			errs = append(errs, errors.New("GEOCODER_PROVIDER=google needs GOOGLE_MAPS_API_KEY"))
+		}
+		if !isHTTPURL(c.GoogleAPIURL) {
+			errs = append(errs, errors.New("GOOGLE_MAPS_API_URL must be an http or https URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("GEOCODER_PROVIDER must be none, nominatim or google, not %q", c.Provider))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("GEOCODER_TIMEOUT must be positive"))
+	}
+	if c.CacheTTL < 0 {
+		errs = append(errs, errors.New("GEOCODER_CACHE_TTL must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// Place is a geocoded place. Elevation, in meters above sea level, is nil when the provider
+// cannot tell.
+type Place struct {
+	Name      string   `json:"name"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Elevation *float64 `json:"elevation"`
+}
+
+// Provider looks places up; each geocoder is one
+type Provider interface {
+	// Geocode returns the best match for query, or ErrNotFound
+	Geocode(ctx context.Context, query string) (*Place, error)
+	// Elevation returns the elevation at the coordinates, nil when unknown
+	Elevation(ctx context.Context, latitude, longitude float64) (*float64, error)
+}
+
+// Client calls the configured provider within the rate limit
+type Client struct {
+	mu       sync.RWMutex
+	cfg      Config
+	provider Provider
+	limiter  *ratelimit.Limiter
+}
+
+// Default is the process-wide client, with no provider until Configure, sharing the buckets
+// of ratelimit.Default
+var Default = &Client{limiter: ratelimit.Default}
+
+// Configure replaces the provider, e.g. on startup or a configuration reload
+func (c *Client) Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var provider Provider
+	switch cfg.Provider {
+	case ProviderNominatim:
+		provider = newNominatim(cfg)
+	case ProviderGoogle:
+		provider = newGoogle(cfg)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg, c.provider = cfg, provider
+	return nil
+}
+
+// Enabled reports whether a provider is configured
+func (c *Client) Enabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.provider != nil
+}
+
+// Config returns the configuration in effect
+func (c *Client) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg
+}
+
+// Geocode looks query up, bounded by GEOCODER_TIMEOUT
+func (c *Client) Geocode(ctx context.Context, query string) (*Place, error) {
+	var place *Place
+	err := c.call(ctx, func(ctx context.Context, p Provider) (err error) {
+		place, err = p.Geocode(ctx, query)
+		return err
+	})
+	return place, err
+}
+
+// Elevation looks the elevation at the coordinates up, bounded by GEOCODER_TIMEOUT
+func (c *Client) Elevation(ctx context.Context, latitude, longitude float64) (*float64, error) {
+	var elevation *float64
+	err := c.call(ctx, func(ctx context.Context, p Provider) (err error) {
+		elevation, err = p.Elevation(ctx, latitude, longitude)
+		return err
+	})
+	return elevation, err
+}
+
+// call runs fn on the provider once the rate limit allows, waiting for it within the timeout
+func (c *Client) call(ctx context.Context, fn func(ctx context.Context, p Provider) error) error {
+	c.mu.RLock()
+	provider, cfg, limiter := c.provider, c.cfg, c.limiter
+	c.mu.RUnlock()
+	if provider == nil {
+		return ErrDisabled
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	for limiter != nil {
+		// When Redis fails the local bucket decides, which is enough to stay polite
+		result, _ := limiter.Allow(ctx, "geocode:"+cfg.Provider, cfg.Rate)
+		if result.Allowed {
+			break
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < result.RetryAfter {
+			return ErrRateLimited
+		}
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ErrRateLimited
+		case <-timer.C:
+		}
+	}
+	return fn(ctx, provider)
+}
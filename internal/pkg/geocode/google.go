@@ -0,0 +1,117 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// google calls the Geocoding and Elevation APIs of the Google Maps Platform
+type google struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newGoogle(cfg Config) *google {
+	return &google{cfg: cfg, client: &http.Client{}}
+}
+
+// googleResponse is the envelope of both APIs; Status is OK, ZERO_RESULTS,
+// OVER_QUERY_LIMIT, REQUEST_DENIED or INVALID_REQUEST
+type googleResponse struct {
+	Status       string          `json:"status"`
+	ErrorMessage string          `json:"error_message"`
+	Results      json.RawMessage `json:"results"`
+}
+
+func (g *google) Geocode(ctx context.Context, query string) (*Place, error) {
+	params := url.Values{"address": {query}}
+	if g.cfg.Countries != "" {
+		var components []string
+		for _, country := range strings.Split(g.cfg.Countries, ",") {
+			components = append(components, "country:"+strings.ToUpper(strings.TrimSpace(country)))
+		}
+		params.Set("components", strings.Join(components, "|"))
+	}
+	if g.cfg.Language != "" {
+		params.Set("language", g.cfg.Language)
+	}
+	var results []struct {
+		FormattedAddress string `json:"formatted_address"`
+		Geometry         struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	}
+	if err := g.get(ctx, "/maps/api/geocode/json", params, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	location := results[0].Geometry.Location
+	elevation, err := g.Elevation(ctx, location.Lat, location.Lng)
+	if err != nil {
+		return nil, err
+	}
+	return &Place{Name: results[0].FormattedAddress, Latitude: location.Lat, Longitude: location.Lng, Elevation: elevation}, nil
+}
+
+func (g *google) Elevation(ctx context.Context, latitude, longitude float64) (*float64, error) {
+	params := url.Values{"locations": {strconv.FormatFloat(latitude, 'f', -1, 64) + "," + strconv.FormatFloat(longitude, 'f', -1, 64)}}
+	var results []struct {
+		Elevation float64 `json:"elevation"`
+	}
+	if err := g.get(ctx, "/maps/api/elevation/json", params, &results); errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0].Elevation, nil
+}
+
+// get calls the API at path and decodes the results of its answer into dest
+func (g *google) get(ctx context.Context, path string, params url.Values, dest any) error {
+	params.Set("key", g.cfg.GoogleAPIKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(g.cfg.GoogleAPIURL, "/")+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return errors.New("google maps: invalid API URL")
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		// The URL carries the API key, so only the cause is kept
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("google maps: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result googleResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("google maps answered %d: %w", resp.StatusCode, err)
+	}
+	switch result.Status {
+	case "OK":
+		return json.Unmarshal(result.Results, dest)
+	case "ZERO_RESULTS":
+		return ErrNotFound
+	case "OVER_QUERY_LIMIT", "OVER_DAILY_LIMIT":
+		return ErrRateLimited
+	default:
+		return fmt.Errorf("google maps answered %s: %s", result.Status, result.ErrorMessage)
+	}
+}
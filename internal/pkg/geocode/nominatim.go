@@ -0,0 +1,105 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// nominatim searches a Nominatim instance and takes elevations from an Open-Meteo compatible
+// elevation API
+type nominatim struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newNominatim(cfg Config) *nominatim {
+	return &nominatim{cfg: cfg, client: &http.Client{}}
+}
+
+type nominatimResult struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+}
+
+func (n *nominatim) Geocode(ctx context.Context, query string) (*Place, error) {
+	params := url.Values{"q": {query}, "format": {"jsonv2"}, "limit": {"1"}}
+	if n.cfg.Countries != "" {
+		params.Set("countrycodes", strings.ToLower(n.cfg.Countries))
+	}
+	if n.cfg.Language != "" {
+		params.Set("accept-language", n.cfg.Language)
+	}
+	var results []nominatimResult
+	if err := n.get(ctx, strings.TrimSuffix(n.cfg.NominatimURL, "/")+"/search?"+params.Encode(), &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, err1 := strconv.ParseFloat(results[0].Lat, 64)
+	lon, err2 := strconv.ParseFloat(results[0].Lon, 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("nominatim answered coordinates %q, %q", results[0].Lat, results[0].Lon)
+	}
+	elevation, err := n.Elevation(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	return &Place{Name: results[0].DisplayName, Latitude: lat, Longitude: lon, Elevation: elevation}, nil
+}
+
+func (n *nominatim) Elevation(ctx context.Context, latitude, longitude float64) (*float64, error) {
+	if n.cfg.ElevationURL == "" {
+		return nil, nil
+	}
+	params := url.Values{
+		"latitude":  {strconv.FormatFloat(latitude, 'f', -1, 64)},
+		"longitude": {strconv.FormatFloat(longitude, 'f', -1, 64)},
+	}
+	var result struct {
+		Elevation []float64 `json:"elevation"`
+	}
+	if err := n.get(ctx, n.cfg.ElevationURL+"?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Elevation) == 0 {
+		return nil, nil
+	}
+	return &result.Elevation[0], nil
+}
+
+// get fetches u and decodes its JSON answer into dest
+func (n *nominatim) get(ctx context.Context, u string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", n.cfg.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("nominatim: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, 1<<20)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(body, 512))
+		return fmt.Errorf("%s answered %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(body).Decode(dest); err != nil {
+		return fmt.Errorf("%s: %w", req.URL.Host, err)
+	}
+	return nil
+}
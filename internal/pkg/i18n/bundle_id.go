@@ -0,0 +1,170 @@
+package i18n
+
+// indonesianMessages translates whole English messages, and the nouns and phrases the
+// templates below fill in. Lower-case entries also translate their capitalized form.
+var indonesianMessages = map[string]string{
+	// Requests and sessions
+	"Internal server error":                                        "Terjadi kesalahan pada server",
+	"Internal server error occurred":                               "Terjadi kesalahan pada server",
+	"Database query failed":                                        "Kueri basis data gagal",
+	"Request validation failed":                                    "Validasi permintaan gagal",
+	"Request body is required":                                     "Isi permintaan wajib ada",
+	"Request body is not valid JSON":                               "Isi permintaan bukan JSON yang valid",
+	"Request exceeded its time budget":                             "Permintaan melebihi batas waktunya",
+	"Too many requests, please retry later":                        "Terlalu banyak permintaan, silakan coba lagi nanti",
+	"Server is busy, please retry shortly":                         "Server sedang sibuk, silakan coba lagi sebentar lagi",
+	"No fields to update":                                          "Tidak ada data yang diubah",
+	"Authorization header required":                                "Header Authorization wajib ada",
+	"Invalid token":                                                "Token tidak valid",
+	"Invalid credentials":                                          "Nama pengguna atau kata sandi salah",
+	"Incorrect password":                                           "Kata sandi salah",
+	"Insufficient permissions":                                     "Hak akses tidak mencukupi",
+	"Account disabled":                                             "Akun dinonaktifkan",
+	"Your account has been disabled":                               "Akun Anda telah dinonaktifkan",
+	"Token generation failed":                                      "Gagal membuat token",
+	"Not signed in as a user":                                      "Belum masuk sebagai pengguna",
+	"Password changed":                                             "Kata sandi diubah",
+	"The new password must differ from the current one":            "Kata sandi baru harus berbeda dari yang sekarang",
+	"Your password has expired; sign in again with a new_password": "Kata sandi Anda kedaluwarsa; masuk lagi dengan new_password",
+	"Invalid or expired code":                                      "Kode tidak valid atau kedaluwarsa",
+	"Access from this address is not allowed":                      "Akses dari alamat ini tidak diizinkan",
+	"Only available to the default tenant":                         "Hanya tersedia untuk tenant bawaan",
+	"Unknown tenant":                                               "Tenant tidak dikenal",
+	"Success":                                                      "Berhasil",
+	"Update failed":                                                "Gagal memperbarui",
+	"Delete failed":                                                "Gagal menghapus",
+	"Soft delete failed":                                           "Gagal menghapus",
+	"Resource already exists":                                      "Data sudah ada",
+	"Resource is still referenced by other records":                "Data masih dirujuk oleh data lain",
+	"Referenced resource does not exist":                           "Data yang dirujuk tidak ada",
+	"Role name already exists":                                     "Nama peran sudah ada",
+	"File type is not allowed":                                     "Jenis berkas tidak diizinkan",
+	"File is too large":                                            "Berkas terlalu besar",
+	"File is empty":                                                "Berkas kosong",
+	"Invalid value for displayName":                                "Nilai displayName tidak valid",
+	"Invalid field value":                                          "Nilai kolom tidak valid",
+	"Invalid JSON value":                                           "Nilai JSON tidak valid",
+
+	// Nouns the templates fill in
+	"user":                  "pengguna",
+	"users":                 "pengguna",
+	"role":                  "peran",
+	"roles":                 "peran",
+	"menu":                  "menu",
+	"file":                  "berkas",
+	"tenant":                "tenant",
+	"tenant settings":       "pengaturan tenant",
+	"location":              "lokasi",
+	"group":                 "grup",
+	"phone":                 "nomor telepon",
+	"phone number":          "nomor telepon",
+	"avatar":                "avatar",
+	"prayer times":          "jadwal salat",
+	"prayer subscription":   "langganan jadwal salat",
+	"webhook":               "webhook",
+	"security event":        "kejadian keamanan",
+	"SMS message":           "pesan SMS",
+	"push device":           "perangkat push",
+	"place":                 "tempat",
+	"notification":          "notifikasi",
+	"import":                "impor",
+	"export job":            "tugas ekspor",
+	"city":                  "kota",
+	"province":              "provinsi",
+	"backup":                "cadangan",
+	"announcement":          "pengumuman",
+	"audit log":             "log audit",
+	"job":                   "tugas",
+	"role inheritance":      "pewarisan peran",
+	"user-role assignment":  "penetapan peran pengguna",
+	"user-menu assignment":  "penetapan menu pengguna",
+	"role-menu assignment":  "penetapan menu peran",
+	"cache key":             "kunci cache",
+	"menus":                 "menu",
+	"provinces":             "provinsi",
+	"cities":                "kota",
+	"audit logs":            "log audit",
+	"role inheritances":     "pewarisan peran",
+	"role hierarchies":      "hierarki peran",
+	"user-role assignments": "penetapan peran pengguna",
+	"user-menu assignments": "penetapan menu pengguna",
+	"role-menu assignments": "penetapan menu peran",
+	"menu navigation":       "navigasi menu",
+	"ID":                    "ID",
+	"user ID":               "ID pengguna",
+	"role ID":               "ID peran",
+	"menu ID":               "ID menu",
+	"province code":         "kode provinsi",
+	"city code":             "kode kota",
+	"cursor":                "kursor",
+	"status":                "status",
+	"month":                 "bulan",
+	"year":                  "tahun",
+	"slug":                  "slug",
+	"time zone":             "zona waktu",
+	"name":                  "nama",
+	"message":               "pesan",
+	"namespace":             "namespace",
+	"file name":             "nama berkas",
+
+	// Field validation (see package validation)
+	"is required":                   "wajib diisi",
+	"is invalid":                    "tidak valid",
+	"must be a valid email address": "harus berupa alamat email yang valid",
+	"may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit": "hanya boleh berisi huruf, angka, '.', '_' dan '-', dan harus diawali huruf atau angka",
+	"must be an Indonesian mobile number, e.g. 081234567890":                                    "harus berupa nomor ponsel Indonesia, misalnya 081234567890",
+	"must be a string":                   "harus berupa teks",
+	"must be a boolean":                  "harus berupa boolean",
+	"must be an integer":                 "harus berupa bilangan bulat",
+	"must be a non-negative integer":     "harus berupa bilangan bulat tidak negatif",
+	"must be a number":                   "harus berupa angka",
+	"must be an array":                   "harus berupa array",
+	"must be an object":                  "harus berupa objek",
+	"is taken by another user":           "sudah dipakai pengguna lain",
+	"must be 1 (active) or 0 (inactive)": "harus 1 (aktif) atau 0 (nonaktif)",
+
+	// Prayer time names (see models.PrayerTimeNames)
+	"Imsak":   "Imsak",
+	"Fajr":    "Subuh",
+	"Sunrise": "Terbit",
+	"Duha":    "Dhuha",
+	"Dhuhr":   "Dzuhur",
+	"Asr":     "Ashar",
+	"Maghrib": "Maghrib",
+	"Isha":    "Isya",
+}
+
+// indonesianTemplates translate the messages built around a noun or a value, most specific
+// first
+var indonesianTemplates = [][2]string{
+	{"Invalid request format: %s", "Format permintaan tidak valid: %s"},
+	{"Invalid merge patch: %s", "Merge patch tidak valid: %s"},
+	{"Request body exceeds %s", "Isi permintaan melebihi %s"},
+	{"Timed out trying to %s", "Waktu habis saat mencoba %s"},
+	{"Failed to %s", "Gagal %s"},
+	{"retrieve %s", "mengambil %s"},
+	{"get %s", "mengambil %s"},
+	{"list %s", "menampilkan %s"},
+	{"create %s", "membuat %s"},
+	{"update %s", "memperbarui %s"},
+	{"delete %s", "menghapus %s"},
+	{"%s service temporarily unavailable", "Layanan %s sedang tidak tersedia"},
+	{"%s not found", "%s tidak ditemukan"},
+	{"%s already exists", "%s sudah ada"},
+	{"%s created", "%s dibuat"},
+	{"%s updated", "%s diperbarui"},
+	{"%s deleted", "%s dihapus"},
+	{"Invalid %s", "%s tidak valid"},
+	{"%s is required", "%s wajib diisi"},
+	{"must be at least %s", "minimal %s"},
+	{"must be at most %s", "maksimal %s"},
+	{"must have at least %s", "harus berisi minimal %s"},
+	{"must have at most %s", "harus berisi maksimal %s"},
+	{"must be one of: %s", "harus salah satu dari: %s"},
+	{"is also in row %s", "juga ada di baris %s"},
+	{"%s characters", "%s karakter"},
+	{"%s character", "%s karakter"},
+	{"%s bytes", "%s byte"},
+	{"%s items", "%s item"},
+	{"%s item", "%s item"},
+}
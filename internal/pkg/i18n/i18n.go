@@ -0,0 +1,220 @@
+// Package i18n localizes the messages clients see. Messages are written in English, the
+// source language, so English needs no bundle of its own beyond them; the Indonesian bundle
+// (bundle_id.go) maps them to their translations, exactly or through templates such as
+// "%s not found". A request's language comes from its lang query parameter or
+// Accept-Language header (see Negotiate); messages a bundle lacks stay in English.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Supported languages, by their ISO 639-1 codes
+const (
+	English    = "en"
+	Indonesian = "id"
+)
+
+// Languages are the languages messages can be localized to
+var Languages = []string{English, Indonesian}
+
+// QueryParam is the query parameter naming a request's language, ahead of Accept-Language
+const QueryParam = "lang"
+
+var defaultLanguage atomic.Value
+
+func init() {
+	defaultLanguage.Store(English)
+}
+
+// SetDefault selects the language of requests that ask for none this package supports
+func SetDefault(lang string) error {
+	if !Supported(lang) {
+		return fmt.Errorf("unsupported language %q (want one of %s)", lang, strings.Join(Languages, ", "))
+	}
+	defaultLanguage.Store(lang)
+	return nil
+}
+
+// Default returns the language of requests that ask for none this package supports
+func Default() string {
+	return defaultLanguage.Load().(string)
+}
+
+// Supported reports whether lang is one of Languages
+func Supported(lang string) bool {
+	return slices.Contains(Languages, lang)
+}
+
+// Negotiate returns the language of a request: its lang query parameter when supported, else
+// the supported language of Accept-Language with the highest quality, else the default.
+// Region subtags are ignored ("id-ID" is Indonesian), as is the obsolete code "in".
+func Negotiate(query, acceptLanguage string) string {
+	if lang := normalize(query); Supported(lang) {
+		return lang
+	}
+
+	type ranked struct {
+		lang    string
+		quality float64
+	}
+	var candidates []ranked
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang := normalize(tag)
+		if !Supported(lang) {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			if err != nil || v <= 0 {
+				continue
+			}
+			quality = v
+		}
+		candidates = append(candidates, ranked{lang, quality})
+	}
+	// Stable, so equal qualities keep the client's order
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	if len(candidates) > 0 {
+		return candidates[0].lang
+	}
+	return Default()
+}
+
+// normalize returns the primary subtag of a language tag, lower-cased
+func normalize(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	primary = strings.ToLower(primary)
+	if primary == "in" {
+		return Indonesian
+	}
+	return primary
+}
+
+// languageKey is the context key of a request's language
+type languageKey struct{}
+
+// WithLanguage returns ctx localizing messages to lang
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// Language returns the language of ctx, the default when none was negotiated
+func Language(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok {
+		return lang
+	}
+	return Default()
+}
+
+// T returns msg in the language of ctx
+func T(ctx context.Context, msg string) string {
+	return Translate(Language(ctx), msg)
+}
+
+// Translate returns msg, an English message, in lang; unchanged when lang is English or its
+// bundle has no translation
+func Translate(lang, msg string) string {
+	b, ok := bundles[lang]
+	if !ok || msg == "" {
+		return msg
+	}
+	if translated, ok := b.translate(msg); ok {
+		return translated
+	}
+	return msg
+}
+
+// bundles are the translations from English, by language
+var bundles = map[string]*bundle{
+	Indonesian: newBundle(indonesianMessages, indonesianTemplates),
+}
+
+// bundle translates English messages to one language
+type bundle struct {
+	messages  map[string]string
+	templates []template
+}
+
+// template translates the messages matching an English pattern, whose %s are translated in
+// turn: "%s not found" makes "User not found" "Pengguna tidak ditemukan"
+type template struct {
+	pattern *regexp.Regexp
+	format  string
+}
+
+// newBundle compiles templates, tried in order, each [English, translation] with the same
+// number of %s
+func newBundle(messages map[string]string, templates [][2]string) *bundle {
+	b := &bundle{messages: messages}
+	for _, t := range templates {
+		pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(t[0]), "%s", "(.+)") + "$"
+		b.templates = append(b.templates, template{pattern: regexp.MustCompile(pattern), format: t[1]})
+	}
+	return b
+}
+
+// translate looks msg up, then its lower-cased form, then tries the templates
+func (b *bundle) translate(msg string) (string, bool) {
+	if translated, ok := b.messages[msg]; ok {
+		return translated, true
+	}
+	capitalized := startsUpper(msg)
+	if capitalized {
+		if translated, ok := b.messages[lowerFirst(msg)]; ok {
+			return upperFirst(translated), true
+		}
+	}
+
+	for _, t := range b.templates {
+		m := t.pattern.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		args := make([]any, len(m)-1)
+		for i, arg := range m[1:] {
+			if translated, ok := b.translate(arg); ok {
+				arg = translated
+			}
+			args[i] = arg
+		}
+		translated := fmt.Sprintf(t.format, args...)
+		if capitalized {
+			translated = upperFirst(translated)
+		}
+		return translated, true
+	}
+	return msg, false
+}
+
+// startsUpper reports whether s starts with an upper-case letter
+func startsUpper(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsUpper(r)
+}
+
+// upperFirst upper-cases the first letter of s
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// lowerFirst lower-cases the first letter of s, unless it starts an acronym such as "ID"
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,307 @@
+// Package ipfilter restricts the administration API by client address: addresses on the deny
+// list are always refused and, when the allow list is not empty, so is every address not on
+// it. The configured lists apply until an administrator replaces them; with Redis attached
+// the replacement is stored there and shared by every instance, which pick up changes within
+// PollInterval, and survives restarts. Without Redis it is per process.
+package ipfilter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultKey is the Redis key replaced rules are stored under
+const DefaultKey = "cms:ip_filter"
+
+// PollInterval is how often an instance rereads the rules from Redis
+const PollInterval = 2 * time.Second
+
+// Where the rules in effect come from
+const (
+	SourceConfig = "config"
+	SourceAdmin  = "admin"
+)
+
+// Refusals, by the list that refused the address
+var (
+	ErrDenied     = errors.New("address is on the deny list")
+	ErrNotAllowed = errors.New("address is not on the allow list")
+)
+
+// Config is the ip_filter section of the configuration: the lists in effect until an
+// administrator replaces them. Entries are CIDRs or single addresses, IPv4 or IPv6.
+type Config struct {
+	Allow []string `yaml:"allow" env:"IP_ALLOWLIST"`
+	Deny  []string `yaml:"deny" env:"IP_DENYLIST"`
+}
+
+// Validate checks every entry parses
+func (c *Config) Validate() error {
+	if _, err := compile(Rules{Allow: c.Allow, Deny: c.Deny}); err != nil {
+		return fmt.Errorf("ip_filter: %w", err)
+	}
+	return nil
+}
+
+// Rules are the lists in effect
+type Rules struct {
+	Allow     []string  `json:"allow"`
+	Deny      []string  `json:"deny"`
+	Source    string    `json:"source"`
+	UpdatedBy uint64    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks every entry parses
+func (r Rules) Validate() error {
+	_, err := compile(r)
+	return err
+}
+
+// compiled are rules parsed for matching
+type compiled struct {
+	allow, deny []netip.Prefix
+}
+
+func compile(r Rules) (compiled, error) {
+	allow, err := parsePrefixes("allow", r.Allow)
+	if err != nil {
+		return compiled{}, err
+	}
+	deny, err := parsePrefixes("deny", r.Deny)
+	if err != nil {
+		return compiled{}, err
+	}
+	return compiled{allow: allow, deny: deny}, nil
+}
+
+// parsePrefixes parses CIDRs, and single addresses as the prefix of that address alone
+func parsePrefixes(list string, entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s entry %q is neither a CIDR nor an address", list, entry)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %q is neither a CIDR nor an address", list, entry)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// check refuses addr with ErrDenied or ErrNotAllowed. An address that does not parse only
+// passes when there is no allow list.
+func (c compiled) check(ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		if len(c.allow) > 0 {
+			return ErrNotAllowed
+		}
+		return nil
+	}
+	addr = addr.Unmap()
+	for _, p := range c.deny {
+		if p.Contains(addr) {
+			return ErrDenied
+		}
+	}
+	if len(c.allow) == 0 {
+		return nil
+	}
+	for _, p := range c.allow {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	return ErrNotAllowed
+}
+
+// Check refuses ip with ErrDenied or ErrNotAllowed under r, which must be valid; it is for
+// trying rules out before setting them
+func (r Rules) Check(ip string) error {
+	c, err := compile(r)
+	if err != nil {
+		return err
+	}
+	return c.check(ip)
+}
+
+// Filter holds the rules of this process, mirrored from Redis once attached
+type Filter struct {
+	mu         sync.RWMutex
+	rules      Rules
+	compiled   compiled
+	configured Rules
+
+	redis  redis.UniversalClient
+	key    string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewFilter creates a filter that lets everyone through
+func NewFilter() *Filter {
+	return &Filter{rules: Rules{Source: SourceConfig}, configured: Rules{Source: SourceConfig}}
+}
+
+// Default is the process-wide filter
+var Default = NewFilter()
+
+// Configure sets the configured lists, e.g. on startup or a configuration reload. They take
+// effect unless an administrator has replaced them.
+func (f *Filter) Configure(cfg Config) error {
+	rules := Rules{Allow: cfg.Allow, Deny: cfg.Deny, Source: SourceConfig}
+	c, err := compile(rules)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.configured = rules
+	if f.rules.Source != SourceAdmin {
+		f.rules, f.compiled = rules, c
+	}
+	return nil
+}
+
+// Current returns the rules in effect on this instance
+func (f *Filter) Current() Rules {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.rules
+}
+
+// Check refuses ip with ErrDenied or ErrNotAllowed
+func (f *Filter) Check(ip string) error {
+	f.mu.RLock()
+	c := f.compiled
+	f.mu.RUnlock()
+	return c.check(ip)
+}
+
+// Set replaces the configured lists with rules, on every instance once Redis is attached.
+// Nothing changes when the rules are invalid or Redis cannot store them.
+func (f *Filter) Set(ctx context.Context, rules Rules) error {
+	rules.Source = SourceAdmin
+	c, err := compile(rules)
+	if err != nil {
+		return err
+	}
+	if f.redis != nil {
+		data, err := json.Marshal(rules)
+		if err != nil {
+			return err
+		}
+		if err := f.redis.Set(ctx, f.key, data, 0).Err(); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	f.rules, f.compiled = rules, c
+	f.mu.Unlock()
+	return nil
+}
+
+// Reset drops the rules an administrator set, going back to the configured lists
+func (f *Filter) Reset(ctx context.Context) (Rules, error) {
+	if f.redis != nil {
+		if err := f.redis.Del(ctx, f.key).Err(); err != nil {
+			return Rules{}, err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.useConfigured()
+	return f.rules, nil
+}
+
+// useConfigured puts the configured lists in effect; f.mu must be held. They were compiled
+// when configured, so cannot fail.
+func (f *Filter) useConfigured() {
+	f.rules = f.configured
+	f.compiled, _ = compile(f.configured)
+}
+
+// AttachRedis shares the rules through key, loading the stored ones now and polling them
+// from then on. Call it once, at startup.
+func (f *Filter) AttachRedis(client redis.UniversalClient, key string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.redis, f.key, f.cancel, f.done = client, key, cancel, make(chan struct{})
+	f.refresh(ctx)
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				f.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// refresh loads the stored rules; on errors the last ones known stay in effect
+func (f *Filter) refresh(ctx context.Context) {
+	data, err := f.redis.Get(ctx, f.key).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		f.mu.Lock()
+		f.useConfigured()
+		f.mu.Unlock()
+		return
+	case err != nil:
+		if ctx.Err() == nil {
+			slog.Warn("Failed to read the IP filter rules, keeping the last ones", "error", err)
+		}
+		return
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		slog.Warn("Ignoring invalid IP filter rules in Redis", "key", f.key, "error", err)
+		return
+	}
+	rules.Source = SourceAdmin
+	c, err := compile(rules)
+	if err != nil {
+		slog.Warn("Ignoring invalid IP filter rules in Redis", "key", f.key, "error", err)
+		return
+	}
+	f.mu.Lock()
+	f.rules, f.compiled = rules, c
+	f.mu.Unlock()
+}
+
+// Close stops polling Redis; it is safe to call more than once and without Redis
+func (f *Filter) Close() {
+	if f.cancel == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
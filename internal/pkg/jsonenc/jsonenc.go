@@ -0,0 +1,104 @@
+// Package jsonenc lets high-volume endpoints marshal responses with a faster JSON library
+// than encoding/json while keeping their output byte-for-byte identical.
+//
+// Every encoder is configured to match encoding/json: HTML characters escaped, map keys
+// sorted and invalid UTF-8 replaced. The one known difference, invalid UTF-8 written as an
+// escaped \ufffd instead of the raw replacement character, is caught per call and the value
+// re-marshalled with encoding/json. Verify checks the promise against golden values before an
+// encoder is put in front of clients.
+package jsonenc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Supported encoder names
+const (
+	NameStd      = "std"
+	NameJsoniter = "jsoniter"
+	NameSonic    = "sonic"
+)
+
+// Encoder marshals a value to JSON
+type Encoder interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+}
+
+// Std is encoding/json, the reference every other encoder is checked against
+var Std Encoder = stdEncoder{}
+
+type stdEncoder struct{}
+
+func (stdEncoder) Name() string                  { return NameStd }
+func (stdEncoder) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// escapedReplacement is how jsoniter and sonic write invalid UTF-8; encoding/json writes
+// the raw U+FFFD bytes instead
+var escapedReplacement = []byte(`\ufffd`)
+
+// compatEncoder wraps a faster library, falling back to encoding/json for any value it
+// would encode differently, or fail to encode, so errors match too
+type compatEncoder struct {
+	name    string
+	marshal func(v any) ([]byte, error)
+}
+
+func (e compatEncoder) Name() string { return e.name }
+
+func (e compatEncoder) Marshal(v any) ([]byte, error) {
+	out, err := e.marshal(v)
+	if err != nil || bytes.Contains(out, escapedReplacement) {
+		return json.Marshal(v)
+	}
+	return out, nil
+}
+
+// New returns the encoder called name; an empty name is std
+func New(name string) (Encoder, error) {
+	switch strings.ToLower(name) {
+	case "", NameStd:
+		return Std, nil
+	case NameJsoniter:
+		return compatEncoder{name: NameJsoniter, marshal: jsoniter.ConfigCompatibleWithStandardLibrary.Marshal}, nil
+	case NameSonic:
+		return compatEncoder{name: NameSonic, marshal: sonic.ConfigStd.Marshal}, nil
+	}
+	return nil, fmt.Errorf("unsupported JSON encoder %q (want %s, %s or %s)", name, NameStd, NameJsoniter, NameSonic)
+}
+
+// Verify marshals every golden value with enc and with encoding/json and returns an error
+// describing the first value whose bytes differ
+func Verify(enc Encoder, golden ...any) error {
+	for i, v := range golden {
+		want, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("golden value %d: encoding/json: %w", i, err)
+		}
+		got, err := enc.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("golden value %d: %s: %w", i, enc.Name(), err)
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("golden value %d: %s output differs from encoding/json at byte %d", i, enc.Name(), firstDiff(got, want))
+		}
+	}
+	return nil
+}
+
+// firstDiff returns the offset of the first differing byte of a and b
+func firstDiff(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
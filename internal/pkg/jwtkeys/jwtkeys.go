@@ -0,0 +1,184 @@
+// Package jwtkeys holds the HMAC keys access tokens are signed and verified with.
+//
+// A keyring names each key with an ID, written as the token's "kid" header, so several keys
+// can verify tokens at once and rotating one does not sign everybody out: the new key is put
+// first and signs from then on, while the previous one keeps verifying the tokens it signed.
+// A key is stamped with the time it stopped signing; once JWT_EXPIRATION has passed since,
+// every token it signed has expired and the keyring drops it on its own. Tokens without a kid,
+// issued before keys were named, are verified with JWT_SECRET.
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Token errors
+var (
+	ErrUnknownKey = errors.New("token signed with an unknown key")
+	ErrRetiredKey = errors.New("token signed with a retired key")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Key is one signing key
+type Key struct {
+	ID     string
+	Secret []byte
+	// RetiredAt is when the key stopped signing, zero for a key never rotated out
+	RetiredAt time.Time
+}
+
+// ParseKeys reads JWT_KEYS entries, "id:secret" or "id:secret:retired at" with the time in
+// RFC 3339. The first entry signs, so it cannot be retired.
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		id, rest, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			// The entry itself is never echoed: it holds the key
+			return nil, fmt.Errorf("JWT key %d: want \"id:secret\" with an id of letters, digits, - and _", i+1)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("JWT key %q is listed twice", id)
+		}
+		seen[id] = true
+		key := Key{ID: id}
+		secret, retired, stamped := strings.Cut(rest, ":")
+		if stamped {
+			t, err := time.Parse(time.RFC3339, retired)
+			if err != nil {
+				return nil, fmt.Errorf("JWT key %q: retirement time must be RFC 3339", id)
+			}
+			if i == 0 {
+				return nil, fmt.Errorf("JWT key %q signs new tokens, so it cannot be retired", id)
+			}
+			key.RetiredAt = t
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("JWT key %q has no secret", id)
+		}
+		key.Secret = []byte(secret)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatKey writes key as a JWT_KEYS entry
+func FormatKey(key Key) string {
+	s := key.ID + ":" + string(key.Secret)
+	if !key.RetiredAt.IsZero() {
+		s += ":" + key.RetiredAt.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Expired reports whether every token key signed has expired at now, tokens living for
+// lifetime, so the key can be dropped
+func (key Key) Expired(now time.Time, lifetime time.Duration) bool {
+	return !key.RetiredAt.IsZero() && now.After(key.RetiredAt.Add(lifetime))
+}
+
+// Keyring signs with its first key and verifies with any it holds
+type Keyring struct {
+	mu       sync.RWMutex
+	legacy   []byte // JWT_SECRET, for tokens without a kid
+	keys     []Key
+	lifetime time.Duration
+}
+
+// Default is the process-wide keyring, empty until Configure
+var Default = &Keyring{}
+
+// Configure replaces the keys, e.g. on startup or a configuration reload: secret is
+// JWT_SECRET, entries JWT_KEYS and lifetime JWT_EXPIRATION. Without entries tokens are
+// signed with secret and carry no kid, as before keys were named.
+func (k *Keyring) Configure(secret string, entries []string, lifetime time.Duration) error {
+	keys, err := ParseKeys(entries)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.legacy, k.keys, k.lifetime = []byte(secret), keys, lifetime
+	return nil
+}
+
+// Current returns the key new tokens are signed with; its ID is "" while only JWT_SECRET is set
+func (k *Keyring) Current() Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) > 0 {
+		return k.keys[0]
+	}
+	return Key{Secret: k.legacy}
+}
+
+// Keys returns the named keys, the signing one first
+func (k *Keyring) Keys() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]Key(nil), k.keys...)
+}
+
+// Sign issues an HS256 token for claims, naming its key in the kid header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.Current()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.Secret)
+}
+
+// Lookup returns the secret that verifies a token with the given kid header ("" for none)
+func (k *Keyring) Lookup(kid string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if kid == "" {
+		if len(k.legacy) == 0 {
+			return nil, ErrUnknownKey
+		}
+		return k.legacy, nil
+	}
+	for _, key := range k.keys {
+		if key.ID == kid {
+			if key.Expired(time.Now(), k.lifetime) {
+				return nil, ErrRetiredKey
+			}
+			return key.Secret, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Keyfunc is the jwt.Keyfunc verifying HMAC tokens with the key their kid names
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	kid, _ := token.Header["kid"].(string)
+	return k.Lookup(kid)
+}
+
+// SecretFor returns the secret of the key named by tokenString's kid header, without
+// verifying the token; nil when there is none
+func (k *Keyring) SecretFor(tokenString string) []byte {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	secret, err := k.Lookup(kid)
+	if err != nil {
+		return nil
+	}
+	return secret
+}
@@ -0,0 +1,221 @@
+// Package lock provides locks shared by every instance of the service, so work that must
+// happen once (a scheduled job, a cache warm) is done by one of them. With Redis attached a
+// lock is a key set only if absent, holding a random token and expiring after its TTL, so a
+// crashed holder cannot keep it; without Redis locks are per process, which is enough for a
+// single instance.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultPrefix is prepended to lock names to form their Redis keys
+const DefaultPrefix = "cms:lock:"
+
+// ErrNotAcquired is returned by Acquire when another holder has the lock
+var ErrNotAcquired = errors.New("lock is held by another instance")
+
+// ErrLost is returned by Refresh, and is the cause of Keep's context, once the lock has
+// expired or was taken over
+var ErrLost = errors.New("lock was lost")
+
+// The token check makes refreshing and releasing safe against a lock that already expired
+// and was taken by someone else
+var (
+	refreshScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+	releaseScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+)
+
+// Locker hands out locks, across instances once Redis is attached
+type Locker struct {
+	mu     sync.RWMutex
+	redis  redis.UniversalClient
+	prefix string
+
+	localMu sync.Mutex
+	local   map[string]localLock
+}
+
+type localLock struct {
+	token   string
+	expires time.Time
+}
+
+// NewLocker creates a locker whose locks are per process until AttachRedis
+func NewLocker() *Locker {
+	return &Locker{local: make(map[string]localLock)}
+}
+
+// Default is the process-wide locker
+var Default = NewLocker()
+
+// AttachRedis shares the locks with every instance using client, under keys starting with prefix
+func (l *Locker) AttachRedis(client redis.UniversalClient, prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redis, l.prefix = client, prefix
+}
+
+// Distributed reports whether locks are shared with other instances
+func (l *Locker) Distributed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.redis != nil
+}
+
+func (l *Locker) client() (redis.UniversalClient, string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.redis, l.prefix
+}
+
+// Acquire takes the lock name for ttl, or fails with ErrNotAcquired while someone else holds
+// it. Other errors mean Redis could not be asked; the lock is not held then either.
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	lk := &Lock{locker: l, name: name, token: token, ttl: ttl}
+
+	client, prefix := l.client()
+	if client == nil {
+		l.localMu.Lock()
+		defer l.localMu.Unlock()
+		if held, ok := l.local[name]; ok && time.Now().Before(held.expires) {
+			return nil, ErrNotAcquired
+		}
+		l.local[name] = localLock{token: token, expires: time.Now().Add(ttl)}
+		return lk, nil
+	}
+
+	ok, err := client.SetNX(ctx, prefix+name, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return lk, nil
+}
+
+// Do runs fn holding the lock name, refreshed while fn runs, and releases it afterwards.
+// fn's context is cancelled if the lock is lost. Do returns ErrNotAcquired without running
+// fn while someone else holds the lock.
+func (l *Locker) Do(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lk, err := l.Acquire(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	held, stop := lk.Keep(ctx)
+	defer func() {
+		stop()
+		// The caller's context may be done by now; releasing must still be tried
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		lk.Release(releaseCtx)
+	}()
+	return fn(held)
+}
+
+// Lock is a held lock
+type Lock struct {
+	locker *Locker
+	name   string
+	token  string
+	ttl    time.Duration
+}
+
+// Name is the name the lock was acquired under
+func (k *Lock) Name() string {
+	return k.name
+}
+
+// Refresh extends the lock to ttl from now, failing with ErrLost once it has expired or
+// been taken over
+func (k *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	client, prefix := k.locker.client()
+	if client == nil {
+		k.locker.localMu.Lock()
+		defer k.locker.localMu.Unlock()
+		held, ok := k.locker.local[k.name]
+		if !ok || held.token != k.token || time.Now().After(held.expires) {
+			return ErrLost
+		}
+		k.locker.local[k.name] = localLock{token: k.token, expires: time.Now().Add(ttl)}
+		return nil
+	}
+
+	n, err := refreshScript.Run(ctx, client, []string{prefix + k.name}, k.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+// Release gives the lock up, if it is still held
+func (k *Lock) Release(ctx context.Context) error {
+	client, prefix := k.locker.client()
+	if client == nil {
+		k.locker.localMu.Lock()
+		defer k.locker.localMu.Unlock()
+		if held, ok := k.locker.local[k.name]; ok && held.token == k.token {
+			delete(k.locker.local, k.name)
+		}
+		return nil
+	}
+	return releaseScript.Run(ctx, client, []string{prefix + k.name}, k.token).Err()
+}
+
+// Keep refreshes the lock every third of its TTL until stop is called. The returned context
+// is cancelled, with ErrLost as its cause, if a refresh finds the lock gone; a refresh that
+// fails to reach Redis is retried at the next tick, as the lock may still be valid.
+func (k *Lock) Keep(ctx context.Context) (held context.Context, stop func()) {
+	held, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(max(k.ttl/3, 10*time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-held.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := k.Refresh(held, k.ttl); errors.Is(err, ErrLost) {
+				cancel(ErrLost)
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return held, func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+			cancel(nil)
+		})
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,122 @@
+// Package logging configures the process-wide structured logger and carries a per-request
+// logger in the context.
+//
+// Setup installs a log/slog logger as the default, which also routes the standard log
+// package through it, so lines from code that still calls log.Printf come out in the same
+// format. Lines logged with a context that carries a request ID get a request_id attribute.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"adminbe/internal/pkg/tracing"
+)
+
+// Supported LOG_FORMAT values
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// KeyRequestID is the attribute carrying the request ID on every request-scoped line
+const KeyRequestID = "request_id"
+
+// Config selects the log format and minimum level
+type Config struct {
+	Format string     `yaml:"format" env:"LOG_FORMAT" default:"json"`
+	Level  slog.Level `yaml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+// Validate accepts a Format of json or text in any case
+func (c *Config) Validate() error {
+	c.Format = strings.ToLower(c.Format)
+	if c.Format != FormatJSON && c.Format != FormatText {
+		return fmt.Errorf("unsupported LOG_FORMAT %q (want %s or %s)", c.Format, FormatJSON, FormatText)
+	}
+	return nil
+}
+
+// level is the minimum level of the logger installed by Setup; SetLevel changes it at runtime
+var level = new(slog.LevelVar)
+
+// Setup makes a logger writing to w with cfg the slog and log package default
+func Setup(w io.Writer, cfg Config) *slog.Logger {
+	level.Set(cfg.Level)
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if cfg.Format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// contextHandler adds the request ID of the record's context, when the logger was not
+// already given one, so slog.InfoContext(ctx, ...) is correlated too
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := tracing.RequestID(ctx); id != "" && !hasRequestID(r) {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for _, a := range attrs {
+		if a.Key == KeyRequestID {
+			// Already correlated; skip the per-record lookup
+			return h.Handler.WithAttrs(attrs)
+		}
+	}
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func hasRequestID(r slog.Record) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == KeyRequestID
+		return !found
+	})
+	return found
+}
+
+// Level returns the current minimum log level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the minimum log level of the running process
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns ctx carrying l
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,219 @@
+// Package mail sends the emails the application writes to its users: account notices and
+// delivered reports. Messages are rendered from named templates (see templates.go) and handed
+// to the configured driver: an SMTP server, an HTTP mail API (SendGrid's v3 send endpoint,
+// which several providers also accept), or the log, for development. Queueing and retrying
+// are left to the caller; the services layer keeps a delivery log of every attempt.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Drivers
+const (
+	DriverNone     = "none"
+	DriverLog      = "log"
+	DriverSMTP     = "smtp"
+	DriverSendGrid = "sendgrid"
+)
+
+// SMTP connection security
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+// ErrDisabled is returned by Send while the driver is none
+var ErrDisabled = errors.New("mail is disabled")
+
+// Config is the mail section of the configuration
+type Config struct {
+	// Driver is none (send nothing), log, smtp or sendgrid
+	Driver   string `yaml:"driver" env:"MAIL_DRIVER" default:"none"`
+	From     string `yaml:"from" env:"MAIL_FROM"`
+	FromName string `yaml:"from_name" env:"MAIL_FROM_NAME" default:"Admin"`
+	// AppURL is where the links in messages point, e.g. the admin console
+	AppURL string `yaml:"app_url" env:"MAIL_APP_URL"`
+	// TemplatesDir holds <name>.tmpl files replacing the built-in templates of the same name
+	TemplatesDir string `yaml:"templates_dir" env:"MAIL_TEMPLATES_DIR"`
+
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" default:"587" min:"1" max:"65535"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	// SMTPTLS is starttls (upgrade on the submission port), tls (implicit, port 465) or none
+	SMTPTLS string `yaml:"smtp_tls" env:"SMTP_TLS" default:"starttls"`
+
+	APIKey string `yaml:"api_key" env:"MAIL_API_KEY"`
+	APIURL string `yaml:"api_url" env:"MAIL_API_URL" default:"https://api.sendgrid.com/v3/mail/send"`
+
+	Workers int           `yaml:"workers" env:"MAIL_WORKERS" default:"2" min:"1" max:"32"`
+	Timeout time.Duration `yaml:"timeout" env:"MAIL_TIMEOUT" default:"30s"`
+	// MaxAttempts is how often a message is tried before it is marked failed
+	MaxAttempts int `yaml:"max_attempts" env:"MAIL_MAX_ATTEMPTS" default:"5" min:"1" max:"20"`
+	// RetryBackoff is the wait before the first retry, doubled after each failure
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"MAIL_RETRY_BACKOFF" default:"1m"`
+	// MaxAttachmentBytes bounds a single attachment; larger files are left out of the message
+	MaxAttachmentBytes int64 `yaml:"max_attachment_bytes" env:"MAIL_MAX_ATTACHMENT_BYTES" default:"10485760" min:"0"`
+}
+
+// Validate checks the driver has what it needs
+func (c *Config) Validate() error {
+	c.Driver = strings.ToLower(strings.TrimSpace(c.Driver))
+	c.SMTPTLS = strings.ToLower(strings.TrimSpace(c.SMTPTLS))
+	switch c.Driver {
+	case DriverNone:
+		return nil
+	case DriverLog:
+	case DriverSMTP:
+		if c.SMTPHost == "" {
+			return errors.New("MAIL_DRIVER=smtp needs SMTP_HOST")
+		}
+		switch c.SMTPTLS {
+		case TLSStartTLS, TLSImplicit, TLSNone:
+		default:
+			return fmt.Errorf("SMTP_TLS must be starttls, tls or none, not %q", c.SMTPTLS)
+		}
+	case DriverSendGrid:
+		if c.APIKey == "" {
+			return errors.New("MAIL_DRIVER=sendgrid needs MAIL_API_KEY")
+		}
+		if !strings.HasPrefix(c.APIURL, "https://") && !strings.HasPrefix(c.APIURL, "http://") {
+			return errors.New("MAIL_API_URL must be an http or https URL")
+		}
+	default:
+		return fmt.Errorf("MAIL_DRIVER must be none, log, smtp or sendgrid, not %q", c.Driver)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("MAIL_FROM must be an email address: %w", err)
+	}
+	if c.Timeout <= 0 {
+		return errors.New("MAIL_TIMEOUT must be positive")
+	}
+	return nil
+}
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a rendered email to one recipient
+type Message struct {
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Sender delivers messages; each driver is one
+type Sender interface {
+	Send(ctx context.Context, from mail.Address, msg *Message) error
+}
+
+// Mailer renders and sends messages with the configured driver and templates
+type Mailer struct {
+	mu        sync.RWMutex
+	cfg       Config
+	sender    Sender
+	templates *Templates
+}
+
+// Default is the process-wide mailer, sending nothing until Configure
+var Default = &Mailer{}
+
+// Configure replaces the driver and reloads the templates, e.g. on startup or a
+// configuration reload
+func (m *Mailer) Configure(cfg Config) error {
+	templates, err := LoadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return err
+	}
+	var sender Sender
+	switch cfg.Driver {
+	case DriverLog:
+		sender = logSender{}
+	case DriverSMTP:
+		sender = &smtpSender{cfg: cfg}
+	case DriverSendGrid:
+		sender = newSendGridSender(cfg)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg, m.sender, m.templates = cfg, sender, templates
+	return nil
+}
+
+// Enabled reports whether a driver is configured
+func (m *Mailer) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sender != nil
+}
+
+// Config returns the configuration in effect
+func (m *Mailer) Config() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+// Render fills in template name for the recipient to. data gains app_name and app_url unless
+// it sets them.
+func (m *Mailer) Render(name, to string, data map[string]any) (*Message, error) {
+	m.mu.RLock()
+	templates, cfg := m.templates, m.cfg
+	m.mu.RUnlock()
+	if templates == nil {
+		return nil, ErrDisabled
+	}
+
+	values := map[string]any{"app_name": cfg.FromName, "app_url": cfg.AppURL}
+	for k, v := range data {
+		values[k] = v
+	}
+	msg, err := templates.Render(name, values)
+	if err != nil {
+		return nil, err
+	}
+	msg.To = to
+	return msg, nil
+}
+
+// Send delivers msg once, bounded by MAIL_TIMEOUT
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	m.mu.RLock()
+	sender, cfg := m.sender, m.cfg
+	m.mu.RUnlock()
+	if sender == nil {
+		return ErrDisabled
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	return sender.Send(ctx, mail.Address{Name: cfg.FromName, Address: cfg.From}, msg)
+}
+
+// logSender writes messages to the log instead of sending them
+type logSender struct{}
+
+func (logSender) Send(_ context.Context, from mail.Address, msg *Message) error {
+	slog.Info("Mail (log driver, not sent)", "from", from.Address, "to", msg.To, "subject", msg.Subject,
+		"attachments", len(msg.Attachments), "text", msg.Text)
+	return nil
+}
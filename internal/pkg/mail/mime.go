@@ -0,0 +1,153 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Build writes msg as an RFC 5322 message: the text and HTML bodies as alternatives, and
+// the attachments after them
+func Build(from mail.Address, msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from.Address, "@"); ok {
+		domain = d
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	header := func(name, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", name, value) }
+	header("From", from.String())
+	header("To", (&mail.Address{Address: msg.To}).String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+
+	body, err := renderBody(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Attachments) == 0 {
+		for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if v := body.header.Get(name); v != "" {
+				header(name, v)
+			}
+		}
+		buf.WriteString("\r\n")
+		buf.Write(body.content)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+	part, err := mixed.CreatePart(body.header)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(body.content)
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mimePart is an encoded part with its headers
+type mimePart struct {
+	header  textproto.MIMEHeader
+	content []byte
+}
+
+// renderBody encodes the text, or the text and HTML as alternatives
+func renderBody(msg *Message) (*mimePart, error) {
+	textPart := func(contentType, s string) (*mimePart, error) {
+		var buf bytes.Buffer
+		if err := writeQuotedPrintable(&buf, s); err != nil {
+			return nil, err
+		}
+		return &mimePart{
+			header: textproto.MIMEHeader{
+				"Content-Type":              {contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			},
+			content: buf.Bytes(),
+		}, nil
+	}
+	if msg.HTML == "" {
+		return textPart("text/plain; charset=utf-8", msg.Text)
+	}
+
+	var buf bytes.Buffer
+	alt := multipart.NewWriter(&buf)
+	for _, body := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		p, err := textPart(body.contentType, body.content)
+		if err != nil {
+			return nil, err
+		}
+		w, err := alt.CreatePart(p.header)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(p.content)
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+	return &mimePart{
+		header:  textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alt.Boundary()}},
+		content: buf.Bytes(),
+	}, nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := fmt.Fprintf(w, "%s\r\n", encoded)
+	return err
+}
@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates
+const (
+	TemplateWelcome         = "welcome"
+	TemplatePasswordReset   = "password_reset"
+	TemplatePasswordExpired = "password_expired"
+	TemplateReportReady     = "report_ready"
+	TemplateRoleChanged     = "role_changed"
+)
+
+// A template is one <name>.tmpl file defining three blocks: "subject" and "text", executed
+// as text, and "html", executed with HTML escaping. "html" may be left out for a plain text
+// message. A template using a value it is not given fails to render, rather than sending
+// "<no value>".
+//
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// Templates are the parsed message templates, by name
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the built-in templates, replacing them by the files of dir, when set,
+// with the same name
+func LoadTemplates(dir string) (*Templates, error) {
+	sources := map[string][]byte{}
+	entries, err := builtin.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtin.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		sources[strings.TrimSuffix(e.Name(), ".tmpl")] = data
+	}
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("mail template: %w", err)
+			}
+			sources[strings.TrimSuffix(filepath.Base(file), ".tmpl")] = data
+		}
+	}
+
+	t := &Templates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+	for name, src := range sources {
+		text, err := texttemplate.New(name).Option("missingkey=error").Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("mail template %s: %w", name, err)
+		}
+		if text.Lookup("subject") == nil || text.Lookup("text") == nil {
+			return nil, fmt.Errorf("mail template %s must define \"subject\" and \"text\"", name)
+		}
+		t.text[name] = text
+		if text.Lookup("html") != nil {
+			html, err := htmltemplate.New(name).Option("missingkey=error").Parse(string(src))
+			if err != nil {
+				return nil, fmt.Errorf("mail template %s: %w", name, err)
+			}
+			t.html[name] = html
+		}
+	}
+	return t, nil
+}
+
+// Names lists the templates, sorted
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.text))
+	for name := range t.text {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes template name with data
+func (t *Templates) Render(name string, data map[string]any) (*Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("mail template %s: %w", name, err)
+	}
+	if err := text.ExecuteTemplate(&body, "text", data); err != nil {
+		return nil, fmt.Errorf("mail template %s: %w", name, err)
+	}
+	msg := &Message{
+		// A header cannot span lines
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+	}
+	if html, ok := t.html[name]; ok {
+		var buf bytes.Buffer
+		if err := html.ExecuteTemplate(&buf, "html", data); err != nil {
+			return nil, fmt.Errorf("mail template %s: %w", name, err)
+		}
+		msg.HTML = strings.TrimSpace(buf.String()) + "\n"
+	}
+	return msg, nil
+}
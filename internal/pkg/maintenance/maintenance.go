@@ -0,0 +1,149 @@
+// Package maintenance holds the maintenance mode switch. While it is on, the API answers
+// 503 to everyone but the users it lets through; operational endpoints keep working. With
+// Redis attached the switch is shared by every instance, which pick up changes within
+// PollInterval; without it the switch is per process.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultKey is the Redis key the state is stored under
+const DefaultKey = "cms:maintenance"
+
+// PollInterval is how often an instance rereads the state from Redis
+const PollInterval = 2 * time.Second
+
+// DefaultRetryAfter is the Retry-After of a state that sets none
+const DefaultRetryAfter = 5 * time.Minute
+
+// State is the maintenance mode as operators set it
+type State struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+	// RetryAfterSeconds is what rejected clients are told to wait
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+	// AllowedUsers keep using the API, e.g. the administrators checking the maintenance
+	AllowedUsers []uint64  `json:"allowed_users"`
+	UpdatedBy    uint64    `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RetryAfter is the wait rejected clients are told, DefaultRetryAfter when none is set
+func (s State) RetryAfter() time.Duration {
+	if s.RetryAfterSeconds <= 0 {
+		return DefaultRetryAfter
+	}
+	return time.Duration(s.RetryAfterSeconds) * time.Second
+}
+
+// Allows reports whether userID may use the API during the maintenance
+func (s State) Allows(userID uint64) bool {
+	return userID != 0 && slices.Contains(s.AllowedUsers, userID)
+}
+
+// Switch is the maintenance mode of this process, mirrored from Redis once attached
+type Switch struct {
+	mu    sync.RWMutex
+	state State
+
+	redis  redis.UniversalClient
+	key    string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSwitch creates a switch that is off
+func NewSwitch() *Switch {
+	return &Switch{}
+}
+
+// Default is the process-wide switch
+var Default = NewSwitch()
+
+// Current returns the state in effect on this instance
+func (s *Switch) Current() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Set changes the state, on every instance once Redis is attached. Nothing changes when
+// Redis cannot store it, so instances never disagree for longer than a poll.
+func (s *Switch) Set(ctx context.Context, state State) error {
+	if s.redis != nil {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		if err := s.redis.Set(ctx, s.key, data, 0).Err(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return nil
+}
+
+// AttachRedis shares the switch through key, loading the stored state now and polling it
+// from then on. Call it once, at startup.
+func (s *Switch) AttachRedis(client redis.UniversalClient, key string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.redis, s.key, s.cancel, s.done = client, key, cancel, make(chan struct{})
+	s.refresh(ctx)
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// refresh loads the stored state; on errors the last one known stays in effect
+func (s *Switch) refresh(ctx context.Context) {
+	data, err := s.redis.Get(ctx, s.key).Bytes()
+	var state State
+	switch {
+	case errors.Is(err, redis.Nil):
+		// Never set: off
+	case err != nil:
+		if ctx.Err() == nil {
+			slog.Warn("Failed to read the maintenance state, keeping the last one", "error", err)
+		}
+		return
+	default:
+		if err := json.Unmarshal(data, &state); err != nil {
+			slog.Warn("Ignoring an invalid maintenance state in Redis", "key", s.key, "error", err)
+			return
+		}
+	}
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+// Close stops polling Redis; it is safe to call more than once and without Redis
+func (s *Switch) Close() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
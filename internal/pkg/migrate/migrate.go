@@ -0,0 +1,371 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TableName records which migrations have been applied
+const TableName = "schema_migrations"
+
+// ErrSchemaOutdated is returned by Check when migrations are pending
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// ErrDirty is returned when a previous migration failed half-way and needs manual repair
+var ErrDirty = errors.New("database schema is dirty")
+
+// fileNamePattern matches 0001_initial_schema.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Dirty     bool       `json:"dirty,omitempty"`
+}
+
+// Migrator applies migrations loaded from a file system to a MySQL or PostgreSQL database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads every migration in fsys and returns a migrator for db
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads and pairs the up/down files in fsys, sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// ensureTable creates the bookkeeping table if needed
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+TableName+` (
+			version BIGINT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			dirty SMALLINT NOT NULL DEFAULT 0,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (version)
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", TableName, err)
+	}
+	return nil
+}
+
+// applied returns the recorded migrations keyed by version
+func (m *Migrator) applied(ctx context.Context) (map[int64]Status, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, name, dirty, applied_at FROM "+TableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", TableName, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]Status)
+	for rows.Next() {
+		var s Status
+		var appliedAt time.Time
+		if err := rows.Scan(&s.Version, &s.Name, &s.Dirty, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", TableName, err)
+		}
+		s.AppliedAt = &appliedAt
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s, ok := applied[mig.Version]
+		if !ok {
+			s = Status{Version: mig.Version, Name: mig.Name}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns how many ran
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkDirty(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %d_%s", mig.Version, mig.Name)
+		if _, err := m.db.ExecContext(ctx, "INSERT INTO "+TableName+" (version, name, dirty) VALUES (?, ?, 1)", mig.Version, mig.Name); err != nil {
+			return count, fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+		if err := m.exec(ctx, mig.Up); err != nil {
+			return count, fmt.Errorf("migration %d_%s failed, schema left dirty: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.db.ExecContext(ctx, "UPDATE "+TableName+" SET dirty = 0, applied_at = NOW() WHERE version = ?", mig.Version); err != nil {
+			return count, fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the latest steps applied migrations and returns how many ran
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkDirty(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return count, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+
+		log.Printf("Reverting migration %d_%s", mig.Version, mig.Name)
+		if _, err := m.db.ExecContext(ctx, "UPDATE "+TableName+" SET dirty = 1 WHERE version = ?", mig.Version); err != nil {
+			return count, fmt.Errorf("failed to mark migration %d: %w", mig.Version, err)
+		}
+		if err := m.exec(ctx, mig.Down); err != nil {
+			return count, fmt.Errorf("revert of %d_%s failed, schema left dirty: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.db.ExecContext(ctx, "DELETE FROM "+TableName+" WHERE version = ?", mig.Version); err != nil {
+			return count, fmt.Errorf("failed to unrecord migration %d: %w", mig.Version, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Force marks version and everything before it as applied and clean, and removes later records.
+// Used to recover from a dirty state after fixing the schema by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM "+TableName+" WHERE version > ?", version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		// UPDATE or INSERT rather than an upsert so the statements work on every engine
+		query := "INSERT INTO " + TableName + " (version, name, dirty) VALUES (?, ?, 0)"
+		args := []interface{}{mig.Version, mig.Name}
+		if _, ok := applied[mig.Version]; ok {
+			query = "UPDATE " + TableName + " SET dirty = 0 WHERE version = ?"
+			args = args[:1]
+		}
+		if _, err := m.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to force version %d: %w", mig.Version, err)
+		}
+	}
+	return nil
+}
+
+// Check returns ErrSchemaOutdated when migrations are pending and ErrDirty when one failed half-way
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range statuses {
+		if s.Dirty {
+			return fmt.Errorf("%w: migration %d_%s did not complete", ErrDirty, s.Version, s.Name)
+		}
+		if s.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// exec runs every statement of a migration on a single connection so session settings
+// such as SET FOREIGN_KEY_CHECKS apply to the whole file. MySQL commits DDL implicitly,
+// so a failing migration is not rolled back; it is left marked dirty instead.
+func (m *Migrator) exec(ctx context.Context, script string) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, stmt := range SplitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nstatement: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// checkDirty refuses to continue while a migration is half-applied
+func checkDirty(applied map[int64]Status) error {
+	for _, s := range applied {
+		if s.Dirty {
+			return fmt.Errorf("%w: migration %d_%s did not complete; fix it by hand and run force", ErrDirty, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// SplitStatements splits a SQL script on semicolons that are outside quotes and comments.
+// Comments are dropped; empty statements are skipped.
+func SplitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      rune
+	)
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if quote != 0 {
+			current.WriteRune(r)
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			current.WriteRune(r)
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			current.WriteRune('\n')
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case r == ';':
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
@@ -0,0 +1,250 @@
+// Package notify delivers notifications to the users they are addressed to, over the /ws
+// connections those users have open. With Redis attached a notification reaches the user's
+// connections on every instance. Like the rest of the live channels it is best effort:
+// nothing is stored for users who are not connected.
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/events"
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Notification types
+const (
+	TypeRoleGranted    = "role_granted"    // the user was given a role
+	TypeReportFinished = "report_finished" // a report the user ran is ready
+	TypeUserLocked     = "user_locked"     // the user's account was disabled
+	TypeExportFinished = "export_finished" // an export job the user created succeeded or failed
+)
+
+// DefaultChannel is the Redis pub/sub channel notifications cross instances on
+const DefaultChannel = "cms:notifications"
+
+var (
+	connectionGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "notify",
+		Name:      "connections",
+		Help:      "Notification connections open on this instance.",
+	})
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "notify",
+		Name:      "notifications_total",
+		Help:      "Notifications handed to connections on this instance, by type.",
+	}, []string{"type"})
+	droppedConnections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "notify",
+		Name:      "dropped_connections_total",
+		Help:      "Notification connections closed because they fell behind.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(connectionGauge, notificationsSent, droppedConnections)
+}
+
+// Notification is one message to a user
+type Notification struct {
+	ID      string         `json:"id"`
+	Type    string         `json:"type"`
+	Message string         `json:"message"`
+	Data    map[string]any `json:"data,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+// envelope carries a notification to the other instances
+type envelope struct {
+	UserID       uint64       `json:"user_id"`
+	Origin       string       `json:"origin"`
+	Notification Notification `json:"notification"`
+}
+
+// Conn is one open connection of a user. Notifications arrive on C, which is closed when
+// the connection is closed or dropped for falling behind.
+type Conn struct {
+	C <-chan Notification
+
+	userID  uint64
+	ch      chan Notification
+	hub     *Hub
+	lagging bool // guarded by hub.mu
+}
+
+// Hub routes notifications to the connections of their users
+type Hub struct {
+	mu         sync.Mutex
+	conns      map[uint64]map[*Conn]struct{}
+	instanceID string
+
+	redis   redis.UniversalClient
+	channel string
+	cancel  context.CancelFunc
+}
+
+// NewHub creates a hub; instanceID tells its notifications from other instances' on Redis
+func NewHub(instanceID string) *Hub {
+	return &Hub{conns: make(map[uint64]map[*Conn]struct{}), instanceID: instanceID}
+}
+
+// Default is the process-wide hub; it shares the event bus's instance ID
+var Default = NewHub(events.Default.InstanceID())
+
+// Register opens a connection for userID holding up to buffer undelivered notifications
+func (h *Hub) Register(userID uint64, buffer int) *Conn {
+	ch := make(chan Notification, max(buffer, 1))
+	c := &Conn{C: ch, userID: userID, ch: ch, hub: h}
+	h.mu.Lock()
+	if h.conns[userID] == nil {
+		h.conns[userID] = make(map[*Conn]struct{})
+	}
+	h.conns[userID][c] = struct{}{}
+	h.mu.Unlock()
+	connectionGauge.Inc()
+	return c
+}
+
+// Close ends the connection; it is safe to call more than once
+func (c *Conn) Close() {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.remove(c)
+}
+
+// Lagging reports whether the connection was dropped for falling behind
+func (c *Conn) Lagging() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return c.lagging
+}
+
+// remove closes c if it is still registered; h.mu must be held
+func (h *Hub) remove(c *Conn) {
+	conns := h.conns[c.userID]
+	if _, ok := conns[c]; !ok {
+		return
+	}
+	delete(conns, c)
+	if len(conns) == 0 {
+		delete(h.conns, c.userID)
+	}
+	close(c.ch)
+	connectionGauge.Dec()
+}
+
+// Send notifies userID on every instance, filling in the ID and time when unset
+func (h *Hub) Send(userID uint64, n Notification) {
+	if n.ID == "" {
+		n.ID = newID()
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	h.deliver(userID, n)
+
+	h.mu.Lock()
+	client, channel := h.redis, h.channel
+	h.mu.Unlock()
+	if client == nil {
+		return
+	}
+	payload, err := json.Marshal(envelope{UserID: userID, Origin: h.instanceID, Notification: n})
+	if err != nil {
+		slog.Error("Failed to encode notification", "type", n.Type, "error", err)
+		return
+	}
+	if err := client.Publish(context.Background(), channel, payload).Err(); err != nil {
+		slog.Warn("Failed to broadcast notification", "type", n.Type, "error", err)
+	}
+}
+
+// deliver hands n to userID's connections on this instance, dropping any whose buffer is full
+func (h *Hub) deliver(userID uint64, n Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.conns[userID] {
+		select {
+		case c.ch <- n:
+			notificationsSent.WithLabelValues(n.Type).Inc()
+		default:
+			c.lagging = true
+			h.remove(c)
+			droppedConnections.Inc()
+		}
+	}
+}
+
+// AttachRedis broadcasts sent notifications over Redis pub/sub and delivers those sent on
+// other instances to local connections
+func (h *Hub) AttachRedis(client redis.UniversalClient, channel string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mu.Lock()
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.redis = client
+	h.channel = channel
+	h.cancel = cancel
+	h.mu.Unlock()
+
+	pubsub := client.Subscribe(ctx, channel)
+	go func() {
+		defer pubsub.Close()
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("Notification subscription error", "error", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			var e envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				slog.Warn("Failed to decode notification", "error", err)
+				continue
+			}
+			if e.Origin == h.instanceID {
+				continue
+			}
+			h.deliver(e.UserID, e.Notification)
+		}
+	}()
+}
+
+// Close stops the Redis subscription, if any
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+	}
+	h.redis = nil
+}
+
+// Send notifies userID through the default hub
+func Send(userID uint64, n Notification) {
+	Default.Send(userID, n)
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,490 @@
+// Package openapi builds the OpenAPI 3 description of the API. Operations are declared next
+// to the routes; request and response schemas are derived from the Go types by reflection,
+// using their json names and binding rules, so the document follows the models as they
+// change. Missing lists the routes the document does not describe.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Tags       []Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+	Security   []map[string][]string           `json:"security,omitempty"`
+
+	routes   map[string]bool   // "GET /api/users/:id"
+	patterns map[string]string // custom binding tag -> regular expression
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL of the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components holds the schemas referenced by operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+	Responses       map[string]Response       `json:"responses,omitempty"`
+}
+
+// SecurityScheme is how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	// In and Name locate an apiKey: header, query or cookie, and its name
+	In   string `json:"in,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Operation is one method on one path
+type Operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+	Ref         string  `json:"$ref,omitempty"`
+}
+
+// RequestBody is an operation's body
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one status of an operation
+type Response struct {
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	Ref         string               `json:"$ref,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType is the body of one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+}
+
+// Typer is implemented by types that decode as another type, e.g. models.Patch; the
+// schema describes the value SchemaType returns instead, as nullable
+type Typer interface {
+	SchemaType() any
+}
+
+// New returns an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]Operation{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{},
+		},
+		routes:   map[string]bool{},
+		patterns: map[string]string{},
+	}
+}
+
+// Pattern describes a custom binding tag as a regular expression, for the schemas of
+// fields using it. Call it before adding the types.
+func (d *Document) Pattern(tag, pattern string) {
+	d.patterns[tag] = pattern
+}
+
+// Add describes method on a gin route template; ":name" and "*name" segments become path
+// parameters, which are declared unless op already declares them
+func (d *Document) Add(method, route string, op Operation) {
+	var segments []string
+	for _, seg := range strings.Split(route, "/") {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			name := seg[1:]
+			if !hasParam(op.Parameters, name) {
+				op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: pathParamSchema(name)})
+			}
+			seg = "{" + name + "}"
+		}
+		segments = append(segments, seg)
+	}
+	path := strings.Join(segments, "/")
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]Operation{}
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]Response{}
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+	d.routes[method+" "+route] = true
+}
+
+// Missing returns the routes the document does not describe, and the operations it
+// describes that no route serves, as "METHOD /route" sorted
+func (d *Document) Missing(routes gin.RoutesInfo) (undocumented, stale []string) {
+	served := map[string]bool{}
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		served[key] = true
+		if !d.routes[key] {
+			undocumented = append(undocumented, key)
+		}
+	}
+	for key := range d.routes {
+		if !served[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(stale)
+	return undocumented, stale
+}
+
+// Schema returns the schema of v's type: a reference to a component for named structs,
+// which are added to the document the first time they are seen
+func (d *Document) Schema(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// Query returns the query parameters bound into v's type, a struct with form tags, with
+// their binding rules
+func (d *Document) Query(v any) []Parameter {
+	t := derefType(reflect.TypeOf(v))
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		binding := f.Tag.Get("binding")
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: hasRule(binding, "required"),
+			Schema:   d.applyBinding(copySchema(d.schemaOf(f.Type)), binding),
+		})
+	}
+	return params
+}
+
+// Envelope returns the schema of a version 2 success body whose data is v; nil for bodies
+// with only meta (e.g. a deletion's message)
+func (d *Document) Envelope(v any) *Schema {
+	props := map[string]*Schema{
+		"meta": {Type: "object", Description: "request_id, message, pagination and other metadata"},
+	}
+	if v != nil {
+		props["data"] = d.Schema(v)
+	}
+	return &Schema{Type: "object", Properties: props, Required: []string{"meta"}}
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Implements(reflect.TypeOf((*Typer)(nil)).Elem()) {
+		s := d.schemaOf(reflect.TypeOf(reflect.Zero(t).Interface().(Typer).SchemaType()))
+		return nullable(s)
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return &Schema{Description: "any JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(d.schemaOf(t.Elem()))
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		format := "int32"
+		if t.Kind() == reflect.Uint64 || t.Kind() == reflect.Uint {
+			format = "int64"
+		}
+		return &Schema{Type: "integer", Format: format, Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return &Schema{Description: "any JSON value"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			d.Components.Schemas[name] = &Schema{} // placeholder for recursive types
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// structSchema describes a struct's json fields, applying their binding rules
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := d.structSchema(derefType(f.Type))
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := d.schemaOf(f.Type)
+		// Rules after dive are for the elements of a slice, not the field
+		binding, _, _ := strings.Cut(f.Tag.Get("binding"), ",dive")
+		if binding != "" {
+			if prop.Ref != "" {
+				prop = &Schema{Ref: prop.Ref} // rules of a referenced type live on the component
+			} else {
+				prop = d.applyBinding(copySchema(prop), binding)
+			}
+			if hasRule(binding, "required") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = prop
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// applyBinding adds the validator rules of a binding tag to s
+func (d *Document) applyBinding(s *Schema, binding string) *Schema {
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "min", "gte":
+			setBound(s, param, true)
+		case "max", "lte":
+			setBound(s, param, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "startswith":
+			s.Pattern = "^" + param
+		default:
+			if pattern, ok := d.patterns[tag]; ok {
+				s.Pattern = pattern
+			}
+		}
+	}
+	return s
+}
+
+func setBound(s *Schema, param string, lower bool) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		if lower {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	case "array":
+		if lower {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+	case "integer", "number":
+		f := float64(n)
+		if lower {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	}
+}
+
+func enumValue(typ, v string) any {
+	if typ == "integer" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func hasParam(params []Parameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == "path" {
+			return true
+		}
+	}
+	return false
+}
+
+// pathParamSchema types IDs as integers and anything else as strings
+func pathParamSchema(name string) *Schema {
+	if name == "id" || strings.HasSuffix(name, "Id") {
+		zero := 1.0
+		return &Schema{Type: "integer", Format: "int64", Minimum: &zero}
+	}
+	return &Schema{Type: "string"}
+}
+
+// schemaName names a component after its type, e.g. "models.User" becomes "User"; generic
+// instantiations keep their argument, e.g. "Page_User"
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		arg := name[i+1 : len(name)-1]
+		if j := strings.LastIndexByte(arg, '.'); j >= 0 {
+			arg = arg[j+1:]
+		}
+		name = name[:i] + "_" + arg
+	}
+	return name
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		// A $ref cannot carry siblings in OpenAPI 3.0
+		return &Schema{Ref: s.Ref}
+	}
+	c := copySchema(s)
+	c.Nullable = true
+	return c
+}
+
+func copySchema(s *Schema) *Schema {
+	c := *s
+	return &c
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// JSON returns the document encoded, indented for people reading it
+func (d *Document) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode OpenAPI document: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,50 @@
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+//go:embed swagger_ui.html
+var swaggerUIPage string
+
+var swaggerUITemplate = template.Must(template.New("swagger_ui").Parse(swaggerUIPage))
+
+// SwaggerUI renders the Swagger UI page browsing the document at specURL. The page is
+// embedded in the binary; Swagger UI's script and stylesheet load from assets, the base URL
+// of a swagger-ui-dist release (a CDN, or a mirror where browsers cannot reach one).
+func SwaggerUI(specURL, assets string) []byte {
+	var b bytes.Buffer
+	data := struct{ SpecURL, Assets string }{specURL, strings.TrimSuffix(assets, "/")}
+	if err := swaggerUITemplate.Execute(&b, data); err != nil {
+		// The template and its data are fixed, so this cannot fail at run time
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+// SwaggerUIPolicy is the Content-Security-Policy page, as SwaggerUI rendered it, needs: its
+// inline script by hash, Swagger UI's files from the origin of assets, and calls to this API
+func SwaggerUIPolicy(page []byte, assets string) string {
+	source := "'self'"
+	if u, err := url.Parse(assets); err == nil && u.Scheme != "" && u.Host != "" {
+		source = u.Scheme + "://" + u.Host
+	}
+
+	scripts := source
+	if start := bytes.Index(page, []byte("<script>")); start >= 0 {
+		inline := page[start+len("<script>"):]
+		if end := bytes.Index(inline, []byte("</script>")); end >= 0 {
+			sum := sha256.Sum256(inline[:end])
+			scripts += " 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+		}
+	}
+	// Swagger UI styles its elements inline and draws icons from data: URLs
+	return "default-src 'none'; script-src " + scripts + "; style-src " + source + " 'unsafe-inline'; " +
+		"img-src " + source + " data:; connect-src 'self'; frame-ancestors 'none'"
+}
@@ -0,0 +1,225 @@
+// Package password hashes and verifies user passwords with bcrypt or argon2id.
+//
+// Hashing is deliberately slow. A Hasher can run it on a small fixed pool of goroutines so a
+// burst of logins or user creations cannot occupy every CPU and starve the rest of the API.
+package password
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported PASSWORD_ALGORITHM values
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrMismatch is returned by Verify when the password does not match the hash
+var ErrMismatch = errors.New("password does not match")
+
+// Argon2Params are the argon2id cost parameters. The defaults follow the RFC 9106 second
+// recommended option, with less memory.
+type Argon2Params struct {
+	Time    uint32 `yaml:"time" env:"ARGON2_TIME" default:"3"`               // iterations
+	Memory  uint32 `yaml:"memory_kb" env:"ARGON2_MEMORY_KB" default:"65536"` // KiB
+	Threads uint8  `yaml:"threads" env:"ARGON2_THREADS" default:"2"`
+	KeyLen  uint32 `yaml:"key_len" default:"32"`
+	SaltLen uint32 `yaml:"salt_len" default:"16"`
+}
+
+// Config selects the algorithm for new hashes and its cost. The defaults match the original
+// behaviour: bcrypt at bcrypt.DefaultCost, hashed inline.
+type Config struct {
+	Algorithm  string       `yaml:"algorithm" env:"PASSWORD_ALGORITHM" default:"bcrypt"`
+	BcryptCost int          `yaml:"bcrypt_cost" env:"BCRYPT_COST" default:"10"`
+	Argon2     Argon2Params `yaml:"argon2"`
+	// Workers are goroutines hashing off the request goroutine; 0 hashes inline
+	Workers int `yaml:"workers" env:"PASSWORD_HASH_WORKERS" default:"0" min:"0" max:"1024"`
+}
+
+// Validate accepts an Algorithm in any case and checks the costs
+func (c *Config) Validate() error {
+	var errs []error
+	c.Algorithm = strings.ToLower(c.Algorithm)
+	if c.Algorithm != AlgorithmBcrypt && c.Algorithm != AlgorithmArgon2id {
+		errs = append(errs, fmt.Errorf("unsupported PASSWORD_ALGORITHM %q (want %s or %s)", c.Algorithm, AlgorithmBcrypt, AlgorithmArgon2id))
+	}
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if c.Argon2.Time == 0 || c.Argon2.Threads == 0 {
+		errs = append(errs, errors.New("ARGON2_TIME and ARGON2_THREADS must be positive"))
+	}
+	if c.Argon2.Memory < 8 {
+		errs = append(errs, errors.New("ARGON2_MEMORY_KB must be at least 8"))
+	}
+	if c.Argon2.KeyLen == 0 || c.Argon2.SaltLen == 0 {
+		errs = append(errs, errors.New("argon2 key_len and salt_len must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Hasher creates and checks password hashes; safe for concurrent use
+type Hasher struct {
+	cfg  Config
+	jobs chan func() // nil when hashing inline
+}
+
+// NewHasher creates a hasher, starting cfg.Workers hashing goroutines when set
+func NewHasher(cfg Config) *Hasher {
+	h := &Hasher{cfg: cfg}
+	if cfg.Workers > 0 {
+		h.jobs = make(chan func())
+		for i := 0; i < cfg.Workers; i++ {
+			go func() {
+				for job := range h.jobs {
+					job()
+				}
+			}()
+		}
+	}
+	return h
+}
+
+// run executes fn on the worker pool, or inline without one. A caller whose ctx ends while
+// waiting gets ctx.Err(); a job already started finishes in the background.
+func (h *Hasher) run(ctx context.Context, fn func()) error {
+	if h.jobs == nil {
+		fn()
+		return nil
+	}
+
+	done := make(chan struct{})
+	job := func() {
+		defer close(done)
+		fn()
+	}
+	select {
+	case h.jobs <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Hash hashes plain with the configured algorithm
+func (h *Hasher) Hash(ctx context.Context, plain string) (string, error) {
+	var hash string
+	var err error
+	if runErr := h.run(ctx, func() { hash, err = h.hash(plain) }); runErr != nil {
+		return "", runErr
+	}
+	return hash, err
+}
+
+func (h *Hasher) hash(plain string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmArgon2id {
+		return hashArgon2id(plain, h.cfg.Argon2)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), h.cfg.BcryptCost)
+	return string(hashed), err
+}
+
+// Verify checks plain against hash, whichever supported algorithm produced it, so switching
+// PASSWORD_ALGORITHM never locks existing users out. It returns ErrMismatch on a wrong password.
+func (h *Hasher) Verify(ctx context.Context, hash, plain string) error {
+	var err error
+	if runErr := h.run(ctx, func() { err = verify(hash, plain) }); runErr != nil {
+		return runErr
+	}
+	return err
+}
+
+func verify(hash, plain string) error {
+	if strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$") {
+		return verifyArgon2id(hash, plain)
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return err
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or cost than configured,
+// so it can be replaced after the next successful login
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$") {
+		if h.cfg.Algorithm != AlgorithmArgon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2id(hash)
+		return err != nil || params.Time != h.cfg.Argon2.Time || params.Memory != h.cfg.Argon2.Memory ||
+			params.Threads != h.cfg.Argon2.Threads
+	}
+	if h.cfg.Algorithm != AlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cfg.BcryptCost
+}
+
+// hashArgon2id returns a PHC-format hash: $argon2id$v=19$m=<KiB>,t=<time>,p=<threads>$<salt>$<key>
+func hashArgon2id(plain string, p Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(plain), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyArgon2id(hash, plain string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(plain), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// decodeArgon2id parses a PHC-format argon2id hash
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return p, nil, nil, errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("malformed argon2id key: %w", err)
+	}
+	p.SaltLen, p.KeyLen = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
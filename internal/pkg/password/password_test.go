@@ -0,0 +1,35 @@
+package password_test
+
+import (
+	"context"
+	"testing"
+
+	"adminbe/internal/pkg/config"
+	"adminbe/internal/pkg/password"
+)
+
+// BenchmarkHash shows what each password setting costs per create-user or login request,
+// to pick BCRYPT_COST or the argon2id parameters for the deployment's hardware
+func BenchmarkHash(b *testing.B) {
+	for _, bm := range []struct {
+		name      string
+		configure func(cfg *password.Config)
+	}{
+		{"bcrypt/cost10", func(cfg *password.Config) { cfg.BcryptCost = 10 }},
+		{"bcrypt/cost12", func(cfg *password.Config) { cfg.BcryptCost = 12 }},
+		{"argon2id/default", func(cfg *password.Config) { cfg.Algorithm = password.AlgorithmArgon2id }},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			cfg := config.Default().Password
+			bm.configure(&cfg)
+			hasher := password.NewHasher(cfg)
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := hasher.Hash(ctx, "correct horse battery staple"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
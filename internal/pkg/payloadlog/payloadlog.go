@@ -0,0 +1,199 @@
+// Package payloadlog keeps recent request and response payloads in memory for incident
+// debugging. Entries are redacted before they are stored: values of password, token and
+// similar fields never reach the buffer, and bodies that cannot be parsed are replaced by a
+// size note rather than kept raw.
+package payloadlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces the value of every sensitive field
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched case-insensitively as substrings of field and query names, so
+// new_password and refresh_token are covered too
+var sensitiveKeys = []string{"password", "passwd", "token", "secret", "authorization", "api_key", "apikey", "cookie", "credential", "encryption_key"}
+
+// Sensitive reports whether a field or query parameter named name must be redacted
+func Sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Body is a captured, redacted payload
+type Body struct {
+	ContentType string          `json:"content_type,omitempty"`
+	Bytes       int             `json:"bytes"`
+	JSON        json.RawMessage `json:"json,omitempty"` // redacted JSON body
+	Form        url.Values      `json:"form,omitempty"` // redacted form body
+	Note        string          `json:"note,omitempty"` // why the body is not shown
+}
+
+// Entry is one captured request
+type Entry struct {
+	RequestID string        `json:"request_id"`
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Route     string        `json:"route"`
+	Path      string        `json:"path"`
+	Query     url.Values    `json:"query,omitempty"`
+	UserID    *uint64       `json:"user_id,omitempty"`
+	Status    int           `json:"status"`
+	Duration  time.Duration `json:"duration_ns"`
+	Request   Body          `json:"request"`
+	Response  Body          `json:"response"`
+}
+
+// RedactQuery returns q with sensitive parameters redacted
+func RedactQuery(q url.Values) url.Values {
+	if len(q) == 0 {
+		return nil
+	}
+	out := make(url.Values, len(q))
+	for k, v := range q {
+		if Sensitive(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// NewBody redacts a captured payload of size bytes. data holds the payload, or is nil when
+// it was larger than the capture limit; a partial body is never parsed, since the cut could
+// fall inside a field that should have been redacted.
+func NewBody(contentType string, size int, data []byte) Body {
+	b := Body{ContentType: contentType, Bytes: size}
+	if size == 0 {
+		return b
+	}
+	if data == nil {
+		b.Note = "body larger than the capture limit"
+		return b
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		redacted, err := RedactJSON(data)
+		if err != nil {
+			b.Note = "invalid JSON"
+			return b
+		}
+		b.JSON = redacted
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			b.Note = "invalid form"
+			return b
+		}
+		b.Form = RedactQuery(form)
+	default:
+		b.Note = fmt.Sprintf("%s body not captured", mediaTypeOrUnknown(mediaType))
+	}
+	return b
+}
+
+func mediaTypeOrUnknown(mediaType string) string {
+	if mediaType == "" {
+		return "untyped"
+	}
+	return mediaType
+}
+
+// RedactJSON re-encodes data with the values of sensitive fields replaced, at any depth
+func RedactJSON(data []byte) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(v))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if Sensitive(k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = redactValue(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = redactValue(val)
+		}
+	}
+	return v
+}
+
+// DefaultSize is how many entries the buffer keeps unless Resize is called
+const DefaultSize = 200
+
+var recent = &ring{size: DefaultSize}
+
+// ring is a fixed-size buffer of the most recent entries
+type ring struct {
+	mu    sync.Mutex
+	size  int
+	next  int
+	items []Entry
+}
+
+// Resize sets how many entries are kept, dropping the current ones
+func Resize(size int) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	recent.size = max(size, 1)
+	recent.next = 0
+	recent.items = nil
+}
+
+// Record keeps e among the recent entries
+func Record(e Entry) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	if len(recent.items) < recent.size {
+		recent.items = append(recent.items, e)
+		return
+	}
+	recent.items[recent.next] = e
+	recent.next = (recent.next + 1) % recent.size
+}
+
+// Recent returns the recorded entries, newest first
+func Recent() []Entry {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	out := make([]Entry, 0, len(recent.items))
+	for i := len(recent.items) - 1; i >= 0; i-- {
+		out = append(out, recent.items[(recent.next+i)%len(recent.items)])
+	}
+	return out
+}
+
+// Clear drops every recorded entry
+func Clear() {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	recent.next = 0
+	recent.items = nil
+}
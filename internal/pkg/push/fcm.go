@@ -0,0 +1,193 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// defaultTokenURI is Google's OAuth 2.0 token endpoint, for keys that do not name one
+const defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// fcmScope is the OAuth scope of the FCM send API
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// serviceAccount holds the members of a service account key that sending needs
+type serviceAccount struct {
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// fcmSender posts messages to the FCM HTTP v1 API with an OAuth access token it obtains by
+// signing a JWT with the service account's key, and renews shortly before it expires
+type fcmSender struct {
+	url    string
+	sa     *serviceAccount
+	key    *rsa.PrivateKey
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newFCMSender(cfg Config, sa *serviceAccount) (*fcmSender, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("FCM credentials private_key: %w", err)
+	}
+	return &fcmSender{
+		url:    strings.TrimSuffix(cfg.APIURL, "/") + "/v1/projects/" + url.PathEscape(sa.ProjectID) + "/messages:send",
+		sa:     sa,
+		key:    key,
+		client: &http.Client{},
+	}, nil
+}
+
+// accessToken returns the cached access token, fetching a new one when it is about to expire
+func (s *fcmSender) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expires) > time.Minute {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	assertion := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.sa.ClientEmail,
+		"scope": fcmScope,
+		"aud":   s.sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	assertion.Header["kid"] = s.sa.PrivateKeyID
+	signed, err := assertion.SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {signed}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("fcm token endpoint answered %d: %s %s", resp.StatusCode, result.Error, result.Description)
+	}
+	s.token, s.expires = result.AccessToken, now.Add(time.Duration(result.ExpiresIn)*time.Second)
+	return s.token, nil
+}
+
+// forget drops the access token after the API refused it
+func (s *fcmSender) forget(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	Priority string `json:"priority"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      fcmAndroid        `json:"android"`
+}
+
+type fcmError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (s *fcmSender) send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]fcmMessage{"message": {
+		Token:        msg.Token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+		// Reminders are time-critical, so they may wake the device
+		Android: fcmAndroid{Priority: "high"},
+	}})
+	if err != nil {
+		return err
+	}
+
+	// One retry with a fresh access token if the cached one was revoked
+	for attempt := 0; ; attempt++ {
+		token, err := s.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("fcm: %w", err)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			return nil
+		}
+
+		var result fcmError
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			s.forget(token)
+			continue
+		}
+		err = fmt.Errorf("fcm answered %d: %s %s", resp.StatusCode, result.Error.Status, result.Error.Message)
+		for _, d := range result.Error.Details {
+			if d.ErrorCode == "UNREGISTERED" || d.ErrorCode == "SENDER_ID_MISMATCH" {
+				return fmt.Errorf("%w: %w", ErrUnregistered, err)
+			}
+		}
+		return err
+	}
+}
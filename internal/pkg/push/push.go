@@ -0,0 +1,183 @@
+// Package push sends notifications to mobile devices through Firebase Cloud Messaging, using
+// the HTTP v1 API with a service account. A message goes to one registration token; SendAll
+// sends many at once on a bounded number of goroutines. Which devices are sent what is left
+// to the caller, which should forget tokens Send reports as ErrUnregistered.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisabled is returned by Send while no service account is configured
+var ErrDisabled = errors.New("push is disabled")
+
+// ErrUnregistered means the token no longer reaches an app installation: the app was
+// uninstalled, or the token expired or belongs to another project
+var ErrUnregistered = errors.New("device token is unregistered")
+
+// Config is the push section of the configuration
+type Config struct {
+	// CredentialsFile is the service account key JSON downloaded from the Firebase console;
+	// Credentials holds the same JSON inline, e.g. from a secrets manager. Neither disables push.
+	CredentialsFile string `yaml:"credentials_file" env:"FCM_CREDENTIALS_FILE"`
+	Credentials     string `yaml:"credentials" env:"FCM_CREDENTIALS"`
+	// ProjectID overrides the project of the service account
+	ProjectID string `yaml:"project_id" env:"FCM_PROJECT_ID"`
+	APIURL    string `yaml:"api_url" env:"FCM_API_URL" default:"https://fcm.googleapis.com"`
+	// Workers is the number of messages sent at once
+	Workers int           `yaml:"workers" env:"FCM_WORKERS" default:"8" min:"1" max:"64"`
+	Timeout time.Duration `yaml:"timeout" env:"FCM_TIMEOUT" default:"10s"`
+	// ReminderLead sends prayer reminders this long before the prayer time; 0 sends them at it
+	ReminderLead time.Duration `yaml:"reminder_lead" env:"PUSH_REMINDER_LEAD" default:"0s"`
+	// ReminderWindow is how late a reminder may still go out, e.g. after downtime
+	ReminderWindow time.Duration `yaml:"reminder_window" env:"PUSH_REMINDER_WINDOW" default:"10m"`
+}
+
+// Validate checks the service account can be read and the settings are usable
+func (c *Config) Validate() error {
+	var errs []error
+	if c.CredentialsFile != "" || c.Credentials != "" {
+		if _, err := c.serviceAccount(); err != nil {
+			errs = append(errs, err)
+		}
+		if !strings.HasPrefix(c.APIURL, "https://") && !strings.HasPrefix(c.APIURL, "http://") {
+			errs = append(errs, errors.New("FCM_API_URL must be an http or https URL"))
+		}
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("FCM_TIMEOUT must be positive"))
+	}
+	if c.ReminderLead < 0 || c.ReminderLead > 2*time.Hour {
+		errs = append(errs, errors.New("PUSH_REMINDER_LEAD must be between 0 and 2h"))
+	}
+	if c.ReminderWindow < time.Minute {
+		errs = append(errs, errors.New("PUSH_REMINDER_WINDOW must be at least 1m"))
+	}
+	return errors.Join(errs...)
+}
+
+// serviceAccount reads the configured service account key
+func (c *Config) serviceAccount() (*serviceAccount, error) {
+	data := []byte(c.Credentials)
+	if c.Credentials == "" {
+		var err error
+		if data, err = os.ReadFile(c.CredentialsFile); err != nil {
+			return nil, fmt.Errorf("FCM_CREDENTIALS_FILE: %w", err)
+		}
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("FCM credentials are not a service account key: %w", err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, errors.New("FCM credentials lack client_email or private_key")
+	}
+	if c.ProjectID != "" {
+		sa.ProjectID = c.ProjectID
+	}
+	if sa.ProjectID == "" {
+		return nil, errors.New("FCM credentials name no project; set FCM_PROJECT_ID")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = defaultTokenURI
+	}
+	return &sa, nil
+}
+
+// Message is a notification for one device. Data is delivered to the app with it, e.g. what
+// screen to open.
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Client sends messages with the configured service account
+type Client struct {
+	mu     sync.RWMutex
+	cfg    Config
+	sender *fcmSender
+}
+
+// Default is the process-wide client, sending nothing until Configure
+var Default = &Client{}
+
+// Configure replaces the service account, e.g. on startup or a configuration reload
+func (c *Client) Configure(cfg Config) error {
+	var sender *fcmSender
+	if cfg.CredentialsFile != "" || cfg.Credentials != "" {
+		sa, err := cfg.serviceAccount()
+		if err != nil {
+			return err
+		}
+		if sender, err = newFCMSender(cfg, sa); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg, c.sender = cfg, sender
+	return nil
+}
+
+// Enabled reports whether a service account is configured
+func (c *Client) Enabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sender != nil
+}
+
+// Config returns the configuration in effect
+func (c *Client) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg
+}
+
+// Send delivers msg once, bounded by FCM_TIMEOUT
+func (c *Client) Send(ctx context.Context, msg Message) error {
+	c.mu.RLock()
+	sender, timeout := c.sender, c.cfg.Timeout
+	c.mu.RUnlock()
+	if sender == nil {
+		return ErrDisabled
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return sender.send(ctx, msg)
+}
+
+// SendAll sends every message, FCM_WORKERS at a time, and returns the error of each by index
+func (c *Client) SendAll(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	workers := max(c.Config().Workers, 1)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(msgs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = c.Send(ctx, msgs[i])
+			}
+		}()
+	}
+	for i := range msgs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
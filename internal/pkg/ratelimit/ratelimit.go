@@ -0,0 +1,235 @@
+// Package ratelimit budgets requests per client with token buckets: a bucket holds up to
+// Limit tokens, refilled at Limit per Period, and every request takes one. With Redis
+// attached the buckets are shared by every instance, updated by one script so concurrent
+// requests cannot both take the last token; without Redis, or while it cannot be reached,
+// each process keeps its own.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultPrefix is prepended to bucket keys to form their Redis keys
+const DefaultPrefix = "cms:ratelimit:"
+
+// Rate is a budget of Limit requests per Period, which may all come at once. The zero Rate
+// allows everything.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled reports whether the rate limits anything
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// String formats the rate as UnmarshalText reads it
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return "0"
+	}
+	// 1m rather than 1m0s
+	period := r.Period.String()
+	if strings.HasSuffix(period, "m0s") {
+		period = strings.TrimSuffix(period, "0s")
+	}
+	if strings.HasSuffix(period, "h0m") {
+		period = strings.TrimSuffix(period, "0m")
+	}
+	return strconv.Itoa(r.Limit) + "/" + period
+}
+
+// UnmarshalText reads "N/period", e.g. "10/1m", "5/30s" or "100/h" (a bare unit is one of
+// it); "0" and "" turn the rate off
+func (r *Rate) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "" || s == "0" {
+		*r = Rate{}
+		return nil
+	}
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return errors.New(`want "N/period", e.g. "10/1m"`)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit < 0 {
+		return fmt.Errorf("invalid request count %q", count)
+	}
+	period = strings.TrimSpace(period)
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		d, err = time.ParseDuration("1" + period)
+	}
+	if err != nil || d < time.Millisecond {
+		return fmt.Errorf("invalid period %q", period)
+	}
+	*r = Rate{Limit: limit, Period: d}
+	return nil
+}
+
+// MarshalText writes the rate as UnmarshalText reads it
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Result is the state of a bucket after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token, when the request was refused
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// takeScript refills the bucket for the time since it was last used, by the Redis clock so
+// the instances' clocks need not agree, and takes a token if there is one.
+// KEYS[1] bucket; ARGV limit, period in ms. Returns {allowed, tokens left as a string}.
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = redis.call("TIME")
+now = now[1] * 1000 + math.floor(now[2] / 1000)
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * limit / period)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// sweepInterval is how often idle local buckets are dropped
+const sweepInterval = time.Minute
+
+// Limiter keeps the buckets, across instances once Redis is attached
+type Limiter struct {
+	mu     sync.RWMutex
+	redis  redis.UniversalClient
+	prefix string
+
+	localMu   sync.Mutex
+	local     map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// NewLimiter creates a limiter whose buckets are per process until AttachRedis
+func NewLimiter() *Limiter {
+	return &Limiter{local: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Default is the process-wide limiter
+var Default = NewLimiter()
+
+// AttachRedis shares the buckets with every instance using client, under keys starting with prefix
+func (l *Limiter) AttachRedis(client redis.UniversalClient, prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redis, l.prefix = client, prefix
+}
+
+// Distributed reports whether buckets are shared with other instances
+func (l *Limiter) Distributed() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.redis != nil
+}
+
+// Allow takes a token from the bucket key, sized by rate. When Redis fails the local bucket
+// decides and the error is returned along with its result, so callers can count the failure
+// without refusing traffic because of it.
+func (l *Limiter) Allow(ctx context.Context, key string, rate Rate) (Result, error) {
+	if !rate.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	l.mu.RLock()
+	client, prefix := l.redis, l.prefix
+	l.mu.RUnlock()
+	if client == nil {
+		return l.allowLocal(key, rate), nil
+	}
+
+	reply, err := takeScript.Run(ctx, client, []string{prefix + key}, rate.Limit, rate.Period.Milliseconds()).Slice()
+	if err == nil && len(reply) != 2 {
+		err = fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	if err != nil {
+		return l.allowLocal(key, rate), err
+	}
+	allowed, _ := reply[0].(int64)
+	left, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return l.allowLocal(key, rate), fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	return result(allowed == 1, tokens, rate), nil
+}
+
+func (l *Limiter) allowLocal(key string, rate Rate) Result {
+	now := time.Now()
+	l.localMu.Lock()
+	defer l.localMu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		// A bucket unused for its period is full again, the same as no bucket
+		for k, b := range l.local {
+			if now.Sub(b.updated) >= b.period {
+				delete(l.local, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	limit := float64(rate.Limit)
+	b, ok := l.local[key]
+	if !ok {
+		b = &bucket{tokens: limit, updated: now}
+		l.local[key] = b
+	}
+	b.period = rate.Period
+	b.tokens = math.Min(limit, b.tokens+float64(now.Sub(b.updated))*limit/float64(rate.Period))
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(allowed, b.tokens, rate)
+}
+
+// result describes a bucket left with tokens
+func result(allowed bool, tokens float64, rate Rate) Result {
+	perToken := float64(rate.Period) / float64(rate.Limit)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(rate.Limit) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return res
+}
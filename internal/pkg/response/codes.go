@@ -0,0 +1,65 @@
+package response
+
+import "net/http"
+
+// Machine-readable error codes. Clients branch on these rather than on messages, which
+// may be reworded.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeValidation       = "VALIDATION_ERROR"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeCSRFInvalid      = "CSRF_INVALID"
+	CodePasswordChange   = "PASSWORD_CHANGE_REQUIRED"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMedia = "UNSUPPORTED_MEDIA_TYPE"
+	CodeFailedDependency = "FAILED_DEPENDENCY"
+	CodeOverloaded       = "OVERLOADED"
+	CodeRateLimited      = "RATE_LIMITED"
+	CodeChallenge        = "CHALLENGE_REQUIRED"
+	CodeInternal         = "INTERNAL_ERROR"
+	CodeExternal         = "EXTERNAL_SERVICE_ERROR"
+	CodeTransient        = "TRANSIENT_ERROR"
+	CodeUnavailable      = "SERVICE_UNAVAILABLE"
+	CodeMaintenance      = "MAINTENANCE"
+	CodeTimeout          = "TIMEOUT"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+)
+
+// CodeForStatus returns the code for an error answered with status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusUnprocessableEntity:
+		return CodeValidation
+	case http.StatusFailedDependency:
+		return CodeFailedDependency
+	case http.StatusTooManyRequests:
+		return CodeOverloaded
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
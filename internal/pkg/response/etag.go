@@ -0,0 +1,57 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag returns a strong ETag for body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header matches etag
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// OKConditional writes data with 200 and an ETag, or 304 with no body when If-None-Match
+// already holds that ETag, so clients polling a resource only download it after it changes.
+// The ETag hashes the rendered body rather than using updated_at: the timestamp columns have
+// second precision, and the body also differs between response versions.
+func OKConditional(c *gin.Context, data any) {
+	rendered := Render(c, Body{Data: data})
+	body, err := json.Marshal(rendered)
+	if err != nil {
+		c.JSON(http.StatusOK, rendered) // let gin report the encoding error as for any other body
+		return
+	}
+
+	etag := ETag(body)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
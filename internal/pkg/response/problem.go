@@ -0,0 +1,93 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentTypeProblem is the media type of RFC 7807 problem details. Clients that list it in
+// Accept get errors as a Problem instead of the envelope or the version 1 body.
+const ContentTypeProblem = "application/problem+json"
+
+var problemTypeBase atomic.Value
+
+func init() {
+	problemTypeBase.Store("")
+}
+
+// SetProblemTypeBase makes a problem's type base followed by its code, e.g.
+// "https://docs.example.com/errors/" gives "https://docs.example.com/errors/not-found".
+// With an empty base every problem is "about:blank" and clients branch on code instead.
+func SetProblemTypeBase(base string) {
+	problemTypeBase.Store(base)
+}
+
+// Problem is an RFC 7807 problem details body. code and trace_id are extension members:
+// the machine-readable code of the envelope, and the request ID (X-Request-ID) to quote
+// when reporting the problem.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	Code     string
+	TraceID  string
+	// Extensions are further members, e.g. fields of a VALIDATION_ERROR; they cannot
+	// replace the members above
+	Extensions Meta
+}
+
+// MarshalJSON writes the extensions as top-level members, as RFC 7807 has them
+func (p Problem) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		body[k] = v
+	}
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	if p.TraceID != "" {
+		body["trace_id"] = p.TraceID
+	}
+	return json.Marshal(body)
+}
+
+// ProblemFor returns f answered with status as problem details. instance is the path of
+// the request that failed.
+func ProblemFor(status int, instance, requestID string, f Failure) Problem {
+	code := f.Code
+	if code == "" {
+		code = CodeForStatus(status)
+	}
+	typ := "about:blank"
+	if base := problemTypeBase.Load().(string); base != "" {
+		typ = base + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+	}
+	return Problem{
+		Type:       typ,
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     f.Message,
+		Instance:   instance,
+		Code:       code,
+		TraceID:    requestID,
+		Extensions: f.Details,
+	}
+}
+
+// WantsProblem reports whether c's client asked for problem details
+func WantsProblem(c *gin.Context) bool {
+	return Accepts(c.GetHeader("Accept"), ContentTypeProblem)
+}
@@ -0,0 +1,219 @@
+// Package response writes API responses in one envelope:
+//
+//	{"data": {...}, "meta": {"message": "User created"}}
+//	{"error": {"code": "NOT_FOUND", "message": "User not found"}, "meta": {"request_id": "..."}}
+//
+// data holds the payload, meta describes it (message, pagination, counts) and error carries
+// a machine-readable code. Clients written against the shapes from before the envelope send
+// "Accept-Version: 1" and get those bodies unchanged, and clients that accept
+// application/problem+json get errors as RFC 7807 problem details (see Problem).
+package response
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"adminbe/internal/pkg/i18n"
+	"adminbe/internal/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderAcceptVersion selects the response format
+const HeaderAcceptVersion = "Accept-Version"
+
+// Response format versions
+const (
+	// Version1 is the legacy format: the bare gin.H shapes each handler used to write
+	Version1 = "1"
+	// Version2 is the envelope
+	Version2 = "2"
+)
+
+var defaultVersion atomic.Value
+
+func init() {
+	defaultVersion.Store(Version2)
+}
+
+// SetDefaultVersion selects the format for requests without Accept-Version
+func SetDefaultVersion(v string) error {
+	if v != Version1 && v != Version2 {
+		return fmt.Errorf("unsupported response version %q (want %s or %s)", v, Version1, Version2)
+	}
+	defaultVersion.Store(v)
+	return nil
+}
+
+// versionKey is the gin context key of a format pinned by Pin
+const versionKey = "response_version"
+
+// Pin fixes the format of c's responses whatever Accept-Version says, for routes such as
+// /api/v2 whose format is part of their version
+func Pin(c *gin.Context, version string) {
+	c.Set(versionKey, version)
+}
+
+// Negotiate returns the format pinned for the route, else the one the client asked for with
+// Accept-Version ("1", "v1", "2" or "v2"), else the default
+func Negotiate(c *gin.Context) string {
+	if v := c.GetString(versionKey); v != "" {
+		return v
+	}
+	switch c.GetHeader(HeaderAcceptVersion) {
+	case "1", "v1":
+		return Version1
+	case "2", "v2":
+		return Version2
+	}
+	return defaultVersion.Load().(string)
+}
+
+// Accepts reports whether an Accept header lists mediaType with a non-zero quality.
+// Wildcards do not count: the formats offered this way are opt-in.
+func Accepts(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mt != mediaType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err != nil || v <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Legacy reports whether the client gets the version 1 format
+func Legacy(c *gin.Context) bool {
+	return Negotiate(c) == Version1
+}
+
+// Meta describes the payload, e.g. message, pagination or count
+type Meta map[string]any
+
+// Envelope is the version 2 body
+type Envelope struct {
+	Data  any    `json:"data,omitempty"`
+	Error *Error `json:"error,omitempty"`
+	Meta  Meta   `json:"meta"`
+}
+
+// Error is the error member of an envelope
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details Meta   `json:"details,omitempty"`
+}
+
+// Body is a successful response
+type Body struct {
+	// Data is the payload
+	Data any
+	// Message describes the outcome, e.g. "User created"
+	Message string
+	// Meta holds other metadata; in version 1 these were top-level fields next to data
+	Meta Meta
+	// Legacy is the exact version 1 body, for the few handlers whose old shape cannot be
+	// derived from the fields above (e.g. login, which had no data member)
+	Legacy any
+}
+
+// Render returns the body to write for b in the negotiated format, its message in the
+// request's language. Legacy bodies are written as they are.
+func Render(c *gin.Context, b Body) any {
+	c.Writer.Header().Add("Vary", HeaderAcceptVersion)
+	b.Message = i18n.T(c.Request.Context(), b.Message)
+	if !Legacy(c) {
+		meta := Meta{}
+		for k, v := range b.Meta {
+			meta[k] = v
+		}
+		if b.Message != "" {
+			meta["message"] = b.Message
+		}
+		return Envelope{Data: b.Data, Meta: meta}
+	}
+
+	if b.Legacy != nil {
+		return b.Legacy
+	}
+	body := gin.H{}
+	for k, v := range b.Meta {
+		body[k] = v
+	}
+	if b.Message != "" {
+		body["message"] = b.Message
+	}
+	if b.Data != nil {
+		body["data"] = b.Data
+	}
+	return body
+}
+
+// Write writes b with status
+func Write(c *gin.Context, status int, b Body) {
+	c.JSON(status, Render(c, b))
+}
+
+// OK writes data with 200
+func OK(c *gin.Context, data any) {
+	Write(c, 200, Body{Data: data})
+}
+
+// Failure is an error response
+type Failure struct {
+	// Code is machine-readable, e.g. NOT_FOUND (see CodeForStatus)
+	Code    string
+	Message string
+	// Details add context such as a timeout's budget; in version 1 they were top-level fields
+	Details Meta
+	// Legacy holds fields only the version 1 body had, e.g. "type"
+	Legacy gin.H
+}
+
+// ErrorFor returns the error body in version, for code without a gin context. The request
+// ID is always included, so a client report can be matched to the server's log lines.
+func ErrorFor(version, requestID string, f Failure) any {
+	if version != Version1 {
+		return Envelope{
+			Error: &Error{Code: f.Code, Message: f.Message, Details: f.Details},
+			Meta:  Meta{"request_id": requestID},
+		}
+	}
+	body := gin.H{"error": f.Message, "request_id": requestID}
+	for k, v := range f.Details {
+		body[k] = v
+	}
+	for k, v := range f.Legacy {
+		body[k] = v
+	}
+	return body
+}
+
+// RenderError returns the error body for f answered with status: problem details when the
+// client accepts application/problem+json (setting that Content-Type), else the negotiated
+// format. The message is in the request's language.
+func RenderError(c *gin.Context, status int, f Failure) any {
+	f.Message = i18n.T(c.Request.Context(), f.Message)
+	h := c.Writer.Header()
+	h.Add("Vary", HeaderAcceptVersion)
+	h.Add("Vary", "Accept")
+	requestID := tracing.RequestID(c.Request.Context())
+	if WantsProblem(c) {
+		h.Set("Content-Type", ContentTypeProblem)
+		return ProblemFor(status, c.Request.URL.Path, requestID, f)
+	}
+	return ErrorFor(Negotiate(c), requestID, f)
+}
+
+// WriteError writes f with status
+func WriteError(c *gin.Context, status int, f Failure) {
+	c.JSON(status, RenderError(c, status, f))
+}
@@ -0,0 +1,413 @@
+// Package scheduler runs recurring background jobs on cron-style schedules (see Parse) for
+// as long as the process serves. Every instance keeps the schedules, and distributed locks
+// make exactly one of them do each run: the first to claim a run's time slot does it. A job
+// also runs at most once at a time across instances: a run still going when the next is due
+// makes that one skipped. The last runs of every job are kept for /api/admin/jobs.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/lock"
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Run outcomes
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped" // the previous run had not finished
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "jobs",
+		Name:      "runs_total",
+		Help:      "Background job runs by job and outcome.",
+	}, []string{"job", "status"})
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "jobs",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of background job runs.",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900},
+	}, []string{"job"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(jobRuns, jobDuration)
+}
+
+// ErrUnknownJob is returned for a job name that was never registered
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobRunning is returned by RunNow while the job is already running, here or on
+// another instance
+var ErrJobRunning = errors.New("job is already running")
+
+// errClaimed means another instance took the scheduled run
+var errClaimed = errors.New("run claimed by another instance")
+
+// runLockTTL is how long the lock of a running job outlives its holder; it is refreshed
+// while the job runs
+const runLockTTL = 30 * time.Second
+
+// Job is a recurring task
+type Job struct {
+	Name        string
+	Description string
+	// Schedule is in Parse's syntax
+	Schedule string
+	// Enabled jobs run on schedule; disabled ones can still be run by hand
+	Enabled bool
+	// Timeout bounds one run through its context; 0 leaves it unbounded
+	Timeout time.Duration
+	// Run does the work. A non-empty result is kept with the run, e.g. "deleted 120 rows".
+	Run func(ctx context.Context) (string, error)
+}
+
+// RunRecord is one run of a job
+type RunRecord struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Status     string    `json:"status"`
+	Trigger    string    `json:"trigger"` // schedule or manual
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// JobStatus describes a job for the admin API
+type JobStatus struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Schedule    string      `json:"schedule"`
+	Enabled     bool        `json:"enabled"`
+	Running     bool        `json:"running"`
+	NextRun     *time.Time  `json:"next_run,omitempty"`
+	LastRun     *RunRecord  `json:"last_run,omitempty"`
+	History     []RunRecord `json:"history,omitempty"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+
+	mu      sync.Mutex
+	running bool
+	next    time.Time
+	history []RunRecord // newest last
+}
+
+// Scheduler runs the registered jobs once started
+type Scheduler struct {
+	historySize int
+	locker      *lock.Locker
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+	// onFailure is told of failed runs; see OnFailure
+	onFailure func(Job, RunRecord)
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// New creates a scheduler keeping the last historySize runs of each job, coordinating with
+// other instances through locker
+func New(historySize int, locker *lock.Locker) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		historySize: max(historySize, 1),
+		locker:      locker,
+		entries:     make(map[string]*entry),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Register adds a job. Register every job before Start.
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := Parse(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job, schedule: schedule}
+	return nil
+}
+
+// OnFailure has fn called with every failed run, e.g. to alert on it; runs stopped by Stop
+// are left out. Set it before Start.
+func (s *Scheduler) OnFailure(fn func(job Job, record RunRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onFailure = fn
+}
+
+// Start runs every enabled job on its schedule until Stop
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, e := range s.entries {
+		if !e.job.Enabled {
+			continue
+		}
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+	slog.Info("Job scheduler started", "jobs", len(s.entries))
+}
+
+// loop waits for each scheduled time of e and runs it in the background
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+	for {
+		next := e.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("Job schedule has no next run, stopping it", "job", e.job.Name, "schedule", e.job.Schedule)
+			return
+		}
+		e.mu.Lock()
+		e.next = next
+		e.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		switch err := s.start(e, "schedule", next); {
+		case err == nil, errors.Is(err, errClaimed), s.ctx.Err() != nil:
+		case errors.Is(err, ErrJobRunning):
+			s.record(e, RunRecord{StartedAt: time.Now(), Status: StatusSkipped, Trigger: "schedule"})
+		default:
+			s.record(e, RunRecord{StartedAt: time.Now(), Status: StatusFailed, Trigger: "schedule", Error: err.Error()})
+		}
+	}
+}
+
+// RunNow starts a run of the named job in the background, whether or not it is enabled
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.RLock()
+	e := s.entries[name]
+	s.mu.RUnlock()
+	if e == nil {
+		return ErrUnknownJob
+	}
+	return s.start(e, "manual", time.Time{})
+}
+
+// start runs e in a new goroutine unless it is already running here or elsewhere. A
+// scheduled run first claims its slot, the time it was due at, for every instance; the
+// claim is left to expire before the following slot, so instances whose clocks or timers
+// lag see it taken.
+func (s *Scheduler) start(e *entry, trigger string, slot time.Time) error {
+	e.mu.Lock()
+	if e.running || s.ctx.Err() != nil {
+		e.mu.Unlock()
+		return ErrJobRunning
+	}
+	e.running = true
+	e.mu.Unlock()
+	started := false
+	defer func() {
+		if !started {
+			e.mu.Lock()
+			e.running = false
+			e.mu.Unlock()
+		}
+	}()
+
+	if !slot.IsZero() {
+		claim := fmt.Sprintf("job:%s:%d", e.job.Name, slot.Unix())
+		if _, err := s.locker.Acquire(s.ctx, claim, slotTTL(e.schedule, slot)); err != nil {
+			if errors.Is(err, lock.ErrNotAcquired) {
+				return errClaimed
+			}
+			return fmt.Errorf("claiming the run: %w", err)
+		}
+	}
+	held, err := s.locker.Acquire(s.ctx, "job:"+e.job.Name, runLockTTL)
+	if err != nil {
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return ErrJobRunning
+		}
+		return fmt.Errorf("locking the job: %w", err)
+	}
+
+	started = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ctx, stop := held.Keep(s.ctx)
+		s.run(ctx, e, trigger)
+		stop()
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := held.Release(releaseCtx); err != nil {
+			slog.Warn("Failed to release job lock", "job", e.job.Name, "error", err)
+		}
+	}()
+	return nil
+}
+
+// slotTTL keeps the claim of slot until the run after it is due, within [1s, 24h]
+func slotTTL(schedule Schedule, slot time.Time) time.Duration {
+	following := schedule.Next(slot)
+	if following.IsZero() {
+		return 24 * time.Hour
+	}
+	return min(max(following.Sub(slot), time.Second), 24*time.Hour)
+}
+
+// run executes one run of e, recovering panics so one broken job cannot stop the process.
+// ctx ends when the scheduler stops or the job's lock is lost.
+func (s *Scheduler) run(ctx context.Context, e *entry, trigger string) {
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+
+	record := RunRecord{StartedAt: time.Now(), Trigger: trigger}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				record.Error = fmt.Sprintf("panic: %v", p)
+			}
+		}()
+		result, err := e.job.Run(ctx)
+		record.Result = result
+		if err != nil {
+			record.Error = err.Error()
+		}
+	}()
+	elapsed := time.Since(record.StartedAt)
+	record.DurationMs = float64(elapsed.Microseconds()) / 1000
+	record.Status = StatusSucceeded
+	if record.Error != "" {
+		record.Status = StatusFailed
+	}
+	jobDuration.WithLabelValues(e.job.Name).Observe(elapsed.Seconds())
+
+	if record.Status == StatusFailed {
+		slog.Error("Job failed", "job", e.job.Name, "trigger", trigger, "duration_ms", record.DurationMs, "error", record.Error)
+	} else {
+		slog.Info("Job finished", "job", e.job.Name, "trigger", trigger, "duration_ms", record.DurationMs, "result", record.Result)
+	}
+
+	e.mu.Lock()
+	e.running = false
+	e.mu.Unlock()
+	s.record(e, record)
+}
+
+// record keeps a run in e's history, counts it and reports it if it failed
+func (s *Scheduler) record(e *entry, record RunRecord) {
+	jobRuns.WithLabelValues(e.job.Name, record.Status).Inc()
+	e.mu.Lock()
+	e.history = append(e.history, record)
+	if over := len(e.history) - s.historySize; over > 0 {
+		e.history = append(e.history[:0], e.history[over:]...)
+	}
+	e.mu.Unlock()
+
+	s.mu.RLock()
+	onFailure := s.onFailure
+	s.mu.RUnlock()
+	if record.Status == StatusFailed && onFailure != nil && s.ctx.Err() == nil {
+		onFailure(e.job, record)
+	}
+}
+
+// Distributed reports whether runs are coordinated with other instances, i.e. the locker
+// has Redis attached
+func (s *Scheduler) Distributed() bool {
+	return s.locker.Distributed()
+}
+
+// Jobs describes every job sorted by name, without their history
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e.status(false))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Job describes the named job with its history, newest first
+func (s *Scheduler) Job(name string) (JobStatus, error) {
+	s.mu.RLock()
+	e := s.entries[name]
+	s.mu.RUnlock()
+	if e == nil {
+		return JobStatus{}, ErrUnknownJob
+	}
+	return e.status(true), nil
+}
+
+func (e *entry) status(withHistory bool) JobStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := JobStatus{
+		Name:        e.job.Name,
+		Description: e.job.Description,
+		Schedule:    e.job.Schedule,
+		Enabled:     e.job.Enabled,
+		Running:     e.running,
+	}
+	if !e.next.IsZero() {
+		next := e.next
+		st.NextRun = &next
+	}
+	if n := len(e.history); n > 0 {
+		last := e.history[n-1]
+		st.LastRun = &last
+	}
+	if withHistory {
+		st.History = make([]RunRecord, len(e.history))
+		for i, r := range e.history {
+			st.History[len(e.history)-1-i] = r
+		}
+	}
+	return st
+}
+
+// Stop cancels running jobs through their context and waits for them, or until ctx ends
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Jobs still running at shutdown were abandoned")
+	}
+}
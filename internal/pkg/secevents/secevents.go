@@ -0,0 +1,230 @@
+// Package secevents detects suspicious activity and raises security events for it: bursts of
+// failed authentication from one address, grants of privileged roles and bulk reads of the
+// audit trail. The detectors live here and at the routes concerned; what becomes of a raised
+// event (storing it, alerting on it) is up to the handler given to SetHandler. With Redis
+// attached, failures are counted across every instance.
+package secevents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event types
+const (
+	TypeAuthFailures        = "auth_failures"        // many 401s from one address
+	TypePrivilegeEscalation = "privilege_escalation" // a user was granted a privileged role
+	TypeAuditExport         = "audit_log_export"     // the audit trail was exported in bulk
+	TypeBackupDownload      = "backup_download"      // a database backup was downloaded
+)
+
+// Severities, from least to most urgent
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists them in order
+var Severities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// DefaultPrefix is prepended to the Redis keys failures are counted under
+const DefaultPrefix = "cms:secevents:"
+
+// maxTracked bounds the addresses counted locally; expired windows are dropped past it
+const maxTracked = 10000
+
+var raised = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "security",
+	Name:      "events_total",
+	Help:      "Security events raised, by type and severity.",
+}, []string{"type", "severity"})
+
+func init() {
+	metrics.Registry.MustRegister(raised)
+}
+
+// Config is the security_events section of the configuration
+type Config struct {
+	// AuthFailureThreshold 401s from one address within AuthFailureWindow raise an event,
+	// once per window; 0 turns the detector off
+	AuthFailureThreshold int           `yaml:"auth_failure_threshold" env:"SECURITY_AUTH_FAILURE_THRESHOLD" default:"20" min:"0" max:"100000"`
+	AuthFailureWindow    time.Duration `yaml:"auth_failure_window" env:"SECURITY_AUTH_FAILURE_WINDOW" default:"5m"`
+	// PrivilegedRoles are the roles whose grant raises an event
+	PrivilegedRoles []string `yaml:"privileged_roles" env:"SECURITY_PRIVILEGED_ROLES" default:"admin,runtime_admin,backup_admin"`
+}
+
+// Validate checks the window
+func (c *Config) Validate() error {
+	if c.AuthFailureThreshold > 0 && c.AuthFailureWindow < time.Second {
+		return errors.New("SECURITY_AUTH_FAILURE_WINDOW must be at least 1s")
+	}
+	return nil
+}
+
+// Event is one suspicious occurrence
+type Event struct {
+	Type     string
+	Severity string
+	Message  string
+	IP       string  // client address, when raised for a request
+	UserID   *uint64 // the user who acted, when known
+	Details  map[string]any
+	Time     time.Time
+}
+
+// Handler receives raised events. It is called synchronously, with a context that outlives
+// the request, so it should not take long.
+type Handler func(ctx context.Context, e Event)
+
+// countScript counts a failure, starting the window with the first one.
+// KEYS[1] counter; ARGV window in ms. Returns the count.
+var countScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Monitor counts failures and hands raised events to its handler
+type Monitor struct {
+	mu      sync.RWMutex
+	cfg     Config
+	handler Handler
+	redis   redis.UniversalClient
+	prefix  string
+
+	localMu sync.Mutex
+	local   map[string]*failureWindow
+}
+
+// failureWindow counts the failures of one address since start
+type failureWindow struct {
+	count int
+	start time.Time
+}
+
+// NewMonitor creates a monitor with the detectors off and no handler
+func NewMonitor() *Monitor {
+	return &Monitor{local: make(map[string]*failureWindow)}
+}
+
+// Default is the process-wide monitor
+var Default = NewMonitor()
+
+// Configure sets the thresholds, e.g. on startup or a configuration reload
+func (m *Monitor) Configure(cfg Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+}
+
+// SetHandler sets what is done with raised events; without one they are only logged
+func (m *Monitor) SetHandler(h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = h
+}
+
+// AttachRedis counts failures with every instance using client, under keys starting with prefix
+func (m *Monitor) AttachRedis(client redis.UniversalClient, prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redis, m.prefix = client, prefix
+}
+
+// Privileged reports whether granting role raises an event
+func (m *Monitor) Privileged(role string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Contains(m.cfg.PrivilegedRoles, role)
+}
+
+// Raise logs e, counts it and hands it to the handler, stamping its time when unset
+func (m *Monitor) Raise(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	raised.WithLabelValues(e.Type, e.Severity).Inc()
+	attrs := []any{"type", e.Type, "severity", e.Severity, "ip", e.IP}
+	if e.UserID != nil {
+		attrs = append(attrs, "user_id", *e.UserID)
+	}
+	slog.Warn("Security event: "+e.Message, attrs...)
+
+	m.mu.RLock()
+	h := m.handler
+	m.mu.RUnlock()
+	if h != nil {
+		h(context.WithoutCancel(ctx), e)
+	}
+}
+
+// AuthFailure counts a 401 answered to ip, raising TypeAuthFailures when the address reaches
+// the threshold within the window. When Redis fails the local count decides.
+func (m *Monitor) AuthFailure(ctx context.Context, ip string) {
+	m.mu.RLock()
+	cfg, client, prefix := m.cfg, m.redis, m.prefix
+	m.mu.RUnlock()
+	if cfg.AuthFailureThreshold <= 0 || ip == "" {
+		return
+	}
+
+	var count int
+	if client != nil {
+		n, err := countScript.Run(ctx, client, []string{prefix + "auth_failures:" + ip}, cfg.AuthFailureWindow.Milliseconds()).Int()
+		if err != nil {
+			slog.Warn("Failed to count an authentication failure in Redis, counting locally", "error", err)
+			count = m.countLocal(ip, cfg.AuthFailureWindow)
+		} else {
+			count = n
+		}
+	} else {
+		count = m.countLocal(ip, cfg.AuthFailureWindow)
+	}
+
+	if count == cfg.AuthFailureThreshold {
+		m.Raise(ctx, Event{
+			Type:     TypeAuthFailures,
+			Severity: SeverityHigh,
+			Message:  fmt.Sprintf("%d authentication failures from %s within %s", count, ip, cfg.AuthFailureWindow),
+			IP:       ip,
+			Details:  map[string]any{"failures": count, "window": cfg.AuthFailureWindow.String()},
+		})
+	}
+}
+
+// countLocal counts a failure of ip in this process
+func (m *Monitor) countLocal(ip string, window time.Duration) int {
+	now := time.Now()
+	m.localMu.Lock()
+	defer m.localMu.Unlock()
+
+	if len(m.local) >= maxTracked {
+		for k, w := range m.local {
+			if now.Sub(w.start) >= window {
+				delete(m.local, k)
+			}
+		}
+	}
+	w, ok := m.local[ip]
+	if !ok || now.Sub(w.start) >= window {
+		w = &failureWindow{start: now}
+		m.local[ip] = w
+	}
+	w.count++
+	return w.count
+}
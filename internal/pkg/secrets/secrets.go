@@ -0,0 +1,246 @@
+// Package secrets fetches credentials from HashiCorp Vault or AWS Secrets Manager, so they
+// need not be kept in the environment or config.yaml.
+//
+// A setting holds a reference instead of its value:
+//
+//	vault:secret/data/adminbe#db_password   field db_password of the Vault secret at that API path
+//	awssm:prod/adminbe#db_password          field of an AWS secret holding a JSON object
+//	awssm:prod/jwt-secret                   an AWS secret holding the value itself
+//
+// Vault paths are as in its HTTP API, so a KV version 2 mount needs its data/ segment. Both
+// stores are called over plain HTTP, with no SDK: Vault with a token, AWS with requests
+// signed with static keys (Signature Version 4).
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reference prefixes
+const (
+	PrefixVault = "vault:"
+	PrefixAWS   = "awssm:"
+)
+
+// Config is the secrets section of the configuration: how to reach the stores references
+// point to, and how often to fetch them again
+type Config struct {
+	Vault VaultConfig `yaml:"vault"`
+	AWS   AWSConfig   `yaml:"aws"`
+	// RefreshInterval is how often referenced secrets are fetched again, applying those that
+	// changed as a configuration reload would; 0 fetches them only on startup and reloads
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"SECRETS_REFRESH_INTERVAL" default:"5m"`
+	// Timeout bounds fetching every referenced secret
+	Timeout time.Duration `yaml:"timeout" env:"SECRETS_TIMEOUT" default:"10s"`
+}
+
+// VaultConfig reaches a Vault server
+type VaultConfig struct {
+	Addr string `yaml:"addr" env:"VAULT_ADDR"`
+	// Token authenticates; TokenFile is read on every fetch instead, for a token an agent renews
+	Token     string `yaml:"token" env:"VAULT_TOKEN"`
+	TokenFile string `yaml:"token_file" env:"VAULT_TOKEN_FILE"`
+	Namespace string `yaml:"namespace" env:"VAULT_NAMESPACE"` // Vault Enterprise
+}
+
+// AWSConfig reaches AWS Secrets Manager with an access key
+type AWSConfig struct {
+	Region          string `yaml:"region" env:"AWS_REGION"`
+	AccessKeyID     string `yaml:"access_key_id" env:"AWS_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" env:"AWS_SECRET_ACCESS_KEY"`
+	SessionToken    string `yaml:"session_token" env:"AWS_SESSION_TOKEN"`
+	// Endpoint replaces https://secretsmanager.<region>.amazonaws.com, e.g. for a VPC endpoint
+	Endpoint string `yaml:"endpoint" env:"AWS_SECRETS_MANAGER_ENDPOINT"`
+}
+
+// IsReference reports whether a setting names a secret rather than holding a value
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, PrefixVault) || strings.HasPrefix(value, PrefixAWS)
+}
+
+// Resolver fetches referenced secrets
+type Resolver struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewResolver creates a resolver for the stores in cfg
+func NewResolver(cfg Config) *Resolver {
+	return &Resolver{cfg: cfg, client: &http.Client{}}
+}
+
+// Resolve returns the value of every reference in refs. Each secret is fetched once however
+// many of its fields are referenced; the first failure is returned.
+func (r *Resolver) Resolve(ctx context.Context, refs []string) (map[string]string, error) {
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	fetched := make(map[string]map[string]string)
+	values := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		store, rest, _ := strings.Cut(ref, ":")
+		path, field, hasField := strings.Cut(rest, "#")
+		if path == "" || (hasField && field == "") {
+			return nil, fmt.Errorf("malformed secret reference %q", ref)
+		}
+
+		key := store + ":" + path
+		secret, ok := fetched[key]
+		if !ok {
+			var err error
+			switch store + ":" {
+			case PrefixVault:
+				secret, err = r.fetchVault(ctx, path)
+			case PrefixAWS:
+				secret, err = r.fetchAWS(ctx, path, hasField)
+			default:
+				err = errors.New("unknown secret store")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			fetched[key] = secret
+		}
+
+		if !hasField {
+			if store+":" == PrefixVault {
+				return nil, fmt.Errorf("%s: name the field, as in %s#password", ref, ref)
+			}
+			field = ""
+		}
+		value, ok := secret[field]
+		if !ok {
+			return nil, fmt.Errorf("%s: no field %q", key, field)
+		}
+		values[ref] = value
+	}
+	return values, nil
+}
+
+// fetchVault reads the secret at path, a KV version 1 or 2 path
+func (r *Resolver) fetchVault(ctx context.Context, path string) (map[string]string, error) {
+	cfg := r.cfg.Vault
+	if cfg.Addr == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	token := cfg.Token
+	if cfg.TokenFile != "" {
+		data, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(cfg.Addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", cfg.Namespace)
+	}
+
+	var body struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+	status, err := r.do(req, &body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("vault answered %d: %s", status, strings.Join(body.Errors, "; "))
+	}
+
+	// KV version 2 nests the fields under data.data, beside data.metadata
+	fields := body.Data
+	if inner, ok := fields["data"]; ok {
+		if _, versioned := fields["metadata"]; versioned {
+			fields = nil
+			if err := json.Unmarshal(inner, &fields); err != nil {
+				return nil, fmt.Errorf("unexpected vault secret: %w", err)
+			}
+		}
+	}
+	return stringFields(fields), nil
+}
+
+// fetchAWS reads the secret id. With fields its string must be a JSON object; without, the
+// whole string is the value, under the field "".
+func (r *Resolver) fetchAWS(ctx context.Context, id string, fields bool) (map[string]string, error) {
+	payload, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := r.awsRequest(ctx, "secretsmanager.GetSecretValue", payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		SecretString *string `json:"SecretString"`
+		Type         string  `json:"__type"`
+		Message      string  `json:"message"`
+	}
+	status, err := r.do(req, &body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("secrets manager answered %d: %s %s", status, body.Type, body.Message)
+	}
+	if body.SecretString == nil {
+		return nil, errors.New("binary secrets are not supported")
+	}
+	if !fields {
+		return map[string]string{"": *body.SecretString}, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*body.SecretString), &object); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	return stringFields(object), nil
+}
+
+// do sends req and decodes its JSON answer, whatever the status, into out
+func (r *Resolver) do(req *http.Request, out any) (int, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil && resp.StatusCode == http.StatusOK {
+			return 0, fmt.Errorf("unexpected answer: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// stringFields renders JSON fields as settings: strings as they are, anything else as JSON
+func stringFields(fields map[string]json.RawMessage) map[string]string {
+	out := make(map[string]string, len(fields))
+	for name, raw := range fields {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			out[name] = s
+		} else {
+			out[name] = string(raw)
+		}
+	}
+	return out
+}
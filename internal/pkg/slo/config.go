@@ -0,0 +1,103 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the slo section of config.yaml
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// WebhookURL receives a JSON POST when an alert fires or resolves
+	WebhookURL string `yaml:"webhook_url"`
+	// EvaluationInterval is how often burn rates are computed
+	EvaluationInterval time.Duration `yaml:"evaluation_interval" default:"1m"`
+	// MinRequests is the fewest requests in an alert's short window for it to fire, so a
+	// handful of errors at night does not page anyone
+	MinRequests uint64      `yaml:"min_requests" default:"10"`
+	Objectives  []Objective `yaml:"objectives"`
+	Alerts      []Alert     `yaml:"alerts"`
+}
+
+// Objective is the target for one group of routes. Availability is the fraction of
+// requests that must not fail with a 5xx; LatencyTarget is the fraction that must finish
+// within LatencyThreshold. Either can be left out.
+type Objective struct {
+	Name             string        `yaml:"name"`
+	Routes           []string      `yaml:"routes"` // route pattern prefixes, e.g. /api/users
+	Availability     float64       `yaml:"availability"`
+	LatencyThreshold time.Duration `yaml:"latency_threshold"`
+	LatencyTarget    float64       `yaml:"latency_target"`
+}
+
+// Alert fires when the error budget burns at least BurnRate times faster than sustainable
+// over both LongWindow and ShortWindow. The short window makes the alert resolve soon
+// after the problem stops.
+type Alert struct {
+	Name        string        `yaml:"name"`
+	LongWindow  time.Duration `yaml:"long_window"`
+	ShortWindow time.Duration `yaml:"short_window"`
+	BurnRate    float64       `yaml:"burn_rate"`
+}
+
+// DefaultAlerts are the usual multiwindow pair: 2% of a 30-day budget spent in an hour
+// pages, 5% in six hours opens a ticket
+var DefaultAlerts = []Alert{
+	{Name: "page", LongWindow: time.Hour, ShortWindow: 5 * time.Minute, BurnRate: 14.4},
+	{Name: "ticket", LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, BurnRate: 6},
+}
+
+// maxWindow bounds alert windows, and with them the request counts kept per objective
+const maxWindow = 24 * time.Hour
+
+// Validate checks an enabled configuration, filling in DefaultAlerts when it has none. A
+// missing section leaves SLO evaluation disabled.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.EvaluationInterval <= 0 {
+		return errors.New("slo: evaluation_interval must be positive")
+	}
+	if cfg.MinRequests == 0 {
+		return errors.New("slo: min_requests must be positive")
+	}
+	if len(cfg.Alerts) == 0 {
+		cfg.Alerts = DefaultAlerts
+	}
+	return cfg.validate()
+}
+
+func (cfg Config) validate() error {
+	if len(cfg.Objectives) == 0 {
+		return errors.New("slo: enabled without objectives")
+	}
+	names := make(map[string]bool, len(cfg.Objectives))
+	for _, o := range cfg.Objectives {
+		switch {
+		case o.Name == "" || names[o.Name]:
+			return fmt.Errorf("slo: objective names must be set and unique (%q)", o.Name)
+		case len(o.Routes) == 0:
+			return fmt.Errorf("slo: objective %q has no routes", o.Name)
+		case o.Availability == 0 && o.LatencyThreshold == 0:
+			return fmt.Errorf("slo: objective %q sets neither availability nor latency_threshold", o.Name)
+		case o.Availability < 0 || o.Availability >= 1:
+			return fmt.Errorf("slo: objective %q availability must be below 1, e.g. 0.999", o.Name)
+		case o.LatencyThreshold > 0 && (o.LatencyTarget <= 0 || o.LatencyTarget >= 1):
+			return fmt.Errorf("slo: objective %q latency_target must be between 0 and 1, e.g. 0.99", o.Name)
+		}
+		names[o.Name] = true
+	}
+	for _, a := range cfg.Alerts {
+		switch {
+		case a.Name == "":
+			return errors.New("slo: alerts need a name")
+		case a.ShortWindow < time.Minute || a.LongWindow < a.ShortWindow || a.LongWindow > maxWindow:
+			return fmt.Errorf("slo: alert %q windows must satisfy 1m <= short_window <= long_window <= %s", a.Name, maxWindow)
+		case a.BurnRate <= 0:
+			return fmt.Errorf("slo: alert %q burn_rate must be positive", a.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package slo
+
+import (
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results recorded on webhookDeliveries
+const (
+	deliveryOK     = "ok"
+	deliveryFailed = "error"
+)
+
+var (
+	burnRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "burn_rate",
+		Help:      "Error budget burn rate over each alert's long window, by objective, SLI and alert.",
+	}, []string{"objective", "sli", "alert"})
+
+	alertFiring = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "alert_firing",
+		Help:      "1 while a burn rate alert is firing, by objective, SLI and alert.",
+	}, []string{"objective", "sli", "alert"})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "slo",
+		Name:      "webhook_deliveries_total",
+		Help:      "SLO alert webhook deliveries by result (ok, error).",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(burnRate, alertFiring, webhookDeliveries)
+}
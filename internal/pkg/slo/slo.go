@@ -0,0 +1,258 @@
+// Package slo evaluates availability and latency objectives in the process and pushes a
+// webhook when an error budget burns too fast, so alerting works without a Prometheus
+// rule pipeline. Alerts also go to Slack and Teams through the alerting.Notifier it is
+// built with.
+//
+// Requests are counted per objective in one-minute buckets. Every evaluation interval the
+// burn rate of each objective's availability and latency SLI is computed over each alert's
+// long and short window; the alert fires when both exceed its burn rate, and resolves when
+// either drops below it. Counts are per process, so each replica alerts on its own traffic.
+package slo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"adminbe/internal/pkg/alerting"
+)
+
+// SLIs evaluated for an objective
+const (
+	SLIAvailability = "availability"
+	SLILatency      = "latency"
+)
+
+// Alert states sent to the webhook
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// bucket counts the requests of one minute
+type bucket struct {
+	minute int64
+	total  uint64
+	errors uint64
+	slow   uint64
+}
+
+// objectiveState holds the recent request counts of one objective
+type objectiveState struct {
+	Objective
+
+	mu      sync.Mutex
+	buckets []bucket
+}
+
+// counts sums the buckets of the last window before now
+func (o *objectiveState) counts(now time.Time, window time.Duration) (total, errors, slow uint64) {
+	from := now.Add(-window).Unix() / 60
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, b := range o.buckets {
+		if b.minute > from && b.total > 0 {
+			total += b.total
+			errors += b.errors
+			slow += b.slow
+		}
+	}
+	return total, errors, slow
+}
+
+// Evaluator records requests and checks them against the configured objectives
+type Evaluator struct {
+	cfg        Config
+	objectives []*objectiveState
+	notifier   *webhookNotifier
+	alerts     alerting.Notifier
+
+	// firing is keyed by objective/sli/alert and only touched by the evaluation loop
+	firing map[string]bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewEvaluator builds an evaluator for cfg, which must come from LoadConfig, that also
+// notifies alerts of every change
+func NewEvaluator(cfg Config, alerts alerting.Notifier) *Evaluator {
+	var longest time.Duration
+	for _, a := range cfg.Alerts {
+		longest = max(longest, a.LongWindow)
+	}
+	e := &Evaluator{
+		cfg:      cfg,
+		notifier: newWebhookNotifier(cfg.WebhookURL),
+		alerts:   alerts,
+		firing:   make(map[string]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, o := range cfg.Objectives {
+		e.objectives = append(e.objectives, &objectiveState{
+			Objective: o,
+			buckets:   make([]bucket, int(longest/time.Minute)+1),
+		})
+	}
+	return e
+}
+
+// Record counts one finished request against every objective whose routes cover route,
+// the matched route pattern
+func (e *Evaluator) Record(route string, status int, duration time.Duration) {
+	now := time.Now().Unix() / 60
+	for _, o := range e.objectives {
+		if !o.covers(route) {
+			continue
+		}
+		o.mu.Lock()
+		b := &o.buckets[now%int64(len(o.buckets))]
+		if b.minute != now {
+			*b = bucket{minute: now}
+		}
+		b.total++
+		if status >= 500 {
+			b.errors++
+		}
+		if o.LatencyThreshold > 0 && duration > o.LatencyThreshold {
+			b.slow++
+		}
+		o.mu.Unlock()
+	}
+}
+
+// covers reports whether route is one of the objective's route prefixes or below one
+func (o *objectiveState) covers(route string) bool {
+	for _, prefix := range o.Routes {
+		if route == prefix || strings.HasPrefix(route, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Start evaluates the objectives every EvaluationInterval until Stop
+func (e *Evaluator) Start() {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.cfg.EvaluationInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				e.evaluate(now)
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+	slog.Info("SLO evaluation started", "objectives", len(e.objectives), "alerts", len(e.cfg.Alerts),
+		"interval", e.cfg.EvaluationInterval.String())
+}
+
+// Stop ends the evaluation loop and waits for it
+func (e *Evaluator) Stop() {
+	close(e.stop)
+	<-e.done
+}
+
+// evaluate computes every burn rate and notifies on alerts that changed state
+func (e *Evaluator) evaluate(now time.Time) {
+	for _, o := range e.objectives {
+		for _, sli := range o.slis() {
+			for _, a := range e.cfg.Alerts {
+				burn := e.burn(o, sli, a, now)
+				burnRate.WithLabelValues(o.Name, sli, a.Name).Set(burn.Long)
+
+				key := o.Name + "/" + sli + "/" + a.Name
+				firing := burn.Requests >= e.cfg.MinRequests && burn.Long >= a.BurnRate && burn.Short >= a.BurnRate
+				if firing == e.firing[key] {
+					continue
+				}
+				e.firing[key] = firing
+				alertFiring.WithLabelValues(o.Name, sli, a.Name).Set(boolFloat(firing))
+
+				notice := newNotification(o.Objective, sli, a, burn, firing, now)
+				if firing {
+					slog.Warn("SLO burn rate alert firing", "objective", o.Name, "sli", sli, "alert", a.Name,
+						"burn_rate_long", burn.Long, "burn_rate_short", burn.Short, "threshold", a.BurnRate)
+				} else {
+					slog.Info("SLO burn rate alert resolved", "objective", o.Name, "sli", sli, "alert", a.Name)
+				}
+				e.notifier.send(notice)
+				e.alert(notice)
+			}
+		}
+	}
+}
+
+// alert hands n to the alerts notifier, bounded like a webhook delivery
+func (e *Evaluator) alert(n Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	if err := e.alerts.Notify(ctx, n.alert()); err != nil {
+		slog.Error("Failed to send SLO alert", "objective", n.Objective, "alert", n.Alert, "error", err)
+	}
+}
+
+// slis lists the SLIs the objective sets a target for
+func (o *objectiveState) slis() []string {
+	var out []string
+	if o.Availability > 0 {
+		out = append(out, SLIAvailability)
+	}
+	if o.LatencyThreshold > 0 {
+		out = append(out, SLILatency)
+	}
+	return out
+}
+
+// target returns the objective's target for sli, e.g. 0.999
+func (o *objectiveState) target(sli string) float64 {
+	if sli == SLILatency {
+		return o.LatencyTarget
+	}
+	return o.Availability
+}
+
+// burnRates are the burn rates of one SLI over an alert's windows
+type burnRates struct {
+	Long       float64 `json:"long"`
+	Short      float64 `json:"short"`
+	ErrorRatio float64 `json:"error_ratio"` // bad fraction over the long window
+	Requests   uint64  `json:"requests"`    // requests in the short window
+}
+
+// burn computes how many times faster than sustainable sli is spending its budget
+func (e *Evaluator) burn(o *objectiveState, sli string, a Alert, now time.Time) burnRates {
+	budget := 1 - o.target(sli)
+	ratio := func(window time.Duration) (float64, uint64) {
+		total, errors, slow := o.counts(now, window)
+		if total == 0 {
+			return 0, 0
+		}
+		bad := errors
+		if sli == SLILatency {
+			bad = slow
+		}
+		return float64(bad) / float64(total), total
+	}
+
+	longRatio, _ := ratio(a.LongWindow)
+	shortRatio, requests := ratio(a.ShortWindow)
+	return burnRates{
+		Long:       longRatio / budget,
+		Short:      shortRatio / budget,
+		ErrorRatio: longRatio,
+		Requests:   requests,
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
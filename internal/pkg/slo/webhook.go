@@ -0,0 +1,124 @@
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"adminbe/internal/pkg/alerting"
+)
+
+// Notification is the JSON body POSTed to the webhook. Text is a one-line summary, so
+// Slack-style incoming webhooks can display it as is.
+type Notification struct {
+	Status      string    `json:"status"` // firing or resolved
+	Objective   string    `json:"objective"`
+	SLI         string    `json:"sli"`
+	Alert       string    `json:"alert"`
+	Target      float64   `json:"target"`
+	Threshold   float64   `json:"burn_rate_threshold"`
+	BurnRate    burnRates `json:"burn_rate"`
+	LongWindow  string    `json:"long_window"`
+	ShortWindow string    `json:"short_window"`
+	Time        time.Time `json:"time"`
+	Text        string    `json:"text"`
+}
+
+func newNotification(o Objective, sli string, a Alert, burn burnRates, firing bool, now time.Time) Notification {
+	n := Notification{
+		Status:      StatusResolved,
+		Objective:   o.Name,
+		SLI:         sli,
+		Alert:       a.Name,
+		Target:      o.Availability,
+		Threshold:   a.BurnRate,
+		BurnRate:    burn,
+		LongWindow:  a.LongWindow.String(),
+		ShortWindow: a.ShortWindow.String(),
+		Time:        now,
+	}
+	if sli == SLILatency {
+		n.Target = o.LatencyTarget
+	}
+	if firing {
+		n.Status = StatusFiring
+	}
+	n.Text = fmt.Sprintf("[%s] SLO %s %s (%s): error budget burning %.1fx over %s, %.1fx over %s (threshold %.1fx)",
+		strings.ToUpper(n.Status), o.Name, sli, a.Name, burn.Long, n.LongWindow, burn.Short, n.ShortWindow, a.BurnRate)
+	return n
+}
+
+// alert converts n for the alerting webhooks. The page alert of DefaultAlerts is critical,
+// every other one a warning.
+func (n Notification) alert() alerting.Alert {
+	severity := alerting.SeverityWarning
+	if n.Alert == "page" {
+		severity = alerting.SeverityCritical
+	}
+	return alerting.Alert{
+		Source:   "slo",
+		Key:      n.Objective + "/" + n.SLI + "/" + n.Alert,
+		Status:   n.Status,
+		Severity: severity,
+		Title:    fmt.Sprintf("SLO %s %s burn rate (%s)", n.Objective, n.SLI, n.Alert),
+		Text:     n.Text,
+		Fields: map[string]string{
+			"burn_rate_long":  fmt.Sprintf("%.1fx over %s", n.BurnRate.Long, n.LongWindow),
+			"burn_rate_short": fmt.Sprintf("%.1fx over %s", n.BurnRate.Short, n.ShortWindow),
+			"threshold":       fmt.Sprintf("%.1fx", n.Threshold),
+		},
+		Time: n.Time,
+	}
+}
+
+// webhookTimeout bounds one delivery, so a slow receiver cannot hold up evaluation
+const webhookTimeout = 5 * time.Second
+
+// webhookNotifier delivers notifications; with no URL they are only logged
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string) *webhookNotifier {
+	return &webhookNotifier{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// send POSTs n, logging rather than retrying on failure; the next state change is sent anyway
+func (w *webhookNotifier) send(n Notification) {
+	if w.url == "" {
+		return
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		slog.Error("Failed to encode SLO notification", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to build SLO webhook request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		webhookDeliveries.WithLabelValues(deliveryFailed).Inc()
+		slog.Error("SLO webhook delivery failed", "objective", n.Objective, "alert", n.Alert, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		webhookDeliveries.WithLabelValues(deliveryFailed).Inc()
+		slog.Error("SLO webhook rejected notification", "objective", n.Objective, "alert", n.Alert, "status", resp.StatusCode)
+		return
+	}
+	webhookDeliveries.WithLabelValues(deliveryOK).Inc()
+}
@@ -0,0 +1,105 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gateway posts messages as JSON to an HTTP SMS gateway. Local providers differ in their
+// APIs, so a small adapter in front of one, or one that takes this shape, is expected:
+//
+//	POST SMS_GATEWAY_URL  {"to", "message", "sender", "callback_url"}
+//	2xx                   {"id" or "message_id", "status"}
+//
+// and reports delivery by posting {"id" or "message_id", "status", "error"} to callback_url.
+type gateway struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newGateway(cfg Config) *gateway {
+	return &gateway{cfg: cfg, client: &http.Client{}}
+}
+
+// gatewayStatus is both the answer to a message and a delivery report
+type gatewayStatus struct {
+	ID        string `json:"id"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+}
+
+func (s gatewayStatus) messageID() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return s.MessageID
+}
+
+func (g *gateway) Send(ctx context.Context, msg Message) (Result, error) {
+	payload := map[string]string{"to": msg.To, "message": msg.Text}
+	if g.cfg.Sender != "" {
+		payload["sender"] = g.cfg.Sender
+	}
+	if callback := g.cfg.callbackURL(); callback != "" {
+		payload["callback_url"] = callback + "?token=" + url.QueryEscape(g.cfg.CallbackSecret)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.cfg.GatewayURL, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, fmt.Errorf("sms gateway: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.cfg.GatewayToken != "" {
+		req.Header.Set("Authorization", "Bearer "+g.cfg.GatewayToken)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("sms gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return Result{}, fmt.Errorf("sms gateway answered %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var status gatewayStatus
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return Result{}, fmt.Errorf("sms gateway answered %d: %w", resp.StatusCode, err)
+	}
+	if status.messageID() == "" {
+		return Result{}, errors.New("sms gateway answered no message id")
+	}
+	if normalizeStatus(status.Status) == StatusFailed {
+		return Result{}, fmt.Errorf("sms gateway refused the message: %s %s", status.Status, status.Error)
+	}
+	return Result{MessageID: status.messageID(), Status: normalizeStatus(status.Status)}, nil
+}
+
+// parseGatewayCallback reads a delivery report, authenticated by the ?token= the callback URL
+// was handed out with
+func parseGatewayCallback(cfg Config, r *http.Request) (*StatusUpdate, error) {
+	if cfg.CallbackSecret == "" || cfg.Driver != DriverGateway ||
+		subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(cfg.CallbackSecret)) != 1 {
+		return nil, ErrUnauthorized
+	}
+	var status gatewayStatus
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+	if status.messageID() == "" {
+		return nil, errors.New("callback without id")
+	}
+	return &StatusUpdate{MessageID: status.messageID(), Status: normalizeStatus(status.Status), Error: status.Error}, nil
+}
@@ -0,0 +1,66 @@
+package sms
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidPhone means a number cannot be read as a phone number
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone writes number in E.164, e.g. +6281234567890. Spaces, dashes, dots and
+// parentheses are dropped; a leading 0 is replaced by countryCode, as in 0812..., and a
+// number starting with countryCode itself, as in 62812..., gains the plus.
+func NormalizePhone(number, countryCode string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	digits := b.String()
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(number), "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = countryCode + digits[1:]
+	case countryCode != "" && strings.HasPrefix(digits, countryCode):
+	default:
+		return "", ErrInvalidPhone
+	}
+	// E.164 allows 15 digits; shorter than 8 is no subscriber number anywhere
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + digits, nil
+}
+
+// MaskPhone hides the middle of an E.164 number, e.g. +62812****7890, for answers to
+// callers who have not proven they own it
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	if len(phone) <= 10 {
+		return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+	}
+	return phone[:6] + strings.Repeat("*", len(phone)-10) + phone[len(phone)-4:]
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+// Package sms sends text messages: through Twilio's Messages API, through an HTTP gateway of
+// the kind Indonesian SMS providers offer, or to the log, for development. Providers report
+// delivery later by calling back; ParseCallback authenticates and reads those calls. What is
+// sent, and how often, is left to the caller.
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"adminbe/internal/pkg/ratelimit"
+)
+
+// Drivers
+const (
+	DriverNone    = "none"
+	DriverLog     = "log"
+	DriverTwilio  = "twilio"
+	DriverGateway = "gateway"
+)
+
+// Delivery statuses, to which each provider's own are mapped
+const (
+	StatusQueued    = "queued"
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// ErrDisabled is returned by Send while the driver is none
+var ErrDisabled = errors.New("sms is disabled")
+
+// ErrUnauthorized means a delivery callback failed authentication
+var ErrUnauthorized = errors.New("sms callback not authenticated")
+
+// Config is the sms section of the configuration
+type Config struct {
+	// Driver is none (send nothing), log, twilio or gateway
+	Driver string `yaml:"driver" env:"SMS_DRIVER" default:"none"`
+	// Sender is the number or alphanumeric sender ID messages come from
+	Sender string `yaml:"sender" env:"SMS_SENDER"`
+
+	TwilioAccountSID string `yaml:"twilio_account_sid" env:"TWILIO_ACCOUNT_SID"`
+	TwilioAuthToken  string `yaml:"twilio_auth_token" env:"TWILIO_AUTH_TOKEN"`
+	// TwilioMessagingServiceSID sends through a messaging service instead of SMS_SENDER
+	TwilioMessagingServiceSID string `yaml:"twilio_messaging_service_sid" env:"TWILIO_MESSAGING_SERVICE_SID"`
+	TwilioAPIURL              string `yaml:"twilio_api_url" env:"TWILIO_API_URL" default:"https://api.twilio.com"`
+
+	// GatewayURL takes {"to","message","sender","callback_url"} as a JSON POST
+	GatewayURL   string `yaml:"gateway_url" env:"SMS_GATEWAY_URL"`
+	GatewayToken string `yaml:"gateway_token" env:"SMS_GATEWAY_TOKEN"`
+
+	// CallbackURL is the public address of this API, e.g. https://admin-api.example.com;
+	// providers report delivery to <CallbackURL>/api/sms/status/<driver>. Empty asks for no
+	// reports.
+	CallbackURL string `yaml:"callback_url" env:"SMS_CALLBACK_URL"`
+	// CallbackSecret authenticates gateway reports, which carry it as ?token=; Twilio's are
+	// signed with TWILIO_AUTH_TOKEN instead
+	CallbackSecret string `yaml:"callback_secret" env:"SMS_CALLBACK_SECRET"`
+
+	// DefaultCountryCode is assumed for numbers written without one, such as 0812...
+	DefaultCountryCode string        `yaml:"default_country_code" env:"SMS_DEFAULT_COUNTRY_CODE" default:"62"`
+	Timeout            time.Duration `yaml:"timeout" env:"SMS_TIMEOUT" default:"15s"`
+
+	// RatePerNumber bounds the codes sent to one number, across instances once the limiter
+	// has Redis
+	RatePerNumber ratelimit.Rate `yaml:"rate_per_number" env:"SMS_RATE_PER_NUMBER" default:"3/15m"`
+	// OTPLength is the number of digits of a one-time code
+	OTPLength int `yaml:"otp_length" env:"OTP_LENGTH" default:"6" min:"4" max:"10"`
+	// OTPTTL is how long a code stays valid
+	OTPTTL time.Duration `yaml:"otp_ttl" env:"OTP_TTL" default:"5m"`
+	// OTPMaxAttempts is how many wrong guesses void a code
+	OTPMaxAttempts int `yaml:"otp_max_attempts" env:"OTP_MAX_ATTEMPTS" default:"5" min:"1" max:"20"`
+}
+
+// Validate checks the driver has what it needs
+func (c *Config) Validate() error {
+	c.Driver = strings.ToLower(strings.TrimSpace(c.Driver))
+	var errs []error
+	switch c.Driver {
+	case DriverNone, DriverLog:
+	case DriverTwilio:
+		if c.TwilioAccountSID == "" || c.TwilioAuthToken == "" {
+			errs = append(errs, errors.New("SMS_DRIVER=twilio needs TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN"))
+		}
+		if c.Sender == "" && c.TwilioMessagingServiceSID == "" {
+			errs = append(errs, errors.New("SMS_DRIVER=twilio needs SMS_SENDER or TWILIO_MESSAGING_SERVICE_SID"))
+		}
+		if !isHTTPURL(c.TwilioAPIURL) {
+			errs = append(errs, errors.New("TWILIO_API_URL must be an http or https URL"))
+		}
+	case DriverGateway:
+		if !isHTTPURL(c.GatewayURL) {
+			errs = append(errs, errors.New("SMS_DRIVER=gateway needs SMS_GATEWAY_URL, an http or https URL"))
+		}
+		if c.CallbackURL != "" && c.CallbackSecret == "" {
+			errs = append(errs, errors.New("SMS_CALLBACK_URL with SMS_DRIVER=gateway needs SMS_CALLBACK_SECRET"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("SMS_DRIVER must be none, log, twilio or gateway, not %q", c.Driver))
+	}
+	if c.CallbackURL != "" && !isHTTPURL(c.CallbackURL) {
+		errs = append(errs, errors.New("SMS_CALLBACK_URL must be an http or https URL"))
+	}
+	if !isDigits(c.DefaultCountryCode) || len(c.DefaultCountryCode) > 3 {
+		errs = append(errs, fmt.Errorf("SMS_DEFAULT_COUNTRY_CODE must be 1 to 3 digits, not %q", c.DefaultCountryCode))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("SMS_TIMEOUT must be positive"))
+	}
+	if c.OTPTTL < time.Minute || c.OTPTTL > time.Hour {
+		errs = append(errs, errors.New("OTP_TTL must be between 1m and 1h"))
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// callbackURL is where the driver's delivery reports go, empty when none are asked for
+func (c *Config) callbackURL() string {
+	if c.CallbackURL == "" {
+		return ""
+	}
+	return strings.TrimRight(c.CallbackURL, "/") + "/api/sms/status/" + c.Driver
+}
+
+// Message is a text to one E.164 number
+type Message struct {
+	To   string
+	Text string
+}
+
+// Result is what the provider answered to a message: its ID there, which delivery reports
+// refer to, and the status so far
+type Result struct {
+	MessageID string
+	Status    string
+}
+
+// StatusUpdate is a delivery report. Error is the provider's reason for a failure.
+type StatusUpdate struct {
+	MessageID string
+	Status    string
+	Error     string
+}
+
+// Sender delivers messages; each driver is one
+type Sender interface {
+	Send(ctx context.Context, msg Message) (Result, error)
+}
+
+// Client sends with the configured driver
+type Client struct {
+	mu     sync.RWMutex
+	cfg    Config
+	sender Sender
+}
+
+// Default is the process-wide client, sending nothing until Configure
+var Default = &Client{}
+
+// Configure replaces the driver, e.g. on startup or a configuration reload
+func (c *Client) Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var sender Sender
+	switch cfg.Driver {
+	case DriverLog:
+		sender = &logSender{}
+	case DriverTwilio:
+		sender = newTwilio(cfg)
+	case DriverGateway:
+		sender = newGateway(cfg)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg, c.sender = cfg, sender
+	return nil
+}
+
+// Enabled reports whether a driver is configured
+func (c *Client) Enabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sender != nil
+}
+
+// Config returns the configuration in effect
+func (c *Client) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg
+}
+
+// Send delivers msg once, bounded by SMS_TIMEOUT. msg.To must be an E.164 number, see
+// NormalizePhone.
+func (c *Client) Send(ctx context.Context, msg Message) (Result, error) {
+	c.mu.RLock()
+	sender, cfg := c.sender, c.cfg
+	c.mu.RUnlock()
+	if sender == nil {
+		return Result{}, ErrDisabled
+	}
+	if !strings.HasPrefix(msg.To, "+") || !isDigits(msg.To[1:]) {
+		return Result{}, fmt.Errorf("invalid recipient %q", msg.To)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	return sender.Send(ctx, msg)
+}
+
+// ParseCallback authenticates and reads a delivery report posted to
+// /api/sms/status/<driver>. Reports for a driver other than the configured one are refused
+// with ErrUnauthorized, as are those failing authentication.
+func (c *Client) ParseCallback(driver string, r *http.Request) (*StatusUpdate, error) {
+	cfg := c.Config()
+	switch driver {
+	case DriverTwilio:
+		return parseTwilioCallback(cfg, r)
+	case DriverGateway:
+		return parseGatewayCallback(cfg, r)
+	default:
+		return nil, ErrUnauthorized
+	}
+}
+
+// logSender writes messages to the log instead of sending them, reporting them delivered
+type logSender struct {
+	n atomic.Uint64
+}
+
+func (l *logSender) Send(_ context.Context, msg Message) (Result, error) {
+	id := fmt.Sprintf("log-%d", l.n.Add(1))
+	slog.Info("SMS (log driver, not sent)", "id", id, "to", msg.To, "text", msg.Text)
+	return Result{MessageID: id, Status: StatusDelivered}, nil
+}
+
+// normalizeStatus maps a provider's status to one of ours, queued for those it cannot place
+func normalizeStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "sent", "submitted":
+		return StatusSent
+	case "delivered", "read", "success":
+		return StatusDelivered
+	case "failed", "undelivered", "canceled", "cancelled", "rejected", "expired", "error":
+		return StatusFailed
+	default:
+		return StatusQueued
+	}
+}
@@ -0,0 +1,113 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// twilio sends through the Messages resource of Twilio's REST API
+type twilio struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newTwilio(cfg Config) *twilio {
+	return &twilio{cfg: cfg, client: &http.Client{}}
+}
+
+func (t *twilio) Send(ctx context.Context, msg Message) (Result, error) {
+	form := url.Values{"To": {msg.To}, "Body": {msg.Text}}
+	if t.cfg.TwilioMessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", t.cfg.TwilioMessagingServiceSID)
+	} else {
+		form.Set("From", t.cfg.Sender)
+	}
+	if callback := t.cfg.callbackURL(); callback != "" {
+		form.Set("StatusCallback", callback)
+	}
+	endpoint := strings.TrimSuffix(t.cfg.TwilioAPIURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(t.cfg.TwilioAccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Result{}, fmt.Errorf("twilio: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.cfg.TwilioAccountSID, t.cfg.TwilioAuthToken)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("twilio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Messages answer 201 with the message, errors with {"code", "message"}
+	var body struct {
+		SID     string `json:"sid"`
+		Status  string `json:"status"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return Result{}, fmt.Errorf("twilio answered %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return Result{}, fmt.Errorf("twilio answered %d: %d %s", resp.StatusCode, body.Code, body.Message)
+	}
+	return Result{MessageID: body.SID, Status: normalizeStatus(body.Status)}, nil
+}
+
+// parseTwilioCallback reads a status callback, a form POST signed in X-Twilio-Signature with
+// the auth token over the URL Twilio called and the sorted form fields
+func parseTwilioCallback(cfg Config, r *http.Request) (*StatusUpdate, error) {
+	callback := cfg.callbackURL()
+	if callback == "" || cfg.TwilioAuthToken == "" || cfg.Driver != DriverTwilio {
+		return nil, ErrUnauthorized
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+	// Behind proxies the request URL is not the one Twilio called, so the configured one is
+	// signed over
+	signed := callback
+	if r.URL.RawQuery != "" {
+		signed += "?" + r.URL.RawQuery
+	}
+	keys := make([]string, 0, len(r.PostForm))
+	for key := range r.PostForm {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(signed)
+	for _, key := range keys {
+		for _, value := range r.PostForm[key] {
+			b.WriteString(key)
+			b.WriteString(value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(cfg.TwilioAuthToken))
+	mac.Write([]byte(b.String()))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(r.Header.Get("X-Twilio-Signature")), []byte(want)) {
+		return nil, ErrUnauthorized
+	}
+
+	update := &StatusUpdate{
+		MessageID: r.PostForm.Get("MessageSid"),
+		Status:    normalizeStatus(r.PostForm.Get("MessageStatus")),
+	}
+	if update.MessageID == "" {
+		return nil, fmt.Errorf("callback without MessageSid")
+	}
+	if code := r.PostForm.Get("ErrorCode"); code != "" {
+		update.Error = "twilio error " + code
+	}
+	return update, nil
+}
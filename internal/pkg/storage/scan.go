@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clamChunk is the size of the chunks streamed to clamd, well under its StreamMaxLength
+const clamChunk = 64 << 10
+
+// clamAV scans with clamd's INSTREAM command over TCP
+type clamAV struct {
+	addr    string
+	timeout time.Duration
+}
+
+func (c *clamAV) scan(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// zINSTREAM, then chunks each led by their length, then a zero length to end the stream
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	buf := make([]byte, 4+clamChunk)
+	for {
+		n, rerr := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return fmt.Errorf("clamd: %w", err)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+
+	// The answer is "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s", ErrInfected, signature)
+	default:
+		return fmt.Errorf("clamd answered %q", reply)
+	}
+}
+
+// httpScanner posts the file as the body of a request to a scanning hook, which answers 200
+// for a clean file and 422 for an infected one, with what was found as a plain-text body
+type httpScanner struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPScanner(u string, timeout time.Duration) *httpScanner {
+	return &httpScanner{url: u, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpScanner) scan(ctx context.Context, r io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("scan hook: %w", err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrInfected, strings.TrimSpace(string(detail)))
+	default:
+		return fmt.Errorf("scan hook answered %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+}
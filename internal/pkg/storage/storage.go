@@ -0,0 +1,253 @@
+// Package storage keeps uploaded files on local disk or in an S3-compatible bucket (AWS S3,
+// MinIO, Cloudflare R2 and the like), addressed by keys the caller chooses, and scans them
+// for malware before they are stored: with clamd, or by posting them to an HTTP hook.
+// Default is the process-wide store; Configure swaps the backend, e.g. on a reload.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage drivers
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// Scan drivers; none stores files unscanned
+const (
+	ScanNone   = "none"
+	ScanClamAV = "clamav"
+	ScanHTTP   = "http"
+)
+
+// ErrNotFound is returned for a key with no file
+var ErrNotFound = errors.New("file not found")
+
+// ErrInfected is returned by Scan for a file the scanner rejected; the message names what
+// was found
+var ErrInfected = errors.New("file is infected")
+
+// Config is the storage section of the configuration
+type Config struct {
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER" default:"local"`
+	// Dir holds the files of the local driver
+	Dir string   `yaml:"dir" env:"STORAGE_DIR" default:"storage"`
+	S3  S3Config `yaml:"s3"`
+	// MaxUploadBytes caps one upload; avatars are capped by MaxAvatarBytes
+	MaxUploadBytes int64 `yaml:"max_upload_bytes" env:"STORAGE_MAX_UPLOAD_BYTES" default:"10485760" min:"1"`
+	MaxAvatarBytes int64 `yaml:"max_avatar_bytes" env:"STORAGE_MAX_AVATAR_BYTES" default:"2097152" min:"1"`
+	// URLSecret signs download URLs; it is required and must be the same on every replica
+	URLSecret string `yaml:"url_secret" env:"STORAGE_URL_SECRET"`
+	// URLExpiry is how long a signed download URL works
+	URLExpiry time.Duration `yaml:"url_expiry" env:"STORAGE_URL_EXPIRY" default:"15m"`
+	// PublicURL is where others, such as JasperServer, reach this API; signed URLs are
+	// relative without it
+	PublicURL string     `yaml:"public_url" env:"STORAGE_PUBLIC_URL"`
+	Scan      ScanConfig `yaml:"scan"`
+}
+
+// S3Config locates the bucket of the s3 driver
+type S3Config struct {
+	// Endpoint defaults to AWS's for Region; set it for other providers
+	Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" env:"S3_REGION" default:"us-east-1"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY_ID"`
+	SecretKey string `yaml:"secret_key" env:"S3_SECRET_ACCESS_KEY"`
+	// PathStyle addresses the bucket in the path rather than the host name, as MinIO needs
+	PathStyle bool          `yaml:"path_style" env:"S3_PATH_STYLE" default:"false"`
+	Timeout   time.Duration `yaml:"timeout" env:"S3_TIMEOUT" default:"60s"`
+}
+
+// ScanConfig selects the malware scanner files pass before they are stored
+type ScanConfig struct {
+	Driver string `yaml:"driver" env:"STORAGE_SCAN_DRIVER" default:"none"`
+	// Addr is clamd's TCP address
+	Addr string `yaml:"addr" env:"CLAMAV_ADDR" default:"localhost:3310"`
+	// URL receives the file as the body of a POST; see httpScanner
+	URL     string        `yaml:"url" env:"STORAGE_SCAN_URL"`
+	Timeout time.Duration `yaml:"timeout" env:"STORAGE_SCAN_TIMEOUT" default:"30s"`
+}
+
+// Validate checks the driver settings
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Driver {
+	case DriverLocal:
+		if c.Dir == "" {
+			errs = append(errs, errors.New("STORAGE_DIR is required for the local driver"))
+		}
+	case DriverS3:
+		if c.S3.Bucket == "" || c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			errs = append(errs, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for the s3 driver"))
+		}
+		if c.S3.Endpoint != "" && !isHTTPURL(c.S3.Endpoint) {
+			errs = append(errs, errors.New("S3_ENDPOINT must be an http or https URL"))
+		}
+		if c.S3.Timeout <= 0 {
+			errs = append(errs, errors.New("S3_TIMEOUT must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_DRIVER must be %s or %s", DriverLocal, DriverS3))
+	}
+	switch c.Scan.Driver {
+	case ScanNone:
+	case ScanClamAV:
+		if c.Scan.Addr == "" {
+			errs = append(errs, errors.New("CLAMAV_ADDR is required for the clamav scanner"))
+		}
+	case ScanHTTP:
+		if !isHTTPURL(c.Scan.URL) {
+			errs = append(errs, errors.New("STORAGE_SCAN_URL must be an http or https URL for the http scanner"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_SCAN_DRIVER must be %s, %s or %s", ScanNone, ScanClamAV, ScanHTTP))
+	}
+	if c.Scan.Timeout <= 0 {
+		errs = append(errs, errors.New("STORAGE_SCAN_TIMEOUT must be positive"))
+	}
+	// The signature is all that guards GET /api/files/:id/content, so there is no default
+	switch c.URLSecret {
+	case "":
+		errs = append(errs, errors.New("STORAGE_URL_SECRET is required: it signs file download URLs"))
+	case "your_generated_secret_key_here", "default_file_url_secret_change_in_prod":
+		errs = append(errs, errors.New("STORAGE_URL_SECRET is still the example value"))
+	}
+	if c.URLExpiry < time.Minute {
+		errs = append(errs, errors.New("STORAGE_URL_EXPIRY must be at least 1m"))
+	}
+	if c.PublicURL != "" && !isHTTPURL(c.PublicURL) {
+		errs = append(errs, errors.New("STORAGE_PUBLIC_URL must be an http or https URL"))
+	}
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// backend stores the bytes of files
+type backend interface {
+	put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	open(ctx context.Context, key string) (io.ReadCloser, error)
+	remove(ctx context.Context, key string) error
+}
+
+// scanner checks a file, returning an error wrapping ErrInfected for malware
+type scanner interface {
+	scan(ctx context.Context, r io.Reader) error
+}
+
+// validKey matches the keys callers may use: slash-separated segments of letters, digits,
+// dots, dashes and underscores, never starting with a dot, so they are safe as paths
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+// Store puts, opens and removes files with the configured backend
+type Store struct {
+	mu      sync.RWMutex
+	cfg     Config
+	backend backend
+	scanner scanner
+}
+
+// Default is the process-wide store, storing nothing until Configure
+var Default = &Store{}
+
+// Configure replaces the backend and scanner, e.g. on startup or a configuration reload.
+// Files already stored stay where they are, so changing the driver hides them.
+func (s *Store) Configure(cfg Config) error {
+	var b backend
+	switch cfg.Driver {
+	case DriverS3:
+		b = newS3(cfg.S3)
+	default:
+		local, err := newLocal(cfg.Dir)
+		if err != nil {
+			return err
+		}
+		b = local
+	}
+	var sc scanner
+	switch cfg.Scan.Driver {
+	case ScanClamAV:
+		sc = &clamAV{addr: cfg.Scan.Addr, timeout: cfg.Scan.Timeout}
+	case ScanHTTP:
+		sc = newHTTPScanner(cfg.Scan.URL, cfg.Scan.Timeout)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg, s.backend, s.scanner = cfg, b, sc
+	return nil
+}
+
+// Config returns the configuration in effect
+func (s *Store) Config() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+func (s *Store) current() (backend, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.backend == nil {
+		return nil, errors.New("storage is not configured")
+	}
+	return s.backend, nil
+}
+
+// Put stores size bytes from r under key, replacing any file there
+func (s *Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	b, err := s.current()
+	if err != nil {
+		return err
+	}
+	return b.put(ctx, key, r, size, contentType)
+}
+
+// Open reads the file under key; the caller closes it
+func (s *Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey.MatchString(key) {
+		return nil, ErrNotFound
+	}
+	b, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return b.open(ctx, key)
+}
+
+// Remove deletes the file under key; a missing file is not an error
+func (s *Store) Remove(ctx context.Context, key string) error {
+	if !validKey.MatchString(key) {
+		return nil
+	}
+	b, err := s.current()
+	if err != nil {
+		return err
+	}
+	return b.remove(ctx, key)
+}
+
+// Scan checks r with the configured scanner, reporting whether it was scanned at all
+func (s *Store) Scan(ctx context.Context, r io.Reader) (bool, error) {
+	s.mu.RLock()
+	sc := s.scanner
+	s.mu.RUnlock()
+	if sc == nil {
+		return false, nil
+	}
+	return true, sc.scan(ctx, r)
+}
@@ -0,0 +1,173 @@
+// Package tabular reads uploaded spreadsheets, CSV or the first sheet of an XLSX workbook,
+// into a header and rows of strings for the import endpoints. CSV may be separated by commas
+// or, as Excel saves it in many locales, semicolons. It also writes rows out as CSV or NDJSON
+// for the CSV lists and export jobs.
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ErrUnsupported means the file is neither CSV nor XLSX
+var ErrUnsupported = errors.New("unsupported file format, expected CSV or XLSX")
+
+// ErrEmpty means the file has no header row
+var ErrEmpty = errors.New("the file has no header row")
+
+// TooManyRowsError is returned when a file has more data rows than allowed
+type TooManyRowsError struct {
+	Max int
+}
+
+func (e *TooManyRowsError) Error() string {
+	return fmt.Sprintf("the file has more than %d rows", e.Max)
+}
+
+// Row is a data row. Line is its row number in the file, the header being 1, as a
+// spreadsheet shows it; Cells are trimmed and padded to the header's width.
+type Row struct {
+	Line  int
+	Cells []string
+}
+
+// Table is a file read: its header, lower-cased and trimmed, and its non-blank rows
+type Table struct {
+	Format string
+	Header []string
+	Rows   []Row
+}
+
+// Column returns the index of the first header among names, or -1
+func (t *Table) Column(names ...string) int {
+	for _, name := range names {
+		for i, h := range t.Header {
+			if h == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Read parses data, taking its format from the file name's extension, or from its content
+// when the name has none. maxRows bounds the data rows; 0 leaves them unbounded.
+func Read(name string, data []byte, maxRows int) (*Table, error) {
+	format := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	if format != FormatCSV && format != FormatXLSX {
+		format = sniff(data)
+	}
+
+	var records []record
+	var err error
+	switch format {
+	case FormatCSV:
+		records, err = readCSV(data, maxRows)
+	case FormatXLSX:
+		records, err = readXLSX(data, maxRows)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return table(format, records, maxRows)
+}
+
+// sniff tells XLSX, a zip archive, from text
+func sniff(data []byte) string {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return FormatXLSX
+	}
+	if bytes.IndexByte(data, 0) < 0 {
+		return FormatCSV
+	}
+	return ""
+}
+
+// record is a row as read, with its row number in the file
+type record struct {
+	line  int
+	cells []string
+}
+
+// table takes the first non-blank record as the header and the non-blank records after it
+// as rows
+func table(format string, records []record, maxRows int) (*Table, error) {
+	t := &Table{Format: format}
+	for _, rec := range records {
+		if blank(rec.cells) {
+			continue
+		}
+		if t.Header == nil {
+			t.Header = make([]string, len(rec.cells))
+			for j, cell := range rec.cells {
+				t.Header[j] = strings.ToLower(strings.TrimSpace(cell))
+			}
+			continue
+		}
+		if maxRows > 0 && len(t.Rows) == maxRows {
+			return nil, &TooManyRowsError{Max: maxRows}
+		}
+		cells := make([]string, len(t.Header))
+		for j := range cells {
+			if j < len(rec.cells) {
+				cells[j] = strings.TrimSpace(rec.cells[j])
+			}
+		}
+		t.Rows = append(t.Rows, Row{Line: rec.line, Cells: cells})
+	}
+	if t.Header == nil {
+		return nil, ErrEmpty
+	}
+	return t, nil
+}
+
+func blank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readCSV reads comma or semicolon separated records, whichever the first line has more of
+func readCSV(data []byte, maxRows int) ([]record, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+
+	var records []record
+	for {
+		cells, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		// Blank records make this a loose bound; table checks the exact one
+		if maxRows > 0 && len(records) > maxRows+1 {
+			return nil, &TooManyRowsError{Max: maxRows}
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, record{line: line, cells: cells})
+	}
+}
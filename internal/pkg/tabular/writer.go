@@ -0,0 +1,103 @@
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatNDJSON is one JSON object per line, which Writer writes besides CSV
+const FormatNDJSON = "ndjson"
+
+// Record renders a row as the CSV record of columns. The row is encoded as JSON and each
+// column takes the member of that name: strings and numbers as they are, null or a missing
+// member as an empty cell and objects as their JSON.
+func Record(columns []string, row interface{}) ([]string, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = Cell(members[column])
+	}
+	return record, nil
+}
+
+// Cell renders a JSON value as a cell. Strings a spreadsheet would evaluate as a formula
+// are prefixed with a quote, so opening an export cannot run one.
+func Cell(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	if raw[0] != '"' {
+		return string(raw)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return string(raw)
+	}
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Writer writes rows to a file as CSV, under a header record of its columns, or as NDJSON
+type Writer struct {
+	format  string
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+// NewWriter writes rows in format, FormatCSV or FormatNDJSON, to w
+func NewWriter(w io.Writer, format string, columns []string) (*Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &Writer{format: format, columns: columns, csv: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &Writer{format: format, columns: columns, json: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// Write encodes one row
+func (w *Writer) Write(row interface{}) error {
+	if w.json != nil {
+		return w.json.Encode(row)
+	}
+	if !w.started {
+		w.started = true
+		if err := w.csv.Write(w.columns); err != nil {
+			return err
+		}
+	}
+	record, err := Record(w.columns, row)
+	if err != nil {
+		return err
+	}
+	return w.csv.Write(record)
+}
+
+// Flush writes what is buffered; a CSV file without rows still gets its header record
+func (w *Writer) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	if !w.started {
+		w.started = true
+		w.csv.Write(w.columns)
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
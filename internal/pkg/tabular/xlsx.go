@@ -0,0 +1,220 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartBytes bounds each XML part of a workbook once decompressed, so a small upload
+// cannot inflate into an unbounded read
+const maxPartBytes = 64 << 20
+
+// The parts of a workbook read: the workbook lists the sheets, its relationships name each
+// sheet's part, and cells holding text usually point into the shared strings
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is a string, plain (t) or made of formatted runs (r)
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (x xlsxText) String() string {
+	if len(x.Runs) == 0 {
+		return x.T
+	}
+	var b strings.Builder
+	for _, r := range x.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		Ref    string   `xml:"r,attr"`
+		Type   string   `xml:"t,attr"`
+		Value  string   `xml:"v"`
+		Inline xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// readXLSX reads the first sheet of a workbook
+func readXLSX(data []byte, maxRows int) ([]record, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheet]
+	if !ok {
+		return nil, fmt.Errorf("invalid XLSX: %s is missing", sheet)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX: %w", err)
+	}
+	defer rc.Close()
+
+	// Rows are decoded one at a time, so only the records kept are held in memory
+	dec := xml.NewDecoder(io.LimitReader(rc, maxPartBytes))
+	var records []record
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+		if maxRows > 0 && len(records) > maxRows+1 {
+			return nil, &TooManyRowsError{Max: maxRows}
+		}
+
+		line := row.R
+		if line == 0 {
+			line = len(records) + 1
+		}
+		var cells []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			if col >= 16384 {
+				return nil, fmt.Errorf("invalid XLSX: cell %s is out of range", c.Ref)
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, fmt.Errorf("invalid XLSX: cell %s points to a missing string", c.Ref)
+				}
+				cells[col] = shared.Items[n].String()
+			case "inlineStr":
+				cells[col] = c.Inline.String()
+			default:
+				// Numbers, booleans (0 or 1), formula results and errors as stored
+				cells[col] = c.Value
+			}
+		}
+		records = append(records, record{line: line, cells: cells})
+	}
+}
+
+// firstSheet returns the part holding the workbook's first sheet
+func firstSheet(files map[string]*zip.File) (string, error) {
+	f, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", fmt.Errorf("invalid XLSX: xl/workbook.xml is missing")
+	}
+	var wb xlsxWorkbook
+	if err := decodePart(f, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", ErrEmpty
+	}
+
+	rels, ok := files["xl/_rels/workbook.xml.rels"]
+	if !ok {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+	var r xlsxRelationships
+	if err := decodePart(rels, &r); err != nil {
+		return "", err
+	}
+	for _, rel := range r.Relationships {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("invalid XLSX: the first sheet has no part")
+}
+
+// decodePart unmarshals a whole XML part into v
+func decodePart(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX: %w", err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxPartBytes)).Decode(v); err != nil {
+		return fmt.Errorf("invalid XLSX: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// columnIndex returns the zero-based column of a cell reference: "A1" is 0, "AB12" 27
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, errors.New("invalid XLSX: bad cell reference " + strconv.Quote(ref))
+	}
+	return col - 1, nil
+}
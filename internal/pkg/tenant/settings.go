@@ -0,0 +1,33 @@
+package tenant
+
+import (
+	"context"
+	"time"
+)
+
+// Settings are a tenant's overrides of the global configuration, from the tenant_settings
+// table; the zero value of a field keeps the global setting
+type Settings struct {
+	PrayerMethod       string `json:"prayer_method,omitempty"`
+	JasperOrganization string `json:"jasper_organization,omitempty"`
+	// Branding strings by name (app_name, logo_url, primary_color, support_email)
+	Branding map[string]string `json:"branding,omitempty"`
+	// CacheTTLs by "<class>" or "<entity>:<class>", as CACHE_TTL_* name them
+	CacheTTLs map[string]time.Duration `json:"cache_ttls,omitempty"`
+}
+
+// settingsKey is the context key holding the tenant's Settings
+type settingsKey struct{}
+
+// WithSettings returns ctx with the settings of its tenant
+func WithSettings(ctx context.Context, s *Settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, s)
+}
+
+// SettingsFrom returns the settings of the tenant of ctx, empty when it overrides nothing
+func SettingsFrom(ctx context.Context) *Settings {
+	if s, ok := ctx.Value(settingsKey{}).(*Settings); ok && s != nil {
+		return s
+	}
+	return &Settings{}
+}
@@ -0,0 +1,70 @@
+// Package tenant carries the tenant a request is served for. Users, roles and menu items
+// belong to a tenant, and repositories scope their queries to ID(ctx), so one deployment
+// serves several organizations, each with its own RBAC. Without tenancy, or for a request
+// naming no tenant, everything is the default tenant's.
+package tenant
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+)
+
+// The default tenant, created by the tenants migration, owns every row from before it
+const (
+	DefaultID   uint = 1
+	DefaultSlug      = "default"
+)
+
+// idKey is the context key holding the tenant ID
+type idKey struct{}
+
+// WithID returns ctx serving the tenant id
+func WithID(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// ID returns the tenant ctx is served for, the default tenant when it names none
+func ID(ctx context.Context) uint {
+	if id, ok := ctx.Value(idKey{}).(uint); ok && id != 0 {
+		return id
+	}
+	return DefaultID
+}
+
+// IsDefault reports whether ctx is served for the default tenant
+func IsDefault(ctx context.Context) bool {
+	return ID(ctx) == DefaultID
+}
+
+// enabled is set when TENANCY_ENABLED is
+var enabled atomic.Bool
+
+// SetEnabled records whether requests may name a tenant other than the default one
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether requests may name a tenant other than the default one
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// keySuffix separates a cache key from the tenant it was scoped to
+const keySuffix = ":tenant:"
+
+// CacheKey scopes a cache key to the tenant of ctx. The default tenant's keys are left as
+// they are, so single-tenant deployments and the cache warmers keep theirs.
+func CacheKey(ctx context.Context, key string) string {
+	id := ID(ctx)
+	if id == DefaultID {
+		return key
+	}
+	return key + keySuffix + strconv.FormatUint(uint64(id), 10)
+}
+
+// KeyPattern matches the copies of a cache key CacheKey scoped to tenants other than the
+// default one
+func KeyPattern(key string) string {
+	return key + keySuffix + "*"
+}
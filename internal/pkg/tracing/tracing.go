@@ -0,0 +1,202 @@
+// Package tracing records a lightweight per-request trace: the request ID plus a span
+// for every database call made while serving it. Traces that contain slow queries or an
+// unusual number of queries are kept in a small in-memory buffer for inspection.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// HeaderRequestID carries the request ID in and out of the service
+const HeaderRequestID = "X-Request-ID"
+
+// MaxSpans caps the spans kept per trace so a runaway loop cannot exhaust memory
+const MaxSpans = 500
+
+// Span is one timed operation inside a request
+type Span struct {
+	Name     string            `json:"name"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration_ns"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Slow     bool              `json:"slow,omitempty"`
+}
+
+// Trace collects the spans of a single request; safe for concurrent use
+type Trace struct {
+	ID    string
+	Name  string
+	Start time.Time
+
+	mu       sync.Mutex
+	spans    []Span
+	dropped  int
+	slow     int
+	duration time.Duration
+	status   int
+}
+
+// MaxIDLength caps an incoming request ID; longer ones are replaced
+const MaxIDLength = 128
+
+// NewTrace starts a trace with the given request ID, generating one when it is empty or
+// not a valid ID (see ValidID)
+func NewTrace(id string) *Trace {
+	if !ValidID(id) {
+		id = NewID()
+	}
+	return &Trace{ID: id, Start: time.Now()}
+}
+
+// NewID returns a random 16-byte hex request ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidID reports whether id is safe to echo in headers, logs and error bodies: 1 to
+// MaxIDLength letters, digits, '-', '_', '.' or ':'
+func ValidID(id string) bool {
+	if id == "" || len(id) > MaxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch b := id[i]; {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '-', b == '_', b == '.', b == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// AddSpan appends a span; spans beyond MaxSpans are counted but not stored
+func (t *Trace) AddSpan(span Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if span.Slow {
+		t.slow++
+	}
+	if len(t.spans) >= MaxSpans {
+		t.dropped++
+		return
+	}
+	t.spans = append(t.spans, span)
+}
+
+// Finish records the request outcome once the handler chain has run
+func (t *Trace) Finish(name string, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Name = name
+	t.status = status
+	t.duration = time.Since(t.Start)
+}
+
+// Summary is a point-in-time copy of a trace, suitable for JSON
+type Summary struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration_ns"`
+	Status    int           `json:"status"`
+	SpanCount int           `json:"span_count"`
+	SlowSpans int           `json:"slow_spans"`
+	SpanTime  time.Duration `json:"span_time_ns"`
+	Dropped   int           `json:"dropped_spans,omitempty"`
+	Spans     []Span        `json:"spans,omitempty"`
+}
+
+// Summary copies the trace and totals its spans
+func (t *Trace) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := Summary{
+		ID:        t.ID,
+		Name:      t.Name,
+		Start:     t.Start,
+		Duration:  t.duration,
+		Status:    t.status,
+		SpanCount: len(t.spans) + t.dropped,
+		SlowSpans: t.slow,
+		Dropped:   t.dropped,
+		Spans:     append([]Span(nil), t.spans...),
+	}
+	for _, span := range t.spans {
+		s.SpanTime += span.Duration
+	}
+	return s
+}
+
+type traceKey struct{}
+
+// WithTrace returns ctx carrying t
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// FromContext returns the trace carried by ctx, or nil
+func FromContext(ctx context.Context) *Trace {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return ""
+}
+
+// DefaultRecentSize is how many flagged traces Recent keeps
+const DefaultRecentSize = 100
+
+var recent = &ring{size: DefaultRecentSize}
+
+// ring is a fixed-size buffer of the most recent flagged traces
+type ring struct {
+	mu    sync.Mutex
+	size  int
+	next  int
+	items []Summary
+}
+
+// Record keeps a summary of t among the recent flagged traces
+func Record(t *Trace) {
+	summary := t.Summary()
+
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	if len(recent.items) < recent.size {
+		recent.items = append(recent.items, summary)
+		return
+	}
+	recent.items[recent.next] = summary
+	recent.next = (recent.next + 1) % recent.size
+}
+
+// Recent returns the recorded traces, newest first
+func Recent() []Summary {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+
+	out := make([]Summary, 0, len(recent.items))
+	for i := len(recent.items) - 1; i >= 0; i-- {
+		out = append(out, recent.items[(recent.next+i)%len(recent.items)])
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Cursor marks a position in a list ordered by created_at DESC, id DESC.
+// Keyset pagination with a cursor stays fast at any depth, unlike OFFSET.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint64    `json:"id"`
+}
+
+// EncodeCursor returns an opaque URL-safe token for c
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token from EncodeCursor. An empty token means the first page and returns nil.
+func DecodeCursor(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, NewValidationError("Invalid cursor", err)
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, NewValidationError("Invalid cursor", err)
+	}
+	return &c, nil
+}
+
+// NextCursor returns the token for the page after the item with createdAt and id
+func NextCursor(createdAt *time.Time, id uint64) string {
+	if createdAt == nil {
+		return ""
+	}
+	return EncodeCursor(Cursor{CreatedAt: *createdAt, ID: id})
+}
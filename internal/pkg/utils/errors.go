@@ -1,10 +1,15 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
+	"adminbe/internal/pkg/errortracking"
+	"adminbe/internal/pkg/logging"
+	"adminbe/internal/pkg/response"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,11 +17,16 @@ import (
 type ErrorType string
 
 const (
-	ErrorTypeValidation ErrorType = "validation"
-	ErrorTypeNotFound   ErrorType = "not_found"
-	ErrorTypeForbidden  ErrorType = "forbidden"
-	ErrorTypeInternal   ErrorType = "internal"
-	ErrorTypeExternal   ErrorType = "external"
+	ErrorTypeValidation  ErrorType = "validation"
+	ErrorTypeNotFound    ErrorType = "not_found"
+	ErrorTypeForbidden   ErrorType = "forbidden"
+	ErrorTypeInternal    ErrorType = "internal"
+	ErrorTypeExternal    ErrorType = "external"
+	ErrorTypeConflict    ErrorType = "conflict"
+	ErrorTypeTransient   ErrorType = "transient"
+	ErrorTypeTimeout     ErrorType = "timeout"
+	ErrorTypeOverloaded  ErrorType = "overloaded"
+	ErrorTypeRateLimited ErrorType = "rate_limited"
 )
 
 // AppError wraps application errors with context
@@ -28,6 +38,11 @@ type AppError struct {
 	Internal error     `json:"-"`                 // The underlying error
 }
 
+// Unwrap exposes the underlying error to errors.Is / errors.As
+func (e *AppError) Unwrap() error {
+	return e.Internal
+}
+
 func (e *AppError) Error() string {
 	if e.Internal != nil {
 		return fmt.Sprintf("[%s] %s: %v", e.Type, e.Message, e.Internal)
@@ -69,6 +84,47 @@ func NewForbiddenError(message string) *AppError {
 	}
 }
 
+// NewConflictError creates a conflict error (duplicate or still-referenced resource)
+func NewConflictError(message string, err error) *AppError {
+	return &AppError{
+		Type:     ErrorTypeConflict,
+		Message:  message,
+		Code:     http.StatusConflict,
+		Internal: err,
+	}
+}
+
+// NewTransientError creates an error for failures that succeed on retry (deadlocks, lock timeouts)
+func NewTransientError(message string, err error) *AppError {
+	return &AppError{
+		Type:     ErrorTypeTransient,
+		Message:  message,
+		Code:     http.StatusServiceUnavailable,
+		Internal: err,
+	}
+}
+
+// NewTimeoutError creates an error for work abandoned because the request's time budget ran out
+func NewTimeoutError(operation string, err error) *AppError {
+	return &AppError{
+		Type:     ErrorTypeTimeout,
+		Message:  fmt.Sprintf("Timed out trying to %s", operation),
+		Code:     http.StatusGatewayTimeout,
+		Internal: err,
+	}
+}
+
+// NewRateLimitedError creates an error for work refused because a rate limit, ours or a
+// provider's, was reached
+func NewRateLimitedError(message string, err error) *AppError {
+	return &AppError{
+		Type:     ErrorTypeRateLimited,
+		Message:  message,
+		Code:     http.StatusTooManyRequests,
+		Internal: err,
+	}
+}
+
 // NewInternalError creates an internal error
 func NewInternalError(operation string, err error) *AppError {
 	return &AppError{
@@ -93,7 +149,8 @@ func NewExternalError(service string, err error) *AppError {
 
 // IsNotFound checks if the error is a not found error
 func IsNotFound(err error) bool {
-	if appErr, ok := err.(*AppError); ok {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
 		return appErr.Type == ErrorTypeNotFound
 	}
 	return false
@@ -108,32 +165,73 @@ func HandleError(c *gin.Context, err error, operation string) bool {
 
 	var appErr *AppError
 
-	// Check if it's already an AppError
-	if ae, ok := err.(*AppError); ok {
-		appErr = ae
-	} else {
-		// Wrap unknown errors as internal errors
-		appErr = NewInternalError(operation, err)
+	// Check if it's already an AppError, possibly wrapped by a service
+	if !errors.As(err, &appErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			appErr = NewTimeoutError(operation, err)
+		} else {
+			// Wrap unknown errors as internal errors
+			appErr = NewInternalError(operation, err)
+		}
 	}
 
 	// Log the full error details for debugging (includes internal info)
+	logger := logging.FromContext(c.Request.Context())
 	if appErr.Internal != nil {
-		log.Printf("[ERROR] %s failed: %v (details: %s)", operation, appErr.Internal, appErr.Details)
+		logger.Error("Request failed", "operation", operation, "type", string(appErr.Type),
+			"error", appErr.Internal, "details", appErr.Details)
 	} else {
-		log.Printf("[ERROR] %s: %s", operation, appErr.Message)
+		logger.Warn("Request rejected", "operation", operation, "type", string(appErr.Type),
+			"message", appErr.Message)
 	}
 
-	// Create response without exposing internal details
-	response := gin.H{
-		"error": appErr.Message,
-		"type":  string(appErr.Type),
+	// Server-side failures go to error tracking as well; 4xx are the client's problem
+	if appErr.Code >= http.StatusInternalServerError {
+		errortracking.CaptureError(c, err, appErr.Code, string(appErr.Type))
 	}
 
-	// Add error code if it's validation error
+	// Create response without exposing internal details
+	failure := response.Failure{
+		Code:    appErr.ErrorCode(),
+		Message: appErr.Message,
+		Legacy:  gin.H{"type": string(appErr.Type)},
+	}
+	// Version 1 bodies only carried a code for validation errors
 	if appErr.Type == ErrorTypeValidation {
-		response["code"] = "VALIDATION_ERROR"
+		failure.Legacy["code"] = response.CodeValidation
 	}
 
-	c.JSON(appErr.Code, response)
+	response.WriteError(c, appErr.Code, failure)
 	return true
 }
+
+// ErrorCode returns the machine-readable code clients see for e
+func (e *AppError) ErrorCode() string {
+	switch e.Type {
+	case ErrorTypeValidation:
+		return response.CodeValidation
+	case ErrorTypeNotFound:
+		return response.CodeNotFound
+	case ErrorTypeForbidden:
+		return response.CodeForbidden
+	case ErrorTypeConflict:
+		return response.CodeConflict
+	case ErrorTypeExternal:
+		return response.CodeExternal
+	case ErrorTypeTransient:
+		return response.CodeTransient
+	case ErrorTypeTimeout:
+		return response.CodeTimeout
+	case ErrorTypeOverloaded:
+		return response.CodeOverloaded
+	case ErrorTypeRateLimited:
+		return response.CodeRateLimited
+	}
+	return response.CodeForStatus(e.Code)
+}
+
+// RespondError writes an error body with the code for status and the request ID, so a
+// client report can be matched to the server's log lines
+func RespondError(c *gin.Context, status int, message string) {
+	response.WriteError(c, status, response.Failure{Code: response.CodeForStatus(status), Message: message})
+}
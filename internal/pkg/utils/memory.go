@@ -1,30 +1,6 @@
 package utils
 
-import (
-	"strings"
-	"sync"
-
-	"github.com/gin-gonic/gin"
-)
-
-// responseObjectPool reuses gin.H objects to reduce allocations
-var responseObjectPool = sync.Pool{
-	New: func() interface{} {
-		return make(gin.H)
-	},
-}
-
-// GetResponseObject returns a reusable gin.H object from the pool
-func GetResponseObject() gin.H {
-	return responseObjectPool.Get().(gin.H)
-}
-
-// PutResponseObject returns a gin.H object to the pool for reuse
-func PutResponseObject(obj gin.H) {
-	// Clear the map before putting it back
-	clear(obj) // Go 1.21+
-	responseObjectPool.Put(obj)
-}
+import "strings"
 
 // ResetSliceToZeroLen resets a slice to zero length without deallocating capacity
 // This helps reuse the underlying array when growing again
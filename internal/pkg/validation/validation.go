@@ -0,0 +1,249 @@
+// Package validation registers the custom binding tags request types use and turns binding
+// errors into per-field messages with stable codes, so clients can show each message next to
+// its input instead of parsing validator output.
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"adminbe/internal/pkg/i18n"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom binding tags
+const (
+	// TagUsername allows letters, digits, '.', '_' and '-', starting with a letter or digit
+	TagUsername = "username"
+	// TagPhoneID allows Indonesian mobile numbers: 08…, 628… or +628…
+	TagPhoneID = "phone_id"
+)
+
+// Field error codes. Clients branch on these rather than on messages, which may be reworded.
+const (
+	CodeRequired        = "REQUIRED"
+	CodeTooShort        = "TOO_SHORT"
+	CodeTooLong         = "TOO_LONG"
+	CodeTooFew          = "TOO_FEW"
+	CodeTooMany         = "TOO_MANY"
+	CodeTooSmall        = "TOO_SMALL"
+	CodeTooLarge        = "TOO_LARGE"
+	CodeInvalidEmail    = "INVALID_EMAIL"
+	CodeInvalidUsername = "INVALID_USERNAME"
+	CodeInvalidPhone    = "INVALID_PHONE"
+	CodeInvalidType     = "INVALID_TYPE"
+	CodeNotAllowed      = "NOT_ALLOWED"
+	CodeInvalid         = "INVALID"
+	// CodeDuplicate and CodeNotFound are not binding failures; imports report them for
+	// values taken already and references to nothing
+	CodeDuplicate = "DUPLICATE"
+	CodeNotFound  = "NOT_FOUND"
+)
+
+// Patterns of the custom tags, also published in the API specification
+const (
+	UsernamePattern = `^[A-Za-z0-9][A-Za-z0-9._-]*$`
+	// A mobile prefix 08 (or 628 / +628) followed by 7 to 11 digits, as Indonesian operators
+	// issue them
+	PhoneIDPattern = `^(\+62|62|0)8[1-9][0-9]{6,10}$`
+)
+
+var (
+	usernamePattern = regexp.MustCompile(UsernamePattern)
+	phonePattern    = regexp.MustCompile(PhoneIDPattern)
+)
+
+// FieldError describes why one field was rejected
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Register adds the custom tags to gin's validator and makes it name fields by their json
+// (or form) name, as clients know them. Call it once before serving requests.
+func Register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unexpected binding validator %T", binding.Validator.Engine())
+	}
+	v.RegisterTagNameFunc(fieldName)
+	if err := v.RegisterValidation(TagUsername, func(fl validator.FieldLevel) bool {
+		return IsUsername(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return v.RegisterValidation(TagPhoneID, func(fl validator.FieldLevel) bool {
+		return IsPhoneID(fl.Field().String())
+	})
+}
+
+// IsUsername reports whether s only uses the characters allowed in a username
+func IsUsername(s string) bool {
+	return usernamePattern.MatchString(s)
+}
+
+// IsPhoneID reports whether s is an Indonesian mobile number
+func IsPhoneID(s string) bool {
+	return phonePattern.MatchString(s)
+}
+
+// Fields checks v against its binding tags, as a request body of that type is checked, and
+// returns the rejected fields; nil when it passes
+func Fields(v any) map[string]FieldError {
+	err := binding.Validator.ValidateStruct(v)
+	if err == nil {
+		return nil
+	}
+	if fields, ok := Translate(err); ok {
+		return fields
+	}
+	return map[string]FieldError{"": {Code: CodeInvalid, Message: err.Error()}}
+}
+
+// Translate returns the rejected fields of a binding error by their path in the request
+// (e.g. "email" or "requests[0].method"), reporting false for errors that are not about
+// particular fields, such as malformed JSON
+func Translate(err error) (map[string]FieldError, bool) {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make(map[string]FieldError, len(verrs))
+		for _, fe := range verrs {
+			if _, seen := fields[fieldPath(fe)]; !seen {
+				fields[fieldPath(fe)] = translateField(fe)
+			}
+		}
+		return fields, true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return map[string]FieldError{jsonPath(typeErr.Field): {
+			Code:    CodeInvalidType,
+			Message: "must be " + kindName(typeErr.Type),
+		}}, true
+	}
+	return nil, false
+}
+
+// Localize rewrites the messages of fields in the language of ctx; codes stay as they are
+func Localize(ctx context.Context, fields map[string]FieldError) map[string]FieldError {
+	for path, fe := range fields {
+		fe.Message = i18n.T(ctx, fe.Message)
+		fields[path] = fe
+	}
+	return fields
+}
+
+// translateField maps a validator failure to its code and message
+func translateField(fe validator.FieldError) FieldError {
+	kind := fe.Kind()
+	switch fe.Tag() {
+	case "required":
+		return FieldError{CodeRequired, "is required"}
+	case "min", "gte":
+		switch kind {
+		case reflect.String:
+			return FieldError{CodeTooShort, "must be at least " + count(fe.Param(), "character")}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return FieldError{CodeTooFew, "must have at least " + count(fe.Param(), "item")}
+		}
+		return FieldError{CodeTooSmall, "must be at least " + fe.Param()}
+	case "max", "lte":
+		switch kind {
+		case reflect.String:
+			return FieldError{CodeTooLong, "must be at most " + count(fe.Param(), "character")}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return FieldError{CodeTooMany, "must have at most " + count(fe.Param(), "item")}
+		}
+		return FieldError{CodeTooLarge, "must be at most " + fe.Param()}
+	case "email":
+		return FieldError{CodeInvalidEmail, "must be a valid email address"}
+	case "oneof":
+		return FieldError{CodeNotAllowed, "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")}
+	case TagUsername:
+		return FieldError{CodeInvalidUsername, "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"}
+	case TagPhoneID:
+		return FieldError{CodeInvalidPhone, "must be an Indonesian mobile number, e.g. 081234567890"}
+	}
+	return FieldError{CodeInvalid, "is invalid"}
+}
+
+// fieldPath drops the request type from a field's namespace: "CreateUserRequest.email"
+// becomes "email"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return ns
+}
+
+// jsonPath writes the array indexes of an encoding/json field path the way validator
+// namespaces do: "role_ids.0" becomes "role_ids[0]"
+func jsonPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// count writes n units, e.g. "1 item" or "3 items"
+func count(n, unit string) string {
+	if n == "1" {
+		return n + " " + unit
+	}
+	return n + " " + unit + "s"
+}
+
+// fieldName names a struct field by its json tag, or its form tag for query and form
+// bindings, falling back to the Go name
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// kindName describes the JSON type a Go type expects
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
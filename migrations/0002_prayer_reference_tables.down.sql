@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `hisab_tgl_puasa`;
+DROP TABLE IF EXISTS `data_lintang_kota_cms_new`;
+DROP TABLE IF EXISTS `app_city`;
+DROP TABLE IF EXISTS `app_province`;
@@ -0,0 +1,54 @@
+-- Reference tables used by the prayer schedule API. Data is loaded separately.
+
+-- ----------------------------
+-- Table structure for app_province
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `app_province`  (
+  `province_id` int NOT NULL AUTO_INCREMENT,
+  `province_title` varchar(100) CHARACTER SET latin1 COLLATE latin1_swedish_ci NOT NULL,
+  `province_id_new` int NOT NULL,
+  PRIMARY KEY (`province_id`) USING BTREE
+) ENGINE = MyISAM CHARACTER SET = latin1 COLLATE = latin1_swedish_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for app_city
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `app_city`  (
+  `city_id` int NOT NULL AUTO_INCREMENT,
+  `city_title` varchar(40) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `city_province` int NOT NULL,
+  `city_id_new` int NOT NULL,
+  PRIMARY KEY (`city_id`) USING BTREE
+) ENGINE = MyISAM CHARACTER SET = latin1 COLLATE = latin1_swedish_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for data_lintang_kota_cms_new
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `data_lintang_kota_cms_new`  (
+  `id_kota` int NOT NULL AUTO_INCREMENT,
+  `nama_propinsi` varchar(255) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `nama_kota` varchar(255) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `bujur_tempat` varchar(50) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `lintang_tempat` varchar(50) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `time_zone` varchar(3) CHARACTER SET latin1 COLLATE latin1_swedish_ci NULL DEFAULT NULL,
+  `h` int NULL DEFAULT NULL,
+  `time_create` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id_kota`) USING BTREE
+) ENGINE = MyISAM CHARACTER SET = latin1 COLLATE = latin1_swedish_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for hisab_tgl_puasa
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `hisab_tgl_puasa`  (
+  `tgl_id` int NOT NULL AUTO_INCREMENT,
+  `tgl_tahun` int NULL DEFAULT NULL,
+  `tgl_start` date NULL DEFAULT NULL,
+  `tgl_end` date NULL DEFAULT NULL,
+  `tgl_status` int NULL DEFAULT 0,
+  `tgl_hijriah` int NULL DEFAULT NULL,
+  `time_add` datetime NULL DEFAULT NULL,
+  `time_update` datetime NULL DEFAULT NULL,
+  `user_add` int NULL DEFAULT NULL,
+  `user_update` int NULL DEFAULT NULL,
+  PRIMARY KEY (`tgl_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = latin1 COLLATE = latin1_swedish_ci ROW_FORMAT = Dynamic;
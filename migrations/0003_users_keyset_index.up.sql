@@ -0,0 +1,3 @@
+-- Supports keyset pagination of active users: WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC.
+-- audit_logs needs no new index: its created_at index already carries the primary key.
+ALTER TABLE `users` ADD INDEX `idx_users_keyset` (`deleted_at`, `created_at`, `id`);
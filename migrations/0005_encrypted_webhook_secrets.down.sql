@@ -0,0 +1,2 @@
+-- Fails while secrets longer than 255 characters, such as encrypted ones, remain.
+ALTER TABLE `webhooks` MODIFY `secret` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL;
@@ -0,0 +1,3 @@
+-- Room for webhook secrets encrypted by internal/pkg/fieldcrypt: a 255-character secret takes
+-- about 420 once sealed and base64-encoded.
+ALTER TABLE `webhooks` MODIFY `secret` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL;
@@ -0,0 +1,19 @@
+-- Security events raised by internal/pkg/secevents, kept until an administrator
+-- acknowledges them (and after, as a record).
+
+CREATE TABLE IF NOT EXISTS `security_events`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `type` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `severity` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `message` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `ip_address` varchar(45) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `user_id` bigint UNSIGNED NULL DEFAULT NULL,
+  `details` json NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `acknowledged_at` timestamp NULL DEFAULT NULL,
+  `acknowledged_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `acknowledgement_note` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `acknowledged_at`(`acknowledged_at` ASC, `id` ASC) USING BTREE,
+  INDEX `type`(`type` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
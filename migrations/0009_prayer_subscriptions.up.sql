@@ -0,0 +1,21 @@
+-- Chats subscribed to prayer times through a messaging bot: the daily schedule, or the imsak
+-- reminder of the fasting period, for one city. last_sent_at keeps a job run from sending a
+-- chat the same day twice.
+
+CREATE TABLE IF NOT EXISTS `prayer_subscriptions`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `channel` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `chat_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `kind` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `province_id` int NOT NULL,
+  `city_id` int NOT NULL,
+  `active` tinyint(1) NOT NULL DEFAULT 1,
+  `last_sent_at` timestamp NULL DEFAULT NULL,
+  `last_error` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `created_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `channel_chat_kind_city`(`channel` ASC, `chat_id` ASC, `kind` ASC, `city_id` ASC) USING BTREE,
+  INDEX `kind_active`(`kind` ASC, `active` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
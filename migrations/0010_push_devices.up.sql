@@ -0,0 +1,20 @@
+-- Mobile app installations registered for push notifications through FCM, with the city whose
+-- prayer reminders and announcements they get. last_reminder_at is the prayer time the device
+-- was last reminded of, so a reminder is never sent twice.
+
+CREATE TABLE IF NOT EXISTS `push_devices`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `token` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+  `platform` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `province_id` int NOT NULL,
+  `city_id` int NOT NULL,
+  `reminders` tinyint(1) NOT NULL DEFAULT 1,
+  `announcements` tinyint(1) NOT NULL DEFAULT 1,
+  `user_id` bigint UNSIGNED NULL DEFAULT NULL,
+  `last_reminder_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `token`(`token` ASC) USING BTREE,
+  INDEX `city_id`(`city_id` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
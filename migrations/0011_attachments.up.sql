@@ -0,0 +1,20 @@
+-- Uploaded files: user avatars and files passed to reports as parameters. The bytes live in
+-- the configured storage (local disk or an S3-compatible bucket) under storage_key; this is
+-- their metadata. owner_id is the user the file belongs to, created_by who uploaded it.
+
+CREATE TABLE IF NOT EXISTS `attachments`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `purpose` varchar(30) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `owner_id` bigint UNSIGNED NOT NULL,
+  `filename` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `content_type` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `size` bigint NOT NULL,
+  `sha256` char(64) CHARACTER SET ascii COLLATE ascii_general_ci NOT NULL,
+  `storage_key` varchar(255) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+  `scan_status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `created_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `storage_key`(`storage_key` ASC) USING BTREE,
+  INDEX `owner_id_purpose`(`owner_id` ASC, `purpose` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
@@ -0,0 +1,19 @@
+-- Announcements shown as banners in the admin panel, e.g. maintenance notices: each is shown
+-- from starts_at until ends_at (open-ended when NULL) to the users holding one of
+-- target_roles, comma-separated role names, or to everyone when it is empty.
+
+CREATE TABLE IF NOT EXISTS `announcements`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `message` varchar(1000) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `severity` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `starts_at` timestamp NOT NULL,
+  `ends_at` timestamp NULL DEFAULT NULL,
+  `target_roles` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `created_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `updated_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `starts_at`(`starts_at` ASC) USING BTREE,
+  INDEX `ends_at`(`ends_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
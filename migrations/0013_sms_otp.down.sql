@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `sms_messages`;
+DROP TABLE IF EXISTS `otp_codes`;
+DROP TABLE IF EXISTS `user_phones`;
@@ -0,0 +1,52 @@
+-- Report schedules: reports the report_schedules scheduler job runs for their owner on a
+-- cron schedule, keeping each rendered file in attachments. The calendar_sync job publishes
+-- their upcoming runs to a shared CalDAV or Google calendar: report_schedule_events records
+-- the event it made for each run, so runs no longer planned can be taken off the calendar,
+-- even those of deleted schedules. calendar_credentials holds the OAuth tokens of the Google
+-- account the events are written with, encrypted with FIELD_ENCRYPTION_KEYS.
+
+CREATE TABLE IF NOT EXISTS `report_schedules`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `owner_id` bigint UNSIGNED NOT NULL,
+  `name` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `report_path` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `output_format` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `parameters` json NULL,
+  `schedule` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `enabled` tinyint(1) NOT NULL DEFAULT 1,
+  `next_run_at` timestamp NULL DEFAULT NULL,
+  `last_run_at` timestamp NULL DEFAULT NULL,
+  `last_status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `last_error` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `last_attachment_id` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `due`(`enabled` ASC, `next_run_at` ASC) USING BTREE,
+  INDEX `owner`(`owner_id` ASC, `id` ASC) USING BTREE,
+  CONSTRAINT `report_schedules_owner_fk` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+CREATE TABLE IF NOT EXISTS `report_schedule_events`  (
+  `schedule_id` bigint UNSIGNED NOT NULL,
+  `run_at` timestamp NOT NULL,
+  `provider` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `event_id` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `digest` char(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `synced_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`provider`, `schedule_id`, `run_at`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+CREATE TABLE IF NOT EXISTS `calendar_credentials`  (
+  `id` int UNSIGNED NOT NULL AUTO_INCREMENT,
+  `provider` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `access_token` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `refresh_token` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `scope` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `connected_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `provider`(`provider` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
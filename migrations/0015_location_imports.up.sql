@@ -0,0 +1,31 @@
+-- Journal of the reference data imports into app_province, app_city and
+-- data_lintang_kota_cms_new. Each import lists the regions it created or changed with
+-- their values before and after, so the latest one can be rolled back. status is applying
+-- while it runs, then applied, failed (undone after an error) or rolled_back.
+
+CREATE TABLE IF NOT EXISTS `location_imports`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `filename` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `source` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `created` int NOT NULL DEFAULT 0,
+  `updated` int NOT NULL DEFAULT 0,
+  `created_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `rolled_back_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `rolled_back_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `status`(`status` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+CREATE TABLE IF NOT EXISTS `location_import_changes`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `import_id` bigint UNSIGNED NOT NULL,
+  `kind` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `record_id` int NOT NULL,
+  `action` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `old_values` json NULL,
+  `new_values` json NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `import_id`(`import_id` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
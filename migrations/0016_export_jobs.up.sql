@@ -0,0 +1,23 @@
+-- Export jobs: long exports run in the background by the export_jobs scheduler job. A job
+-- is queued, then running (heartbeat_at advancing while an instance works on it), then
+-- succeeded with its file in attachments, failed or expired once its file was removed.
+
+CREATE TABLE IF NOT EXISTS `export_jobs`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `kind` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `format` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `params` json NULL,
+  `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `processed` bigint NOT NULL DEFAULT 0,
+  `total` bigint NULL DEFAULT NULL,
+  `attachment_id` bigint UNSIGNED NULL DEFAULT NULL,
+  `error` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `created_by` bigint UNSIGNED NOT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `started_at` timestamp NULL DEFAULT NULL,
+  `heartbeat_at` timestamp NULL DEFAULT NULL,
+  `finished_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `status`(`status` ASC, `id` ASC) USING BTREE,
+  INDEX `created_by`(`created_by` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
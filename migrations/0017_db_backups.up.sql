@@ -0,0 +1,22 @@
+-- Database backups: logical dumps made by the db_backup scheduler job and kept in the
+-- configured storage. A backup is queued, then running, then succeeded with its file under
+-- storage_key, failed, or expired once its file was removed after DB_BACKUP_RETENTION.
+-- created_by is NULL for the backups the schedule makes.
+
+CREATE TABLE IF NOT EXISTS `db_backups`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `storage_key` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `size` bigint NULL DEFAULT NULL,
+  `checksum` char(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `table_count` int NOT NULL DEFAULT 0,
+  `row_count` bigint NOT NULL DEFAULT 0,
+  `schema_version` bigint NULL DEFAULT NULL,
+  `error` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+  `created_by` bigint UNSIGNED NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `started_at` timestamp NULL DEFAULT NULL,
+  `finished_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `status`(`status` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
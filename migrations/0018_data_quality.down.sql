@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `data_quality_findings`;
+DROP TABLE IF EXISTS `data_quality_checks`;
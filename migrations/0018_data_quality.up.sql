@@ -0,0 +1,25 @@
+-- Data quality: what the data_quality scheduler job found. data_quality_checks holds each
+-- check's last run; data_quality_findings the records it flagged, one row per check and
+-- record_key, kept while the check keeps finding the record and deleted once it does not.
+
+CREATE TABLE IF NOT EXISTS `data_quality_checks`  (
+  `name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `severity` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `finding_count` int NOT NULL DEFAULT 0,
+  `truncated` tinyint(1) NOT NULL DEFAULT 0,
+  `checked_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`name`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+CREATE TABLE IF NOT EXISTS `data_quality_findings`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `check_name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `severity` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `record_key` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `detail` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `first_seen_at` timestamp NULL DEFAULT NULL,
+  `last_seen_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `check_record`(`check_name` ASC, `record_key` ASC) USING BTREE,
+  INDEX `severity`(`severity` ASC, `id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
@@ -0,0 +1,8 @@
+-- Forced password changes: users flagged by POST /api/users/force-password-reset set a new
+-- password at their next sign-in, which clears the flag. last_login_at is when a user last
+-- signed in, which the force-password-reset filter selects on; NULL for users who have not
+-- since this migration.
+
+ALTER TABLE `users`
+  ADD COLUMN `password_reset_required` tinyint(1) NOT NULL DEFAULT 0 AFTER `status`,
+  ADD COLUMN `last_login_at` timestamp NULL DEFAULT NULL AFTER `password_reset_required`;
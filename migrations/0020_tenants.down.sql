@@ -0,0 +1,34 @@
+-- Restoring the global unique indexes fails while two tenants share a username, email or
+-- role name; rename or delete one of them first.
+
+CREATE OR REPLACE ALGORITHM = UNDEFINED SQL SECURITY DEFINER VIEW `menu_navigation` AS with recursive `menu_tree` as (select `m`.`id` AS `parent_id`,`c`.`id` AS `child_id` from (`menu` `m` left join `menu` `c` on((`c`.`parent_id` = `m`.`id`))) where ((`m`.`deleted_at` is null) and (`c`.`deleted_at` is null)) union all select `mt`.`parent_id` AS `parent_id`,`c`.`id` AS `child_id` from (`menu` `c` join `menu_tree` `mt` on((`c`.`parent_id` = `mt`.`child_id`))) where (`c`.`deleted_at` is null)) select `m`.`id` AS `id`,`m`.`label` AS `label`,(case when ((`m`.`url` is not null) and (`m`.`url` <> '')) then `m`.`url` else 'javascript:void(0);' end) AS `url`,`m`.`icon` AS `icon`,coalesce(json_arrayagg(json_object('label',`c`.`label`,'parent_id',`c`.`parent_id`,'url',`c`.`url`)),json_array()) AS `children` from ((`menu` `m` left join `menu_tree` `mt` on((`m`.`id` = `mt`.`parent_id`))) left join `menu` `c` on((`c`.`id` = `mt`.`child_id`))) where ((`m`.`deleted_at` is null) and (`m`.`parent_id` is null)) group by `m`.`id`,`m`.`label`,`url` order by `m`.`sort_order`,`m`.`id`;
+
+CREATE OR REPLACE ALGORITHM = UNDEFINED SQL SECURITY DEFINER VIEW `v_roles` AS with recursive `all_children` as (select `r`.`id` AS `parent_id`,`c`.`id` AS `child_id`,1 AS `level` from ((`role_inheritances` `ri` join `roles` `r` on((`r`.`id` = `ri`.`parent_role_id`))) join `roles` `c` on((`c`.`id` = `ri`.`role_id`))) union all select `ac`.`parent_id` AS `parent_id`,`c`.`id` AS `child_id`,(`ac`.`level` + 1) AS `level` from ((`role_inheritances` `ri` join `roles` `c` on((`c`.`id` = `ri`.`role_id`))) join `all_children` `ac` on((`ri`.`parent_role_id` = `ac`.`child_id`)))) select distinct `p`.`id` AS `role_id`,`p`.`name` AS `role_name`,`ac`.`child_id` AS `child_id`,`c`.`name` AS `child_name`,`ac`.`level` AS `level` from ((`all_children` `ac` join `roles` `p` on((`p`.`id` = `ac`.`parent_id`))) join `roles` `c` on((`c`.`id` = `ac`.`child_id`))) order by `role_id`,`ac`.`level`,`ac`.`child_id`;
+
+ALTER TABLE `report_schedules`
+  DROP FOREIGN KEY `report_schedules_tenant_fk`,
+  DROP INDEX `tenant_owner`,
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `menu`
+  DROP FOREIGN KEY `menu_tenant_fk`,
+  DROP INDEX `tenant_sort`,
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `roles`
+  DROP FOREIGN KEY `roles_tenant_fk`,
+  DROP INDEX `tenant_name`,
+  ADD UNIQUE INDEX `name`(`name` ASC) USING BTREE,
+  DROP COLUMN `tenant_id`;
+
+ALTER TABLE `users`
+  DROP FOREIGN KEY `users_tenant_fk`,
+  DROP INDEX `tenant_username`,
+  DROP INDEX `tenant_email`,
+  DROP INDEX `idx_users_keyset`,
+  ADD UNIQUE INDEX `username`(`username` ASC) USING BTREE,
+  ADD UNIQUE INDEX `email`(`email` ASC) USING BTREE,
+  ADD INDEX `idx_users_keyset`(`deleted_at`, `created_at`, `id`),
+  DROP COLUMN `tenant_id`;
+
+DROP TABLE IF EXISTS `tenants`;
@@ -0,0 +1,49 @@
+-- Multi-tenancy: tenants are the organizations one deployment serves. Users, roles, menu
+-- items and report schedules belong to one; user_roles, user_menu, role_menu and
+-- role_inheritances belong to the tenant of the rows they link. Every existing row goes to the default tenant, which keeps
+-- the instance-wide administration. Usernames, emails and role names are unique per tenant.
+
+CREATE TABLE IF NOT EXISTS `tenants`  (
+  `id` int UNSIGNED NOT NULL AUTO_INCREMENT,
+  `slug` varchar(63) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `name` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `status` tinyint UNSIGNED NOT NULL DEFAULT 1,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `slug`(`slug` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+INSERT INTO `tenants` (`id`, `slug`, `name`) VALUES (1, 'default', 'Default');
+
+ALTER TABLE `users`
+  ADD COLUMN `tenant_id` int UNSIGNED NOT NULL DEFAULT 1 AFTER `id`,
+  DROP INDEX `username`,
+  DROP INDEX `email`,
+  DROP INDEX `idx_users_keyset`,
+  ADD UNIQUE INDEX `tenant_username`(`tenant_id` ASC, `username` ASC) USING BTREE,
+  ADD UNIQUE INDEX `tenant_email`(`tenant_id` ASC, `email` ASC) USING BTREE,
+  ADD INDEX `idx_users_keyset`(`tenant_id` ASC, `deleted_at` ASC, `created_at` ASC, `id` ASC) USING BTREE,
+  ADD CONSTRAINT `users_tenant_fk` FOREIGN KEY (`tenant_id`) REFERENCES `tenants` (`id`) ON DELETE RESTRICT ON UPDATE RESTRICT;
+
+ALTER TABLE `roles`
+  ADD COLUMN `tenant_id` int UNSIGNED NOT NULL DEFAULT 1 AFTER `id`,
+  DROP INDEX `name`,
+  ADD UNIQUE INDEX `tenant_name`(`tenant_id` ASC, `name` ASC) USING BTREE,
+  ADD CONSTRAINT `roles_tenant_fk` FOREIGN KEY (`tenant_id`) REFERENCES `tenants` (`id`) ON DELETE RESTRICT ON UPDATE RESTRICT;
+
+ALTER TABLE `menu`
+  ADD COLUMN `tenant_id` int UNSIGNED NOT NULL DEFAULT 1 AFTER `id`,
+  ADD INDEX `tenant_sort`(`tenant_id` ASC, `sort_order` ASC) USING BTREE,
+  ADD CONSTRAINT `menu_tenant_fk` FOREIGN KEY (`tenant_id`) REFERENCES `tenants` (`id`) ON DELETE RESTRICT ON UPDATE RESTRICT;
+
+-- `owner` stays: the owner foreign key needs it
+ALTER TABLE `report_schedules`
+  ADD COLUMN `tenant_id` int UNSIGNED NOT NULL DEFAULT 1 AFTER `id`,
+  ADD INDEX `tenant_owner`(`tenant_id` ASC, `owner_id` ASC, `id` ASC) USING BTREE,
+  ADD CONSTRAINT `report_schedules_tenant_fk` FOREIGN KEY (`tenant_id`) REFERENCES `tenants` (`id`) ON DELETE CASCADE ON UPDATE RESTRICT;
+
+-- The views carry the tenant of their rows, last so the columns before it keep their places
+CREATE OR REPLACE ALGORITHM = UNDEFINED SQL SECURITY DEFINER VIEW `menu_navigation` AS with recursive `menu_tree` as (select `m`.`id` AS `parent_id`,`c`.`id` AS `child_id` from (`menu` `m` left join `menu` `c` on((`c`.`parent_id` = `m`.`id`))) where ((`m`.`deleted_at` is null) and (`c`.`deleted_at` is null)) union all select `mt`.`parent_id` AS `parent_id`,`c`.`id` AS `child_id` from (`menu` `c` join `menu_tree` `mt` on((`c`.`parent_id` = `mt`.`child_id`))) where (`c`.`deleted_at` is null)) select `m`.`id` AS `id`,`m`.`label` AS `label`,(case when ((`m`.`url` is not null) and (`m`.`url` <> '')) then `m`.`url` else 'javascript:void(0);' end) AS `url`,`m`.`icon` AS `icon`,coalesce(json_arrayagg(json_object('label',`c`.`label`,'parent_id',`c`.`parent_id`,'url',`c`.`url`)),json_array()) AS `children`,`m`.`tenant_id` AS `tenant_id` from ((`menu` `m` left join `menu_tree` `mt` on((`m`.`id` = `mt`.`parent_id`))) left join `menu` `c` on((`c`.`id` = `mt`.`child_id`))) where ((`m`.`deleted_at` is null) and (`m`.`parent_id` is null)) group by `m`.`id`,`m`.`label`,`url`,`m`.`tenant_id` order by `m`.`sort_order`,`m`.`id`;
+
+CREATE OR REPLACE ALGORITHM = UNDEFINED SQL SECURITY DEFINER VIEW `v_roles` AS with recursive `all_children` as (select `r`.`id` AS `parent_id`,`c`.`id` AS `child_id`,1 AS `level` from ((`role_inheritances` `ri` join `roles` `r` on((`r`.`id` = `ri`.`parent_role_id`))) join `roles` `c` on((`c`.`id` = `ri`.`role_id`))) union all select `ac`.`parent_id` AS `parent_id`,`c`.`id` AS `child_id`,(`ac`.`level` + 1) AS `level` from ((`role_inheritances` `ri` join `roles` `c` on((`c`.`id` = `ri`.`role_id`))) join `all_children` `ac` on((`ri`.`parent_role_id` = `ac`.`child_id`)))) select distinct `p`.`id` AS `role_id`,`p`.`name` AS `role_name`,`ac`.`child_id` AS `child_id`,`c`.`name` AS `child_name`,`ac`.`level` AS `level`,`p`.`tenant_id` AS `tenant_id` from ((`all_children` `ac` join `roles` `p` on((`p`.`id` = `ac`.`parent_id`))) join `roles` `c` on((`c`.`id` = `ac`.`child_id`))) order by `role_id`,`ac`.`level`,`ac`.`child_id`;
@@ -0,0 +1,12 @@
+-- Tenant settings: what a tenant overrides of the global configuration, one row per setting
+-- (prayer_method, jasper_organization, branding.<name>, cache_ttl.<key>). Settings without
+-- a row keep the global value.
+
+CREATE TABLE IF NOT EXISTS `tenant_settings`  (
+  `tenant_id` int UNSIGNED NOT NULL,
+  `name` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `value` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`tenant_id`, `name`) USING BTREE,
+  CONSTRAINT `tenant_settings_tenant_fk` FOREIGN KEY (`tenant_id`) REFERENCES `tenants` (`id`) ON DELETE CASCADE ON UPDATE RESTRICT
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
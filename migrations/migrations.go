@@ -0,0 +1,32 @@
+// Package migrations embeds the versioned SQL schema migrations.
+//
+// Files are named NNNN_description.up.sql / NNNN_description.down.sql and are
+// applied in version order by internal/pkg/migrate. The top-level files target
+// MySQL; postgres/ holds the same versions written for PostgreSQL. Every schema
+// change must be added to both sets under the same version number.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// FS holds every MySQL migration file
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed postgres/*.sql
+var postgresFS embed.FS
+
+// ForDriver returns the migration set for a DB_DRIVER value
+func ForDriver(driver string) (fs.FS, error) {
+	switch driver {
+	case "mysql":
+		return FS, nil
+	case "postgres":
+		return fs.Sub(postgresFS, "postgres")
+	}
+	return nil, fmt.Errorf("no migrations for driver %q", driver)
+}
@@ -0,0 +1,10 @@
+DROP VIEW IF EXISTS v_roles;
+DROP VIEW IF EXISTS menu_navigation;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS user_menu;
+DROP TABLE IF EXISTS role_menu;
+DROP TABLE IF EXISTS role_inheritances;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS menu;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
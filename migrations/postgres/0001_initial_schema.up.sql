@@ -0,0 +1,146 @@
+-- Core CMS schema for PostgreSQL: users, roles, menus, their assignments, audit log and hierarchy views.
+-- Mirrors ../0001_initial_schema.up.sql. PostgreSQL has no ON UPDATE CURRENT_TIMESTAMP;
+-- the application sets updated_at explicitly on every update.
+
+CREATE TABLE IF NOT EXISTS users (
+  id BIGSERIAL PRIMARY KEY,
+  username VARCHAR(100) NOT NULL,
+  email VARCHAR(191) NOT NULL,
+  password_hash VARCHAR(255) NOT NULL,
+  status SMALLINT NULL DEFAULT 1,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL,
+  CONSTRAINT users_username_key UNIQUE (username),
+  CONSTRAINT users_email_key UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS roles (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  description VARCHAR(255) NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL,
+  CONSTRAINT roles_name_key UNIQUE (name)
+);
+CREATE INDEX IF NOT EXISTS roles_deleted_at_idx ON roles (deleted_at);
+
+CREATE TABLE IF NOT EXISTS menu (
+  id SERIAL PRIMARY KEY,
+  label VARCHAR(100) NOT NULL,
+  url VARCHAR(255) NULL DEFAULT NULL,
+  icon VARCHAR(100) NULL DEFAULT NULL,
+  parent_id INTEGER NULL DEFAULT NULL REFERENCES menu (id) ON DELETE SET NULL,
+  sort_order SMALLINT NULL DEFAULT 0,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS menu_parent_id_idx ON menu (parent_id);
+CREATE INDEX IF NOT EXISTS menu_deleted_at_idx ON menu (deleted_at);
+
+-- ip_address holds the 4- or 16-byte binary form, like MySQL's INET6_ATON
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NULL DEFAULT NULL,
+  event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('CREATE','UPDATE','DELETE','RESTORE','LOGIN','LOGOUT','API_ACCESS','API_ERROR')),
+  table_name VARCHAR(100) NOT NULL,
+  record_id BIGINT NOT NULL,
+  old_values JSON NULL,
+  new_values JSON NULL,
+  ip_address BYTEA NULL DEFAULT NULL,
+  user_agent VARCHAR(255) NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS audit_logs_user_id_idx ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS audit_logs_created_at_idx ON audit_logs (created_at);
+CREATE INDEX IF NOT EXISTS audit_logs_table_name_idx ON audit_logs (table_name, record_id);
+CREATE INDEX IF NOT EXISTS audit_logs_event_type_idx ON audit_logs (event_type);
+
+CREATE TABLE IF NOT EXISTS role_inheritances (
+  id BIGSERIAL PRIMARY KEY,
+  role_id INTEGER NOT NULL,
+  parent_role_id INTEGER NOT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS role_inheritances_role_id_idx ON role_inheritances (role_id);
+CREATE INDEX IF NOT EXISTS role_inheritances_parent_role_id_idx ON role_inheritances (parent_role_id);
+
+CREATE TABLE IF NOT EXISTS role_menu (
+  role_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  menu_id INTEGER NOT NULL REFERENCES menu (id) ON DELETE CASCADE,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL,
+  PRIMARY KEY (role_id, menu_id)
+);
+CREATE INDEX IF NOT EXISTS role_menu_menu_id_idx ON role_menu (menu_id);
+CREATE INDEX IF NOT EXISTS role_menu_deleted_at_idx ON role_menu (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_menu (
+  user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  menu_id INTEGER NOT NULL REFERENCES menu (id) ON DELETE CASCADE,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL,
+  PRIMARY KEY (user_id, menu_id)
+);
+CREATE INDEX IF NOT EXISTS user_menu_menu_id_idx ON user_menu (menu_id);
+CREATE INDEX IF NOT EXISTS user_menu_deleted_at_idx ON user_menu (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  role_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL,
+  PRIMARY KEY (user_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS user_roles_role_id_idx ON user_roles (role_id);
+CREATE INDEX IF NOT EXISTS user_roles_deleted_at_idx ON user_roles (deleted_at);
+
+-- Top-level menus with their descendants aggregated into a JSON array
+CREATE OR REPLACE VIEW menu_navigation AS
+WITH RECURSIVE menu_tree AS (
+  SELECT m.id AS parent_id, c.id AS child_id
+  FROM menu m
+  LEFT JOIN menu c ON c.parent_id = m.id
+  WHERE m.deleted_at IS NULL AND c.deleted_at IS NULL
+  UNION ALL
+  SELECT mt.parent_id, c.id AS child_id
+  FROM menu c
+  JOIN menu_tree mt ON c.parent_id = mt.child_id
+  WHERE c.deleted_at IS NULL
+)
+SELECT m.id AS id,
+  m.label AS label,
+  CASE WHEN m.url IS NOT NULL AND m.url <> '' THEN m.url ELSE 'javascript:void(0);' END AS url,
+  m.icon AS icon,
+  COALESCE(json_agg(json_build_object('label', c.label, 'parent_id', c.parent_id, 'url', c.url)), '[]'::json) AS children
+FROM menu m
+LEFT JOIN menu_tree mt ON m.id = mt.parent_id
+LEFT JOIN menu c ON c.id = mt.child_id
+WHERE m.deleted_at IS NULL AND m.parent_id IS NULL
+GROUP BY m.id
+ORDER BY m.sort_order, m.id;
+
+-- Every role with all of its direct and inherited children and their depth
+CREATE OR REPLACE VIEW v_roles AS
+WITH RECURSIVE all_children AS (
+  SELECT r.id AS parent_id, c.id AS child_id, 1 AS level
+  FROM role_inheritances ri
+  JOIN roles r ON r.id = ri.parent_role_id
+  JOIN roles c ON c.id = ri.role_id
+  UNION ALL
+  SELECT ac.parent_id, c.id AS child_id, ac.level + 1 AS level
+  FROM role_inheritances ri
+  JOIN roles c ON c.id = ri.role_id
+  JOIN all_children ac ON ri.parent_role_id = ac.child_id
+)
+SELECT DISTINCT p.id AS role_id, p.name AS role_name, ac.child_id AS child_id, c.name AS child_name, ac.level AS level
+FROM all_children ac
+JOIN roles p ON p.id = ac.parent_id
+JOIN roles c ON c.id = ac.child_id
+ORDER BY role_id, level, child_id;
@@ -0,0 +1,40 @@
+-- Reference tables used by the prayer schedule API. Data is loaded separately.
+-- data_lintang_kota_cms_new.nama_propinsi / nama_kota hold province and city ids; they are
+-- integers here because PostgreSQL does not compare varchar with integer implicitly.
+
+CREATE TABLE IF NOT EXISTS app_province (
+  province_id SERIAL PRIMARY KEY,
+  province_title VARCHAR(100) NOT NULL,
+  province_id_new INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS app_city (
+  city_id SERIAL PRIMARY KEY,
+  city_title VARCHAR(40) NULL DEFAULT NULL,
+  city_province INTEGER NOT NULL,
+  city_id_new INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS data_lintang_kota_cms_new (
+  id_kota SERIAL PRIMARY KEY,
+  nama_propinsi INTEGER NULL DEFAULT NULL,
+  nama_kota INTEGER NULL DEFAULT NULL,
+  bujur_tempat VARCHAR(50) NULL DEFAULT NULL,
+  lintang_tempat VARCHAR(50) NULL DEFAULT NULL,
+  time_zone VARCHAR(3) NULL DEFAULT NULL,
+  h INTEGER NULL DEFAULT NULL,
+  time_create TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS hisab_tgl_puasa (
+  tgl_id SERIAL PRIMARY KEY,
+  tgl_tahun INTEGER NULL DEFAULT NULL,
+  tgl_start DATE NULL DEFAULT NULL,
+  tgl_end DATE NULL DEFAULT NULL,
+  tgl_status INTEGER NULL DEFAULT 0,
+  tgl_hijriah INTEGER NULL DEFAULT NULL,
+  time_add TIMESTAMP NULL DEFAULT NULL,
+  time_update TIMESTAMP NULL DEFAULT NULL,
+  user_add INTEGER NULL DEFAULT NULL,
+  user_update INTEGER NULL DEFAULT NULL
+);
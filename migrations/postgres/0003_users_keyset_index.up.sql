@@ -0,0 +1,2 @@
+-- Supports keyset pagination of active users: WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC.
+CREATE INDEX IF NOT EXISTS idx_users_keyset ON users (deleted_at, created_at, id);
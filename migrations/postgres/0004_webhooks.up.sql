@@ -0,0 +1,32 @@
+-- Outbound webhooks: subscriptions to entity events, and the log of their deliveries.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+  id BIGSERIAL PRIMARY KEY,
+  url VARCHAR(2048) NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  events VARCHAR(1024) NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  description VARCHAR(255) NULL DEFAULT NULL,
+  created_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL DEFAULT NULL,
+  deleted_by BIGINT NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS webhooks_deleted_at_idx ON webhooks (deleted_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  webhook_id BIGINT NOT NULL,
+  event_id VARCHAR(64) NOT NULL,
+  event VARCHAR(100) NOT NULL,
+  payload JSON NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER NULL DEFAULT NULL,
+  last_error VARCHAR(1024) NULL DEFAULT NULL,
+  next_attempt_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
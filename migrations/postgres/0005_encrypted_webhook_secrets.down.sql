@@ -0,0 +1,2 @@
+-- Fails while secrets longer than 255 characters, such as encrypted ones, remain.
+ALTER TABLE webhooks ALTER COLUMN secret TYPE VARCHAR(255);
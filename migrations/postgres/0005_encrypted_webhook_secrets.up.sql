@@ -0,0 +1,3 @@
+-- Room for webhook secrets encrypted by internal/pkg/fieldcrypt: a 255-character secret takes
+-- about 420 once sealed and base64-encoded.
+ALTER TABLE webhooks ALTER COLUMN secret TYPE VARCHAR(512);
@@ -0,0 +1,18 @@
+-- Security events raised by internal/pkg/secevents, kept until an administrator
+-- acknowledges them (and after, as a record).
+
+CREATE TABLE IF NOT EXISTS security_events (
+  id BIGSERIAL PRIMARY KEY,
+  type VARCHAR(50) NOT NULL,
+  severity VARCHAR(20) NOT NULL,
+  message VARCHAR(500) NOT NULL,
+  ip_address VARCHAR(45) NULL DEFAULT NULL,
+  user_id BIGINT NULL DEFAULT NULL,
+  details JSON NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  acknowledged_at TIMESTAMP NULL DEFAULT NULL,
+  acknowledged_by BIGINT NULL DEFAULT NULL,
+  acknowledgement_note VARCHAR(500) NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS security_events_acknowledged_at_idx ON security_events (acknowledged_at, id);
+CREATE INDEX IF NOT EXISTS security_events_type_idx ON security_events (type, id);
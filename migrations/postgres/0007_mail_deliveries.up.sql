@@ -0,0 +1,18 @@
+-- Emails sent through internal/pkg/mail: one row per message, with the outcome of its
+-- latest attempt, for troubleshooting delivery.
+
+CREATE TABLE IF NOT EXISTS mail_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  template VARCHAR(100) NOT NULL,
+  recipient VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  user_id BIGINT NULL DEFAULT NULL,
+  status VARCHAR(20) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error VARCHAR(1024) NULL DEFAULT NULL,
+  next_attempt_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS mail_deliveries_status_idx ON mail_deliveries (status, id);
+CREATE INDEX IF NOT EXISTS mail_deliveries_recipient_idx ON mail_deliveries (recipient, id);
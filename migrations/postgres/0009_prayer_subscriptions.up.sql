@@ -0,0 +1,20 @@
+-- Chats subscribed to prayer times through a messaging bot: the daily schedule, or the imsak
+-- reminder of the fasting period, for one city. last_sent_at keeps a job run from sending a
+-- chat the same day twice.
+
+CREATE TABLE IF NOT EXISTS prayer_subscriptions (
+  id BIGSERIAL PRIMARY KEY,
+  channel VARCHAR(20) NOT NULL,
+  chat_id VARCHAR(64) NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  province_id INTEGER NOT NULL,
+  city_id INTEGER NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  last_sent_at TIMESTAMP NULL DEFAULT NULL,
+  last_error VARCHAR(1024) NULL DEFAULT NULL,
+  created_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS prayer_subscriptions_channel_chat_kind_city_idx ON prayer_subscriptions (channel, chat_id, kind, city_id);
+CREATE INDEX IF NOT EXISTS prayer_subscriptions_kind_active_idx ON prayer_subscriptions (kind, active, id);
@@ -0,0 +1,19 @@
+-- Mobile app installations registered for push notifications through FCM, with the city whose
+-- prayer reminders and announcements they get. last_reminder_at is the prayer time the device
+-- was last reminded of, so a reminder is never sent twice.
+
+CREATE TABLE IF NOT EXISTS push_devices (
+  id BIGSERIAL PRIMARY KEY,
+  token VARCHAR(512) NOT NULL,
+  platform VARCHAR(20) NOT NULL,
+  province_id INTEGER NOT NULL,
+  city_id INTEGER NOT NULL,
+  reminders BOOLEAN NOT NULL DEFAULT TRUE,
+  announcements BOOLEAN NOT NULL DEFAULT TRUE,
+  user_id BIGINT NULL DEFAULT NULL,
+  last_reminder_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS push_devices_token_idx ON push_devices (token);
+CREATE INDEX IF NOT EXISTS push_devices_city_id_idx ON push_devices (city_id, id);
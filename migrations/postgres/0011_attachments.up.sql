@@ -0,0 +1,19 @@
+-- Uploaded files: user avatars and files passed to reports as parameters. The bytes live in
+-- the configured storage (local disk or an S3-compatible bucket) under storage_key; this is
+-- their metadata. owner_id is the user the file belongs to, created_by who uploaded it.
+
+CREATE TABLE IF NOT EXISTS attachments (
+  id BIGSERIAL PRIMARY KEY,
+  purpose VARCHAR(30) NOT NULL,
+  owner_id BIGINT NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size BIGINT NOT NULL,
+  sha256 CHAR(64) NOT NULL,
+  storage_key VARCHAR(255) NOT NULL,
+  scan_status VARCHAR(20) NOT NULL,
+  created_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS attachments_storage_key_idx ON attachments (storage_key);
+CREATE INDEX IF NOT EXISTS attachments_owner_id_purpose_idx ON attachments (owner_id, purpose, id);
@@ -0,0 +1,18 @@
+-- Announcements shown as banners in the admin panel, e.g. maintenance notices: each is shown
+-- from starts_at until ends_at (open-ended when NULL) to the users holding one of
+-- target_roles, comma-separated role names, or to everyone when it is empty.
+
+CREATE TABLE IF NOT EXISTS announcements (
+  id BIGSERIAL PRIMARY KEY,
+  message VARCHAR(1000) NOT NULL,
+  severity VARCHAR(20) NOT NULL,
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NULL DEFAULT NULL,
+  target_roles VARCHAR(1024) NOT NULL DEFAULT '',
+  created_by BIGINT NULL DEFAULT NULL,
+  updated_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS announcements_starts_at_idx ON announcements (starts_at);
+CREATE INDEX IF NOT EXISTS announcements_ends_at_idx ON announcements (ends_at);
@@ -0,0 +1,49 @@
+-- SMS one-time codes for two-factor sign-in and password resets. user_phones holds each
+-- user's number, in E.164, once they have proven it (verified_at) and whether sign-in asks
+-- for a code (two_factor). otp_codes are the codes sent, kept as hashes; a caller refers to
+-- one by its challenge, also hashed. sms_messages is every text sent, with the delivery
+-- status the provider reported, for audit.
+
+CREATE TABLE IF NOT EXISTS user_phones (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  phone VARCHAR(20) NOT NULL,
+  verified_at TIMESTAMP NULL DEFAULT NULL,
+  two_factor BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS user_phones_user_id_idx ON user_phones (user_id);
+CREATE INDEX IF NOT EXISTS user_phones_phone_idx ON user_phones (phone);
+
+CREATE TABLE IF NOT EXISTS otp_codes (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  purpose VARCHAR(20) NOT NULL,
+  phone VARCHAR(20) NOT NULL,
+  code_hash CHAR(64) NOT NULL,
+  challenge_hash CHAR(64) NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  expires_at TIMESTAMP NOT NULL,
+  consumed_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS otp_codes_challenge_hash_idx ON otp_codes (challenge_hash);
+CREATE INDEX IF NOT EXISTS otp_codes_user_id_idx ON otp_codes (user_id, purpose);
+CREATE INDEX IF NOT EXISTS otp_codes_expires_at_idx ON otp_codes (expires_at);
+
+CREATE TABLE IF NOT EXISTS sms_messages (
+  id BIGSERIAL PRIMARY KEY,
+  provider VARCHAR(20) NOT NULL,
+  provider_message_id VARCHAR(100) NULL DEFAULT NULL,
+  phone VARCHAR(20) NOT NULL,
+  purpose VARCHAR(20) NOT NULL,
+  user_id BIGINT NULL DEFAULT NULL,
+  status VARCHAR(20) NOT NULL,
+  error VARCHAR(1024) NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS sms_messages_provider_message_id_idx ON sms_messages (provider, provider_message_id);
+CREATE INDEX IF NOT EXISTS sms_messages_phone_idx ON sms_messages (phone, id);
+CREATE INDEX IF NOT EXISTS sms_messages_status_idx ON sms_messages (status, id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS calendar_credentials;
+DROP TABLE IF EXISTS report_schedule_events;
+DROP TABLE IF EXISTS report_schedules;
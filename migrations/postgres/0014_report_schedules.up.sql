@@ -0,0 +1,48 @@
+-- Report schedules: reports the report_schedules scheduler job runs for their owner on a
+-- cron schedule, keeping each rendered file in attachments. The calendar_sync job publishes
+-- their upcoming runs to a shared CalDAV or Google calendar: report_schedule_events records
+-- the event it made for each run, so runs no longer planned can be taken off the calendar,
+-- even those of deleted schedules. calendar_credentials holds the OAuth tokens of the Google
+-- account the events are written with, encrypted with FIELD_ENCRYPTION_KEYS.
+
+CREATE TABLE IF NOT EXISTS report_schedules (
+  id BIGSERIAL PRIMARY KEY,
+  owner_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  report_path VARCHAR(255) NOT NULL,
+  output_format VARCHAR(10) NOT NULL,
+  parameters JSON NULL,
+  schedule VARCHAR(100) NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  next_run_at TIMESTAMP NULL DEFAULT NULL,
+  last_run_at TIMESTAMP NULL DEFAULT NULL,
+  last_status VARCHAR(20) NULL DEFAULT NULL,
+  last_error VARCHAR(500) NULL DEFAULT NULL,
+  last_attachment_id BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS report_schedules_due_idx ON report_schedules (enabled, next_run_at);
+CREATE INDEX IF NOT EXISTS report_schedules_owner_idx ON report_schedules (owner_id, id);
+
+CREATE TABLE IF NOT EXISTS report_schedule_events (
+  schedule_id BIGINT NOT NULL,
+  run_at TIMESTAMP NOT NULL,
+  provider VARCHAR(10) NOT NULL,
+  event_id VARCHAR(255) NOT NULL,
+  digest CHAR(64) NOT NULL,
+  synced_at TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (provider, schedule_id, run_at)
+);
+
+CREATE TABLE IF NOT EXISTS calendar_credentials (
+  id SERIAL PRIMARY KEY,
+  provider VARCHAR(10) NOT NULL UNIQUE,
+  access_token TEXT NOT NULL,
+  refresh_token TEXT NOT NULL,
+  expires_at TIMESTAMP NULL DEFAULT NULL,
+  scope VARCHAR(255) NOT NULL DEFAULT '',
+  connected_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS location_import_changes;
+DROP TABLE IF EXISTS location_imports;
@@ -0,0 +1,29 @@
+-- Journal of the reference data imports into app_province, app_city and
+-- data_lintang_kota_cms_new. Each import lists the regions it created or changed with
+-- their values before and after, so the latest one can be rolled back. status is applying
+-- while it runs, then applied, failed (undone after an error) or rolled_back.
+
+CREATE TABLE IF NOT EXISTS location_imports (
+  id BIGSERIAL PRIMARY KEY,
+  filename VARCHAR(255) NOT NULL,
+  source VARCHAR(20) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  created INTEGER NOT NULL DEFAULT 0,
+  updated INTEGER NOT NULL DEFAULT 0,
+  created_by BIGINT NULL DEFAULT NULL,
+  rolled_back_by BIGINT NULL DEFAULT NULL,
+  rolled_back_at TIMESTAMP NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS location_imports_status_idx ON location_imports (status, id);
+
+CREATE TABLE IF NOT EXISTS location_import_changes (
+  id BIGSERIAL PRIMARY KEY,
+  import_id BIGINT NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  record_id INTEGER NOT NULL,
+  action VARCHAR(20) NOT NULL,
+  old_values JSON NULL,
+  new_values JSON NULL
+);
+CREATE INDEX IF NOT EXISTS location_import_changes_import_id_idx ON location_import_changes (import_id, id);
@@ -0,0 +1,22 @@
+-- Export jobs: long exports run in the background by the export_jobs scheduler job. A job
+-- is queued, then running (heartbeat_at advancing while an instance works on it), then
+-- succeeded with its file in attachments, failed or expired once its file was removed.
+
+CREATE TABLE IF NOT EXISTS export_jobs (
+  id BIGSERIAL PRIMARY KEY,
+  kind VARCHAR(50) NOT NULL,
+  format VARCHAR(10) NOT NULL,
+  params JSON NULL,
+  status VARCHAR(20) NOT NULL,
+  processed BIGINT NOT NULL DEFAULT 0,
+  total BIGINT NULL DEFAULT NULL,
+  attachment_id BIGINT NULL DEFAULT NULL,
+  error VARCHAR(500) NULL DEFAULT NULL,
+  created_by BIGINT NOT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at TIMESTAMP NULL DEFAULT NULL,
+  heartbeat_at TIMESTAMP NULL DEFAULT NULL,
+  finished_at TIMESTAMP NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS export_jobs_status_idx ON export_jobs (status, id);
+CREATE INDEX IF NOT EXISTS export_jobs_created_by_idx ON export_jobs (created_by, id);
@@ -0,0 +1,21 @@
+-- Database backups: logical dumps made by the db_backup scheduler job and kept in the
+-- configured storage. A backup is queued, then running, then succeeded with its file under
+-- storage_key, failed, or expired once its file was removed after DB_BACKUP_RETENTION.
+-- created_by is NULL for the backups the schedule makes.
+
+CREATE TABLE IF NOT EXISTS db_backups (
+  id BIGSERIAL PRIMARY KEY,
+  status VARCHAR(20) NOT NULL,
+  storage_key VARCHAR(255) NULL DEFAULT NULL,
+  size BIGINT NULL DEFAULT NULL,
+  checksum CHAR(64) NULL DEFAULT NULL,
+  table_count INTEGER NOT NULL DEFAULT 0,
+  row_count BIGINT NOT NULL DEFAULT 0,
+  schema_version BIGINT NULL DEFAULT NULL,
+  error VARCHAR(500) NULL DEFAULT NULL,
+  created_by BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at TIMESTAMP NULL DEFAULT NULL,
+  finished_at TIMESTAMP NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS db_backups_status_idx ON db_backups (status, id);
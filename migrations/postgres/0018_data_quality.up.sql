@@ -0,0 +1,23 @@
+-- Data quality: what the data_quality scheduler job found. data_quality_checks holds each
+-- check's last run; data_quality_findings the records it flagged, one row per check and
+-- record_key, kept while the check keeps finding the record and deleted once it does not.
+
+CREATE TABLE IF NOT EXISTS data_quality_checks (
+  name VARCHAR(50) PRIMARY KEY,
+  severity VARCHAR(10) NOT NULL,
+  finding_count INTEGER NOT NULL DEFAULT 0,
+  truncated BOOLEAN NOT NULL DEFAULT FALSE,
+  checked_at TIMESTAMP NULL DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS data_quality_findings (
+  id BIGSERIAL PRIMARY KEY,
+  check_name VARCHAR(50) NOT NULL,
+  severity VARCHAR(10) NOT NULL,
+  record_key VARCHAR(100) NOT NULL,
+  detail VARCHAR(255) NOT NULL,
+  first_seen_at TIMESTAMP NULL DEFAULT NULL,
+  last_seen_at TIMESTAMP NULL DEFAULT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS data_quality_findings_check_record_idx ON data_quality_findings (check_name, record_key);
+CREATE INDEX IF NOT EXISTS data_quality_findings_severity_idx ON data_quality_findings (severity, id);
@@ -0,0 +1,8 @@
+-- Forced password changes: users flagged by POST /api/users/force-password-reset set a new
+-- password at their next sign-in, which clears the flag. last_login_at is when a user last
+-- signed in, which the force-password-reset filter selects on; NULL for users who have not
+-- since this migration.
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP NULL DEFAULT NULL;
@@ -0,0 +1,68 @@
+-- Restoring the global unique constraints fails while two tenants share a username, email
+-- or role name; rename or delete one of them first.
+
+DROP VIEW IF EXISTS menu_navigation;
+CREATE VIEW menu_navigation AS
+WITH RECURSIVE menu_tree AS (
+  SELECT m.id AS parent_id, c.id AS child_id
+  FROM menu m
+  LEFT JOIN menu c ON c.parent_id = m.id
+  WHERE m.deleted_at IS NULL AND c.deleted_at IS NULL
+  UNION ALL
+  SELECT mt.parent_id, c.id AS child_id
+  FROM menu c
+  JOIN menu_tree mt ON c.parent_id = mt.child_id
+  WHERE c.deleted_at IS NULL
+)
+SELECT m.id AS id,
+  m.label AS label,
+  CASE WHEN m.url IS NOT NULL AND m.url <> '' THEN m.url ELSE 'javascript:void(0);' END AS url,
+  m.icon AS icon,
+  COALESCE(json_agg(json_build_object('label', c.label, 'parent_id', c.parent_id, 'url', c.url)), '[]'::json) AS children
+FROM menu m
+LEFT JOIN menu_tree mt ON m.id = mt.parent_id
+LEFT JOIN menu c ON c.id = mt.child_id
+WHERE m.deleted_at IS NULL AND m.parent_id IS NULL
+GROUP BY m.id
+ORDER BY m.sort_order, m.id;
+
+DROP VIEW IF EXISTS v_roles;
+CREATE VIEW v_roles AS
+WITH RECURSIVE all_children AS (
+  SELECT r.id AS parent_id, c.id AS child_id, 1 AS level
+  FROM role_inheritances ri
+  JOIN roles r ON r.id = ri.parent_role_id
+  JOIN roles c ON c.id = ri.role_id
+  UNION ALL
+  SELECT ac.parent_id, c.id AS child_id, ac.level + 1 AS level
+  FROM role_inheritances ri
+  JOIN roles c ON c.id = ri.role_id
+  JOIN all_children ac ON ri.parent_role_id = ac.child_id
+)
+SELECT DISTINCT p.id AS role_id, p.name AS role_name, ac.child_id AS child_id, c.name AS child_name, ac.level AS level
+FROM all_children ac
+JOIN roles p ON p.id = ac.parent_id
+JOIN roles c ON c.id = ac.child_id
+ORDER BY role_id, level, child_id;
+
+DROP INDEX IF EXISTS report_schedules_tenant_owner_idx;
+ALTER TABLE report_schedules DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS menu_tenant_sort_idx;
+ALTER TABLE menu DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE roles
+  DROP CONSTRAINT IF EXISTS roles_tenant_name_key,
+  ADD CONSTRAINT roles_name_key UNIQUE (name),
+  DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS idx_users_keyset;
+ALTER TABLE users
+  DROP CONSTRAINT IF EXISTS users_tenant_username_key,
+  DROP CONSTRAINT IF EXISTS users_tenant_email_key,
+  ADD CONSTRAINT users_username_key UNIQUE (username),
+  ADD CONSTRAINT users_email_key UNIQUE (email),
+  DROP COLUMN IF EXISTS tenant_id;
+CREATE INDEX IF NOT EXISTS idx_users_keyset ON users (deleted_at, created_at, id);
+
+DROP TABLE IF EXISTS tenants;
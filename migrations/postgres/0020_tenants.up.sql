@@ -0,0 +1,86 @@
+-- Multi-tenancy: tenants are the organizations one deployment serves. Users, roles, menu
+-- items and report schedules belong to one; user_roles, user_menu, role_menu and
+-- role_inheritances belong to the tenant of the rows they link. Every existing row goes to the default tenant, which keeps
+-- the instance-wide administration. Usernames, emails and role names are unique per tenant.
+
+CREATE TABLE IF NOT EXISTS tenants (
+  id SERIAL PRIMARY KEY,
+  slug VARCHAR(63) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  status SMALLINT NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT tenants_slug_key UNIQUE (slug)
+);
+
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'Default') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants));
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+  DROP CONSTRAINT IF EXISTS users_username_key,
+  DROP CONSTRAINT IF EXISTS users_email_key,
+  ADD CONSTRAINT users_tenant_username_key UNIQUE (tenant_id, username),
+  ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email);
+DROP INDEX IF EXISTS idx_users_keyset;
+CREATE INDEX IF NOT EXISTS idx_users_keyset ON users (tenant_id, deleted_at, created_at, id);
+
+ALTER TABLE roles
+  ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id),
+  DROP CONSTRAINT IF EXISTS roles_name_key,
+  ADD CONSTRAINT roles_tenant_name_key UNIQUE (tenant_id, name);
+
+ALTER TABLE menu
+  ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS menu_tenant_sort_idx ON menu (tenant_id, sort_order);
+
+ALTER TABLE report_schedules
+  ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS report_schedules_tenant_owner_idx ON report_schedules (tenant_id, owner_id, id);
+
+-- The views carry the tenant of their rows, last so the columns before it keep their places
+DROP VIEW IF EXISTS menu_navigation;
+CREATE VIEW menu_navigation AS
+WITH RECURSIVE menu_tree AS (
+  SELECT m.id AS parent_id, c.id AS child_id
+  FROM menu m
+  LEFT JOIN menu c ON c.parent_id = m.id
+  WHERE m.deleted_at IS NULL AND c.deleted_at IS NULL
+  UNION ALL
+  SELECT mt.parent_id, c.id AS child_id
+  FROM menu c
+  JOIN menu_tree mt ON c.parent_id = mt.child_id
+  WHERE c.deleted_at IS NULL
+)
+SELECT m.id AS id,
+  m.label AS label,
+  CASE WHEN m.url IS NOT NULL AND m.url <> '' THEN m.url ELSE 'javascript:void(0);' END AS url,
+  m.icon AS icon,
+  COALESCE(json_agg(json_build_object('label', c.label, 'parent_id', c.parent_id, 'url', c.url)), '[]'::json) AS children,
+  m.tenant_id AS tenant_id
+FROM menu m
+LEFT JOIN menu_tree mt ON m.id = mt.parent_id
+LEFT JOIN menu c ON c.id = mt.child_id
+WHERE m.deleted_at IS NULL AND m.parent_id IS NULL
+GROUP BY m.id
+ORDER BY m.sort_order, m.id;
+
+DROP VIEW IF EXISTS v_roles;
+CREATE VIEW v_roles AS
+WITH RECURSIVE all_children AS (
+  SELECT r.id AS parent_id, c.id AS child_id, 1 AS level
+  FROM role_inheritances ri
+  JOIN roles r ON r.id = ri.parent_role_id
+  JOIN roles c ON c.id = ri.role_id
+  UNION ALL
+  SELECT ac.parent_id, c.id AS child_id, ac.level + 1 AS level
+  FROM role_inheritances ri
+  JOIN roles c ON c.id = ri.role_id
+  JOIN all_children ac ON ri.parent_role_id = ac.child_id
+)
+SELECT DISTINCT p.id AS role_id, p.name AS role_name, ac.child_id AS child_id, c.name AS child_name, ac.level AS level,
+  p.tenant_id AS tenant_id
+FROM all_children ac
+JOIN roles p ON p.id = ac.parent_id
+JOIN roles c ON c.id = ac.child_id
+ORDER BY role_id, level, child_id;
@@ -0,0 +1,11 @@
+-- Tenant settings: what a tenant overrides of the global configuration, one row per setting
+-- (prayer_method, jasper_organization, branding.<name>, cache_ttl.<key>). Settings without
+-- a row keep the global value.
+
+CREATE TABLE IF NOT EXISTS tenant_settings (
+  tenant_id INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+  name VARCHAR(64) NOT NULL,
+  value VARCHAR(255) NOT NULL,
+  updated_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (tenant_id, name)
+);
@@ -0,0 +1,6 @@
+// Package adminpb holds the protobuf messages and gRPC clients of the adminbe gRPC API, for
+// internal Go services. The .proto files next to it are the source; regenerate the *.pb.go
+// files after changing them (protoc with protoc-gen-go and protoc-gen-go-grpc on PATH).
+package adminpb
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative roles.proto users.proto prayer.proto
@@ -0,0 +1,620 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v6.31.1
+// source: prayer.proto
+
+package adminpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Location struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_prayer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Location) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Location) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListProvincesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProvincesRequest) Reset() {
+	*x = ListProvincesRequest{}
+	mi := &file_prayer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProvincesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProvincesRequest) ProtoMessage() {}
+
+func (x *ListProvincesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProvincesRequest.ProtoReflect.Descriptor instead.
+func (*ListProvincesRequest) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{1}
+}
+
+type ListCitiesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Province      string                 `protobuf:"bytes,1,opt,name=province,proto3" json:"province,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCitiesRequest) Reset() {
+	*x = ListCitiesRequest{}
+	mi := &file_prayer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCitiesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCitiesRequest) ProtoMessage() {}
+
+func (x *ListCitiesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCitiesRequest.ProtoReflect.Descriptor instead.
+func (*ListCitiesRequest) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListCitiesRequest) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+type ListLocationsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Locations     []*Location            `protobuf:"bytes,1,rep,name=locations,proto3" json:"locations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLocationsResponse) Reset() {
+	*x = ListLocationsResponse{}
+	mi := &file_prayer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLocationsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLocationsResponse) ProtoMessage() {}
+
+func (x *ListLocationsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLocationsResponse.ProtoReflect.Descriptor instead.
+func (*ListLocationsResponse) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListLocationsResponse) GetLocations() []*Location {
+	if x != nil {
+		return x.Locations
+	}
+	return nil
+}
+
+type GetScheduleRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Province string                 `protobuf:"bytes,1,opt,name=province,proto3" json:"province,omitempty"`
+	City     string                 `protobuf:"bytes,2,opt,name=city,proto3" json:"city,omitempty"`
+	// start_date is the first day, in YYYY-MM-DD form
+	StartDate string `protobuf:"bytes,3,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	// days defaults to 1; at most 366
+	Days          int32 `protobuf:"varint,4,opt,name=days,proto3" json:"days,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetScheduleRequest) Reset() {
+	*x = GetScheduleRequest{}
+	mi := &file_prayer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetScheduleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetScheduleRequest) ProtoMessage() {}
+
+func (x *GetScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetScheduleRequest.ProtoReflect.Descriptor instead.
+func (*GetScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetScheduleRequest) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+func (x *GetScheduleRequest) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *GetScheduleRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *GetScheduleRequest) GetDays() int32 {
+	if x != nil {
+		return x.Days
+	}
+	return 0
+}
+
+type GetFastingScheduleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Province      string                 `protobuf:"bytes,1,opt,name=province,proto3" json:"province,omitempty"`
+	City          string                 `protobuf:"bytes,2,opt,name=city,proto3" json:"city,omitempty"`
+	Year          int32                  `protobuf:"varint,3,opt,name=year,proto3" json:"year,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetFastingScheduleRequest) Reset() {
+	*x = GetFastingScheduleRequest{}
+	mi := &file_prayer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFastingScheduleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFastingScheduleRequest) ProtoMessage() {}
+
+func (x *GetFastingScheduleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFastingScheduleRequest.ProtoReflect.Descriptor instead.
+func (*GetFastingScheduleRequest) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetFastingScheduleRequest) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+func (x *GetFastingScheduleRequest) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *GetFastingScheduleRequest) GetYear() int32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+type PrayerDay struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// date is in YYYY-MM-DD form, the times in HH:MM local time
+	Date          string `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Imsak         string `protobuf:"bytes,2,opt,name=imsak,proto3" json:"imsak,omitempty"`
+	Subuh         string `protobuf:"bytes,3,opt,name=subuh,proto3" json:"subuh,omitempty"`
+	Terbit        string `protobuf:"bytes,4,opt,name=terbit,proto3" json:"terbit,omitempty"`
+	Dhuha         string `protobuf:"bytes,5,opt,name=dhuha,proto3" json:"dhuha,omitempty"`
+	Dzuhur        string `protobuf:"bytes,6,opt,name=dzuhur,proto3" json:"dzuhur,omitempty"`
+	Ashar         string `protobuf:"bytes,7,opt,name=ashar,proto3" json:"ashar,omitempty"`
+	Maghrib       string `protobuf:"bytes,8,opt,name=maghrib,proto3" json:"maghrib,omitempty"`
+	Isya          string `protobuf:"bytes,9,opt,name=isya,proto3" json:"isya,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrayerDay) Reset() {
+	*x = PrayerDay{}
+	mi := &file_prayer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrayerDay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrayerDay) ProtoMessage() {}
+
+func (x *PrayerDay) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrayerDay.ProtoReflect.Descriptor instead.
+func (*PrayerDay) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PrayerDay) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetImsak() string {
+	if x != nil {
+		return x.Imsak
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetSubuh() string {
+	if x != nil {
+		return x.Subuh
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetTerbit() string {
+	if x != nil {
+		return x.Terbit
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetDhuha() string {
+	if x != nil {
+		return x.Dhuha
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetDzuhur() string {
+	if x != nil {
+		return x.Dzuhur
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetAshar() string {
+	if x != nil {
+		return x.Ashar
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetMaghrib() string {
+	if x != nil {
+		return x.Maghrib
+	}
+	return ""
+}
+
+func (x *PrayerDay) GetIsya() string {
+	if x != nil {
+		return x.Isya
+	}
+	return ""
+}
+
+type Schedule struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Province *Location              `protobuf:"bytes,1,opt,name=province,proto3" json:"province,omitempty"`
+	City     *Location              `protobuf:"bytes,2,opt,name=city,proto3" json:"city,omitempty"`
+	// hijri_year is set for the fasting schedule only
+	HijriYear     string       `protobuf:"bytes,3,opt,name=hijri_year,json=hijriYear,proto3" json:"hijri_year,omitempty"`
+	Days          []*PrayerDay `protobuf:"bytes,4,rep,name=days,proto3" json:"days,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Schedule) Reset() {
+	*x = Schedule{}
+	mi := &file_prayer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Schedule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Schedule) ProtoMessage() {}
+
+func (x *Schedule) ProtoReflect() protoreflect.Message {
+	mi := &file_prayer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Schedule.ProtoReflect.Descriptor instead.
+func (*Schedule) Descriptor() ([]byte, []int) {
+	return file_prayer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Schedule) GetProvince() *Location {
+	if x != nil {
+		return x.Province
+	}
+	return nil
+}
+
+func (x *Schedule) GetCity() *Location {
+	if x != nil {
+		return x.City
+	}
+	return nil
+}
+
+func (x *Schedule) GetHijriYear() string {
+	if x != nil {
+		return x.HijriYear
+	}
+	return ""
+}
+
+func (x *Schedule) GetDays() []*PrayerDay {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+var File_prayer_proto protoreflect.FileDescriptor
+
+const file_prayer_proto_rawDesc = "" +
+	"\n" +
+	"\fprayer.proto\x12\n" +
+	"adminbe.v1\"2\n" +
+	"\bLocation\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x16\n" +
+	"\x14ListProvincesRequest\"/\n" +
+	"\x11ListCitiesRequest\x12\x1a\n" +
+	"\bprovince\x18\x01 \x01(\tR\bprovince\"K\n" +
+	"\x15ListLocationsResponse\x122\n" +
+	"\tlocations\x18\x01 \x03(\v2\x14.adminbe.v1.LocationR\tlocations\"w\n" +
+	"\x12GetScheduleRequest\x12\x1a\n" +
+	"\bprovince\x18\x01 \x01(\tR\bprovince\x12\x12\n" +
+	"\x04city\x18\x02 \x01(\tR\x04city\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x03 \x01(\tR\tstartDate\x12\x12\n" +
+	"\x04days\x18\x04 \x01(\x05R\x04days\"_\n" +
+	"\x19GetFastingScheduleRequest\x12\x1a\n" +
+	"\bprovince\x18\x01 \x01(\tR\bprovince\x12\x12\n" +
+	"\x04city\x18\x02 \x01(\tR\x04city\x12\x12\n" +
+	"\x04year\x18\x03 \x01(\x05R\x04year\"\xd5\x01\n" +
+	"\tPrayerDay\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12\x14\n" +
+	"\x05imsak\x18\x02 \x01(\tR\x05imsak\x12\x14\n" +
+	"\x05subuh\x18\x03 \x01(\tR\x05subuh\x12\x16\n" +
+	"\x06terbit\x18\x04 \x01(\tR\x06terbit\x12\x14\n" +
+	"\x05dhuha\x18\x05 \x01(\tR\x05dhuha\x12\x16\n" +
+	"\x06dzuhur\x18\x06 \x01(\tR\x06dzuhur\x12\x14\n" +
+	"\x05ashar\x18\a \x01(\tR\x05ashar\x12\x18\n" +
+	"\amaghrib\x18\b \x01(\tR\amaghrib\x12\x12\n" +
+	"\x04isya\x18\t \x01(\tR\x04isya\"\xb0\x01\n" +
+	"\bSchedule\x120\n" +
+	"\bprovince\x18\x01 \x01(\v2\x14.adminbe.v1.LocationR\bprovince\x12(\n" +
+	"\x04city\x18\x02 \x01(\v2\x14.adminbe.v1.LocationR\x04city\x12\x1d\n" +
+	"\n" +
+	"hijri_year\x18\x03 \x01(\tR\thijriYear\x12)\n" +
+	"\x04days\x18\x04 \x03(\v2\x15.adminbe.v1.PrayerDayR\x04days2\xcd\x02\n" +
+	"\rPrayerService\x12T\n" +
+	"\rListProvinces\x12 .adminbe.v1.ListProvincesRequest\x1a!.adminbe.v1.ListLocationsResponse\x12N\n" +
+	"\n" +
+	"ListCities\x12\x1d.adminbe.v1.ListCitiesRequest\x1a!.adminbe.v1.ListLocationsResponse\x12C\n" +
+	"\vGetSchedule\x12\x1e.adminbe.v1.GetScheduleRequest\x1a\x14.adminbe.v1.Schedule\x12Q\n" +
+	"\x12GetFastingSchedule\x12%.adminbe.v1.GetFastingScheduleRequest\x1a\x14.adminbe.v1.ScheduleB\x15Z\x13adminbe/pkg/adminpbb\x06proto3"
+
+var (
+	file_prayer_proto_rawDescOnce sync.Once
+	file_prayer_proto_rawDescData []byte
+)
+
+func file_prayer_proto_rawDescGZIP() []byte {
+	file_prayer_proto_rawDescOnce.Do(func() {
+		file_prayer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_prayer_proto_rawDesc), len(file_prayer_proto_rawDesc)))
+	})
+	return file_prayer_proto_rawDescData
+}
+
+var file_prayer_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_prayer_proto_goTypes = []any{
+	(*Location)(nil),                  // 0: adminbe.v1.Location
+	(*ListProvincesRequest)(nil),      // 1: adminbe.v1.ListProvincesRequest
+	(*ListCitiesRequest)(nil),         // 2: adminbe.v1.ListCitiesRequest
+	(*ListLocationsResponse)(nil),     // 3: adminbe.v1.ListLocationsResponse
+	(*GetScheduleRequest)(nil),        // 4: adminbe.v1.GetScheduleRequest
+	(*GetFastingScheduleRequest)(nil), // 5: adminbe.v1.GetFastingScheduleRequest
+	(*PrayerDay)(nil),                 // 6: adminbe.v1.PrayerDay
+	(*Schedule)(nil),                  // 7: adminbe.v1.Schedule
+}
+var file_prayer_proto_depIdxs = []int32{
+	0, // 0: adminbe.v1.ListLocationsResponse.locations:type_name -> adminbe.v1.Location
+	0, // 1: adminbe.v1.Schedule.province:type_name -> adminbe.v1.Location
+	0, // 2: adminbe.v1.Schedule.city:type_name -> adminbe.v1.Location
+	6, // 3: adminbe.v1.Schedule.days:type_name -> adminbe.v1.PrayerDay
+	1, // 4: adminbe.v1.PrayerService.ListProvinces:input_type -> adminbe.v1.ListProvincesRequest
+	2, // 5: adminbe.v1.PrayerService.ListCities:input_type -> adminbe.v1.ListCitiesRequest
+	4, // 6: adminbe.v1.PrayerService.GetSchedule:input_type -> adminbe.v1.GetScheduleRequest
+	5, // 7: adminbe.v1.PrayerService.GetFastingSchedule:input_type -> adminbe.v1.GetFastingScheduleRequest
+	3, // 8: adminbe.v1.PrayerService.ListProvinces:output_type -> adminbe.v1.ListLocationsResponse
+	3, // 9: adminbe.v1.PrayerService.ListCities:output_type -> adminbe.v1.ListLocationsResponse
+	7, // 10: adminbe.v1.PrayerService.GetSchedule:output_type -> adminbe.v1.Schedule
+	7, // 11: adminbe.v1.PrayerService.GetFastingSchedule:output_type -> adminbe.v1.Schedule
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_prayer_proto_init() }
+func file_prayer_proto_init() {
+	if File_prayer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_prayer_proto_rawDesc), len(file_prayer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_prayer_proto_goTypes,
+		DependencyIndexes: file_prayer_proto_depIdxs,
+		MessageInfos:      file_prayer_proto_msgTypes,
+	}.Build()
+	File_prayer_proto = out.File
+	file_prayer_proto_goTypes = nil
+	file_prayer_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.31.1
+// source: prayer.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PrayerService_ListProvinces_FullMethodName      = "/adminbe.v1.PrayerService/ListProvinces"
+	PrayerService_ListCities_FullMethodName         = "/adminbe.v1.PrayerService/ListCities"
+	PrayerService_GetSchedule_FullMethodName        = "/adminbe.v1.PrayerService/GetSchedule"
+	PrayerService_GetFastingSchedule_FullMethodName = "/adminbe.v1.PrayerService/GetFastingSchedule"
+)
+
+// PrayerServiceClient is the client API for PrayerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PrayerService computes prayer schedules. Locations are identified by the same opaque
+// codes as /api/v2/prayer, so codes can be shared between both APIs.
+type PrayerServiceClient interface {
+	// ListProvinces returns every province
+	ListProvinces(ctx context.Context, in *ListProvincesRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error)
+	// ListCities returns the cities and regencies of a province
+	ListCities(ctx context.Context, in *ListCitiesRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error)
+	// GetSchedule returns the prayer times of consecutive days at a location
+	GetSchedule(ctx context.Context, in *GetScheduleRequest, opts ...grpc.CallOption) (*Schedule, error)
+	// GetFastingSchedule returns the prayer times of a year's fasting period (imsakiyah)
+	GetFastingSchedule(ctx context.Context, in *GetFastingScheduleRequest, opts ...grpc.CallOption) (*Schedule, error)
+}
+
+type prayerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPrayerServiceClient(cc grpc.ClientConnInterface) PrayerServiceClient {
+	return &prayerServiceClient{cc}
+}
+
+func (c *prayerServiceClient) ListProvinces(ctx context.Context, in *ListProvincesRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListLocationsResponse)
+	err := c.cc.Invoke(ctx, PrayerService_ListProvinces_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prayerServiceClient) ListCities(ctx context.Context, in *ListCitiesRequest, opts ...grpc.CallOption) (*ListLocationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListLocationsResponse)
+	err := c.cc.Invoke(ctx, PrayerService_ListCities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prayerServiceClient) GetSchedule(ctx context.Context, in *GetScheduleRequest, opts ...grpc.CallOption) (*Schedule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Schedule)
+	err := c.cc.Invoke(ctx, PrayerService_GetSchedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *prayerServiceClient) GetFastingSchedule(ctx context.Context, in *GetFastingScheduleRequest, opts ...grpc.CallOption) (*Schedule, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Schedule)
+	err := c.cc.Invoke(ctx, PrayerService_GetFastingSchedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PrayerServiceServer is the server API for PrayerService service.
+// All implementations must embed UnimplementedPrayerServiceServer
+// for forward compatibility.
+//
+// PrayerService computes prayer schedules. Locations are identified by the same opaque
+// codes as /api/v2/prayer, so codes can be shared between both APIs.
+type PrayerServiceServer interface {
+	// ListProvinces returns every province
+	ListProvinces(context.Context, *ListProvincesRequest) (*ListLocationsResponse, error)
+	// ListCities returns the cities and regencies of a province
+	ListCities(context.Context, *ListCitiesRequest) (*ListLocationsResponse, error)
+	// GetSchedule returns the prayer times of consecutive days at a location
+	GetSchedule(context.Context, *GetScheduleRequest) (*Schedule, error)
+	// GetFastingSchedule returns the prayer times of a year's fasting period (imsakiyah)
+	GetFastingSchedule(context.Context, *GetFastingScheduleRequest) (*Schedule, error)
+	mustEmbedUnimplementedPrayerServiceServer()
+}
+
+// UnimplementedPrayerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPrayerServiceServer struct{}
+
+func (UnimplementedPrayerServiceServer) ListProvinces(context.Context, *ListProvincesRequest) (*ListLocationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProvinces not implemented")
+}
+func (UnimplementedPrayerServiceServer) ListCities(context.Context, *ListCitiesRequest) (*ListLocationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCities not implemented")
+}
+func (UnimplementedPrayerServiceServer) GetSchedule(context.Context, *GetScheduleRequest) (*Schedule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchedule not implemented")
+}
+func (UnimplementedPrayerServiceServer) GetFastingSchedule(context.Context, *GetFastingScheduleRequest) (*Schedule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFastingSchedule not implemented")
+}
+func (UnimplementedPrayerServiceServer) mustEmbedUnimplementedPrayerServiceServer() {}
+func (UnimplementedPrayerServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafePrayerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PrayerServiceServer will
+// result in compilation errors.
+type UnsafePrayerServiceServer interface {
+	mustEmbedUnimplementedPrayerServiceServer()
+}
+
+func RegisterPrayerServiceServer(s grpc.ServiceRegistrar, srv PrayerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPrayerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PrayerService_ServiceDesc, srv)
+}
+
+func _PrayerService_ListProvinces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProvincesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrayerServiceServer).ListProvinces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrayerService_ListProvinces_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrayerServiceServer).ListProvinces(ctx, req.(*ListProvincesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrayerService_ListCities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrayerServiceServer).ListCities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrayerService_ListCities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrayerServiceServer).ListCities(ctx, req.(*ListCitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrayerService_GetSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrayerServiceServer).GetSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrayerService_GetSchedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrayerServiceServer).GetSchedule(ctx, req.(*GetScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PrayerService_GetFastingSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFastingScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PrayerServiceServer).GetFastingSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PrayerService_GetFastingSchedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PrayerServiceServer).GetFastingSchedule(ctx, req.(*GetFastingScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PrayerService_ServiceDesc is the grpc.ServiceDesc for PrayerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PrayerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "adminbe.v1.PrayerService",
+	HandlerType: (*PrayerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListProvinces",
+			Handler:    _PrayerService_ListProvinces_Handler,
+		},
+		{
+			MethodName: "ListCities",
+			Handler:    _PrayerService_ListCities_Handler,
+		},
+		{
+			MethodName: "GetSchedule",
+			Handler:    _PrayerService_GetSchedule_Handler,
+		},
+		{
+			MethodName: "GetFastingSchedule",
+			Handler:    _PrayerService_GetFastingSchedule_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "prayer.proto",
+}
@@ -3,29 +3,48 @@ package jasper
 import (
 	"adminbe/internal/app/models"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 // Client handles JasperServer REST API operations
 type Client struct {
-	config *models.JasperServerConfig
+	config atomic.Pointer[models.JasperServerConfig]
 	client *http.Client
 }
 
 // NewClient creates a new JasperServer client
 func NewClient(config *models.JasperServerConfig) *Client {
-	return &Client{
-		config: config,
-		client: &http.Client{},
+	c := &Client{client: &http.Client{}}
+	c.SetConfig(*config)
+	return c
+}
+
+// SetConfig points the client at another server or account; calls already running finish
+// with the old one
+func (c *Client) SetConfig(config models.JasperServerConfig) {
+	c.config.Store(&config)
+}
+
+// organizationKey is the context key of the organization a call runs reports for
+type organizationKey struct{}
+
+// WithOrganization returns ctx running reports for organization instead of the configured
+// one; an empty organization keeps it
+func WithOrganization(ctx context.Context, organization string) context.Context {
+	if organization == "" {
+		return ctx
 	}
+	return context.WithValue(ctx, organizationKey{}, organization)
 }
 
 // createRequest creates HTTP request with basic auth
-func (c *Client) createRequest(method, url string, body interface{}) (*http.Request, error) {
+func (c *Client) createRequest(ctx context.Context, config *models.JasperServerConfig, method, url string, body interface{}) (*http.Request, error) {
 	var bodyReader io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -35,30 +54,36 @@ func (c *Client) createRequest(method, url string, body interface{}) (*http.Requ
 		bodyReader = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
 	// Add basic auth
-	req.SetBasicAuth(c.config.Username, c.config.Password)
+	req.SetBasicAuth(config.Username, config.Password)
 
 	// Add headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	// Add organization header if specified
-	if c.config.Organization != "" {
-		req.Header.Set("organization", c.config.Organization)
+	// Add organization header if specified, by ctx first
+	organization, _ := ctx.Value(organizationKey{}).(string)
+	if organization == "" {
+		organization = config.Organization
+	}
+	if organization != "" {
+		req.Header.Set("organization", organization)
 	}
 
 	return req, nil
 }
 
-// RunReport runs a JasperServer report
-func (c *Client) RunReport(req *models.JasperReportRequest) (*models.JasperReportResponse, []byte, error) {
+// RunReport runs a JasperServer report; the call is abandoned when ctx is done
+func (c *Client) RunReport(ctx context.Context, req *models.JasperReportRequest) (*models.JasperReportResponse, []byte, error) {
+	config := c.config.Load()
+
 	// Build URL for report execution
-	runURL := fmt.Sprintf("%s/rest_v2/reports%s.%s", c.config.BaseURL, req.ReportPath, req.OutputFormat)
+	runURL := fmt.Sprintf("%s/rest_v2/reports%s.%s", config.BaseURL, req.ReportPath, req.OutputFormat)
 
 	// Add query parameters
 	if len(req.Parameters) > 0 {
@@ -69,14 +94,14 @@ func (c *Client) RunReport(req *models.JasperReportRequest) (*models.JasperRepor
 		runURL += "?" + strings.Join(params, "&")
 	}
 
-	httpReq, err := c.createRequest("GET", runURL, nil)
+	httpReq, err := c.createRequest(ctx, config, "GET", runURL, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// For reports with POST parameters (if any), use POST method
 	if len(req.Parameters) > 0 {
-		runURL = fmt.Sprintf("%s/rest_v2/reports%s.%s", c.config.BaseURL, req.ReportPath, req.OutputFormat)
+		runURL = fmt.Sprintf("%s/rest_v2/reports%s.%s", config.BaseURL, req.ReportPath, req.OutputFormat)
 		paramBody := map[string]interface{}{
 			"reportParameter": req.Parameters,
 		}
@@ -90,21 +115,15 @@ func (c *Client) RunReport(req *models.JasperReportRequest) (*models.JasperRepor
 			paramBody["pages"] = req.Pages
 		}
 
-		httpReq, err = c.createRequest("POST", runURL, paramBody)
+		httpReq, err = c.createRequest(ctx, config, "POST", runURL, paramBody)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create POST request: %w", err)
 		}
 	}
 
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	resp, body, err := c.do("run_report", httpReq)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -131,28 +150,27 @@ func (c *Client) RunReport(req *models.JasperReportRequest) (*models.JasperRepor
 	return &jasResp, body, nil
 }
 
-// GetServerInfo retrieves JasperServer information
-func (c *Client) GetServerInfo() (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/rest_v2/serverInfo", c.config.BaseURL)
+// GetServerInfo retrieves JasperServer information; the call is abandoned when ctx is done
+func (c *Client) GetServerInfo(ctx context.Context) (map[string]interface{}, error) {
+	config := c.config.Load()
+	url := fmt.Sprintf("%s/rest_v2/serverInfo", config.BaseURL)
 
-	req, err := c.createRequest("GET", url, nil)
+	req, err := c.createRequest(ctx, config, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.client.Do(req)
+	resp, body, err := c.do("server_info", req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("failed to get server info: %s", body)
 	}
 
 	var info map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+	if err := json.Unmarshal(body, &info); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,47 @@
+package jasper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"adminbe/internal/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var jasperCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "jasper",
+	Name:      "request_duration_seconds",
+	Help:      "JasperServer call latency, including reading the response body, by operation and status code (error when no response arrived).",
+	Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+}, []string{"operation", "code"})
+
+func init() {
+	metrics.Registry.MustRegister(jasperCallDuration)
+}
+
+// do sends req, reads the whole response body and records the call under operation
+func (c *Client) do(operation string, req *http.Request) (*http.Response, []byte, error) {
+	start := time.Now()
+	code := "error"
+	defer func() {
+		jasperCallDuration.WithLabelValues(operation, code).Observe(time.Since(start).Seconds())
+	}()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	code = strconv.Itoa(resp.StatusCode)
+	return resp, body, nil
+}
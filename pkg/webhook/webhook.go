@@ -0,0 +1,73 @@
+// Package webhook signs the outbound webhook requests adminbe sends, and verifies them for
+// receivers written in Go.
+//
+// Each request is a POST of a JSON Payload. X-Webhook-Signature holds "sha256=" and the hex
+// HMAC-SHA256, keyed by the webhook's secret, of the X-Webhook-Timestamp value, a period and
+// the raw body. The timestamp is in Unix seconds and is signed so that a captured request
+// cannot be replayed later.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers
+const (
+	// HeaderID is the event ID, the same on every attempt; receivers can deduplicate on it
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the body of a webhook request
+type Payload struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       Data      `json:"data"`
+}
+
+// Data identifies the changed entity; receivers read its current state from the API
+type Data struct {
+	Entity string `json:"entity"`
+	ID     string `json:"id"`
+}
+
+// Verification errors
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpired          = errors.New("webhook: timestamp outside tolerance")
+)
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the X-Webhook-Signature and X-Webhook-Timestamp values of a request against
+// its raw body, rejecting timestamps more than tolerance away from now
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+	if !strings.HasPrefix(signature, "sha256=") ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}